@@ -3,6 +3,7 @@ package balancers
 import (
 	"container/list"
 	"fmt"
+	"maps"
 	"math/rand/v2"
 	"slices"
 
@@ -18,20 +19,29 @@ type Balancer interface {
 
 // 按权重概率抽取，类似抽签。
 type Lottery struct {
-	store   map[uint]int
-	success uint
-	fails   map[uint]struct{}
-	reduces map[uint]struct{}
+	store     map[uint]int
+	success   uint
+	fails     map[uint]struct{}
+	reduces   map[uint]struct{}
+	minWeight int
 }
 
 func NewLottery(items map[uint]int) *Lottery {
 	return &Lottery{
-		store:   items,
+		// 复制一份，Delete/Reduce 不能修改调用方的候选集合
+		store:   maps.Clone(items),
 		fails:   map[uint]struct{}{},
 		reduces: map[uint]struct{}{},
 	}
 }
 
+// NewLotteryWithFloor 创建带最低权重的 Lottery：Reduce 降权不会低于 minWeight，避免候选被饿死
+func NewLotteryWithFloor(items map[uint]int, minWeight int) *Lottery {
+	w := NewLottery(items)
+	w.minWeight = minWeight
+	return w
+}
+
 func (w *Lottery) Pop() (uint, error) {
 	if len(w.store) == 0 {
 		return 0, fmt.Errorf("no provide items or all items are disabled")
@@ -60,7 +70,18 @@ func (w *Lottery) Delete(key uint) {
 
 func (w *Lottery) Reduce(key uint) {
 	w.reduces[key] = struct{}{}
-	w.store[key] -= w.store[key] / 3
+	// 只降低已有候选的权重，不能因 Reduce 引入候选集合之外的 key
+	if weight, ok := w.store[key]; ok {
+		w.store[key] = weightFloor(weight-weight/3, weight, w.minWeight)
+	}
+}
+
+// weightFloor 降权后的权重不低于下限，但下限不会把原本就低于它的权重抬高
+func weightFloor[T int | float64](reduced T, current T, floor T) T {
+	if floor <= 0 || reduced >= floor {
+		return reduced
+	}
+	return min(floor, current)
 }
 
 func (w *Lottery) Success(key uint) {
@@ -0,0 +1,130 @@
+package balancers
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+)
+
+// TestBalancersStayWithinCandidates 无论怎样 Delete/Reduce（包括候选集合之外的 key），所有策略都只会返回原候选集合中的 key
+func TestBalancersStayWithinCandidates(t *testing.T) {
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			items := map[uint]int{1: 3, 2: 1, 3: 2}
+			orig := maps.Clone(items)
+			opts := Options{
+				Costs:     map[uint]float64{1: 1, 2: 2, 99: 0},
+				Latencies: map[uint]float64{1: 100, 99: 1},
+			}
+			b, ok := New(name, items, opts)
+			if !ok {
+				t.Fatalf("strategy %s not registered", name)
+			}
+			b = BalancerWrapperFailover(b, items)
+
+			seen := map[uint]int{}
+			for round := 0; ; round++ {
+				if round > 100 {
+					t.Fatal("balancer never exhausted")
+				}
+				id, err := b.Pop()
+				if err != nil {
+					break
+				}
+				if _, ok := orig[id]; !ok {
+					t.Fatalf("popped %d outside candidates %v", id, orig)
+				}
+				seen[id]++
+				// 候选集合之外的 key 被 Reduce/Delete 不能引入新候选
+				b.Reduce(99)
+				b.Delete(100)
+				// 每个候选先降权一次，再次选中时删除
+				if seen[id] == 1 {
+					b.Reduce(id)
+				} else {
+					b.Delete(id)
+				}
+			}
+			if !maps.Equal(items, orig) {
+				t.Fatalf("caller items mutated: %v, want %v", items, orig)
+			}
+		})
+	}
+}
+
+func TestWeightFloor(t *testing.T) {
+	tests := []struct {
+		reduced, current, floor, want int
+	}{
+		{60, 90, 0, 60},  // 未设下限
+		{60, 90, 10, 60}, // 高于下限
+		{8, 12, 10, 10},  // 降到下限
+		{3, 4, 10, 4},    // 原本低于下限的权重不被抬高
+	}
+	for _, tt := range tests {
+		if got := weightFloor(tt.reduced, tt.current, tt.floor); got != tt.want {
+			t.Errorf("weightFloor(%d, %d, %d) = %d, want %d", tt.reduced, tt.current, tt.floor, got, tt.want)
+		}
+	}
+}
+
+// popCounts 抽取 n 次，返回各 key 被选中的次数
+func popCounts(t *testing.T, b Balancer, n int) map[uint]int {
+	t.Helper()
+	counts := make(map[uint]int)
+	for range n {
+		key, err := b.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[key]++
+	}
+	return counts
+}
+
+func TestLotteryMinWeightKeepsReducedProviderSelectable(t *testing.T) {
+	const pops = 3000
+	reduced := func(minWeight int) *Lottery {
+		b, ok := New(consts.BalancerLottery, map[uint]int{1: 90, 2: 90}, Options{MinWeight: minWeight})
+		if !ok {
+			t.Fatal("lottery not registered")
+		}
+		lottery := b.(*Lottery)
+		for range 20 {
+			lottery.Reduce(1)
+		}
+		return lottery
+	}
+
+	floored := reduced(10)
+	if w := floored.store[1]; w != 10 {
+		t.Fatalf("weight with floor = %d, want 10", w)
+	}
+	// 期望约 10/100 的流量，取宽松下限避免随机波动
+	if n := popCounts(t, floored, pops)[1]; n < pops/20 {
+		t.Fatalf("reduced provider selected %d/%d times, want occasional traffic", n, pops)
+	}
+
+	unfloored := reduced(0)
+	if w := unfloored.store[1]; w >= 10 {
+		t.Fatalf("weight without floor = %d, want decayed below 10", w)
+	}
+	if unfloored.store[2] != 90 {
+		t.Fatalf("other provider weight changed: %d", unfloored.store[2])
+	}
+}
+
+func TestCostMinWeightScaledByPrice(t *testing.T) {
+	// 有效权重与下限按同一单价换算：90/3=30，下限 9/3=3
+	b := NewCost(map[uint]int{1: 90, 2: 90}, Options{MinWeight: 9, Costs: map[uint]float64{1: 3}})
+	for range 30 {
+		b.Reduce(1)
+	}
+	if w := b.store[1]; w != 3 {
+		t.Fatalf("effective weight = %v, want floor 3", w)
+	}
+	if n := popCounts(t, b, 3000)[1]; n == 0 {
+		t.Fatal("reduced provider never selected")
+	}
+}
@@ -1,6 +1,8 @@
 package balancers
 
 import (
+	"cmp"
+	"slices"
 	"sync"
 	"time"
 )
@@ -13,11 +15,24 @@ const (
 	StateHalfOpen              // 探测恢复
 )
 
+// String 返回状态名称
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "halfopen"
+	default:
+		return "closed"
+	}
+}
+
 type Node struct {
 	state        State     // 熔断状态
 	failCount    int       // 失败次数
 	successCount int       // 成功次数
 	expiry       time.Time // 冷却结束时间
+	lastUsed     time.Time // 最近一次被选中或上报结果的时间，用于清理长期闲置的节点
 }
 
 func (n *Node) Reset(state State) {
@@ -26,30 +41,65 @@ func (n *Node) Reset(state State) {
 	n.successCount = 0
 }
 
+// BreakerConfig 熔断参数，按模型配置，未设置（<=0）的项使用默认值
+type BreakerConfig struct {
+	MaxFailures      int           // 最多失败次数，达到后熔断
+	SleepWindow      time.Duration // 熔断后的冷却时间
+	HalfOpenRequests int           // 在 HalfOpen 状态下, 如果请求成功次数达到此数值，熔断器关闭（恢复）；如果有一个失败，重新进入 Open 状态
+}
+
+// DefaultBreakerConfig 模型未配置熔断参数时的默认值
+var DefaultBreakerConfig = BreakerConfig{
+	MaxFailures:      5,
+	SleepWindow:      60 * time.Second,
+	HalfOpenRequests: 2,
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = DefaultBreakerConfig.MaxFailures
+	}
+	if c.SleepWindow <= 0 {
+		c.SleepWindow = DefaultBreakerConfig.SleepWindow
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = DefaultBreakerConfig.HalfOpenRequests
+	}
+	return c
+}
+
+// nodeKey 熔断节点按模型 + 模型-提供商关联区分，不同模型不共享熔断状态
+type nodeKey struct {
+	model uint
+	key   uint
+}
+
 var (
-	mu          sync.Mutex
-	nodes       = make(map[uint]*Node)
-	MaxFailures = 5                // 最多失败次数
-	SleepWindow = 60 * time.Second // 冷却时间
-	MaxRequests = 2                // 在 HalfOpen 状态下, 如果请求成功次数超过此数值，熔断器关闭（恢复）；如果有一个失败，重新进入 Open 状态
+	mu    sync.Mutex
+	nodes = make(map[nodeKey]*Node)
 )
 
 type Breaker struct {
 	Balancer
+	model  uint
+	config BreakerConfig
 }
 
-func BalancerWrapperBreaker(balancer Balancer) *Breaker {
+func BalancerWrapperBreaker(balancer Balancer, model uint, config BreakerConfig) *Breaker {
 	mu.Lock()
 	defer mu.Unlock()
-	for key, node := range nodes {
+	for nk, node := range nodes {
+		if nk.model != model {
+			continue
+		}
 		if node.state == StateOpen && node.expiry.Before(time.Now()) {
 			node.Reset(StateHalfOpen)
 		}
 		if node.state == StateOpen {
-			balancer.Delete(key)
+			balancer.Delete(nk.key)
 		}
 	}
-	return &Breaker{Balancer: balancer}
+	return &Breaker{Balancer: balancer, model: model, config: config.withDefaults()}
 }
 
 func (b *Breaker) Pop() (uint, error) {
@@ -59,9 +109,13 @@ func (b *Breaker) Pop() (uint, error) {
 	}
 	mu.Lock()
 	defer mu.Unlock()
-	if _, ok := nodes[key]; !ok {
-		nodes[key] = &Node{state: StateClosed}
+	nk := nodeKey{model: b.model, key: key}
+	node, ok := nodes[nk]
+	if !ok {
+		node = &Node{state: StateClosed}
+		nodes[nk] = node
 	}
+	node.lastUsed = time.Now()
 	return key, nil
 }
 
@@ -78,16 +132,17 @@ func (b *Breaker) Reduce(key uint) {
 func (b *Breaker) failCountAdd(key uint) {
 	mu.Lock()
 	defer mu.Unlock()
-	if node, ok := nodes[key]; ok {
+	if node, ok := nodes[nodeKey{model: b.model, key: key}]; ok {
+		node.lastUsed = time.Now()
 		node.failCount += 1
-		if node.state == StateClosed && node.failCount >= MaxFailures {
+		if node.state == StateClosed && node.failCount >= b.config.MaxFailures {
 			node.Reset(StateOpen)
-			node.expiry = time.Now().Add(SleepWindow)
+			node.expiry = time.Now().Add(b.config.SleepWindow)
 		}
 
 		if node.state == StateHalfOpen {
 			node.Reset(StateOpen)
-			node.expiry = time.Now().Add(SleepWindow)
+			node.expiry = time.Now().Add(b.config.SleepWindow)
 		}
 	}
 }
@@ -95,13 +150,81 @@ func (b *Breaker) failCountAdd(key uint) {
 func (b *Breaker) Success(key uint) {
 	mu.Lock()
 	defer mu.Unlock()
-	if node, ok := nodes[key]; ok {
+	if node, ok := nodes[nodeKey{model: b.model, key: key}]; ok {
+		node.lastUsed = time.Now()
 		if node.state == StateHalfOpen {
 			node.successCount += 1
-			if node.successCount >= MaxRequests {
+			if node.successCount >= b.config.HalfOpenRequests {
 				node.Reset(StateClosed)
 			}
 		}
 	}
 	b.Balancer.Success(key)
 }
+
+// NodeSnapshot 熔断节点的只读快照
+type NodeSnapshot struct {
+	ModelID      uint       `json:"model_id"`
+	Key          uint       `json:"key"` // 模型-提供商关联 ID
+	State        string     `json:"state"`
+	FailCount    int        `json:"fail_count"`
+	SuccessCount int        `json:"success_count"`
+	Expiry       *time.Time `json:"expiry,omitempty"` // 仅 open 状态下有效的冷却结束时间
+}
+
+// Snapshot 返回当前所有熔断节点的状态副本（按模型、key 排序），不会修改节点状态
+func Snapshot() []NodeSnapshot {
+	mu.Lock()
+	defer mu.Unlock()
+	result := make([]NodeSnapshot, 0, len(nodes))
+	for nk, node := range nodes {
+		item := NodeSnapshot{
+			ModelID:      nk.model,
+			Key:          nk.key,
+			State:        node.state.String(),
+			FailCount:    node.failCount,
+			SuccessCount: node.successCount,
+		}
+		if node.state == StateOpen {
+			expiry := node.expiry
+			item.Expiry = &expiry
+		}
+		result = append(result, item)
+	}
+	slices.SortFunc(result, func(a, b NodeSnapshot) int {
+		return cmp.Or(cmp.Compare(a.ModelID, b.ModelID), cmp.Compare(a.Key, b.Key))
+	})
+	return result
+}
+
+// ResetBreaker 移除模型-提供商关联在所有模型下的熔断节点，关联恢复为 closed 状态；返回是否存在节点
+func ResetBreaker(key uint) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	found := false
+	for nk := range nodes {
+		if nk.key == key {
+			delete(nodes, nk)
+			found = true
+		}
+	}
+	return found
+}
+
+// SweepIdleBreakers 移除闲置超过 idle 的熔断节点（仍在冷却中的 open 节点保留），返回移除数量
+func SweepIdleBreakers(idle time.Duration, now time.Time) int {
+	mu.Lock()
+	defer mu.Unlock()
+	removed := 0
+	for nk, node := range nodes {
+		if now.Sub(node.lastUsed) < idle {
+			continue
+		}
+		if node.state == StateOpen && node.expiry.After(now) {
+			continue
+		}
+		delete(nodes, nk)
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,109 @@
+package balancers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	cooldownMu sync.Mutex
+	cooldowns  = make(map[uint]time.Time) // 关联 ID -> 冷却结束时间（上游 429 的 Retry-After）
+)
+
+// SetCooldown 标记关联在 d 时间内不可用，跨请求生效；已有更晚的冷却时间时保留较晚者
+func SetCooldown(key uint, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	until := time.Now().Add(d)
+	cooldownMu.Lock()
+	defer cooldownMu.Unlock()
+	if cur, ok := cooldowns[key]; ok && cur.After(until) {
+		return
+	}
+	cooldowns[key] = until
+}
+
+// cooldownUntil 返回关联的冷却结束时间，未冷却或已过期时返回 false
+func cooldownUntil(key uint) (time.Time, bool) {
+	cooldownMu.Lock()
+	defer cooldownMu.Unlock()
+	until, ok := cooldowns[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !until.After(time.Now()) {
+		delete(cooldowns, key)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// CooldownError 剩余候选全部处于冷却中，Until 为最早可用的时间
+type CooldownError struct {
+	Until time.Time
+}
+
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("all providers cooling down until %s", e.Until.Format(time.RFC3339))
+}
+
+// Cooldown 跳过冷却中的候选：Pop 命中冷却中的关联时将其暂存，冷却结束后优先返回；
+// 其余候选耗尽时返回 CooldownError，由调用方决定是否等待
+type Cooldown struct {
+	Balancer
+	parked map[uint]struct{}
+}
+
+func BalancerWrapperCooldown(balancer Balancer) *Cooldown {
+	return &Cooldown{Balancer: balancer, parked: map[uint]struct{}{}}
+}
+
+func (b *Cooldown) Pop() (uint, error) {
+	var earliest time.Time
+	for key := range b.parked {
+		until, cooling := cooldownUntil(key)
+		if !cooling {
+			return key, nil
+		}
+		if earliest.IsZero() || until.Before(earliest) {
+			earliest = until
+		}
+	}
+	for {
+		key, err := b.Balancer.Pop()
+		if err != nil {
+			if len(b.parked) > 0 {
+				return 0, &CooldownError{Until: earliest}
+			}
+			return 0, err
+		}
+		until, cooling := cooldownUntil(key)
+		if !cooling {
+			return key, nil
+		}
+		// 移出底层负载均衡器，由本层暂存到冷却结束
+		b.Balancer.Delete(key)
+		b.parked[key] = struct{}{}
+		if earliest.IsZero() || until.Before(earliest) {
+			earliest = until
+		}
+	}
+}
+
+func (b *Cooldown) Delete(key uint) {
+	if _, ok := b.parked[key]; ok {
+		delete(b.parked, key)
+		return
+	}
+	b.Balancer.Delete(key)
+}
+
+func (b *Cooldown) Reduce(key uint) {
+	// 暂存的候选只在冷却结束后返回，无需再调整
+	if _, ok := b.parked[key]; ok {
+		return
+	}
+	b.Balancer.Reduce(key)
+}
@@ -0,0 +1,80 @@
+package balancers
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// 按有效权重概率抽取：有效权重 = 权重 / (输入单价 + 输出单价)，价格未知的按原始权重
+type Cost struct {
+	store   map[uint]float64
+	floors  map[uint]float64 // 关联 ID -> 按同样方式换算的最低有效权重
+	success uint
+	fails   map[uint]struct{}
+	reduces map[uint]struct{}
+}
+
+func NewCost(items map[uint]int, opts Options) *Cost {
+	store := make(map[uint]float64, len(items))
+	floors := make(map[uint]float64, len(items))
+	for key, weight := range items {
+		effective := float64(weight)
+		floor := float64(opts.MinWeight)
+		if cost, ok := opts.Costs[key]; ok && cost > 0 {
+			effective /= cost
+			floor /= cost
+		}
+		store[key] = effective
+		floors[key] = floor
+	}
+	return &Cost{
+		store:   store,
+		floors:  floors,
+		fails:   map[uint]struct{}{},
+		reduces: map[uint]struct{}{},
+	}
+}
+
+func (w *Cost) Pop() (uint, error) {
+	if len(w.store) == 0 {
+		return 0, fmt.Errorf("no provide items or all items are disabled")
+	}
+	total := 0.0
+	for _, v := range w.store {
+		total += v
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("total provide weight must be greater than 0")
+	}
+	r := rand.Float64() * total
+	var last uint
+	for k, v := range w.store {
+		if v <= 0 {
+			continue
+		}
+		if r < v {
+			return k, nil
+		}
+		r -= v
+		last = k
+	}
+	// 浮点累加误差兜底
+	return last, nil
+}
+
+func (w *Cost) Delete(key uint) {
+	w.fails[key] = struct{}{}
+	delete(w.store, key)
+}
+
+func (w *Cost) Reduce(key uint) {
+	w.reduces[key] = struct{}{}
+	// 只降低已有候选的权重，不能因 Reduce 引入候选集合之外的 key
+	if weight, ok := w.store[key]; ok {
+		w.store[key] = weightFloor(weight-weight/3, weight, w.floors[key])
+	}
+}
+
+func (w *Cost) Success(key uint) {
+	w.success = key
+}
@@ -0,0 +1,79 @@
+package balancers
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// 按成本从低到高选择：优先最便宜且成功率达标的提供商，失败或被限流时再升级到更贵的提供商
+type CostAware struct {
+	order   []uint
+	success uint
+	fails   map[uint]struct{}
+	reduces map[uint]struct{}
+}
+
+func NewCostAware(items map[uint]int, opts Options) *CostAware {
+	order := make([]uint, 0, len(items))
+	for key := range items {
+		order = append(order, key)
+	}
+	// 低于质量下限的排到最后；其余按单价升序，价格未知的排在已知价格之后，同价按权重降序
+	belowFloor := func(key uint) bool {
+		rate, ok := opts.SuccessRates[key]
+		return ok && rate < opts.QualityFloor
+	}
+	slices.SortFunc(order, func(a, b uint) int {
+		if fa, fb := belowFloor(a), belowFloor(b); fa != fb {
+			if fa {
+				return 1
+			}
+			return -1
+		}
+		ca, okA := opts.Costs[a]
+		cb, okB := opts.Costs[b]
+		if okA != okB {
+			if okA {
+				return -1
+			}
+			return 1
+		}
+		if c := cmp.Compare(ca, cb); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(items[b], items[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	return &CostAware{
+		order:   order,
+		fails:   map[uint]struct{}{},
+		reduces: map[uint]struct{}{},
+	}
+}
+
+func (w *CostAware) Pop() (uint, error) {
+	if len(w.order) == 0 {
+		return 0, fmt.Errorf("no provide items")
+	}
+	return w.order[0], nil
+}
+
+func (w *CostAware) Delete(key uint) {
+	w.fails[key] = struct{}{}
+	w.order = slices.DeleteFunc(w.order, func(k uint) bool { return k == key })
+}
+
+// Reduce 被限流/暂时失败时移到队尾，下一次选择更贵的提供商
+func (w *CostAware) Reduce(key uint) {
+	w.reduces[key] = struct{}{}
+	if i := slices.Index(w.order, key); i >= 0 {
+		w.order = append(slices.Delete(w.order, i, i+1), key)
+	}
+}
+
+func (w *CostAware) Success(key uint) {
+	w.success = key
+}
@@ -0,0 +1,79 @@
+package balancers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+)
+
+// popOrder 依次 Pop 并 Delete，返回完整的选择顺序
+func popOrder(t *testing.T, b Balancer) []uint {
+	t.Helper()
+	var order []uint
+	for {
+		id, err := b.Pop()
+		if err != nil {
+			return order
+		}
+		order = append(order, id)
+		b.Delete(id)
+	}
+}
+
+func TestCostAwareOrder(t *testing.T) {
+	items := map[uint]int{1: 1, 2: 1, 3: 1, 4: 5, 5: 1, 6: 1}
+	opts := Options{
+		Costs: map[uint]float64{
+			1: 30, // 最贵
+			2: 5,  // 最便宜但低于质量下限
+			3: 10,
+			4: 10, // 与 3 同价，权重更高
+			// 5、6 价格未知
+		},
+		SuccessRates: map[uint]float64{2: 0.5, 3: 0.95, 6: 0.99},
+		QualityFloor: 0.8,
+	}
+	b, ok := New(consts.BalancerCostAware, items, opts)
+	if !ok {
+		t.Fatal("cost_aware not registered")
+	}
+	// 达标且有价格的按单价升序（同价按权重），然后是价格未知的，最后是低于质量下限的
+	want := []uint{4, 3, 1, 5, 6, 2}
+	if got := popOrder(t, b); !reflect.DeepEqual(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+}
+
+func TestCostAwareReduceEscalates(t *testing.T) {
+	items := map[uint]int{1: 1, 2: 1, 3: 1}
+	b := NewCostAware(items, Options{Costs: map[uint]float64{1: 1, 2: 2, 3: 3}})
+
+	if id, _ := b.Pop(); id != 1 {
+		t.Fatalf("first pop = %d, want cheapest 1", id)
+	}
+	// 最便宜的被限流：升级到次便宜的，被限流的排到最后但仍可用
+	b.Reduce(1)
+	if id, _ := b.Pop(); id != 2 {
+		t.Fatalf("after reduce pop = %d, want 2", id)
+	}
+	b.Delete(2)
+	if id, _ := b.Pop(); id != 3 {
+		t.Fatalf("after delete pop = %d, want 3", id)
+	}
+	b.Delete(3)
+	if id, _ := b.Pop(); id != 1 {
+		t.Fatalf("last pop = %d, want reduced 1", id)
+	}
+	b.Delete(1)
+	if _, err := b.Pop(); err == nil {
+		t.Fatal("expected error when all deleted")
+	}
+}
+
+func TestCostAwareWithoutPricesFallsBackToWeight(t *testing.T) {
+	b := NewCostAware(map[uint]int{1: 1, 2: 10, 3: 5}, Options{})
+	if got, want := popOrder(t, b), []uint{2, 3, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package balancers
+
+import "maps"
+
+// Failover 保证单次请求内的重试向前推进：失败过的候选（Reduce 后仍留在候选集合中）不会被立即再次选中，
+// 底层负载均衡器抽中失败过的候选时，改为选择剩余未失败候选中权重最高的一个；
+// 剩余候选全部失败过时才回退到底层的选择结果
+type Failover struct {
+	Balancer
+	weights map[uint]int      // 仍在候选集合中的关联 ID -> 配置权重
+	failed  map[uint]struct{} // 本次请求中已失败（被 Reduce）的关联 ID
+}
+
+// BalancerWrapperFailover 需直接包装底层负载均衡器，items 与创建底层负载均衡器时的候选集合一致
+func BalancerWrapperFailover(balancer Balancer, items map[uint]int) *Failover {
+	return &Failover{
+		Balancer: balancer,
+		weights:  maps.Clone(items),
+		failed:   map[uint]struct{}{},
+	}
+}
+
+func (b *Failover) Pop() (uint, error) {
+	key, err := b.Balancer.Pop()
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := b.failed[key]; !ok {
+		return key, nil
+	}
+	if next, ok := b.heaviest(); ok {
+		return next, nil
+	}
+	return key, nil
+}
+
+// heaviest 返回未失败候选中权重最高的关联，权重相同时取 ID 较小者，保证结果确定
+func (b *Failover) heaviest() (uint, bool) {
+	var best uint
+	found := false
+	for key, weight := range b.weights {
+		if _, ok := b.failed[key]; ok {
+			continue
+		}
+		if !found || weight > b.weights[best] || (weight == b.weights[best] && key < best) {
+			best, found = key, true
+		}
+	}
+	return best, found
+}
+
+func (b *Failover) Delete(key uint) {
+	delete(b.weights, key)
+	delete(b.failed, key)
+	b.Balancer.Delete(key)
+}
+
+func (b *Failover) Reduce(key uint) {
+	if _, ok := b.weights[key]; ok {
+		b.failed[key] = struct{}{}
+	}
+	b.Balancer.Reduce(key)
+}
+
+func (b *Failover) Success(key uint) {
+	delete(b.failed, key)
+	b.Balancer.Success(key)
+}
@@ -0,0 +1,82 @@
+package balancers
+
+import "testing"
+
+// fixedBalancer 总是返回 key，用于模拟底层负载均衡器反复抽中同一个候选
+type fixedBalancer struct {
+	key     uint
+	deleted map[uint]bool
+}
+
+func (b *fixedBalancer) Pop() (uint, error) { return b.key, nil }
+func (b *fixedBalancer) Delete(key uint)    { b.deleted[key] = true }
+func (b *fixedBalancer) Reduce(uint)        {}
+func (b *fixedBalancer) Success(uint)       {}
+
+func TestFailoverSkipsFailedCandidate(t *testing.T) {
+	inner := &fixedBalancer{key: 1, deleted: map[uint]bool{}}
+	b := BalancerWrapperFailover(inner, map[uint]int{1: 10, 2: 5, 3: 3, 4: 5})
+
+	pop := func() uint {
+		t.Helper()
+		key, err := b.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	if got := pop(); got != 1 {
+		t.Fatalf("before failure got %d, want underlying choice 1", got)
+	}
+	// 失败后不再立即选中 1，改选剩余权重最高者；权重相同取 ID 较小者
+	b.Reduce(1)
+	if got := pop(); got != 2 {
+		t.Fatalf("after 1 failed got %d, want 2", got)
+	}
+	b.Reduce(2)
+	if got := pop(); got != 4 {
+		t.Fatalf("after 2 failed got %d, want 4", got)
+	}
+	// 被删除的候选不再参与
+	b.Delete(4)
+	if !inner.deleted[4] {
+		t.Fatal("delete not forwarded to underlying balancer")
+	}
+	if got := pop(); got != 3 {
+		t.Fatalf("after 4 deleted got %d, want 3", got)
+	}
+	// 剩余候选全部失败过时回退到底层的选择
+	b.Reduce(3)
+	if got := pop(); got != 1 {
+		t.Fatalf("all failed got %d, want underlying choice 1", got)
+	}
+	// 成功后重新视为健康
+	b.Success(1)
+	if got := pop(); got != 1 {
+		t.Fatalf("after success got %d, want 1", got)
+	}
+}
+
+func TestFailoverIgnoresUnknownReduce(t *testing.T) {
+	b := BalancerWrapperFailover(&fixedBalancer{key: 1, deleted: map[uint]bool{}}, map[uint]int{1: 1})
+	b.Reduce(99)
+	if _, ok := b.failed[99]; ok {
+		t.Fatal("reduce outside candidate set tracked as failed")
+	}
+}
+
+func TestFailoverLotteryNoImmediateReselection(t *testing.T) {
+	items := map[uint]int{1: 1000, 2: 1, 3: 1}
+	b := BalancerWrapperFailover(NewLottery(items), items)
+	b.Reduce(1)
+	for range 200 {
+		key, err := b.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key == 1 {
+			t.Fatal("failed provider re-selected while healthy candidates remain")
+		}
+	}
+}
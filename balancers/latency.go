@@ -0,0 +1,69 @@
+package balancers
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// 按近期平均响应时间从低到高选择；全部关联都没有历史数据时按权重降序
+type Latency struct {
+	order   []uint
+	success uint
+	fails   map[uint]struct{}
+	reduces map[uint]struct{}
+}
+
+func NewLatency(items map[uint]int, opts Options) *Latency {
+	order := make([]uint, 0, len(items))
+	for key := range items {
+		order = append(order, key)
+	}
+	// 有历史耗时的按耗时升序排在前面，无历史的排在之后，同耗时或无历史时按权重降序
+	slices.SortFunc(order, func(a, b uint) int {
+		la, okA := opts.Latencies[a]
+		lb, okB := opts.Latencies[b]
+		if okA != okB {
+			if okA {
+				return -1
+			}
+			return 1
+		}
+		if c := cmp.Compare(la, lb); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(items[b], items[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	return &Latency{
+		order:   order,
+		fails:   map[uint]struct{}{},
+		reduces: map[uint]struct{}{},
+	}
+}
+
+func (w *Latency) Pop() (uint, error) {
+	if len(w.order) == 0 {
+		return 0, fmt.Errorf("no provide items")
+	}
+	return w.order[0], nil
+}
+
+func (w *Latency) Delete(key uint) {
+	w.fails[key] = struct{}{}
+	w.order = slices.DeleteFunc(w.order, func(k uint) bool { return k == key })
+}
+
+// Reduce 被限流/暂时失败时移到队尾，下一次选择次快的提供商
+func (w *Latency) Reduce(key uint) {
+	w.reduces[key] = struct{}{}
+	if i := slices.Index(w.order, key); i >= 0 {
+		w.order = append(slices.Delete(w.order, i, i+1), key)
+	}
+}
+
+func (w *Latency) Success(key uint) {
+	w.success = key
+}
@@ -0,0 +1,83 @@
+package balancers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/racio/llmio/consts"
+)
+
+// Options 创建负载均衡器时的附加数据，由需要的策略按需使用
+type Options struct {
+	Costs        map[uint]float64 // 关联 ID -> 单价（输入+输出），缺失表示价格未知
+	SuccessRates map[uint]float64 // 关联 ID -> 近期成功率 (0-1)，缺失表示样本不足
+	QualityFloor float64          // 成功率下限，低于该值的提供商排到最后
+	Latencies    map[uint]float64 // 关联 ID -> 近期平均响应时间(毫秒)，缺失表示无历史
+	MinWeight    int              // 按权重抽取的策略中 Reduce 降权后保留的最低权重，0 表示不设下限
+}
+
+// Factory 根据关联 ID -> 权重创建负载均衡器
+type Factory func(items map[uint]int, opts Options) Balancer
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+func init() {
+	Register(consts.BalancerLottery, func(items map[uint]int, opts Options) Balancer {
+		return NewLotteryWithFloor(items, opts.MinWeight)
+	})
+	Register(consts.BalancerRotor, func(items map[uint]int, _ Options) Balancer { return NewRotor(items) })
+	Register(consts.BalancerCostAware, func(items map[uint]int, opts Options) Balancer { return NewCostAware(items, opts) })
+	Register(consts.BalancerCost, func(items map[uint]int, opts Options) Balancer { return NewCost(items, opts) })
+	Register(consts.BalancerLatency, func(items map[uint]int, opts Options) Balancer { return NewLatency(items, opts) })
+}
+
+// Register 注册负载均衡策略，应在 init 中调用；名称为空、factory 为 nil 或重复注册时 panic
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if name == "" {
+		panic("balancers: Register with empty name")
+	}
+	if factory == nil {
+		panic("balancers: Register factory is nil for " + name)
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("balancers: Register called twice for %s", name))
+	}
+	registry[name] = factory
+}
+
+// New 按策略名创建负载均衡器，未注册的策略返回 false
+func New(name string, items map[uint]int, opts Options) (Balancer, bool) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return factory(items, opts), true
+}
+
+// Registered 判断策略是否已注册
+func Registered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[name]
+	return ok
+}
+
+// Names 返回已注册的策略名（排序后）
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorCode 机器可读的错误码，随错误响应返回，便于客户端按类型处理
+type ErrorCode string
+
+const (
+	ErrCodeBadRequest         ErrorCode = "BAD_REQUEST"
+	ErrCodeNotFound           ErrorCode = "NOT_FOUND"
+	ErrCodeInternal           ErrorCode = "INTERNAL_ERROR"
+	ErrCodeAuthInvalid        ErrorCode = "AUTH_INVALID"
+	ErrCodeAuthExpired        ErrorCode = "AUTH_EXPIRED"
+	ErrCodeForbidden          ErrorCode = "FORBIDDEN"
+	ErrCodeModelNotFound      ErrorCode = "MODEL_NOT_FOUND"
+	ErrCodeModelDisabled      ErrorCode = "MODEL_DISABLED"
+	ErrCodeModelForbidden     ErrorCode = "MODEL_FORBIDDEN"
+	ErrCodeNoProvider         ErrorCode = "NO_PROVIDER"
+	ErrCodeRateLimited        ErrorCode = "RATE_LIMITED"
+	ErrCodeConcurrencyLimited ErrorCode = "CONCURRENCY_LIMITED"
+	ErrCodeLimiterUnavailable ErrorCode = "LIMITER_UNAVAILABLE"
+	ErrCodeBudgetUnavailable  ErrorCode = "BUDGET_UNAVAILABLE"  // 预算用量查询失败
+	ErrCodeStorageUnavailable ErrorCode = "STORAGE_UNAVAILABLE" // 数据库读写失败
+	ErrCodeServiceUnavailable ErrorCode = "SERVICE_UNAVAILABLE" // 未细分原因的 503
+	ErrCodeUpstreamError      ErrorCode = "UPSTREAM_ERROR"
+	ErrCodeUpstreamTimeout    ErrorCode = "UPSTREAM_TIMEOUT"
+	ErrCodeInputTooLarge      ErrorCode = "INPUT_TOO_LARGE"
+	ErrCodeBudgetExceeded     ErrorCode = "BUDGET_EXCEEDED"
+	ErrCodeResourceLimit      ErrorCode = "RESOURCE_LIMIT_REACHED"
+)
+
+// CodedError 携带错误码的错误，可由 service 层返回并在 handler 中透出
+type CodedError struct {
+	Code ErrorCode
+	Err  error
+}
+
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// NewError 创建带错误码的错误
+func NewError(code ErrorCode, message string) error {
+	return &CodedError{Code: code, Err: errors.New(message)}
+}
+
+// WrapError 为已有错误附加错误码
+func WrapError(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
+
+// ErrorCodeOf 提取错误链中的错误码，未标注时返回 INTERNAL_ERROR
+func ErrorCodeOf(err error) ErrorCode {
+	var coded *CodedError
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return ErrCodeInternal
+}
+
+// HTTPStatusOf 返回错误链中错误码对应的 HTTP 状态码，未标注错误码时为 500
+func HTTPStatusOf(err error) int {
+	switch ErrorCodeOf(err) {
+	case ErrCodeBadRequest, ErrCodeInputTooLarge:
+		return http.StatusBadRequest
+	case ErrCodeAuthInvalid, ErrCodeAuthExpired:
+		return http.StatusUnauthorized
+	case ErrCodeBudgetExceeded:
+		return http.StatusPaymentRequired
+	case ErrCodeForbidden, ErrCodeModelForbidden, ErrCodeModelDisabled, ErrCodeResourceLimit:
+		return http.StatusForbidden
+	case ErrCodeNotFound, ErrCodeModelNotFound:
+		return http.StatusNotFound
+	case ErrCodeRateLimited, ErrCodeConcurrencyLimited:
+		return http.StatusTooManyRequests
+	case ErrCodeUpstreamError:
+		return http.StatusBadGateway
+	case ErrCodeNoProvider, ErrCodeLimiterUnavailable, ErrCodeBudgetUnavailable, ErrCodeStorageUnavailable, ErrCodeServiceUnavailable:
+		return http.StatusServiceUnavailable
+	case ErrCodeUpstreamTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorCodeFromStatus 未显式指定错误码时按状态码推断
+func errorCodeFromStatus(code int) ErrorCode {
+	switch code {
+	case http.StatusBadRequest:
+		return ErrCodeBadRequest
+	case http.StatusUnauthorized:
+		return ErrCodeAuthInvalid
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusTooManyRequests:
+		return ErrCodeRateLimited
+	case http.StatusBadGateway:
+		return ErrCodeUpstreamError
+	case http.StatusServiceUnavailable:
+		return ErrCodeServiceUnavailable
+	case http.StatusGatewayTimeout:
+		return ErrCodeUpstreamTimeout
+	default:
+		if code >= 400 && code < 500 {
+			return ErrCodeBadRequest
+		}
+		return ErrCodeInternal
+	}
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHTTPStatusOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{NewError(ErrCodeBadRequest, "x"), http.StatusBadRequest},
+		{NewError(ErrCodeModelNotFound, "x"), http.StatusNotFound},
+		{NewError(ErrCodeModelForbidden, "x"), http.StatusForbidden},
+		{NewError(ErrCodeModelDisabled, "x"), http.StatusForbidden},
+		{NewError(ErrCodeNoProvider, "x"), http.StatusServiceUnavailable},
+		{NewError(ErrCodeUpstreamError, "x"), http.StatusBadGateway},
+		{NewError(ErrCodeUpstreamTimeout, "x"), http.StatusGatewayTimeout},
+		{NewError(ErrCodeBudgetExceeded, "x"), http.StatusPaymentRequired},
+		{NewError(ErrCodeStorageUnavailable, "x"), http.StatusServiceUnavailable},
+		// 错误码在包装后的错误链中同样生效
+		{fmt.Errorf("balance: %w", NewError(ErrCodeNoProvider, "x")), http.StatusServiceUnavailable},
+		{errors.New("plain"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(string(ErrorCodeOf(tt.err)), func(t *testing.T) {
+			if got := HTTPStatusOf(tt.err); got != tt.want {
+				t.Fatalf("HTTPStatusOf(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCodeFromStatus(t *testing.T) {
+	if got := errorCodeFromStatus(http.StatusServiceUnavailable); got != ErrCodeServiceUnavailable {
+		t.Fatalf("503 = %s, want %s", got, ErrCodeServiceUnavailable)
+	}
+	if got := errorCodeFromStatus(http.StatusUnprocessableEntity); got != ErrCodeBadRequest {
+		t.Fatalf("422 = %s, want %s", got, ErrCodeBadRequest)
+	}
+}
+
+func TestErrorFrom(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	ErrorFrom(c, NewError(ErrCodeModelNotFound, "not found model gpt-x"))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	var resp Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusNotFound || resp.ErrorCode != ErrCodeModelNotFound || resp.Message != "not found model gpt-x" {
+		t.Fatalf("response = %+v", resp)
+	}
+}
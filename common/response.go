@@ -1,6 +1,8 @@
 package common
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -8,10 +10,38 @@ import (
 
 // Response 统一响应结构
 type Response struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Error   string `json:"error,omitempty"`
-	Data    any    `json:"data,omitempty"`
+	Code      int       `json:"code"`
+	ErrorCode ErrorCode `json:"error_code,omitempty"` // 机器可读错误码，见 errors.go
+	Message   string    `json:"message"`
+	Error     string    `json:"error,omitempty"`
+	Data      any       `json:"data,omitempty"`
+}
+
+// streamErrorKey 标记客户端期望 SSE 流式响应的上下文键
+const streamErrorKey = "llmio_stream_error"
+
+// MarkStreamRequest 标记当前请求为流式请求：此后开始转发前的错误以单个 SSE error 事件返回，
+// 避免期望 text/event-stream 的客户端收到无法解析的 JSON 响应体
+func MarkStreamRequest(c *gin.Context) {
+	c.Set(streamErrorKey, true)
+}
+
+// writeError 写出错误响应：流式请求输出 SSE error 事件后结束，否则输出 JSON
+func writeError(c *gin.Context, httpStatus int, resp Response) {
+	if !c.GetBool(streamErrorKey) || c.Writer.Written() {
+		c.JSON(httpStatus, resp)
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		c.JSON(httpStatus, resp)
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(httpStatus)
+	fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", data)
+	c.Writer.Flush()
 }
 
 // Success 成功响应
@@ -37,35 +67,55 @@ func SuccessWithMessage(c *gin.Context, message string, data any) {
 	})
 }
 
-// ErrorWithHttpStatus 带HTTP状态码的错误响应
+// ErrorWithHttpStatus 带HTTP状态码的错误响应，错误码按 code 推断
 func ErrorWithHttpStatus(c *gin.Context, httpStatus int, code int, message string) {
-	c.JSON(httpStatus, Response{
-		Code:    code,
-		Message: message,
+	ErrorWithCode(c, httpStatus, code, errorCodeFromStatus(code), message)
+}
+
+// ErrorWithCode 带HTTP状态码和错误码的错误响应
+func ErrorWithCode(c *gin.Context, httpStatus int, code int, errCode ErrorCode, message string) {
+	writeError(c, httpStatus, Response{
+		Code:      code,
+		ErrorCode: errCode,
+		Message:   message,
 	})
 }
 
 // InternalServerError 内部服务器错误
 func InternalServerError(c *gin.Context, message string) {
-	c.JSON(http.StatusInternalServerError, Response{
-		Code:    500,
-		Error:   message,
-		Message: message,
+	writeError(c, http.StatusInternalServerError, Response{
+		Code:      500,
+		ErrorCode: ErrCodeInternal,
+		Error:     message,
+		Message:   message,
+	})
+}
+
+// ErrorFrom 错误响应，错误码取自错误链中的 CodedError，HTTP 状态码按错误码确定（见 HTTPStatusOf）
+func ErrorFrom(c *gin.Context, err error) {
+	status := HTTPStatusOf(err)
+	writeError(c, status, Response{
+		Code:      status,
+		ErrorCode: ErrorCodeOf(err),
+		Error:     err.Error(),
+		Message:   err.Error(),
 	})
 }
 
 // BadRequest 请求参数错误
 func BadRequest(c *gin.Context, message string) {
 	c.JSON(http.StatusOK, Response{
-		Code:    http.StatusBadRequest,
-		Message: message,
+		Code:      http.StatusBadRequest,
+		ErrorCode: ErrCodeBadRequest,
+		Message:   message,
 	})
 }
 
 // NotFound 资源未找到
 func NotFound(c *gin.Context, message string) {
 	c.JSON(http.StatusOK, Response{
-		Code:    404,
-		Message: message,
+		Code:      404,
+		ErrorCode: ErrCodeNotFound,
+		Message:   message,
 	})
 }
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestErrorWithCodeOnStreamRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	MarkStreamRequest(c)
+
+	ErrorWithCode(c, http.StatusTooManyRequests, http.StatusTooManyRequests, ErrCodeRateLimited, "slow down")
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content-type = %q, want text/event-stream", ct)
+	}
+	body := w.Body.String()
+	data, ok := strings.CutPrefix(body, "event: error\ndata: ")
+	if !ok || !strings.HasSuffix(data, "\n\n") {
+		t.Fatalf("body %q is not a single SSE error event", body)
+	}
+	var resp Response
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(data, "\n\n")), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ErrorCode != ErrCodeRateLimited || resp.Message != "slow down" {
+		t.Fatalf("resp = %+v", resp)
+	}
+}
+
+func TestErrorWithCodeOnPlainRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	InternalServerError(c, "boom")
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("content-type = %q, want application/json", ct)
+	}
+	var resp Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("body %q: %v", w.Body.String(), err)
+	}
+	if resp.ErrorCode != ErrCodeInternal {
+		t.Fatalf("error code = %s, want %s", resp.ErrorCode, ErrCodeInternal)
+	}
+}
@@ -7,6 +7,12 @@ const (
 	StyleOpenAIRes Style = "codex"
 	StyleAnthropic Style = "anthropic"
 	StyleGemini    Style = "gemini"
+	// Azure OpenAI 部署，仅作为提供商类型，承接 openai 风格的请求
+	StyleAzure Style = "azure"
+	// AWS Bedrock 上的 Anthropic 模型，仅作为提供商类型，承接 anthropic 风格的请求
+	StyleBedrock Style = "bedrock"
+	// Mistral La Plateforme，仅作为提供商类型，承接 openai 风格的请求
+	StyleMistral Style = "mistral"
 
 	// Embeddings：用于在日志中区分请求类型（提供商类型仍沿用 openai / gemini）
 	StyleOpenAIEmbeddings Style = "openai-embeddings"
@@ -18,6 +24,12 @@ const (
 	BalancerLottery = "lottery"
 	// 按顺序循环轮转，每次降低权重后移到队尾
 	BalancerRotor = "rotor"
+	// 按成本从低到高选择，成功率低于下限的提供商排到最后
+	BalancerCostAware = "cost_aware"
+	// 按 权重/(输入单价+输出单价) 概率抽取，价格未知时按原始权重
+	BalancerCost = "cost"
+	// 按近期平均响应时间从低到高选择，无历史数据时按权重
+	BalancerLatency = "latency"
 	// 默认策略
 	BalancerDefault = BalancerLottery
 )
@@ -6,6 +6,16 @@ const (
 	ContextKeyAllowAllModel ContextKey = "allow_all_model"
 	ContextKeyAllowModels   ContextKey = "allow_models"
 	ContextKeyAuthKeyID     ContextKey = "auth_key_id"
+	// ContextKeyMaxConcurrency AuthKey 允许的最大并发请求数（0 表示不限制）
+	ContextKeyMaxConcurrency ContextKey = "max_concurrency"
+	// ContextKeyRpmLimit AuthKey 每分钟允许的请求数（0 表示不限制）
+	ContextKeyRpmLimit ContextKey = "rpm_limit"
+	// ContextKeyMonthlyBudget AuthKey 每月消费预算（0 表示不限制）
+	ContextKeyMonthlyBudget ContextKey = "monthly_budget"
+	// ContextKeyTimeline 被采样请求的生命周期时间线
+	ContextKeyTimeline ContextKey = "timeline"
+	// ContextKeyAccessLog 开启结构化访问日志时，单个代理请求的访问日志记录
+	ContextKeyAccessLog ContextKey = "access_log"
 )
 
 const (
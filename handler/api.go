@@ -2,30 +2,39 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/balancers"
 	"github.com/racio/llmio/common"
 	"github.com/racio/llmio/consts"
 	"github.com/racio/llmio/models"
 	"github.com/racio/llmio/providers"
+	"github.com/racio/llmio/service"
 	"github.com/samber/lo"
 	"gorm.io/gorm"
 )
 
 // ProviderRequest represents the request body for creating/updating a provider
 type ProviderRequest struct {
-	Name          string `json:"name"`
-	Type          string `json:"type"`
-	Config        string `json:"config"`
-	Console       string `json:"console"`
-	RpmLimit      int    `json:"rpm_limit"`
-	IpLockMinutes int    `json:"ip_lock_minutes"`
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Config         string `json:"config"`
+	Console        string `json:"console"`
+	RpmLimit       int    `json:"rpm_limit"`
+	TpmLimit       int    `json:"tpm_limit"`
+	IpLockMinutes  int    `json:"ip_lock_minutes"`
+	KeepWarm       bool   `json:"keep_warm"`
+	MaxConcurrency int    `json:"max_concurrency"`
+	// 视为成功的上游状态码，逗号分隔，空表示仅 200
+	SuccessStatusCodes string `json:"success_status_codes"`
 }
 
 // ModelRequest represents the request body for creating/updating a model
@@ -37,6 +46,27 @@ type ModelRequest struct {
 	IOLog    bool   `json:"io_log"`
 	Strategy string `json:"strategy"`
 	Breaker  bool   `json:"breaker"`
+	// 以下为可选项，更新时未传入则保持原值
+	MaxInputTokens         *int  `json:"max_input_tokens"`
+	TokenLockSeconds       *int  `json:"token_lock_seconds"`
+	MaxProvidersPerRequest *int  `json:"max_providers_per_request"`
+	AutoWeight             *bool `json:"auto_weight"`
+	CacheTTLSeconds        *int  `json:"cache_ttl_seconds"`
+	MinWeight              *int  `json:"min_weight"`
+	// 熔断参数，0 表示使用默认值
+	BreakerMaxFailures      *int `json:"breaker_max_failures"`
+	BreakerSleepSeconds     *int `json:"breaker_sleep_seconds"`
+	BreakerHalfOpenRequests *int `json:"breaker_half_open_requests"`
+	// 全部提供商失败后改用的备用模型，空字符串表示关闭
+	FallbackModel *string `json:"fallback_model"`
+	// 流式响应总时长上限（秒），0 表示不限制
+	StreamMaxSeconds *int `json:"stream_max_seconds"`
+	// 推理预算上限，0/空字符串表示不限制
+	MaxThinkingTokens   *int    `json:"max_thinking_tokens"`
+	MaxReasoningEffort  *string `json:"max_reasoning_effort"`
+	MaxCompletionTokens *int    `json:"max_completion_tokens"`
+	// 是否将响应中的模型名替换为网关模型名
+	EchoModel *bool `json:"echo_model"`
 }
 
 type ModelWithPrice struct {
@@ -56,6 +86,11 @@ type ModelWithProviderRequest struct {
 	WithHeader       bool              `json:"with_header"`
 	CustomerHeaders  map[string]string `json:"customer_headers"`
 	Weight           int               `json:"weight"`
+	Shadow           bool              `json:"shadow"`
+	ShadowRate       float64           `json:"shadow_rate"`
+	RpmLimit         int               `json:"rpm_limit"`
+	// 排除规则，请求属性满足任一规则时本次请求不使用该关联
+	ExcludeRules []service.ExcludeRule `json:"exclude_rules"`
 }
 
 // ModelProviderStatusRequest represents the request body for updating provider status
@@ -110,24 +145,42 @@ func GetProviders(c *gin.Context) {
 	common.Success(c, providers)
 }
 
+// ProviderModelsResponse 上游模型列表，CacheAgeSeconds 为距上次从上游拉取的秒数
+type ProviderModelsResponse struct {
+	*service.ProviderModelsResult
+	CacheAgeSeconds int64 `json:"cache_age_seconds"`
+}
+
+// GetProviderModels 获取提供商上游模型列表，默认使用缓存；?refresh=true 跳过缓存，强制从上游拉取
 func GetProviderModels(c *gin.Context) {
+	respondProviderModels(c, c.Query("refresh") == "true")
+}
+
+// RefreshProviderModels 清除缓存并从上游重新拉取提供商模型列表
+func RefreshProviderModels(c *gin.Context) {
+	respondProviderModels(c, true)
+}
+
+func respondProviderModels(c *gin.Context, refresh bool) {
 	id := c.Param("id")
 	provider, err := gorm.G[models.Provider](models.DB).Where("id = ?", id).First(c.Request.Context())
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.NotFound(c, "Provider not found")
+			return
+		}
 		common.InternalServerError(c, err.Error())
 		return
 	}
-	chatModel, err := providers.New(provider.Type, provider.Config)
-	if err != nil {
-		common.InternalServerError(c, "Failed to get models: "+err.Error())
-		return
-	}
-	models, err := chatModel.Models(c.Request.Context())
+	result, err := service.GetProviderModels(c.Request.Context(), provider, refresh)
 	if err != nil {
 		common.NotFound(c, "Failed to get models: "+err.Error())
 		return
 	}
-	common.Success(c, models)
+	common.Success(c, ProviderModelsResponse{
+		ProviderModelsResult: result,
+		CacheAgeSeconds:      int64(time.Since(result.FetchedAt).Seconds()),
+	})
 }
 
 // CreateProvider 创建提供商
@@ -137,6 +190,11 @@ func CreateProvider(c *gin.Context) {
 		common.BadRequest(c, "Invalid request body: "+err.Error())
 		return
 	}
+	provider, err := providerFromRequest(req)
+	if err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
 
 	// Check if provider exists
 	count, err := gorm.G[models.Provider](models.DB).Where("name = ?", req.Name).Count(c.Request.Context(), "id")
@@ -149,14 +207,9 @@ func CreateProvider(c *gin.Context) {
 		common.BadRequest(c, "Provider already exists")
 		return
 	}
-
-	provider := models.Provider{
-		Name:          req.Name,
-		Type:          req.Type,
-		Config:        req.Config,
-		Console:       req.Console,
-		RpmLimit:      req.RpmLimit,
-		IpLockMinutes: req.IpLockMinutes,
+	if err := service.CheckResourceLimit(c.Request.Context(), service.ResourceProviders); err != nil {
+		respondResourceLimit(c, err)
+		return
 	}
 
 	if err := gorm.G[models.Provider](models.DB).Create(c.Request.Context(), &provider); err != nil {
@@ -167,6 +220,43 @@ func CreateProvider(c *gin.Context) {
 	common.Success(c, provider)
 }
 
+// providerFromRequest 校验创建/更新请求并构造提供商记录
+func providerFromRequest(req ProviderRequest) (models.Provider, error) {
+	if err := providers.ValidateExtraBody(req.Config); err != nil {
+		return models.Provider{}, fmt.Errorf("Invalid config: %w", err)
+	}
+	if err := providers.ValidateUserPolicy(req.Config); err != nil {
+		return models.Provider{}, fmt.Errorf("Invalid config: %w", err)
+	}
+	if err := providers.ValidateQueryParams(req.Config); err != nil {
+		return models.Provider{}, fmt.Errorf("Invalid config: %w", err)
+	}
+	if req.MaxConcurrency < 0 {
+		return models.Provider{}, errors.New("max_concurrency must be >= 0")
+	}
+	if _, err := service.ParseSuccessStatusCodes(req.SuccessStatusCodes); err != nil {
+		return models.Provider{}, fmt.Errorf("Invalid success_status_codes: %w", err)
+	}
+
+	keepWarm := 0
+	if req.KeepWarm {
+		keepWarm = 1
+	}
+
+	return models.Provider{
+		Name:               req.Name,
+		Type:               req.Type,
+		Config:             req.Config,
+		Console:            req.Console,
+		RpmLimit:           req.RpmLimit,
+		TpmLimit:           req.TpmLimit,
+		IpLockMinutes:      req.IpLockMinutes,
+		KeepWarm:           keepWarm,
+		MaxConcurrency:     req.MaxConcurrency,
+		SuccessStatusCodes: strings.TrimSpace(req.SuccessStatusCodes),
+	}, nil
+}
+
 // UpdateProvider 更新提供商
 func UpdateProvider(c *gin.Context) {
 	idStr := c.Param("id")
@@ -181,6 +271,11 @@ func UpdateProvider(c *gin.Context) {
 		common.BadRequest(c, "Invalid request body: "+err.Error())
 		return
 	}
+	provider, err := providerFromRequest(req)
+	if err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
 
 	// Check if provider exists
 	if _, err := gorm.G[models.Provider](models.DB).Where("id = ?", id).First(c.Request.Context()); err != nil {
@@ -192,20 +287,24 @@ func UpdateProvider(c *gin.Context) {
 		return
 	}
 
-	// Update fields
-	updates := models.Provider{
-		Name:          req.Name,
-		Type:          req.Type,
-		Config:        req.Config,
-		Console:       req.Console,
-		RpmLimit:      req.RpmLimit,
-		IpLockMinutes: req.IpLockMinutes,
-	}
-
-	if _, err := gorm.G[models.Provider](models.DB).Where("id = ?", id).Updates(c.Request.Context(), updates); err != nil {
+	// struct Updates 会忽略 0 值，可清零的字段在下面单独更新
+	if _, err := gorm.G[models.Provider](models.DB).Where("id = ?", id).Updates(c.Request.Context(), provider); err != nil {
 		common.InternalServerError(c, "Failed to update provider: "+err.Error())
 		return
 	}
+	for column, value := range map[string]any{
+		"tpm_limit":            provider.TpmLimit,
+		"keep_warm":            provider.KeepWarm,
+		"max_concurrency":      provider.MaxConcurrency,
+		"success_status_codes": provider.SuccessStatusCodes,
+	} {
+		if _, err := gorm.G[models.Provider](models.DB).Where("id = ?", id).Update(c.Request.Context(), column, value); err != nil {
+			common.InternalServerError(c, "Failed to update provider: "+err.Error())
+			return
+		}
+	}
+
+	service.InvalidateProviderModels(c.Request.Context(), uint(id))
 
 	// Get updated provider
 	updatedProvider, err := gorm.G[models.Provider](models.DB).Where("id = ?", id).First(c.Request.Context())
@@ -217,6 +316,50 @@ func UpdateProvider(c *gin.Context) {
 	common.Success(c, updatedProvider)
 }
 
+// ProviderDisabledUntilRequest 安排提供商停用，until 为 null 或已过去的时间表示立即恢复
+type ProviderDisabledUntilRequest struct {
+	Until *time.Time `json:"until"`
+}
+
+// SetProviderDisabledUntil 设置提供商在指定时间前不参与路由，用于按时间窗口重置额度的提供商
+func SetProviderDisabledUntil(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID format")
+		return
+	}
+
+	var req ProviderDisabledUntilRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		req.Until = nil
+	}
+
+	ctx := c.Request.Context()
+	if _, err := gorm.G[models.Provider](models.DB).Where("id = ?", id).First(ctx); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			common.NotFound(c, "Provider not found")
+			return
+		}
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	if err := service.DisableProviderUntil(ctx, uint(id), req.Until); err != nil {
+		common.InternalServerError(c, "Failed to update provider: "+err.Error())
+		return
+	}
+
+	provider, err := gorm.G[models.Provider](models.DB).Where("id = ?", id).First(ctx)
+	if err != nil {
+		common.InternalServerError(c, "Failed to retrieve updated provider: "+err.Error())
+		return
+	}
+	common.Success(c, provider)
+}
+
 // DeleteProvider 删除提供商
 func DeleteProvider(c *gin.Context) {
 	idStr := c.Param("id")
@@ -242,6 +385,7 @@ func DeleteProvider(c *gin.Context) {
 		common.NotFound(c, "Provider not found")
 		return
 	}
+	service.InvalidateProviderModels(c.Request.Context(), uint(id))
 
 	common.Success(c, nil)
 }
@@ -262,13 +406,11 @@ func GetModels(c *gin.Context) {
 	}
 
 	if strategy := strings.TrimSpace(c.Query("strategy")); strategy != "" {
-		switch strategy {
-		case consts.BalancerLottery, consts.BalancerRotor:
-			query = query.Where("strategy = ?", strategy)
-		default:
+		if !balancers.Registered(strategy) {
 			common.BadRequest(c, "invalid strategy filter")
 			return
 		}
+		query = query.Where("strategy = ?", strategy)
 	}
 
 	if ioLog := strings.TrimSpace(c.Query("io_log")); ioLog != "" {
@@ -350,6 +492,11 @@ func CreateModel(c *gin.Context) {
 		common.BadRequest(c, "Invalid request body: "+err.Error())
 		return
 	}
+	model, err := modelFromRequest(req)
+	if err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
 
 	// Check if model exists
 	count, err := gorm.G[models.Model](models.DB).Where("name = ?", req.Name).Count(c.Request.Context(), "id")
@@ -361,10 +508,34 @@ func CreateModel(c *gin.Context) {
 		common.BadRequest(c, fmt.Sprintf("Model: %s already exists", req.Name))
 		return
 	}
+	if err := service.CheckResourceLimit(c.Request.Context(), service.ResourceModels); err != nil {
+		respondResourceLimit(c, err)
+		return
+	}
+
+	if err := gorm.G[models.Model](models.DB).Create(c.Request.Context(), &model); err != nil {
+		common.InternalServerError(c, "Failed to create model: "+err.Error())
+		return
+	}
+
+	common.Success(c, model)
+}
+
+// modelFromRequest 校验创建请求并构造模型记录，新建模型默认启用
+func modelFromRequest(req ModelRequest) (models.Model, error) {
+	if req.FallbackModel != nil && strings.TrimSpace(*req.FallbackModel) == req.Name {
+		return models.Model{}, errors.New("fallback_model cannot be the model itself")
+	}
+	if req.MaxReasoningEffort != nil && !service.ValidReasoningEffort(*req.MaxReasoningEffort) {
+		return models.Model{}, errors.New("Invalid max_reasoning_effort: " + *req.MaxReasoningEffort)
+	}
 	strategy := req.Strategy
 	if strategy == "" {
 		strategy = consts.BalancerDefault
 	}
+	if !balancers.Registered(strategy) {
+		return models.Model{}, errors.New("Invalid strategy: " + strategy)
+	}
 
 	ioLog := 0
 	if req.IOLog {
@@ -374,24 +545,36 @@ func CreateModel(c *gin.Context) {
 	if req.Breaker {
 		breaker = 1
 	}
-
-	model := models.Model{
-		Name:     req.Name,
-		Remark:   req.Remark,
-		MaxRetry: req.MaxRetry,
-		TimeOut:  req.TimeOut,
-		IOLog:    ioLog,
-		Strategy: strategy,
-		Breaker:  breaker,
-		Status:   1,
-	}
-
-	if err := gorm.G[models.Model](models.DB).Create(c.Request.Context(), &model); err != nil {
-		common.InternalServerError(c, "Failed to create model: "+err.Error())
-		return
-	}
-
-	common.Success(c, model)
+	autoWeight := 0
+	if lo.FromPtr(req.AutoWeight) {
+		autoWeight = 1
+	}
+
+	return models.Model{
+		Name:                    req.Name,
+		Remark:                  req.Remark,
+		MaxRetry:                req.MaxRetry,
+		TimeOut:                 req.TimeOut,
+		IOLog:                   ioLog,
+		Strategy:                strategy,
+		Breaker:                 breaker,
+		Status:                  1,
+		MaxInputTokens:          lo.FromPtr(req.MaxInputTokens),
+		TokenLockSeconds:        lo.FromPtr(req.TokenLockSeconds),
+		MaxProvidersPerRequest:  lo.FromPtr(req.MaxProvidersPerRequest),
+		AutoWeight:              autoWeight,
+		CacheTTLSeconds:         lo.FromPtr(req.CacheTTLSeconds),
+		MinWeight:               lo.FromPtr(req.MinWeight),
+		BreakerMaxFailures:      lo.FromPtr(req.BreakerMaxFailures),
+		BreakerSleepSeconds:     lo.FromPtr(req.BreakerSleepSeconds),
+		BreakerHalfOpenRequests: lo.FromPtr(req.BreakerHalfOpenRequests),
+		FallbackModel:           strings.TrimSpace(lo.FromPtr(req.FallbackModel)),
+		StreamMaxSeconds:        lo.FromPtr(req.StreamMaxSeconds),
+		MaxThinkingTokens:       lo.FromPtr(req.MaxThinkingTokens),
+		MaxReasoningEffort:      lo.FromPtr(req.MaxReasoningEffort),
+		MaxCompletionTokens:     lo.FromPtr(req.MaxCompletionTokens),
+		EchoModel:               lo.Ternary(lo.FromPtr(req.EchoModel), 1, 0),
+	}, nil
 }
 
 // UpdateModel 更新模型
@@ -424,6 +607,18 @@ func UpdateModel(c *gin.Context) {
 	if strategy == "" {
 		strategy = consts.BalancerDefault
 	}
+	if !balancers.Registered(strategy) {
+		common.BadRequest(c, "Invalid strategy: "+strategy)
+		return
+	}
+	if req.FallbackModel != nil && strings.TrimSpace(*req.FallbackModel) == req.Name {
+		common.BadRequest(c, "fallback_model cannot be the model itself")
+		return
+	}
+	if req.MaxReasoningEffort != nil && !service.ValidReasoningEffort(*req.MaxReasoningEffort) {
+		common.BadRequest(c, "Invalid max_reasoning_effort: "+*req.MaxReasoningEffort)
+		return
+	}
 
 	// Update fields
 	ioLog := 0
@@ -444,7 +639,51 @@ func UpdateModel(c *gin.Context) {
 		Breaker:  breaker,
 	}
 
-	if _, err := gorm.G[models.Model](models.DB).Where("id = ?", id).Updates(c.Request.Context(), updates); err != nil {
+	// struct Updates 会忽略 0 值，单独更新以支持关闭预检/token 锁/提供商数上限/自动调权/响应缓存/最低权重/恢复默认熔断参数/取消流式时长上限/取消推理预算上限；未传入的可选项保持原值
+	optionalUpdates := make(map[string]any)
+	for col, val := range map[string]*int{
+		"max_input_tokens":           req.MaxInputTokens,
+		"token_lock_seconds":         req.TokenLockSeconds,
+		"max_providers_per_request":  req.MaxProvidersPerRequest,
+		"cache_ttl_seconds":          req.CacheTTLSeconds,
+		"min_weight":                 req.MinWeight,
+		"breaker_max_failures":       req.BreakerMaxFailures,
+		"breaker_sleep_seconds":      req.BreakerSleepSeconds,
+		"breaker_half_open_requests": req.BreakerHalfOpenRequests,
+		"stream_max_seconds":         req.StreamMaxSeconds,
+		"max_thinking_tokens":        req.MaxThinkingTokens,
+		"max_completion_tokens":      req.MaxCompletionTokens,
+	} {
+		if val != nil {
+			optionalUpdates[col] = *val
+		}
+	}
+	if req.AutoWeight != nil {
+		optionalUpdates["auto_weight"] = lo.Ternary(*req.AutoWeight, 1, 0)
+	}
+	if req.EchoModel != nil {
+		optionalUpdates["echo_model"] = lo.Ternary(*req.EchoModel, 1, 0)
+	}
+	if req.FallbackModel != nil {
+		optionalUpdates["fallback_model"] = strings.TrimSpace(*req.FallbackModel)
+	}
+	if req.MaxReasoningEffort != nil {
+		optionalUpdates["max_reasoning_effort"] = *req.MaxReasoningEffort
+	}
+
+	// 所有字段在同一事务内写入，任一失败时整体回滚，避免模型只更新一半
+	err = models.DB.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if _, err := gorm.G[models.Model](tx).Where("id = ?", id).Updates(c.Request.Context(), updates); err != nil {
+			return err
+		}
+		for col, val := range optionalUpdates {
+			if _, err := gorm.G[models.Model](tx).Where("id = ?", id).Update(c.Request.Context(), col, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		common.InternalServerError(c, "Failed to update model: "+err.Error())
 		return
 	}
@@ -551,12 +790,45 @@ var template = []ProviderTemplate{
 			"version": "2023-06-01"
 		}`,
 	},
+	{
+		Type: "azure",
+		Template: `{
+			"endpoint": "https://YOUR_RESOURCE.openai.azure.com",
+			"api_key": "YOUR_API_KEY",
+			"api_version": "2024-10-21",
+			"deployment": "YOUR_DEPLOYMENT"
+		}`,
+	},
+	{
+		Type: "bedrock",
+		Template: `{
+			"region": "us-east-1",
+			"access_key": "YOUR_ACCESS_KEY",
+			"secret_key": "YOUR_SECRET_KEY",
+			"session_token": ""
+		}`,
+	},
+	{
+		Type: "mistral",
+		Template: `{
+			"base_url": "https://api.mistral.ai/v1",
+			"api_key": "YOUR_API_KEY",
+			"safe_prompt": false
+		}`,
+	},
 }
 
 func GetProviderTemplates(c *gin.Context) {
 	common.Success(c, template)
 }
 
+// ModelProviderItem 模型关联及其提供商信息
+type ModelProviderItem struct {
+	models.ModelWithProvider
+	ProviderName string `json:"provider_name"`
+	ProviderType string `json:"provider_type"`
+}
+
 // GetModelProviders 获取模型的提供商关联列表
 func GetModelProviders(c *gin.Context) {
 	modelIDStr := c.Query("model_id")
@@ -571,13 +843,60 @@ func GetModelProviders(c *gin.Context) {
 		return
 	}
 
-	modelProviders, err := gorm.G[models.ModelWithProvider](models.DB).Where("model_id = ?", modelID).Find(c.Request.Context())
+	query := models.DB.Model(&models.ModelWithProvider{}).Where("model_id = ?", modelID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if providerID := c.Query("provider_id"); providerID != "" {
+		query = query.Where("provider_id = ?", providerID)
+	}
+	query = query.Order("id ASC")
+
+	// 未携带分页参数时保持原有行为，返回全部关联
+	paginate := c.Query("page") != "" || c.Query("page_size") != ""
+	var params common.PaginationParams
+	var modelProviders []models.ModelWithProvider
+	var total int64
+	if paginate {
+		params, err = common.ParsePagination(c)
+		if err != nil {
+			common.BadRequest(c, err.Error())
+			return
+		}
+		total, err = common.PaginateQuery(query, params, &modelProviders)
+	} else {
+		err = query.Find(&modelProviders).Error
+	}
+	if err != nil {
+		common.InternalServerError(c, err.Error())
+		return
+	}
+
+	// 补充提供商名称/类型，前端无需再与提供商列表交叉匹配
+	providerList, err := gorm.G[models.Provider](models.DB).
+		Where("id IN ?", lo.Uniq(lo.Map(modelProviders, func(mp models.ModelWithProvider, _ int) uint { return mp.ProviderID }))).
+		Find(c.Request.Context())
 	if err != nil {
 		common.InternalServerError(c, err.Error())
 		return
 	}
+	providerMap := lo.KeyBy(providerList, func(p models.Provider) uint { return p.ID })
 
-	common.Success(c, modelProviders)
+	items := make([]ModelProviderItem, 0, len(modelProviders))
+	for _, mp := range modelProviders {
+		item := ModelProviderItem{ModelWithProvider: mp}
+		if provider, ok := providerMap[mp.ProviderID]; ok {
+			item.ProviderName = provider.Name
+			item.ProviderType = provider.Type
+		}
+		items = append(items, item)
+	}
+
+	if paginate {
+		common.Success(c, common.NewPaginationResponse(items, total, params))
+		return
+	}
+	common.Success(c, items)
 }
 
 // GetModelProviderStatus 获取提供商状态信息
@@ -598,14 +917,14 @@ func GetModelProviderStatus(c *gin.Context) {
 	}
 
 	// 获取提供商信息
-	provider, err := gorm.G[models.Provider](models.DB).Where("id = ?", providerID).First(c.Request.Context())
+	provider, err := gorm.G[models.Provider](models.Reader()).Where("id = ?", providerID).First(c.Request.Context())
 	if err != nil {
 		common.InternalServerError(c, "Failed to retrieve provider: "+err.Error())
 		return
 	}
 
 	// 获取最近10次请求状态
-	logs, err := gorm.G[models.ChatLog](models.DB).
+	logs, err := gorm.G[models.ChatLog](models.Reader()).
 		Where("provider_name = ?", provider.Name).
 		Where("provider_model = ?", providerModel).
 		Where("name = ?", modelName).
@@ -632,7 +951,23 @@ func CreateModelProvider(c *gin.Context) {
 		common.BadRequest(c, "Invalid request body: "+err.Error())
 		return
 	}
+	modelProvider, err := modelProviderFromRequest(req)
+	if err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
 
+	err = gorm.G[models.ModelWithProvider](models.DB).Create(c.Request.Context(), &modelProvider)
+	if err != nil {
+		common.InternalServerError(c, "Failed to create model-provider association: "+err.Error())
+		return
+	}
+
+	common.Success(c, modelProvider)
+}
+
+// modelProviderFromRequest 校验创建请求并构造模型提供商关联，新建关联默认启用
+func modelProviderFromRequest(req ModelWithProviderRequest) (models.ModelWithProvider, error) {
 	// 将 CustomerHeaders 转换为 JSON 字符串
 	customerHeadersJSON := ""
 	if req.CustomerHeaders != nil && len(req.CustomerHeaders) > 0 {
@@ -658,8 +993,26 @@ func CreateModelProvider(c *gin.Context) {
 	if req.WithHeader {
 		withHeader = 1
 	}
+	shadow := 0
+	if req.Shadow {
+		shadow = 1
+	}
+	if req.ShadowRate < 0 || req.ShadowRate > 1 {
+		return models.ModelWithProvider{}, errors.New("shadow_rate must be between 0 and 1")
+	}
+	// 权重 0 表示仅用于故障转移，停用关联请使用 status
+	if req.Weight < 0 {
+		return models.ModelWithProvider{}, errors.New("weight must be >= 0 (0 means failover only)")
+	}
+	if req.RpmLimit < 0 {
+		return models.ModelWithProvider{}, errors.New("rpm_limit must be >= 0")
+	}
+	excludeRules, err := service.EncodeExcludeRules(req.ExcludeRules)
+	if err != nil {
+		return models.ModelWithProvider{}, fmt.Errorf("Invalid exclude_rules: %w", err)
+	}
 
-	modelProvider := models.ModelWithProvider{
+	return models.ModelWithProvider{
 		ModelID:          req.ModelID,
 		ProviderModel:    req.ProviderModel,
 		ProviderID:       req.ProviderID,
@@ -669,16 +1022,13 @@ func CreateModelProvider(c *gin.Context) {
 		WithHeader:       withHeader,
 		CustomerHeaders:  customerHeadersJSON,
 		Weight:           req.Weight,
+		BaseWeight:       req.Weight,
+		Shadow:           shadow,
+		ShadowRate:       req.ShadowRate,
+		RpmLimit:         req.RpmLimit,
+		ExcludeRules:     excludeRules,
 		Status:           1, // 默认启用
-	}
-
-	err := gorm.G[models.ModelWithProvider](models.DB).Create(c.Request.Context(), &modelProvider)
-	if err != nil {
-		common.InternalServerError(c, "Failed to create model-provider association: "+err.Error())
-		return
-	}
-
-	common.Success(c, modelProvider)
+	}, nil
 }
 
 // UpdateModelProvider 更新模型提供商关联
@@ -722,6 +1072,28 @@ func UpdateModelProvider(c *gin.Context) {
 	if req.WithHeader {
 		withHeader = 1
 	}
+	shadow := 0
+	if req.Shadow {
+		shadow = 1
+	}
+	if req.ShadowRate < 0 || req.ShadowRate > 1 {
+		common.BadRequest(c, "shadow_rate must be between 0 and 1")
+		return
+	}
+	// 权重 0 表示仅用于故障转移，停用关联请使用 status
+	if req.Weight < 0 {
+		common.BadRequest(c, "weight must be >= 0 (0 means failover only)")
+		return
+	}
+	if req.RpmLimit < 0 {
+		common.BadRequest(c, "rpm_limit must be >= 0")
+		return
+	}
+	excludeRules, err := service.EncodeExcludeRules(req.ExcludeRules)
+	if err != nil {
+		common.BadRequest(c, "Invalid exclude_rules: "+err.Error())
+		return
+	}
 
 	// Check if model-provider association exists
 	_, err = gorm.G[models.ModelWithProvider](models.DB).Where("id = ?", id).First(c.Request.Context())
@@ -750,6 +1122,11 @@ func UpdateModelProvider(c *gin.Context) {
 		{"with_header", withHeader},
 		{"customer_headers", customerHeadersJSON},
 		{"weight", req.Weight},
+		{"base_weight", req.Weight},
+		{"shadow", shadow},
+		{"shadow_rate", req.ShadowRate},
+		{"rpm_limit", req.RpmLimit},
+		{"exclude_rules", excludeRules},
 	}
 	for _, pair := range updatePairs {
 		if _, err := gorm.G[models.ModelWithProvider](models.DB).Where("id = ?", id).Update(c.Request.Context(), pair.col, pair.val); err != nil {
@@ -809,6 +1186,65 @@ func UpdateModelProviderStatus(c *gin.Context) {
 	common.Success(c, existing)
 }
 
+// BulkModelProviderStatusRequest 批量切换关联状态：provider_id 与 ids 至少提供一个，二者同时提供时取交集
+type BulkModelProviderStatusRequest struct {
+	ProviderID uint   `json:"provider_id"`
+	IDs        []uint `json:"ids"`
+	Status     *bool  `json:"status"`
+}
+
+// BulkUpdateModelProviderStatus 按提供商或关联 ID 列表批量启用/停用模型提供商关联，用于故障时快速摘除提供商
+func BulkUpdateModelProviderStatus(c *gin.Context) {
+	var req BulkModelProviderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+	// 防止误操作：空条件绝不等于“全部关联”
+	if req.ProviderID == 0 && len(req.IDs) == 0 {
+		common.BadRequest(c, "provider_id or ids is required")
+		return
+	}
+	if req.Status == nil {
+		common.BadRequest(c, "status is required")
+		return
+	}
+
+	status := 0
+	if *req.Status {
+		status = 1
+	}
+
+	var affected int64
+	err := models.DB.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		result := bulkModelProviderStatusQuery(tx, req.ProviderID, req.IDs, status)
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		common.InternalServerError(c, "Failed to update status: "+err.Error())
+		return
+	}
+
+	common.Success(c, map[string]any{"affected_count": affected, "status": *req.Status})
+}
+
+// bulkModelProviderStatusQuery 按提供商和/或关联 ID 更新状态，两个条件同时给出时取交集
+func bulkModelProviderStatusQuery(tx *gorm.DB, providerID uint, ids []uint, status int) *gorm.DB {
+	query := tx.Model(&models.ModelWithProvider{})
+	if providerID != 0 {
+		query = query.Where("provider_id = ?", providerID)
+	}
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	// 单列 Update，避免 struct 更新忽略 0 值导致无法停用
+	return query.Update("status", status)
+}
+
 // DeleteModelProvider 删除模型提供商关联
 func DeleteModelProvider(c *gin.Context) {
 	idStr := c.Param("id")
@@ -828,6 +1264,8 @@ func DeleteModelProvider(c *gin.Context) {
 		common.NotFound(c, "Model-provider association not found")
 		return
 	}
+	// 关联已删除，清理其熔断节点
+	balancers.ResetBreaker(uint(id))
 
 	common.Success(c, nil)
 }
@@ -839,6 +1277,30 @@ type WrapLog struct {
 
 // GetRequestLogs 获取最近的请求日志（支持分页和筛选）
 func GetRequestLogs(c *gin.Context) {
+	respondRequestLogs(c, c.Query("provider_name"))
+}
+
+// GetProviderLogs 按提供商 ID 查询请求日志，按当前名称匹配，避免改名后手动拼写名称
+func GetProviderLogs(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID format")
+		return
+	}
+	provider, err := gorm.G[models.Provider](models.DB).Where("id = ?", id).First(c.Request.Context())
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			common.NotFound(c, "Provider not found")
+			return
+		}
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	respondRequestLogs(c, provider.Name)
+}
+
+// respondRequestLogs 按提供商名称及查询参数中的其它筛选条件分页返回请求日志
+func respondRequestLogs(c *gin.Context, providerName string) {
 	// 解析分页参数
 	params, err := common.ParsePagination(c)
 	if err != nil {
@@ -847,14 +1309,14 @@ func GetRequestLogs(c *gin.Context) {
 	}
 
 	// 获取筛选参数
-	providerName := c.Query("provider_name")
 	name := c.Query("name")
 	status := c.Query("status")
 	style := c.Query("style")
 	authKeyID := c.Query("auth_key_id")
+	requestID := c.Query("request_id")
 
 	// 构建查询条件
-	query := models.DB.Model(&models.ChatLog{})
+	query := models.Reader().Model(&models.ChatLog{})
 
 	if providerName != "" {
 		query = query.Where("provider_name = ?", providerName)
@@ -876,6 +1338,10 @@ func GetRequestLogs(c *gin.Context) {
 		query = query.Where("auth_key_id = ?", authKeyID)
 	}
 
+	if requestID != "" {
+		query = query.Where("request_id = ?", requestID)
+	}
+
 	// 执行分页查询
 	var logs []models.ChatLog
 	total, err := common.PaginateQuery(
@@ -888,7 +1354,7 @@ func GetRequestLogs(c *gin.Context) {
 		return
 	}
 
-	keys, err := gorm.G[models.AuthKey](models.DB).Where("id IN ?", lo.Map(logs, func(log models.ChatLog, _ int) uint { return log.AuthKeyID })).Find(c.Request.Context())
+	keys, err := gorm.G[models.AuthKey](models.Reader()).Where("id IN ?", lo.Map(logs, func(log models.ChatLog, _ int) uint { return log.AuthKeyID })).Find(c.Request.Context())
 	if err != nil {
 		common.InternalServerError(c, "Failed to query auth keys: "+err.Error())
 		return
@@ -916,25 +1382,85 @@ func GetRequestLogs(c *gin.Context) {
 	common.Success(c, response)
 }
 
+// GetShadowLogs 分页查询影子提供商请求记录
+func GetShadowLogs(c *gin.Context) {
+	params, err := common.ParsePagination(c)
+	if err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
+
+	query := models.Reader().Model(&models.ShadowLog{})
+	if providerName := c.Query("provider_name"); providerName != "" {
+		query = query.Where("provider_name = ?", providerName)
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name = ?", name)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var logs []models.ShadowLog
+	total, err := common.PaginateQuery(query.Order("id DESC"), params, &logs)
+	if err != nil {
+		common.InternalServerError(c, "Failed to query shadow logs: "+err.Error())
+		return
+	}
+
+	common.Success(c, common.NewPaginationResponse(logs, total, params))
+}
+
 // GetChatIO 查询指定日志的输入输出记录
 func GetChatIO(c *gin.Context) {
 	id := c.Param("id")
 
-	chatIO, err := gorm.G[models.ChatIO](models.DB).Where("log_id = ?", id).First(c.Request.Context())
+	chatIO, err := gorm.G[models.ChatIO](models.Reader()).Where("log_id = ?", id).First(c.Request.Context())
 	if err != nil {
 		common.NotFound(c, "ChatIO not found")
 		return
 	}
+	// 内容存放在对象存储时读回；读取失败只返回错误信息，不影响日志其它功能
+	if err := service.LoadChatIOBlob(c.Request.Context(), &chatIO); err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusOK, http.StatusBadGateway, "Failed to load chat io from blob store: "+err.Error())
+		return
+	}
+	// decode=true 时还原以 base64 保存的内容，默认返回原始记录
+	if c.Query("decode") == "true" {
+		service.DecodeChatIO(&chatIO)
+	}
 
 	common.Success(c, chatIO)
 }
 
+// GetLogTimeline 获取被采样请求的生命周期时间线
+func GetLogTimeline(c *gin.Context) {
+	id := c.Param("id")
+
+	log, err := gorm.G[models.ChatLog](models.Reader()).Where("id = ?", id).First(c.Request.Context())
+	if err != nil {
+		common.NotFound(c, "Log not found")
+		return
+	}
+	if log.Timeline == "" {
+		common.NotFound(c, "Timeline not recorded for this request")
+		return
+	}
+
+	var events []service.TimelineEvent
+	if err := json.Unmarshal([]byte(log.Timeline), &events); err != nil {
+		common.InternalServerError(c, "Failed to parse timeline: "+err.Error())
+		return
+	}
+	common.Success(c, events)
+}
+
 // GetUserAgents 获取所有不重复的用户代理种类
 func GetUserAgents(c *gin.Context) {
 	var userAgents []string
 
 	// 查询所有不重复的非空用户代理
-	if err := models.DB.Model(&models.ChatLog{}).
+	if err := models.Reader().Model(&models.ChatLog{}).
 		Where("user_agent IS NOT NULL AND user_agent != ''").
 		Distinct("user_agent").
 		Pluck("user_agent", &userAgents).
@@ -980,6 +1506,31 @@ func UpdateConfigByKey(c *gin.Context) {
 		return
 	}
 
+	// 结构化配置在保存前校验
+	if key == models.KeySLO && strings.TrimSpace(req.Value) != "" {
+		var cfg models.SLOConfig
+		if err := json.Unmarshal([]byte(req.Value), &cfg); err != nil {
+			common.BadRequest(c, "Invalid SLO config: "+err.Error())
+			return
+		}
+		if err := service.ValidateSLOConfig(cfg); err != nil {
+			common.BadRequest(c, "Invalid SLO config: "+err.Error())
+			return
+		}
+	}
+
+	if key == models.KeyProviderHealthAlert && strings.TrimSpace(req.Value) != "" {
+		var cfg models.ProviderHealthAlertConfig
+		if err := json.Unmarshal([]byte(req.Value), &cfg); err != nil {
+			common.BadRequest(c, "Invalid provider health alert config: "+err.Error())
+			return
+		}
+		if err := service.ValidateProviderHealthAlertConfig(cfg); err != nil {
+			common.BadRequest(c, "Invalid provider health alert config: "+err.Error())
+			return
+		}
+	}
+
 	// 获取或创建配置记录
 	config, err := gorm.G[models.Config](models.DB).Where("key = ?", key).First(c.Request.Context())
 	if err != nil {
@@ -1005,6 +1556,7 @@ func UpdateConfigByKey(c *gin.Context) {
 			return
 		}
 	}
+	service.InvalidateConfigCache(key)
 
 	common.Success(c, map[string]string{
 		"key":   config.Key,
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+func TestBulkModelProviderStatusQuery(t *testing.T) {
+	db := dryRunDB(t)
+	tests := []struct {
+		name       string
+		providerID uint
+		ids        []uint
+		status     int
+		want       []string
+		absent     []string
+	}{
+		{
+			name:       "disable by provider",
+			providerID: 7,
+			status:     0,
+			// 0 值也必须写入
+			want:   []string{`"status"=0`, "provider_id = 7"},
+			absent: []string{"id IN"},
+		},
+		{
+			name:   "enable by ids",
+			ids:    []uint{1, 2},
+			status: 1,
+			want:   []string{`"status"=1`, "id IN (1,2)"},
+			absent: []string{"provider_id"},
+		},
+		{
+			name:       "provider intersect ids",
+			providerID: 7,
+			ids:        []uint{3},
+			status:     0,
+			want:       []string{"provider_id = 7", "id IN (3)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return bulkModelProviderStatusQuery(tx, tt.providerID, tt.ids, tt.status)
+			})
+			if !strings.HasPrefix(sql, `UPDATE "model_with_providers" SET "status"=`) {
+				t.Fatalf("unexpected statement: %s", sql)
+			}
+			// 软删除的关联不受影响
+			if !strings.Contains(sql, `"deleted_at" IS NULL`) {
+				t.Fatalf("sql %q missing soft delete condition", sql)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(sql, want) {
+					t.Fatalf("sql %q missing %q", sql, want)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(sql, absent) {
+					t.Fatalf("sql %q should not contain %q", sql, absent)
+				}
+			}
+		})
+	}
+}
+
+func TestBulkUpdateModelProviderStatusValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty object", `{"status":false}`},
+		{"empty ids", `{"ids":[],"status":false}`},
+		{"missing status", `{"provider_id":1}`},
+		{"invalid json", `{`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPatch, "/api/model-providers/status/bulk", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			// 校验失败时在访问数据库前返回
+			BulkUpdateModelProviderStatus(c)
+
+			var resp struct {
+				Code int `json:"code"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != http.StatusBadRequest {
+				t.Fatalf("code = %d, want %d; body %s", resp.Code, http.StatusBadRequest, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProviderFromRequestValidatesQueryParams(t *testing.T) {
+	tests := []struct {
+		config  string
+		wantErr bool
+	}{
+		{`{"base_url":"https://api.example.com/v1","query_params":{"region":"eu"}}`, false},
+		{`{"base_url":"https://api.example.com/v1","query_params":{"region":1}}`, true},
+		{`{"base_url":"https://api.example.com/v1","query_params":"region=eu"}`, true},
+	}
+	for _, tt := range tests {
+		_, err := providerFromRequest(ProviderRequest{Name: "p", Type: "openai", Config: tt.config})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("providerFromRequest(%s) err = %v, wantErr %v", tt.config, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "query_params") {
+			t.Errorf("err = %v, want query_params error", err)
+		}
+	}
+}
+
+func TestUpdateProviderClearsTpmLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := models.Provider{Name: "p", Type: consts.StyleOpenAI, Config: `{}`}
+	provider.ID = 4
+	stubRecordsDB(t, map[string]any{"providers": []models.Provider{provider}})
+
+	for _, limit := range []int{100, 0} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		body := fmt.Sprintf(`{"name":"p","type":"openai","config":"{}","tpm_limit":%d}`, limit)
+		c.Request = httptest.NewRequest(http.MethodPut, "/api/providers/4", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: "4"}}
+
+		UpdateProvider(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("tpm_limit %d: status = %d, body %s", limit, w.Code, w.Body.String())
+		}
+		reloaded, err := gorm.G[models.Provider](models.DB).Where("id = ?", 4).First(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reloaded.TpmLimit != limit {
+			t.Fatalf("tpm_limit = %d, want %d", reloaded.TpmLimit, limit)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math"
 	"net/http"
 	"sort"
@@ -14,6 +16,7 @@ import (
 	"github.com/racio/llmio/common"
 	"github.com/racio/llmio/consts"
 	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/service"
 	"gorm.io/gorm"
 )
 
@@ -34,20 +37,114 @@ type AuthKeySummaryRes struct {
 	OutputCost       float64    `json:"outputCost"`
 	AllowAll         bool       `json:"allowAll"`
 	Models           []string   `json:"models"`
+	// MaxConcurrency 并发上限（0 表示不限制），CurrentConcurrency 当前在途请求数
+	MaxConcurrency     int `json:"maxConcurrency"`
+	CurrentConcurrency int `json:"currentConcurrency"`
 }
 
-type authKeyTokenAgg struct {
-	Model      string `gorm:"column:model"`
-	Prompt     int64  `gorm:"column:prompt"`
-	Completion int64  `gorm:"column:completion"`
+// AuthKeyModelUsage API Key 按模型的用量：TotalCost 为请求记录的实际费用，InputCost/OutputCost 按当前模型价格计算
+type AuthKeyModelUsage struct {
+	Model            string  `json:"model" gorm:"column:model"`
+	Requests         int64   `json:"requests" gorm:"column:requests"`
+	PromptTokens     int64   `json:"promptTokens" gorm:"column:prompt"`
+	CompletionTokens int64   `json:"completionTokens" gorm:"column:completion"`
+	TotalTokens      int64   `json:"totalTokens" gorm:"column:total"`
+	TotalCost        float64 `json:"totalCost" gorm:"column:total_cost"`
+	InputCost        float64 `json:"inputCost" gorm:"-"`
+	OutputCost       float64 `json:"outputCost" gorm:"-"`
+}
+
+// authKeyFromContext 取出当前请求的 API Key ID，非 API Key 访问时返回 403
+func authKeyFromContext(c *gin.Context) (uint, bool) {
+	authKeyID, ok := c.Request.Context().Value(consts.ContextKeyAuthKeyID).(uint)
+	if !ok || authKeyID == 0 {
+		common.ErrorWithHttpStatus(c, http.StatusForbidden, http.StatusForbidden, "auth key required")
+		return 0, false
+	}
+	return authKeyID, true
+}
+
+// loadAuthKeyModelUsage 按模型名称（不区分大小写）汇总 API Key 的请求数、token 与费用，按实际费用降序
+func loadAuthKeyModelUsage(ctx context.Context, authKeyID uint) ([]AuthKeyModelUsage, error) {
+	usage := make([]AuthKeyModelUsage, 0)
+	if err := authKeyModelUsageQuery(models.DB.WithContext(ctx), authKeyID).Scan(&usage).Error; err != nil {
+		return nil, fmt.Errorf("aggregate tokens: %w", err)
+	}
+
+	modelIDs := make([]string, 0, len(usage))
+	for _, item := range usage {
+		if item.Model != "" {
+			modelIDs = append(modelIDs, item.Model)
+		}
+	}
+	prices := make([]models.ModelPrice, 0, len(modelIDs))
+	if len(modelIDs) > 0 {
+		if err := models.DB.WithContext(ctx).
+			Where("model_id IN ?", modelIDs).
+			Find(&prices).Error; err != nil {
+			return nil, fmt.Errorf("query model prices: %w", err)
+		}
+	}
+	return priceModelUsage(usage, prices), nil
+}
+
+// authKeyModelUsageQuery API Key 按模型名称（不区分大小写）聚合请求数、token 与实际费用
+func authKeyModelUsageQuery(tx *gorm.DB, authKeyID uint) *gorm.DB {
+	return tx.
+		Model(&models.ChatLog{}).
+		Where("deleted_at IS NULL").
+		Where("auth_key_id = ?", authKeyID).
+		Select(
+			"LOWER(name) AS model, COUNT(id) AS requests, COALESCE(SUM(prompt_tokens),0) AS prompt, " +
+				"COALESCE(SUM(completion_tokens),0) AS completion, COALESCE(SUM(total_tokens),0) AS total, COALESCE(SUM(total_cost),0) AS total_cost",
+		).
+		Group("LOWER(name)")
+}
+
+// priceModelUsage 按当前模型价格计算输入/输出费用，并按实际费用降序、模型名升序排列
+func priceModelUsage(usage []AuthKeyModelUsage, prices []models.ModelPrice) []AuthKeyModelUsage {
+	priceMap := make(map[string]models.ModelPrice, len(prices))
+	for _, price := range prices {
+		priceMap[price.ModelID] = price
+	}
+
+	for i := range usage {
+		price, ok := priceMap[usage[i].Model]
+		if !ok {
+			continue
+		}
+		usage[i].InputCost = float64(usage[i].PromptTokens) * price.Input
+		usage[i].OutputCost = float64(usage[i].CompletionTokens) * price.Output
+	}
+
+	sort.SliceStable(usage, func(i, j int) bool {
+		if usage[i].TotalCost != usage[j].TotalCost {
+			return usage[i].TotalCost > usage[j].TotalCost
+		}
+		return usage[i].Model < usage[j].Model
+	})
+	return usage
+}
+
+// AuthKeyModelUsageHandler 返回当前 API Key 按模型的用量明细，用于查看消费主要来自哪些模型
+func AuthKeyModelUsageHandler(c *gin.Context) {
+	authKeyID, ok := authKeyFromContext(c)
+	if !ok {
+		return
+	}
+	usage, err := loadAuthKeyModelUsage(c.Request.Context(), authKeyID)
+	if err != nil {
+		common.InternalServerError(c, "Failed to load model usage: "+err.Error())
+		return
+	}
+	common.Success(c, usage)
 }
 
 // AuthKeySummary 返回 API Key 视角的概览数据
 func AuthKeySummary(c *gin.Context) {
 	ctx := c.Request.Context()
-	authKeyID, ok := ctx.Value(consts.ContextKeyAuthKeyID).(uint)
-	if !ok || authKeyID == 0 {
-		common.ErrorWithHttpStatus(c, http.StatusForbidden, http.StatusForbidden, "auth key required")
+	authKeyID, ok := authKeyFromContext(c)
+	if !ok {
 		return
 	}
 
@@ -111,48 +208,16 @@ func AuthKeySummary(c *gin.Context) {
 		return
 	}
 
-	modelAgg := make([]authKeyTokenAgg, 0)
-	if err := base.Select(
-		"LOWER(name) AS model, COALESCE(SUM(prompt_tokens),0) AS prompt, COALESCE(SUM(completion_tokens),0) AS completion",
-	).Group("LOWER(name)").Scan(&modelAgg).Error; err != nil {
-		common.InternalServerError(c, "Failed to aggregate tokens: "+err.Error())
+	modelUsage, err := loadAuthKeyModelUsage(ctx, authKeyID)
+	if err != nil {
+		common.InternalServerError(c, "Failed to load model usage: "+err.Error())
 		return
 	}
-
 	inputCost := 0.0
 	outputCost := 0.0
-	if len(modelAgg) > 0 {
-		modelIDs := make([]string, 0, len(modelAgg))
-		for _, item := range modelAgg {
-			if item.Model == "" {
-				continue
-			}
-			modelIDs = append(modelIDs, item.Model)
-		}
-
-		if len(modelIDs) > 0 {
-			prices := make([]models.ModelPrice, 0, len(modelIDs))
-			if err := models.DB.WithContext(ctx).
-				Where("model_id IN ?", modelIDs).
-				Find(&prices).Error; err != nil {
-				common.InternalServerError(c, "Failed to query model prices: "+err.Error())
-				return
-			}
-
-			priceMap := make(map[string]models.ModelPrice, len(prices))
-			for _, price := range prices {
-				priceMap[price.ModelID] = price
-			}
-
-			for _, item := range modelAgg {
-				price, ok := priceMap[item.Model]
-				if !ok {
-					continue
-				}
-				inputCost += float64(item.Prompt) * price.Input
-				outputCost += float64(item.Completion) * price.Output
-			}
-		}
+	for _, item := range modelUsage {
+		inputCost += item.InputCost
+		outputCost += item.OutputCost
 	}
 
 	allowAll, _ := ctx.Value(consts.ContextKeyAllowAllModel).(bool)
@@ -176,6 +241,11 @@ func AuthKeySummary(c *gin.Context) {
 		sort.Strings(allowedModels)
 	}
 
+	currentConcurrency, err := service.GetKeyConcurrency(ctx, authKeyID)
+	if err != nil {
+		slog.Warn("Failed to load key concurrency", "auth_key_id", authKeyID, "error", err)
+	}
+
 	var expireInDays *int
 	if authKey.ExpiresAt != nil {
 		days := int(math.Ceil(authKey.ExpiresAt.Sub(time.Now()).Hours() / 24))
@@ -186,22 +256,24 @@ func AuthKeySummary(c *gin.Context) {
 	}
 
 	common.Success(c, AuthKeySummaryRes{
-		Name:             authKey.Name,
-		KeyMasked:        maskAuthKey(authKey.Key),
-		ExpiresAt:        authKey.ExpiresAt,
-		ExpireInDays:     expireInDays,
-		TotalCost:        totalCost.Float64,
-		TotalRequests:    totalRequests,
-		SuccessRequests:  successRequests,
-		FailureRequests:  failureRequests,
-		TotalTimeMs:      totalTime.Int64,
-		PromptTokens:     tokens.Prompt.Int64,
-		CompletionTokens: tokens.Completion.Int64,
-		TotalTokens:      tokens.Total.Int64,
-		InputCost:        inputCost,
-		OutputCost:       outputCost,
-		AllowAll:         allowAll,
-		Models:           allowedModels,
+		Name:               authKey.Name,
+		KeyMasked:          maskAuthKey(authKey.Key),
+		ExpiresAt:          authKey.ExpiresAt,
+		ExpireInDays:       expireInDays,
+		TotalCost:          totalCost.Float64,
+		TotalRequests:      totalRequests,
+		SuccessRequests:    successRequests,
+		FailureRequests:    failureRequests,
+		TotalTimeMs:        totalTime.Int64,
+		PromptTokens:       tokens.Prompt.Int64,
+		CompletionTokens:   tokens.Completion.Int64,
+		TotalTokens:        tokens.Total.Int64,
+		InputCost:          inputCost,
+		OutputCost:         outputCost,
+		AllowAll:           allowAll,
+		Models:             allowedModels,
+		MaxConcurrency:     authKey.MaxConcurrency,
+		CurrentConcurrency: currentConcurrency,
 	})
 }
 
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+func TestAuthKeyModelUsageQuery(t *testing.T) {
+	db := dryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var usage []AuthKeyModelUsage
+		return authKeyModelUsageQuery(tx, 7).Find(&usage)
+	})
+	for _, want := range []string{`FROM "chat_logs"`, "auth_key_id = 7", "deleted_at IS NULL", "GROUP BY LOWER(name)", "LOWER(name) AS model"} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("sql %q missing %q", sql, want)
+		}
+	}
+}
+
+func TestPriceModelUsageMultipleModels(t *testing.T) {
+	usage := []AuthKeyModelUsage{
+		{Model: "gpt-4o-mini", Requests: 10, PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500, TotalCost: 0.5},
+		{Model: "claude-sonnet-4", Requests: 3, PromptTokens: 200, CompletionTokens: 100, TotalTokens: 300, TotalCost: 2},
+		{Model: "unpriced", Requests: 1, PromptTokens: 50, CompletionTokens: 50, TotalTokens: 100, TotalCost: 0.5},
+	}
+	prices := []models.ModelPrice{
+		{ModelID: "gpt-4o-mini", Input: 0.001, Output: 0.002},
+		{ModelID: "claude-sonnet-4", Input: 0.003, Output: 0.015},
+	}
+
+	got := priceModelUsage(usage, prices)
+
+	// 按实际费用降序，费用相同时按模型名升序
+	order := []string{"claude-sonnet-4", "gpt-4o-mini", "unpriced"}
+	for i, model := range order {
+		if got[i].Model != model {
+			t.Fatalf("order[%d] = %s, want %s", i, got[i].Model, model)
+		}
+	}
+	want := map[string][2]float64{
+		"claude-sonnet-4": {0.6, 1.5},
+		"gpt-4o-mini":     {1, 1},
+		"unpriced":        {0, 0},
+	}
+	for _, item := range got {
+		w := want[item.Model]
+		if math.Abs(item.InputCost-w[0]) > 1e-9 || math.Abs(item.OutputCost-w[1]) > 1e-9 {
+			t.Fatalf("%s cost = %v/%v, want %v/%v", item.Model, item.InputCost, item.OutputCost, w[0], w[1])
+		}
+	}
+}
+
+func TestAuthKeyModelUsageRequiresAuthKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"missing", context.Background()},
+		{"zero id", context.WithValue(context.Background(), consts.ContextKeyAuthKeyID, uint(0))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/auth-key/models", nil).WithContext(tt.ctx)
+
+			// 校验失败时在访问数据库前返回
+			AuthKeyModelUsageHandler(c)
+
+			var resp struct {
+				Code int `json:"code"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusForbidden || resp.Code != http.StatusForbidden {
+				t.Fatalf("status = %d code = %d, want 403; body %s", w.Code, resp.Code, w.Body)
+			}
+		})
+	}
+}
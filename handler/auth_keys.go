@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/racio/llmio/consts"
 	"github.com/racio/llmio/models"
 	"github.com/racio/llmio/pkg"
+	"github.com/racio/llmio/service"
 	"gorm.io/gorm"
 )
 
@@ -22,6 +24,12 @@ type AuthKeyRequest struct {
 	AllowAll  *bool    `json:"allow_all"`
 	Models    []string `json:"models"`
 	ExpiresAt *string  `json:"expires_at"`
+	// MaxConcurrency 最大并发请求数，0 表示不限制；未传时创建默认 0、更新保持原值
+	MaxConcurrency *int `json:"max_concurrency"`
+	// RpmLimit 每分钟最大请求数，0 表示不限制；未传时创建默认 0、更新保持原值
+	RpmLimit *int `json:"rpm_limit"`
+	// MonthlyBudget 每月消费上限，0 表示不限制；未传时创建默认 0、更新保持原值
+	MonthlyBudget *float64 `json:"monthly_budget"`
 }
 
 // boolPtrToInt 将bool指针转换为int，nil时返回默认值
@@ -48,6 +56,24 @@ func sanitizeModelsToString(modelsList []string) string {
 	return string(jsonBytes)
 }
 
+// AuthKeyWithExpired 在 AuthKey 基础上附加是否已过期的计算字段
+type AuthKeyWithExpired struct {
+	models.AuthKey
+	Expired bool `json:"expired"`
+}
+
+// withExpired 按 now 计算每个 AuthKey 是否已过期
+func withExpired(keys []models.AuthKey, now time.Time) []AuthKeyWithExpired {
+	wrapKeys := make([]AuthKeyWithExpired, 0, len(keys))
+	for _, key := range keys {
+		wrapKeys = append(wrapKeys, AuthKeyWithExpired{
+			AuthKey: key,
+			Expired: key.ExpiresAt != nil && key.ExpiresAt.Before(now),
+		})
+	}
+	return wrapKeys
+}
+
 func GetAuthKeys(c *gin.Context) {
 	// 解析分页参数
 	params, err := common.ParsePagination(c)
@@ -104,7 +130,7 @@ func GetAuthKeys(c *gin.Context) {
 	}
 
 	// 返回分页响应
-	response := common.NewPaginationResponse(keys, total, params)
+	response := common.NewPaginationResponse(withExpired(keys, time.Now()), total, params)
 	common.Success(c, response)
 }
 
@@ -136,6 +162,13 @@ func CreateAuthKey(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
+	// 新建的 Key 为启用状态时才占用上限
+	if boolPtrToInt(req.Status, 1) == 1 {
+		if err := service.CheckResourceLimit(ctx, service.ResourceAuthKeys); err != nil {
+			respondResourceLimit(c, err)
+			return
+		}
+	}
 
 	authKey := models.AuthKey{
 		Name:      req.Name,
@@ -145,6 +178,15 @@ func CreateAuthKey(c *gin.Context) {
 		Models:    sanitizeModelsToString(req.Models),
 		ExpiresAt: expiresAt,
 	}
+	if req.MaxConcurrency != nil {
+		authKey.MaxConcurrency = *req.MaxConcurrency
+	}
+	if req.RpmLimit != nil {
+		authKey.RpmLimit = *req.RpmLimit
+	}
+	if req.MonthlyBudget != nil {
+		authKey.MonthlyBudget = *req.MonthlyBudget
+	}
 
 	if err := gorm.G[models.AuthKey](models.DB).Create(ctx, &authKey); err != nil {
 		common.InternalServerError(c, "Failed to create auth key: "+err.Error())
@@ -175,7 +217,8 @@ func UpdateAuthKey(c *gin.Context) {
 
 	ctx := c.Request.Context()
 
-	if _, err := gorm.G[models.AuthKey](models.DB).Where("id = ?", id).First(ctx); err != nil {
+	existing, err := gorm.G[models.AuthKey](models.DB).Where("id = ?", id).First(ctx)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			common.NotFound(c, "Auth key not found")
 			return
@@ -183,6 +226,13 @@ func UpdateAuthKey(c *gin.Context) {
 		common.InternalServerError(c, "Failed to load auth key: "+err.Error())
 		return
 	}
+	// 重新启用已禁用的 Key 同样受启用数量上限约束
+	if existing.Status != 1 && boolPtrToInt(req.Status, 1) == 1 {
+		if err := service.CheckResourceLimit(ctx, service.ResourceAuthKeys); err != nil {
+			respondResourceLimit(c, err)
+			return
+		}
+	}
 
 	var expiresAt *time.Time
 	if req.ExpiresAt != nil {
@@ -209,6 +259,26 @@ func UpdateAuthKey(c *gin.Context) {
 		}
 	}
 
+	// Updates 会忽略零值，并发上限单独更新以支持改回 0（不限制）
+	if req.MaxConcurrency != nil {
+		if _, err := gorm.G[models.AuthKey](models.DB).Where("id = ?", id).Update(ctx, "max_concurrency", *req.MaxConcurrency); err != nil {
+			common.InternalServerError(c, "Failed to update max_concurrency: "+err.Error())
+			return
+		}
+	}
+	if req.RpmLimit != nil {
+		if _, err := gorm.G[models.AuthKey](models.DB).Where("id = ?", id).Update(ctx, "rpm_limit", *req.RpmLimit); err != nil {
+			common.InternalServerError(c, "Failed to update rpm_limit: "+err.Error())
+			return
+		}
+	}
+	if req.MonthlyBudget != nil {
+		if _, err := gorm.G[models.AuthKey](models.DB).Where("id = ?", id).Update(ctx, "monthly_budget", *req.MonthlyBudget); err != nil {
+			common.InternalServerError(c, "Failed to update monthly_budget: "+err.Error())
+			return
+		}
+	}
+
 	if _, err := gorm.G[models.AuthKey](models.DB).Where("id = ?", id).Updates(ctx, update); err != nil {
 		common.InternalServerError(c, "Failed to update auth key: "+err.Error())
 		return
@@ -238,6 +308,66 @@ func DeleteAuthKey(c *gin.Context) {
 	common.SuccessWithMessage(c, "Deleted", gin.H{"id": id})
 }
 
+// BulkDeleteAuthKeysRequest 批量删除请求：ids 与 filter 至少提供一个，二者同时提供时取交集
+type BulkDeleteAuthKeysRequest struct {
+	IDs    []uint `json:"ids"`
+	Filter string `json:"filter"` // "expired" 已过期 / "inactive" 已禁用
+}
+
+// BulkDeleteAuthKeys 按 ID 列表或过滤条件批量删除 AuthKey
+func BulkDeleteAuthKeys(c *gin.Context) {
+	var req BulkDeleteAuthKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	filter := strings.TrimSpace(req.Filter)
+	// 防止误删：空条件绝不等于“全部删除”
+	if len(req.IDs) == 0 && filter == "" {
+		common.BadRequest(c, "ids or filter is required")
+		return
+	}
+
+	switch filter {
+	case "", "expired", "inactive":
+	default:
+		common.BadRequest(c, "Invalid filter: must be 'expired' or 'inactive'")
+		return
+	}
+
+	var deletedCount int64
+	err := models.DB.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		result := bulkDeleteAuthKeysQuery(tx, req.IDs, filter, time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		deletedCount = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		common.InternalServerError(c, "Failed to delete auth keys: "+err.Error())
+		return
+	}
+
+	common.Success(c, map[string]any{"deleted_count": deletedCount})
+}
+
+// bulkDeleteAuthKeysQuery 按 ID 列表与过滤条件构造批量删除语句
+func bulkDeleteAuthKeysQuery(tx *gorm.DB, ids []uint, filter string, now time.Time) *gorm.DB {
+	query := tx.Model(&models.AuthKey{})
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	switch filter {
+	case "expired":
+		query = query.Where("expires_at IS NOT NULL AND expires_at < ?", now)
+	case "inactive":
+		query = query.Where("status = ?", 0)
+	}
+	return query.Delete(&models.AuthKey{})
+}
+
 // ToggleAuthKeyStatus 切换 AuthKey 状态
 func ToggleAuthKeyStatus(c *gin.Context) {
 	idStr := c.Param("id")
@@ -266,6 +396,10 @@ func ToggleAuthKeyStatus(c *gin.Context) {
 		newStatus = 0
 	} else {
 		newStatus = 1
+		if err := service.CheckResourceLimit(ctx, service.ResourceAuthKeys); err != nil {
+			respondResourceLimit(c, err)
+			return
+		}
 	}
 	update := models.AuthKey{
 		Status: newStatus,
@@ -313,6 +447,15 @@ func validateAuthKeyRequest(req AuthKeyRequest) error {
 	if req.AllowAll != nil && !*req.AllowAll && len(req.Models) == 0 {
 		return errors.New("请至少选择一个允许的模型或启用允许全部模型")
 	}
+	if req.MaxConcurrency != nil && *req.MaxConcurrency < 0 {
+		return errors.New("max_concurrency 不能为负数")
+	}
+	if req.RpmLimit != nil && *req.RpmLimit < 0 {
+		return errors.New("rpm_limit 不能为负数")
+	}
+	if req.MonthlyBudget != nil && *req.MonthlyBudget < 0 {
+		return errors.New("monthly_budget 不能为负数")
+	}
 	return nil
 }
 
@@ -332,3 +475,49 @@ func sanitizeModels(modelsList []string) []string {
 	}
 	return result
 }
+
+// GetAuthKeySpend 获取 AuthKey 本月消费与剩余预算
+func GetAuthKeySpend(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID")
+		return
+	}
+	ctx := c.Request.Context()
+	authKey, err := gorm.G[models.AuthKey](models.Reader()).Where("id = ?", id).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.NotFound(c, "Auth key not found")
+			return
+		}
+		common.InternalServerError(c, "Failed to load auth key: "+err.Error())
+		return
+	}
+	spend, err := service.GetMonthSpend(ctx, authKey.ID)
+	if err != nil {
+		common.InternalServerError(c, "Failed to load month spend: "+err.Error())
+		return
+	}
+	// 未设置预算时剩余额度为 null
+	var remaining *float64
+	if authKey.MonthlyBudget > 0 {
+		left := max(authKey.MonthlyBudget-spend, 0)
+		remaining = &left
+	}
+	common.Success(c, gin.H{
+		"auth_key_id":    authKey.ID,
+		"month_start":    service.MonthStart(time.Now()),
+		"spend":          spend,
+		"monthly_budget": authKey.MonthlyBudget,
+		"remaining":      remaining,
+	})
+}
+
+// respondResourceLimit 达到资源数量上限时返回 403 及 RESOURCE_LIMIT_REACHED，其它错误按内部错误处理
+func respondResourceLimit(c *gin.Context, err error) {
+	if common.ErrorCodeOf(err) == common.ErrCodeResourceLimit {
+		common.ErrorWithCode(c, http.StatusForbidden, http.StatusForbidden, common.ErrCodeResourceLimit, err.Error())
+		return
+	}
+	common.InternalServerError(c, "Failed to check resource limit: "+err.Error())
+}
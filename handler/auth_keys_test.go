@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB 返回一个不连接数据库的 PostgreSQL 会话，仅用于通过 ToSQL 检查生成的语句
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1"), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestBulkDeleteAuthKeysQuery(t *testing.T) {
+	db := dryRunDB(t)
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		ids    []uint
+		filter string
+		want   []string
+		absent []string
+	}{
+		{
+			name:   "expired",
+			filter: "expired",
+			want:   []string{`expires_at IS NOT NULL AND expires_at < '2026-10-17 08:00:00'`, `"deleted_at" IS NULL`},
+			absent: []string{"id IN", "status ="},
+		},
+		{
+			name:   "inactive",
+			filter: "inactive",
+			want:   []string{"status = 0"},
+			absent: []string{"expires_at", "id IN"},
+		},
+		{
+			name:   "ids intersect expired",
+			ids:    []uint{1, 2},
+			filter: "expired",
+			want:   []string{"id IN (1,2)", "expires_at < '2026-10-17 08:00:00'"},
+		},
+		{
+			name:   "ids only",
+			ids:    []uint{3},
+			want:   []string{"id IN (3)"},
+			absent: []string{"expires_at", "status ="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return bulkDeleteAuthKeysQuery(tx, tt.ids, tt.filter, now)
+			})
+			// 软删除：生成 UPDATE ... SET deleted_at
+			if !strings.HasPrefix(sql, `UPDATE "auth_keys" SET "deleted_at"=`) {
+				t.Fatalf("unexpected statement: %s", sql)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(sql, want) {
+					t.Fatalf("sql %q missing %q", sql, want)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(sql, absent) {
+					t.Fatalf("sql %q should not contain %q", sql, absent)
+				}
+			}
+		})
+	}
+}
+
+func TestBulkDeleteAuthKeysRejectsEmptyCondition(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty object", `{}`},
+		{"empty ids and filter", `{"ids":[],"filter":"  "}`},
+		{"unknown filter", `{"filter":"all"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/auth-keys/bulk-delete", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			// 校验失败时在访问数据库前返回
+			BulkDeleteAuthKeys(c)
+
+			var resp struct {
+				Code int `json:"code"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != http.StatusBadRequest {
+				t.Fatalf("code = %d, want %d; body %s", resp.Code, http.StatusBadRequest, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithExpired(t *testing.T) {
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	keys := []models.AuthKey{
+		{Name: "never"},
+		{Name: "past", ExpiresAt: &past},
+		{Name: "future", ExpiresAt: &future},
+		{Name: "exact", ExpiresAt: &now},
+	}
+	want := map[string]bool{"never": false, "past": true, "future": false, "exact": false}
+
+	got := withExpired(keys, now)
+	if len(got) != len(keys) {
+		t.Fatalf("len = %d, want %d", len(got), len(keys))
+	}
+	for _, key := range got {
+		if key.Expired != want[key.Name] {
+			t.Fatalf("%s expired = %v, want %v", key.Name, key.Expired, want[key.Name])
+		}
+	}
+
+	// expired 与 AuthKey 字段平铺在同一层
+	raw, err := json.Marshal(got[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), `"expired":true`) || !strings.Contains(string(raw), `"Name":"past"`) {
+		t.Fatalf("unexpected json %s", raw)
+	}
+}
+
+func TestRespondResourceLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"limit reached", common.NewError(common.ErrCodeResourceLimit, "maximum number of auth keys reached (limit 2)"), http.StatusForbidden},
+		{"count failed", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			respondResourceLimit(c, tt.err)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
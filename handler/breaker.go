@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/balancers"
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/models"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// BreakerStatusItem 熔断节点状态及对应的模型-提供商关联信息
+type BreakerStatusItem struct {
+	balancers.NodeSnapshot
+	ProviderID    uint   `json:"provider_id"`
+	ProviderModel string `json:"provider_model"`
+}
+
+// GetBreakerStatus 获取各模型-提供商关联当前的熔断状态
+func GetBreakerStatus(c *gin.Context) {
+	snapshot := balancers.Snapshot()
+	if len(snapshot) == 0 {
+		common.Success(c, []BreakerStatusItem{})
+		return
+	}
+
+	modelProviders, err := gorm.G[models.ModelWithProvider](models.DB).
+		Where("id IN ?", lo.Map(snapshot, func(n balancers.NodeSnapshot, _ int) uint { return n.Key })).
+		Find(c.Request.Context())
+	if err != nil {
+		common.InternalServerError(c, err.Error())
+		return
+	}
+	mpMap := lo.KeyBy(modelProviders, func(mp models.ModelWithProvider) uint { return mp.ID })
+
+	items := make([]BreakerStatusItem, 0, len(snapshot))
+	for _, node := range snapshot {
+		item := BreakerStatusItem{NodeSnapshot: node}
+		if mp, ok := mpMap[node.Key]; ok {
+			item.ProviderID = mp.ProviderID
+			item.ProviderModel = mp.ProviderModel
+		}
+		items = append(items, item)
+	}
+	common.Success(c, items)
+}
+
+// ResetBreakerStatus 手动重置模型-提供商关联的熔断状态（如上游已恢复，不等待冷却结束）
+func ResetBreakerStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID format")
+		return
+	}
+	common.Success(c, gin.H{"reset": balancers.ResetBreaker(uint(id))})
+}
@@ -1,13 +1,16 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +20,7 @@ import (
 	"github.com/racio/llmio/limiter"
 	"github.com/racio/llmio/models"
 	"github.com/racio/llmio/service"
+	"github.com/samber/lo"
 )
 
 func ChatCompletionsHandler(c *gin.Context) {
@@ -76,6 +80,10 @@ func GeminiGenerateContentHandler(c *gin.Context) {
 }
 
 func chatHandler(c *gin.Context, preProcessor service.Beforer, postProcessor service.Processer, providerType string, logStyle string) {
+	// 按采样率为请求开启生命周期时间线
+	c.Request = c.Request.WithContext(service.StartTimeline(c.Request.Context()))
+	service.RecordTimeline(c.Request.Context(), "received", map[string]any{"style": logStyle, "remote_ip": c.ClientIP()})
+
 	// 读取原始请求体
 	reqBody, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -83,14 +91,44 @@ func chatHandler(c *gin.Context, preProcessor service.Beforer, postProcessor ser
 		return
 	}
 	c.Request.Body.Close()
+	// 幂等校验按客户端原始请求体计算，不受模板、系统提示词等服务端改写影响
+	requestHash := service.IdempotencyRequestHash(reqBody)
+	// 展开请求头引用的服务端提示词模板
+	reqBody, err = service.ApplyPromptTemplate(c.Request.Context(), c.Request.Header, logStyle, reqBody)
+	if err != nil {
+		switch common.ErrorCodeOf(err) {
+		case common.ErrCodeBadRequest, common.ErrCodeNotFound:
+			common.ErrorWithCode(c, http.StatusBadRequest, http.StatusBadRequest, common.ErrorCodeOf(err), err.Error())
+		default:
+			common.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	// collect 模式（?collect=true）：以流式请求上游，服务端聚合为单个 chat.completion JSON 返回，供无法处理 SSE 的客户端使用
+	collect := logStyle == consts.StyleOpenAI && isCollectMode(c)
+	if collect {
+		if reqBody, err = service.EnableCollectStream(reqBody); err != nil {
+			common.BadRequest(c, "Invalid request body: "+err.Error())
+			return
+		}
+	}
 	// 预处理、提取模型参数
 	before, err := preProcessor(reqBody)
 	if err != nil {
 		common.InternalServerError(c, err.Error())
 		return
 	}
+	// 客户端请求流式输出时，转发前的错误改为 SSE error 事件返回
+	if !collect && (before.Stream || strings.Contains(c.GetHeader("Accept"), "text/event-stream")) {
+		common.MarkStreamRequest(c)
+	}
 
 	ctx := c.Request.Context()
+	// 全局模型策略：对所有 Key（包括管理员）生效
+	if status, err := service.CheckModelPolicy(ctx, before.Model); err != nil {
+		common.ErrorWithCode(c, status, status, common.ErrorCodeOf(err), err.Error())
+		return
+	}
 	// 校验 authKey 是否有权限使用该模型
 	valid, err := validateAuthKey(ctx, before.Model)
 	if err != nil {
@@ -101,50 +139,208 @@ func chatHandler(c *gin.Context, preProcessor service.Beforer, postProcessor ser
 		common.ErrorWithHttpStatus(c, http.StatusForbidden, http.StatusForbidden, "auth key has no permission to use this model")
 		return
 	}
+	authKeyID, _ := ctx.Value(consts.ContextKeyAuthKeyID).(uint)
+	service.RecordTimeline(ctx, "auth_resolved", map[string]any{"auth_key_id": authKeyID, "model": before.Model, "stream": before.Stream})
+	// 全局系统提示词（如合规声明）：插入在提示词模板与客户端系统提示词之前
+	if reqBody, err = service.ApplyGlobalSystemPrompt(ctx, logStyle, before, authKeyID); err != nil {
+		common.InternalServerError(c, err.Error())
+		return
+	}
+	// 单 Key RPM 上限：避免单个 Key 占满共享的提供商额度
+	rpmLimit, _ := ctx.Value(consts.ContextKeyRpmLimit).(int)
+	allowed, err := service.AllowKeyRequest(ctx, authKeyID, rpmLimit)
+	if err != nil {
+		common.ErrorWithCode(c, http.StatusServiceUnavailable, http.StatusServiceUnavailable, common.ErrCodeLimiterUnavailable, "限流服务不可用，请稍后重试")
+		return
+	}
+	if !allowed {
+		common.ErrorWithCode(c, http.StatusTooManyRequests, http.StatusTooManyRequests, common.ErrCodeRateLimited, fmt.Sprintf("rate limit exceeded for this key (limit %d requests per minute)", rpmLimit))
+		return
+	}
+	// 单 Key 月度预算：本月累计消费达到预算后拒绝
+	monthlyBudget, _ := ctx.Value(consts.ContextKeyMonthlyBudget).(float64)
+	spend, overBudget, err := service.CheckMonthlyBudget(ctx, authKeyID, monthlyBudget)
+	if err != nil {
+		common.ErrorWithCode(c, http.StatusServiceUnavailable, http.StatusServiceUnavailable, common.ErrCodeBudgetUnavailable, "预算服务不可用，请稍后重试")
+		return
+	}
+	if overBudget {
+		common.ErrorWithCode(c, http.StatusPaymentRequired, http.StatusPaymentRequired, common.ErrCodeBudgetExceeded, fmt.Sprintf("monthly budget exceeded for this key (spent %.4f of %.4f)", spend, monthlyBudget))
+		return
+	}
+	// 单 Key 并发上限：限制同时在途的请求数，响应写完（含客户端断开）后释放
+	maxConcurrency, _ := ctx.Value(consts.ContextKeyMaxConcurrency).(int)
+	acquired, releaseConcurrency, err := service.AcquireKeyConcurrency(ctx, authKeyID, maxConcurrency)
+	if err != nil {
+		common.ErrorWithCode(c, http.StatusServiceUnavailable, http.StatusServiceUnavailable, common.ErrCodeLimiterUnavailable, "限流服务不可用，请稍后重试")
+		return
+	}
+	if !acquired {
+		common.ErrorWithCode(c, http.StatusTooManyRequests, http.StatusTooManyRequests, common.ErrCodeConcurrencyLimited, fmt.Sprintf("too many concurrent requests for this key (limit %d)", maxConcurrency))
+		return
+	}
+	defer releaseConcurrency()
 	// 按模型获取可用 provider
 	providersWithMeta, err := service.ProvidersWithMetaBymodelsName(ctx, providerType, logStyle, *before)
 	if err != nil {
-		common.InternalServerError(c, err.Error())
+		common.ErrorFrom(c, err)
 		return
 	}
+	// 敏感请求：客户端可通过请求头关闭本次请求的 IO 记录，覆盖模型的 IOLog 设置
+	providersWithMeta.ApplyRequestIOLog(c.Request.Header)
+	service.RecordTimeline(ctx, "model_resolved", map[string]any{"strategy": providersWithMeta.Strategy, "candidates": len(providersWithMeta.WeightItems), "max_retry": providersWithMeta.MaxRetry})
+
+	// Gemini 上下文长度预检：超出模型 MaxInputTokens 时直接拒绝，避免浪费一次完整生成调用
+	if logStyle == consts.StyleGemini {
+		if tokens, exceeded := service.PrecheckGeminiInputTokens(ctx, *before, providersWithMeta); exceeded {
+			common.ErrorWithCode(c, http.StatusBadRequest, http.StatusBadRequest, common.ErrCodeInputTooLarge, fmt.Sprintf("input tokens %d exceed model limit %d", tokens, providersWithMeta.MaxInputTokens))
+			return
+		}
+	}
+
+	// 响应缓存：模型开启 cache_ttl_seconds 时，相同的非流式请求直接返回缓存结果，不再调用上游
+	cacheWriter, handled := lookupResponseCache(c, before, logStyle, reqBody, providersWithMeta.CacheTTL)
+	if handled {
+		return
+	}
+
+	// Idempotency-Key 去重：命中时直接重放已有响应
+	idem, handled := beginIdempotency(c, before, logStyle, requestHash, time.Second*time.Duration(providersWithMeta.TimeOut))
+	if handled {
+		return
+	}
+	if idem != nil {
+		defer func() {
+			if !idem.finished {
+				idem.release(context.WithoutCancel(ctx))
+			}
+		}()
+	}
 
 	startReq := time.Now()
 	// 调用负载均衡后的 provider 并转发
-	res, log, err := service.BalanceChatWithLimiter(c, startReq, logStyle, *before, providersWithMeta, models.ReqMeta{
+	reqMeta := models.ReqMeta{
 		Header:    c.Request.Header,
 		RemoteIP:  c.ClientIP(),
 		UserAgent: c.Request.UserAgent(),
-	})
+	}
+	res, log, usedMeta, err := service.BalanceChatWithLimiter(c, startReq, logStyle, *before, providersWithMeta, reqMeta)
 	if err != nil {
 		// 限流/锁定依赖不可用：按 fail-closed 策略直接拒绝
 		if errors.Is(err, limiter.ErrLimiterUnavailable) {
-			common.ErrorWithHttpStatus(c, http.StatusServiceUnavailable, http.StatusServiceUnavailable, "限流服务不可用，请稍后重试")
+			common.ErrorWithCode(c, http.StatusServiceUnavailable, http.StatusServiceUnavailable, common.ErrCodeLimiterUnavailable, "限流服务不可用，请稍后重试")
 			return
 		}
-		common.InternalServerError(c, err.Error())
+		common.ErrorFrom(c, err)
 		return
 	}
 	defer res.Body.Close()
 
+	// 日志写入失败（如数据库短暂断连）不影响已拿到的上游响应，仅跳过用量记录
+	var src io.Reader = res.Body
+	var pw *io.PipeWriter
 	logId, err := service.SaveChatLog(ctx, *log)
 	if err != nil {
-		common.InternalServerError(c, err.Error())
-		return
+		slog.Error("save chat log error, skip usage recording", "model", before.Model, "provider", log.ProviderName, "error", err)
+	} else {
+		var pr *io.PipeReader
+		pr, pw = io.Pipe()
+		src = io.TeeReader(res.Body, pw)
+		// 异步处理输出并记录 tokens
+		// 切换到备用模型时按备用模型记录与计费
+		provider, _ := lo.Find(lo.Values(usedMeta.ProviderMap), func(p models.Provider) bool { return p.Name == log.ProviderName })
+		accessLog := service.AccessLogFromContext(ctx)
+		accessLog.Track(logId)
+		recordCtx := service.WithAccessLog(service.WithTimeline(context.Background(), service.TimelineFromContext(ctx)), accessLog)
+		go service.RecordLog(recordCtx, startReq, pr, postProcessor, logId, usedMeta.Before(*before), usedMeta.IOLog, provider, log.AuthKeyID)
 	}
 
-	pr, pw := io.Pipe()
-	tee := io.TeeReader(res.Body, pw)
-	// 异步处理输出并记录 tokens
-	go service.RecordLog(context.Background(), startReq, pr, postProcessor, logId, *before, providersWithMeta.IOLog)
+	if collect {
+		writeCollected(c, src, pw, idem, res.Header)
+		service.DispatchShadow(ctx, logStyle, usedMeta.Before(*before), usedMeta, reqMeta, postProcessor)
+		return
+	}
 
 	writeHeader(c, before.Stream, res.Header)
-	if _, err := io.Copy(c.Writer, tee); err != nil {
-		pw.CloseWithError(err)
+	service.RecordTimeline(ctx, "response_started", map[string]any{"log_id": logId})
+	var dst io.Writer = c.Writer
+	if before.Stream {
+		// 流式响应逐块 Flush，避免 net/http 的写缓冲把 SSE 事件攒到响应结束才下发
+		dst = flushWriter{ResponseWriter: c.Writer}
+	}
+	if idem != nil {
+		dst = io.MultiWriter(dst, idem)
+	}
+	if cacheWriter != nil {
+		dst = io.MultiWriter(dst, cacheWriter)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		if pw != nil {
+			pw.CloseWithError(err)
+		}
 		slog.Error("io copy", "err:", err)
 		return
 	}
 
-	pw.Close()
+	if pw != nil {
+		pw.Close()
+	}
+	if idem != nil {
+		idem.complete(context.WithoutCancel(ctx), res.Header)
+	}
+	if cacheWriter != nil {
+		cacheWriter.save(context.WithoutCancel(ctx), res.StatusCode, res.Header, log)
+	}
+
+	// 正式响应已返回，按采样率异步复制请求到实际服务本次请求的模型（含备用模型）的影子提供商
+	service.DispatchShadow(ctx, logStyle, usedMeta.Before(*before), usedMeta, reqMeta, postProcessor)
+}
+
+func isCollectMode(c *gin.Context) bool {
+	collect, _ := strconv.ParseBool(c.Query("collect"))
+	return collect
+}
+
+// writeCollected 读完上游 SSE 响应后聚合为单个 JSON 返回；日志记录仍按流式解析原始响应
+func writeCollected(c *gin.Context, src io.Reader, pw *io.PipeWriter, idem *idempotentRequest, upstreamHeader http.Header) {
+	ctx := c.Request.Context()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, src); err != nil {
+		if pw != nil {
+			pw.CloseWithError(err)
+		}
+		slog.Error("io copy", "err:", err)
+		// 尚未向客户端写入任何内容，返回明确的错误而不是空响应
+		if errors.Is(err, service.ErrStreamReadTimeout) || errors.Is(err, service.ErrStreamDeadline) || errors.Is(err, context.DeadlineExceeded) {
+			common.ErrorWithCode(c, http.StatusGatewayTimeout, http.StatusGatewayTimeout, common.ErrCodeUpstreamTimeout, err.Error())
+			return
+		}
+		common.ErrorWithCode(c, http.StatusBadGateway, http.StatusBadGateway, common.ErrCodeUpstreamError, err.Error())
+		return
+	}
+	if pw != nil {
+		pw.Close()
+	}
+
+	body, err := service.CollectOpenAIStream(ctx, &buf)
+	if err != nil {
+		common.ErrorWithCode(c, http.StatusBadGateway, http.StatusBadGateway, common.ErrCodeUpstreamError, err.Error())
+		return
+	}
+	header := upstreamHeader.Clone()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json")
+	writeHeader(c, false, header)
+	service.RecordTimeline(ctx, "response_started", nil)
+	if _, err := c.Writer.Write(body); err != nil {
+		slog.Error("write collected response", "err:", err)
+		return
+	}
+	if idem != nil {
+		if _, err := idem.Write(body); err == nil {
+			idem.complete(context.WithoutCancel(ctx), header)
+		}
+	}
 }
 
 func writeHeader(c *gin.Context, stream bool, header http.Header) {
@@ -163,6 +359,17 @@ func writeHeader(c *gin.Context, stream bool, header http.Header) {
 	c.Writer.Flush()
 }
 
+// flushWriter 每次写入后立即 Flush 到客户端
+type flushWriter struct {
+	gin.ResponseWriter
+}
+
+func (w flushWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.ResponseWriter.Flush()
+	return n, err
+}
+
 func formatHeadersJSON(header http.Header) string {
 	content, err := json.MarshalIndent(header, "", "  ")
 	if err != nil {
@@ -0,0 +1,431 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/limiter"
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/service"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// errAfterReader 先返回 data，再返回 err
+type errAfterReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *errAfterReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestWriteCollectedCopyError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		wantCode common.ErrorCode
+	}{
+		{"read timeout", service.ErrStreamReadTimeout, http.StatusGatewayTimeout, common.ErrCodeUpstreamTimeout},
+		{"stream deadline", service.ErrStreamDeadline, http.StatusGatewayTimeout, common.ErrCodeUpstreamTimeout},
+		{"connection reset", errors.New("connection reset by peer"), http.StatusBadGateway, common.ErrCodeUpstreamError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions?collect=true", nil)
+
+			src := &errAfterReader{data: strings.NewReader("data: {\"choices\":[]}\n\n"), err: tt.err}
+			writeCollected(c, src, nil, nil, http.Header{})
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if body := w.Body.String(); !strings.Contains(body, string(tt.wantCode)) {
+				t.Fatalf("body = %s, want error code %s", body, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWriteCollectedAggregates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions?collect=true", nil)
+
+	stream := "data: {\"id\":\"c\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n" +
+		"data: {\"id\":\"c\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" there\"},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: [DONE]\n\n"
+	header := http.Header{"Content-Type": {"text/event-stream"}, "Content-Length": {"123"}}
+	writeCollected(c, strings.NewReader(stream), nil, nil, header)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("content-type = %q, want application/json", ct)
+	}
+	if !strings.Contains(w.Body.String(), `"content":"Hi there"`) {
+		t.Fatalf("body = %s", w.Body.String())
+	}
+}
+
+func TestFlushWriterStreamsEventsImmediately(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	upstreamR, upstreamW := io.Pipe()
+
+	r := gin.New()
+	r.GET("/stream", func(c *gin.Context) {
+		writeHeader(c, true, http.Header{})
+		_, _ = io.Copy(flushWriter{ResponseWriter: c.Writer}, upstreamR)
+	})
+	srv := httptest.NewServer(r)
+	// 先结束上游流，处理器返回后服务器才能关闭
+	defer srv.Close()
+	defer upstreamW.Close()
+
+	resp, err := http.Get(srv.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	readEvent := func() string {
+		t.Helper()
+		lines := make(chan string, 1)
+		go func() {
+			line, _ := reader.ReadString('\n')
+			lines <- line
+		}()
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(2 * time.Second):
+			t.Fatal("event not delivered to client before the next upstream write")
+			return ""
+		}
+	}
+
+	// message_stop 尚未写入时，客户端必须已经收到 message_start
+	if _, err := upstreamW.Write([]byte("event: message_start\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readEvent(); got != "event: message_start\n" {
+		t.Fatalf("first event = %q", got)
+	}
+	if _, err := upstreamW.Write([]byte("event: message_stop\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readEvent(); got != "event: message_stop\n" {
+		t.Fatalf("second event = %q", got)
+	}
+}
+
+// unreachableDB 将 models.DB 替换为指向无监听端口的连接，使所有查询以连接错误失败
+func unreachableDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 connect_timeout=1"), &gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = orig })
+}
+
+func TestChatCompletionsPreStreamError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	unreachableDB(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		accept string
+		sse    bool
+	}{
+		{"stream body", `{"model":"m","stream":true,"messages":[]}`, "", true},
+		{"accept header", `{"model":"m","messages":[]}`, "text/event-stream", true},
+		{"non stream", `{"model":"m","messages":[]}`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions", strings.NewReader(tt.body))
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+
+			// 读取模型策略配置失败，发生在开始转发之前
+			ChatCompletionsHandler(c)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusInternalServerError, w.Body.String())
+			}
+			ct := w.Header().Get("Content-Type")
+			body := w.Body.String()
+			if tt.sse {
+				if ct != "text/event-stream" || !strings.HasPrefix(body, "event: error\ndata: {") || !strings.HasSuffix(body, "}\n\n") {
+					t.Fatalf("content-type %q body %q, want a single SSE error event", ct, body)
+				}
+				return
+			}
+			if !strings.HasPrefix(ct, "application/json") || !strings.HasPrefix(body, "{") {
+				t.Fatalf("content-type %q body %q, want JSON", ct, body)
+			}
+		})
+	}
+}
+
+// dryRunModelsDB 将 models.DB 替换为不执行 SQL 的会话：查询均返回空结果，写入不生效
+func dryRunModelsDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1"), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = orig })
+}
+
+func TestChatCompletionsKeyConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dryRunModelsDB(t)
+	manager := limiter.NewManager(nil)
+	service.SetLimiterManager(manager)
+	// 结束后换回空的内存限流器，避免计数影响其它用例
+	t.Cleanup(func() { service.SetLimiterManager(limiter.NewManager(nil)) })
+
+	const keyID = 42
+	// 模拟该 Key 已有一个在途请求
+	if ok, err := manager.AcquireKeyConcurrency(context.Background(), keyID, 1); err != nil || !ok {
+		t.Fatalf("pre-acquire = %v, %v", ok, err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	ctx := context.WithValue(context.Background(), consts.ContextKeyAllowAllModel, true)
+	ctx = context.WithValue(ctx, consts.ContextKeyAuthKeyID, uint(keyID))
+	ctx = context.WithValue(ctx, consts.ContextKeyMaxConcurrency, 1)
+	c.Request = httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions", strings.NewReader(`{"model":"m","messages":[]}`)).WithContext(ctx)
+
+	ChatCompletionsHandler(c)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusTooManyRequests, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), string(common.ErrCodeConcurrencyLimited)) {
+		t.Fatalf("body %s missing %s", w.Body.String(), common.ErrCodeConcurrencyLimited)
+	}
+	// 被拒绝的请求不占用名额
+	if n, _ := manager.GetKeyConcurrency(context.Background(), keyID); n != 1 {
+		t.Fatalf("concurrency = %d, want 1", n)
+	}
+}
+
+func TestMessagesStreamFlushesEachEvent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 上游写完第一个事件后等待客户端收到，再写下一个
+	delivered := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: message_start\ndata: {\"type\":\"message_start\"}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-delivered:
+		case <-time.After(2 * time.Second):
+		}
+		io.WriteString(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer upstream.Close()
+
+	model := models.Model{Name: "claude", Status: 1, MaxRetry: 1, TimeOut: 10}
+	model.ID = 1
+	provider := models.Provider{Name: "claude", Type: consts.StyleAnthropic, Config: `{"base_url":"` + upstream.URL + `/v1","api_key":"k"}`}
+	provider.ID = 2
+	mp := models.ModelWithProvider{ModelID: 1, ProviderID: 2, ProviderModel: "claude-sonnet", Status: 1, Weight: 1}
+	mp.ID = 3
+	stubRecordsDB(t, map[string]any{
+		"models":               []models.Model{model},
+		"providers":            []models.Provider{provider},
+		"model_with_providers": []models.ModelWithProvider{mp},
+	})
+	// 日志写入失败时跳过用量记录，避免后台记录在用例结束后访问 models.DB
+	err := models.DB.Callback().Create().Before("gorm:create").Register("test:fail", func(tx *gorm.DB) {
+		_ = tx.AddError(errors.New("log storage unavailable"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), consts.ContextKeyAllowAllModel, true)
+		c.Request = c.Request.WithContext(ctx)
+		Messages(c)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/messages", "application/json", strings.NewReader(`{"model":"claude","stream":true,"max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status = %d, body %s", resp.StatusCode, body)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	readLine := func() string {
+		t.Helper()
+		lines := make(chan string, 1)
+		go func() {
+			line, _ := reader.ReadString('\n')
+			lines <- line
+		}()
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(time.Second):
+			t.Fatal("event not delivered to client before the next upstream write")
+			return ""
+		}
+	}
+
+	// message_stop 尚未写入时，客户端必须已经收到 message_start
+	if got := readLine(); got != "event: message_start\n" {
+		t.Fatalf("first line = %q", got)
+	}
+	close(delivered)
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rest), "event: message_stop\n") {
+		t.Fatalf("rest = %q, want message_stop", rest)
+	}
+}
+
+func TestChatCompletionsShadowFollowsFallbackModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newUpstream := func(status int) (*httptest.Server, *atomic.Int64) {
+		hits := new(atomic.Int64)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv, hits
+	}
+	failing, _ := newUpstream(http.StatusInternalServerError)
+	backup, backupHits := newUpstream(http.StatusOK)
+	backupShadow, backupShadowHits := newUpstream(http.StatusOK)
+	primaryShadow, primaryShadowHits := newUpstream(http.StatusOK)
+
+	model := func(id uint, name, fallback string) models.Model {
+		m := models.Model{Name: name, Status: 1, MaxRetry: 1, TimeOut: 10, FallbackModel: fallback}
+		m.ID = id
+		return m
+	}
+	provider := func(id uint, srv *httptest.Server) models.Provider {
+		p := models.Provider{Name: fmt.Sprintf("p%d", id), Type: consts.StyleOpenAI, Config: `{"base_url":"` + srv.URL + `/v1","api_key":"k"}`}
+		p.ID = id
+		return p
+	}
+	association := func(id, modelID, providerID uint, shadow bool) models.ModelWithProvider {
+		mp := models.ModelWithProvider{ModelID: modelID, ProviderID: providerID, ProviderModel: "m", Status: 1, Weight: 1}
+		if shadow {
+			mp.Shadow, mp.ShadowRate, mp.Weight = 1, 1, 0
+		}
+		mp.ID = id
+		return mp
+	}
+	writes := stubRecordsDB(t, map[string]any{
+		"models":    []models.Model{model(1, "primary", "backup"), model(2, "backup", "")},
+		"providers": []models.Provider{provider(20, failing), provider(21, backup), provider(22, backupShadow), provider(23, primaryShadow)},
+		"model_with_providers": []models.ModelWithProvider{
+			association(10, 1, 20, false),
+			association(13, 1, 23, true),
+			association(11, 2, 21, false),
+			association(12, 2, 22, true),
+		},
+	})
+	// 日志写入失败时跳过用量记录，避免后台记录在用例结束后访问 models.DB
+	err := models.DB.Callback().Create().After("test:capture").Register("test:fail", func(tx *gorm.DB) {
+		if tx.Statement.Table == "chat_logs" {
+			_ = tx.AddError(errors.New("log storage unavailable"))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	ctx := context.WithValue(context.Background(), consts.ContextKeyAllowAllModel, true)
+	c.Request = httptest.NewRequest(http.MethodPost, "/openai/v1/chat/completions", strings.NewReader(`{"model":"primary","messages":[{"role":"user","content":"hi"}]}`)).WithContext(ctx)
+
+	ChatCompletionsHandler(c)
+
+	if w.Code != http.StatusOK || backupHits.Load() != 1 {
+		t.Fatalf("status = %d, backup hits = %d; body %s", w.Code, backupHits.Load(), w.Body.String())
+	}
+
+	inserts := func() (shadowLogs []string, chatLogs int) {
+		for _, sql := range writes() {
+			switch {
+			case strings.HasPrefix(sql, `INSERT INTO "shadow_logs"`):
+				shadowLogs = append(shadowLogs, sql)
+			case strings.HasPrefix(sql, `INSERT INTO "chat_logs"`):
+				chatLogs++
+			}
+		}
+		return shadowLogs, chatLogs
+	}
+	// 等待影子记录与日志写完（主模型的尝试记录与汇总记录 + 正式日志），避免在 models.DB 还原后写入
+	deadline := time.Now().Add(2 * time.Second)
+	shadowLogs, chatLogs := inserts()
+	for len(shadowLogs) < 1 || chatLogs < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out: %d shadow logs, %d chat logs", len(shadowLogs), chatLogs)
+		}
+		time.Sleep(10 * time.Millisecond)
+		shadowLogs, chatLogs = inserts()
+	}
+
+	if backupShadowHits.Load() != 1 || primaryShadowHits.Load() != 0 {
+		t.Fatalf("backup shadow hits = %d, primary shadow hits = %d; want 1 and 0", backupShadowHits.Load(), primaryShadowHits.Load())
+	}
+	// 影子记录按实际服务的备用模型记录
+	if len(shadowLogs) != 1 || !strings.Contains(shadowLogs[0], "'backup'") || !strings.Contains(shadowLogs[0], "'p22'") {
+		t.Fatalf("shadow logs = %v", shadowLogs)
+	}
+}
@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/service"
+	"github.com/samber/lo"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"gorm.io/gorm"
+)
+
+// providerSecretFields 提供商配置中的凭据字段，导出时清空
+var providerSecretFields = []string{"api_key", "access_key", "secret_key", "session_token"}
+
+// ConfigBundle 提供商、模型与模型提供商关联的导入导出格式，关联通过名称引用模型与提供商
+type ConfigBundle struct {
+	Providers    []ProviderRequest   `json:"providers"`
+	Models       []BundleModel       `json:"models"`
+	Associations []BundleAssociation `json:"associations"`
+}
+
+// BundleModel 模型配置，status 未传入时默认启用
+type BundleModel struct {
+	ModelRequest
+	Status *bool `json:"status"`
+}
+
+// BundleAssociation 模型提供商关联，model/provider 为模型与提供商名称，status 未传入时默认启用
+type BundleAssociation struct {
+	Model            string                `json:"model"`
+	Provider         string                `json:"provider"`
+	ProviderModel    string                `json:"provider_model"`
+	ToolCall         bool                  `json:"tool_call"`
+	StructuredOutput bool                  `json:"structured_output"`
+	Image            bool                  `json:"image"`
+	WithHeader       bool                  `json:"with_header"`
+	CustomerHeaders  map[string]string     `json:"customer_headers"`
+	Weight           int                   `json:"weight"`
+	Shadow           bool                  `json:"shadow"`
+	ShadowRate       float64               `json:"shadow_rate"`
+	RpmLimit         int                   `json:"rpm_limit"`
+	ExcludeRules     []service.ExcludeRule `json:"exclude_rules"`
+	Status           *bool                 `json:"status"`
+}
+
+// ImportResult 单类资源的导入结果，已存在的同名资源（关联为同一模型、提供商与提供商模型）跳过
+type ImportResult struct {
+	Created []string `json:"created"`
+	Skipped []string `json:"skipped"`
+}
+
+// ImportSummary 导入结果汇总
+type ImportSummary struct {
+	Providers    ImportResult `json:"providers"`
+	Models       ImportResult `json:"models"`
+	Associations ImportResult `json:"associations"`
+}
+
+func (a BundleAssociation) label() string {
+	return fmt.Sprintf("%s -> %s/%s", a.Model, a.Provider, a.ProviderModel)
+}
+
+// ImportConfig 批量导入提供商、模型与关联，任一条目失败时整体回滚
+func ImportConfig(c *gin.Context) {
+	var bundle ConfigBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		common.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	summary := ImportSummary{
+		Providers:    ImportResult{Created: []string{}, Skipped: []string{}},
+		Models:       ImportResult{Created: []string{}, Skipped: []string{}},
+		Associations: ImportResult{Created: []string{}, Skipped: []string{}},
+	}
+	ctx := c.Request.Context()
+	err := models.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, req := range bundle.Providers {
+			provider, err := providerFromRequest(req)
+			if err != nil {
+				return common.NewError(common.ErrCodeBadRequest, fmt.Sprintf("provider %s: %v", req.Name, err))
+			}
+			count, err := gorm.G[models.Provider](tx).Where("name = ?", req.Name).Count(ctx, "id")
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				summary.Providers.Skipped = append(summary.Providers.Skipped, req.Name)
+				continue
+			}
+			if err := service.CheckResourceLimitTx(ctx, tx, service.ResourceProviders); err != nil {
+				return err
+			}
+			if err := gorm.G[models.Provider](tx).Create(ctx, &provider); err != nil {
+				return err
+			}
+			summary.Providers.Created = append(summary.Providers.Created, req.Name)
+		}
+
+		for _, req := range bundle.Models {
+			model, err := modelFromRequest(req.ModelRequest)
+			if err != nil {
+				return common.NewError(common.ErrCodeBadRequest, fmt.Sprintf("model %s: %v", req.Name, err))
+			}
+			model.Status = lo.Ternary(lo.FromPtrOr(req.Status, true), 1, 0)
+			count, err := gorm.G[models.Model](tx).Where("name = ?", req.Name).Count(ctx, "id")
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				summary.Models.Skipped = append(summary.Models.Skipped, req.Name)
+				continue
+			}
+			if err := service.CheckResourceLimitTx(ctx, tx, service.ResourceModels); err != nil {
+				return err
+			}
+			if err := gorm.G[models.Model](tx).Create(ctx, &model); err != nil {
+				return err
+			}
+			summary.Models.Created = append(summary.Models.Created, req.Name)
+		}
+
+		for _, assoc := range bundle.Associations {
+			model, err := gorm.G[models.Model](tx).Where("name = ?", assoc.Model).First(ctx)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return common.NewError(common.ErrCodeBadRequest, fmt.Sprintf("association %s: model not found", assoc.label()))
+				}
+				return err
+			}
+			provider, err := gorm.G[models.Provider](tx).Where("name = ?", assoc.Provider).First(ctx)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return common.NewError(common.ErrCodeBadRequest, fmt.Sprintf("association %s: provider not found", assoc.label()))
+				}
+				return err
+			}
+			modelProvider, err := modelProviderFromRequest(ModelWithProviderRequest{
+				ModelID:          model.ID,
+				ProviderModel:    assoc.ProviderModel,
+				ProviderID:       provider.ID,
+				ToolCall:         assoc.ToolCall,
+				StructuredOutput: assoc.StructuredOutput,
+				Image:            assoc.Image,
+				WithHeader:       assoc.WithHeader,
+				CustomerHeaders:  assoc.CustomerHeaders,
+				Weight:           assoc.Weight,
+				Shadow:           assoc.Shadow,
+				ShadowRate:       assoc.ShadowRate,
+				RpmLimit:         assoc.RpmLimit,
+				ExcludeRules:     assoc.ExcludeRules,
+			})
+			if err != nil {
+				return common.NewError(common.ErrCodeBadRequest, fmt.Sprintf("association %s: %v", assoc.label(), err))
+			}
+			modelProvider.Status = lo.Ternary(lo.FromPtrOr(assoc.Status, true), 1, 0)
+			count, err := gorm.G[models.ModelWithProvider](tx).
+				Where("model_id = ? AND provider_id = ? AND provider_model = ?", model.ID, provider.ID, assoc.ProviderModel).
+				Count(ctx, "id")
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				summary.Associations.Skipped = append(summary.Associations.Skipped, assoc.label())
+				continue
+			}
+			if err := gorm.G[models.ModelWithProvider](tx).Create(ctx, &modelProvider); err != nil {
+				return err
+			}
+			summary.Associations.Created = append(summary.Associations.Created, assoc.label())
+		}
+		return nil
+	})
+	if err != nil {
+		switch common.ErrorCodeOf(err) {
+		case common.ErrCodeBadRequest:
+			common.BadRequest(c, err.Error())
+		case common.ErrCodeResourceLimit:
+			respondResourceLimit(c, err)
+		default:
+			common.InternalServerError(c, "Failed to import: "+err.Error())
+		}
+		return
+	}
+
+	common.Success(c, summary)
+}
+
+// ExportConfig 导出全部提供商、模型与关联，格式与导入一致，提供商凭据已清空
+func ExportConfig(c *gin.Context) {
+	ctx := c.Request.Context()
+	providerList, err := gorm.G[models.Provider](models.DB).Order("id").Find(ctx)
+	if err != nil {
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	modelList, err := gorm.G[models.Model](models.DB).Order("id").Find(ctx)
+	if err != nil {
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	associations, err := gorm.G[models.ModelWithProvider](models.DB).Order("id").Find(ctx)
+	if err != nil {
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+
+	bundle := ConfigBundle{
+		Providers:    make([]ProviderRequest, 0, len(providerList)),
+		Models:       make([]BundleModel, 0, len(modelList)),
+		Associations: make([]BundleAssociation, 0, len(associations)),
+	}
+	providerNames := make(map[uint]string, len(providerList))
+	for _, provider := range providerList {
+		providerNames[provider.ID] = provider.Name
+		bundle.Providers = append(bundle.Providers, ProviderRequest{
+			Name:               provider.Name,
+			Type:               provider.Type,
+			Config:             redactProviderConfig(provider.Config),
+			Console:            provider.Console,
+			RpmLimit:           provider.RpmLimit,
+			TpmLimit:           provider.TpmLimit,
+			IpLockMinutes:      provider.IpLockMinutes,
+			KeepWarm:           provider.KeepWarm == 1,
+			MaxConcurrency:     provider.MaxConcurrency,
+			SuccessStatusCodes: provider.SuccessStatusCodes,
+		})
+	}
+	modelNames := make(map[uint]string, len(modelList))
+	for _, model := range modelList {
+		modelNames[model.ID] = model.Name
+		bundle.Models = append(bundle.Models, BundleModel{
+			ModelRequest: ModelRequest{
+				Name:                    model.Name,
+				Remark:                  model.Remark,
+				MaxRetry:                model.MaxRetry,
+				TimeOut:                 model.TimeOut,
+				IOLog:                   model.IOLog == 1,
+				Strategy:                model.Strategy,
+				Breaker:                 model.Breaker == 1,
+				MaxInputTokens:          lo.ToPtr(model.MaxInputTokens),
+				TokenLockSeconds:        lo.ToPtr(model.TokenLockSeconds),
+				MaxProvidersPerRequest:  lo.ToPtr(model.MaxProvidersPerRequest),
+				AutoWeight:              lo.ToPtr(model.AutoWeight == 1),
+				CacheTTLSeconds:         lo.ToPtr(model.CacheTTLSeconds),
+				MinWeight:               lo.ToPtr(model.MinWeight),
+				BreakerMaxFailures:      lo.ToPtr(model.BreakerMaxFailures),
+				BreakerSleepSeconds:     lo.ToPtr(model.BreakerSleepSeconds),
+				BreakerHalfOpenRequests: lo.ToPtr(model.BreakerHalfOpenRequests),
+				FallbackModel:           lo.ToPtr(model.FallbackModel),
+				StreamMaxSeconds:        lo.ToPtr(model.StreamMaxSeconds),
+				MaxThinkingTokens:       lo.ToPtr(model.MaxThinkingTokens),
+				MaxReasoningEffort:      lo.ToPtr(model.MaxReasoningEffort),
+				MaxCompletionTokens:     lo.ToPtr(model.MaxCompletionTokens),
+				EchoModel:               lo.ToPtr(model.EchoModel == 1),
+			},
+			Status: lo.ToPtr(model.Status == 1),
+		})
+	}
+	for _, assoc := range associations {
+		modelName, modelOK := modelNames[assoc.ModelID]
+		providerName, providerOK := providerNames[assoc.ProviderID]
+		// 跳过引用已删除模型或提供商的关联
+		if !modelOK || !providerOK {
+			continue
+		}
+		var headers map[string]string
+		if assoc.CustomerHeaders != "" {
+			_ = json.Unmarshal([]byte(assoc.CustomerHeaders), &headers)
+		}
+		// 规则保存前已校验，解析失败（如直接修改数据库）时不导出规则
+		excludeRules, _ := service.ParseExcludeRules(assoc.ExcludeRules)
+		// 导出人工配置的权重而非自动调权后的当前权重，旧数据没有 BaseWeight 时以当前权重为准
+		weight := cmp.Or(assoc.BaseWeight, assoc.Weight)
+		bundle.Associations = append(bundle.Associations, BundleAssociation{
+			Model:            modelName,
+			Provider:         providerName,
+			ProviderModel:    assoc.ProviderModel,
+			ToolCall:         assoc.ToolCall == 1,
+			StructuredOutput: assoc.StructuredOutput == 1,
+			Image:            assoc.Image == 1,
+			WithHeader:       assoc.WithHeader == 1,
+			CustomerHeaders:  headers,
+			Weight:           weight,
+			Shadow:           assoc.Shadow == 1,
+			ShadowRate:       assoc.ShadowRate,
+			RpmLimit:         assoc.RpmLimit,
+			ExcludeRules:     excludeRules,
+			Status:           lo.ToPtr(assoc.Status == 1),
+		})
+	}
+
+	common.Success(c, bundle)
+}
+
+// redactProviderConfig 清空提供商配置中的凭据字段，非 JSON 配置原样返回
+func redactProviderConfig(config string) string {
+	if !gjson.Valid(config) {
+		return config
+	}
+	for _, field := range providerSecretFields {
+		if gjson.Get(config, field).String() == "" {
+			continue
+		}
+		if redacted, err := sjson.Set(config, field, ""); err == nil {
+			config = redacted
+		}
+	}
+	return config
+}
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/racio/llmio/common"
 	"github.com/racio/llmio/models"
 	"github.com/racio/llmio/providers"
+	"github.com/racio/llmio/service"
 	"gorm.io/gorm"
 )
 
@@ -39,7 +42,15 @@ func CountTokens(c *gin.Context) {
 		Version: anthropicConfig.Version,
 	}
 
-	req, err := anthropic.BuildCountTokensReq(ctx, c.Request.Header, c.Request.Body)
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		common.InternalServerError(c, "Failed to read request body: "+err.Error())
+		return
+	}
+	// 网关模型名改写为上游模型名，避免上游因未知模型返回 404
+	body = service.ResolveCountTokensBody(ctx, body)
+
+	req, err := anthropic.BuildCountTokensReq(ctx, c.Request.Header, bytes.NewReader(body))
 	if err != nil {
 		common.InternalServerError(c, "Failed to create request: "+err.Error())
 		return
@@ -47,11 +58,23 @@ func CountTokens(c *gin.Context) {
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
+		if service.CountTokensFallbackEnabled(ctx) {
+			slog.Warn("count tokens upstream unreachable, using local estimation", "error", err)
+			estimateCountTokens(c, body)
+			return
+		}
 		common.InternalServerError(c, "Failed to send request: "+err.Error())
 		return
 	}
 	defer res.Body.Close()
 
+	// 上游未实现 count_tokens 端点时本地估算
+	if res.StatusCode == http.StatusNotFound && service.CountTokensFallbackEnabled(ctx) {
+		slog.Warn("count tokens upstream returned 404, using local estimation")
+		estimateCountTokens(c, body)
+		return
+	}
+
 	c.Status(res.StatusCode)
 
 	for k, values := range res.Header {
@@ -67,6 +90,14 @@ func CountTokens(c *gin.Context) {
 	}
 }
 
+// estimateCountTokens 返回本地估算的输入 token 数，estimated 标记结果非上游精确统计
+func estimateCountTokens(c *gin.Context, body []byte) {
+	c.JSON(http.StatusOK, CountTokensResponse{
+		InputTokens: service.EstimateInputTokens(body),
+		Estimated:   true,
+	})
+}
+
 const testBody = `{
     	"model": "claude-sonnet-4-5",
     	"messages": [
@@ -80,6 +111,7 @@ const testBody = `{
 
 type CountTokensResponse struct {
 	InputTokens int64 `json:"input_tokens"`
+	Estimated   bool  `json:"estimated,omitempty"`
 }
 
 func TestCountTokens(c *gin.Context) {
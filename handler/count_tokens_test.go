@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/service"
+	"github.com/tidwall/gjson"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/logger"
+)
+
+// configDB 将 models.DB 替换为不执行 SQL 的会话，configs 表按 key 返回 values 中的值，其余查询均为空结果
+func configDB(t *testing.T, values map[string]string) {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1"), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Callback().Query().Replace("gorm:query", func(tx *gorm.DB) {
+		callbacks.BuildQuerySQL(tx)
+		if config, ok := tx.Statement.Dest.(*models.Config); ok && tx.Statement.Table == "configs" {
+			for _, v := range tx.Statement.Vars {
+				key, _ := v.(string)
+				if value, found := values[key]; found {
+					config.Key, config.Value = key, value
+					tx.RowsAffected = 1
+					return
+				}
+			}
+		}
+		if tx.Statement.RaiseErrorOnNotFound {
+			tx.AddError(gorm.ErrRecordNotFound)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := models.DB
+	models.DB = db
+	// 估算开关经配置缓存读取，前后都清掉避免用例互相影响
+	service.InvalidateConfigCache(models.KeyCountTokensFallback)
+	t.Cleanup(func() {
+		models.DB = prev
+		service.InvalidateConfigCache(models.KeyCountTokensFallback)
+	})
+}
+
+func doCountTokens(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages/count_tokens", strings.NewReader(testBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+	CountTokens(c)
+	return w
+}
+
+func countTokensConfig(baseURL string) string {
+	return `{"base_url":"` + baseURL + `","api_key":"k","version":"2023-06-01"}`
+}
+
+func TestCountTokensUpstreamSuccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"input_tokens":42}`))
+	}))
+	defer srv.Close()
+	configDB(t, map[string]string{models.KeyAnthropicCountTokens: countTokensConfig(srv.URL + "/v1")})
+
+	w := doCountTokens(t)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if !strings.HasSuffix(path, "/messages/count_tokens") {
+		t.Fatalf("upstream path = %s", path)
+	}
+	body := w.Body.Bytes()
+	if gjson.GetBytes(body, "input_tokens").Int() != 42 || gjson.GetBytes(body, "estimated").Exists() {
+		t.Fatalf("body = %s, want upstream result", body)
+	}
+}
+
+func TestCountTokensFallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		baseURL string
+	}{
+		{"upstream 404", notFound.URL + "/v1"},
+		{"connection error", closed.URL + "/v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configDB(t, map[string]string{models.KeyAnthropicCountTokens: countTokensConfig(tt.baseURL)})
+
+			w := doCountTokens(t)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			body := w.Body.Bytes()
+			if !gjson.GetBytes(body, "estimated").Bool() {
+				t.Fatalf("body = %s, want estimated result", body)
+			}
+			if got, want := gjson.GetBytes(body, "input_tokens").Int(), service.EstimateInputTokens([]byte(testBody)); got != want || got == 0 {
+				t.Fatalf("input_tokens = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestCountTokensFallbackDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	configDB(t, map[string]string{
+		models.KeyAnthropicCountTokens: countTokensConfig(srv.URL + "/v1"),
+		models.KeyCountTokensFallback:  `{"enabled":false}`,
+	})
+
+	w := doCountTokens(t)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want upstream 404 passed through", w.Code)
+	}
+	if gjson.GetBytes(w.Body.Bytes(), "estimated").Exists() {
+		t.Fatalf("body = %s, want no estimation", w.Body)
+	}
+}
@@ -3,12 +3,14 @@ package handler
 import (
 	"context"
 	"log/slog"
-	"slices"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/limiter"
 	"github.com/racio/llmio/models"
 	"github.com/racio/llmio/service"
 )
@@ -20,41 +22,6 @@ type ComponentStatus struct {
 	ResponseTimeMs *int    `json:"responseTimeMs,omitempty"`
 }
 
-// ModelHealthRequestBlock 模型健康请求块
-type ModelHealthRequestBlock struct {
-	Success   bool   `json:"success"`
-	Timestamp string `json:"timestamp"`
-}
-
-// ModelHealth 模型健康状态
-type ModelHealth struct {
-	ModelName         string                    `json:"modelName"`
-	ProviderModel     string                    `json:"providerModel"`
-	Status            string                    `json:"status"`
-	TotalRequests     int                       `json:"totalRequests"`
-	FailedRequests    int                       `json:"failedRequests"`
-	SuccessRate       float64                   `json:"successRate"`
-	AvgResponseTimeMs float64                   `json:"avgResponseTimeMs"`
-	LastCheck         string                    `json:"lastCheck"`
-	LastError         *string                   `json:"lastError,omitempty"`
-	RequestBlocks     []ModelHealthRequestBlock `json:"requestBlocks"`
-}
-
-// ProviderHealth 提供商健康状态
-type ProviderHealth struct {
-	ID             int           `json:"id"`
-	Name           string        `json:"name"`
-	Type           string        `json:"type"`
-	Status         string        `json:"status"`
-	LastCheck      string        `json:"lastCheck"`
-	ResponseTimeMs int           `json:"responseTimeMs"`
-	ErrorRate      float64       `json:"errorRate"`
-	TotalRequests  int           `json:"totalRequests"`
-	FailedRequests int           `json:"failedRequests"`
-	LastError      *string       `json:"lastError,omitempty"`
-	Models         []ModelHealth `json:"models"`
-}
-
 // SystemHealth 系统健康状态
 type SystemHealth struct {
 	Status          string `json:"status"`
@@ -63,16 +30,9 @@ type SystemHealth struct {
 	ProcessUptime   int    `json:"processUptime"`
 	FirstDeployTime string `json:"firstDeployTime"`
 	Components      struct {
-		Database  ComponentStatus `json:"database"`
-		Redis     ComponentStatus `json:"redis"`
-		Providers struct {
-			Status    string           `json:"status"`
-			Total     int              `json:"total"`
-			Healthy   int              `json:"healthy"`
-			Degraded  int              `json:"degraded"`
-			Unhealthy int              `json:"unhealthy"`
-			Details   []ProviderHealth `json:"details"`
-		} `json:"providers"`
+		Database  ComponentStatus         `json:"database"`
+		Redis     ComponentStatus         `json:"redis"`
+		Providers service.ProvidersHealth `json:"providers"`
 	} `json:"components"`
 }
 
@@ -133,6 +93,7 @@ func ReadinessCheck(c *gin.Context) {
 		if err := models.DB.Raw(tableCheckSQL).Scan(&count).Error; err != nil {
 			ready["status"] = "not_ready"
 			ready["database"] = "table_check_failed"
+			ready["failed"] = "database"
 			ready["error"] = err.Error()
 			c.JSON(503, ready)
 			return
@@ -141,6 +102,7 @@ func ReadinessCheck(c *gin.Context) {
 		if count < 3 {
 			ready["status"] = "not_ready"
 			ready["database"] = "missing_tables"
+			ready["failed"] = "database"
 			ready["error"] = "Required tables not found"
 			c.JSON(503, ready)
 			return
@@ -150,13 +112,43 @@ func ReadinessCheck(c *gin.Context) {
 	} else {
 		ready["status"] = "not_ready"
 		ready["database"] = "not_initialized"
+		ready["failed"] = "database"
 		c.JSON(503, ready)
 		return
 	}
 
+	// 可选的额外检查：通过环境变量开启，最小化部署默认不受影响
+	// READINESS_REQUIRE_MIGRATIONS=true：要求启动数据修复已完成
+	// READINESS_REQUIRE_PRICE_SYNC=true：要求首次模型价格同步成功
+	if envBool("READINESS_REQUIRE_MIGRATIONS") {
+		if !models.MigrationsDone() {
+			ready["status"] = "not_ready"
+			ready["migrations"] = "pending"
+			ready["failed"] = "migrations"
+			c.JSON(503, ready)
+			return
+		}
+		ready["migrations"] = "ready"
+	}
+	if envBool("READINESS_REQUIRE_PRICE_SYNC") {
+		if !service.PriceSyncReady() {
+			ready["status"] = "not_ready"
+			ready["price_sync"] = "pending"
+			ready["failed"] = "price_sync"
+			c.JSON(503, ready)
+			return
+		}
+		ready["price_sync"] = "ready"
+	}
+
 	c.JSON(200, ready)
 }
 
+func envBool(key string) bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	return err == nil && v
+}
+
 // LivenessCheck 存活检查接口
 func LivenessCheck(c *gin.Context) {
 	liveness := gin.H{
@@ -210,7 +202,7 @@ func GetSystemHealthDetail(c *gin.Context) {
 	health.Components.Redis = checkRedisHealth()
 
 	// 检查提供商状态
-	health.Components.Providers = checkProvidersHealth(windowMinutes)
+	health.Components.Providers = service.CheckProvidersHealth(c.Request.Context(), windowMinutes)
 
 	// 根据组件状态确定整体状态
 	if health.Components.Database.Status == "unhealthy" ||
@@ -266,6 +258,13 @@ func checkDatabaseHealth() ComponentStatus {
 
 // checkRedisHealth 检查Redis健康状态
 func checkRedisHealth() ComponentStatus {
+	// 配置了 Redis 但运行期不可用：限流已切换到进程内存计数，后台探测恢复后自动切回
+	if service.GetLimiterBackend() == limiter.BackendDegraded {
+		return ComponentStatus{
+			Status:  "unhealthy",
+			Message: stringPtr("Redis unavailable, limiter fell back to in-memory counting"),
+		}
+	}
 	redisClient := service.GetRedisClient()
 	if redisClient == nil {
 		return ComponentStatus{
@@ -298,313 +297,7 @@ func checkRedisHealth() ComponentStatus {
 	}
 }
 
-// checkProvidersHealth 检查提供商健康状态
-func checkProvidersHealth(windowMinutes int) struct {
-	Status    string           `json:"status"`
-	Total     int              `json:"total"`
-	Healthy   int              `json:"healthy"`
-	Degraded  int              `json:"degraded"`
-	Unhealthy int              `json:"unhealthy"`
-	Details   []ProviderHealth `json:"details"`
-} {
-	result := struct {
-		Status    string           `json:"status"`
-		Total     int              `json:"total"`
-		Healthy   int              `json:"healthy"`
-		Degraded  int              `json:"degraded"`
-		Unhealthy int              `json:"unhealthy"`
-		Details   []ProviderHealth `json:"details"`
-	}{
-		Status:  "healthy",
-		Details: []ProviderHealth{},
-	}
-
-	now := time.Now()
-	windowStart := now.Add(-time.Duration(windowMinutes) * time.Minute)
-
-	// 1) 一次性获取所有提供商
-	var providers []models.Provider
-	if err := models.DB.Where("deleted_at IS NULL").Find(&providers).Error; err != nil {
-		result.Status = "unhealthy"
-		return result
-	}
-	result.Total = len(providers)
-
-	if len(providers) == 0 {
-		return result
-	}
-
-	providerByID := make(map[uint]models.Provider, len(providers))
-	providerNames := make([]string, 0, len(providers))
-	for _, p := range providers {
-		providerByID[p.ID] = p
-		providerNames = append(providerNames, p.Name)
-	}
-
-	// 2) 一次性获取所有 model_with_providers 关联（避免 N+1）
-	var modelProviders []models.ModelWithProvider
-	if err := models.DB.Where("deleted_at IS NULL").Find(&modelProviders).Error; err != nil {
-		result.Status = "unhealthy"
-		return result
-	}
-
-	// 只保留 provider 存在的关联
-	filteredMP := make([]models.ModelWithProvider, 0, len(modelProviders))
-	modelIDSet := make(map[uint]struct{})
-	providerModelSet := make(map[string]struct{})
-	for _, mp := range modelProviders {
-		if _, ok := providerByID[mp.ProviderID]; !ok {
-			continue
-		}
-		filteredMP = append(filteredMP, mp)
-		modelIDSet[mp.ModelID] = struct{}{}
-		if mp.ProviderModel != "" {
-			providerModelSet[mp.ProviderModel] = struct{}{}
-		}
-	}
-
-	// 3) 一次性获取模型信息（id->name）
-	modelIDs := make([]uint, 0, len(modelIDSet))
-	for id := range modelIDSet {
-		modelIDs = append(modelIDs, id)
-	}
-	var modelList []models.Model
-	if len(modelIDs) > 0 {
-		if err := models.DB.Where("id IN ? AND deleted_at IS NULL", modelIDs).Find(&modelList).Error; err != nil {
-			result.Status = "unhealthy"
-			return result
-		}
-	}
-	modelNameByID := make(map[uint]string, len(modelList))
-	modelNames := make([]string, 0, len(modelList))
-	for _, m := range modelList {
-		modelNameByID[m.ID] = m.Name
-		modelNames = append(modelNames, m.Name)
-	}
-
-	providerModels := make([]string, 0, len(providerModelSet))
-	for pm := range providerModelSet {
-		providerModels = append(providerModels, pm)
-	}
-
-	// 4) 批量查询 chat_logs：用窗口函数取每组（provider_name,name,provider_model）最新 100 条
-	type logRow struct {
-		Name          string    `gorm:"column:name"`
-		ProviderName  string    `gorm:"column:provider_name"`
-		ProviderModel string    `gorm:"column:provider_model"`
-		Status        string    `gorm:"column:status"`
-		Error         string    `gorm:"column:error"`
-		ProxyTimeMs   int       `gorm:"column:proxy_time_ms"`
-		CreatedAt     time.Time `gorm:"column:created_at"`
-	}
-
-	type logKey struct {
-		providerName  string
-		modelName     string
-		providerModel string
-	}
-
-	logsByKey := make(map[logKey][]logRow)
-	if len(providerNames) > 0 && len(modelNames) > 0 && len(providerModels) > 0 {
-		sql := `
-SELECT name, provider_name, provider_model, status, error, proxy_time_ms, created_at
-FROM (
-  SELECT name, provider_name, provider_model, status, error, proxy_time_ms, created_at,
-         row_number() OVER (PARTITION BY provider_name, name, provider_model ORDER BY created_at DESC) AS rn
-  FROM chat_logs
-  WHERE created_at >= ? AND deleted_at IS NULL
-    AND provider_name IN (?)
-    AND name IN (?)
-    AND provider_model IN (?)
-) t
-WHERE rn <= 100
-`
-		var rows []logRow
-		if err := models.DB.Raw(sql, windowStart, providerNames, modelNames, providerModels).Scan(&rows).Error; err != nil {
-			// 日志查询失败会严重影响健康统计，直接标记为不健康
-			result.Status = "unhealthy"
-			return result
-		}
-
-		for _, r := range rows {
-			k := logKey{
-				providerName:  r.ProviderName,
-				modelName:     r.Name,
-				providerModel: r.ProviderModel,
-			}
-			logsByKey[k] = append(logsByKey[k], r)
-		}
-	}
-
-	// 5) 内存聚合生成 ProviderHealth / ModelHealth（不再逐模型查询）
-	healthByProviderID := make(map[uint]*ProviderHealth, len(providers))
-	for _, p := range providers {
-		healthByProviderID[p.ID] = &ProviderHealth{
-			ID:        int(p.ID),
-			Name:      p.Name,
-			Type:      p.Type,
-			Status:    "unknown",
-			LastCheck: now.UTC().Format(time.RFC3339),
-			Models:    []ModelHealth{},
-		}
-	}
-
-	for _, mp := range filteredMP {
-		p := providerByID[mp.ProviderID]
-		modelName := modelNameByID[mp.ModelID]
-		if modelName == "" {
-			// 模型不存在/已删除：跳过
-			continue
-		}
-
-		k := logKey{
-			providerName:  p.Name,
-			modelName:     modelName,
-			providerModel: mp.ProviderModel,
-		}
-		rows := logsByKey[k]
-
-		modelHealth := ModelHealth{
-			ModelName:     modelName,
-			ProviderModel: mp.ProviderModel,
-			Status:        "unknown",
-			LastCheck:     now.UTC().Format(time.RFC3339),
-			RequestBlocks: []ModelHealthRequestBlock{},
-		}
-
-		modelHealth.TotalRequests = len(rows)
-		totalResponseTime := 0.0
-		var latestErrAt time.Time
-		var latestErr string
-
-		// blocks 需要从旧到新
-		if len(rows) > 0 {
-			slices.SortFunc(rows, func(a, b logRow) int {
-				if a.CreatedAt.Before(b.CreatedAt) {
-					return -1
-				}
-				if a.CreatedAt.After(b.CreatedAt) {
-					return 1
-				}
-				return 0
-			})
-		}
-
-		for _, r := range rows {
-			isSuccess := r.Status == "success"
-			if !isSuccess {
-				modelHealth.FailedRequests++
-				if latestErrAt.IsZero() || r.CreatedAt.After(latestErrAt) {
-					latestErrAt = r.CreatedAt
-					latestErr = r.Error
-				}
-			}
-			modelHealth.RequestBlocks = append(modelHealth.RequestBlocks, ModelHealthRequestBlock{
-				Success:   isSuccess,
-				Timestamp: r.CreatedAt.UTC().Format(time.RFC3339),
-			})
-			if r.ProxyTimeMs > 0 {
-				totalResponseTime += float64(r.ProxyTimeMs)
-			}
-		}
-
-		if modelHealth.TotalRequests > 0 {
-			modelHealth.SuccessRate = float64(modelHealth.TotalRequests-modelHealth.FailedRequests) / float64(modelHealth.TotalRequests) * 100
-			modelHealth.AvgResponseTimeMs = totalResponseTime / float64(modelHealth.TotalRequests)
-
-			if modelHealth.SuccessRate < 50 {
-				modelHealth.Status = "unhealthy"
-			} else if modelHealth.SuccessRate < 90 || modelHealth.AvgResponseTimeMs > 10000 {
-				modelHealth.Status = "degraded"
-			} else {
-				modelHealth.Status = "healthy"
-			}
-		}
-		if latestErrAt.IsZero() == false && latestErr != "" {
-			modelHealth.LastError = stringPtr(latestErr)
-		}
-
-		ph := healthByProviderID[mp.ProviderID]
-		ph.Models = append(ph.Models, modelHealth)
-		ph.TotalRequests += modelHealth.TotalRequests
-		ph.FailedRequests += modelHealth.FailedRequests
-	}
-
-	// 6) 计算每个 provider 的整体状态
-	for _, p := range providers {
-		ph := healthByProviderID[p.ID]
-
-		// 平均响应时间：取有请求的模型的平均值
-		totalAvg := 0.0
-		count := 0
-		for _, mh := range ph.Models {
-			if mh.TotalRequests > 0 {
-				totalAvg += mh.AvgResponseTimeMs
-				count++
-			}
-		}
-		if count > 0 {
-			ph.ResponseTimeMs = int(totalAvg / float64(count))
-		}
-
-		if ph.TotalRequests > 0 {
-			ph.ErrorRate = float64(ph.FailedRequests) / float64(ph.TotalRequests) * 100
-		}
-
-		if ph.TotalRequests == 0 {
-			ph.Status = "unknown"
-		} else if ph.ErrorRate > 50 {
-			ph.Status = "unhealthy"
-		} else if ph.ErrorRate > 10 || ph.ResponseTimeMs > 5000 {
-			ph.Status = "degraded"
-		} else {
-			ph.Status = "healthy"
-		}
-
-		// 让模型列表输出更稳定（按 modelName+providerModel 排序）
-		slices.SortFunc(ph.Models, func(a, b ModelHealth) int {
-			if a.ModelName != b.ModelName {
-				return stringsCompare(a.ModelName, b.ModelName)
-			}
-			return stringsCompare(a.ProviderModel, b.ProviderModel)
-		})
-
-		result.Details = append(result.Details, *ph)
-		switch ph.Status {
-		case "healthy":
-			result.Healthy++
-		case "degraded":
-			result.Degraded++
-		case "unhealthy":
-			result.Unhealthy++
-		}
-	}
-
-	// 确定整体提供商状态
-	if result.Unhealthy > 0 {
-		if result.Unhealthy >= result.Total/2 {
-			result.Status = "unhealthy"
-		} else {
-			result.Status = "degraded"
-		}
-	} else if result.Degraded > 0 {
-		result.Status = "degraded"
-	}
-
-	return result
-}
-
 // stringPtr 返回字符串指针
 func stringPtr(s string) *string {
 	return &s
 }
-
-func stringsCompare(a, b string) int {
-	if a == b {
-		return 0
-	}
-	if a < b {
-		return -1
-	}
-	return 1
-}
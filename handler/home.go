@@ -2,6 +2,8 @@ package handler
 
 import (
 	"database/sql"
+	"fmt"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -10,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/racio/llmio/common"
 	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/service"
 	"gorm.io/gorm"
 )
 
@@ -53,7 +56,7 @@ func Metrics(c *gin.Context) {
 
 	now := time.Now()
 	year, month, day := now.Date()
-	chain := gorm.G[models.ChatLog](models.DB).Where("created_at >= ?", time.Date(year, month, day, 0, 0, 0, 0, now.Location()).AddDate(0, 0, -days))
+	chain := gorm.G[models.ChatLog](models.Reader()).Where("created_at >= ?", time.Date(year, month, day, 0, 0, 0, 0, now.Location()).AddDate(0, 0, -days))
 
 	reqs, err := chain.Count(c.Request.Context(), "id")
 	if err != nil {
@@ -79,15 +82,15 @@ func Metrics(c *gin.Context) {
 func MetricsSummary(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	base := models.DB.Model(&models.ChatLog{}).Where("deleted_at IS NULL")
+	base := models.Reader().Model(&models.ChatLog{}).Where("deleted_at IS NULL")
 
-	totalReqs, err := gorm.G[models.ChatLog](models.DB).Where("deleted_at IS NULL").Count(ctx, "id")
+	totalReqs, err := gorm.G[models.ChatLog](models.Reader()).Where("deleted_at IS NULL").Count(ctx, "id")
 	if err != nil {
 		common.InternalServerError(c, "Failed to count requests: "+err.Error())
 		return
 	}
 
-	totalSuccess, err := gorm.G[models.ChatLog](models.DB).Where("deleted_at IS NULL").Where("status = ?", "success").Count(ctx, "id")
+	totalSuccess, err := gorm.G[models.ChatLog](models.Reader()).Where("deleted_at IS NULL").Where("status = ?", "success").Count(ctx, "id")
 	if err != nil {
 		common.InternalServerError(c, "Failed to count success requests: "+err.Error())
 		return
@@ -108,12 +111,12 @@ func MetricsSummary(c *gin.Context) {
 	year, month, day := now.Date()
 	startOfDay := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
 
-	todayReqs, err := gorm.G[models.ChatLog](models.DB).Where("deleted_at IS NULL").Where("created_at >= ?", startOfDay).Count(ctx, "id")
+	todayReqs, err := gorm.G[models.ChatLog](models.Reader()).Where("deleted_at IS NULL").Where("created_at >= ?", startOfDay).Count(ctx, "id")
 	if err != nil {
 		common.InternalServerError(c, "Failed to count today requests: "+err.Error())
 		return
 	}
-	todaySuccess, err := gorm.G[models.ChatLog](models.DB).Where("deleted_at IS NULL").Where("created_at >= ?", startOfDay).Where("status = ?", "success").Count(ctx, "id")
+	todaySuccess, err := gorm.G[models.ChatLog](models.Reader()).Where("deleted_at IS NULL").Where("created_at >= ?", startOfDay).Where("status = ?", "success").Count(ctx, "id")
 	if err != nil {
 		common.InternalServerError(c, "Failed to count today success requests: "+err.Error())
 		return
@@ -152,11 +155,11 @@ func RequestAmountTrend(c *gin.Context) {
 	startOfDay := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
-	base := models.DB.Model(&models.ChatLog{}).
+	base := models.Reader().Model(&models.ChatLog{}).
 		Where("deleted_at IS NULL").
 		Where("created_at >= ? AND created_at < ?", startOfDay, endOfDay)
 
-	totalRequests, err := gorm.G[models.ChatLog](models.DB).Where("deleted_at IS NULL").Where("created_at >= ? AND created_at < ?", startOfDay, endOfDay).Count(ctx, "id")
+	totalRequests, err := gorm.G[models.ChatLog](models.Reader()).Where("deleted_at IS NULL").Where("created_at >= ? AND created_at < ?", startOfDay, endOfDay).Count(ctx, "id")
 	if err != nil {
 		common.InternalServerError(c, "Failed to count requests: "+err.Error())
 		return
@@ -174,7 +177,7 @@ func RequestAmountTrend(c *gin.Context) {
 		Amount     float64   `gorm:"column:amount"`
 	}
 	rows := make([]hourRow, 0)
-	if err := models.DB.Raw(
+	if err := models.Reader().Raw(
 		`SELECT date_trunc('hour', created_at) AS hour_bucket,
 		        COUNT(*) AS requests,
 		        COALESCE(SUM(total_cost),0) AS amount
@@ -220,6 +223,213 @@ func RequestAmountTrend(c *gin.Context) {
 	})
 }
 
+type DailyCostGroup struct {
+	Key              string  `json:"key"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+type DailyCostPoint struct {
+	Date             string           `json:"date"`
+	Requests         int64            `json:"requests"`
+	PromptTokens     int64            `json:"prompt_tokens"`
+	CompletionTokens int64            `json:"completion_tokens"`
+	TotalTokens      int64            `json:"total_tokens"`
+	Cost             float64          `json:"cost"`
+	Groups           []DailyCostGroup `json:"groups,omitempty"`
+}
+
+type DailyCostRes struct {
+	Start     string           `json:"start"`
+	End       string           `json:"end"`
+	GroupBy   string           `json:"group_by,omitempty"`
+	TotalCost float64          `json:"total_cost"`
+	Points    []DailyCostPoint `json:"points"`
+}
+
+// maxDailyCostDays 单次查询允许的最大天数
+const maxDailyCostDays = 366
+
+// DailyCost 返回指定日期范围内按天聚合的消费与 token 用量，用于账单对账
+// 查询参数：
+// - start/end：日期（YYYY-MM-DD，按服务器时区，包含两端），默认最近 30 天
+// - group_by：可选 model / key，按模型或 API Key 进一步拆分
+func DailyCost(c *gin.Context) {
+	now := time.Now()
+	loc := now.Location()
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, loc)
+
+	start := today.AddDate(0, 0, -29)
+	end := today
+	if v := strings.TrimSpace(c.Query("start")); v != "" {
+		t, err := time.ParseInLocation(time.DateOnly, v, loc)
+		if err != nil {
+			common.BadRequest(c, "Invalid start parameter, must be YYYY-MM-DD")
+			return
+		}
+		start = t
+	}
+	if v := strings.TrimSpace(c.Query("end")); v != "" {
+		t, err := time.ParseInLocation(time.DateOnly, v, loc)
+		if err != nil {
+			common.BadRequest(c, "Invalid end parameter, must be YYYY-MM-DD")
+			return
+		}
+		end = t
+	}
+	if end.Before(start) {
+		common.BadRequest(c, "end must not be before start")
+		return
+	}
+	dates := dailyCostDates(start, end, maxDailyCostDays+1)
+	if len(dates) > maxDailyCostDays {
+		common.BadRequest(c, fmt.Sprintf("date range too large (max %d days)", maxDailyCostDays))
+		return
+	}
+
+	groupBy := strings.TrimSpace(c.Query("group_by"))
+	var groupExpr string
+	switch groupBy {
+	case "":
+		groupExpr = "''"
+	case "model":
+		groupExpr = "name"
+	case "key":
+		groupExpr = "CAST(auth_key_id AS TEXT)"
+	default:
+		common.BadRequest(c, "Invalid group_by: must be 'model' or 'key'")
+		return
+	}
+
+	type dayRow struct {
+		DayBucket        string  `gorm:"column:day_bucket"`
+		GroupKey         string  `gorm:"column:group_key"`
+		Requests         int64   `gorm:"column:requests"`
+		PromptTokens     int64   `gorm:"column:prompt_tokens"`
+		CompletionTokens int64   `gorm:"column:completion_tokens"`
+		TotalTokens      int64   `gorm:"column:total_tokens"`
+		Cost             float64 `gorm:"column:cost"`
+	}
+	rows := make([]dayRow, 0)
+	rangeEnd := end.AddDate(0, 0, 1)
+	if err := models.Reader().WithContext(c.Request.Context()).Raw(
+		`SELECT to_char(date_trunc('day', created_at AT TIME ZONE ?), 'YYYY-MM-DD') AS day_bucket,
+		        `+groupExpr+` AS group_key,
+		        COUNT(*) AS requests,
+		        COALESCE(SUM(prompt_tokens),0) AS prompt_tokens,
+		        COALESCE(SUM(completion_tokens),0) AS completion_tokens,
+		        COALESCE(SUM(total_tokens),0) AS total_tokens,
+		        COALESCE(SUM(total_cost),0) AS cost
+		   FROM chat_logs
+		  WHERE deleted_at IS NULL
+		    AND created_at >= ? AND created_at < ?
+		  GROUP BY day_bucket, group_key
+		  ORDER BY day_bucket, group_key`,
+		pgTimeZone(loc, now),
+		start,
+		rangeEnd,
+	).Scan(&rows).Error; err != nil {
+		common.InternalServerError(c, "Failed to query daily cost: "+err.Error())
+		return
+	}
+
+	keyNames := make(map[string]string)
+	if groupBy == "key" {
+		keys := make([]models.AuthKey, 0)
+		if err := models.Reader().WithContext(c.Request.Context()).Model(&models.AuthKey{}).Select("id", "name").Find(&keys).Error; err != nil {
+			common.InternalServerError(c, "Failed to query auth keys: "+err.Error())
+			return
+		}
+		for _, key := range keys {
+			keyNames[strconv.FormatUint(uint64(key.ID), 10)] = key.Name
+		}
+		keyNames["0"] = "admin"
+	}
+
+	pointByDate := make(map[string]*DailyCostPoint, len(dates))
+	points := make([]DailyCostPoint, len(dates))
+	for i, date := range dates {
+		points[i] = DailyCostPoint{Date: date}
+		pointByDate[date] = &points[i]
+	}
+
+	totalCost := 0.0
+	for _, row := range rows {
+		point, ok := pointByDate[row.DayBucket]
+		if !ok {
+			continue
+		}
+		point.Requests += row.Requests
+		point.PromptTokens += row.PromptTokens
+		point.CompletionTokens += row.CompletionTokens
+		point.TotalTokens += row.TotalTokens
+		point.Cost += row.Cost
+		totalCost += row.Cost
+		if groupBy != "" {
+			key := row.GroupKey
+			if name, ok := keyNames[key]; ok {
+				key = name
+			}
+			point.Groups = append(point.Groups, DailyCostGroup{
+				Key:              key,
+				Requests:         row.Requests,
+				PromptTokens:     row.PromptTokens,
+				CompletionTokens: row.CompletionTokens,
+				TotalTokens:      row.TotalTokens,
+				Cost:             row.Cost,
+			})
+		}
+	}
+
+	common.Success(c, DailyCostRes{
+		Start:     start.Format(time.DateOnly),
+		End:       end.Format(time.DateOnly),
+		GroupBy:   groupBy,
+		TotalCost: totalCost,
+		Points:    points,
+	})
+}
+
+// dailyCostDates 返回 start 到 end（含）的日期，最多 limit 个
+// 按日历日逐天递增而不是按 24 小时换算，夏令时切换当天（23/25 小时）不会多算或漏算
+func dailyCostDates(start, end time.Time, limit int) []string {
+	dates := make([]string, 0)
+	for d := start; !d.After(end) && len(dates) < limit; d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d.Format(time.DateOnly))
+	}
+	return dates
+}
+
+// pgTimeZone 返回 PostgreSQL 可识别的时区名，使数据库按服务器时区划分日期
+// time.Local 的名称为 "Local"，此时依次尝试 TZ 环境变量与 /etc/localtime；都无法确定时退回 at 时刻的固定偏移
+func pgTimeZone(loc *time.Location, at time.Time) string {
+	if loc == time.Local {
+		if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+			if _, err := time.LoadLocation(tz); err == nil {
+				return tz
+			}
+		}
+		if target, err := os.Readlink("/etc/localtime"); err == nil {
+			if i := strings.Index(target, "zoneinfo/"); i >= 0 {
+				return target[i+len("zoneinfo/"):]
+			}
+		}
+	} else if name := loc.String(); name != "" {
+		return name
+	}
+	// POSIX 时区格式的偏移与 ISO 相反：UTC-8 表示东八区
+	_, offset := at.In(loc).Zone()
+	sign := "-"
+	if offset < 0 {
+		sign, offset = "+", -offset
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
 type Count struct {
 	Model string `json:"model"`
 	Calls int64  `json:"calls"`
@@ -227,7 +437,7 @@ type Count struct {
 
 func Counts(c *gin.Context) {
 	results := make([]Count, 0)
-	if err := models.DB.
+	if err := models.Reader().
 		Model(&models.ChatLog{}).
 		Select("name as model, COUNT(*) as calls").
 		Group("name").
@@ -264,7 +474,7 @@ func ProjectCounts(c *gin.Context) {
 	}
 
 	rows := make([]authKeyCount, 0)
-	if err := models.DB.
+	if err := models.Reader().
 		Model(&models.ChatLog{}).
 		Select("auth_key_id, COUNT(*) as calls").
 		Group("auth_key_id").
@@ -284,7 +494,7 @@ func ProjectCounts(c *gin.Context) {
 
 	keys := make([]models.AuthKey, 0)
 	if len(ids) > 0 {
-		if err := models.DB.
+		if err := models.Reader().
 			Model(&models.AuthKey{}).
 			Where("id IN ?", ids).
 			Find(&keys).Error; err != nil {
@@ -333,3 +543,41 @@ func ProjectCounts(c *gin.Context) {
 
 	common.Success(c, results)
 }
+
+const defaultSLOWindowMinutes = 60
+
+type SLORes struct {
+	WindowMinutes int                 `json:"window_minutes"`
+	Reports       []service.SLOReport `json:"reports"`
+}
+
+// SLOMetrics 按配置的 SLO 目标报告窗口内的达成率与违约次数
+// 参数 window：统计窗口（分钟），默认 60
+func SLOMetrics(c *gin.Context) {
+	window := defaultSLOWindowMinutes
+	if v := strings.TrimSpace(c.Query("window")); v != "" {
+		w, err := strconv.Atoi(v)
+		if err != nil || w < 1 || w > service.MaxSLOWindowMinutes {
+			common.BadRequest(c, fmt.Sprintf("Invalid window parameter (1-%d minutes)", service.MaxSLOWindowMinutes))
+			return
+		}
+		window = w
+	}
+
+	ctx := c.Request.Context()
+	cfg, err := service.LoadSLOConfig(ctx)
+	if err != nil {
+		common.BadRequest(c, "Invalid SLO config: "+err.Error())
+		return
+	}
+
+	reports, err := service.ComputeSLO(ctx, cfg.Targets, time.Now().Add(-time.Duration(window)*time.Minute))
+	if err != nil {
+		common.InternalServerError(c, "Failed to compute SLO: "+err.Error())
+		return
+	}
+	common.Success(c, SLORes{
+		WindowMinutes: window,
+		Reports:       reports,
+	})
+}
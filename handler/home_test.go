@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyCostDatesAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata not available:", err)
+	}
+	tests := []struct {
+		name       string
+		start, end string
+		want       int
+		last       string
+	}{
+		// 2024-03-10 只有 23 小时，按小时换算会少算一天
+		{"spring forward", "2024-03-09", "2024-03-11", 3, "2024-03-11"},
+		// 2024-11-03 有 25 小时
+		{"fall back", "2024-11-02", "2024-11-04", 3, "2024-11-04"},
+		{"single day", "2024-03-10", "2024-03-10", 1, "2024-03-10"},
+		{"full year", "2024-01-01", "2024-12-31", 366, "2024-12-31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, _ := time.ParseInLocation(time.DateOnly, tt.start, loc)
+			end, _ := time.ParseInLocation(time.DateOnly, tt.end, loc)
+			dates := dailyCostDates(start, end, maxDailyCostDays+1)
+			if len(dates) != tt.want {
+				t.Fatalf("len = %d, want %d: %v", len(dates), tt.want, dates)
+			}
+			if dates[0] != tt.start || dates[len(dates)-1] != tt.last {
+				t.Fatalf("dates = %s..%s, want %s..%s", dates[0], dates[len(dates)-1], tt.start, tt.last)
+			}
+		})
+	}
+}
+
+func TestDailyCostDatesLimit(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(5, 0, 0)
+	if got := len(dailyCostDates(start, end, maxDailyCostDays+1)); got != maxDailyCostDays+1 {
+		t.Fatalf("len = %d, want %d", got, maxDailyCostDays+1)
+	}
+}
+
+func TestPGTimeZone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata not available:", err)
+	}
+	at := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	if got := pgTimeZone(ny, at); got != "America/New_York" {
+		t.Errorf("named location = %q", got)
+	}
+	if got := pgTimeZone(time.UTC, at); got != "UTC" {
+		t.Errorf("utc = %q", got)
+	}
+	if got := pgTimeZone(time.FixedZone("", 8*3600), at); got != "UTC-08:00" {
+		t.Errorf("east offset = %q, want UTC-08:00", got)
+	}
+	if got := pgTimeZone(time.FixedZone("", -(5*3600+30*60)), at); got != "UTC+05:30" {
+		t.Errorf("west offset = %q, want UTC+05:30", got)
+	}
+}
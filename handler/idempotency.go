@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/service"
+)
+
+// idempotentRequest 记录一个占位中的 Idempotency-Key 请求，并捕获下发给客户端的响应体
+type idempotentRequest struct {
+	authKeyID   uint
+	key         string
+	requestHash string
+	ttl         time.Duration
+	body        bytes.Buffer
+	overflow    bool
+	finished    bool
+}
+
+func (r *idempotentRequest) Write(p []byte) (int, error) {
+	if !r.overflow {
+		if r.body.Len()+len(p) > service.MaxIdempotentBodySize {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// complete 响应完整下发后缓存结果；超出大小限制时仅释放占位
+func (r *idempotentRequest) complete(ctx context.Context, header http.Header) {
+	r.finished = true
+	if r.overflow {
+		r.release(ctx)
+		return
+	}
+	resp := &service.IdempotentResponse{Header: header.Clone(), Body: r.body.Bytes(), RequestHash: r.requestHash}
+	if err := service.CompleteIdempotent(ctx, r.authKeyID, r.key, resp, r.ttl); err != nil {
+		slog.Error("save idempotent response error", "error", err)
+	}
+}
+
+// release 请求未成功完成时释放占位，允许客户端用同一个 key 重试
+func (r *idempotentRequest) release(ctx context.Context) {
+	r.finished = true
+	if err := service.ReleaseIdempotent(ctx, r.authKeyID, r.key); err != nil {
+		slog.Error("release idempotency key error", "error", err)
+	}
+}
+
+// beginIdempotency 处理 Idempotency-Key（仅对携带 auth key 的客户端、且配置开启时生效）：
+// 命中已完成/在途的同 key 请求时直接重放并返回 handled=true，同 key 请求体不同时返回 422；
+// 否则返回占位（可能为 nil），调用方在请求结束时 complete 或 release
+func beginIdempotency(c *gin.Context, before *service.Before, logStyle string, requestHash string, waitTimeout time.Duration) (*idempotentRequest, bool) {
+	key := strings.TrimSpace(c.GetHeader(service.IdempotencyHeader))
+	if key == "" {
+		return nil, false
+	}
+	ctx := c.Request.Context()
+	authKeyID, _ := ctx.Value(consts.ContextKeyAuthKeyID).(uint)
+	if authKeyID == 0 {
+		return nil, false
+	}
+	if len(key) > service.MaxIdempotencyKeyLength {
+		common.BadRequest(c, "Idempotency-Key too long")
+		return nil, true
+	}
+	ttl, err := service.LoadIdempotencyTTL(ctx)
+	if err != nil {
+		slog.Error("load idempotency config error", "error", err)
+		return nil, false
+	}
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	resp, acquired, err := service.BeginIdempotent(ctx, authKeyID, key, requestHash, ttl)
+	if errors.Is(err, service.ErrIdempotencyKeyReused) {
+		common.ErrorWithHttpStatus(c, http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, err.Error())
+		return nil, true
+	}
+	if err != nil {
+		// 去重依赖不可用时不影响正常请求
+		slog.Warn("idempotency check failed", "error", err)
+		return nil, false
+	}
+	if acquired {
+		return &idempotentRequest{authKeyID: authKeyID, key: key, requestHash: requestHash, ttl: ttl}, false
+	}
+	if resp == nil {
+		// 同 key 请求仍在处理中：等待其完成后重放
+		resp, err = service.WaitIdempotent(ctx, authKeyID, key, waitTimeout)
+		if err != nil {
+			slog.Warn("wait idempotent response failed", "error", err)
+		}
+		if resp == nil {
+			common.ErrorWithHttpStatus(c, http.StatusConflict, http.StatusConflict, "a request with the same Idempotency-Key is in progress or failed, please retry")
+			return nil, true
+		}
+	}
+
+	replayIdempotent(c, before, logStyle, resp)
+	return nil, true
+}
+
+// replayIdempotent 重放缓存的响应并记录一条去重命中日志
+func replayIdempotent(c *gin.Context, before *service.Before, logStyle string, resp *service.IdempotentResponse) {
+	ctx := c.Request.Context()
+	authKeyID, _ := ctx.Value(consts.ContextKeyAuthKeyID).(uint)
+	if _, err := service.SaveChatLog(ctx, models.ChatLog{
+		Name:      before.Model,
+		Status:    "success",
+		Style:     logStyle,
+		UserAgent: c.Request.UserAgent(),
+		RemoteIP:  c.ClientIP(),
+		AuthKeyID: authKeyID,
+		Dedup:     1,
+	}); err != nil {
+		slog.Error("save dedup chat log error", "error", err)
+	}
+
+	c.Header(service.IdempotencyReplayedHeader, "true")
+	writeHeader(c, before.Stream, resp.Header)
+	if _, err := c.Writer.Write(resp.Body); err != nil {
+		slog.Error("write idempotent response error", "error", err)
+	}
+}
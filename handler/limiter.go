@@ -1,20 +1,77 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/limiter"
+	"github.com/racio/llmio/models"
 	"github.com/racio/llmio/service"
+	"gorm.io/gorm"
 )
 
-// GetLimiterStats 获取限流器统计信息
+// GetLimiterStats 获取限流器统计信息，rejections 为最近一小时内各提供商按原因统计的拒绝次数
 func GetLimiterStats(c *gin.Context) {
 	ctx := c.Request.Context()
 	stats := service.GetRPMStats(ctx)
+	stats["rejections"] = service.GetLimiterRejections(limiter.RejectionRetention)
 	common.Success(c, stats)
 }
 
+// LimiterRejectionItem 提供商限流拒绝统计
+type LimiterRejectionItem struct {
+	limiter.RejectionCount
+	ProviderName string `json:"provider_name"`
+}
+
+// LimiterRejectionsRes 限流拒绝统计：window 为分钟数，计数为当前实例进程内统计
+type LimiterRejectionsRes struct {
+	WindowMinutes int                    `json:"window_minutes"`
+	Items         []LimiterRejectionItem `json:"items"`
+}
+
+// LimiterRejections 返回各提供商被 RPM/TPM/IP 锁定/token 锁/并发限制拒绝的次数（累计与时间窗口内）
+func LimiterRejections(c *gin.Context) {
+	maxWindow := int(limiter.RejectionRetention / time.Minute)
+	window := maxWindow
+	if v := c.Query("window"); v != "" {
+		w, err := strconv.Atoi(v)
+		if err != nil || w < 1 || w > maxWindow {
+			common.BadRequest(c, fmt.Sprintf("Invalid window parameter (1-%d minutes)", maxWindow))
+			return
+		}
+		window = w
+	}
+
+	counts := service.GetLimiterRejections(time.Duration(window) * time.Minute)
+	ids := make([]uint, 0, len(counts))
+	for _, count := range counts {
+		ids = append(ids, count.ProviderID)
+	}
+	names := make(map[uint]string)
+	if len(ids) > 0 {
+		providers, err := gorm.G[models.Provider](models.DB).Where("id IN ?", ids).Find(c.Request.Context())
+		if err != nil {
+			common.InternalServerError(c, "Failed to query providers: "+err.Error())
+			return
+		}
+		for _, provider := range providers {
+			names[provider.ID] = provider.Name
+		}
+	}
+
+	items := make([]LimiterRejectionItem, 0, len(counts))
+	for _, count := range counts {
+		items = append(items, LimiterRejectionItem{RejectionCount: count, ProviderName: names[count.ProviderID]})
+	}
+	common.Success(c, LimiterRejectionsRes{WindowMinutes: window, Items: items})
+}
+
 type ProviderStatsRequest struct {
 	ProviderIDs []uint `json:"provider_ids"`
 }
@@ -23,6 +80,8 @@ type ProviderStatsItem struct {
 	ProviderID   uint       `json:"provider_id"`
 	RPMCount     int        `json:"rpm_count"`
 	RPMLoaded    bool       `json:"rpm_loaded"`
+	TPMCount     int64      `json:"tpm_count"`
+	TPMLoaded    bool       `json:"tpm_loaded"`
 	Locked       bool       `json:"locked"`
 	IPLockLoaded bool       `json:"ip_lock_loaded"`
 	LockUntil    *time.Time `json:"lock_until,omitempty"`
@@ -49,6 +108,12 @@ func GetProvidersStats(c *gin.Context) {
 			rpmCount = 0
 		}
 
+		tpmCount, tpmErr := service.GetCurrentTPM(ctx, providerID)
+		tpmLoaded := tpmErr == nil
+		if tpmErr != nil {
+			tpmCount = 0
+		}
+
 		status, ipErr := service.GetIPLockStatus(ctx, providerID)
 		ipLoaded := ipErr == nil
 		locked := false
@@ -63,6 +128,8 @@ func GetProvidersStats(c *gin.Context) {
 			ProviderID:   providerID,
 			RPMCount:     rpmCount,
 			RPMLoaded:    rpmLoaded,
+			TPMCount:     tpmCount,
+			TPMLoaded:    tpmLoaded,
 			Locked:       locked,
 			IPLockLoaded: ipLoaded,
 			LockUntil:    lockUntil,
@@ -77,16 +144,164 @@ func GetLimiterHealth(c *gin.Context) {
 	ctx := c.Request.Context()
 	stats := service.GetRPMStats(ctx)
 
+	// 计数后端：redis / memory / degraded（配置了 Redis 但不可用，已降级为进程内存）
+	backend, _ := stats["backend"].(string)
 	health := gin.H{
 		"status":    "healthy",
 		"timestamp": gin.H{},
+		"backend":   backend,
 		"limiter":   stats,
 	}
 
 	// 检查限流器是否启用
 	if enabled, ok := stats["enabled"].(bool); ok && !enabled {
 		health["status"] = "disabled"
+	} else if backend == limiter.BackendDegraded {
+		health["status"] = "degraded"
 	}
 
 	common.Success(c, health)
 }
+
+// respondLimiterError 限流依赖不可用时返回 503，其余返回 500
+func respondLimiterError(c *gin.Context, err error) {
+	if errors.Is(err, limiter.ErrLimiterUnavailable) {
+		common.ErrorWithCode(c, http.StatusServiceUnavailable, http.StatusServiceUnavailable, common.ErrCodeLimiterUnavailable, "限流服务不可用，请稍后重试")
+		return
+	}
+	common.InternalServerError(c, err.Error())
+}
+
+// GetTokenLocks 列出当前生效的 token 锁
+func GetTokenLocks(c *gin.Context) {
+	locks, err := service.ListTokenLocks(c.Request.Context())
+	if err != nil {
+		respondLimiterError(c, err)
+		return
+	}
+	common.Success(c, locks)
+}
+
+// DeleteTokenLock 清除指定关联的 token 锁
+func DeleteTokenLock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("mwppId"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID format")
+		return
+	}
+	if err := service.ClearTokenLock(c.Request.Context(), uint(id)); err != nil {
+		respondLimiterError(c, err)
+		return
+	}
+	common.Success(c, nil)
+}
+
+// ClearTokenLocks 清除全部 token 锁
+func ClearTokenLocks(c *gin.Context) {
+	cleared, err := service.ClearAllTokenLocks(c.Request.Context())
+	if err != nil {
+		respondLimiterError(c, err)
+		return
+	}
+	common.Success(c, gin.H{"cleared_count": cleared})
+}
+
+// GetProviderConcurrency 获取提供商当前在途请求数与并发上限
+func GetProviderConcurrency(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID format")
+		return
+	}
+	ctx := c.Request.Context()
+	provider, err := gorm.G[models.Provider](models.Reader()).Where("id = ?", id).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.NotFound(c, "Provider not found")
+			return
+		}
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	current, err := service.GetProviderConcurrency(ctx, provider.ID)
+	if err != nil {
+		respondLimiterError(c, err)
+		return
+	}
+	common.Success(c, gin.H{
+		"provider_id":     provider.ID,
+		"current":         current,
+		"max_concurrency": provider.MaxConcurrency,
+	})
+}
+
+// GetModelProviderRPM 获取模型-提供商关联当前 RPM 计数，以及关联与所属提供商的 RPM 上限
+func GetModelProviderRPM(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID format")
+		return
+	}
+	ctx := c.Request.Context()
+	modelProvider, err := gorm.G[models.ModelWithProvider](models.Reader()).Where("id = ?", id).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.NotFound(c, "Model-provider association not found")
+			return
+		}
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	provider, err := gorm.G[models.Provider](models.Reader()).Where("id = ?", modelProvider.ProviderID).First(ctx)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	current, err := service.GetCurrentModelProviderRPMCount(ctx, modelProvider.ID)
+	if err != nil {
+		respondLimiterError(c, err)
+		return
+	}
+	providerCurrent, err := service.GetCurrentRPMCount(ctx, modelProvider.ProviderID)
+	if err != nil {
+		respondLimiterError(c, err)
+		return
+	}
+	common.Success(c, gin.H{
+		"model_with_provider_id": modelProvider.ID,
+		"rpm_count":              current,
+		"rpm_limit":              modelProvider.RpmLimit,
+		"provider_id":            modelProvider.ProviderID,
+		"provider_rpm_count":     providerCurrent,
+		"provider_rpm_limit":     provider.RpmLimit,
+	})
+}
+
+// GetAuthKeyRPM 获取 AuthKey 当前 RPM 计数与上限
+func GetAuthKeyRPM(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID format")
+		return
+	}
+	ctx := c.Request.Context()
+	authKey, err := gorm.G[models.AuthKey](models.Reader()).Where("id = ?", id).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.NotFound(c, "Auth key not found")
+			return
+		}
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	current, err := service.GetKeyRPMCount(ctx, authKey.ID)
+	if err != nil {
+		respondLimiterError(c, err)
+		return
+	}
+	common.Success(c, gin.H{
+		"auth_key_id": authKey.ID,
+		"rpm_count":   current,
+		"rpm_limit":   authKey.RpmLimit,
+	})
+}
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/models"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+const (
+	// logSearchMaxPageSize IO 内容较大，每页最多返回的匹配条数
+	logSearchMaxPageSize = 20
+	// logSearchMaxRange 单次搜索允许的最大时间范围，限制扫描的数据量
+	logSearchMaxRange = 31 * 24 * time.Hour
+	// logSearchDefaultRange 未指定开始时间时向前搜索的范围
+	logSearchDefaultRange = 7 * 24 * time.Hour
+	// logSearchSnippetRadius 匹配片段在关键词前后各保留的字符数
+	logSearchSnippetRadius = 120
+	// logSearchMaxQueryLen 关键词最大长度
+	logSearchMaxQueryLen = 512
+
+	logSearchModeContains = "contains" // 子串匹配（默认），Postgres 使用 ILIKE
+	logSearchModeFulltext = "fulltext" // 全文检索，仅 Postgres，使用 chat_io 上的 tsvector GIN 索引
+)
+
+// logSearchTSVector 全文检索表达式，需与 init_pg_db.sql 中 idx_chat_io_fts 的索引表达式保持一致才能命中索引
+const logSearchTSVector = "to_tsvector('simple', left(input, 100000) || ' ' || left(output_string, 100000) || ' ' || left(output_string_array, 100000))"
+
+// LogSearchRequest IO 内容搜索请求
+type LogSearchRequest struct {
+	Query     string     `json:"query"`
+	Mode      string     `json:"mode"` // contains（默认）/ fulltext
+	StartTime *time.Time `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	Name      string     `json:"name"` // 按模型名称过滤
+	Page      int        `json:"page"`
+	PageSize  int        `json:"page_size"`
+}
+
+// LogSearchItem 搜索命中的请求日志，只返回匹配位置附近的片段而非完整 IO
+type LogSearchItem struct {
+	models.ChatLog
+	InputSnippet  string `json:"input_snippet"`
+	OutputSnippet string `json:"output_snippet"`
+}
+
+// LogSearchResponse 搜索结果：分页数据之外说明时间范围内未能搜索的请求
+type LogSearchResponse struct {
+	common.PaginationResponse
+	IODisabledCount int64    `json:"io_disabled_count"` // 未开启 IO 记录、无法搜索的请求数
+	BlobStoredCount int64    `json:"blob_stored_count"` // IO 存放在对象存储、无法搜索的请求数
+	Warnings        []string `json:"warnings"`
+}
+
+type logSearchRow struct {
+	LogId             uint
+	Input             string
+	OutputString      string
+	OutputStringArray string
+}
+
+// SearchLogs 在记录的请求/响应内容中搜索关键词，按时间范围与模型过滤并分页返回
+func SearchLogs(c *gin.Context) {
+	var req LogSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		common.BadRequest(c, "query is required")
+		return
+	}
+	if utf8.RuneCountInString(req.Query) > logSearchMaxQueryLen {
+		common.BadRequest(c, fmt.Sprintf("query must be at most %d characters", logSearchMaxQueryLen))
+		return
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = logSearchModeContains
+	case logSearchModeContains, logSearchModeFulltext:
+	default:
+		common.BadRequest(c, "mode must be contains or fulltext")
+		return
+	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PageSize < 1 || req.PageSize > logSearchMaxPageSize {
+		req.PageSize = logSearchMaxPageSize
+	}
+
+	end := time.Now()
+	if req.EndTime != nil {
+		end = *req.EndTime
+	}
+	start := end.Add(-logSearchDefaultRange)
+	if req.StartTime != nil {
+		start = *req.StartTime
+	}
+	if !start.Before(end) {
+		common.BadRequest(c, "start_time must be before end_time")
+		return
+	}
+	if end.Sub(start) > logSearchMaxRange {
+		common.BadRequest(c, fmt.Sprintf("time range must be at most %d days", int(logSearchMaxRange.Hours()/24)))
+		return
+	}
+
+	ctx := c.Request.Context()
+	db := models.Reader().WithContext(ctx)
+	warnings := []string{}
+
+	logScope := func(query *gorm.DB) *gorm.DB {
+		query = query.Where("chat_logs.deleted_at IS NULL").
+			Where("chat_logs.created_at >= ? AND chat_logs.created_at < ?", start, end)
+		if req.Name != "" {
+			query = query.Where("chat_logs.name = ?", req.Name)
+		}
+		return query
+	}
+
+	query := logScope(db.Table("chat_io").
+		Joins("JOIN chat_logs ON chat_logs.id = chat_io.log_id").
+		Where("chat_io.deleted_at IS NULL"))
+
+	postgres := db.Dialector.Name() == "postgres"
+	if req.Mode == logSearchModeFulltext && !postgres {
+		req.Mode = logSearchModeContains
+		warnings = append(warnings, "fulltext mode is only supported on Postgres, fell back to contains")
+	}
+	switch {
+	case req.Mode == logSearchModeFulltext:
+		query = query.Where(logSearchTSVector+" @@ plainto_tsquery('simple', ?)", req.Query)
+	case postgres:
+		pattern := "%" + escapeLike(req.Query) + "%"
+		query = query.Where("(chat_io.input ILIKE ? OR chat_io.output_string ILIKE ? OR chat_io.output_string_array ILIKE ?)", pattern, pattern, pattern)
+	default:
+		pattern := "%" + escapeLike(strings.ToLower(req.Query)) + "%"
+		query = query.Where("(LOWER(chat_io.input) LIKE ? OR LOWER(chat_io.output_string) LIKE ? OR LOWER(chat_io.output_string_array) LIKE ?)", pattern, pattern, pattern)
+	}
+
+	params := common.PaginationParams{Page: req.Page, PageSize: req.PageSize}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		common.InternalServerError(c, "Failed to search logs: "+err.Error())
+		return
+	}
+	var rows []logSearchRow
+	if err := common.ApplyPagination(query.Select("chat_io.log_id, chat_io.input, chat_io.output_string, chat_io.output_string_array").Order("chat_io.log_id DESC"), params).
+		Scan(&rows).Error; err != nil {
+		common.InternalServerError(c, "Failed to search logs: "+err.Error())
+		return
+	}
+
+	// 未开启 IO 记录或 IO 存放在对象存储的请求无法在数据库中搜索，统计后提示
+	var ioDisabled, blobStored int64
+	if err := logScope(db.Table("chat_logs")).Where("chat_logs.chat_io = ?", 0).Count(&ioDisabled).Error; err != nil {
+		common.InternalServerError(c, "Failed to count logs: "+err.Error())
+		return
+	}
+	if err := logScope(db.Table("chat_io").Joins("JOIN chat_logs ON chat_logs.id = chat_io.log_id")).
+		Where("chat_io.deleted_at IS NULL AND chat_io.blob_key <> ''").Count(&blobStored).Error; err != nil {
+		common.InternalServerError(c, "Failed to count logs: "+err.Error())
+		return
+	}
+	if ioDisabled > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d requests in range had IO logging disabled and were not searched", ioDisabled))
+	}
+	if blobStored > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d requests in range store IO in the blob store and were not searched", blobStored))
+	}
+
+	logs, err := gorm.G[models.ChatLog](models.Reader()).
+		Where("id IN ?", lo.Map(rows, func(row logSearchRow, _ int) uint { return row.LogId })).
+		Find(ctx)
+	if err != nil {
+		common.InternalServerError(c, "Failed to query logs: "+err.Error())
+		return
+	}
+	logMap := lo.KeyBy(logs, func(log models.ChatLog) uint { return log.ID })
+
+	items := make([]LogSearchItem, 0, len(rows))
+	for _, row := range rows {
+		log, ok := logMap[row.LogId]
+		if !ok {
+			continue
+		}
+		output := row.OutputString
+		if output == "" {
+			output = row.OutputStringArray
+		}
+		items = append(items, LogSearchItem{
+			ChatLog:       log,
+			InputSnippet:  matchSnippet(row.Input, req.Query),
+			OutputSnippet: matchSnippet(output, req.Query),
+		})
+	}
+
+	common.Success(c, LogSearchResponse{
+		PaginationResponse: common.NewPaginationResponse(items, total, params),
+		IODisabledCount:    ioDisabled,
+		BlobStoredCount:    blobStored,
+		Warnings:           warnings,
+	})
+}
+
+// escapeLike 转义 LIKE 模式中的通配符，关键词按字面匹配
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// matchSnippet 返回关键词（不区分大小写）首次出现位置前后的片段，未直接命中（如全文检索按词匹配）时返回开头部分
+func matchSnippet(text string, query string) string {
+	if text == "" {
+		return ""
+	}
+	runes := []rune(text)
+	pos := 0
+	if idx := strings.Index(strings.ToLower(text), strings.ToLower(query)); idx >= 0 && idx <= len(text) {
+		pos = utf8.RuneCountInString(text[:idx])
+	}
+	from := max(pos-logSearchSnippetRadius, 0)
+	to := min(pos+utf8.RuneCountInString(query)+logSearchSnippetRadius, len(runes))
+	snippet := string(runes[from:to])
+	if from > 0 {
+		snippet = "..." + snippet
+	}
+	if to < len(runes) {
+		snippet += "..."
+	}
+	return snippet
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/service"
+)
+
+// StreamLogs 以 SSE 实时推送新完成的请求日志，支持按 model/status 过滤
+func StreamLogs(c *gin.Context) {
+	filter := service.LogStreamFilter{
+		Model:  strings.TrimSpace(c.Query("model")),
+		Status: strings.TrimSpace(c.Query("status")),
+	}
+	switch filter.Status {
+	case "", "success", "error":
+	default:
+		common.BadRequest(c, "Invalid status filter: must be 'success' or 'error'")
+		return
+	}
+
+	sub, err := service.SubscribeLogs(filter)
+	if err != nil {
+		if errors.Is(err, service.ErrTooManyLogSubscribers) {
+			common.ErrorWithHttpStatus(c, http.StatusTooManyRequests, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		common.InternalServerError(c, err.Error())
+		return
+	}
+	defer service.UnsubscribeLogs(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// 定期发送注释行保活，避免代理因空闲断开连接
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event := <-sub.C:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: log\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/service"
+)
+
+// ResolveModelPrice 查看模型名称匹配到的价格记录（及经由的别名），未匹配时返回候选写法
+func ResolveModelPrice(c *gin.Context) {
+	model := strings.TrimSpace(c.Query("model"))
+	if model == "" {
+		common.BadRequest(c, "model is required")
+		return
+	}
+	res, err := service.ResolveModelPrice(c.Request.Context(), model)
+	if err != nil {
+		common.InternalServerError(c, "Failed to query model prices: "+err.Error())
+		return
+	}
+	common.Success(c, res)
+}
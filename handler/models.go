@@ -1,20 +1,25 @@
 package handler
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
 	"github.com/racio/llmio/common"
 	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
 	"github.com/racio/llmio/providers"
 	"github.com/racio/llmio/service"
+	"github.com/samber/lo"
 )
 
 func OpenAIModelsHandler(c *gin.Context) {
 	ctx := c.Request.Context()
-	models, err := service.ModelsByTypes(ctx, consts.StyleOpenAI, consts.StyleOpenAIRes)
+	models, err := service.ModelsByTypes(ctx, consts.StyleOpenAI, consts.StyleOpenAIRes, consts.StyleAzure, consts.StyleMistral, consts.StyleAnthropic, consts.StyleBedrock)
 	if err != nil {
 		common.InternalServerError(c, err.Error())
 		return
 	}
+	models = allowedModels(ctx, models)
 	resModels := make([]providers.Model, 0)
 	for _, model := range models {
 		resModels = append(resModels, providers.Model{
@@ -32,11 +37,12 @@ func OpenAIModelsHandler(c *gin.Context) {
 
 func AnthropicModelsHandler(c *gin.Context) {
 	ctx := c.Request.Context()
-	models, err := service.ModelsByTypes(ctx, consts.StyleAnthropic)
+	models, err := service.ModelsByTypes(ctx, consts.StyleAnthropic, consts.StyleBedrock)
 	if err != nil {
 		common.InternalServerError(c, err.Error())
 		return
 	}
+	models = allowedModels(ctx, models)
 	resModels := make([]providers.AnthropicModel, 0)
 	for _, model := range models {
 		resModels = append(resModels, providers.AnthropicModel{
@@ -69,6 +75,7 @@ func GeminiModelsHandler(c *gin.Context) {
 		common.InternalServerError(c, err.Error())
 		return
 	}
+	models = allowedModels(ctx, models)
 
 	resModels := make([]GeminiModel, 0, len(models))
 	for _, model := range models {
@@ -85,3 +92,11 @@ func GeminiModelsHandler(c *gin.Context) {
 		Models: resModels,
 	})
 }
+
+// allowedModels 按调用方 Key 的权限过滤模型列表，受限 Key 只返回其可调用的模型，管理员 Key 返回全部
+func allowedModels(ctx context.Context, list []models.Model) []models.Model {
+	return lo.Filter(list, func(model models.Model, _ int) bool {
+		ok, err := validateAuthKey(ctx, model.Name)
+		return err == nil && ok
+	})
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"github.com/samber/lo"
+)
+
+func TestAllowedModels(t *testing.T) {
+	list := []models.Model{{Name: "gpt-4o"}, {Name: "claude-sonnet"}, {Name: "gemini-2.5-pro"}}
+	names := func(list []models.Model) []string {
+		return lo.Map(list, func(m models.Model, _ int) string { return m.Name })
+	}
+
+	// 与鉴权中间件 checkAuthKey 写入的上下文保持一致
+	admin := context.WithValue(context.Background(), consts.ContextKeyAllowAllModel, true)
+	restricted := context.WithValue(context.Background(), consts.ContextKeyAllowAllModel, false)
+	restricted = context.WithValue(restricted, consts.ContextKeyAllowModels, []string{"claude-sonnet", "not-configured"})
+	empty := context.WithValue(context.Background(), consts.ContextKeyAllowAllModel, false)
+	empty = context.WithValue(empty, consts.ContextKeyAllowModels, []string(nil))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{"admin sees all", admin, []string{"gpt-4o", "claude-sonnet", "gemini-2.5-pro"}},
+		{"restricted key", restricted, []string{"claude-sonnet"}},
+		{"key without models", empty, []string{}},
+		{"unauthenticated", context.Background(), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(allowedModels(tt.ctx, list)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/service"
+	"gorm.io/gorm"
+)
+
+type PromptTemplateRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Content string `json:"content"`
+}
+
+// GetPromptTemplates 获取全部提示词模板
+func GetPromptTemplates(c *gin.Context) {
+	templates, err := gorm.G[models.PromptTemplate](models.DB).Order("id DESC").Find(c.Request.Context())
+	if err != nil {
+		common.InternalServerError(c, "Failed to query prompt templates: "+err.Error())
+		return
+	}
+	common.Success(c, templates)
+}
+
+// CreatePromptTemplate 创建提示词模板
+func CreatePromptTemplate(c *gin.Context) {
+	var req PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		common.BadRequest(c, "Name is required")
+		return
+	}
+
+	ctx := c.Request.Context()
+	count, err := gorm.G[models.PromptTemplate](models.DB).Where("name = ?", req.Name).Count(ctx, "id")
+	if err != nil {
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	if count > 0 {
+		common.BadRequest(c, "Prompt template: "+req.Name+" already exists")
+		return
+	}
+
+	template := models.PromptTemplate{
+		Name:    req.Name,
+		Content: req.Content,
+	}
+	if err := gorm.G[models.PromptTemplate](models.DB).Create(ctx, &template); err != nil {
+		common.InternalServerError(c, "Failed to create prompt template: "+err.Error())
+		return
+	}
+	service.InvalidatePromptTemplateCache()
+	common.Success(c, template)
+}
+
+// UpdatePromptTemplate 更新提示词模板
+func UpdatePromptTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID format")
+		return
+	}
+
+	var req PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		common.BadRequest(c, "Name is required")
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := gorm.G[models.PromptTemplate](models.DB).Where("id = ?", id).First(ctx); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.NotFound(c, "Prompt template not found")
+			return
+		}
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+
+	count, err := gorm.G[models.PromptTemplate](models.DB).Where("name = ? AND id <> ?", req.Name, id).Count(ctx, "id")
+	if err != nil {
+		common.InternalServerError(c, "Database error: "+err.Error())
+		return
+	}
+	if count > 0 {
+		common.BadRequest(c, "Prompt template: "+req.Name+" already exists")
+		return
+	}
+
+	// 逐列更新，允许将内容清空
+	for col, val := range map[string]string{"name": req.Name, "content": req.Content} {
+		if _, err := gorm.G[models.PromptTemplate](models.DB).Where("id = ?", id).Update(ctx, col, val); err != nil {
+			common.InternalServerError(c, "Failed to update prompt template: "+err.Error())
+			return
+		}
+	}
+
+	service.InvalidatePromptTemplateCache()
+
+	updated, err := gorm.G[models.PromptTemplate](models.DB).Where("id = ?", id).First(ctx)
+	if err != nil {
+		common.InternalServerError(c, "Failed to retrieve updated prompt template: "+err.Error())
+		return
+	}
+	common.Success(c, updated)
+}
+
+// DeletePromptTemplate 删除提示词模板
+func DeletePromptTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "Invalid ID format")
+		return
+	}
+	if _, err := gorm.G[models.PromptTemplate](models.DB).Where("id = ?", id).Delete(c.Request.Context()); err != nil {
+		common.InternalServerError(c, "Failed to delete prompt template: "+err.Error())
+		return
+	}
+	service.InvalidatePromptTemplateCache()
+	common.SuccessWithMessage(c, "Deleted", gin.H{"id": id})
+}
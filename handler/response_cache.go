@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/service"
+)
+
+// cachedResponseWriter 捕获下发给客户端的非流式响应体，响应完整后写入响应缓存
+type cachedResponseWriter struct {
+	key      string
+	ttl      time.Duration
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *cachedResponseWriter) Write(p []byte) (int, error) {
+	if !w.overflow {
+		if w.body.Len()+len(p) > service.MaxCachedResponseSize {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// save 仅缓存状态码为 200 且未超出大小限制的响应，log 为产生该响应的上游请求记录
+func (w *cachedResponseWriter) save(ctx context.Context, statusCode int, header http.Header, log *models.ChatLog) {
+	if w.overflow || statusCode != http.StatusOK {
+		return
+	}
+	resp := &service.CachedResponse{Header: header.Clone(), Body: w.body.Bytes(), ProviderName: log.ProviderName, ProviderModel: log.ProviderModel}
+	if err := service.SaveCachedResponse(ctx, w.key, resp, w.ttl); err != nil {
+		slog.Error("save response cache error", "error", err)
+	}
+}
+
+// lookupResponseCache 模型开启响应缓存时查询相同请求的缓存：
+// 命中时直接返回缓存响应并返回 handled=true；未命中时返回用于写入缓存的 writer（缓存不可用时为 nil）
+func lookupResponseCache(c *gin.Context, before *service.Before, logStyle string, body []byte, ttl time.Duration) (*cachedResponseWriter, bool) {
+	if ttl <= 0 || before.Stream {
+		return nil, false
+	}
+	ctx := c.Request.Context()
+	key, err := service.ResponseCacheKey(logStyle, before.Model, body)
+	if err != nil {
+		return nil, false
+	}
+	resp, err := service.GetCachedResponse(ctx, key)
+	if err != nil {
+		// 缓存不可用时不影响正常请求
+		slog.Warn("response cache lookup failed", "error", err)
+		return nil, false
+	}
+	if resp == nil {
+		c.Header(service.ResponseCacheHeader, "MISS")
+		return &cachedResponseWriter{key: key, ttl: ttl}, false
+	}
+
+	authKeyID, _ := ctx.Value(consts.ContextKeyAuthKeyID).(uint)
+	// 与 Idempotency-Key 重放（Dedup）分开标记
+	if _, err := service.SaveChatLog(ctx, models.ChatLog{
+		Name:          before.Model,
+		ProviderName:  resp.ProviderName,
+		ProviderModel: resp.ProviderModel,
+		Status:        "success",
+		Style:         logStyle,
+		UserAgent:     c.Request.UserAgent(),
+		RemoteIP:      c.ClientIP(),
+		AuthKeyID:     authKeyID,
+		CacheHit:      1,
+	}); err != nil {
+		slog.Error("save cached chat log error", "error", err)
+	}
+
+	c.Header(service.ResponseCacheHeader, "HIT")
+	writeHeader(c, false, resp.Header)
+	if _, err := c.Writer.Write(resp.Body); err != nil {
+		slog.Error("write cached response error", "error", err)
+	}
+	return nil, true
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/service"
+)
+
+// selfCheckProvider 自检时每个提供商选取的一条启用关联
+type selfCheckProvider struct {
+	ProviderID   uint
+	ProviderName string
+	MwpID        uint
+}
+
+// RunSelfCheck 启动自检：数据库连接、兼容性数据修复、Redis 以及所有启用提供商的连通性
+// 结果逐项写入 w，存在关键项失败时返回 false
+func RunSelfCheck(ctx context.Context, w io.Writer) bool {
+	ok := true
+	report := func(name string, passed bool, detail string) {
+		mark := "OK"
+		if !passed {
+			mark = "FAIL"
+			ok = false
+		}
+		fmt.Fprintf(w, "[%-4s] %-32s %s\n", mark, name, detail)
+	}
+
+	db := checkDatabaseHealth()
+	report("database", db.Status != "unhealthy", componentDetail(db))
+	if db.Status == "unhealthy" {
+		return false
+	}
+	report("migrations", models.MigrationsDone(), "")
+
+	redisStatus := checkRedisHealth()
+	// 初始化时 Redis 连接失败会回退到内存存储，自检中视为失败
+	if strings.TrimSpace(os.Getenv("REDIS_URL")) != "" && service.GetRedisClient() == nil {
+		redisStatus = ComponentStatus{Status: "unhealthy", Message: stringPtr("REDIS_URL is set but connection failed")}
+	}
+	report("redis", redisStatus.Status != "unhealthy", componentDetail(redisStatus))
+
+	list, err := selfCheckProviders(ctx)
+	if err != nil {
+		report("providers", false, err.Error())
+		return false
+	}
+	if len(list) == 0 {
+		report("providers", true, "no enabled providers")
+	}
+	for _, p := range list {
+		name := fmt.Sprintf("provider %s", p.ProviderName)
+		chatModel, err := FindChatModel(ctx, fmt.Sprint(p.MwpID))
+		if err != nil {
+			report(name, false, err.Error())
+			continue
+		}
+		start := time.Now()
+		if _, testErr := testChatModel(ctx, chatModel, http.Header{}); testErr != nil {
+			report(name, false, fmt.Sprintf("model=%s %s", chatModel.Model, testErr.message))
+			continue
+		}
+		report(name, true, fmt.Sprintf("model=%s %dms", chatModel.Model, time.Since(start).Milliseconds()))
+	}
+	return ok
+}
+
+// selfCheckProviders 返回每个提供商下第一条启用的关联（所属模型也需启用）
+func selfCheckProviders(ctx context.Context) ([]selfCheckProvider, error) {
+	var rows []selfCheckProvider
+	if err := models.DB.WithContext(ctx).
+		Table("model_with_providers").
+		Select("DISTINCT ON (providers.id) providers.id AS provider_id, providers.name AS provider_name, model_with_providers.id AS mwp_id").
+		Joins("JOIN providers ON providers.id = model_with_providers.provider_id AND providers.deleted_at IS NULL").
+		Joins("JOIN models ON models.id = model_with_providers.model_id AND models.deleted_at IS NULL").
+		Where("model_with_providers.deleted_at IS NULL AND model_with_providers.status = 1 AND models.status = 1").
+		Order("providers.id, model_with_providers.id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func componentDetail(status ComponentStatus) string {
+	parts := []string{status.Status}
+	if status.ResponseTimeMs != nil {
+		parts = append(parts, fmt.Sprintf("%dms", *status.ResponseTimeMs))
+	}
+	if status.Message != nil {
+		parts = append(parts, *status.Message)
+	}
+	return strings.Join(parts, " ")
+}
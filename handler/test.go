@@ -21,6 +21,7 @@ import (
 	"github.com/racio/llmio/providers"
 	"github.com/racio/llmio/service"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 	"gorm.io/gorm"
 )
 
@@ -117,39 +118,105 @@ func ProviderTestHandler(c *gin.Context) {
 		common.InternalServerError(c, "Database error")
 		return
 	}
+	if err := applyTestOverride(c, chatModel); err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
 
-	// Create the provider instance
-	providerInstance, err := providers.New(chatModel.Type, chatModel.Config)
+	content, testErr := testChatModel(ctx, chatModel, c.Request.Header)
+	if testErr != nil {
+		switch testErr.code {
+		case http.StatusBadRequest:
+			common.BadRequest(c, testErr.message)
+		case http.StatusInternalServerError:
+			common.InternalServerError(c, testErr.message)
+		default:
+			common.ErrorWithHttpStatus(c, http.StatusOK, testErr.code, testErr.message)
+		}
+		return
+	}
+
+	common.SuccessWithMessage(c, content, nil)
+}
+
+// ProviderConnectivityResult 提供商基础连通性测试结果
+type ProviderConnectivityResult struct {
+	Reachable  bool   `json:"reachable"`
+	LatencyMs  int64  `json:"latency_ms"`
+	ModelCount int    `json:"model_count"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ProviderConnectivityHandler 按提供商配置调用模型列表接口测试基础连通性，不依赖任何模型关联
+func ProviderConnectivityHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	provider, err := gorm.G[models.Provider](models.DB).Where("id = ?", c.Param("id")).First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.NotFound(c, "Provider not found")
+			return
+		}
+		common.InternalServerError(c, "Database error")
+		return
+	}
+	providerInstance, err := providers.New(provider.Type, provider.Config)
 	if err != nil {
 		common.BadRequest(c, "Failed to create provider: "+err.Error())
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	start := time.Now()
+	modelList, err := providerInstance.Models(ctx)
+	result := ProviderConnectivityResult{
+		Reachable:  err == nil,
+		LatencyMs:  time.Since(start).Milliseconds(),
+		ModelCount: len(modelList),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	common.Success(c, result)
+}
+
+// providerTestError 连通性测试失败信息，code 沿用接口返回的业务码
+type providerTestError struct {
+	code    int
+	message string
+}
+
+// testChatModel 向模型关联的提供商发送一条测试请求，成功时返回上游响应内容
+func testChatModel(ctx context.Context, chatModel *ChatModel, reqHeader http.Header) (string, *providerTestError) {
+	// Create the provider instance
+	providerInstance, err := providers.New(chatModel.Type, chatModel.Config)
+	if err != nil {
+		return "", &providerTestError{http.StatusBadRequest, "Failed to create provider: " + err.Error()}
+	}
+
 	// Test connectivity by fetching models
 	responseHeaderTimeout := time.Second * time.Duration(30)
 	var testBody []byte
 	switch chatModel.Type {
-	case consts.StyleOpenAI:
+	case consts.StyleOpenAI, consts.StyleAzure, consts.StyleMistral:
 		testBody = []byte(testOpenAI)
-	case consts.StyleAnthropic:
+	case consts.StyleAnthropic, consts.StyleBedrock:
 		testBody = []byte(testAnthropic)
 	case consts.StyleOpenAIRes:
 		testBody = []byte(testOpenAIRes)
 	case consts.StyleGemini:
 		testBody = []byte(testGemini)
 	default:
-		common.BadRequest(c, "Invalid provider type")
-		return
+		return "", &providerTestError{http.StatusBadRequest, "Invalid provider type"}
 	}
 	withHeader := false
 	if chatModel.WithHeader != nil {
 		withHeader = *chatModel.WithHeader
 	}
-	header := service.BuildHeaders(c.Request.Header, withHeader, chatModel.CustomerHeaders, false)
+	header := service.BuildHeaders(reqHeader, withHeader, chatModel.CustomerHeaders, false)
 	extraHeaders, err := loadHeadersFromFile("headers.json")
 	if err != nil {
-		common.InternalServerError(c, "Failed to load headers.json: "+err.Error())
-		return
+		return "", &providerTestError{http.StatusInternalServerError, "Failed to load headers.json: " + err.Error()}
 	}
 	if header == nil {
 		header = http.Header{}
@@ -161,31 +228,26 @@ func ProviderTestHandler(c *gin.Context) {
 	})
 	req, err := providerInstance.BuildReq(ctx, header, chatModel.Model, []byte(testBody))
 	if err != nil {
-		common.ErrorWithHttpStatus(c, http.StatusOK, 502, "Failed to connect to provider: "+err.Error())
-		return
+		return "", &providerTestError{502, "Failed to connect to provider: " + err.Error()}
 	}
 	client := &http.Client{
 		Timeout: responseHeaderTimeout,
 	}
 	res, err := client.Do(req)
 	if err != nil {
-		common.ErrorWithHttpStatus(c, http.StatusOK, 502, "Failed to connect to provider: "+err.Error())
-		return
+		return "", &providerTestError{502, "Failed to connect to provider: " + err.Error()}
 	}
 	defer res.Body.Close()
 
 	content, err := io.ReadAll(res.Body)
 	if err != nil {
-		common.ErrorWithHttpStatus(c, http.StatusOK, res.StatusCode, "Failed to send request: "+err.Error())
-		return
+		return "", &providerTestError{res.StatusCode, "Failed to send request: " + err.Error()}
 	}
 
 	if res.StatusCode != http.StatusOK {
-		common.ErrorWithHttpStatus(c, http.StatusOK, res.StatusCode, fmt.Sprintf("code: %d body: %s", res.StatusCode, string(content)))
-		return
+		return "", &providerTestError{res.StatusCode, fmt.Sprintf("code: %d body: %s", res.StatusCode, string(content))}
 	}
-
-	common.SuccessWithMessage(c, string(content), nil)
+	return string(content), nil
 }
 
 func TestReactHandler(c *gin.Context) {
@@ -205,14 +267,23 @@ func TestReactHandler(c *gin.Context) {
 		common.InternalServerError(c, "Database error")
 		return
 	}
+	if err := applyTestOverride(c, chatModel); err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
 
 	if chatModel.Type != consts.StyleOpenAI {
 		c.SSEvent("error", "该测试仅支持 OpenAI 类型")
 		return
 	}
 
+	rawConfig, err := providers.ResolveConfig(chatModel.Config)
+	if err != nil {
+		common.ErrorWithHttpStatus(c, http.StatusBadRequest, 400, err.Error())
+		return
+	}
 	var config providers.OpenAI
-	if err := json.Unmarshal([]byte(chatModel.Config), &config); err != nil {
+	if err := json.Unmarshal([]byte(rawConfig), &config); err != nil {
 		common.ErrorWithHttpStatus(c, http.StatusBadRequest, 400, "Invalid config format")
 		return
 	}
@@ -390,6 +461,40 @@ type ChatModel struct {
 	CustomerHeaders map[string]string `json:"customer_headers,omitempty"`
 }
 
+// ProviderTestOverride 测试时临时替换的提供商凭据（如轮换前验证新 Key），只作用于本次测试，不会保存
+type ProviderTestOverride struct {
+	APIKey  string `json:"api_key"`
+	BaseURL string `json:"base_url"`
+}
+
+// applyTestOverride 读取 POST 请求体中的 api_key/base_url，覆盖到内存中的提供商配置副本上；GET 或空请求体时不做修改
+func applyTestOverride(c *gin.Context, chatModel *ChatModel) error {
+	if c.Request.Method != http.MethodPost || c.Request.ContentLength == 0 {
+		return nil
+	}
+	var override ProviderTestOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	config := chatModel.Config
+	if strings.TrimSpace(config) == "" {
+		config = "{}"
+	}
+	var err error
+	if apiKey := strings.TrimSpace(override.APIKey); apiKey != "" {
+		if config, err = sjson.Set(config, "api_key", apiKey); err != nil {
+			return fmt.Errorf("invalid provider config: %w", err)
+		}
+	}
+	if baseURL := strings.TrimSpace(override.BaseURL); baseURL != "" {
+		if config, err = sjson.Set(config, "base_url", baseURL); err != nil {
+			return fmt.Errorf("invalid provider config: %w", err)
+		}
+	}
+	chatModel.Config = config
+	return nil
+}
+
 func FindChatModel(ctx context.Context, id string) (*ChatModel, error) {
 	// Get ModelWithProvider by ID
 	modelWithProvider, err := gorm.G[models.ModelWithProvider](models.DB).Where("id = ?", id).First(ctx)
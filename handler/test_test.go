@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// stubRecordsDB 将 models.DB 替换为按表名返回固定记录的会话（records 的值为记录切片，查询只按 filterRows 支持的条件过滤），
+// UPDATE 的 SET 子句应用到该表的全部记录，其它写入不执行；返回的函数获取已发出的写入语句
+func stubRecordsDB(t *testing.T, records map[string]any) func() []string {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1"), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu     sync.Mutex
+		writes []string
+	)
+	record := func(tx *gorm.DB) {
+		mu.Lock()
+		defer mu.Unlock()
+		writes = append(writes, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	}
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Query().Replace("gorm:query", func(tx *gorm.DB) {
+			dest := reflect.ValueOf(tx.Statement.Dest).Elem()
+			rows := filterRows(tx, reflect.ValueOf(records[tx.Statement.Table]))
+			switch {
+			case dest.Kind() == reflect.Slice:
+				if rows.IsValid() {
+					dest.Set(rows)
+					tx.RowsAffected = int64(rows.Len())
+				}
+			case dest.Kind() == reflect.Struct:
+				if !rows.IsValid() || rows.Len() == 0 {
+					_ = tx.AddError(gorm.ErrRecordNotFound)
+					return
+				}
+				dest.Set(rows.Index(0))
+				tx.RowsAffected = 1
+			}
+		}),
+		cb.Create().After("gorm:create").Register("test:capture", record),
+		cb.Update().After("gorm:update").Register("test:capture", record),
+		cb.Update().Before("gorm:update").Register("test:apply", func(tx *gorm.DB) {
+			rows := reflect.ValueOf(records[tx.Statement.Table])
+			if !rows.IsValid() || tx.Statement.Schema == nil {
+				return
+			}
+			// 与 gorm:update 相同的 SET 计算：struct 更新忽略 0 值
+			for _, assignment := range callbacks.ConvertToAssignments(tx.Statement) {
+				field := tx.Statement.Schema.LookUpField(assignment.Column.Name)
+				if field == nil {
+					continue
+				}
+				for i := range rows.Len() {
+					if err := field.Set(tx.Statement.Context, rows.Index(i), assignment.Value); err != nil {
+						_ = tx.AddError(err)
+					}
+				}
+			}
+		}),
+		cb.Delete().After("gorm:delete").Register("test:capture", record),
+		cb.Raw().After("gorm:raw").Register("test:capture", record),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	orig := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = orig })
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(writes)
+	}
+}
+
+var simpleCondition = regexp.MustCompile(`^(\w+) (=|IN) \?$`)
+
+// filterRows 按 "col = ?" 与 "col IN ?" 形式的查询条件过滤记录，其它条件忽略
+func filterRows(tx *gorm.DB, rows reflect.Value) reflect.Value {
+	where, ok := tx.Statement.Clauses["WHERE"].Expression.(clause.Where)
+	if !rows.IsValid() || !ok || tx.Statement.Schema == nil {
+		return rows
+	}
+	kept := reflect.MakeSlice(rows.Type(), 0, rows.Len())
+	for i := range rows.Len() {
+		if rowMatches(tx, rows.Index(i), where.Exprs) {
+			kept = reflect.Append(kept, rows.Index(i))
+		}
+	}
+	return kept
+}
+
+func rowMatches(tx *gorm.DB, row reflect.Value, exprs []clause.Expression) bool {
+	for _, expr := range exprs {
+		e, ok := expr.(clause.Expr)
+		if !ok || len(e.Vars) != 1 {
+			continue
+		}
+		m := simpleCondition.FindStringSubmatch(e.SQL)
+		if m == nil {
+			continue
+		}
+		field := tx.Statement.Schema.LookUpField(m[1])
+		if field == nil {
+			continue
+		}
+		value, _ := field.ValueOf(tx.Statement.Context, row)
+		got := fmt.Sprint(value)
+		if m[2] == "=" {
+			if got != fmt.Sprint(e.Vars[0]) {
+				return false
+			}
+			continue
+		}
+		candidates := reflect.ValueOf(e.Vars[0])
+		found := false
+		for j := range candidates.Len() {
+			if fmt.Sprint(candidates.Index(j).Interface()) == got {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProviderTestHandlerOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// testChatModel 从工作目录读取 headers.json
+	t.Chdir("..")
+
+	var staleHits atomic.Int64
+	stale := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		staleHits.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer stale.Close()
+
+	var gotAuth, gotPath string
+	candidate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"yes"}}]}`))
+	}))
+	defer candidate.Close()
+
+	storedConfig := `{"base_url":"` + stale.URL + `/v1","api_key":"old-key"}`
+	provider := models.Provider{Name: "p", Type: consts.StyleOpenAI, Config: storedConfig}
+	provider.ID = 3
+	mp := models.ModelWithProvider{ProviderID: 3, ProviderModel: "gpt-4o"}
+	mp.ID = 5
+	writes := stubRecordsDB(t, map[string]any{"providers": []models.Provider{provider}, "model_with_providers": []models.ModelWithProvider{mp}})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	body := `{"api_key":"new-key","base_url":"` + candidate.URL + `/v1"}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/test/5", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "5"}}
+
+	ProviderTestHandler(c)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `yes`) {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if gotAuth != "Bearer new-key" || gotPath != "/v1/chat/completions" {
+		t.Fatalf("candidate got auth %q path %q, want override key on /v1/chat/completions", gotAuth, gotPath)
+	}
+	if staleHits.Load() != 0 {
+		t.Fatalf("stored base_url received %d requests, want 0", staleHits.Load())
+	}
+
+	// 覆盖只作用于本次测试：未发出任何写入，重新读取的提供商配置不变
+	if got := writes(); len(got) != 0 {
+		t.Fatalf("unexpected writes: %v", got)
+	}
+	reloaded, err := gorm.G[models.Provider](models.DB).Where("id = ?", provider.ID).First(c.Request.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Config != storedConfig {
+		t.Fatalf("provider config = %s, want %s", reloaded.Config, storedConfig)
+	}
+}
@@ -15,11 +15,21 @@ CREATE TABLE IF NOT EXISTS providers (
     config TEXT NOT NULL DEFAULT '{}',
     console VARCHAR(500) NOT NULL DEFAULT '',
     rpm_limit INTEGER NOT NULL DEFAULT 0,
+    tpm_limit INTEGER NOT NULL DEFAULT 0,
     ip_lock_minutes INTEGER NOT NULL DEFAULT 0,
+    keep_warm INTEGER NOT NULL DEFAULT 0,
+    max_concurrency INTEGER NOT NULL DEFAULT 0,
+    success_status_codes VARCHAR(255) NOT NULL DEFAULT '',
+    disabled_until TIMESTAMPTZ,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     deleted_at TIMESTAMPTZ
 );
+ALTER TABLE providers ADD COLUMN IF NOT EXISTS keep_warm INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE providers ADD COLUMN IF NOT EXISTS tpm_limit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE providers ADD COLUMN IF NOT EXISTS max_concurrency INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE providers ADD COLUMN IF NOT EXISTS success_status_codes VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE providers ADD COLUMN IF NOT EXISTS disabled_until TIMESTAMPTZ;
 
 -- 创建 models 表
 CREATE TABLE IF NOT EXISTS models (
@@ -32,11 +42,43 @@ CREATE TABLE IF NOT EXISTS models (
     strategy VARCHAR(50) NOT NULL DEFAULT 'lottery',
     breaker INTEGER NOT NULL DEFAULT 0,
     status INTEGER NOT NULL DEFAULT 1,
+    max_input_tokens INTEGER NOT NULL DEFAULT 0,
+    token_lock_seconds INTEGER NOT NULL DEFAULT 0,
+    max_providers_per_request INTEGER NOT NULL DEFAULT 0,
+    auto_weight INTEGER NOT NULL DEFAULT 0,
+    cache_ttl_seconds INTEGER NOT NULL DEFAULT 0,
+    min_weight INTEGER NOT NULL DEFAULT 0,
+    breaker_max_failures INTEGER NOT NULL DEFAULT 0,
+    breaker_sleep_seconds INTEGER NOT NULL DEFAULT 0,
+    breaker_half_open_requests INTEGER NOT NULL DEFAULT 0,
+    fallback_model VARCHAR(255) NOT NULL DEFAULT '',
+    stream_max_seconds INTEGER NOT NULL DEFAULT 0,
+    max_thinking_tokens INTEGER NOT NULL DEFAULT 0,
+    max_reasoning_effort VARCHAR(32) NOT NULL DEFAULT '',
+    max_completion_tokens INTEGER NOT NULL DEFAULT 0,
+    echo_model INTEGER NOT NULL DEFAULT 0,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     deleted_at TIMESTAMPTZ
 );
 ALTER TABLE models ADD COLUMN IF NOT EXISTS status INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS max_input_tokens INTEGER NOT NULL DEFAULT 0;
+-- 升级前 token 锁固定开启（120 秒）：新增列时已有模型回填为 120 以保持原行为，之后新建模型默认关闭
+ALTER TABLE models ADD COLUMN IF NOT EXISTS token_lock_seconds INTEGER NOT NULL DEFAULT 120;
+ALTER TABLE models ALTER COLUMN token_lock_seconds SET DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS max_providers_per_request INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS auto_weight INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS cache_ttl_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS min_weight INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS breaker_max_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS breaker_sleep_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS breaker_half_open_requests INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS fallback_model VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE models ADD COLUMN IF NOT EXISTS stream_max_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS max_thinking_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS max_reasoning_effort VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE models ADD COLUMN IF NOT EXISTS max_completion_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS echo_model INTEGER NOT NULL DEFAULT 0;
 
 -- 创建 model_with_providers 表
 CREATE TABLE IF NOT EXISTS model_with_providers (
@@ -51,10 +93,20 @@ CREATE TABLE IF NOT EXISTS model_with_providers (
     status INTEGER NOT NULL DEFAULT 1,
     customer_headers TEXT NOT NULL DEFAULT '{}',
     weight INTEGER NOT NULL DEFAULT 1,
+    base_weight INTEGER NOT NULL DEFAULT 0,
+    shadow INTEGER NOT NULL DEFAULT 0,
+    shadow_rate DOUBLE PRECISION NOT NULL DEFAULT 0,
+    rpm_limit INTEGER NOT NULL DEFAULT 0,
+    exclude_rules TEXT NOT NULL DEFAULT '',
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     deleted_at TIMESTAMPTZ
 );
+ALTER TABLE model_with_providers ADD COLUMN IF NOT EXISTS shadow INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE model_with_providers ADD COLUMN IF NOT EXISTS shadow_rate DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE model_with_providers ADD COLUMN IF NOT EXISTS base_weight INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE model_with_providers ADD COLUMN IF NOT EXISTS rpm_limit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE model_with_providers ADD COLUMN IF NOT EXISTS exclude_rules TEXT NOT NULL DEFAULT '';
 
 -- 创建 auth_keys 表
 CREATE TABLE IF NOT EXISTS auth_keys (
@@ -67,10 +119,16 @@ CREATE TABLE IF NOT EXISTS auth_keys (
     expires_at TIMESTAMPTZ,
     usage_count BIGINT NOT NULL DEFAULT 0,
     last_used_at TIMESTAMPTZ,
+    max_concurrency INTEGER NOT NULL DEFAULT 0,
+    rpm_limit INTEGER NOT NULL DEFAULT 0,
+    monthly_budget DOUBLE PRECISION NOT NULL DEFAULT 0,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     deleted_at TIMESTAMPTZ
 );
+ALTER TABLE auth_keys ADD COLUMN IF NOT EXISTS max_concurrency INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE auth_keys ADD COLUMN IF NOT EXISTS rpm_limit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE auth_keys ADD COLUMN IF NOT EXISTS monthly_budget DOUBLE PRECISION NOT NULL DEFAULT 0;
 
 -- 创建 configs 表
 CREATE TABLE IF NOT EXISTS configs (
@@ -100,6 +158,7 @@ CREATE TABLE IF NOT EXISTS model_prices (
 CREATE TABLE IF NOT EXISTS chat_logs (
     id SERIAL PRIMARY KEY,
     uuid VARCHAR(255) NOT NULL UNIQUE,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
     name VARCHAR(255) NOT NULL,
     provider_model VARCHAR(255) NOT NULL DEFAULT '',
     provider_name VARCHAR(255) NOT NULL DEFAULT '',
@@ -109,6 +168,10 @@ CREATE TABLE IF NOT EXISTS chat_logs (
     remote_ip VARCHAR(45) NOT NULL DEFAULT '',
     auth_key_id INTEGER NOT NULL DEFAULT 0,
     chat_io INTEGER NOT NULL DEFAULT 0,
+    dedup INTEGER NOT NULL DEFAULT 0,
+    cache_hit INTEGER NOT NULL DEFAULT 0,
+    timeline TEXT NOT NULL DEFAULT '',
+    fallback_from VARCHAR(255) NOT NULL DEFAULT '',
     error TEXT NOT NULL DEFAULT '',
     retry INTEGER NOT NULL DEFAULT 0,
     proxy_time_ms INTEGER NOT NULL DEFAULT 0,
@@ -126,6 +189,11 @@ CREATE TABLE IF NOT EXISTS chat_logs (
     deleted_at TIMESTAMPTZ
 );
 ALTER TABLE chat_logs ADD COLUMN IF NOT EXISTS total_cost DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE chat_logs ADD COLUMN IF NOT EXISTS dedup INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chat_logs ADD COLUMN IF NOT EXISTS cache_hit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chat_logs ADD COLUMN IF NOT EXISTS timeline TEXT NOT NULL DEFAULT '';
+ALTER TABLE chat_logs ADD COLUMN IF NOT EXISTS request_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE chat_logs ADD COLUMN IF NOT EXISTS fallback_from VARCHAR(255) NOT NULL DEFAULT '';
 
 -- 创建 chat_io 表
 CREATE TABLE IF NOT EXISTS chat_io (
@@ -134,10 +202,50 @@ CREATE TABLE IF NOT EXISTS chat_io (
     input TEXT NOT NULL DEFAULT '',
     output_string TEXT NOT NULL DEFAULT '',
     output_string_array TEXT NOT NULL DEFAULT '[]',
+    blob_key TEXT NOT NULL DEFAULT '',
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     deleted_at TIMESTAMPTZ
 );
+ALTER TABLE chat_io ADD COLUMN IF NOT EXISTS blob_key TEXT NOT NULL DEFAULT '';
+-- IO 内容全文检索（POST /api/logs/search 的 fulltext 模式），表达式需与 handler/log_search.go 保持一致
+CREATE INDEX IF NOT EXISTS idx_chat_io_fts ON chat_io USING GIN (to_tsvector('simple', left(input, 100000) || ' ' || left(output_string, 100000) || ' ' || left(output_string_array, 100000)));
+
+-- 创建 shadow_logs 表（影子提供商对比记录）
+CREATE TABLE IF NOT EXISTS shadow_logs (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    provider_model VARCHAR(255) NOT NULL DEFAULT '',
+    provider_name VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(50) NOT NULL DEFAULT '',
+    style VARCHAR(100) NOT NULL DEFAULT '',
+    auth_key_id INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    proxy_time_ms INTEGER NOT NULL DEFAULT 0,
+    first_chunk_time_ms INTEGER NOT NULL DEFAULT 0,
+    chunk_time_ms INTEGER NOT NULL DEFAULT 0,
+    tps REAL NOT NULL DEFAULT 0,
+    size INTEGER NOT NULL DEFAULT 0,
+    prompt_tokens BIGINT NOT NULL DEFAULT 0,
+    completion_tokens BIGINT NOT NULL DEFAULT 0,
+    total_tokens BIGINT NOT NULL DEFAULT 0,
+    prompt_tokens_details TEXT NOT NULL DEFAULT '{}',
+    total_cost DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ
+);
+
+-- 创建 prompt_templates 表（服务端提示词模板）
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_templates_name ON prompt_templates(name) WHERE deleted_at IS NULL;
 
 -- 创建索引（如果不存在）
 CREATE INDEX IF NOT EXISTS idx_providers_deleted_at ON providers(deleted_at);
@@ -162,6 +270,7 @@ CREATE INDEX IF NOT EXISTS idx_configs_key ON configs(key);
 CREATE INDEX IF NOT EXISTS idx_configs_deleted_at ON configs(deleted_at);
 
 CREATE INDEX IF NOT EXISTS idx_chat_logs_uuid ON chat_logs(uuid);
+CREATE INDEX IF NOT EXISTS idx_chat_logs_request_id ON chat_logs(request_id);
 CREATE INDEX IF NOT EXISTS idx_chat_logs_name ON chat_logs(name);
 CREATE INDEX IF NOT EXISTS idx_chat_logs_provider_name ON chat_logs(provider_name);
 CREATE INDEX IF NOT EXISTS idx_chat_logs_status ON chat_logs(status);
@@ -175,6 +284,10 @@ CREATE INDEX IF NOT EXISTS idx_chat_logs_health_window
 ON chat_logs (provider_name, name, provider_model, created_at DESC)
 WHERE deleted_at IS NULL;
 
+CREATE INDEX IF NOT EXISTS idx_shadow_logs_name ON shadow_logs(name);
+CREATE INDEX IF NOT EXISTS idx_shadow_logs_provider_name ON shadow_logs(provider_name);
+CREATE INDEX IF NOT EXISTS idx_shadow_logs_created_at ON shadow_logs(created_at);
+
 CREATE INDEX IF NOT EXISTS idx_chat_io_log_id ON chat_io(log_id);
 CREATE INDEX IF NOT EXISTS idx_chat_io_deleted_at ON chat_io(deleted_at);
 
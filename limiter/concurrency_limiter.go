@@ -0,0 +1,124 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ConcurrencyLimiter 按 ID（AuthKey 或提供商）限制同时处理中的请求数（与 RPM 不同，只关心在途请求）
+type ConcurrencyLimiter struct {
+	redis  *redis.Client
+	scope  string // 计数维度，用于区分 Redis key，如 auth_key、provider
+	mu     sync.Mutex
+	memory map[uint]int // 内存存储，当Redis不可用时使用
+	// ttl Redis 计数的兜底过期时间，防止进程崩溃后未释放的计数永久占用
+	ttl time.Duration
+}
+
+// NewConcurrencyLimiter 创建新的并发限制器
+func NewConcurrencyLimiter(redisClient *redis.Client, scope string) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		redis:  redisClient,
+		scope:  scope,
+		memory: make(map[uint]int),
+		ttl:    time.Hour,
+	}
+}
+
+func (l *ConcurrencyLimiter) getKey(id uint) string {
+	return fmt.Sprintf("concurrency:%s:%d", l.scope, id)
+}
+
+// Acquire 尝试占用一个并发名额，达到上限时返回 false；limit <= 0 表示不限制
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, id uint, limit int) (bool, error) {
+	if limit <= 0 || id == 0 {
+		return true, nil
+	}
+	if l.redis != nil {
+		return l.acquireRedis(ctx, id, limit)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.memory[id] >= limit {
+		return false, nil
+	}
+	l.memory[id]++
+	return true, nil
+}
+
+// Release 释放一个并发名额，必须与成功的 Acquire 成对调用
+func (l *ConcurrencyLimiter) Release(ctx context.Context, id uint) error {
+	if id == 0 {
+		return nil
+	}
+	if l.redis != nil {
+		return l.releaseRedis(ctx, id)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.memory[id] <= 1 {
+		delete(l.memory, id)
+		return nil
+	}
+	l.memory[id]--
+	return nil
+}
+
+// Current 获取当前在途请求数
+func (l *ConcurrencyLimiter) Current(ctx context.Context, id uint) (int, error) {
+	if l.redis != nil {
+		count, err := l.redis.Get(ctx, l.getKey(id)).Int()
+		if err == redis.Nil {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: redis concurrency count failed: %w", ErrLimiterUnavailable, err)
+		}
+		return max(count, 0), nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.memory[id], nil
+}
+
+// acquireConcurrencyScript Lua 保证原子性：先自增，超过上限则回滚并拒绝
+var acquireConcurrencyScript = redis.NewScript(`
+local c = redis.call("INCR", KEYS[1])
+redis.call("EXPIRE", KEYS[1], ARGV[2])
+if c > tonumber(ARGV[1]) then
+  redis.call("DECR", KEYS[1])
+  return 0
+end
+return 1
+`)
+
+// releaseConcurrencyScript 计数归零（或兜底过期后出现负数）时直接删除
+var releaseConcurrencyScript = redis.NewScript(`
+local c = redis.call("DECR", KEYS[1])
+if c <= 0 then
+  redis.call("DEL", KEYS[1])
+end
+return c
+`)
+
+func (l *ConcurrencyLimiter) acquireRedis(ctx context.Context, id uint, limit int) (bool, error) {
+	res, err := acquireConcurrencyScript.Run(ctx, l.redis, []string{l.getKey(id)}, limit, int64(l.ttl.Seconds())).Int()
+	if err != nil {
+		return false, fmt.Errorf("%w: redis concurrency acquire failed: %w", ErrLimiterUnavailable, err)
+	}
+	return res == 1, nil
+}
+
+func (l *ConcurrencyLimiter) releaseRedis(ctx context.Context, id uint) error {
+	if err := releaseConcurrencyScript.Run(ctx, l.redis, []string{l.getKey(id)}).Err(); err != nil {
+		return fmt.Errorf("%w: redis concurrency release failed: %w", ErrLimiterUnavailable, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConcurrencyLimiterAcquireConcurrent(t *testing.T) {
+	l := NewConcurrencyLimiter(nil, "auth_key")
+	ctx := context.Background()
+	const limit = 3
+
+	var (
+		wg       sync.WaitGroup
+		acquired atomic.Int64
+	)
+	start := make(chan struct{})
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			ok, err := l.Acquire(ctx, 1, limit)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				acquired.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if acquired.Load() != limit {
+		t.Fatalf("acquired = %d, want %d", acquired.Load(), limit)
+	}
+	if n, _ := l.Current(ctx, 1); n != limit {
+		t.Fatalf("current = %d, want %d", n, limit)
+	}
+	// 其它 ID 互不影响
+	if ok, _ := l.Acquire(ctx, 2, limit); !ok {
+		t.Fatal("other id should not be limited")
+	}
+}
+
+func TestConcurrencyLimiterRelease(t *testing.T) {
+	l := NewConcurrencyLimiter(nil, "auth_key")
+	ctx := context.Background()
+
+	if ok, _ := l.Acquire(ctx, 1, 1); !ok {
+		t.Fatal("first acquire should succeed")
+	}
+	if ok, _ := l.Acquire(ctx, 1, 1); ok {
+		t.Fatal("second acquire should be rejected at limit 1")
+	}
+	if err := l.Release(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := l.Current(ctx, 1); n != 0 {
+		t.Fatalf("current after release = %d, want 0", n)
+	}
+	// 多余的 Release 不会使计数变为负数
+	if err := l.Release(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := l.Current(ctx, 1); n != 0 {
+		t.Fatalf("current after extra release = %d, want 0", n)
+	}
+	if ok, _ := l.Acquire(ctx, 1, 1); !ok {
+		t.Fatal("acquire after release should succeed")
+	}
+}
+
+func TestConcurrencyLimiterUnlimited(t *testing.T) {
+	l := NewConcurrencyLimiter(nil, "auth_key")
+	ctx := context.Background()
+	for range 10 {
+		if ok, _ := l.Acquire(ctx, 1, 0); !ok {
+			t.Fatal("limit 0 must not reject")
+		}
+		if ok, _ := l.Acquire(ctx, 0, 1); !ok {
+			t.Fatal("id 0 must not be limited")
+		}
+	}
+	if n, _ := l.Current(ctx, 1); n != 0 {
+		t.Fatalf("unlimited acquires should not be counted, got %d", n)
+	}
+}
@@ -138,7 +138,7 @@ func (l *IPLocker) checkIPAccessRedis(ctx context.Context, providerID uint, clie
 	}
 	if err != nil {
 		// Redis 出错：由上层决定 fail-open / fail-closed
-		return false, fmt.Errorf("%w: redis ip lock check failed: %v", ErrLimiterUnavailable, err)
+		return false, fmt.Errorf("%w: redis ip lock check failed: %w", ErrLimiterUnavailable, err)
 	}
 
 	var record IPLockRecord
@@ -167,7 +167,7 @@ func (l *IPLocker) recordIPAccessRedis(ctx context.Context, providerID uint, cli
 	// 检查是否已有记录
 	exists, err := l.redis.Exists(ctx, key).Result()
 	if err != nil {
-		return fmt.Errorf("%w: redis ip lock exists failed: %v", ErrLimiterUnavailable, err)
+		return fmt.Errorf("%w: redis ip lock exists failed: %w", ErrLimiterUnavailable, err)
 	}
 
 	if exists == 0 {
@@ -184,7 +184,7 @@ func (l *IPLocker) recordIPAccessRedis(ctx context.Context, providerID uint, cli
 		}
 
 		if err := l.redis.Set(ctx, key, data, time.Duration(lockMinutes)*time.Minute).Err(); err != nil {
-			return fmt.Errorf("%w: redis ip lock set failed: %v", ErrLimiterUnavailable, err)
+			return fmt.Errorf("%w: redis ip lock set failed: %w", ErrLimiterUnavailable, err)
 		}
 		return nil
 	}
@@ -198,7 +198,7 @@ func (l *IPLocker) getIPLockStatusRedis(ctx context.Context, key string) (*IPLoc
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("%w: redis ip lock get failed: %v", ErrLimiterUnavailable, err)
+		return nil, fmt.Errorf("%w: redis ip lock get failed: %w", ErrLimiterUnavailable, err)
 	}
 
 	var record IPLockRecord
@@ -2,9 +2,12 @@ package limiter
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,15 +15,32 @@ import (
 )
 
 // Manager 限流管理器
+// 配置了 Redis 时使用 Redis 计数，运行期 Redis 不可用时切换到进程内存计数，恢复后切回（见 redis_failover.go）
 type Manager struct {
-	rpmLimiter   *RPMLimiter
-	ipLocker     *IPLocker
-	tokenLocker  *TokenLocker
+	redisSet     *limiterSet // 未配置 Redis 时为 nil
+	memorySet    *limiterSet
+	redisDown    atomic.Bool
 	redisClient  *redis.Client
 	enabled      bool
 	redisTimeout time.Duration
+	// redisConfigured 配置了 Redis（REDIS_URL），redisClient 为 nil 说明 REDIS_URL 无法解析、只能使用进程内存计数
+	redisConfigured bool
+	rejections      *RejectionCounter
+	// strict 为 true 时（LIMITER_STRICT=true）降级状态下拒绝需要计数的请求，避免多副本各自计数使限制按副本数放大
+	strict bool
+	// 并发名额可能在 Redis 与内存之间切换时仍在途，按后端记账以便释放到占用时的后端
+	concMu       sync.Mutex
+	redisHeld    map[concurrencyKey]int // 通过 Redis 占用且尚未释放的名额
+	owedReleases map[concurrencyKey]int // Redis 不可用期间未能释放的 Redis 名额
 }
 
+// 限流计数后端
+const (
+	BackendRedis    = "redis"
+	BackendMemory   = "memory"
+	BackendDegraded = "degraded" // 配置了 Redis 但不可用，使用进程内存计数
+)
+
 // NewManager 创建新的限流管理器
 func NewManager(redisClient *redis.Client) *Manager {
 	redisTimeout := 300 * time.Millisecond
@@ -29,22 +49,50 @@ func NewManager(redisClient *redis.Client) *Manager {
 			redisTimeout = time.Duration(ms) * time.Millisecond
 		}
 	}
-	return &Manager{
-		rpmLimiter:   NewRPMLimiter(redisClient),
-		ipLocker:     NewIPLocker(redisClient),
-		tokenLocker:  NewTokenLocker(redisClient, 2*time.Minute),
+	m := &Manager{
+		memorySet:    newLimiterSet(nil),
 		redisClient:  redisClient,
 		enabled:      true,
 		redisTimeout: redisTimeout,
+		strict:       os.Getenv("LIMITER_STRICT") == "true",
+		rejections:   NewRejectionCounter(),
+		redisHeld:    make(map[concurrencyKey]int),
+		owedReleases: make(map[concurrencyKey]int),
 	}
+	if redisClient != nil {
+		m.redisSet = newLimiterSet(redisClient)
+	}
+	return m
+}
+
+// SetRedisConfigured 标记是否配置了 Redis，用于区分未配置（memory）与连接失败后的降级（degraded）
+func (m *Manager) SetRedisConfigured(configured bool) {
+	m.redisConfigured = configured
 }
 
-func (m *Manager) withRedisTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
-	// 内存模式不需要额外超时控制
-	if m.redisClient == nil {
-		return ctx, func() {}
+// Backend 返回当前使用的计数后端：redis / memory / degraded
+func (m *Manager) Backend() string {
+	switch {
+	case m.redisUp():
+		return BackendRedis
+	case m.redisConfigured:
+		return BackendDegraded
+	default:
+		return BackendMemory
 	}
-	return context.WithTimeout(ctx, m.redisTimeout)
+}
+
+// IsStrict 是否开启严格模式
+func (m *Manager) IsStrict() bool {
+	return m.strict
+}
+
+// checkStrict 严格模式下 Redis 降级时返回 ErrLimiterUnavailable，调用方按 fail-closed 拒绝
+func (m *Manager) checkStrict() error {
+	if m.strict && m.Backend() == BackendDegraded {
+		return fmt.Errorf("%w: redis configured but unavailable (LIMITER_STRICT)", ErrLimiterUnavailable)
+	}
+	return nil
 }
 
 // SetEnabled 设置限流器是否启用
@@ -57,8 +105,11 @@ func (m *Manager) IsEnabled() bool {
 	return m.enabled
 }
 
-// GetRedisClient 获取Redis客户端
+// GetRedisClient 获取Redis客户端，Redis 当前不可用时返回 nil，调用方按未配置 Redis 处理
 func (m *Manager) GetRedisClient() *redis.Client {
+	if !m.redisUp() {
+		return nil
+	}
 	return m.redisClient
 }
 
@@ -67,9 +118,9 @@ func (m *Manager) CheckRPMLimit(ctx context.Context, providerID uint, rpmLimit i
 	if !m.enabled {
 		return true, nil
 	}
-	ctx, cancel := m.withRedisTimeout(ctx)
-	defer cancel()
-	return m.rpmLimiter.CheckRPMLimit(ctx, providerID, rpmLimit)
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (bool, error) {
+		return set.rpm.CheckRPMLimit(ctx, providerID, rpmLimit)
+	})
 }
 
 // RecordRPMRequest 记录RPM请求
@@ -77,9 +128,92 @@ func (m *Manager) RecordRPMRequest(ctx context.Context, providerID uint) error {
 	if !m.enabled {
 		return nil
 	}
-	ctx, cancel := m.withRedisTimeout(ctx)
-	defer cancel()
-	return m.rpmLimiter.RecordRequest(ctx, providerID)
+	return m.run(ctx, func(ctx context.Context, set *limiterSet) error {
+		return set.rpm.RecordRequest(ctx, providerID)
+	})
+}
+
+// CheckModelProviderRPMLimit 检查模型-提供商关联的RPM限制
+func (m *Manager) CheckModelProviderRPMLimit(ctx context.Context, modelWithProviderID uint, rpmLimit int) (bool, error) {
+	if !m.enabled {
+		return true, nil
+	}
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (bool, error) {
+		return set.mwpRpm.CheckRPMLimit(ctx, modelWithProviderID, rpmLimit)
+	})
+}
+
+// RecordModelProviderRPMRequest 记录模型-提供商关联的RPM请求
+func (m *Manager) RecordModelProviderRPMRequest(ctx context.Context, modelWithProviderID uint) error {
+	if !m.enabled {
+		return nil
+	}
+	return m.run(ctx, func(ctx context.Context, set *limiterSet) error {
+		return set.mwpRpm.RecordRequest(ctx, modelWithProviderID)
+	})
+}
+
+// GetCurrentModelProviderRPMCount 获取模型-提供商关联当前RPM计数
+func (m *Manager) GetCurrentModelProviderRPMCount(ctx context.Context, modelWithProviderID uint) (int, error) {
+	if !m.enabled {
+		return 0, nil
+	}
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (int, error) {
+		return set.mwpRpm.GetCurrentRPMCount(ctx, modelWithProviderID)
+	})
+}
+
+// AllowKeyRequest 检查 AuthKey 的RPM限制，未超出时记录本次请求；limit <= 0 表示不限制
+func (m *Manager) AllowKeyRequest(ctx context.Context, authKeyID uint, limit int) (bool, error) {
+	if !m.enabled || limit <= 0 || authKeyID == 0 {
+		return true, nil
+	}
+	if err := m.checkStrict(); err != nil {
+		return false, err
+	}
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (bool, error) {
+		return set.keyRpm.AllowRequest(ctx, authKeyID, limit)
+	})
+}
+
+// GetKeyRPMCount 获取 AuthKey 当前RPM计数
+func (m *Manager) GetKeyRPMCount(ctx context.Context, authKeyID uint) (int, error) {
+	if !m.enabled {
+		return 0, nil
+	}
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (int, error) {
+		return set.keyRpm.GetCurrentRPMCount(ctx, authKeyID)
+	})
+}
+
+// CheckTPMLimit 检查TPM限制
+func (m *Manager) CheckTPMLimit(ctx context.Context, providerID uint, tpmLimit int) (bool, error) {
+	if !m.enabled {
+		return true, nil
+	}
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (bool, error) {
+		return set.tpm.CheckTPMLimit(ctx, providerID, tpmLimit)
+	})
+}
+
+// RecordProviderTokens 记录提供商一次成功响应消耗的 token（仅在配置了 TPM 限制时记录）
+func (m *Manager) RecordProviderTokens(ctx context.Context, providerID uint, tpmLimit int, tokens int64) error {
+	if !m.enabled || tpmLimit <= 0 {
+		return nil
+	}
+	return m.run(ctx, func(ctx context.Context, set *limiterSet) error {
+		return set.tpm.RecordTokens(ctx, providerID, tokens)
+	})
+}
+
+// GetCurrentTPM 获取当前TPM用量
+func (m *Manager) GetCurrentTPM(ctx context.Context, providerID uint) (int64, error) {
+	if !m.enabled {
+		return 0, nil
+	}
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (int64, error) {
+		return set.tpm.GetCurrentTPM(ctx, providerID)
+	})
 }
 
 // CheckIPAccess 检查IP访问权限
@@ -87,9 +221,9 @@ func (m *Manager) CheckIPAccess(ctx context.Context, providerID uint, clientIP s
 	if !m.enabled {
 		return true, nil
 	}
-	ctx, cancel := m.withRedisTimeout(ctx)
-	defer cancel()
-	return m.ipLocker.CheckIPAccess(ctx, providerID, clientIP, lockMinutes)
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (bool, error) {
+		return set.ipLocker.CheckIPAccess(ctx, providerID, clientIP, lockMinutes)
+	})
 }
 
 // RecordIPAccess 记录IP访问
@@ -97,14 +231,14 @@ func (m *Manager) RecordIPAccess(ctx context.Context, providerID uint, clientIP
 	if !m.enabled {
 		return nil
 	}
-	ctx, cancel := m.withRedisTimeout(ctx)
-	defer cancel()
-	return m.ipLocker.RecordIPAccess(ctx, providerID, clientIP, lockMinutes)
+	return m.run(ctx, func(ctx context.Context, set *limiterSet) error {
+		return set.ipLocker.RecordIPAccess(ctx, providerID, clientIP, lockMinutes)
+	})
 }
 
 // GetClientIP 获取客户端IP
 func (m *Manager) GetClientIP(c *gin.Context) string {
-	return m.ipLocker.GetClientIP(c)
+	return m.active().ipLocker.GetClientIP(c)
 }
 
 // GetRPMStats 获取RPM统计信息
@@ -112,10 +246,15 @@ func (m *Manager) GetRPMStats(ctx context.Context) map[string]interface{} {
 	if !m.enabled {
 		return map[string]interface{}{
 			"enabled": false,
+			"backend": m.Backend(),
+			"strict":  m.strict,
 		}
 	}
-	stats := m.rpmLimiter.GetStats(ctx)
+	stats := m.active().rpm.GetStats(ctx)
+	stats["model_providers"] = m.active().mwpRpm.GetStats(ctx)["providers"]
 	stats["enabled"] = true
+	stats["backend"] = m.Backend()
+	stats["strict"] = m.strict
 	return stats
 }
 
@@ -124,7 +263,9 @@ func (m *Manager) GetIPLockStatus(ctx context.Context, providerID uint) (*IPLock
 	if !m.enabled {
 		return nil, nil
 	}
-	return m.ipLocker.GetIPLockStatus(ctx, providerID)
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (*IPLockRecord, error) {
+		return set.ipLocker.GetIPLockStatus(ctx, providerID)
+	})
 }
 
 // ClearIPLock 清除IP锁定
@@ -132,30 +273,133 @@ func (m *Manager) ClearIPLock(ctx context.Context, providerID uint) error {
 	if !m.enabled {
 		return nil
 	}
-	return m.ipLocker.ClearIPLock(ctx, providerID)
+	return m.run(ctx, func(ctx context.Context, set *limiterSet) error {
+		return set.ipLocker.ClearIPLock(ctx, providerID)
+	})
+}
+
+// ListTokenLocks 列出当前生效的 token 锁
+func (m *Manager) ListTokenLocks(ctx context.Context) ([]TokenLockInfo, error) {
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) ([]TokenLockInfo, error) {
+		return set.tokenLocker.List(ctx)
+	})
+}
+
+// ClearTokenLock 清除指定 model_with_provider 的 token 锁
+func (m *Manager) ClearTokenLock(ctx context.Context, modelWithProviderID uint) error {
+	return m.run(ctx, func(ctx context.Context, set *limiterSet) error {
+		return set.tokenLocker.Clear(ctx, modelWithProviderID)
+	})
+}
+
+// ClearAllTokenLocks 清除全部 token 锁
+func (m *Manager) ClearAllTokenLocks(ctx context.Context) (int, error) {
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (int, error) {
+		return set.tokenLocker.ClearAll(ctx)
+	})
 }
 
-// CheckProviderLimits 检查提供商的所有限制
-func (m *Manager) CheckProviderLimits(ctx context.Context, c *gin.Context, providerID uint, rpmLimit, ipLockMinutes int, modelWithProviderID uint, tokenID uint) (bool, string, error) {
+// AcquireKeyConcurrency 占用 AuthKey 的一个并发名额
+func (m *Manager) AcquireKeyConcurrency(ctx context.Context, authKeyID uint, limit int) (bool, error) {
+	if !m.enabled {
+		return true, nil
+	}
+	if limit > 0 {
+		if err := m.checkStrict(); err != nil {
+			return false, err
+		}
+	}
+	return m.acquireConcurrency(ctx, concurrencyScopeKey, authKeyID, limit)
+}
+
+// ReleaseKeyConcurrency 释放 AuthKey 的一个并发名额
+func (m *Manager) ReleaseKeyConcurrency(ctx context.Context, authKeyID uint) error {
+	return m.releaseConcurrency(ctx, concurrencyScopeKey, authKeyID)
+}
+
+// GetKeyConcurrency 获取 AuthKey 当前在途请求数
+func (m *Manager) GetKeyConcurrency(ctx context.Context, authKeyID uint) (int, error) {
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (int, error) {
+		return set.concurrency.Current(ctx, authKeyID)
+	})
+}
+
+// AcquireProviderConcurrency 占用提供商的一个并发名额
+func (m *Manager) AcquireProviderConcurrency(ctx context.Context, providerID uint, limit int) (bool, error) {
+	if !m.enabled {
+		return true, nil
+	}
+	if limit > 0 {
+		if err := m.checkStrict(); err != nil {
+			return false, err
+		}
+	}
+	ok, err := m.acquireConcurrency(ctx, concurrencyScopeProvider, providerID, limit)
+	if err != nil {
+		m.rejections.Record(providerID, "limiter_unavailable", time.Now())
+	} else if !ok {
+		m.rejections.Record(providerID, "concurrency_limit_exceeded", time.Now())
+	}
+	return ok, err
+}
+
+// ReleaseProviderConcurrency 释放提供商的一个并发名额
+func (m *Manager) ReleaseProviderConcurrency(ctx context.Context, providerID uint) error {
+	return m.releaseConcurrency(ctx, concurrencyScopeProvider, providerID)
+}
+
+// GetProviderConcurrency 获取提供商当前在途请求数
+func (m *Manager) GetProviderConcurrency(ctx context.Context, providerID uint) (int, error) {
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (int, error) {
+		return set.providerConc.Current(ctx, providerID)
+	})
+}
+
+// CheckProviderLimits 检查提供商的所有限制，拒绝时按原因计入拒绝统计
+// 提供商与模型-提供商关联的 RPM 限制同时生效，任一达到上限即拒绝（更严格者生效）
+func (m *Manager) CheckProviderLimits(ctx context.Context, c *gin.Context, providerID uint, rpmLimit, tpmLimit, ipLockMinutes int, modelWithProviderID uint, modelRpmLimit int, tokenID uint, tokenLockTTL time.Duration) (bool, string, error) {
+	ok, reason, err := m.checkProviderLimits(ctx, c, providerID, rpmLimit, tpmLimit, ipLockMinutes, modelWithProviderID, modelRpmLimit, tokenID, tokenLockTTL)
+	if !ok && reason != "" {
+		m.rejections.Record(providerID, reason, time.Now())
+	}
+	return ok, reason, err
+}
+
+// GetRejections 返回各提供商按原因统计的限流拒绝次数，window 为时间窗口（最长 RejectionRetention）
+func (m *Manager) GetRejections(window time.Duration) []RejectionCount {
+	return m.rejections.Snapshot(window, time.Now())
+}
+
+func (m *Manager) checkProviderLimits(ctx context.Context, c *gin.Context, providerID uint, rpmLimit, tpmLimit, ipLockMinutes int, modelWithProviderID uint, modelRpmLimit int, tokenID uint, tokenLockTTL time.Duration) (bool, string, error) {
 	if !m.enabled {
 		return true, "", nil
 	}
 
-	// 检查RPM限制
-	if rpmLimit > 0 {
-		canProceed, err := m.CheckRPMLimit(ctx, providerID, rpmLimit)
+	// 严格模式：需要计数或锁定时，Redis 降级为内存后不再放行
+	if rpmLimit > 0 || modelRpmLimit > 0 || tpmLimit > 0 || ipLockMinutes > 0 || tokenLockTTL > 0 {
+		if err := m.checkStrict(); err != nil {
+			slog.Warn("Limiter strict mode rejected request", "provider_id", providerID, "error", err)
+			return false, "limiter_unavailable", err
+		}
+	}
+
+	// 检查TPM限制：超出时返回独立原因，调用方降低权重而非移除
+	if tpmLimit > 0 {
+		canProceed, err := m.CheckTPMLimit(ctx, providerID, tpmLimit)
 		if err != nil {
-			slog.Warn("RPM limit check failed", "provider_id", providerID, "error", err)
-			// 用户选择 fail-closed：限流依赖不可用时直接拒绝
+			slog.Warn("TPM limit check failed", "provider_id", providerID, "error", err)
 			return false, "limiter_unavailable", err
 		} else if !canProceed {
-			return false, "rpm_limit_exceeded", nil
+			return false, "tpm_limit_exceeded", nil
 		}
 	}
 
 	// token 独占锁：放在 IP 锁定之前（避免被伪造的 XFF 影响，也符合“同 token 独占供应商”的诉求）
-	if tokenID > 0 && modelWithProviderID > 0 && m.tokenLocker != nil {
-		ok, err := m.tokenLocker.CheckAndTouch(ctx, modelWithProviderID, tokenID)
+	// tokenLockTTL 为 0 表示该模型未启用 token 锁
+	if tokenLockTTL > 0 && tokenID > 0 && modelWithProviderID > 0 {
+		ok, err := runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (bool, error) {
+			return set.tokenLocker.CheckAndTouch(ctx, modelWithProviderID, tokenID, tokenLockTTL)
+		})
 		if err != nil {
 			slog.Warn("Token lock check failed", "provider_id", providerID, "model_with_provider_id", modelWithProviderID, "token_id", tokenID, "error", err)
 			return false, "limiter_unavailable", err
@@ -178,22 +422,51 @@ func (m *Manager) CheckProviderLimits(ctx context.Context, c *gin.Context, provi
 		}
 	}
 
+	// RPM 检查与记录原子完成，放在其余检查之后，避免被其他限制拒绝的请求占用配额
+	// 先检查范围更小的模型-提供商关联，再检查提供商整体；提供商拒绝时撤销关联的记录
+	if modelRpmLimit > 0 && modelWithProviderID > 0 {
+		canProceed, err := runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (bool, error) {
+			return set.mwpRpm.AllowRequest(ctx, modelWithProviderID, modelRpmLimit)
+		})
+		if err != nil {
+			slog.Warn("Model provider RPM limit check failed", "model_with_provider_id", modelWithProviderID, "error", err)
+			return false, "limiter_unavailable", err
+		} else if !canProceed {
+			return false, "model_rpm_limit_exceeded", nil
+		}
+	}
+	if rpmLimit > 0 {
+		canProceed, err := runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (bool, error) {
+			return set.rpm.AllowRequest(ctx, providerID, rpmLimit)
+		})
+		if err != nil || !canProceed {
+			// 提供商拒绝时撤销已记录的模型-提供商关联请求，避免未转发的请求占用模型配额
+			if modelRpmLimit > 0 && modelWithProviderID > 0 {
+				if cancelErr := m.run(ctx, func(ctx context.Context, set *limiterSet) error {
+					return set.mwpRpm.CancelRequest(ctx, modelWithProviderID)
+				}); cancelErr != nil {
+					slog.Warn("Failed to cancel model provider RPM request", "model_with_provider_id", modelWithProviderID, "error", cancelErr)
+				}
+			}
+		}
+		if err != nil {
+			slog.Warn("RPM limit check failed", "provider_id", providerID, "error", err)
+			// 用户选择 fail-closed：限流依赖不可用时直接拒绝
+			return false, "limiter_unavailable", err
+		} else if !canProceed {
+			return false, "rpm_limit_exceeded", nil
+		}
+	}
+
 	return true, "", nil
 }
 
-// RecordProviderAccess 记录提供商访问
-func (m *Manager) RecordProviderAccess(ctx context.Context, c *gin.Context, providerID uint, rpmLimit, ipLockMinutes int) error {
+// RecordProviderAccess 记录提供商访问（RPM 已在 CheckProviderLimits 通过时记录）
+func (m *Manager) RecordProviderAccess(ctx context.Context, c *gin.Context, providerID uint, ipLockMinutes int) error {
 	if !m.enabled {
 		return nil
 	}
 
-	// 记录RPM请求
-	if rpmLimit > 0 {
-		if err := m.RecordRPMRequest(ctx, providerID); err != nil {
-			slog.Warn("Failed to record RPM request", "provider_id", providerID, "error", err)
-		}
-	}
-
 	// 记录IP访问
 	if ipLockMinutes > 0 {
 		clientIP := m.GetClientIP(c)
@@ -210,13 +483,15 @@ func (m *Manager) GetCurrentRPMCount(ctx context.Context, providerID uint) (int,
 	if !m.enabled {
 		return 0, nil
 	}
-	return m.rpmLimiter.GetCurrentRPMCount(ctx, providerID)
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (int, error) {
+		return set.rpm.GetCurrentRPMCount(ctx, providerID)
+	})
 }
 
 // ClearMemoryData 清理内存数据（用于测试）
 func (m *Manager) ClearMemoryData() {
-	if m.rpmLimiter != nil {
-		m.rpmLimiter.ClearMemoryData()
-	}
+	m.memorySet.rpm.ClearMemoryData()
+	m.memorySet.mwpRpm.ClearMemoryData()
+	m.memorySet.keyRpm.ClearMemoryData()
 	// IP锁定器的内存清理可以在需要时添加
 }
@@ -0,0 +1,233 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisProbeInterval 运行期探测 Redis 可用性的间隔
+const RedisProbeInterval = 5 * time.Second
+
+// limiterSet 一组使用同一计数后端的限流器
+type limiterSet struct {
+	rpm          *RPMLimiter
+	mwpRpm       *RPMLimiter // 模型-提供商关联维度的 RPM 限制
+	keyRpm       *RPMLimiter // AuthKey 维度的 RPM 限制
+	tpm          *TPMLimiter
+	ipLocker     *IPLocker
+	tokenLocker  *TokenLocker
+	concurrency  *ConcurrencyLimiter
+	providerConc *ConcurrencyLimiter
+}
+
+// newLimiterSet redisClient 为 nil 时创建进程内存计数的限流器
+func newLimiterSet(redisClient *redis.Client) *limiterSet {
+	return &limiterSet{
+		rpm:          NewRPMLimiter(redisClient, "provider"),
+		mwpRpm:       NewRPMLimiter(redisClient, "mwpp"),
+		keyRpm:       NewRPMLimiter(redisClient, "auth_key"),
+		tpm:          NewTPMLimiter(redisClient),
+		ipLocker:     NewIPLocker(redisClient),
+		tokenLocker:  NewTokenLocker(redisClient, 2*time.Minute),
+		concurrency:  NewConcurrencyLimiter(redisClient, concurrencyScopeKey),
+		providerConc: NewConcurrencyLimiter(redisClient, concurrencyScopeProvider),
+	}
+}
+
+// 并发名额的计数维度
+const (
+	concurrencyScopeKey      = "auth_key"
+	concurrencyScopeProvider = "provider"
+)
+
+// concurrencyFor 按计数维度返回并发限制器
+func (s *limiterSet) concurrencyFor(scope string) *ConcurrencyLimiter {
+	if scope == concurrencyScopeProvider {
+		return s.providerConc
+	}
+	return s.concurrency
+}
+
+// concurrencyKey 区分 AuthKey 与提供商的并发名额
+type concurrencyKey struct {
+	scope string
+	id    uint
+}
+
+// redisUp 当前是否使用 Redis 计数
+func (m *Manager) redisUp() bool {
+	return m.redisSet != nil && !m.redisDown.Load()
+}
+
+// active 返回当前生效的限流器：Redis 可用时使用 Redis，否则使用进程内存
+func (m *Manager) active() *limiterSet {
+	if m.redisUp() {
+		return m.redisSet
+	}
+	return m.memorySet
+}
+
+// SetRedisAvailable 设置 Redis 是否可用，启动时连接失败的 Redis 在探测恢复后自动启用
+func (m *Manager) SetRedisAvailable(available bool) {
+	if m.redisSet == nil {
+		return
+	}
+	if m.redisDown.Swap(!available) == !available {
+		return
+	}
+	if available {
+		slog.Info("Redis recovered, limiter switched back to redis")
+		m.flushOwedReleases()
+	} else {
+		slog.Warn("Redis unavailable, limiter switched to memory")
+	}
+}
+
+// runLimiter 在当前后端执行 op；Redis 连接失败时切换到内存计数，非严格模式下在内存后端重试本次操作
+// 调用方取消或超过自身截止时间导致的错误不视为 Redis 故障
+func runLimiter[T any](ctx context.Context, m *Manager, op func(ctx context.Context, set *limiterSet) (T, error)) (T, error) {
+	if !m.redisUp() {
+		return op(ctx, m.memorySet)
+	}
+	opCtx, cancel := context.WithTimeout(ctx, m.redisTimeout)
+	v, err := op(opCtx, m.redisSet)
+	cancel()
+	if err == nil || ctx.Err() != nil || !isRedisConnError(err) {
+		return v, err
+	}
+	m.SetRedisAvailable(false)
+	if m.strict {
+		return v, err
+	}
+	return op(ctx, m.memorySet)
+}
+
+// run 无返回值版本的 runLimiter
+func (m *Manager) run(ctx context.Context, op func(ctx context.Context, set *limiterSet) error) error {
+	_, err := runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (struct{}, error) {
+		return struct{}{}, op(ctx, set)
+	})
+	return err
+}
+
+// isRedisConnError 判断 Redis 错误是否为连接类故障（连接拒绝、断开、超时等）
+func isRedisConnError(err error) bool {
+	if !errors.Is(err, ErrLimiterUnavailable) {
+		return false
+	}
+	if errors.Is(err, redis.ErrClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	// Redis 操作自身的超时（redisTimeout）同样说明 Redis 不可达
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// StartRedisMonitor 后台定期探测 Redis，不可用时切换到内存计数，恢复后切回 Redis
+func (m *Manager) StartRedisMonitor(ctx context.Context, interval time.Duration) {
+	if m.redisClient == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			m.probeRedis(ctx)
+		}
+	}()
+}
+
+func (m *Manager) probeRedis(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, max(m.redisTimeout, time.Second))
+	defer cancel()
+	err := m.redisClient.Ping(ctx).Err()
+	if err != nil && m.redisUp() {
+		slog.Warn("Redis ping failed", "error", err)
+	}
+	m.SetRedisAvailable(err == nil)
+}
+
+// acquireConcurrency 在当前后端占用并发名额，记录通过 Redis 占用的名额以便切换后到原后端释放
+func (m *Manager) acquireConcurrency(ctx context.Context, scope string, id uint, limit int) (bool, error) {
+	return runLimiter(ctx, m, func(ctx context.Context, set *limiterSet) (bool, error) {
+		ok, err := set.concurrencyFor(scope).Acquire(ctx, id, limit)
+		if err != nil || !ok || set != m.redisSet || limit <= 0 || id == 0 {
+			return ok, err
+		}
+		m.concMu.Lock()
+		m.redisHeld[concurrencyKey{scope, id}]++
+		m.concMu.Unlock()
+		return true, nil
+	})
+}
+
+// releaseConcurrency 优先释放通过 Redis 占用的名额；Redis 不可用时暂记，恢复后补释放，避免名额残留到兜底过期
+func (m *Manager) releaseConcurrency(ctx context.Context, scope string, id uint) error {
+	key := concurrencyKey{scope, id}
+	m.concMu.Lock()
+	held := m.redisHeld[key] > 0
+	if held {
+		if m.redisHeld[key]--; m.redisHeld[key] == 0 {
+			delete(m.redisHeld, key)
+		}
+	}
+	m.concMu.Unlock()
+	if !held {
+		return m.memorySet.concurrencyFor(scope).Release(ctx, id)
+	}
+	if m.redisUp() {
+		opCtx, cancel := context.WithTimeout(ctx, m.redisTimeout)
+		err := m.redisSet.concurrencyFor(scope).Release(opCtx, id)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() == nil && isRedisConnError(err) {
+			m.SetRedisAvailable(false)
+		}
+	}
+	m.concMu.Lock()
+	m.owedReleases[key]++
+	m.concMu.Unlock()
+	return nil
+}
+
+// flushOwedReleases Redis 恢复后补释放不可用期间未能释放的名额
+func (m *Manager) flushOwedReleases() {
+	m.concMu.Lock()
+	owed := m.owedReleases
+	m.owedReleases = make(map[concurrencyKey]int)
+	m.concMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), max(m.redisTimeout, time.Second))
+	defer cancel()
+	for key, count := range owed {
+		limiter := m.redisSet.concurrencyFor(key.scope)
+		for released := range count {
+			if err := limiter.Release(ctx, key.id); err != nil {
+				// 未释放的部分留待下次恢复时重试
+				slog.Warn("Failed to release concurrency after redis recovered", "scope", key.scope, "id", key.id, "error", err)
+				m.concMu.Lock()
+				m.owedReleases[key] += count - released
+				m.concMu.Unlock()
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package limiter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// closedRedisAddr 返回一个没有监听的本地地址，连接时得到 connection refused
+func closedRedisAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// pongRedisAddr 启动一个对任意命令都回复 PONG 的最小 Redis 服务，仅用于 Ping 探测
+func pongRedisAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					// RESP 数组：*N 之后 N 组 $len + 内容
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					var n int
+					fmt.Sscanf(line, "*%d", &n)
+					for range 2 * n {
+						if _, err := r.ReadString('\n'); err != nil {
+							return
+						}
+					}
+					if _, err := conn.Write([]byte("+PONG\r\n")); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func newFailoverTestManager(t *testing.T, addr string, strict bool) *Manager {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{
+		Addr:        addr,
+		MaxRetries:  -1,
+		DialTimeout: 200 * time.Millisecond,
+	})
+	t.Cleanup(func() { client.Close() })
+	m := NewManager(client)
+	m.SetRedisConfigured(true)
+	m.redisTimeout = time.Second
+	m.strict = strict
+	return m
+}
+
+func TestRunLimiterFailsOverToMemoryOnConnError(t *testing.T) {
+	m := newFailoverTestManager(t, closedRedisAddr(t), false)
+	if got := m.Backend(); got != BackendRedis {
+		t.Fatalf("backend = %s, want %s", got, BackendRedis)
+	}
+
+	ok, err := m.AllowKeyRequest(context.Background(), 1, 5)
+	if err != nil || !ok {
+		t.Fatalf("AllowKeyRequest = %v, %v; want true, nil (retried in memory)", ok, err)
+	}
+	if got := m.Backend(); got != BackendDegraded {
+		t.Fatalf("backend = %s, want %s", got, BackendDegraded)
+	}
+	// 重试的请求计入内存计数
+	count, err := m.memorySet.keyRpm.GetCurrentRPMCount(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("memory count = %d, want 1", count)
+	}
+}
+
+func TestRunLimiterStrictModeRejectsOnConnError(t *testing.T) {
+	m := newFailoverTestManager(t, closedRedisAddr(t), true)
+
+	ok, err := m.AllowKeyRequest(context.Background(), 1, 5)
+	if ok || !errors.Is(err, ErrLimiterUnavailable) {
+		t.Fatalf("AllowKeyRequest = %v, %v; want false, ErrLimiterUnavailable", ok, err)
+	}
+	if got := m.Backend(); got != BackendDegraded {
+		t.Fatalf("backend = %s, want %s", got, BackendDegraded)
+	}
+	// 严格模式下不在内存重试
+	count, _ := m.memorySet.keyRpm.GetCurrentRPMCount(context.Background(), 1)
+	if count != 0 {
+		t.Fatalf("memory count = %d, want 0", count)
+	}
+}
+
+func TestRunLimiterIgnoresCallerCancellation(t *testing.T) {
+	m := newFailoverTestManager(t, closedRedisAddr(t), false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := m.AllowKeyRequest(ctx, 1, 5); err == nil {
+		t.Fatal("expected error for canceled context")
+	}
+	if got := m.Backend(); got != BackendRedis {
+		t.Fatalf("backend = %s, want %s (client cancel must not trip failover)", got, BackendRedis)
+	}
+}
+
+func TestProbeRedisTransitions(t *testing.T) {
+	m := newFailoverTestManager(t, closedRedisAddr(t), false)
+	m.probeRedis(context.Background())
+	if got := m.Backend(); got != BackendDegraded {
+		t.Fatalf("after failed ping backend = %s, want %s", got, BackendDegraded)
+	}
+
+	up := newFailoverTestManager(t, pongRedisAddr(t), false)
+	up.SetRedisAvailable(false)
+	if got := up.Backend(); got != BackendDegraded {
+		t.Fatalf("backend = %s, want %s", got, BackendDegraded)
+	}
+	up.probeRedis(context.Background())
+	if got := up.Backend(); got != BackendRedis {
+		t.Fatalf("after successful ping backend = %s, want %s", got, BackendRedis)
+	}
+}
+
+func TestIsRedisConnError(t *testing.T) {
+	wrap := func(err error) error { return fmt.Errorf("%w: redis op: %w", ErrLimiterUnavailable, err) }
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", wrap(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), true},
+		{"connection reset", wrap(syscall.ECONNRESET), true},
+		{"eof", wrap(io.EOF), true},
+		{"client closed", wrap(redis.ErrClosed), true},
+		{"op timeout", wrap(context.DeadlineExceeded), true},
+		{"canceled", wrap(context.Canceled), false},
+		{"script error", wrap(errors.New("ERR wrong number of arguments")), false},
+		{"not limiter error", syscall.ECONNREFUSED, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRedisConnError(tt.err); got != tt.want {
+				t.Fatalf("isRedisConnError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package limiter
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RejectionRetention 按分钟保留限流拒绝计数的时长，时间窗口查询不能超过该值
+const RejectionRetention = time.Hour
+
+// RejectionCount 提供商按拒绝原因统计的次数：Total 为进程启动以来累计，Window 为查询时间窗口内
+type RejectionCount struct {
+	ProviderID uint   `json:"provider_id"`
+	Reason     string `json:"reason"`
+	Total      int64  `json:"total"`
+	Window     int64  `json:"window"`
+}
+
+type rejectionKey struct {
+	providerID uint
+	reason     string
+}
+
+// RejectionCounter 记录提供商被限流拒绝的次数（进程内，多副本部署时各副本分别统计）
+type RejectionCounter struct {
+	mu      sync.Mutex
+	totals  map[rejectionKey]int64
+	buckets map[int64]map[rejectionKey]int64 // 分钟时间戳 -> 该分钟内的拒绝次数
+}
+
+func NewRejectionCounter() *RejectionCounter {
+	return &RejectionCounter{
+		totals:  make(map[rejectionKey]int64),
+		buckets: make(map[int64]map[rejectionKey]int64),
+	}
+}
+
+// Record 记录一次拒绝，同时清理超出保留时长的分钟桶
+func (r *RejectionCounter) Record(providerID uint, reason string, now time.Time) {
+	key := rejectionKey{providerID: providerID, reason: reason}
+	minute := now.Unix() / 60
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totals[key]++
+	bucket, ok := r.buckets[minute]
+	if !ok {
+		bucket = make(map[rejectionKey]int64)
+		r.buckets[minute] = bucket
+		oldest := minute - int64(RejectionRetention/time.Minute)
+		for m := range r.buckets {
+			if m <= oldest {
+				delete(r.buckets, m)
+			}
+		}
+	}
+	bucket[key]++
+}
+
+// Snapshot 返回各提供商各原因的累计拒绝次数及最近 window 内的次数，按提供商、原因排序
+func (r *RejectionCounter) Snapshot(window time.Duration, now time.Time) []RejectionCount {
+	window = min(window, RejectionRetention)
+	since := now.Add(-window).Unix() / 60
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	windowCounts := make(map[rejectionKey]int64)
+	for minute, bucket := range r.buckets {
+		if minute <= since {
+			continue
+		}
+		for key, count := range bucket {
+			windowCounts[key] += count
+		}
+	}
+	result := make([]RejectionCount, 0, len(r.totals))
+	for key, total := range r.totals {
+		result = append(result, RejectionCount{
+			ProviderID: key.providerID,
+			Reason:     key.reason,
+			Total:      total,
+			Window:     windowCounts[key],
+		})
+	}
+	slices.SortFunc(result, func(a, b RejectionCount) int {
+		return cmp.Or(cmp.Compare(a.ProviderID, b.ProviderID), cmp.Compare(a.Reason, b.Reason))
+	})
+	return result
+}
@@ -0,0 +1,95 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func clientContext(ip string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.RemoteAddr = ip + ":40000"
+	return c
+}
+
+func TestCheckProviderLimitsRecordsRejections(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewManager(nil)
+	ctx := context.Background()
+
+	// 提供商 1：RPM 1，第二、三次被拒绝
+	for range 3 {
+		m.CheckProviderLimits(ctx, nil, 1, 1, 0, 0, 10, 0, 0, 0)
+	}
+	// 提供商 2：模型关联 RPM 1
+	for range 2 {
+		m.CheckProviderLimits(ctx, nil, 2, 0, 0, 0, 20, 1, 0, 0)
+	}
+	// 提供商 3：token 独占锁，其他 token 被拒绝
+	m.CheckProviderLimits(ctx, nil, 3, 0, 0, 0, 30, 0, 100, time.Minute)
+	m.CheckProviderLimits(ctx, nil, 3, 0, 0, 0, 30, 0, 200, time.Minute)
+	// 提供商 4：IP 锁定，其他 IP 被拒绝
+	first := clientContext("10.0.0.1")
+	m.CheckProviderLimits(ctx, first, 4, 0, 0, 5, 40, 0, 0, 0)
+	m.RecordProviderAccess(ctx, first, 4, 5)
+	m.CheckProviderLimits(ctx, clientContext("10.0.0.2"), 4, 0, 0, 5, 40, 0, 0, 0)
+	// 提供商 5：并发上限 1
+	for range 2 {
+		m.AcquireProviderConcurrency(ctx, 5, 1)
+	}
+	// 放行的请求不计数
+	m.CheckProviderLimits(ctx, nil, 6, 100, 0, 0, 60, 100, 0, 0)
+
+	want := []RejectionCount{
+		{ProviderID: 1, Reason: "rpm_limit_exceeded", Total: 2, Window: 2},
+		{ProviderID: 2, Reason: "model_rpm_limit_exceeded", Total: 1, Window: 1},
+		{ProviderID: 3, Reason: "token_access_denied", Total: 1, Window: 1},
+		{ProviderID: 4, Reason: "ip_access_denied", Total: 1, Window: 1},
+		{ProviderID: 5, Reason: "concurrency_limit_exceeded", Total: 1, Window: 1},
+	}
+	if got := m.GetRejections(5 * time.Minute); !slices.Equal(got, want) {
+		t.Fatalf("rejections = %+v, want %+v", got, want)
+	}
+}
+
+func TestRejectionCounterWindow(t *testing.T) {
+	now := time.Date(2026, 10, 17, 8, 0, 30, 0, time.UTC)
+	r := NewRejectionCounter()
+	r.Record(1, "rpm_limit_exceeded", now.Add(-90*time.Minute))
+	r.Record(1, "rpm_limit_exceeded", now.Add(-30*time.Minute))
+	r.Record(1, "rpm_limit_exceeded", now)
+	r.Record(2, "ip_access_denied", now.Add(-2*time.Minute))
+
+	tests := []struct {
+		name   string
+		window time.Duration
+		want   []RejectionCount
+	}{
+		{"last 5 minutes", 5 * time.Minute, []RejectionCount{
+			{ProviderID: 1, Reason: "rpm_limit_exceeded", Total: 3, Window: 1},
+			{ProviderID: 2, Reason: "ip_access_denied", Total: 1, Window: 1},
+		}},
+		{"last minute", time.Minute, []RejectionCount{
+			{ProviderID: 1, Reason: "rpm_limit_exceeded", Total: 3, Window: 1},
+			{ProviderID: 2, Reason: "ip_access_denied", Total: 1, Window: 0},
+		}},
+		// 超出保留时长的窗口按保留时长计算，更早的分钟桶已被清理
+		{"beyond retention", 3 * time.Hour, []RejectionCount{
+			{ProviderID: 1, Reason: "rpm_limit_exceeded", Total: 3, Window: 2},
+			{ProviderID: 2, Reason: "ip_access_denied", Total: 1, Window: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Snapshot(tt.window, now); !slices.Equal(got, tt.want) {
+				t.Fatalf("snapshot = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
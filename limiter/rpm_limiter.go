@@ -10,10 +10,12 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
-// RPMLimiter RPM限流器
+// RPMLimiter RPM限流器，按 ID（提供商或模型-提供商关联）统计 1 分钟窗口内的请求数
 type RPMLimiter struct {
 	redis  *redis.Client
-	memory *sync.Map // 内存存储，当Redis不可用时使用
+	scope  string     // 计数维度，用于区分 Redis key，如 provider、mwpp
+	memory *sync.Map  // 内存存储，当Redis不可用时使用
+	mu     sync.Mutex // 保护内存记录的读改写，使检查与记录在同一临界区内完成
 }
 
 // RequestRecord 请求记录
@@ -22,9 +24,10 @@ type RequestRecord struct {
 }
 
 // NewRPMLimiter 创建新的RPM限流器
-func NewRPMLimiter(redisClient *redis.Client) *RPMLimiter {
+func NewRPMLimiter(redisClient *redis.Client, scope string) *RPMLimiter {
 	return &RPMLimiter{
 		redis:  redisClient,
+		scope:  scope,
 		memory: &sync.Map{},
 	}
 }
@@ -46,6 +49,29 @@ func (r *RPMLimiter) CheckRPMLimit(ctx context.Context, providerID uint, rpmLimi
 	return r.checkRPMLimitMemory(providerID, rpmLimit, now, windowStart), nil
 }
 
+// AllowRequest 检查RPM限制，未超出时记录本次请求
+// 内存模式下检查与记录在同一把锁内完成，Redis 模式下由 Lua 脚本原子完成，并发请求不会同时通过检查而超出限制
+func (r *RPMLimiter) AllowRequest(ctx context.Context, providerID uint, rpmLimit int) (bool, error) {
+	if rpmLimit <= 0 {
+		return true, nil
+	}
+
+	now := time.Now().Unix()
+	windowStart := now - 60
+
+	if r.redis != nil {
+		return r.allowRequestRedis(ctx, providerID, rpmLimit, now, windowStart)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.checkRPMLimitMemoryLocked(providerID, rpmLimit, windowStart) {
+		return false, nil
+	}
+	r.recordRequestMemoryLocked(providerID, now)
+	return true, nil
+}
+
 // RecordRequest 记录一次请求
 func (r *RPMLimiter) RecordRequest(ctx context.Context, providerID uint) error {
 	now := time.Now().Unix()
@@ -58,6 +84,27 @@ func (r *RPMLimiter) RecordRequest(ctx context.Context, providerID uint) error {
 	return nil
 }
 
+// CancelRequest 撤销最近一次记录的请求，用于 AllowRequest 通过后被其它限制拒绝的情况
+func (r *RPMLimiter) CancelRequest(ctx context.Context, providerID uint) error {
+	if r.redis != nil {
+		if err := r.redis.ZPopMax(ctx, r.getRPMKey(providerID), 1).Err(); err != nil {
+			return fmt.Errorf("%w: redis rpm cancel failed: %w", ErrLimiterUnavailable, err)
+		}
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, exists := r.memory.Load(r.getRPMKey(providerID))
+	if !exists {
+		return nil
+	}
+	if record, ok := value.(*RequestRecord); ok && len(record.Timestamps) > 0 {
+		record.Timestamps = record.Timestamps[:len(record.Timestamps)-1]
+	}
+	return nil
+}
+
 // GetCurrentRPMCount 获取当前RPM计数
 func (r *RPMLimiter) GetCurrentRPMCount(ctx context.Context, providerID uint) (int, error) {
 	now := time.Now().Unix()
@@ -72,7 +119,7 @@ func (r *RPMLimiter) GetCurrentRPMCount(ctx context.Context, providerID uint) (i
 
 // getRPMKey 获取RPM存储键
 func (r *RPMLimiter) getRPMKey(providerID uint) string {
-	return fmt.Sprintf("rpm:provider:%d", providerID)
+	return fmt.Sprintf("rpm:%s:%d", r.scope, providerID)
 }
 
 // ==================== Redis实现 ====================
@@ -92,13 +139,33 @@ func (r *RPMLimiter) checkRPMLimitRedis(ctx context.Context, providerID uint, rp
 	_, err := pipe.Exec(ctx)
 	if err != nil {
 		// Redis 出错：由上层决定 fail-open / fail-closed，这里统一标记为不可用
-		return false, fmt.Errorf("%w: redis rpm check failed: %v", ErrLimiterUnavailable, err)
+		return false, fmt.Errorf("%w: redis rpm check failed: %w", ErrLimiterUnavailable, err)
 	}
 
 	count := countCmd.Val()
 	return count < int64(rpmLimit), nil
 }
 
+// allowRPMScript Lua 保证原子性：清理过期记录后计数，未超出上限时记录本次请求
+var allowRPMScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "0", ARGV[1])
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+  return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[4])
+redis.call("EXPIRE", KEYS[1], 120)
+return 1
+`)
+
+func (r *RPMLimiter) allowRequestRedis(ctx context.Context, providerID uint, rpmLimit int, now, windowStart int64) (bool, error) {
+	member := fmt.Sprintf("%d-%d", now, time.Now().UnixNano()%1000000)
+	res, err := allowRPMScript.Run(ctx, r.redis, []string{r.getRPMKey(providerID)}, windowStart, rpmLimit, now, member).Int()
+	if err != nil {
+		return false, fmt.Errorf("%w: redis rpm allow failed: %w", ErrLimiterUnavailable, err)
+	}
+	return res == 1, nil
+}
+
 func (r *RPMLimiter) recordRequestRedis(ctx context.Context, providerID uint, now int64) error {
 	key := r.getRPMKey(providerID)
 
@@ -117,7 +184,7 @@ func (r *RPMLimiter) recordRequestRedis(ctx context.Context, providerID uint, no
 
 	_, err := pipe.Exec(ctx)
 	if err != nil {
-		return fmt.Errorf("%w: redis rpm record failed: %v", ErrLimiterUnavailable, err)
+		return fmt.Errorf("%w: redis rpm record failed: %w", ErrLimiterUnavailable, err)
 	}
 	return nil
 }
@@ -135,7 +202,7 @@ func (r *RPMLimiter) getCurrentRPMCountRedis(ctx context.Context, providerID uin
 
 	_, err := pipe.Exec(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("%w: redis rpm count failed: %v", ErrLimiterUnavailable, err)
+		return 0, fmt.Errorf("%w: redis rpm count failed: %w", ErrLimiterUnavailable, err)
 	}
 
 	return int(countCmd.Val()), nil
@@ -144,6 +211,13 @@ func (r *RPMLimiter) getCurrentRPMCountRedis(ctx context.Context, providerID uin
 // ==================== 内存实现 ====================
 
 func (r *RPMLimiter) checkRPMLimitMemory(providerID uint, rpmLimit int, now, windowStart int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.checkRPMLimitMemoryLocked(providerID, rpmLimit, windowStart)
+}
+
+// checkRPMLimitMemoryLocked 调用方需持有 r.mu
+func (r *RPMLimiter) checkRPMLimitMemoryLocked(providerID uint, rpmLimit int, windowStart int64) bool {
 	key := r.getRPMKey(providerID)
 
 	value, exists := r.memory.Load(key)
@@ -172,6 +246,13 @@ func (r *RPMLimiter) checkRPMLimitMemory(providerID uint, rpmLimit int, now, win
 }
 
 func (r *RPMLimiter) recordRequestMemory(providerID uint, now int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recordRequestMemoryLocked(providerID, now)
+}
+
+// recordRequestMemoryLocked 调用方需持有 r.mu
+func (r *RPMLimiter) recordRequestMemoryLocked(providerID uint, now int64) {
 	key := r.getRPMKey(providerID)
 
 	value, exists := r.memory.Load(key)
@@ -204,6 +285,8 @@ func (r *RPMLimiter) recordRequestMemory(providerID uint, now int64) {
 }
 
 func (r *RPMLimiter) getCurrentRPMCountMemory(providerID uint, windowStart int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	key := r.getRPMKey(providerID)
 
 	value, exists := r.memory.Load(key)
@@ -229,6 +312,8 @@ func (r *RPMLimiter) getCurrentRPMCountMemory(providerID uint, windowStart int64
 
 // ClearMemoryData 清理内存数据（用于测试）
 func (r *RPMLimiter) ClearMemoryData() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.memory = &sync.Map{}
 }
 
@@ -243,7 +328,7 @@ func (r *RPMLimiter) GetStats(ctx context.Context) map[string]interface{} {
 		stats["storage_type"] = "redis"
 
 		// 获取Redis中的RPM键
-		keys, err := r.redis.Keys(ctx, "rpm:provider:*").Result()
+		keys, err := r.redis.Keys(ctx, fmt.Sprintf("rpm:%s:*", r.scope)).Result()
 		if err == nil {
 			providerStats := make(map[string]int)
 			for _, key := range keys {
@@ -257,6 +342,7 @@ func (r *RPMLimiter) GetStats(ctx context.Context) map[string]interface{} {
 	} else {
 		// 内存统计
 		providerStats := make(map[string]int)
+		r.mu.Lock()
 		r.memory.Range(func(key, value interface{}) bool {
 			if keyStr, ok := key.(string); ok {
 				if record, ok := value.(*RequestRecord); ok {
@@ -265,6 +351,7 @@ func (r *RPMLimiter) GetStats(ctx context.Context) map[string]interface{} {
 			}
 			return true
 		})
+		r.mu.Unlock()
 		stats["providers"] = providerStats
 	}
 
@@ -0,0 +1,182 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// runConcurrently 并发执行 n 次 fn，返回成功次数
+func runConcurrently(t *testing.T, n int, fn func() (bool, error)) int {
+	t.Helper()
+	var (
+		wg      sync.WaitGroup
+		allowed atomic.Int64
+		start   = make(chan struct{})
+	)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			ok, err := fn()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return int(allowed.Load())
+}
+
+func TestRPMLimiterAllowRequestConcurrent(t *testing.T) {
+	const n, limit = 200, 10
+	r := NewRPMLimiter(nil, "provider")
+	allowed := runConcurrently(t, n, func() (bool, error) {
+		return r.AllowRequest(context.Background(), 1, limit)
+	})
+	if allowed != limit {
+		t.Fatalf("allowed = %d, want %d", allowed, limit)
+	}
+	count, err := r.GetCurrentRPMCount(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != limit {
+		t.Fatalf("recorded = %d, want %d", count, limit)
+	}
+}
+
+func TestCheckProviderLimitsRPMConcurrent(t *testing.T) {
+	const n = 200
+	tests := []struct {
+		name          string
+		rpmLimit      int
+		modelRpmLimit int
+		want          int
+	}{
+		{"provider", 7, 0, 7},
+		{"model provider", 0, 5, 5},
+		{"model provider below provider", 9, 4, 4},
+		{"provider below model provider", 3, 8, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(nil)
+			allowed := runConcurrently(t, n, func() (bool, error) {
+				ok, _, err := m.CheckProviderLimits(context.Background(), nil, 1, tt.rpmLimit, 0, 0, 2, tt.modelRpmLimit, 0, 0)
+				return ok, err
+			})
+			if allowed != tt.want {
+				t.Fatalf("allowed = %d, want %d", allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckProviderLimitsRejectionReason(t *testing.T) {
+	m := NewManager(nil)
+	ctx := context.Background()
+	if ok, _, _ := m.CheckProviderLimits(ctx, nil, 1, 1, 0, 0, 2, 0, 0, 0); !ok {
+		t.Fatal("first request rejected")
+	}
+	ok, reason, err := m.CheckProviderLimits(ctx, nil, 1, 1, 0, 0, 2, 0, 0, 0)
+	if ok || err != nil || reason != "rpm_limit_exceeded" {
+		t.Fatalf("got ok=%v reason=%q err=%v", ok, reason, err)
+	}
+	ok, reason, _ = m.CheckProviderLimits(ctx, nil, 3, 0, 0, 0, 4, 1, 0, 0)
+	if !ok {
+		t.Fatalf("model provider first request rejected: %s", reason)
+	}
+	ok, reason, _ = m.CheckProviderLimits(ctx, nil, 3, 0, 0, 0, 4, 1, 0, 0)
+	if ok || reason != "model_rpm_limit_exceeded" {
+		t.Fatalf("got ok=%v reason=%q", ok, reason)
+	}
+}
+
+func TestCheckProviderLimitsSharedProviderAccount(t *testing.T) {
+	m := NewManager(nil)
+	ctx := context.Background()
+	// 提供商账号 RPM 5，两个模型关联各自 RPM 3
+	check := func(mwpID uint) (bool, string) {
+		ok, reason, err := m.CheckProviderLimits(ctx, nil, 1, 5, 0, 0, mwpID, 3, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok, reason
+	}
+
+	for i := range 3 {
+		if ok, reason := check(10); !ok {
+			t.Fatalf("mwp 10 request %d rejected: %s", i, reason)
+		}
+	}
+	// 单个模型用满自己的配额，不影响同账号的其它模型
+	if ok, reason := check(10); ok || reason != "model_rpm_limit_exceeded" {
+		t.Fatalf("mwp 10 over quota: ok=%v reason=%q", ok, reason)
+	}
+	for i := range 2 {
+		if ok, reason := check(11); !ok {
+			t.Fatalf("mwp 11 request %d rejected: %s", i, reason)
+		}
+	}
+	// 两个模型合计达到账号上限后，未用满模型配额的关联也被提供商限制拒绝
+	if ok, reason := check(11); ok || reason != "rpm_limit_exceeded" {
+		t.Fatalf("provider over quota: ok=%v reason=%q", ok, reason)
+	}
+
+	counts := map[uint]int{}
+	for _, id := range []uint{10, 11} {
+		n, err := m.GetCurrentModelProviderRPMCount(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[id] = n
+	}
+	// 被任一限制拒绝的请求都不占用另一维度的配额
+	if counts[10] != 3 || counts[11] != 2 {
+		t.Fatalf("model provider counts = %v", counts)
+	}
+	if n, err := m.GetCurrentRPMCount(ctx, 1); err != nil || n != 5 {
+		t.Fatalf("provider count = %d, %v; want 5", n, err)
+	}
+
+	stats := m.GetRPMStats(ctx)
+	modelStats, _ := stats["model_providers"].(map[string]int)
+	providerStats, _ := stats["providers"].(map[string]int)
+	if modelStats["rpm:mwpp:10"] != 3 || modelStats["rpm:mwpp:11"] != 2 || providerStats["rpm:provider:1"] != 5 {
+		t.Fatalf("stats = %v", stats)
+	}
+}
+
+func TestRPMLimiterCancelRequest(t *testing.T) {
+	ctx := context.Background()
+	r := NewRPMLimiter(nil, "mwpp")
+	// 未记录时撤销为空操作
+	if err := r.CancelRequest(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if ok, err := r.AllowRequest(ctx, 1, 2); !ok || err != nil {
+			t.Fatalf("allow: ok=%v err=%v", ok, err)
+		}
+	}
+	if ok, _ := r.AllowRequest(ctx, 1, 2); ok {
+		t.Fatal("third request allowed over limit")
+	}
+	if err := r.CancelRequest(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := r.GetCurrentRPMCount(ctx, 1); n != 1 {
+		t.Fatalf("count after cancel = %d, want 1", n)
+	}
+	if ok, _ := r.AllowRequest(ctx, 1, 2); !ok {
+		t.Fatal("cancelled slot not reusable")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -47,20 +48,24 @@ func (l *TokenLocker) getKey(modelWithProviderID uint) string {
 // - key 不存在：写入 token 并设置 ttl
 // - key 存在且 token 一致：续期
 // - key 存在且 token 不一致：拒绝
-func (l *TokenLocker) CheckAndTouch(ctx context.Context, modelWithProviderID uint, tokenID uint) (bool, error) {
+// ttl <= 0 时使用默认时长
+func (l *TokenLocker) CheckAndTouch(ctx context.Context, modelWithProviderID uint, tokenID uint, ttl time.Duration) (bool, error) {
 	if modelWithProviderID == 0 || tokenID == 0 {
 		return true, nil
 	}
+	if ttl <= 0 {
+		ttl = l.ttl
+	}
 
 	if l.redis != nil {
-		return l.checkAndTouchRedis(ctx, modelWithProviderID, tokenID)
+		return l.checkAndTouchRedis(ctx, modelWithProviderID, tokenID, ttl)
 	}
-	return l.checkAndTouchMemory(modelWithProviderID, tokenID), nil
+	return l.checkAndTouchMemory(modelWithProviderID, tokenID, ttl), nil
 }
 
-func (l *TokenLocker) checkAndTouchRedis(ctx context.Context, modelWithProviderID uint, tokenID uint) (bool, error) {
+func (l *TokenLocker) checkAndTouchRedis(ctx context.Context, modelWithProviderID uint, tokenID uint, ttl time.Duration) (bool, error) {
 	key := l.getKey(modelWithProviderID)
-	ttlSeconds := int64(l.ttl.Seconds())
+	ttlSeconds := int64(ttl.Seconds())
 	if ttlSeconds <= 0 {
 		ttlSeconds = 120
 	}
@@ -84,12 +89,12 @@ return 0
 
 	res, err := script.Run(ctx, l.redis, []string{key}, strconv.FormatUint(uint64(tokenID), 10), strconv.FormatInt(ttlSeconds, 10)).Int()
 	if err != nil {
-		return false, fmt.Errorf("%w: redis token lock failed: %v", ErrLimiterUnavailable, err)
+		return false, fmt.Errorf("%w: redis token lock failed: %w", ErrLimiterUnavailable, err)
 	}
 	return res == 1, nil
 }
 
-func (l *TokenLocker) checkAndTouchMemory(modelWithProviderID uint, tokenID uint) bool {
+func (l *TokenLocker) checkAndTouchMemory(modelWithProviderID uint, tokenID uint, ttl time.Duration) bool {
 	key := l.getKey(modelWithProviderID)
 	now := time.Now()
 
@@ -108,7 +113,104 @@ func (l *TokenLocker) checkAndTouchMemory(modelWithProviderID uint, tokenID uint
 
 	l.memory.Store(key, &tokenLockRecord{
 		TokenID: tokenID,
-		Expiry:  now.Add(l.ttl),
+		Expiry:  now.Add(ttl),
 	})
 	return true
 }
+
+// TokenLockInfo 当前生效的 token 锁
+type TokenLockInfo struct {
+	ModelWithProviderID uint      `json:"model_with_provider_id"`
+	TokenID             uint      `json:"token_id"`
+	ExpiresAt           time.Time `json:"expires_at"`
+}
+
+// List 列出当前生效的 token 锁
+func (l *TokenLocker) List(ctx context.Context) ([]TokenLockInfo, error) {
+	locks := make([]TokenLockInfo, 0)
+	prefix := l.keyBase + ":mwpp:"
+
+	if l.redis != nil {
+		iter := l.redis.Scan(ctx, 0, prefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			mwppID, err := strconv.ParseUint(strings.TrimPrefix(key, prefix), 10, 64)
+			if err != nil {
+				continue
+			}
+			val, err := l.redis.Get(ctx, key).Result()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: redis token lock list failed: %w", ErrLimiterUnavailable, err)
+			}
+			tokenID, err := strconv.ParseUint(val, 10, 64)
+			if err != nil {
+				continue
+			}
+			ttl, err := l.redis.PTTL(ctx, key).Result()
+			if err != nil {
+				return nil, fmt.Errorf("%w: redis token lock list failed: %w", ErrLimiterUnavailable, err)
+			}
+			locks = append(locks, TokenLockInfo{
+				ModelWithProviderID: uint(mwppID),
+				TokenID:             uint(tokenID),
+				ExpiresAt:           time.Now().Add(ttl),
+			})
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("%w: redis token lock list failed: %w", ErrLimiterUnavailable, err)
+		}
+		return locks, nil
+	}
+
+	now := time.Now()
+	l.memory.Range(func(k, v any) bool {
+		key, _ := k.(string)
+		rec, ok := v.(*tokenLockRecord)
+		if !ok || now.After(rec.Expiry) {
+			return true
+		}
+		mwppID, err := strconv.ParseUint(strings.TrimPrefix(key, prefix), 10, 64)
+		if err != nil {
+			return true
+		}
+		locks = append(locks, TokenLockInfo{
+			ModelWithProviderID: uint(mwppID),
+			TokenID:             rec.TokenID,
+			ExpiresAt:           rec.Expiry,
+		})
+		return true
+	})
+	return locks, nil
+}
+
+// Clear 清除指定 model_with_provider 的 token 锁
+func (l *TokenLocker) Clear(ctx context.Context, modelWithProviderID uint) error {
+	key := l.getKey(modelWithProviderID)
+
+	if l.redis != nil {
+		if err := l.redis.Del(ctx, key).Err(); err != nil {
+			return fmt.Errorf("%w: redis token lock clear failed: %w", ErrLimiterUnavailable, err)
+		}
+		return nil
+	}
+
+	l.memory.Delete(key)
+	return nil
+}
+
+// ClearAll 清除全部 token 锁，返回清除数量
+func (l *TokenLocker) ClearAll(ctx context.Context) (int, error) {
+	locks, err := l.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, lock := range locks {
+		if err := l.Clear(ctx, lock.ModelWithProviderID); err != nil {
+			return 0, err
+		}
+	}
+	return len(locks), nil
+}
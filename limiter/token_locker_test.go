@@ -0,0 +1,86 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenLockerTTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	l := NewTokenLocker(nil, 2*time.Minute)
+	const ttl = 200 * time.Millisecond
+
+	if ok, _ := l.CheckAndTouch(ctx, 1, 10, ttl); !ok {
+		t.Fatal("first token rejected")
+	}
+	if ok, _ := l.CheckAndTouch(ctx, 1, 20, ttl); ok {
+		t.Fatal("other token allowed while lock held")
+	}
+	// 锁按 model_with_provider 隔离
+	if ok, _ := l.CheckAndTouch(ctx, 2, 20, ttl); !ok {
+		t.Fatal("other token rejected on a different model provider")
+	}
+
+	// 持有者在 TTL 内的请求会续期
+	time.Sleep(120 * time.Millisecond)
+	if ok, _ := l.CheckAndTouch(ctx, 1, 10, ttl); !ok {
+		t.Fatal("holder rejected")
+	}
+	time.Sleep(120 * time.Millisecond)
+	if ok, _ := l.CheckAndTouch(ctx, 1, 20, ttl); ok {
+		t.Fatal("other token allowed before renewed lock expired")
+	}
+
+	time.Sleep(ttl + 50*time.Millisecond)
+	if ok, _ := l.CheckAndTouch(ctx, 1, 20, ttl); !ok {
+		t.Fatal("other token rejected after lock expired")
+	}
+	locks, err := l.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lock := range locks {
+		if lock.ModelWithProviderID == 1 && lock.TokenID != 20 {
+			t.Fatalf("lock holder = %d, want 20", lock.TokenID)
+		}
+	}
+}
+
+func TestCheckProviderLimitsTokenLock(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		secondAllow bool
+	}{
+		{"enabled", time.Minute, false},
+		// TokenLockSeconds 为 0 时不加锁，其他 token 可以使用同一提供商
+		{"disabled", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(nil)
+			if ok, reason, err := m.CheckProviderLimits(ctx, nil, 1, 0, 0, 0, 2, 0, 10, tt.ttl); !ok || err != nil {
+				t.Fatalf("first token: ok=%v reason=%q err=%v", ok, reason, err)
+			}
+			ok, reason, err := m.CheckProviderLimits(ctx, nil, 1, 0, 0, 0, 2, 0, 20, tt.ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.secondAllow {
+				t.Fatalf("second token: ok=%v reason=%q, want %v", ok, reason, tt.secondAllow)
+			}
+			if !ok && reason != "token_access_denied" {
+				t.Fatalf("reason = %q, want token_access_denied", reason)
+			}
+			locks, err := m.ListTokenLocks(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wantLocks := map[bool]int{true: 0, false: 1}[tt.secondAllow]; len(locks) != wantLocks {
+				t.Fatalf("locks = %d, want %d", len(locks), wantLocks)
+			}
+		})
+	}
+}
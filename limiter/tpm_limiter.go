@@ -0,0 +1,153 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// TPMLimiter TPM限流器：统计提供商最近 60 秒内消耗的 total_tokens
+type TPMLimiter struct {
+	redis  *redis.Client
+	mu     sync.Mutex
+	memory map[uint][]tokenUsage // 内存存储，当Redis不可用时使用
+}
+
+// tokenUsage 一次请求的 token 消耗
+type tokenUsage struct {
+	Timestamp int64
+	Tokens    int64
+}
+
+// NewTPMLimiter 创建新的TPM限流器
+func NewTPMLimiter(redisClient *redis.Client) *TPMLimiter {
+	return &TPMLimiter{
+		redis:  redisClient,
+		memory: make(map[uint][]tokenUsage),
+	}
+}
+
+// CheckTPMLimit 检查是否达到TPM限制；窗口内已用 token 未达上限即放行
+func (t *TPMLimiter) CheckTPMLimit(ctx context.Context, providerID uint, tpmLimit int) (bool, error) {
+	// 0表示无限制
+	if tpmLimit <= 0 {
+		return true, nil
+	}
+	used, err := t.GetCurrentTPM(ctx, providerID)
+	if err != nil {
+		return false, err
+	}
+	return used < int64(tpmLimit), nil
+}
+
+// RecordTokens 记录一次请求消耗的 token
+func (t *TPMLimiter) RecordTokens(ctx context.Context, providerID uint, tokens int64) error {
+	if tokens <= 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+
+	if t.redis != nil {
+		return t.recordTokensRedis(ctx, providerID, tokens, now)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.memory[providerID] = append(pruneTokenUsage(t.memory[providerID], now-60), tokenUsage{Timestamp: now, Tokens: tokens})
+	return nil
+}
+
+// GetCurrentTPM 获取最近 60 秒内已消耗的 token 数
+func (t *TPMLimiter) GetCurrentTPM(ctx context.Context, providerID uint) (int64, error) {
+	windowStart := time.Now().Unix() - 60
+
+	if t.redis != nil {
+		return t.getCurrentTPMRedis(ctx, providerID, windowStart)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usages := pruneTokenUsage(t.memory[providerID], windowStart)
+	if len(usages) == 0 {
+		delete(t.memory, providerID)
+		return 0, nil
+	}
+	t.memory[providerID] = usages
+	var total int64
+	for _, u := range usages {
+		total += u.Tokens
+	}
+	return total, nil
+}
+
+// getTPMKey 获取TPM存储键
+func (t *TPMLimiter) getTPMKey(providerID uint) string {
+	return fmt.Sprintf("tpm:provider:%d", providerID)
+}
+
+// ==================== Redis实现 ====================
+
+func (t *TPMLimiter) recordTokensRedis(ctx context.Context, providerID uint, tokens int64, now int64) error {
+	key := t.getTPMKey(providerID)
+
+	// 有序集合 score 为时间戳，member 为 "时间戳-随机后缀:token 数"
+	member := fmt.Sprintf("%d-%d:%d", now, time.Now().UnixNano()%1000000, tokens)
+
+	pipe := t.redis.TxPipeline()
+	pipe.ZAdd(ctx, key, &redis.Z{
+		Score:  float64(now),
+		Member: member,
+	})
+	// 设置过期时间为2分钟
+	pipe.Expire(ctx, key, 2*time.Minute)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("%w: redis tpm record failed: %w", ErrLimiterUnavailable, err)
+	}
+	return nil
+}
+
+func (t *TPMLimiter) getCurrentTPMRedis(ctx context.Context, providerID uint, windowStart int64) (int64, error) {
+	key := t.getTPMKey(providerID)
+
+	pipe := t.redis.TxPipeline()
+	// 移除过期的记录
+	pipe.ZRemRangeByScore(ctx, key, "0", strconv.FormatInt(windowStart, 10))
+	membersCmd := pipe.ZRange(ctx, key, 0, -1)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("%w: redis tpm check failed: %w", ErrLimiterUnavailable, err)
+	}
+
+	var total int64
+	for _, member := range membersCmd.Val() {
+		_, tokens, ok := strings.Cut(member, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(tokens, 10, 64)
+		if err != nil {
+			continue
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// ==================== 内存实现 ====================
+
+// pruneTokenUsage 过滤出窗口内的记录
+func pruneTokenUsage(usages []tokenUsage, windowStart int64) []tokenUsage {
+	valid := usages[:0]
+	for _, u := range usages {
+		if u.Timestamp > windowStart {
+			valid = append(valid, u)
+		}
+	}
+	return valid
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"/":         "",
+		"  ":        "",
+		"llmio":     "/llmio",
+		"/llmio/":   "/llmio",
+		" /a/b/ ":   "/a/b",
+		"//llmio//": "/llmio",
+	}
+	for in, want := range tests {
+		if got := normalizeBasePath(in); got != want {
+			t.Errorf("normalizeBasePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNewRouterUnderBasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orig := indexHTML
+	indexHTML = []byte(`<html><head><script src="/assets/index.js"></script></head></html>`)
+	t.Cleanup(func() { indexHTML = orig })
+
+	router := newRouter("/llmio")
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		contains string
+	}{
+		{"prefixed route", "/llmio/health/live", http.StatusOK, `"status":"alive"`},
+		{"unprefixed route", "/health/live", http.StatusNotFound, "404"},
+		{"spa fallback", "/llmio/providers", http.StatusOK, `src="/llmio/assets/index.js"`},
+		{"spa root", "/llmio", http.StatusOK, `window.__LLMIO_BASE_PATH__="/llmio"`},
+		{"unknown api", "/llmio/api/unknown", http.StatusNotFound, "404"},
+		{"outside prefix", "/other", http.StatusNotFound, "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.status, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Fatalf("body %q missing %q", w.Body.String(), tt.contains)
+			}
+		})
+	}
+}
+
+func TestNewRouterAtRoot(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newRouter("")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
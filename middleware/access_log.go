@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/service"
+)
+
+// AccessLog 代理接口的结构化访问日志（LLMIO_ACCESS_LOG=json 开启），
+// 响应写完后与异步计算的请求日志汇合，每个请求输出一行 JSON
+func AccessLog() gin.HandlerFunc {
+	enabled := service.AccessLogEnabled()
+	return func(c *gin.Context) {
+		if !enabled {
+			return
+		}
+		ctx, entry := service.StartAccessLog(c.Request.Context(), c.Request.Method, c.Request.URL.Path, c.ClientIP())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		entry.Responded(c.Writer.Status())
+	}
+}
@@ -102,7 +102,7 @@ func checkAuthKey(c *gin.Context, key string, adminToken string) {
 	}
 	// 检查是否过期
 	if authKey.ExpiresAt != nil && authKey.ExpiresAt.Before(time.Now()) {
-		common.ErrorWithHttpStatus(c, http.StatusUnauthorized, http.StatusUnauthorized, "Token has expired")
+		common.ErrorWithCode(c, http.StatusUnauthorized, http.StatusUnauthorized, common.ErrCodeAuthExpired, "Token has expired")
 		c.Abort()
 		return
 	}
@@ -111,6 +111,9 @@ func checkAuthKey(c *gin.Context, key string, adminToken string) {
 
 	allowAll := authKey.AllowAll == 1
 	ctx = context.WithValue(ctx, consts.ContextKeyAuthKeyID, authKey.ID)
+	ctx = context.WithValue(ctx, consts.ContextKeyMaxConcurrency, authKey.MaxConcurrency)
+	ctx = context.WithValue(ctx, consts.ContextKeyRpmLimit, authKey.RpmLimit)
+	ctx = context.WithValue(ctx, consts.ContextKeyMonthlyBudget, authKey.MonthlyBudget)
 	ctx = context.WithValue(ctx, consts.ContextKeyAllowAllModel, allowAll)
 	// 如果不允许所有模型 则设置允许的模型列表
 	if !allowAll {
@@ -16,6 +16,32 @@ const (
 	KeyModelPriceSync = "model_price_sync"
 	// KeyFirstDeployTime 首次部署时间（用于跨重启统计系统总运行时间），值为 RFC3339 时间字符串（UTC）。
 	KeyFirstDeployTime = "first_deploy_time"
+	// KeyWebhookNotifier 告警 webhook 通知配置
+	KeyWebhookNotifier = "webhook_notifier"
+	// KeyCostAlert 按模型的消费告警阈值配置
+	KeyCostAlert = "cost_alert"
+	// KeyModelPolicy 网关全局模型允许/禁止列表（对管理员 Key 同样生效）
+	KeyModelPolicy = "model_policy"
+	// KeySLO 按模型的响应时间 SLO 目标配置
+	KeySLO = "slo"
+	// KeyIdempotency Idempotency-Key 请求去重配置
+	KeyIdempotency = "idempotency"
+	// KeyCostAwareRouting cost_aware 负载均衡策略的质量下限配置
+	KeyCostAwareRouting = "cost_aware_routing"
+	// KeyRequestTimeline 请求生命周期时间线采样配置
+	KeyRequestTimeline = "request_timeline"
+	// KeyAutoWeight 按健康状况自动调整权重任务的配置
+	KeyAutoWeight = "auto_weight"
+	// KeyCountTokensFallback 上游不支持 count_tokens 时的本地估算配置
+	KeyCountTokensFallback = "count_tokens_fallback"
+	// KeyGlobalSystemPrompt 全局系统提示词（如合规声明）配置
+	KeyGlobalSystemPrompt = "global_system_prompt"
+	// KeyProviderModelsCache 提供商上游模型列表缓存配置
+	KeyProviderModelsCache = "provider_models_cache"
+	// KeyResourceLimits API Key / 提供商 / 模型数量上限配置
+	KeyResourceLimits = "resource_limits"
+	// KeyProviderHealthAlert 提供商健康状态变化的 webhook 通知配置
+	KeyProviderHealthAlert = "provider_health_alert"
 )
 
 type AnthropicCountTokens struct {
@@ -33,4 +59,102 @@ type ModelPriceSyncConfig struct {
 	Enabled         bool   `json:"enabled"`
 	IntervalMinutes int    `json:"interval_minutes"`
 	SourceURL       string `json:"source_url"`
+	TimeoutSeconds  int    `json:"timeout_seconds"` // 单次请求超时（秒），默认 20
+	Retries         int    `json:"retries"`         // 失败后的重试次数（指数退避），默认 2
+	UserAgent       string `json:"user_agent"`      // 自定义 User-Agent，为空时使用 Go 默认值
+	Authorization   string `json:"authorization"`   // 可选的 Authorization 请求头（经代理的价格源）
+}
+
+type WebhookNotifierConfig struct {
+	Enabled bool              `json:"enabled"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+type CostAlertConfig struct {
+	Enabled         bool               `json:"enabled"`
+	WindowMinutes   int                `json:"window_minutes"`   // 滑动窗口（分钟），默认 60
+	DebounceMinutes int                `json:"debounce_minutes"` // 同一模型重复告警的最小间隔（分钟），默认 60
+	Thresholds      map[string]float64 `json:"thresholds"`       // 模型名 -> 窗口内消费阈值
+}
+
+type ProviderHealthAlertConfig struct {
+	Enabled         bool              `json:"enabled"`
+	URL             string            `json:"url"`              // 接收通知的 webhook 地址，为空时使用 webhook_notifier 配置
+	Headers         map[string]string `json:"headers"`          // 随通知发送的请求头，仅在设置 url 时使用
+	IntervalSeconds int               `json:"interval_seconds"` // 检查间隔（秒），默认 60
+	WindowMinutes   int               `json:"window_minutes"`   // 健康统计窗口（分钟），默认 15
+	DebounceMinutes int               `json:"debounce_minutes"` // 同一提供商两次通知的最小间隔（分钟），默认 10
+}
+
+// ModelPolicyConfig 全局模型策略，支持 path.Match 风格通配符（如 gpt-3.5-*）
+// Deny 优先；Allow 非空时仅放行匹配的模型
+type ModelPolicyConfig struct {
+	Enabled bool     `json:"enabled"`
+	Allow   []string `json:"allow"`
+	Deny    []string `json:"deny"`
+}
+
+// SLOTarget 单个模型的 SLO 目标，如 p95 首字耗时 < 2000ms
+type SLOTarget struct {
+	Metric      string  `json:"metric"`       // first_chunk 或 proxy
+	Percentile  float64 `json:"percentile"`   // 百分位 (0-100)，如 95
+	ThresholdMs int     `json:"threshold_ms"` // 阈值（毫秒）
+}
+
+type SLOConfig struct {
+	Enabled             bool                 `json:"enabled"`               // 是否开启 SLO 持续违约告警
+	AlertWindowMinutes  int                  `json:"alert_window_minutes"`  // 告警计算窗口（分钟），默认 15
+	AlertSustainMinutes int                  `json:"alert_sustain_minutes"` // 持续违约多久后告警（分钟），默认 5
+	Targets             map[string]SLOTarget `json:"targets"`               // 模型名 -> SLO 目标
+}
+
+type IdempotencyConfig struct {
+	Enabled    bool `json:"enabled"`
+	TTLSeconds int  `json:"ttl_seconds"` // 响应缓存时长（秒），默认 600
+}
+
+type CostAwareRoutingConfig struct {
+	QualityFloor  float64 `json:"quality_floor"`  // 成功率下限 (0-1)，默认 0.8
+	WindowMinutes int     `json:"window_minutes"` // 统计成功率的时间窗口（分钟），默认 15
+	MinRequests   int     `json:"min_requests"`   // 窗口内请求数达到该值才参与质量判断，默认 10
+}
+
+type RequestTimelineConfig struct {
+	Enabled    bool    `json:"enabled"`
+	SampleRate float64 `json:"sample_rate"` // 采样率 (0-1)
+}
+
+type AutoWeightConfig struct {
+	IntervalMinutes int `json:"interval_minutes"`  // 调整间隔（分钟），默认 10
+	WindowMinutes   int `json:"window_minutes"`    // 统计窗口（分钟），默认 30
+	MinRequests     int `json:"min_requests"`      // 窗口内请求数达到该值才下调权重，默认 20
+	MinWeight       int `json:"min_weight"`        // 权重下限，默认 1，保证提供商不会被完全移出路由
+	LatencyTargetMs int `json:"latency_target_ms"` // 平均延迟超过该值时按比例降低权重，默认 10000
+}
+
+// CountTokensFallbackConfig 上游 count_tokens 返回 404 或连接失败时是否本地估算输入 token，未配置时默认开启
+type CountTokensFallbackConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// GlobalSystemPromptConfig 全局系统提示词，插入到提示词模板与客户端系统提示词之前
+// ExemptModels 支持 path.Match 风格通配符；ExemptAuthKeyIDs 为不注入的 API Key ID
+type GlobalSystemPromptConfig struct {
+	Enabled          bool     `json:"enabled"`
+	Prompt           string   `json:"prompt"`
+	ExemptModels     []string `json:"exempt_models"`
+	ExemptAuthKeyIDs []uint   `json:"exempt_auth_key_ids"`
+}
+
+// ProviderModelsCacheConfig 提供商上游模型列表缓存时长（秒），0 表示不缓存；未配置时使用环境变量或默认值
+type ProviderModelsCacheConfig struct {
+	TTLSeconds int `json:"ttl_seconds"`
+}
+
+// ResourceLimitsConfig 多租户部署下的资源数量上限，<= 0 表示不限制
+type ResourceLimitsConfig struct {
+	MaxAuthKeys  int `json:"max_auth_keys"` // 启用中的 API Key 数量上限
+	MaxProviders int `json:"max_providers"`
+	MaxModels    int `json:"max_models"`
 }
@@ -3,7 +3,12 @@ package models
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/racio/llmio/consts"
 	"gorm.io/driver/postgres"
@@ -12,6 +17,59 @@ import (
 
 var DB *gorm.DB
 
+// 连接池默认参数，可通过环境变量覆盖
+const (
+	defaultMaxOpenConns    = 50
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute // 定期轮换连接，避免数据库/中间件重启后长期持有失效连接
+	defaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// configurePool 按环境变量设置连接池参数：
+// DB_MAX_OPEN_CONNS / DB_MAX_IDLE_CONNS / DB_CONN_MAX_LIFETIME_SECONDS / DB_CONN_MAX_IDLE_TIME_SECONDS
+func configurePool(name string, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	maxOpen := envInt("DB_MAX_OPEN_CONNS", defaultMaxOpenConns)
+	maxIdle := envInt("DB_MAX_IDLE_CONNS", defaultMaxIdleConns)
+	if maxOpen > 0 && maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+	lifetime := time.Duration(envInt("DB_CONN_MAX_LIFETIME_SECONDS", int(defaultConnMaxLifetime/time.Second))) * time.Second
+	idleTime := time.Duration(envInt("DB_CONN_MAX_IDLE_TIME_SECONDS", int(defaultConnMaxIdleTime/time.Second))) * time.Second
+
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(lifetime)
+	sqlDB.SetConnMaxIdleTime(idleTime)
+	slog.Info("database pool configured", "db", name, "max_open_conns", maxOpen, "max_idle_conns", maxIdle, "conn_max_lifetime", lifetime, "conn_max_idle_time", idleTime)
+	return nil
+}
+
+// envInt 读取非负整数环境变量，未设置或非法时返回默认值（0 表示不限制）
+func envInt(key string, def int) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		slog.Warn("invalid env value, using default", "key", key, "value", raw, "default", def)
+		return def
+	}
+	return v
+}
+
+// migrated 兼容性数据修复执行完成后置为 true，用于就绪检查
+var migrated atomic.Bool
+
+// MigrationsDone 启动时的兼容性数据修复是否已完成
+func MigrationsDone() bool {
+	return migrated.Load()
+}
+
 // Init 初始化数据库连接（仅支持 PostgreSQL）
 // postgres:
 // - key=value DSN: host=localhost user=postgres password=postgres dbname=llmio port=5432 sslmode=disable
@@ -26,6 +84,9 @@ func Init(ctx context.Context, dsn string) {
 	if err != nil {
 		panic(err)
 	}
+	if err := configurePool("primary", db); err != nil {
+		panic(err)
+	}
 	DB = db
 
 	// 兼容性数据修复
@@ -47,4 +108,5 @@ func Init(ctx context.Context, dsn string) {
 	if _, err := gorm.G[ChatLog](DB).Where("auth_key_id IS NULL").Update(ctx, "auth_key_id", 0); err != nil {
 		// 忽略错误
 	}
+	migrated.Store(true)
 }
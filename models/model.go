@@ -9,12 +9,19 @@ import (
 
 type Provider struct {
 	gorm.Model
-	Name          string
-	Type          string
-	Config        string
-	Console       string // 控制台地址
-	RpmLimit      int    // 每分钟请求数限制
-	IpLockMinutes int    // IP 锁定时间（分钟）
+	Name           string
+	Type           string
+	Config         string
+	Console        string // 控制台地址
+	RpmLimit       int    // 每分钟请求数限制
+	TpmLimit       int    // 每分钟 token 数限制
+	IpLockMinutes  int    // IP 锁定时间（分钟）
+	KeepWarm       int    // 是否定期保活连接 (0/1)
+	MaxConcurrency int    // 同时在途请求数上限，0 表示不限制
+	// SuccessStatusCodes 视为成功的上游 HTTP 状态码（逗号分隔，如 "200,201"），空表示仅 200
+	SuccessStatusCodes string
+	// DisabledUntil 在此时间前不参与路由（手动安排或上游额度耗尽时自动设置），为空表示未停用，过期后自动清除
+	DisabledUntil *time.Time
 }
 
 type AnthropicConfig struct {
@@ -25,14 +32,31 @@ type AnthropicConfig struct {
 
 type Model struct {
 	gorm.Model
-	Name     string
-	Remark   string
-	MaxRetry int    // 重试次数限制
-	TimeOut  int    // 超时时间 单位秒
-	IOLog    int    // 是否记录IO (0/1)
-	Strategy string // 负载均衡策略 默认 lottery
-	Breaker  int    // 是否开启熔断 (0/1)
-	Status   int    // 是否启用 (0/1)
+	Name                   string
+	Remark                 string
+	MaxRetry               int    // 重试次数限制
+	TimeOut                int    // 超时时间 单位秒
+	IOLog                  int    // 是否记录IO (0/1)
+	Strategy               string // 负载均衡策略 默认 lottery
+	Breaker                int    // 是否开启熔断 (0/1)
+	Status                 int    // 是否启用 (0/1)
+	MaxInputTokens         int    // 最大输入 token 数，>0 时 Gemini 请求转发前调用 countTokens 预检
+	TokenLockSeconds       int    // token 独占锁时长（秒），0 表示关闭
+	MaxProvidersPerRequest int    // 单次请求最多尝试的不同提供商数，0 表示不限制
+	AutoWeight             int    // 是否按健康状况自动调整关联权重 (0/1)
+	CacheTTLSeconds        int    `gorm:"column:cache_ttl_seconds"` // 非流式响应缓存时长（秒），0 表示关闭
+	MinWeight              int    // 失败降权后保留的最低权重，保证恢复后仍有机会被选中，0 表示不设下限
+	// 熔断参数，0 表示使用默认值（连续失败 5 次熔断、冷却 60 秒、半开状态成功 2 次恢复）
+	BreakerMaxFailures      int
+	BreakerSleepSeconds     int
+	BreakerHalfOpenRequests int
+	FallbackModel           string // 全部提供商失败后改用的备用模型名称，为空表示不启用（最多切换一次）
+	StreamMaxSeconds        int    // 流式响应总时长上限（秒），超时后截断响应，0 表示不限制
+	// 推理预算上限，客户端请求超出时转发前压低到该值，0/空表示不限制
+	MaxThinkingTokens   int    // Anthropic thinking.budget_tokens 上限
+	MaxReasoningEffort  string // OpenAI 推理强度上限（none/minimal/low/medium/high/xhigh）
+	MaxCompletionTokens int    // OpenAI max_completion_tokens / max_output_tokens 上限
+	EchoModel           int    // 是否将响应中的模型名替换为网关模型名 (0/1)
 }
 
 type ModelWithProvider struct {
@@ -47,11 +71,17 @@ type ModelWithProvider struct {
 	Status           int    // 是否启用 (0/1)
 	CustomerHeaders  string // 自定义headers (JSON)
 	Weight           int
+	BaseWeight       int     // 人工配置的权重，自动调权任务只在 [MinWeight, BaseWeight] 区间内调整 Weight
+	Shadow           int     // 是否为影子提供商 (0/1)：不参与正式路由，仅异步复制请求用于对比
+	ShadowRate       float64 // 影子请求采样率 (0-1)
+	RpmLimit         int     // 该关联每分钟请求数限制，与提供商 RpmLimit 同时生效，0 表示无限制
+	ExcludeRules     string  // 排除规则 (JSON)，请求属性满足任一规则时本次请求不使用该关联
 }
 
 type ChatLog struct {
 	gorm.Model
 	UUID          string `gorm:"column:uuid"`
+	RequestID     string `gorm:"column:request_id;index"` // 同一客户端请求的各次重试及最终汇总记录共享
 	Name          string `gorm:"index"`
 	ProviderModel string `gorm:"index"`
 	ProviderName  string `gorm:"index"`
@@ -61,6 +91,10 @@ type ChatLog struct {
 	RemoteIP      string // 访问ip
 	AuthKeyID     uint   `gorm:"index"` // 使用的AuthKey ID
 	ChatIO        int    // 是否开启IO记录 (0/1)
+	Dedup         int    // 是否为 Idempotency-Key 重放 (0/1)
+	CacheHit      int    // 是否为响应缓存命中 (0/1)：未请求上游，不产生 IO 记录，ProviderName 为缓存响应的原提供商
+	Timeline      string `json:"-"` // 采样请求的生命周期时间线 (JSON)，通过 /api/logs/:id/timeline 查询
+	FallbackFrom  string // 由原模型全部提供商失败后切换到备用模型时，记录原模型名称
 
 	Error            string // if status is error, this field will be set
 	Retry            int    // 重试次数
@@ -92,8 +126,32 @@ type Usage struct {
 }
 
 type PromptTokensDetails struct {
-	CachedTokens int64 `json:"cached_tokens"`
-	AudioTokens  int64 `json:"audio_tokens"`
+	CachedTokens        int64 `json:"cached_tokens"`
+	AudioTokens         int64 `json:"audio_tokens"`
+	CacheCreationTokens int64 `json:"cache_creation_tokens,omitempty"` // Anthropic 写入缓存的 token，不包含在 PromptTokens 中
+}
+
+// ShadowLog 影子提供商的请求记录，响应内容被丢弃，仅保留性能与用量数据
+type ShadowLog struct {
+	gorm.Model
+	Name             string `gorm:"index"`
+	ProviderModel    string
+	ProviderName     string `gorm:"index"`
+	Status           string // error or success
+	Style            string
+	AuthKeyID        uint
+	Error            string
+	ProxyTimeMs      int `gorm:"column:proxy_time_ms"`
+	FirstChunkTimeMs int `gorm:"column:first_chunk_time_ms"`
+	ChunkTimeMs      int `gorm:"column:chunk_time_ms"`
+	Tps              float64
+	Size             int
+	Usage
+}
+
+// TableName 指定表名
+func (ShadowLog) TableName() string {
+	return "shadow_logs"
 }
 
 type ChatIO struct {
@@ -102,6 +160,7 @@ type ChatIO struct {
 	Input             string
 	OutputString      string `gorm:"column:output_string"`
 	OutputStringArray string `gorm:"column:output_string_array"` // JSON 数组字符串
+	BlobKey           string `gorm:"column:blob_key"`            // 内容存放在对象存储时的对象 key，此时以上内容字段为空
 }
 
 // TableName 指定表名
@@ -130,9 +189,27 @@ type AuthKey struct {
 	ExpiresAt  *time.Time // nil=永不过期，有值=具体过期时间
 	UsageCount int64      // 使用次数统计
 	LastUsedAt *time.Time // 最后使用时间
+	// MaxConcurrency 同时在途的最大请求数 (0=不限制)
+	MaxConcurrency int
+	// RpmLimit 每分钟最大请求数 (0=不限制)
+	RpmLimit int
+	// MonthlyBudget 每自然月的消费上限 (0=不限制)，按 chat_logs.total_cost 累计
+	MonthlyBudget float64
 }
 
 // TableName 指定表名
 func (AuthKey) TableName() string {
 	return "auth_keys"
 }
+
+// PromptTemplate 服务端集中管理的系统提示词模板，请求可通过请求头按名称引用
+type PromptTemplate struct {
+	gorm.Model
+	Name    string `gorm:"index"`
+	Content string // 支持 {{var}} 变量占位
+}
+
+// TableName 指定表名
+func (PromptTemplate) TableName() string {
+	return "prompt_templates"
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"strings"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// ReadDB 只读副本连接，用于统计/健康/日志等分析类查询；未配置时为 nil
+var ReadDB *gorm.DB
+
+// InitReplica 初始化只读副本连接（DATABASE_REPLICA_DSN），dsn 为空时不启用
+func InitReplica(dsn string) error {
+	dsn = strings.TrimSpace(dsn)
+	if dsn == "" {
+		return nil
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+	if err := configurePool("replica", db); err != nil {
+		return err
+	}
+	ReadDB = db
+	return nil
+}
+
+// Reader 返回分析类只读查询使用的连接：配置了副本时使用副本，否则回退到主库
+// 写入以及请求链路上的读取仍应使用 DB，避免复制延迟带来的不一致
+func Reader() *gorm.DB {
+	if ReadDB != nil {
+		return ReadDB
+	}
+	return DB
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	readRetryAttempts = 3
+	readRetryBackoff  = 50 * time.Millisecond
+)
+
+// RetryRead 对请求链路上的关键读查询做有限次退避重试，仅在连接类瞬时错误时重试
+// 用于数据库短暂断连时避免直接让客户端请求失败；写操作不应使用，避免重复写入
+func RetryRead[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var (
+		result T
+		err    error
+	)
+	backoff := readRetryBackoff
+	for attempt := 0; attempt < readRetryAttempts; attempt++ {
+		result, err = fn()
+		if err == nil || !IsTransientDBError(err) || attempt == readRetryAttempts-1 {
+			return result, err
+		}
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return result, err
+}
+
+// IsTransientDBError 判断是否为连接断开/超时等可重试的数据库错误
+// 与 SaveChatLog 一致按错误文本匹配 SQLSTATE，不引入 driver 相关依赖
+func IsTransientDBError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := err.Error()
+	// SQLSTATE 08xxx: connection exception；57P01-57P03: admin shutdown / cannot connect now
+	for _, code := range []string{"SQLSTATE 08", "SQLSTATE 57P01", "SQLSTATE 57P02", "SQLSTATE 57P03"} {
+		if strings.Contains(msg, code) {
+			return true
+		}
+	}
+	for _, text := range []string{"connection refused", "connection reset", "broken pipe", "conn closed", "failed to connect"} {
+		if strings.Contains(msg, text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestRetryRead(t *testing.T) {
+	transient := fmt.Errorf("query: %w", driver.ErrBadConn)
+	tests := []struct {
+		name      string
+		errs      []error // 每次调用依次返回的错误，超出部分返回 nil
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", nil, 1, nil},
+		{"recovers after transient", []error{transient, transient}, 3, nil},
+		{"gives up after attempts", []error{transient, transient, transient, transient}, readRetryAttempts, driver.ErrBadConn},
+		{"no retry on not found", []error{gorm.ErrRecordNotFound}, 1, gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			got, err := RetryRead(context.Background(), func() (int, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return 0, tt.errs[calls-1]
+				}
+				return 42, nil
+			})
+			if calls != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != 42 {
+				t.Fatalf("got %d, %v; want 42, nil", got, err)
+			}
+		})
+	}
+}
+
+func TestRetryReadStopsOnContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	start := time.Now()
+	_, err := RetryRead(ctx, func() (int, error) {
+		calls++
+		return 0, driver.ErrBadConn
+	})
+	if calls != 1 || !errors.Is(err, driver.ErrBadConn) {
+		t.Fatalf("calls = %d, err = %v; want 1 call returning the query error", calls, err)
+	}
+	if elapsed := time.Since(start); elapsed >= readRetryBackoff {
+		t.Fatalf("waited %v after cancellation", elapsed)
+	}
+}
+
+func TestIsTransientDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"bad conn", driver.ErrBadConn, true},
+		{"unexpected eof", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"net error", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{"connection exception", errors.New("ERROR: terminating connection (SQLSTATE 08006)"), true},
+		{"admin shutdown", errors.New("FATAL: terminating connection due to administrator command (SQLSTATE 57P01)"), true},
+		{"failed to connect", errors.New("failed to connect to `host=db`: dial error"), true},
+		{"not found", gorm.ErrRecordNotFound, false},
+		{"unique violation", errors.New("duplicate key value (SQLSTATE 23505)"), false},
+		{"syntax error", errors.New("syntax error at or near (SQLSTATE 42601)"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransientDBError(tt.err); got != tt.want {
+				t.Fatalf("IsTransientDBError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
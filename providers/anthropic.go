@@ -17,6 +17,19 @@ type Anthropic struct {
 	BaseURL string `json:"base_url"`
 	APIKey  string `json:"api_key"`
 	Version string `json:"version"`
+	// RawBaseURL 为 true 时不自动补全版本段，按 base_url 原样拼接
+	RawBaseURL bool `json:"raw_base_url"`
+	// ExtraBody 固定附加到请求体的参数，深度合并且客户端传入的值优先
+	ExtraBody json.RawMessage `json:"extra_body"`
+	// QueryParams 附加到请求地址的查询参数（如 api-version、region），地址中已有非空值的参数保持不变
+	QueryParams map[string]string `json:"query_params"`
+}
+
+// anthropicEndpointSuffixes base_url 中可能误带的接口路径
+var anthropicEndpointSuffixes = []string{"/messages/count_tokens", "/messages", "/models"}
+
+func (a *Anthropic) baseURL() string {
+	return resolveBaseURL(a.BaseURL, "v1", a.RawBaseURL, anthropicEndpointSuffixes...)
 }
 
 func appendQueryParam(rawURL string, key string, value string) (string, error) {
@@ -38,11 +51,19 @@ func (a *Anthropic) BuildReq(ctx context.Context, header http.Header, model stri
 	if err != nil {
 		return nil, err
 	}
-	rawURL := fmt.Sprintf("%s/messages", a.BaseURL)
+	body, err = mergeExtraBody(body, a.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+	rawURL := fmt.Sprintf("%s/messages", a.baseURL())
 	rawURL, err = appendQueryParam(rawURL, "beta", "true")
 	if err != nil {
 		return nil, err
 	}
+	rawURL, err = mergeQueryParams(rawURL, a.QueryParams)
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
@@ -73,7 +94,7 @@ type AnthropicModel struct {
 }
 
 func (a *Anthropic) Models(ctx context.Context) ([]Model, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", a.BaseURL), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", a.baseURL()), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +127,7 @@ func (a *Anthropic) Models(ctx context.Context) ([]Model, error) {
 }
 
 func (a *Anthropic) BuildCountTokensReq(ctx context.Context, header http.Header, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/messages/count_tokens", a.BaseURL), body)
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/messages/count_tokens", a.baseURL()), body)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,136 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/racio/llmio/consts"
+	"github.com/tidwall/sjson"
+)
+
+// Azure 调用 Azure OpenAI 部署，协议与 OpenAI Chat Completions 相同
+// 请求地址: {endpoint}/openai/deployments/{deployment}/chat/completions?api-version={api_version}
+type Azure struct {
+	Endpoint   string `json:"endpoint"`
+	APIKey     string `json:"api_key"`
+	APIVersion string `json:"api_version"`
+	// Deployment 部署名称，为空时使用模型关联中的提供商模型名作为部署名
+	Deployment string `json:"deployment"`
+	// ExtraBody 固定附加到请求体的参数，深度合并且客户端传入的值优先
+	ExtraBody json.RawMessage `json:"extra_body"`
+	// QueryParams 附加到请求地址的查询参数（如 api-version、region），地址中已有非空值的参数保持不变
+	QueryParams map[string]string `json:"query_params"`
+	// UserPolicy 请求体 user 字段策略：keep（默认）/ inject / strip
+	UserPolicy string `json:"user_policy"`
+	// StripStreamOptions 为 true 时转发前删除 stream_options，适配不识别该字段的上游
+	StripStreamOptions bool `json:"strip_stream_options"`
+}
+
+func (a *Azure) StripsStreamOptions() bool {
+	return a.StripStreamOptions
+}
+
+func (a *Azure) endpoint() string {
+	return strings.TrimRight(strings.TrimSpace(a.Endpoint), "/")
+}
+
+func (a *Azure) deploymentURL(deployment string, path string) string {
+	return fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s", a.endpoint(), url.PathEscape(deployment), path, url.QueryEscape(a.APIVersion))
+}
+
+func (a *Azure) BuildReq(ctx context.Context, header http.Header, model string, rawBody []byte) (*http.Request, error) {
+	body, err := sjson.SetBytes(rawBody, "model", model)
+	if err != nil {
+		return nil, err
+	}
+	body, err = mergeExtraBody(body, a.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+	body, err = applyUserPolicy(ctx, body, a.UserPolicy)
+	if err != nil {
+		return nil, err
+	}
+	body, err = stripStreamOptions(body, a.StripStreamOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment := strings.TrimSpace(a.Deployment)
+	if deployment == "" {
+		deployment = model
+	}
+	endpoint, _ := ctx.Value(consts.ContextKeyOpenAIEndpoint).(string)
+	path := "chat/completions"
+	if strings.EqualFold(strings.TrimSpace(endpoint), "embeddings") {
+		path = "embeddings"
+	}
+	rawURL, err := mergeQueryParams(a.deploymentURL(deployment, path), a.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		req.Header = header
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Azure 使用 api-key 头鉴权，移除透传的 Authorization 避免冲突
+	req.Header.Del("Authorization")
+	req.Header.Set("api-key", a.APIKey)
+
+	return req, nil
+}
+
+type azureDeploymentsResponse struct {
+	Data []struct {
+		ID        string `json:"id"`
+		Model     string `json:"model"`
+		CreatedAt int64  `json:"created_at"`
+	} `json:"data"`
+}
+
+// Models 列出该资源下的部署（部署名即请求时使用的模型名）
+func (a *Azure) Models(ctx context.Context) ([]Model, error) {
+	// api-version 可能只配置在 query_params 中，列部署时同样需要
+	rawURL, err := mergeQueryParams(fmt.Sprintf("%s/openai/deployments?api-version=%s", a.endpoint(), url.QueryEscape(a.APIVersion)), a.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("api-key", a.APIKey)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d", res.StatusCode)
+	}
+
+	var deployments azureDeploymentsResponse
+	if err := json.NewDecoder(res.Body).Decode(&deployments); err != nil {
+		return nil, err
+	}
+	modelList := make([]Model, 0, len(deployments.Data))
+	for _, deployment := range deployments.Data {
+		modelList = append(modelList, Model{
+			ID:      deployment.ID,
+			Object:  "model",
+			Created: deployment.CreatedAt,
+			OwnedBy: deployment.Model,
+		})
+	}
+	return modelList, nil
+}
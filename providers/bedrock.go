@@ -0,0 +1,211 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// bedrockAnthropicVersion Bedrock 上 Anthropic 模型要求的请求体版本字段
+const bedrockAnthropicVersion = "bedrock-2023-05-31"
+
+// Bedrock 通过 AWS Bedrock Runtime 调用 Anthropic 模型，请求体为 Anthropic messages 格式
+// 请求地址: https://bedrock-runtime.{region}.amazonaws.com/model/{model}/invoke(-with-response-stream)，使用 SigV4 签名
+type Bedrock struct {
+	Region       string `json:"region"`
+	AccessKey    string `json:"access_key"`
+	SecretKey    string `json:"secret_key"`
+	SessionToken string `json:"session_token"`
+	// Endpoint 自定义 Bedrock Runtime 地址（如 VPC 终端节点），为空时按 region 生成
+	Endpoint string `json:"endpoint"`
+	// ExtraBody 固定附加到请求体的参数，深度合并且客户端传入的值优先
+	ExtraBody json.RawMessage `json:"extra_body"`
+	// QueryParams 附加到请求地址的查询参数（如 api-version、region），地址中已有非空值的参数保持不变
+	QueryParams map[string]string `json:"query_params"`
+}
+
+func (b *Bedrock) endpoint() string {
+	if endpoint := strings.TrimRight(strings.TrimSpace(b.Endpoint), "/"); endpoint != "" {
+		return endpoint
+	}
+	return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", b.Region)
+}
+
+func (b *Bedrock) BuildReq(ctx context.Context, header http.Header, model string, rawBody []byte) (*http.Request, error) {
+	body, err := mergeExtraBody(rawBody, b.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+	stream := gjson.GetBytes(body, "stream").Bool()
+	// 模型与流式由 URL 决定，Bedrock 不接受请求体中的 model/stream 字段
+	body, err = sjson.DeleteBytes(body, "model")
+	if err != nil {
+		return nil, err
+	}
+	body, err = sjson.DeleteBytes(body, "stream")
+	if err != nil {
+		return nil, err
+	}
+	if !gjson.GetBytes(body, "anthropic_version").Exists() {
+		body, err = sjson.SetBytes(body, "anthropic_version", bedrockAnthropicVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if header == nil {
+		header = http.Header{}
+	}
+	// Bedrock 不识别 anthropic-beta 请求头，改为请求体中的 anthropic_beta 数组
+	if beta := header.Get("anthropic-beta"); beta != "" && !gjson.GetBytes(body, "anthropic_beta").Exists() {
+		var betas []string
+		for item := range strings.SplitSeq(beta, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				betas = append(betas, item)
+			}
+		}
+		body, err = sjson.SetBytes(body, "anthropic_beta", betas)
+		if err != nil {
+			return nil, err
+		}
+	}
+	header.Del("anthropic-beta")
+	header.Del("anthropic-version")
+	header.Del("Authorization")
+	header.Del("x-api-key")
+
+	action := "invoke"
+	if stream {
+		action = "invoke-with-response-stream"
+	}
+	// 模型 ID 中的 ":" 等字符按 SigV4 规则编码后放入路径，与签名时使用的规范路径保持一致
+	rawURL := fmt.Sprintf("%s/model/%s/%s", b.endpoint(), bedrockEscapePath(model), action)
+	// 查询参数在签名前合并，参与 SigV4 规范请求
+	rawURL, err = mergeQueryParams(rawURL, b.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	b.sign(req, body, time.Now().UTC())
+	return req, nil
+}
+
+// sign 按 AWS Signature Version 4 为请求签名，只签名 host、content-type 与 x-amz-* 头，透传的客户端头不参与签名
+func (b *Bedrock) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := bedrockSHA256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.SessionToken)
+	}
+
+	signed := map[string]string{
+		"host":                 req.URL.Host,
+		"content-type":         req.Header.Get("Content-Type"),
+		"x-amz-date":           amzDate,
+		"x-amz-content-sha256": payloadHash,
+	}
+	names := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	if b.SessionToken != "" {
+		signed["x-amz-security-token"] = b.SessionToken
+		names = append(names, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(signed[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// 非 S3 服务的规范路径需要在 URL 编码的基础上再编码一次
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		bedrockEscapePath(req.URL.EscapedPath()),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + b.Region + "/bedrock/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, bedrockSHA256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := bedrockHMAC([]byte("AWS4"+b.SecretKey), date)
+	signingKey = bedrockHMAC(signingKey, b.Region)
+	signingKey = bedrockHMAC(signingKey, "bedrock")
+	signingKey = bedrockHMAC(signingKey, "aws4_request")
+	signature := hex.EncodeToString(bedrockHMAC(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", b.AccessKey, scope, signedHeaders, signature))
+}
+
+// bedrockEscapePath 对路径按 SigV4 规则编码：除非保留字符 A-Z a-z 0-9 - _ . ~ 外全部编码，保留 /
+func bedrockEscapePath(path string) string {
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
+
+func bedrockSHA256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func bedrockHMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// bedrockModels Bedrock 上可用的 Anthropic 模型（Bedrock Runtime 没有简单的模型列表接口，使用固定列表）
+var bedrockModels = []string{
+	"anthropic.claude-opus-4-1-20250805-v1:0",
+	"anthropic.claude-opus-4-20250514-v1:0",
+	"anthropic.claude-sonnet-4-5-20250929-v1:0",
+	"anthropic.claude-sonnet-4-20250514-v1:0",
+	"anthropic.claude-haiku-4-5-20251001-v1:0",
+	"anthropic.claude-3-7-sonnet-20250219-v1:0",
+	"anthropic.claude-3-5-sonnet-20241022-v2:0",
+	"anthropic.claude-3-5-haiku-20241022-v1:0",
+	"anthropic.claude-3-haiku-20240307-v1:0",
+}
+
+func (b *Bedrock) Models(ctx context.Context) ([]Model, error) {
+	modelList := make([]Model, 0, len(bedrockModels))
+	for _, id := range bedrockModels {
+		modelList = append(modelList, Model{
+			ID:      id,
+			Object:  "model",
+			OwnedBy: "anthropic",
+		})
+	}
+	return modelList, nil
+}
@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envRefPattern 匹配配置中的 ${ENV_NAME} 环境变量引用
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ResolveConfig 展开提供商配置中的 ${ENV_NAME} 引用，使密钥等敏感信息可以只保存在环境变量/密钥管理中
+// 引用的环境变量未设置时返回错误；替换值按 JSON 字符串转义，避免破坏配置结构
+func ResolveConfig(config string) (string, error) {
+	if !strings.Contains(config, "${") {
+		return config, nil
+	}
+	var missing []string
+	resolved := envRefPattern.ReplaceAllStringFunc(config, func(ref string) string {
+		name := envRefPattern.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+			return ref
+		}
+		escaped, _ := json.Marshal(value)
+		return string(escaped[1 : len(escaped)-1])
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("provider config references unset env var: %s", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
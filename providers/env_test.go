@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+)
+
+func TestResolveConfig(t *testing.T) {
+	t.Setenv("LLMIO_TEST_KEY", "sk-test")
+	t.Setenv("LLMIO_TEST_QUOTED", `a"b\c`)
+	t.Setenv("LLMIO_TEST_EMPTY", "")
+	tests := []struct {
+		name    string
+		config  string
+		want    string
+		wantErr string
+	}{
+		{"no refs", `{"api_key":"literal"}`, `{"api_key":"literal"}`, ""},
+		{"single ref", `{"api_key":"${LLMIO_TEST_KEY}"}`, `{"api_key":"sk-test"}`, ""},
+		{"embedded ref", `{"base_url":"https://x/${LLMIO_TEST_KEY}/v1"}`, `{"base_url":"https://x/sk-test/v1"}`, ""},
+		{"json escaped", `{"api_key":"${LLMIO_TEST_QUOTED}"}`, `{"api_key":"a\"b\\c"}`, ""},
+		{"empty but set", `{"api_key":"${LLMIO_TEST_EMPTY}"}`, `{"api_key":""}`, ""},
+		{"not a ref", `{"api_key":"$LLMIO_TEST_KEY ${1BAD}"}`, `{"api_key":"$LLMIO_TEST_KEY ${1BAD}"}`, ""},
+		{"unset", `{"api_key":"${LLMIO_TEST_UNSET_A}","x":"${LLMIO_TEST_UNSET_B}"}`, "", "LLMIO_TEST_UNSET_A, LLMIO_TEST_UNSET_B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveConfig(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewResolvesEnvRefs(t *testing.T) {
+	t.Setenv("LLMIO_TEST_KEY", "sk-test")
+	p, err := New(consts.StyleOpenAI, `{"base_url":"https://api.openai.com/v1","api_key":"${LLMIO_TEST_KEY}"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.(*OpenAI).APIKey; got != "sk-test" {
+		t.Fatalf("api key = %q, want sk-test", got)
+	}
+
+	if _, err := New(consts.StyleOpenAI, `{"api_key":"${LLMIO_TEST_UNSET_A}"}`); err == nil || !strings.Contains(err.Error(), "LLMIO_TEST_UNSET_A") {
+		t.Fatalf("err = %v, want unset env var error", err)
+	}
+}
@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ValidateExtraBody 校验提供商配置中的 extra_body，必须为 JSON 对象（未配置时通过）
+func ValidateExtraBody(config string) error {
+	extra := gjson.Get(config, "extra_body")
+	if !extra.Exists() || extra.Type == gjson.Null {
+		return nil
+	}
+	if !extra.IsObject() {
+		return errors.New("extra_body must be a JSON object")
+	}
+	return nil
+}
+
+// mergeExtraBody 将提供商配置的 extra_body 深度合并进请求体，冲突时以客户端请求的值为准
+func mergeExtraBody(body []byte, extra []byte) ([]byte, error) {
+	if len(extra) == 0 {
+		return body, nil
+	}
+	extraResult := gjson.ParseBytes(extra)
+	if !extraResult.IsObject() {
+		return body, nil
+	}
+	return mergeJSONObject(body, extraResult)
+}
+
+func mergeJSONObject(base []byte, extra gjson.Result) ([]byte, error) {
+	var err error
+	extra.ForEach(func(key, value gjson.Result) bool {
+		path := escapeJSONPathKey(key.String())
+		existing := gjson.GetBytes(base, path)
+		switch {
+		case !existing.Exists():
+			base, err = sjson.SetRawBytes(base, path, []byte(value.Raw))
+		case existing.IsObject() && value.IsObject():
+			var merged []byte
+			merged, err = mergeJSONObject([]byte(existing.Raw), value)
+			if err == nil {
+				base, err = sjson.SetRawBytes(base, path, merged)
+			}
+		}
+		return err == nil
+	})
+	return base, err
+}
+
+// escapeJSONPathKey 转义 gjson/sjson 路径中的特殊字符，使 key 按字面匹配
+func escapeJSONPathKey(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		switch r {
+		case '.', '*', '?', '|', '#', '@', '\\', ':', '!', '=', '<', '>', '%':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+)
+
+func TestMergeExtraBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		extra string
+		want  string
+	}{
+		{"no extra", `{"model":"m"}`, ``, `{"model":"m"}`},
+		{"non object extra ignored", `{"model":"m"}`, `[1]`, `{"model":"m"}`},
+		{"adds missing keys", `{"model":"m"}`, `{"transforms":["middle-out"]}`, `{"model":"m","transforms":["middle-out"]}`},
+		{"client wins on conflict", `{"model":"m","temperature":0.2}`, `{"temperature":1}`, `{"model":"m","temperature":0.2}`},
+		{"deep merge", `{"provider":{"order":["a"]}}`, `{"provider":{"sort":"throughput","order":["b"]}}`, `{"provider":{"order":["a"],"sort":"throughput"}}`},
+		{"client scalar beats extra object", `{"provider":"x"}`, `{"provider":{"sort":"price"}}`, `{"provider":"x"}`},
+		{"client null kept", `{"seed":null}`, `{"seed":1}`, `{"seed":null}`},
+		{"special chars in key", `{"model":"m"}`, `{"a.b":1,"c*":2}`, `{"model":"m","a.b":1,"c*":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeExtraBody([]byte(tt.body), []byte(tt.extra))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestValidateExtraBody(t *testing.T) {
+	tests := []struct {
+		config  string
+		wantErr bool
+	}{
+		{`{"api_key":"k"}`, false},
+		{`{"extra_body":null}`, false},
+		{`{"extra_body":{"provider":{"sort":"throughput"}}}`, false},
+		{`{"extra_body":"x"}`, true},
+		{`{"extra_body":[1]}`, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateExtraBody(tt.config); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateExtraBody(%s) = %v, wantErr %v", tt.config, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildReqMergesExtraBody(t *testing.T) {
+	extra := `"extra_body":{"metadata":{"team":"infra"},"temperature":1}`
+	tests := []struct {
+		style  string
+		config string
+		body   string
+		want   string
+	}{
+		{
+			consts.StyleOpenAI,
+			`{"base_url":"https://api.example.com/v1","api_key":"k",` + extra + `}`,
+			`{"model":"gw","temperature":0.5}`,
+			`{"model":"upstream-model","temperature":0.5,"metadata":{"team":"infra"}}`,
+		},
+		{
+			consts.StyleAnthropic,
+			`{"base_url":"https://api.example.com/v1","api_key":"k",` + extra + `}`,
+			`{"model":"gw","temperature":0.5,"metadata":{"user_id":"u"}}`,
+			`{"model":"upstream-model","temperature":0.5,"metadata":{"user_id":"u","team":"infra"}}`,
+		},
+		{
+			consts.StyleGemini,
+			`{"base_url":"https://api.example.com/v1beta","api_key":"k",` + extra + `}`,
+			`{"contents":[],"temperature":0.5}`,
+			`{"contents":[],"temperature":0.5,"metadata":{"team":"infra"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			p, err := New(tt.style, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := p.BuildReq(context.Background(), http.Header{}, "upstream-model", []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameJSON(t, body, tt.want)
+		})
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"strings"
 
 	"github.com/racio/llmio/consts"
+	"github.com/tidwall/sjson"
 )
 
 // Gemini 调用 Gemini 原生 REST API。
@@ -17,6 +19,133 @@ import (
 type Gemini struct {
 	BaseURL string `json:"base_url"`
 	APIKey  string `json:"api_key"`
+	// RawBaseURL 为 true 时不自动补全版本段，按 base_url 原样拼接
+	RawBaseURL bool `json:"raw_base_url"`
+	// FieldCase 转发前将已知字段统一为指定命名风格："camel" / "snake"，为空则原样转发
+	FieldCase string `json:"field_case"`
+	// ExtraBody 固定附加到请求体的参数，深度合并且客户端传入的值优先
+	ExtraBody json.RawMessage `json:"extra_body"`
+	// QueryParams 附加到请求地址的查询参数（如 api-version、region），地址中已有非空值的参数保持不变
+	QueryParams map[string]string `json:"query_params"`
+}
+
+// geminiEndpointSuffixes base_url 中可能误带的接口路径（如 .../v1beta/models/gemini-pro:generateContent）
+var geminiEndpointSuffixes = []string{"/models/*", "/models"}
+
+func (g *Gemini) baseURL() string {
+	return resolveBaseURL(g.BaseURL, "v1beta", g.RawBaseURL, geminiEndpointSuffixes...)
+}
+
+const (
+	GeminiFieldCaseCamel = "camel"
+	GeminiFieldCaseSnake = "snake"
+)
+
+// geminiFieldNames Gemini 请求中已知的 camelCase 字段及其 snake_case 形式
+var geminiFieldNames = map[string]string{
+	"systemInstruction":     "system_instruction",
+	"generationConfig":      "generation_config",
+	"safetySettings":        "safety_settings",
+	"cachedContent":         "cached_content",
+	"toolConfig":            "tool_config",
+	"functionCallingConfig": "function_calling_config",
+	"allowedFunctionNames":  "allowed_function_names",
+	"functionDeclarations":  "function_declarations",
+	"functionCall":          "function_call",
+	"functionResponse":      "function_response",
+	"codeExecution":         "code_execution",
+	"googleSearch":          "google_search",
+	"inlineData":            "inline_data",
+	"fileData":              "file_data",
+	"mimeType":              "mime_type",
+	"fileUri":               "file_uri",
+	"responseMimeType":      "response_mime_type",
+	"responseSchema":        "response_schema",
+	"responseJsonSchema":    "response_json_schema",
+	"responseModalities":    "response_modalities",
+	"maxOutputTokens":       "max_output_tokens",
+	"candidateCount":        "candidate_count",
+	"stopSequences":         "stop_sequences",
+	"topP":                  "top_p",
+	"topK":                  "top_k",
+	"presencePenalty":       "presence_penalty",
+	"frequencyPenalty":      "frequency_penalty",
+	"thinkingConfig":        "thinking_config",
+	"thinkingBudget":        "thinking_budget",
+	"includeThoughts":       "include_thoughts",
+}
+
+// GeminiFieldNames 返回字段的 camelCase 与 snake_case 两种写法，不在 geminiFieldNames 中的字段只返回自身
+func GeminiFieldNames(camel string) []string {
+	if snake, ok := geminiFieldNames[camel]; ok {
+		return []string{camel, snake}
+	}
+	return []string{camel}
+}
+
+// geminiOpaqueFields 这些字段的值由用户定义（函数参数、JSON Schema 等），不递归改写其内部键名
+var geminiOpaqueFields = map[string]struct{}{
+	"args":                   {},
+	"response":               {},
+	"parameters":             {},
+	"parametersJsonSchema":   {},
+	"parameters_json_schema": {},
+	"responseSchema":         {},
+	"responseJsonSchema":     {},
+}
+
+// NormalizeGeminiFieldCase 将请求体中已知的 Gemini 字段改写为指定命名风格
+func NormalizeGeminiFieldCase(rawBody []byte, fieldCase string) ([]byte, error) {
+	var rename map[string]string
+	switch fieldCase {
+	case GeminiFieldCaseSnake:
+		rename = geminiFieldNames
+	case GeminiFieldCaseCamel:
+		rename = make(map[string]string, len(geminiFieldNames))
+		for camel, snake := range geminiFieldNames {
+			rename[snake] = camel
+		}
+	default:
+		return rawBody, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(rawBody))
+	decoder.UseNumber()
+	var data any
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	return json.Marshal(renameGeminiFields(data, rename))
+}
+
+func renameGeminiFields(value any, rename map[string]string) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(typed))
+		for key, item := range typed {
+			newKey := key
+			if mapped, ok := rename[key]; ok {
+				newKey = mapped
+			}
+			_, opaque := geminiOpaqueFields[key]
+			if _, ok := geminiOpaqueFields[newKey]; ok {
+				opaque = true
+			}
+			if opaque {
+				result[newKey] = item
+				continue
+			}
+			result[newKey] = renameGeminiFields(item, rename)
+		}
+		return result
+	case []any:
+		for i, item := range typed {
+			typed[i] = renameGeminiFields(item, rename)
+		}
+		return typed
+	default:
+		return value
+	}
 }
 
 func (g *Gemini) BuildReq(ctx context.Context, header http.Header, model string, rawBody []byte) (*http.Request, error) {
@@ -25,22 +154,38 @@ func (g *Gemini) BuildReq(ctx context.Context, header http.Header, model string,
 	method, _ := ctx.Value(consts.ContextKeyGeminiMethod).(string)
 
 	action := "generateContent"
-	urlSuffix := ""
+	params := g.QueryParams
 	if strings.TrimSpace(method) != "" {
 		// 覆盖为指定方法（如 embedContent/batchEmbedContents）；这些方法不支持 SSE
 		action = strings.TrimSpace(method)
 		stream = false
 	} else if stream {
 		action = "streamGenerateContent"
-		urlSuffix = "?alt=sse"
+		// 流式响应固定使用 SSE，覆盖配置中的 alt
+		params = maps.Clone(params)
+		if params == nil {
+			params = make(map[string]string, 1)
+		}
+		params["alt"] = "sse"
 	}
 
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		fmt.Sprintf("%s/models/%s:%s%s", g.BaseURL, model, action, urlSuffix),
-		bytes.NewReader(rawBody),
-	)
+	if g.FieldCase != "" {
+		normalized, err := NormalizeGeminiFieldCase(rawBody, g.FieldCase)
+		if err != nil {
+			return nil, err
+		}
+		rawBody = normalized
+	}
+	rawBody, err := mergeExtraBody(rawBody, g.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+
+	rawURL, err := mergeQueryParams(joinURL(g.baseURL(), fmt.Sprintf("models/%s:%s", model, action)), params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(rawBody))
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +211,7 @@ type geminiModel struct {
 }
 
 func (g *Gemini) Models(ctx context.Context) ([]Model, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/models", strings.TrimRight(g.BaseURL, "/")), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/models", g.baseURL()), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -98,3 +243,57 @@ func (g *Gemini) Models(ctx context.Context) ([]Model, error) {
 	}
 	return models, nil
 }
+
+type geminiCountTokensResponse struct {
+	TotalTokens int64 `json:"totalTokens"`
+}
+
+// CountTokens 调用 Gemini countTokens 统计 generateContent 请求体的输入 token 数
+func (g *Gemini) CountTokens(ctx context.Context, model string, rawBody []byte) (int64, error) {
+	model = strings.TrimPrefix(model, "models/")
+
+	if g.FieldCase != "" {
+		normalized, err := NormalizeGeminiFieldCase(rawBody, g.FieldCase)
+		if err != nil {
+			return 0, err
+		}
+		rawBody = normalized
+	}
+	// 包装为 generateContentRequest，使 systemInstruction/tools 也计入统计
+	generateReq, err := sjson.SetBytes(rawBody, "model", "models/"+model)
+	if err != nil {
+		return 0, err
+	}
+	body, err := sjson.SetRawBytes([]byte(`{}`), "generateContentRequest", generateReq)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("%s/models/%s:countTokens", g.baseURL(), model),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", g.APIKey)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status code: %d", res.StatusCode)
+	}
+
+	var resp geminiCountTokensResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, err
+	}
+	return resp.TotalTokens, nil
+}
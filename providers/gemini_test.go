@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeGeminiFieldCase(t *testing.T) {
+	camel := `{"contents":[{"role":"user","parts":[{"inlineData":{"mimeType":"image/png","data":"AA=="}},{"functionCall":{"name":"f","args":{"someArg":1}}}]}],"systemInstruction":{"parts":[{"text":"hi"}]},"generationConfig":{"maxOutputTokens":16,"responseJsonSchema":{"properties":{"fooBar":{"type":"string"}}}},"tools":[{"functionDeclarations":[{"name":"f","parameters":{"properties":{"someArg":{"type":"integer"}}}}]}]}`
+	snake := `{"contents":[{"role":"user","parts":[{"inline_data":{"mime_type":"image/png","data":"AA=="}},{"function_call":{"name":"f","args":{"someArg":1}}}]}],"system_instruction":{"parts":[{"text":"hi"}]},"generation_config":{"max_output_tokens":16,"response_json_schema":{"properties":{"fooBar":{"type":"string"}}}},"tools":[{"function_declarations":[{"name":"f","parameters":{"properties":{"someArg":{"type":"integer"}}}}]}]}`
+
+	tests := []struct {
+		name      string
+		in        string
+		fieldCase string
+		want      string
+	}{
+		{"camel to snake", camel, GeminiFieldCaseSnake, snake},
+		{"snake to camel", snake, GeminiFieldCaseCamel, camel},
+		{"snake stays snake", snake, GeminiFieldCaseSnake, snake},
+		{"unknown case passes through", camel, "", camel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeGeminiFieldCase([]byte(tt.in), tt.fieldCase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestNormalizeGeminiFieldCaseKeepsNumbers(t *testing.T) {
+	got, err := NormalizeGeminiFieldCase([]byte(`{"generationConfig":{"seed":12345678901234567890}}`), GeminiFieldCaseSnake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"generation_config":{"seed":12345678901234567890}}` {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestGeminiFieldNames(t *testing.T) {
+	if got := GeminiFieldNames("inlineData"); !reflect.DeepEqual(got, []string{"inlineData", "inline_data"}) {
+		t.Fatalf("GeminiFieldNames(inlineData) = %v", got)
+	}
+	if got := GeminiFieldNames("contents"); !reflect.DeepEqual(got, []string{"contents"}) {
+		t.Fatalf("GeminiFieldNames(contents) = %v", got)
+	}
+}
+
+func assertSameJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("invalid json %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+}
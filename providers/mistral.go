@@ -0,0 +1,113 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/racio/llmio/consts"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Mistral 调用 Mistral La Plateforme（api.mistral.ai），请求体沿用 OpenAI Chat Completions 格式
+// 流式响应的最后一个分块总是携带 usage，不需要也不接受 stream_options
+type Mistral struct {
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"`
+	// SafePrompt 为 true 时默认开启 Mistral 的安全提示词（safe_prompt），客户端显式传入时以客户端为准
+	SafePrompt bool `json:"safe_prompt"`
+	// ExtraBody 固定附加到请求体的参数，深度合并且客户端传入的值优先
+	ExtraBody json.RawMessage `json:"extra_body"`
+	// QueryParams 附加到请求地址的查询参数（如 api-version、region），地址中已有非空值的参数保持不变
+	QueryParams map[string]string `json:"query_params"`
+}
+
+func (m *Mistral) baseURL() string {
+	baseURL := m.BaseURL
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = "https://api.mistral.ai/v1"
+	}
+	return resolveBaseURL(baseURL, "v1", false, openAIEndpointSuffixes...)
+}
+
+func (m *Mistral) BuildReq(ctx context.Context, header http.Header, model string, rawBody []byte) (*http.Request, error) {
+	body, err := sjson.SetBytes(rawBody, "model", model)
+	if err != nil {
+		return nil, err
+	}
+	body, err = mergeExtraBody(body, m.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+	// 网关为记录用量注入的 stream_options 会被 Mistral 拒绝
+	body, err = sjson.DeleteBytes(body, "stream_options")
+	if err != nil {
+		return nil, err
+	}
+	// Mistral 只识别 max_tokens
+	if maxTokens := gjson.GetBytes(body, "max_completion_tokens"); maxTokens.Exists() {
+		if !gjson.GetBytes(body, "max_tokens").Exists() {
+			body, err = sjson.SetRawBytes(body, "max_tokens", []byte(maxTokens.Raw))
+			if err != nil {
+				return nil, err
+			}
+		}
+		body, err = sjson.DeleteBytes(body, "max_completion_tokens")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	endpoint, _ := ctx.Value(consts.ContextKeyOpenAIEndpoint).(string)
+	path := "chat/completions"
+	if strings.EqualFold(strings.TrimSpace(endpoint), "embeddings") {
+		path = "embeddings"
+	} else if m.SafePrompt && !gjson.GetBytes(body, "safe_prompt").Exists() {
+		body, err = sjson.SetBytes(body, "safe_prompt", true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rawURL, err := mergeQueryParams(joinURL(m.baseURL(), path), m.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		req.Header = header
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.APIKey))
+
+	return req, nil
+}
+
+func (m *Mistral) Models(ctx context.Context) ([]Model, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", joinURL(m.baseURL(), "models"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.APIKey))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d", res.StatusCode)
+	}
+
+	var modelList ModelList
+	if err := json.NewDecoder(res.Body).Decode(&modelList); err != nil {
+		return nil, err
+	}
+	return modelList.Data, nil
+}
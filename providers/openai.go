@@ -15,6 +15,41 @@ import (
 type OpenAI struct {
 	BaseURL string `json:"base_url"`
 	APIKey  string `json:"api_key"`
+	// RawBaseURL 为 true 时不自动补全版本段，按 base_url 原样拼接
+	RawBaseURL bool `json:"raw_base_url"`
+	// ExtraBody 固定附加到请求体的参数，深度合并且客户端传入的值优先
+	ExtraBody json.RawMessage `json:"extra_body"`
+	// QueryParams 附加到请求地址的查询参数（如 api-version、region），地址中已有非空值的参数保持不变
+	QueryParams map[string]string `json:"query_params"`
+	// UserPolicy 请求体 user 字段策略：keep（默认）/ inject / strip
+	UserPolicy string `json:"user_policy"`
+	// SkipAuth 为 true 时不发送 Authorization 头（如本地 Ollama），api_key 为空时同样不发送
+	SkipAuth bool `json:"skip_auth"`
+	// StripStreamOptions 为 true 时转发前删除 stream_options，适配不识别该字段的上游
+	StripStreamOptions bool `json:"strip_stream_options"`
+}
+
+func (o *OpenAI) StripsStreamOptions() bool {
+	return o.StripStreamOptions
+}
+
+// openAIEndpointSuffixes base_url 中可能误带的接口路径，拼接前去掉，避免出现 .../chat/completions/chat/completions
+var openAIEndpointSuffixes = []string{"/chat/completions", "/embeddings", "/models"}
+
+func (o *OpenAI) baseURL() string {
+	if o.RawBaseURL {
+		return resolveBaseURL(o.BaseURL, "v1", true)
+	}
+	return resolveBaseURL(o.BaseURL, "v1", false, openAIEndpointSuffixes...)
+}
+
+// setAuth 设置鉴权头；无需鉴权时同时移除透传的 Authorization，避免空 Bearer 被本地服务拒绝
+func (o *OpenAI) setAuth(header http.Header) {
+	if o.SkipAuth || strings.TrimSpace(o.APIKey) == "" {
+		header.Del("Authorization")
+		return
+	}
+	header.Set("Authorization", fmt.Sprintf("Bearer %s", o.APIKey))
 }
 
 func (o *OpenAI) BuildReq(ctx context.Context, header http.Header, model string, rawBody []byte) (*http.Request, error) {
@@ -22,14 +57,29 @@ func (o *OpenAI) BuildReq(ctx context.Context, header http.Header, model string,
 	if err != nil {
 		return nil, err
 	}
+	body, err = mergeExtraBody(body, o.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+	body, err = applyUserPolicy(ctx, body, o.UserPolicy)
+	if err != nil {
+		return nil, err
+	}
+	body, err = stripStreamOptions(body, o.StripStreamOptions)
+	if err != nil {
+		return nil, err
+	}
 
 	endpoint, _ := ctx.Value(consts.ContextKeyOpenAIEndpoint).(string)
 	path := "chat/completions"
 	if strings.EqualFold(strings.TrimSpace(endpoint), "embeddings") {
 		path = "embeddings"
 	}
-	base := strings.TrimRight(o.BaseURL, "/")
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/%s", base, path), bytes.NewReader(body))
+	rawURL, err := mergeQueryParams(joinURL(o.baseURL(), path), o.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -37,17 +87,17 @@ func (o *OpenAI) BuildReq(ctx context.Context, header http.Header, model string,
 		req.Header = header
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", o.APIKey))
+	o.setAuth(req.Header)
 
 	return req, nil
 }
 
 func (o *OpenAI) Models(ctx context.Context) ([]Model, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", o.BaseURL), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", joinURL(o.baseURL(), "models"), nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", o.APIKey))
+	o.setAuth(req.Header)
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
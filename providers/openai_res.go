@@ -14,6 +14,21 @@ import (
 type OpenAIRes struct {
 	BaseURL string `json:"base_url"`
 	APIKey  string `json:"api_key"`
+	// RawBaseURL 为 true 时不自动补全版本段，按 base_url 原样拼接
+	RawBaseURL bool `json:"raw_base_url"`
+	// ExtraBody 固定附加到请求体的参数，深度合并且客户端传入的值优先
+	ExtraBody json.RawMessage `json:"extra_body"`
+	// QueryParams 附加到请求地址的查询参数（如 api-version、region），地址中已有非空值的参数保持不变
+	QueryParams map[string]string `json:"query_params"`
+	// UserPolicy 请求体 user 字段策略：keep（默认）/ inject / strip
+	UserPolicy string `json:"user_policy"`
+}
+
+// openAIResEndpointSuffixes base_url 中可能误带的接口路径
+var openAIResEndpointSuffixes = []string{"/responses", "/models"}
+
+func (o *OpenAIRes) baseURL() string {
+	return resolveBaseURL(o.BaseURL, "v1", o.RawBaseURL, openAIResEndpointSuffixes...)
 }
 
 func (o *OpenAIRes) BuildReq(ctx context.Context, header http.Header, model string, rawBody []byte) (*http.Request, error) {
@@ -21,7 +36,19 @@ func (o *OpenAIRes) BuildReq(ctx context.Context, header http.Header, model stri
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/responses", o.BaseURL), bytes.NewReader(body))
+	body, err = mergeExtraBody(body, o.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+	body, err = applyUserPolicy(ctx, body, o.UserPolicy)
+	if err != nil {
+		return nil, err
+	}
+	rawURL, err := mergeQueryParams(fmt.Sprintf("%s/responses", o.baseURL()), o.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -35,7 +62,7 @@ func (o *OpenAIRes) BuildReq(ctx context.Context, header http.Header, model stri
 }
 
 func (o *OpenAIRes) Models(ctx context.Context) ([]Model, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", o.BaseURL), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", o.baseURL()), nil)
 	if err != nil {
 		return nil, err
 	}
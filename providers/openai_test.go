@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+)
+
+func TestOpenAIAuthorizationHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"api key", `{"base_url":"http://localhost:11434/v1","api_key":"sk-test"}`, "Bearer sk-test"},
+		{"empty key", `{"base_url":"http://localhost:11434/v1","api_key":""}`, ""},
+		{"blank key", `{"base_url":"http://localhost:11434/v1","api_key":"  "}`, ""},
+		{"missing key", `{"base_url":"http://localhost:11434/v1"}`, ""},
+		{"skip auth", `{"base_url":"http://localhost:11434/v1","api_key":"sk-test","skip_auth":true}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(consts.StyleOpenAI, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 透传的客户端 Authorization 也不能发给无需鉴权的上游
+			header := http.Header{}
+			header.Set("Authorization", "Bearer client-key")
+			req, err := p.BuildReq(context.Background(), header, "llama3", []byte(`{"messages":[]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, present := req.Header["Authorization"]
+			if tt.want == "" {
+				if present {
+					t.Fatalf("Authorization header = %q, want absent", got)
+				}
+				return
+			}
+			if req.Header.Get("Authorization") != tt.want {
+				t.Fatalf("Authorization = %q, want %q", req.Header.Get("Authorization"), tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIEndpointURLs(t *testing.T) {
+	var modelsPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		modelsPath = r.URL.RequestURI()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		baseURL    string
+		wantChat   string
+		wantModels string
+	}{
+		{"prefix with v1", "/proxy/openai/v1", "/proxy/openai/v1/chat/completions", "/proxy/openai/v1/models"},
+		{"prefix with v1 trailing slash", "/proxy/openai/v1/", "/proxy/openai/v1/chat/completions", "/proxy/openai/v1/models"},
+		{"host without v1", "", "/v1/chat/completions", "/v1/models"},
+		{"host trailing slash", "/", "/v1/chat/completions", "/v1/models"},
+		{"v1 only", "/v1", "/v1/chat/completions", "/v1/models"},
+		{"endpoint pasted", "/proxy/openai/v1/chat/completions", "/proxy/openai/v1/chat/completions", "/proxy/openai/v1/models"},
+		{"query kept", "/proxy/openai/v1?key=abc", "/proxy/openai/v1/chat/completions?key=abc", "/proxy/openai/v1/models?key=abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(consts.StyleOpenAI, `{"base_url":"`+srv.URL+tt.baseURL+`","api_key":"sk-test"}`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := p.BuildReq(context.Background(), nil, "gpt-4o", []byte(`{"messages":[]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := req.URL.String(); got != srv.URL+tt.wantChat {
+				t.Fatalf("chat url = %q, want %q", got, srv.URL+tt.wantChat)
+			}
+
+			modelsPath = ""
+			if _, err := p.Models(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if modelsPath != tt.wantModels {
+				t.Fatalf("models path = %q, want %q", modelsPath, tt.wantModels)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package providers
+
+import "net/url"
+
+// RequestBaseURLer 能给出转发请求所用基础地址的提供商
+type RequestBaseURLer interface {
+	RequestBaseURL() string
+}
+
+// Origin 返回提供商请求地址的 scheme://host/，无法确定时返回 false
+func Origin(p Provider) (string, bool) {
+	b, ok := p.(RequestBaseURLer)
+	if !ok {
+		return "", false
+	}
+	u, err := url.Parse(b.RequestBaseURL())
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", false
+	}
+	return u.Scheme + "://" + u.Host + "/", true
+}
+
+func (o *OpenAI) RequestBaseURL() string    { return o.baseURL() }
+func (o *OpenAIRes) RequestBaseURL() string { return o.baseURL() }
+func (a *Anthropic) RequestBaseURL() string { return a.baseURL() }
+func (g *Gemini) RequestBaseURL() string    { return g.baseURL() }
+func (m *Mistral) RequestBaseURL() string   { return m.baseURL() }
+func (a *Azure) RequestBaseURL() string     { return a.endpoint() }
+
+func (b *Bedrock) RequestBaseURL() string {
+	if b.Endpoint == "" && b.Region == "" {
+		return ""
+	}
+	return b.endpoint()
+}
@@ -27,6 +27,10 @@ type Provider interface {
 }
 
 func New(Type, providerConfig string) (Provider, error) {
+	providerConfig, err := ResolveConfig(providerConfig)
+	if err != nil {
+		return nil, err
+	}
 	switch Type {
 	case consts.StyleOpenAI:
 		var openai OpenAI
@@ -54,7 +58,46 @@ func New(Type, providerConfig string) (Provider, error) {
 			return nil, errors.New("invalid gemini config")
 		}
 		return &gemini, nil
+	case consts.StyleAzure:
+		var azure Azure
+		if err := json.Unmarshal([]byte(providerConfig), &azure); err != nil {
+			return nil, errors.New("invalid azure config")
+		}
+		return &azure, nil
+	case consts.StyleBedrock:
+		var bedrock Bedrock
+		if err := json.Unmarshal([]byte(providerConfig), &bedrock); err != nil {
+			return nil, errors.New("invalid bedrock config")
+		}
+		return &bedrock, nil
+	case consts.StyleMistral:
+		var mistral Mistral
+		if err := json.Unmarshal([]byte(providerConfig), &mistral); err != nil {
+			return nil, errors.New("invalid mistral config")
+		}
+		return &mistral, nil
 	default:
 		return nil, errors.New("unknown provider")
 	}
 }
+
+// CompatibleTypes 返回可以承接指定风格请求的提供商类型
+func CompatibleTypes(providerType string) []string {
+	if providerType == consts.StyleOpenAI {
+		return []string{consts.StyleOpenAI, consts.StyleAzure, consts.StyleMistral}
+	}
+	return []string{providerType}
+}
+
+// RoutableTypes 返回可以承接指定请求的提供商类型：在 CompatibleTypes 基础上，
+// Anthropic 请求可转发到 Bedrock 提供商，OpenAI chat/completions 请求可经协议转换转发到 Anthropic/Bedrock 提供商
+func RoutableTypes(providerType string, style string) []string {
+	types := CompatibleTypes(providerType)
+	if providerType == consts.StyleAnthropic {
+		types = append(types, consts.StyleBedrock)
+	}
+	if style == consts.StyleOpenAI {
+		types = append(types, consts.StyleAnthropic, consts.StyleBedrock)
+	}
+	return types
+}
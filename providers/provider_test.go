@@ -0,0 +1,30 @@
+package providers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+)
+
+func TestRoutableTypes(t *testing.T) {
+	openai := RoutableTypes(consts.StyleOpenAI, consts.StyleOpenAI)
+	for _, typ := range []string{consts.StyleAnthropic, consts.StyleBedrock} {
+		if !slices.Contains(openai, typ) {
+			t.Fatalf("openai chat requests should route to %s: %v", typ, openai)
+		}
+	}
+	for _, typ := range CompatibleTypes(consts.StyleOpenAI) {
+		if !slices.Contains(openai, typ) {
+			t.Fatalf("missing compatible type %s: %v", typ, openai)
+		}
+	}
+
+	// 其他风格不做 OpenAI → Anthropic 协议转换
+	if got := RoutableTypes(consts.StyleAnthropic, consts.StyleAnthropic); !slices.Equal(got, []string{consts.StyleAnthropic, consts.StyleBedrock}) {
+		t.Fatalf("anthropic = %v", got)
+	}
+	if got := RoutableTypes(consts.StyleOpenAI, consts.StyleOpenAIRes); slices.Contains(got, consts.StyleAnthropic) {
+		t.Fatalf("responses requests must not route to anthropic: %v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/tidwall/gjson"
+)
+
+// ValidateQueryParams 校验提供商配置中的 query_params，必须为值是字符串的 JSON 对象（未配置时通过）
+func ValidateQueryParams(config string) error {
+	params := gjson.Get(config, "query_params")
+	if !params.Exists() || params.Type == gjson.Null {
+		return nil
+	}
+	if !params.IsObject() {
+		return errors.New("query_params must be a JSON object")
+	}
+	var err error
+	params.ForEach(func(key, value gjson.Result) bool {
+		switch {
+		case key.String() == "":
+			err = errors.New("query_params keys must not be empty")
+		case value.Type != gjson.String:
+			err = fmt.Errorf("query_params.%s must be a string", key.String())
+		}
+		return err == nil
+	})
+	return err
+}
+
+// mergeQueryParams 将 params 合并进 rawURL 的查询参数，地址中已有非空值的参数保持不变
+func mergeQueryParams(rawURL string, params map[string]string) (string, error) {
+	if len(params) == 0 {
+		return rawURL, nil
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	for key, value := range params {
+		if query.Get(key) != "" {
+			continue
+		}
+		query.Set(key, value)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
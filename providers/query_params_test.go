@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+)
+
+func TestValidateQueryParams(t *testing.T) {
+	tests := []struct {
+		config  string
+		wantErr bool
+	}{
+		{`{"api_key":"k"}`, false},
+		{`{"query_params":null}`, false},
+		{`{"query_params":{"api-version":"2024-10-21","region":"eu"}}`, false},
+		{`{"query_params":"api-version=1"}`, true},
+		{`{"query_params":["a"]}`, true},
+		{`{"query_params":{"alt":1}}`, true},
+		{`{"query_params":{"":"x"}}`, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateQueryParams(tt.config); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateQueryParams(%s) = %v, wantErr %v", tt.config, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMergeQueryParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		rawURL string
+		params map[string]string
+		want   string
+	}{
+		{"no params", "https://gw.example.com/v1/chat/completions?key=abc", nil, "https://gw.example.com/v1/chat/completions?key=abc"},
+		{"added", "https://gw.example.com/v1/chat/completions", map[string]string{"region": "eu"}, "https://gw.example.com/v1/chat/completions?region=eu"},
+		// 地址中已有非空值的参数保持不变
+		{"existing wins", "https://gw.example.com/v1?api-version=2024-10-21", map[string]string{"api-version": "2025-01-01", "region": "eu"}, "https://gw.example.com/v1?api-version=2024-10-21&region=eu"},
+		{"empty existing replaced", "https://gw.example.com/v1?api-version=", map[string]string{"api-version": "2025-01-01"}, "https://gw.example.com/v1?api-version=2025-01-01"},
+		{"escaped", "https://gw.example.com/v1", map[string]string{"tag": "a b&c"}, "https://gw.example.com/v1?tag=a+b%26c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeQueryParams(tt.rawURL, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("mergeQueryParams = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildReqAppliesQueryParams(t *testing.T) {
+	params := `"query_params":{"region":"eu","api-version":"2025-01-01"}`
+	tests := []struct {
+		style  string
+		config string
+		body   string
+		want   url.Values
+	}{
+		{consts.StyleOpenAI, `{"base_url":"https://api.example.com/v1","api_key":"k",` + params + `}`, `{"messages":[]}`,
+			url.Values{"region": {"eu"}, "api-version": {"2025-01-01"}}},
+		{consts.StyleOpenAIRes, `{"base_url":"https://api.example.com/v1","api_key":"k",` + params + `}`, `{"input":"hi"}`,
+			url.Values{"region": {"eu"}, "api-version": {"2025-01-01"}}},
+		{consts.StyleAnthropic, `{"base_url":"https://api.example.com/v1","api_key":"k",` + params + `}`, `{"messages":[]}`,
+			url.Values{"region": {"eu"}, "api-version": {"2025-01-01"}}},
+		{consts.StyleMistral, `{"base_url":"https://api.example.com/v1","api_key":"k",` + params + `}`, `{"messages":[]}`,
+			url.Values{"region": {"eu"}, "api-version": {"2025-01-01"}}},
+		{consts.StyleGemini, `{"base_url":"https://api.example.com/v1beta","api_key":"k",` + params + `}`, `{"contents":[]}`,
+			url.Values{"region": {"eu"}, "api-version": {"2025-01-01"}}},
+		// Azure 的 api_version 已写入地址，query_params 中的同名参数不覆盖
+		{consts.StyleAzure, `{"endpoint":"https://res.openai.azure.com","api_key":"k","api_version":"2024-10-21",` + params + `}`, `{"messages":[]}`,
+			url.Values{"region": {"eu"}, "api-version": {"2024-10-21"}}},
+		{consts.StyleBedrock, `{"region":"us-east-1","access_key":"ak","secret_key":"sk",` + params + `}`, `{"messages":[],"max_tokens":16}`,
+			url.Values{"region": {"eu"}, "api-version": {"2025-01-01"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			p, err := New(tt.style, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := p.BuildReq(context.Background(), http.Header{}, "upstream-model", []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := req.URL.Query()
+			for key, want := range tt.want {
+				if got := query[key]; len(got) != 1 || got[0] != want[0] {
+					t.Fatalf("%s = %v, want %v; url %s", key, got, want, req.URL)
+				}
+			}
+		})
+	}
+}
+
+func TestGeminiStreamKeepsSSEAlt(t *testing.T) {
+	p, err := New(consts.StyleGemini, `{"base_url":"https://api.example.com/v1beta","api_key":"k","query_params":{"alt":"json","region":"eu"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 流式响应固定使用 SSE，覆盖配置中的 alt
+	ctx := context.WithValue(context.Background(), consts.ContextKeyGeminiStream, true)
+	req, err := p.BuildReq(ctx, http.Header{}, "gemini-2.5-pro", []byte(`{"contents":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := req.URL.Query()
+	if query.Get("alt") != "sse" || query.Get("region") != "eu" {
+		t.Fatalf("stream url = %s", req.URL)
+	}
+
+	req, err = p.BuildReq(context.Background(), http.Header{}, "gemini-2.5-pro", []byte(`{"contents":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.URL.Query().Get("alt"); got != "json" {
+		t.Fatalf("non-stream alt = %q, want json", got)
+	}
+}
@@ -0,0 +1,25 @@
+package providers
+
+import (
+	"github.com/tidwall/sjson"
+)
+
+// StreamOptionsStripper 转发前会删除 stream_options 的提供商（上游不识别该字段时报错），
+// 此类上游的流式响应不含 usage，用量需由网关估算
+type StreamOptionsStripper interface {
+	StripsStreamOptions() bool
+}
+
+// StripsStreamOptions 判断提供商是否在转发前删除 stream_options
+func StripsStreamOptions(p Provider) bool {
+	s, ok := p.(StreamOptionsStripper)
+	return ok && s.StripsStreamOptions()
+}
+
+// stripStreamOptions 删除请求体中的 stream_options（含网关为记录用量注入的 include_usage）
+func stripStreamOptions(body []byte, strip bool) ([]byte, error) {
+	if !strip {
+		return body, nil
+	}
+	return sjson.DeleteBytes(body, "stream_options")
+}
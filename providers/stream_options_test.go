@@ -0,0 +1,61 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+	"github.com/tidwall/gjson"
+)
+
+func TestBuildReqStripStreamOptions(t *testing.T) {
+	raw := []byte(`{"model":"m","stream":true,"stream_options":{"include_usage":true},"messages":[]}`)
+	tests := []struct {
+		name      string
+		typ       string
+		config    string
+		wantStrip bool
+	}{
+		{"openai keep", consts.StyleOpenAI, `{"base_url":"http://upstream/v1","api_key":"k"}`, false},
+		{"openai strip", consts.StyleOpenAI, `{"base_url":"http://upstream/v1","api_key":"k","strip_stream_options":true}`, true},
+		{"azure keep", consts.StyleAzure, `{"endpoint":"http://upstream","api_key":"k","api_version":"2024-10-21"}`, false},
+		{"azure strip", consts.StyleAzure, `{"endpoint":"http://upstream","api_key":"k","api_version":"2024-10-21","strip_stream_options":true}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.typ, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := StripsStreamOptions(p); got != tt.wantStrip {
+				t.Fatalf("StripsStreamOptions = %v, want %v", got, tt.wantStrip)
+			}
+			req, err := p.BuildReq(context.Background(), nil, "m", raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := gjson.GetBytes(body, "stream_options").Exists(); got == tt.wantStrip {
+				t.Fatalf("stream_options present = %v, body %s", got, body)
+			}
+			// 其余字段保持不变
+			if !gjson.GetBytes(body, "stream").Bool() || !gjson.GetBytes(body, "messages").IsArray() {
+				t.Fatalf("body = %s", body)
+			}
+		})
+	}
+}
+
+func TestStripsStreamOptionsUnsupported(t *testing.T) {
+	p, err := New(consts.StyleAnthropic, `{"base_url":"http://upstream/v1","api_key":"k","strip_stream_options":true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if StripsStreamOptions(p) {
+		t.Fatal("anthropic providers do not support strip_stream_options")
+	}
+}
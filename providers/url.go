@@ -0,0 +1,65 @@
+package providers
+
+import (
+	"net/url"
+	"strings"
+)
+
+// resolveBaseURL 规范化 base_url，避免出现 /v1/v1 或缺少 /v1 导致的 404：
+// - 去掉首尾空白与末尾斜杠
+// - 去掉误填的接口路径（endpointSuffixes，如 .../v1/chat/completions 只保留 .../v1）
+// - 若仅填写了主机（没有任何路径），补上默认版本段（如 /v1、/v1beta）
+// - 已包含路径（如 /v1、/proxy/openai/v1、/api）时保持原样
+// - 查询参数（如网关要求的 ?key=）保留在末尾，不影响路径判断
+// raw 为 true 时不做任何修正，完全按用户填写的地址拼接
+func resolveBaseURL(baseURL string, defaultVersion string, raw bool, endpointSuffixes ...string) string {
+	if !raw {
+		baseURL = trimEndpointSuffix(baseURL, endpointSuffixes...)
+	}
+	path, query, hasQuery := strings.Cut(strings.TrimSpace(baseURL), "?")
+	base := strings.TrimRight(path, "/")
+	if !raw && defaultVersion != "" {
+		if parsed, err := url.Parse(base); err == nil && parsed.Host != "" && parsed.Path == "" {
+			base += "/" + defaultVersion
+		}
+	}
+	if hasQuery {
+		base += "?" + query
+	}
+	return base
+}
+
+// trimEndpointSuffix 去掉 base_url 末尾误填的接口路径（如 .../v1/chat/completions），查询参数保持不变
+// suffixes 按顺序匹配，较长的路径应排在前面（如 /messages/count_tokens 在 /messages 之前）
+// 以 "*" 结尾的后缀匹配该前缀之后的任意路径（如 /models/* 匹配 /models/gemini-pro:generateContent）
+func trimEndpointSuffix(baseURL string, suffixes ...string) string {
+	path, query, hasQuery := strings.Cut(strings.TrimSpace(baseURL), "?")
+	path = strings.TrimRight(path, "/")
+	for _, suffix := range suffixes {
+		if prefix, ok := strings.CutSuffix(suffix, "*"); ok {
+			if i := strings.LastIndex(path, prefix); i >= 0 && !strings.Contains(path[i+len(prefix):], "/") {
+				path = path[:i]
+				break
+			}
+			continue
+		}
+		if trimmed, ok := strings.CutSuffix(path, suffix); ok {
+			path = trimmed
+			break
+		}
+	}
+	if hasQuery {
+		path += "?" + query
+	}
+	return path
+}
+
+// joinURL 在 base 的路径末尾追加 elem，base 中的查询参数保留在拼接后的地址末尾
+func joinURL(base string, elem string) string {
+	path, query, hasQuery := strings.Cut(base, "?")
+	joined := strings.TrimRight(path, "/") + "/" + strings.TrimLeft(elem, "/")
+	if hasQuery {
+		joined += "?" + query
+	}
+	return joined
+}
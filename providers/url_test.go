@@ -0,0 +1,58 @@
+package providers
+
+import "testing"
+
+func TestResolveBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		version  string
+		raw      bool
+		suffixes []string
+		want     string
+	}{
+		{"host only", "https://api.openai.com", "v1", false, openAIEndpointSuffixes, "https://api.openai.com/v1"},
+		{"trailing slash", "https://api.openai.com/v1/", "v1", false, openAIEndpointSuffixes, "https://api.openai.com/v1"},
+		{"whitespace", "  https://api.openai.com/v1  ", "v1", false, openAIEndpointSuffixes, "https://api.openai.com/v1"},
+		{"custom path kept", "https://gw.example.com/proxy/openai/v1", "v1", false, openAIEndpointSuffixes, "https://gw.example.com/proxy/openai/v1"},
+		{"chat completions suffix", "https://api.openai.com/v1/chat/completions", "v1", false, openAIEndpointSuffixes, "https://api.openai.com/v1"},
+		{"chat completions suffix with slash", "https://api.openai.com/v1/chat/completions/", "v1", false, openAIEndpointSuffixes, "https://api.openai.com/v1"},
+		{"embeddings suffix", "https://gw.example.com/openai/v1/embeddings", "v1", false, openAIEndpointSuffixes, "https://gw.example.com/openai/v1"},
+		// 只有接口路径时去掉后补全版本段
+		{"suffix without version", "https://api.openai.com/chat/completions", "v1", false, openAIEndpointSuffixes, "https://api.openai.com/v1"},
+		{"suffix with query", "https://gw.example.com/v1/chat/completions?key=abc", "v1", false, openAIEndpointSuffixes, "https://gw.example.com/v1?key=abc"},
+		{"host with query", "https://gw.example.com?key=abc", "v1", false, openAIEndpointSuffixes, "https://gw.example.com/v1?key=abc"},
+		{"raw keeps suffix", "https://api.openai.com/v1/chat/completions", "v1", true, openAIEndpointSuffixes, "https://api.openai.com/v1/chat/completions"},
+		{"raw host only", "https://api.openai.com", "v1", true, openAIEndpointSuffixes, "https://api.openai.com"},
+		{"anthropic messages", "https://api.anthropic.com/v1/messages", "v1", false, anthropicEndpointSuffixes, "https://api.anthropic.com/v1"},
+		{"anthropic count tokens", "https://api.anthropic.com/v1/messages/count_tokens", "v1", false, anthropicEndpointSuffixes, "https://api.anthropic.com/v1"},
+		{"responses", "https://api.openai.com/v1/responses", "v1", false, openAIResEndpointSuffixes, "https://api.openai.com/v1"},
+		{"gemini host only", "https://generativelanguage.googleapis.com", "v1beta", false, geminiEndpointSuffixes, "https://generativelanguage.googleapis.com/v1beta"},
+		{"gemini model action", "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-pro:generateContent", "v1beta", false, geminiEndpointSuffixes, "https://generativelanguage.googleapis.com/v1beta"},
+		{"gemini models list", "https://generativelanguage.googleapis.com/v1beta/models", "v1beta", false, geminiEndpointSuffixes, "https://generativelanguage.googleapis.com/v1beta"},
+		{"gemini nested path kept", "https://gw.example.com/models/team/v1beta", "v1beta", false, geminiEndpointSuffixes, "https://gw.example.com/models/team/v1beta"},
+		{"no suffixes", "https://api.openai.com/v1/chat/completions", "v1", false, nil, "https://api.openai.com/v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveBaseURL(tt.baseURL, tt.version, tt.raw, tt.suffixes...); got != tt.want {
+				t.Fatalf("resolveBaseURL(%q) = %q, want %q", tt.baseURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinURL(t *testing.T) {
+	tests := []struct {
+		base, elem, want string
+	}{
+		{"https://api.openai.com/v1", "chat/completions", "https://api.openai.com/v1/chat/completions"},
+		{"https://api.openai.com/v1/", "/chat/completions", "https://api.openai.com/v1/chat/completions"},
+		{"https://gw.example.com/v1beta?key=abc", "models/gemini:generateContent", "https://gw.example.com/v1beta/models/gemini:generateContent?key=abc"},
+	}
+	for _, tt := range tests {
+		if got := joinURL(tt.base, tt.elem); got != tt.want {
+			t.Errorf("joinURL(%q, %q) = %q, want %q", tt.base, tt.elem, got, tt.want)
+		}
+	}
+}
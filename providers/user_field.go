@@ -0,0 +1,45 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/racio/llmio/consts"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// 请求体 user 字段的处理策略（OpenAI 协议用于滥用监控，部分兼容服务收到该字段会返回 400）
+const (
+	UserPolicyKeep   = "keep"   // 保持客户端原样（默认）
+	UserPolicyInject = "inject" // 使用当前 AuthKey ID 覆盖，便于上游按 Key 追踪
+	UserPolicyStrip  = "strip"  // 删除该字段
+)
+
+// ValidateUserPolicy 校验提供商配置中的 user_policy（未配置时通过）
+func ValidateUserPolicy(config string) error {
+	switch gjson.Get(config, "user_policy").String() {
+	case "", UserPolicyKeep, UserPolicyInject, UserPolicyStrip:
+		return nil
+	default:
+		return errors.New("user_policy must be one of keep, inject, strip")
+	}
+}
+
+// applyUserPolicy 按策略注入/保留/删除请求体中的 user 字段
+// inject 时若请求未绑定 AuthKey（如管理员 Token）则保持原样
+func applyUserPolicy(ctx context.Context, body []byte, policy string) ([]byte, error) {
+	switch policy {
+	case UserPolicyStrip:
+		return sjson.DeleteBytes(body, "user")
+	case UserPolicyInject:
+		authKeyID, _ := ctx.Value(consts.ContextKeyAuthKeyID).(uint)
+		if authKeyID == 0 {
+			return body, nil
+		}
+		return sjson.SetBytes(body, "user", fmt.Sprintf("llmio-key-%d", authKeyID))
+	default:
+		return body, nil
+	}
+}
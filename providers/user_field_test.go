@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+)
+
+func TestApplyUserPolicy(t *testing.T) {
+	withKey := context.WithValue(context.Background(), consts.ContextKeyAuthKeyID, uint(12))
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		policy string
+		body   string
+		want   string
+	}{
+		{"default keeps", withKey, "", `{"user":"alice"}`, `{"user":"alice"}`},
+		{"keep", withKey, UserPolicyKeep, `{"user":"alice"}`, `{"user":"alice"}`},
+		{"keep without user", withKey, UserPolicyKeep, `{"model":"m"}`, `{"model":"m"}`},
+		{"inject overrides", withKey, UserPolicyInject, `{"user":"alice"}`, `{"user":"llmio-key-12"}`},
+		{"inject adds", withKey, UserPolicyInject, `{"model":"m"}`, `{"model":"m","user":"llmio-key-12"}`},
+		{"inject without key", context.Background(), UserPolicyInject, `{"user":"alice"}`, `{"user":"alice"}`},
+		{"strip", withKey, UserPolicyStrip, `{"model":"m","user":"alice"}`, `{"model":"m"}`},
+		{"strip without user", withKey, UserPolicyStrip, `{"model":"m"}`, `{"model":"m"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyUserPolicy(tt.ctx, []byte(tt.body), tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestValidateUserPolicy(t *testing.T) {
+	for _, config := range []string{`{}`, `{"user_policy":"keep"}`, `{"user_policy":"inject"}`, `{"user_policy":"strip"}`} {
+		if err := ValidateUserPolicy(config); err != nil {
+			t.Errorf("ValidateUserPolicy(%s) = %v", config, err)
+		}
+	}
+	if err := ValidateUserPolicy(`{"user_policy":"drop"}`); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestBuildReqAppliesUserPolicy(t *testing.T) {
+	ctx := context.WithValue(context.Background(), consts.ContextKeyAuthKeyID, uint(12))
+	p, err := New(consts.StyleOpenAI, `{"base_url":"https://api.example.com/v1","api_key":"k","user_policy":"strip"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := p.BuildReq(ctx, http.Header{}, "m", []byte(`{"user":"alice","messages":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameJSON(t, body, `{"model":"m","messages":[]}`)
+}
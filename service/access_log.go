@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+// accessLogger 结构化访问日志输出到 stdout，每个代理请求一行 JSON
+var accessLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// AccessLogEnabled 是否开启结构化访问日志（LLMIO_ACCESS_LOG=json，默认 off）
+func AccessLogEnabled() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("LLMIO_ACCESS_LOG")), "json")
+}
+
+// AccessLog 单个代理请求的访问日志：响应写完且请求日志（含首字/生成耗时）计算完成后输出一行，
+// 二者先完成的一方只记录状态，由后完成的一方输出，不阻塞响应
+type AccessLog struct {
+	mu         sync.Mutex
+	start      time.Time
+	method     string
+	path       string
+	remoteIP   string
+	httpStatus int
+	latency    time.Duration
+	responded  bool
+	pending    bool // 请求日志仍在异步处理中
+	logId      uint
+	chatLog    *models.ChatLog
+	emitted    bool
+}
+
+// StartAccessLog 开启访问日志时为请求创建记录并挂到返回的 context 上
+func StartAccessLog(ctx context.Context, method string, path string, remoteIP string) (context.Context, *AccessLog) {
+	entry := &AccessLog{start: time.Now(), method: method, path: path, remoteIP: remoteIP}
+	return context.WithValue(ctx, consts.ContextKeyAccessLog, entry), entry
+}
+
+// AccessLogFromContext 取出请求的访问日志记录，未开启时返回 nil
+func AccessLogFromContext(ctx context.Context) *AccessLog {
+	entry, _ := ctx.Value(consts.ContextKeyAccessLog).(*AccessLog)
+	return entry
+}
+
+// WithAccessLog 将访问日志记录挂到另一个 context 上（用于脱离请求生命周期的异步处理）
+func WithAccessLog(ctx context.Context, entry *AccessLog) context.Context {
+	if entry == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, consts.ContextKeyAccessLog, entry)
+}
+
+// Track 请求日志已落库、用量与耗时将由 RecordLog 异步计算，访问日志等待其完成后输出
+func (a *AccessLog) Track(logId uint) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.logId = logId
+	a.pending = true
+}
+
+// Responded 响应写完时调用；请求日志已计算完成或本次请求未产生请求日志时直接输出
+func (a *AccessLog) Responded(httpStatus int) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.httpStatus = httpStatus
+	a.latency = time.Since(a.start)
+	a.responded = true
+	if !a.pending {
+		a.emit()
+	}
+}
+
+// recorded RecordLog 处理完成后调用，响应已写完时输出
+func (a *AccessLog) recorded(chatLog *models.ChatLog) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.chatLog = chatLog
+	a.pending = false
+	if a.responded {
+		a.emit()
+	}
+}
+
+// emit 输出访问日志，调用方需持有锁
+func (a *AccessLog) emit() {
+	if a.emitted {
+		return
+	}
+	a.emitted = true
+	attrs := []any{
+		"method", a.method,
+		"path", a.path,
+		"remote_ip", a.remoteIP,
+		"http_status", a.httpStatus,
+		"latency_ms", a.latency.Milliseconds(),
+	}
+	if log := a.chatLog; log != nil {
+		attrs = append(attrs,
+			"log_id", log.ID,
+			"uuid", log.UUID,
+			"request_id", log.RequestID,
+			"model", log.Name,
+			"provider", log.ProviderName,
+			"provider_model", log.ProviderModel,
+			"status", log.Status,
+			"auth_key_id", log.AuthKeyID,
+			"retry", log.Retry,
+			"proxy_time_ms", log.ProxyTimeMs,
+			"first_chunk_time_ms", log.FirstChunkTimeMs,
+			"chunk_time_ms", log.ChunkTimeMs,
+			"tps", log.Tps,
+			"prompt_tokens", log.PromptTokens,
+			"completion_tokens", log.CompletionTokens,
+			"total_cost", log.TotalCost,
+		)
+	}
+	accessLogger.Info("access", attrs...)
+}
+
+// finishAccessLog 请求日志处理完成后读取落库结果，交给访问日志输出
+func finishAccessLog(ctx context.Context, logId uint) {
+	entry := AccessLogFromContext(ctx)
+	if entry == nil {
+		return
+	}
+	log, err := gorm.G[models.ChatLog](models.DB).Where("id = ?", logId).First(ctx)
+	if err != nil {
+		slog.Error("load chat log for access log error", "error", err)
+		entry.recorded(nil)
+		return
+	}
+	entry.recorded(&log)
+}
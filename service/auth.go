@@ -16,7 +16,9 @@ var singleFlightGroup singleflight.Group
 func GetAuthKey(ctx context.Context, key string) (*models.AuthKey, error) {
 	ch := singleFlightGroup.DoChan(key, func() (any, error) {
 		// auth_keys.status 在数据库中是 0/1（int），不能用 bool 参数查询
-		authKey, err := gorm.G[models.AuthKey](models.DB).Where("key = ?", key).Where("status = ?", 1).First(ctx)
+		authKey, err := models.RetryRead(ctx, func() (models.AuthKey, error) {
+			return gorm.G[models.AuthKey](models.DB).Where("key = ?", key).Where("status = ?", 1).First(ctx)
+		})
 		return &authKey, err
 	})
 
@@ -77,3 +79,40 @@ func backgroundFlush() {
 		}
 	}
 }
+
+const authKeyExpireInterval = time.Minute
+
+// StartAuthKeyExpiry 后台定期将已过期的 AuthKey 标记为禁用，使列表状态与实际可用性一致
+func StartAuthKeyExpiry(ctx context.Context) {
+	go authKeyExpiryLoop(ctx)
+}
+
+func authKeyExpiryLoop(ctx context.Context) {
+	ticker := time.NewTicker(authKeyExpireInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := ExpireAuthKeys(ctx, time.Now()); err != nil {
+			slog.Error("Failed to expire auth keys", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireAuthKeys 将 expires_at 早于 now 且仍启用的 AuthKey 置为禁用，返回受影响数量
+func ExpireAuthKeys(ctx context.Context, now time.Time) (int, error) {
+	rows, err := gorm.G[models.AuthKey](models.DB).
+		Where("status = ?", 1).
+		Where("expires_at IS NOT NULL AND expires_at < ?", now).
+		Update(ctx, "status", 0)
+	if err != nil {
+		return 0, err
+	}
+	if rows > 0 {
+		slog.Info("Auth keys expired", "count", rows)
+	}
+	return rows, nil
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpireAuthKeysOnlyDisablesActiveExpiredKeys(t *testing.T) {
+	statements := captureSQL(t)
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+
+	if _, err := ExpireAuthKeys(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	got := statements()
+	if len(got) != 1 {
+		t.Fatalf("statements = %v, want 1", got)
+	}
+	sql := got[0]
+	for _, want := range []string{
+		`UPDATE "auth_keys" SET "status"=0`,
+		"status = 1",
+		"expires_at IS NOT NULL AND expires_at < '2026-10-17 08:00:00'",
+		`"auth_keys"."deleted_at" IS NULL`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("sql %q missing %q", sql, want)
+		}
+	}
+}
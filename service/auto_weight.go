@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAutoWeightIntervalMinutes = 10
+	defaultAutoWeightWindowMinutes   = 30
+	defaultAutoWeightMinRequests     = 20
+	defaultAutoWeightMinWeight       = 1
+	defaultAutoWeightLatencyTargetMs = 10000
+)
+
+// autoWeightStat 窗口内单个模型-提供商关联的请求统计
+type autoWeightStat struct {
+	Total        int64
+	Success      int64
+	AvgLatencyMs float64
+}
+
+// StartAutoWeight 启动按健康状况自动调整关联权重的后台任务，仅对开启 AutoWeight 的模型生效
+// 关联的 BaseWeight 为人工配置的权重，任务只在 [MinWeight, BaseWeight] 区间内调整 Weight
+func StartAutoWeight(ctx context.Context) {
+	go func() {
+		interval := time.Duration(defaultAutoWeightIntervalMinutes) * time.Minute
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+
+			cfg := loadAutoWeightConfig(ctx)
+			interval = time.Duration(cfg.IntervalMinutes) * time.Minute
+			if err := adjustWeights(ctx, cfg); err != nil {
+				slog.Error("auto weight adjust error", "error", err)
+			}
+		}
+	}()
+}
+
+func loadAutoWeightConfig(ctx context.Context) models.AutoWeightConfig {
+	var cfg models.AutoWeightConfig
+	if _, err := loadJSONConfig(ctx, models.KeyAutoWeight, &cfg); err != nil {
+		slog.Warn("load auto weight config error", "error", err)
+	}
+	if cfg.IntervalMinutes <= 0 {
+		cfg.IntervalMinutes = defaultAutoWeightIntervalMinutes
+	}
+	if cfg.WindowMinutes <= 0 {
+		cfg.WindowMinutes = defaultAutoWeightWindowMinutes
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultAutoWeightMinRequests
+	}
+	if cfg.MinWeight <= 0 {
+		cfg.MinWeight = defaultAutoWeightMinWeight
+	}
+	if cfg.LatencyTargetMs <= 0 {
+		cfg.LatencyTargetMs = defaultAutoWeightLatencyTargetMs
+	}
+	return cfg
+}
+
+func adjustWeights(ctx context.Context, cfg models.AutoWeightConfig) error {
+	modelList, err := gorm.G[models.Model](models.DB).Where("auto_weight = ?", 1).Where("status = ?", 1).Find(ctx)
+	if err != nil {
+		return err
+	}
+	for _, model := range modelList {
+		if err := adjustModelWeights(ctx, model, cfg); err != nil {
+			slog.Error("auto weight adjust model error", "model", model.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+func adjustModelWeights(ctx context.Context, model models.Model, cfg models.AutoWeightConfig) error {
+	modelWithProviders, err := gorm.G[models.ModelWithProvider](models.DB).
+		Where("model_id = ?", model.ID).
+		Where("status = ?", 1).
+		Where("shadow = ?", 0).
+		Find(ctx)
+	if err != nil || len(modelWithProviders) == 0 {
+		return err
+	}
+	providerList, err := gorm.G[models.Provider](models.DB).Find(ctx)
+	if err != nil {
+		return err
+	}
+	providerNames := make(map[uint]string, len(providerList))
+	for _, p := range providerList {
+		providerNames[p.ID] = p.Name
+	}
+
+	type row struct {
+		ProviderName  string
+		ProviderModel string
+		Total         int64
+		Success       int64
+		AvgLatencyMs  float64
+	}
+	var rows []row
+	if err := models.Reader().WithContext(ctx).
+		Model(&models.ChatLog{}).
+		Select("provider_name, provider_model, COUNT(*) AS total, SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END) AS success, AVG(proxy_time_ms) AS avg_latency_ms").
+		Where("name = ?", model.Name).
+		Where("created_at >= ?", time.Now().Add(-time.Duration(cfg.WindowMinutes)*time.Minute)).
+		Group("provider_name, provider_model").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	type statKey struct{ provider, model string }
+	stats := make(map[statKey]autoWeightStat, len(rows))
+	for _, r := range rows {
+		stats[statKey{r.ProviderName, r.ProviderModel}] = autoWeightStat{Total: r.Total, Success: r.Success, AvgLatencyMs: r.AvgLatencyMs}
+	}
+
+	for _, mp := range modelWithProviders {
+		base := mp.BaseWeight
+		if base <= 0 {
+			// 旧数据没有 BaseWeight：以当前权重作为人工配置的基准
+			base = mp.Weight
+		}
+		var stat *autoWeightStat
+		if s, ok := stats[statKey{providerNames[mp.ProviderID], mp.ProviderModel}]; ok {
+			stat = &s
+		}
+		weight := computeAutoWeight(mp.Weight, base, stat, cfg)
+		if weight == mp.Weight && base == mp.BaseWeight {
+			continue
+		}
+		if _, err := gorm.G[models.ModelWithProvider](models.DB).Where("id = ?", mp.ID).Update(ctx, "base_weight", base); err != nil {
+			return err
+		}
+		if _, err := gorm.G[models.ModelWithProvider](models.DB).Where("id = ?", mp.ID).Update(ctx, "weight", weight); err != nil {
+			return err
+		}
+		if weight != mp.Weight {
+			attrs := []any{"model", model.Name, "provider", providerNames[mp.ProviderID], "provider_model", mp.ProviderModel, "from", mp.Weight, "to", weight, "base", base}
+			if stat != nil {
+				attrs = append(attrs, "requests", stat.Total, "success_rate", float64(stat.Success)/float64(stat.Total), "avg_latency_ms", int(stat.AvgLatencyMs))
+			}
+			slog.Info("auto weight adjusted", attrs...)
+		}
+	}
+	return nil
+}
+
+// computeAutoWeight 计算关联的新权重
+// 目标权重 = 基准权重 × 成功率（平均延迟超出目标时按比例再打折），且不低于 MinWeight；
+// 样本不足时目标为基准权重（逐步恢复），每轮只向目标移动一半，避免一次抖动把权重压到底
+func computeAutoWeight(current, base int, stat *autoWeightStat, cfg models.AutoWeightConfig) int {
+	if base <= 0 {
+		return current
+	}
+	minWeight := min(cfg.MinWeight, base)
+	target := base
+	if stat != nil && stat.Total >= int64(cfg.MinRequests) {
+		factor := float64(stat.Success) / float64(stat.Total)
+		if stat.AvgLatencyMs > float64(cfg.LatencyTargetMs) {
+			factor *= float64(cfg.LatencyTargetMs) / stat.AvgLatencyMs
+		}
+		target = max(minWeight, int(math.Round(float64(base)*factor)))
+	}
+	current = min(max(current, minWeight), base)
+	if current == target {
+		return current
+	}
+	step := (target - current) / 2
+	if step == 0 {
+		step = target - current
+	}
+	return current + step
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+
+	"github.com/racio/llmio/consts"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// bedrockMaxFrameSize 单个 event-stream 帧的上限，防止异常长度字段导致大块内存分配
+const bedrockMaxFrameSize = 16 * 1024 * 1024
+
+// translateBedrockResponse Bedrock 流式响应为 AWS event-stream 二进制帧，转换为 Anthropic SSE，
+// 客户端与用量统计（ProcesserAnthropic）按 Anthropic 协议处理；非流式响应本身即 Anthropic 格式
+func translateBedrockResponse(res *http.Response, providerType string, stream bool) {
+	if providerType != consts.StyleBedrock || !stream {
+		return
+	}
+	res.Header.Del("Content-Length")
+	res.Header.Set("Content-Type", "text/event-stream")
+	res.Body = newBedrockEventStream(res.Body)
+	res.ContentLength = -1
+}
+
+// bedrockEventStream 逐帧解码 event-stream，每个 chunk 事件输出一条 Anthropic SSE 事件
+type bedrockEventStream struct {
+	src    io.ReadCloser
+	reader *bufio.Reader
+	buf    bytes.Buffer
+	done   bool
+	// startUsage message_start 中的输入用量，补到 message_delta 的 usage 中
+	startUsage gjson.Result
+}
+
+func newBedrockEventStream(src io.ReadCloser) *bedrockEventStream {
+	return &bedrockEventStream{src: src, reader: bufio.NewReader(src)}
+}
+
+func (s *bedrockEventStream) Read(p []byte) (int, error) {
+	for s.buf.Len() == 0 && !s.done {
+		headers, payload, err := readBedrockFrame(s.reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return 0, err
+			}
+			s.done = true
+			break
+		}
+		s.convert(headers, payload)
+	}
+	if s.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return s.buf.Read(p)
+}
+
+func (s *bedrockEventStream) Close() error {
+	return s.src.Close()
+}
+
+// convert chunk 事件的 payload 为 {"bytes": "<base64 的 Anthropic 事件>"}；异常帧转换为 Anthropic error 事件
+func (s *bedrockEventStream) convert(headers map[string]string, payload []byte) {
+	switch headers[":message-type"] {
+	case "event":
+		if headers[":event-type"] != "chunk" {
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(gjson.GetBytes(payload, "bytes").String())
+		if err != nil || !gjson.ValidBytes(data) {
+			return
+		}
+		data = s.fillUsage(data)
+		fmt.Fprintf(&s.buf, "event: %s\ndata: %s\n\n", gjson.GetBytes(data, "type").String(), data)
+	case "exception", "error":
+		errType := headers[":exception-type"]
+		if errType == "" {
+			errType = headers[":error-code"]
+		}
+		message := gjson.GetBytes(payload, "message").String()
+		if message == "" {
+			message = headers[":error-message"]
+		}
+		data, _ := json.Marshal(map[string]any{
+			"type":  "error",
+			"error": map[string]string{"type": errType, "message": message},
+		})
+		fmt.Fprintf(&s.buf, "event: error\ndata: %s\n\n", data)
+	}
+}
+
+// fillUsage Bedrock 的 message_delta 只返回 output_tokens，输入用量仅出现在 message_start 中；
+// 将其补到 message_delta，与 Anthropic 官方流式响应一致，便于按 message_delta 统计用量
+func (s *bedrockEventStream) fillUsage(data []byte) []byte {
+	switch gjson.GetBytes(data, "type").String() {
+	case "message_start":
+		s.startUsage = gjson.GetBytes(data, "message.usage")
+	case "message_delta":
+		for _, key := range []string{"input_tokens", "cache_creation_input_tokens", "cache_read_input_tokens"} {
+			value := s.startUsage.Get(key)
+			if !value.Exists() || gjson.GetBytes(data, "usage."+key).Exists() {
+				continue
+			}
+			if filled, err := sjson.SetBytes(data, "usage."+key, value.Int()); err == nil {
+				data = filled
+			}
+		}
+	}
+	return data
+}
+
+// readBedrockFrame 读取一个 event-stream 帧：
+// 总长度(4) | 头部长度(4) | 前导 CRC(4) | 头部 | 负载 | 帧 CRC(4)，整数均为大端序
+func readBedrockFrame(r io.Reader) (map[string]string, []byte, error) {
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, errors.New("bedrock event stream: truncated frame")
+		}
+		return nil, nil, err
+	}
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, nil, errors.New("bedrock event stream: prelude crc mismatch")
+	}
+	if totalLen < 16 || totalLen > bedrockMaxFrameSize || headersLen > totalLen-16 {
+		return nil, nil, fmt.Errorf("bedrock event stream: invalid frame length %d", totalLen)
+	}
+
+	frame := make([]byte, totalLen)
+	copy(frame, prelude)
+	if _, err := io.ReadFull(r, frame[12:]); err != nil {
+		return nil, nil, errors.New("bedrock event stream: truncated frame")
+	}
+	if crc32.ChecksumIEEE(frame[:totalLen-4]) != binary.BigEndian.Uint32(frame[totalLen-4:]) {
+		return nil, nil, errors.New("bedrock event stream: message crc mismatch")
+	}
+
+	headers, err := parseBedrockHeaders(frame[12 : 12+headersLen])
+	if err != nil {
+		return nil, nil, err
+	}
+	return headers, frame[12+headersLen : totalLen-4], nil
+}
+
+// parseBedrockHeaders 解析帧头部，仅保留字符串类型的值，其余类型跳过
+func parseBedrockHeaders(raw []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	errInvalid := errors.New("bedrock event stream: invalid headers")
+	for len(raw) > 0 {
+		nameLen := int(raw[0])
+		if len(raw) < 1+nameLen+1 {
+			return nil, errInvalid
+		}
+		name := string(raw[1 : 1+nameLen])
+		valueType := raw[1+nameLen]
+		raw = raw[2+nameLen:]
+
+		var size int
+		switch valueType {
+		case 0, 1: // bool true / false
+			size = 0
+		case 2: // byte
+			size = 1
+		case 3: // short
+			size = 2
+		case 4: // int
+			size = 4
+		case 5, 8: // long / timestamp
+			size = 8
+		case 9: // uuid
+			size = 16
+		case 6, 7: // bytes / string：2 字节长度前缀
+			if len(raw) < 2 {
+				return nil, errInvalid
+			}
+			size = int(binary.BigEndian.Uint16(raw[:2]))
+			raw = raw[2:]
+		default:
+			return nil, errInvalid
+		}
+		if len(raw) < size {
+			return nil, errInvalid
+		}
+		if valueType == 7 {
+			headers[name] = string(raw[:size])
+		}
+		raw = raw[size:]
+	}
+	return headers, nil
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/racio/llmio/providers"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -15,6 +16,7 @@ type Before struct {
 	structuredOutput bool
 	image            bool
 	raw              []byte
+	fallbackFrom     string // 解析备用模型时为原模型名称，用于限制只切换一次
 }
 
 type Beforer func(data []byte) (*Before, error)
@@ -27,21 +29,22 @@ func NewBeforerGemini(model string, stream bool) Beforer {
 			return nil, errors.New("model is empty")
 		}
 
+		root := gjson.ParseBytes(data)
+
 		var toolCall bool
-		if tools := gjson.GetBytes(data, "tools"); tools.Exists() && len(tools.Array()) != 0 {
+		if tools := root.Get("tools"); tools.Exists() && len(tools.Array()) != 0 {
 			toolCall = true
 		}
-		if gjson.GetBytes(data, "toolConfig").Exists() || gjson.GetBytes(data, "tool_config").Exists() {
+		if geminiGet(root, "toolConfig").Exists() {
 			toolCall = true
 		}
 		if !toolCall {
-			gjson.GetBytes(data, "contents").ForEach(func(_, content gjson.Result) bool {
+			root.Get("contents").ForEach(func(_, content gjson.Result) bool {
 				if toolCall {
 					return false
 				}
 				content.Get("parts").ForEach(func(_, part gjson.Result) bool {
-					if part.Get("functionCall").Exists() || part.Get("function_call").Exists() ||
-						part.Get("functionResponse").Exists() || part.Get("function_response").Exists() {
+					if geminiGet(part, "functionCall").Exists() || geminiGet(part, "functionResponse").Exists() {
 						toolCall = true
 						return false
 					}
@@ -51,67 +54,31 @@ func NewBeforerGemini(model string, stream bool) Beforer {
 			})
 		}
 
+		// config 为部分 SDK 使用的 generationConfig 别名
 		var structuredOutput bool
-		if gjson.GetBytes(data, "generationConfig.responseJsonSchema").Exists() ||
-			gjson.GetBytes(data, "generationConfig.response_json_schema").Exists() ||
-			gjson.GetBytes(data, "generation_config.responseJsonSchema").Exists() ||
-			gjson.GetBytes(data, "generation_config.response_json_schema").Exists() ||
-			gjson.GetBytes(data, "config.responseJsonSchema").Exists() ||
-			gjson.GetBytes(data, "config.response_json_schema").Exists() {
-			structuredOutput = true
-		}
-		if strings.EqualFold(gjson.GetBytes(data, "generationConfig.responseMimeType").String(), "application/json") ||
-			strings.EqualFold(gjson.GetBytes(data, "generationConfig.response_mime_type").String(), "application/json") ||
-			strings.EqualFold(gjson.GetBytes(data, "generation_config.responseMimeType").String(), "application/json") ||
-			strings.EqualFold(gjson.GetBytes(data, "generation_config.response_mime_type").String(), "application/json") ||
-			strings.EqualFold(gjson.GetBytes(data, "config.responseMimeType").String(), "application/json") ||
-			strings.EqualFold(gjson.GetBytes(data, "config.response_mime_type").String(), "application/json") {
-			structuredOutput = true
+		for _, configField := range []string{"generationConfig", "config"} {
+			config := geminiGet(root, configField)
+			if geminiGet(config, "responseJsonSchema").Exists() ||
+				strings.EqualFold(geminiGet(config, "responseMimeType").String(), "application/json") {
+				structuredOutput = true
+			}
 		}
 
 		var image bool
-		gjson.GetBytes(data, "contents").ForEach(func(_, content gjson.Result) bool {
+		root.Get("contents").ForEach(func(_, content gjson.Result) bool {
 			if image {
 				return false
 			}
 			content.Get("parts").ForEach(func(_, part gjson.Result) bool {
-				if image {
-					return false
-				}
-
-				// 支持 camelCase 与 snake_case 两种字段命名
-				inlineData := part.Get("inlineData")
-				if !inlineData.Exists() {
-					inlineData = part.Get("inline_data")
-				}
-				if inlineData.Exists() {
-					mimeType := inlineData.Get("mimeType").String()
-					if mimeType == "" {
-						mimeType = inlineData.Get("mime_type").String()
-					}
-					if strings.HasPrefix(mimeType, "image/") {
-						image = true
-						return false
-					}
-				}
-
-				fileData := part.Get("fileData")
-				if !fileData.Exists() {
-					fileData = part.Get("file_data")
-				}
-				if fileData.Exists() {
-					mimeType := fileData.Get("mimeType").String()
-					if mimeType == "" {
-						mimeType = fileData.Get("mime_type").String()
-					}
-					if strings.HasPrefix(mimeType, "image/") {
+				for _, field := range []string{"inlineData", "fileData"} {
+					if strings.HasPrefix(geminiGet(part, field, "mimeType").String(), "image/") {
 						image = true
 						return false
 					}
 				}
 				return true
 			})
-			return true
+			return !image
 		})
 
 		return &Before{
@@ -125,6 +92,23 @@ func NewBeforerGemini(model string, stream bool) Beforer {
 	}
 }
 
+// geminiGet 按 camelCase 路径逐级读取字段，每一级同时兼容 snake_case 写法（字段表见 providers.GeminiFieldNames）
+func geminiGet(result gjson.Result, path ...string) gjson.Result {
+	for _, field := range path {
+		var next gjson.Result
+		for _, name := range providers.GeminiFieldNames(field) {
+			if next = result.Get(name); next.Exists() {
+				break
+			}
+		}
+		if !next.Exists() {
+			return next
+		}
+		result = next
+	}
+	return result
+}
+
 func BeforerOpenAI(data []byte) (*Before, error) {
 	model := gjson.GetBytes(data, "model").String()
 	if model == "" {
@@ -248,8 +232,26 @@ func BeforerAnthropic(data []byte) (*Before, error) {
 		Model:            model,
 		Stream:           stream,
 		toolCall:         toolCall,
-		structuredOutput: toolCall,
+		structuredOutput: anthropicStructuredOutput(data, toolCall),
 		image:            image,
 		raw:              data,
 	}, nil
 }
+
+// anthropicStructuredOutput 仅在请求强制结构化输出时返回 true：
+// 指定 output_format（JSON Schema 输出），或携带工具且 tool_choice 强制调用（any / 指定工具）；
+// 仅提供工具、由模型自行决定是否调用（auto / none）不算结构化输出
+func anthropicStructuredOutput(data []byte, toolCall bool) bool {
+	if gjson.GetBytes(data, "output_format").Exists() {
+		return true
+	}
+	if !toolCall {
+		return false
+	}
+	switch gjson.GetBytes(data, "tool_choice.type").String() {
+	case "any", "tool":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewBeforerGeminiFieldCase(t *testing.T) {
+	tests := []struct {
+		name                          string
+		body                          string
+		toolCall, structured, isImage bool
+	}{
+		{"plain", `{"contents":[{"parts":[{"text":"hi"}]}]}`, false, false, false},
+		{"camel tool config", `{"toolConfig":{"functionCallingConfig":{"mode":"ANY"}}}`, true, false, false},
+		{"snake tool config", `{"tool_config":{"function_calling_config":{"mode":"ANY"}}}`, true, false, false},
+		{"camel function call", `{"contents":[{"parts":[{"functionCall":{"name":"f"}}]}]}`, true, false, false},
+		{"snake function response", `{"contents":[{"parts":[{"function_response":{"name":"f"}}]}]}`, true, false, false},
+		{"camel json schema", `{"generationConfig":{"responseJsonSchema":{"type":"object"}}}`, false, true, false},
+		{"mixed json schema", `{"generation_config":{"responseJsonSchema":{"type":"object"}}}`, false, true, false},
+		{"snake mime type", `{"generation_config":{"response_mime_type":"application/json"}}`, false, true, false},
+		{"sdk config alias", `{"config":{"response_mime_type":"APPLICATION/JSON"}}`, false, true, false},
+		{"text mime type", `{"generationConfig":{"responseMimeType":"text/plain"}}`, false, false, false},
+		{"camel inline image", `{"contents":[{"parts":[{"inlineData":{"mimeType":"image/png"}}]}]}`, false, false, true},
+		{"snake file image", `{"contents":[{"parts":[{"file_data":{"mime_type":"image/jpeg"}}]}]}`, false, false, true},
+		{"mixed file image", `{"contents":[{"parts":[{"fileData":{"mime_type":"image/jpeg"}}]}]}`, false, false, true},
+		{"non image file", `{"contents":[{"parts":[{"fileData":{"mimeType":"application/pdf"}}]}]}`, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := NewBeforerGemini("gemini-2.5-pro", false)([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if before.toolCall != tt.toolCall || before.structuredOutput != tt.structured || before.image != tt.isImage {
+				t.Fatalf("toolCall=%v structured=%v image=%v, want %v %v %v",
+					before.toolCall, before.structuredOutput, before.image, tt.toolCall, tt.structured, tt.isImage)
+			}
+		})
+	}
+}
+
+func TestBeforerAnthropicCapabilities(t *testing.T) {
+	const tools = `"tools":[{"name":"get_weather","input_schema":{"type":"object"}}]`
+	tests := []struct {
+		name    string
+		body    string
+		columns []string
+	}{
+		{"plain", `{"model":"m"}`, nil},
+		{"empty tools", `{"model":"m","tools":[]}`, nil},
+		// 仅提供工具：不要求结构化输出能力
+		{"tools only", `{"model":"m",` + tools + `}`, []string{"tool_call"}},
+		{"tools auto", `{"model":"m",` + tools + `,"tool_choice":{"type":"auto"}}`, []string{"tool_call"}},
+		{"tools none", `{"model":"m",` + tools + `,"tool_choice":{"type":"none"}}`, []string{"tool_call"}},
+		// 仅结构化输出
+		{"output format only", `{"model":"m","output_format":{"type":"json_schema","schema":{"type":"object"}}}`, []string{"structured_output"}},
+		// 强制工具调用视为结构化输出
+		{"tools forced any", `{"model":"m",` + tools + `,"tool_choice":{"type":"any"}}`, []string{"tool_call", "structured_output"}},
+		{"tools forced named", `{"model":"m",` + tools + `,"tool_choice":{"type":"tool","name":"get_weather"}}`, []string{"tool_call", "structured_output"}},
+		{"tools and output format", `{"model":"m",` + tools + `,"output_format":{"type":"json_schema","schema":{}}}`, []string{"tool_call", "structured_output"}},
+		// 没有工具时 tool_choice 不生效
+		{"forced without tools", `{"model":"m","tool_choice":{"type":"any"}}`, nil},
+		{"image", `{"model":"m","messages":[{"role":"user","content":[{"type":"image","source":{}}]}]}`, []string{"image"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := BeforerAnthropic([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := capabilityColumns(*before); !slices.Equal(got, tt.columns) {
+				t.Fatalf("capability columns = %v, want %v", got, tt.columns)
+			}
+		})
+	}
+}
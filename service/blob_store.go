@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+// BlobStore 对象存储，用于存放完整的请求/响应内容（ChatIO），数据库只保留对象 key
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+var (
+	blobStoreOnce sync.Once
+	blobStore     BlobStore
+)
+
+// GetBlobStore 返回按环境变量配置的对象存储，未配置 CHAT_IO_S3_ENDPOINT/CHAT_IO_S3_BUCKET 时返回 nil
+func GetBlobStore() BlobStore {
+	blobStoreOnce.Do(func() {
+		endpoint := strings.TrimSpace(os.Getenv("CHAT_IO_S3_ENDPOINT"))
+		bucket := strings.TrimSpace(os.Getenv("CHAT_IO_S3_BUCKET"))
+		if endpoint == "" || bucket == "" {
+			return
+		}
+		region := strings.TrimSpace(os.Getenv("CHAT_IO_S3_REGION"))
+		if region == "" {
+			region = "us-east-1"
+		}
+		blobStore = &S3BlobStore{
+			Endpoint:  strings.TrimRight(endpoint, "/"),
+			Bucket:    bucket,
+			Region:    region,
+			AccessKey: os.Getenv("CHAT_IO_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("CHAT_IO_S3_SECRET_KEY"),
+		}
+		slog.Info("chat io blob store enabled", "endpoint", endpoint, "bucket", bucket)
+	})
+	return blobStore
+}
+
+// chatIOBlobKey ChatIO 对象 key：<前缀><年/月/日>/<日志 ID>.json，前缀由 CHAT_IO_S3_PREFIX 配置
+func chatIOBlobKey(logId uint, now time.Time) string {
+	prefix := os.Getenv("CHAT_IO_S3_PREFIX")
+	if prefix == "" {
+		prefix = "chat-io/"
+	}
+	return fmt.Sprintf("%s%s/%d.json", prefix, now.Format("2006/01/02"), logId)
+}
+
+// chatIOBlob 对象存储中的 ChatIO 内容
+type chatIOBlob struct {
+	Input             string `json:"input"`
+	OutputString      string `json:"output_string"`
+	OutputStringArray string `json:"output_string_array"`
+}
+
+// saveChatIOToBlob 将 ChatIO 内容写入对象存储，数据库只记录对象 key；写入失败时回退为直接落库
+func saveChatIOToBlob(ctx context.Context, store BlobStore, chatIO models.ChatIO) error {
+	content, err := json.Marshal(chatIOBlob{
+		Input:             chatIO.Input,
+		OutputString:      chatIO.OutputString,
+		OutputStringArray: chatIO.OutputStringArray,
+	})
+	if err != nil {
+		return err
+	}
+	key := chatIOBlobKey(chatIO.LogId, time.Now())
+	if err := store.Put(ctx, key, content); err != nil {
+		slog.Warn("put chat io to blob store failed, fallback to database", "log_id", chatIO.LogId, "error", err)
+		return gorm.G[models.ChatIO](models.DB).Create(ctx, &chatIO)
+	}
+	return gorm.G[models.ChatIO](models.DB).Create(ctx, &models.ChatIO{
+		LogId:   chatIO.LogId,
+		BlobKey: key,
+	})
+}
+
+// LoadChatIOBlob 对象存储中的 ChatIO 读回填充到记录，未引用对象时为空操作
+func LoadChatIOBlob(ctx context.Context, chatIO *models.ChatIO) error {
+	if chatIO.BlobKey == "" {
+		return nil
+	}
+	store := GetBlobStore()
+	if store == nil {
+		return fmt.Errorf("blob store not configured for key %s", chatIO.BlobKey)
+	}
+	content, err := store.Get(ctx, chatIO.BlobKey)
+	if err != nil {
+		return err
+	}
+	var blob chatIOBlob
+	if err := json.Unmarshal(content, &blob); err != nil {
+		return err
+	}
+	chatIO.Input = blob.Input
+	chatIO.OutputString = blob.OutputString
+	chatIO.OutputStringArray = blob.OutputStringArray
+	return nil
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/racio/llmio/balancers"
+)
+
+const (
+	breakerSweepInterval = 10 * time.Minute
+	// breakerIdleTimeout 熔断节点闲置超过该时长后移除（如关联已删除或模型已关闭熔断），再次使用时重新以 closed 状态创建
+	breakerIdleTimeout = time.Hour
+)
+
+// StartBreakerSweeper 后台定期清理长期闲置的熔断节点，避免已删除关联的节点一直占用内存
+func StartBreakerSweeper(ctx context.Context) {
+	go breakerSweepLoop(ctx)
+}
+
+func breakerSweepLoop(ctx context.Context) {
+	ticker := time.NewTicker(breakerSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if removed := balancers.SweepIdleBreakers(breakerIdleTimeout, time.Now()); removed > 0 {
+			slog.Info("Idle breaker nodes removed", "count", removed)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/racio/llmio/models"
+)
+
+// monthSpendTTL 月度消费缓存的过期时间，覆盖整月即可，跨月后使用新的 key
+const monthSpendTTL = 32 * 24 * time.Hour
+
+var (
+	monthSpendMu          sync.Mutex
+	monthSpendMemory      = make(map[string]float64) // 未启用 Redis 时的月度消费缓存
+	monthSpendMemoryMonth string                     // monthSpendMemory 中条目所属的月份
+)
+
+// incrMonthSpendScript 仅在缓存已存在时累加：缓存缺失时下次读取会从数据库重新汇总（已包含本次消费）
+var incrMonthSpendScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("INCRBYFLOAT", KEYS[1], ARGV[1])
+end
+return false
+`)
+
+// MonthStart 返回 t 所在自然月的起始时间
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func monthSpendKey(authKeyID uint, now time.Time) string {
+	return fmt.Sprintf("spend:auth_key:%d:%s", authKeyID, now.Format("200601"))
+}
+
+// rollMonthSpendMemoryLocked 跨月后清空内存缓存中上月的条目，调用方需持有 monthSpendMu
+func rollMonthSpendMemoryLocked(now time.Time) {
+	if month := now.Format("200601"); month != monthSpendMemoryMonth {
+		monthSpendMemory = make(map[string]float64)
+		monthSpendMemoryMonth = month
+	}
+}
+
+// GetMonthSpend 获取 AuthKey 本月累计消费：优先读取缓存，缓存缺失时从 chat_logs 汇总并写入缓存
+func GetMonthSpend(ctx context.Context, authKeyID uint) (float64, error) {
+	now := time.Now()
+	key := monthSpendKey(authKeyID, now)
+
+	rdb := GetRedisClient()
+	if rdb != nil {
+		value, err := rdb.Get(ctx, key).Result()
+		if err == nil {
+			return strconv.ParseFloat(value, 64)
+		}
+		if !errors.Is(err, redis.Nil) {
+			return 0, err
+		}
+	} else {
+		monthSpendMu.Lock()
+		rollMonthSpendMemoryLocked(now)
+		spend, ok := monthSpendMemory[key]
+		monthSpendMu.Unlock()
+		if ok {
+			return spend, nil
+		}
+	}
+
+	spend, err := sumMonthSpend(ctx, authKeyID, MonthStart(now))
+	if err != nil {
+		return 0, err
+	}
+	if rdb != nil {
+		// 并发请求同时回源时以先写入者为准
+		if err := rdb.SetNX(ctx, key, strconv.FormatFloat(spend, 'f', -1, 64), monthSpendTTL).Err(); err != nil {
+			slog.Warn("cache month spend failed", "auth_key_id", authKeyID, "error", err)
+		}
+		return spend, nil
+	}
+	monthSpendMu.Lock()
+	rollMonthSpendMemoryLocked(now)
+	if _, ok := monthSpendMemory[key]; !ok {
+		monthSpendMemory[key] = spend
+	}
+	monthSpendMu.Unlock()
+	return spend, nil
+}
+
+// addMonthSpend 请求费用计算完成后累加到本月消费缓存
+func addMonthSpend(ctx context.Context, authKeyID uint, cost float64) {
+	if authKeyID == 0 || cost <= 0 {
+		return
+	}
+	now := time.Now()
+	key := monthSpendKey(authKeyID, now)
+	if rdb := GetRedisClient(); rdb != nil {
+		if err := incrMonthSpendScript.Run(ctx, rdb, []string{key}, cost).Err(); err != nil && !errors.Is(err, redis.Nil) {
+			slog.Warn("incr month spend failed", "auth_key_id", authKeyID, "error", err)
+		}
+		return
+	}
+	monthSpendMu.Lock()
+	defer monthSpendMu.Unlock()
+	rollMonthSpendMemoryLocked(now)
+	if spend, ok := monthSpendMemory[key]; ok {
+		monthSpendMemory[key] = spend + cost
+	}
+}
+
+// sumMonthSpend 汇总本月消费；预算检查在请求链路上，读取主库避免副本延迟导致超出预算
+func sumMonthSpend(ctx context.Context, authKeyID uint, since time.Time) (float64, error) {
+	var total float64
+	err := models.DB.WithContext(ctx).
+		Model(&models.ChatLog{}).
+		Select("COALESCE(SUM(total_cost), 0)").
+		Where("auth_key_id = ? AND created_at >= ?", authKeyID, since).
+		Scan(&total).Error
+	return total, err
+}
+
+// CheckMonthlyBudget 检查 AuthKey 本月消费是否已达到预算，budget <= 0 表示不限制
+func CheckMonthlyBudget(ctx context.Context, authKeyID uint, budget float64) (float64, bool, error) {
+	if authKeyID == 0 || budget <= 0 {
+		return 0, false, nil
+	}
+	spend, err := GetMonthSpend(ctx, authKeyID)
+	if err != nil {
+		return 0, false, err
+	}
+	return spend, spend >= budget, nil
+}
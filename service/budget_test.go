@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollMonthSpendMemory(t *testing.T) {
+	monthSpendMu.Lock()
+	defer monthSpendMu.Unlock()
+	origMemory, origMonth := monthSpendMemory, monthSpendMemoryMonth
+	t.Cleanup(func() { monthSpendMemory, monthSpendMemoryMonth = origMemory, origMonth })
+
+	september := time.Date(2026, 9, 30, 23, 59, 0, 0, time.UTC)
+	october := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	rollMonthSpendMemoryLocked(september)
+	monthSpendMemory[monthSpendKey(1, september)] = 3
+	monthSpendMemory[monthSpendKey(2, september)] = 5
+
+	// 同月内不清空
+	rollMonthSpendMemoryLocked(september.Add(-time.Hour))
+	if len(monthSpendMemory) != 2 {
+		t.Fatalf("entries = %d, want 2 within the same month", len(monthSpendMemory))
+	}
+
+	// 跨月后上月条目全部移除
+	rollMonthSpendMemoryLocked(october)
+	if len(monthSpendMemory) != 0 {
+		t.Fatalf("entries after rollover = %v, want none", monthSpendMemory)
+	}
+	monthSpendMemory[monthSpendKey(1, october)] = 1
+	rollMonthSpendMemoryLocked(october.Add(time.Hour))
+	if monthSpendMemory[monthSpendKey(1, october)] != 1 {
+		t.Fatalf("current month entry lost: %v", monthSpendMemory)
+	}
+}
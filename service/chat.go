@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"cmp"
 	"compress/gzip"
 	"context"
 	"encoding/base64"
@@ -11,12 +12,15 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/racio/llmio/balancers"
+	"github.com/racio/llmio/common"
 	"github.com/racio/llmio/consts"
 	"github.com/racio/llmio/models"
 	"github.com/racio/llmio/pkg"
@@ -39,7 +43,7 @@ func safeBodyTextForLog(res *http.Response, body []byte) string {
 		if zr, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
 			if b, err := io.ReadAll(zr); err == nil {
 				decoded = b
-				decodedLabel = " (gzip 解压后)"
+				decodedLabel = logBodyGzipLabel
 			}
 			_ = zr.Close()
 		}
@@ -56,7 +60,7 @@ func safeBodyTextForLog(res *http.Response, body []byte) string {
 	if utf8.Valid(decoded) {
 		text := string(decoded)
 		if truncated {
-			return fmt.Sprintf("%s%s...(已截断，总计 %d 字节)", text, decodedLabel, totalBytes)
+			return text + decodedLabel + fmt.Sprintf(logBodyTruncatedFormat, totalBytes)
 		}
 		return text + decodedLabel
 	}
@@ -64,18 +68,66 @@ func safeBodyTextForLog(res *http.Response, body []byte) string {
 	// 非 UTF-8 内容：用 base64 保存（避免 PostgreSQL UTF8 编码错误）
 	b64 := base64.StdEncoding.EncodeToString(decoded)
 	if truncated {
-		return fmt.Sprintf("base64%s:%s...(已截断，总计 %d 字节)", decodedLabel, b64, totalBytes)
+		return logBodyBase64Prefix + decodedLabel + ":" + b64 + fmt.Sprintf(logBodyTruncatedFormat, totalBytes)
 	}
-	return fmt.Sprintf("base64%s:%s", decodedLabel, b64)
+	return logBodyBase64Prefix + decodedLabel + ":" + b64
 }
 
 // BalanceChatWithLimiter 带限流功能的聊天负载均衡
-func BalanceChatWithLimiter(c *gin.Context, start time.Time, style string, before Before, providersWithMeta *ProvidersWithMeta, reqMeta models.ReqMeta) (*http.Response, *models.ChatLog, error) {
+// 返回本次实际使用的提供商集合：切换到备用模型时为备用模型的集合，调用方据此记录日志、计费与查找提供商
+func BalanceChatWithLimiter(c *gin.Context, start time.Time, style string, before Before, providersWithMeta *ProvidersWithMeta, reqMeta models.ReqMeta) (*http.Response, *models.ChatLog, *ProvidersWithMeta, error) {
 	return balanceChatInternal(c, start, style, before, providersWithMeta, reqMeta, true)
 }
 
-// balanceChatInternal 内部聊天负载均衡实现
-func balanceChatInternal(c *gin.Context, start time.Time, style string, before Before, providersWithMeta *ProvidersWithMeta, reqMeta models.ReqMeta, enableLimiter bool) (*http.Response, *models.ChatLog, error) {
+// balanceChatInternal 内部聊天负载均衡实现：模型的全部提供商都失败时，若配置了备用模型则改用备用模型的提供商重试一次
+func balanceChatInternal(c *gin.Context, start time.Time, style string, before Before, providersWithMeta *ProvidersWithMeta, reqMeta models.ReqMeta, enableLimiter bool) (*http.Response, *models.ChatLog, *ProvidersWithMeta, error) {
+	res, log, err := balanceChatModel(c, start, style, before, providersWithMeta, reqMeta, enableLimiter)
+	if err == nil || providersWithMeta.loadFallback == nil {
+		return res, log, providersWithMeta, err
+	}
+	// 仅在提供商全部失败/无可用提供商时切换；超时、客户端取消、限流依赖不可用等直接返回
+	switch common.ErrorCodeOf(err) {
+	case common.ErrCodeUpstreamError, common.ErrCodeNoProvider:
+	default:
+		return res, log, providersWithMeta, err
+	}
+
+	ctx := context.Background()
+	if c != nil {
+		ctx = c.Request.Context()
+	}
+	fallback, fallbackErr := providersWithMeta.loadFallback(ctx)
+	if fallbackErr != nil {
+		slog.Warn("load fallback model failed", "model", before.Model, "error", fallbackErr)
+		return res, log, providersWithMeta, err
+	}
+	RecordTimeline(ctx, "model_fallback", map[string]any{"from": before.Model, "to": fallback.model})
+	slog.Info("all providers failed, falling back", "model", before.Model, "fallback_model", fallback.model)
+
+	// 客户端通过请求头关闭的 IO 记录对备用模型同样生效
+	if c != nil {
+		fallback.ApplyRequestIOLog(c.Request.Header)
+	}
+	res, log, err = balanceChatModel(c, start, style, fallback.Before(before), fallback, reqMeta, enableLimiter)
+	return res, log, fallback, err
+}
+
+// requestedModel 返回客户端请求的模型名，备用模型的集合返回原模型名称
+func (p *ProvidersWithMeta) requestedModel(before Before) string {
+	return cmp.Or(p.FallbackFrom, before.Model)
+}
+
+// Before 返回按该提供商集合的模型改写后的请求信息，备用模型的集合会记录原模型名称
+func (p *ProvidersWithMeta) Before(before Before) Before {
+	if p.FallbackFrom != "" {
+		before.Model = p.model
+		before.fallbackFrom = p.FallbackFrom
+	}
+	return before
+}
+
+// balanceChatModel 在单个模型的提供商集合内负载均衡与重试
+func balanceChatModel(c *gin.Context, start time.Time, style string, before Before, providersWithMeta *ProvidersWithMeta, reqMeta models.ReqMeta, enableLimiter bool) (*http.Response, *models.ChatLog, error) {
 	slog.Info("request", "model", before.Model, "stream", before.Stream, "tool_call", before.toolCall, "structured_output", before.structuredOutput, "image", before.image)
 
 	// 获取context
@@ -86,6 +138,9 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 		ctx = context.Background()
 	}
 
+	// 按模型配置压低客户端请求的推理预算，备用模型按其自身配置处理
+	applyReasoningLimits(ctx, style, &before, providersWithMeta.ReasoningLimits)
+
 	providerMap := providersWithMeta.ProviderMap
 
 	var proxyIP string
@@ -93,27 +148,72 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 		proxyIP = cfg.ProxyIP
 	}
 
-	// 收集重试过程中的err日志
-	retryLog := make(chan models.ChatLog, providersWithMeta.MaxRetry)
+	// 收集重试过程中的err日志（额外预留一条最终汇总记录）
+	retryLog := make(chan models.ChatLog, providersWithMeta.MaxRetry+1)
 	defer close(retryLog)
 
 	go RecordRetryLog(context.Background(), retryLog)
 
-	// 选择负载均衡策略
-	var balancer balancers.Balancer
-	switch providersWithMeta.Strategy {
-	case consts.BalancerLottery:
-		balancer = balancers.NewLottery(providersWithMeta.WeightItems)
-	case consts.BalancerRotor:
-		balancer = balancers.NewRotor(providersWithMeta.WeightItems)
-	default:
-		balancer = balancers.NewLottery(providersWithMeta.WeightItems)
+	authKeyID, _ := ctx.Value(consts.ContextKeyAuthKeyID).(uint)
+
+	// 同一客户端请求的各次尝试与最终汇总记录共享 RequestID
+	requestID, err := pkg.GenerateRandomCharsKey(36)
+	if err != nil {
+		return nil, nil, err
+	}
+	attemptErrors := make([]string, 0, providersWithMeta.MaxRetry)
+	attempt := 0
+	// fail 请求最终失败：写入一条汇总所有尝试失败原因的记录后返回
+	fail := func(err error) (*http.Response, *models.ChatLog, error) {
+		summary := err.Error()
+		if len(attemptErrors) == 0 {
+			summary += "; no provider attempted"
+		} else {
+			summary += "; attempts: " + strings.Join(attemptErrors, "; ")
+		}
+		retryLog <- models.ChatLog{
+			RequestID:    requestID,
+			FallbackFrom: providersWithMeta.FallbackFrom,
+			Name:         before.Model,
+			Status:       "error",
+			Style:        style,
+			UserAgent:    reqMeta.UserAgent,
+			RemoteIP:     reqMeta.RemoteIP,
+			AuthKeyID:    authKeyID,
+			Error:        summary,
+			Retry:        attempt,
+			ProxyTimeMs:  int(time.Since(start).Milliseconds()),
+		}
+		return nil, nil, err
 	}
 
-	// 是否开启熔断
-	if providersWithMeta.Breaker {
-		balancer = balancers.BalancerWrapperBreaker(balancer)
+	// 选择负载均衡策略（未注册的策略回退到默认策略）
+	balancerOpts := balancers.Options{
+		Costs:        providersWithMeta.Costs,
+		SuccessRates: providersWithMeta.SuccessRates,
+		QualityFloor: providersWithMeta.QualityFloor,
+		Latencies:    providersWithMeta.Latencies,
+		MinWeight:    providersWithMeta.MinWeight,
 	}
+	newBalancer := func(items map[uint]int) balancers.Balancer {
+		balancer, ok := balancers.New(providersWithMeta.Strategy, items, balancerOpts)
+		if !ok {
+			balancer, _ = balancers.New(consts.BalancerDefault, items, balancerOpts)
+		}
+		// 重试时不立即再次选中刚失败的提供商，优先选择剩余权重最高的提供商
+		balancer = balancers.BalancerWrapperFailover(balancer, items)
+		// 跳过因 429 Retry-After 处于冷却中的提供商
+		balancer = balancers.BalancerWrapperCooldown(balancer)
+		// 是否开启熔断
+		if providersWithMeta.Breaker {
+			balancer = balancers.BalancerWrapperBreaker(balancer, providersWithMeta.ModelID, providersWithMeta.BreakerConfig)
+		}
+		return balancer
+	}
+
+	// 权重为 0 的关联仅用于故障转移：先在权重 >0 的关联中选择，全部耗尽后才启用故障转移层
+	primaryItems, fallbackItems := splitFailoverItems(providersWithMeta.WeightItems)
+	balancer := newBalancer(primaryItems)
 
 	// 设置请求超时
 	responseHeaderTimeout := time.Second * time.Duration(providersWithMeta.TimeOut)
@@ -123,8 +223,6 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 	}
 	client := providers.GetClient(responseHeaderTimeout)
 
-	authKeyID, _ := ctx.Value(consts.ContextKeyAuthKeyID).(uint)
-
 	timer := time.NewTimer(time.Second * time.Duration(providersWithMeta.TimeOut))
 	defer timer.Stop()
 	// 同一 provider 失败时先重试 N 次，再切换到其它 provider
@@ -141,20 +239,55 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 		return false
 	}
 
-	attempt := 0
+	// 已尝试过的不同提供商：MaxRetry 控制总尝试次数，MaxProviders 单独限制扇出的提供商数量
+	triedProviders := make(map[uint]struct{})
+	providerCapReached := false
+
 	for attempt < providersWithMeta.MaxRetry {
 		select {
 		case <-ctx.Done():
-			return nil, nil, ctx.Err()
+			return fail(ctx.Err())
 		case <-timer.C:
-			return nil, nil, errors.New("retry time out")
+			return fail(common.NewError(common.ErrCodeUpstreamTimeout, "retry time out"))
 		default:
 			// 加权负载均衡
 			id, err := balancer.Pop()
+			// 剩余提供商都在 Retry-After 冷却中：等待到最早可用时间再选择（仍受整体超时约束）
+			var cooldownErr *balancers.CooldownError
+			if errors.As(err, &cooldownErr) && len(fallbackItems) == 0 {
+				RecordTimeline(ctx, "cooldown_wait", map[string]any{"until": cooldownErr.Until})
+				wait := time.NewTimer(time.Until(cooldownErr.Until))
+				select {
+				case <-ctx.Done():
+					wait.Stop()
+					return fail(ctx.Err())
+				case <-timer.C:
+					wait.Stop()
+					return fail(common.NewError(common.ErrCodeUpstreamTimeout, "retry time out"))
+				case <-wait.C:
+				}
+				continue
+			}
+			if err != nil && len(fallbackItems) > 0 {
+				RecordTimeline(ctx, "failover_tier", map[string]any{"candidates": len(fallbackItems)})
+				balancer = newBalancer(fallbackItems)
+				fallbackItems = nil
+				continue
+			}
 			if err != nil {
-				return nil, nil, err
+				if providerCapReached {
+					return fail(common.NewError(common.ErrCodeUpstreamError, "maximum providers per request reached"))
+				}
+				return fail(common.WrapError(common.ErrCodeNoProvider, err))
 			}
 
+			// 能力不变式：只能使用 WeightItems 中的候选。WeightItems 已按请求所需能力（tool_call/structured_output/image）过滤，
+			// 负载均衡器的 Delete/Reduce 只会缩小或调整该集合，无论删除多少个提供商都不会回退到不具备能力的关联；
+			// ModelWithProviderMap 还包含影子关联，不能用作候选判断
+			if _, ok := providersWithMeta.WeightItems[id]; !ok {
+				balancer.Delete(id)
+				continue
+			}
 			modelWithProvider, ok := providersWithMeta.ModelWithProviderMap[id]
 			if !ok {
 				// 数据不一致，移除该模型避免下次重复命中
@@ -165,21 +298,50 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 			provider := providerMap[modelWithProvider.ProviderID]
 
 			// 限流检查（fail-closed：依赖不可用直接拒绝）
+			// token 锁以当前关联 ID + 请求 auth key ID 为维度：同一 token 粘住该关联，其它 token 被拒后切换到其它提供商
+			// 锁时长由模型 TokenLockSeconds 决定，0 表示该模型不启用 token 锁
 			if enableLimiter && c != nil {
-				canProceed, reason, err := CheckProviderLimits(ctx, c, provider.ID, provider.RpmLimit, provider.IpLockMinutes, modelWithProvider.ID, authKeyID)
+				canProceed, reason, err := CheckProviderLimits(ctx, c, provider.ID, provider.RpmLimit, provider.TpmLimit, provider.IpLockMinutes, modelWithProvider.ID, modelWithProvider.RpmLimit, authKeyID, providersWithMeta.TokenLockTTL)
 				if err != nil {
-					return nil, nil, err
+					return fail(err)
 				}
 				if !canProceed {
-					slog.Info("Provider blocked by limiter", "provider", provider.Name, "reason", reason)
+					RecordTimeline(ctx, "limiter_blocked", map[string]any{"provider": provider.Name, "reason": reason})
+					slog.Info("Provider blocked by limiter", "provider", provider.Name, "model_with_provider_id", modelWithProvider.ID, "token_id", authKeyID, "reason", reason)
 					balancer.Reduce(id) // 降低权重，但不完全删除
 					continue
 				}
 			}
 
+			// 已达到提供商数上限：只允许继续使用已尝试过的提供商
+			if _, tried := triedProviders[provider.ID]; !tried {
+				if providersWithMeta.MaxProviders > 0 && len(triedProviders) >= providersWithMeta.MaxProviders {
+					providerCapReached = true
+					balancer.Delete(id)
+					continue
+				}
+				triedProviders[provider.ID] = struct{}{}
+			}
+
 			chatModel, err := providers.New(provider.Type, provider.Config)
 			if err != nil {
-				return nil, nil, err
+				return fail(err)
+			}
+
+			// 提供商并发上限：名额在该提供商的重试期间一直占用，成功时随响应体关闭释放，切换提供商前释放
+			releaseSlot := func() {}
+			if enableLimiter && c != nil {
+				acquired, release, err := AcquireProviderConcurrency(ctx, provider.ID, provider.MaxConcurrency)
+				if err != nil {
+					return fail(err)
+				}
+				if !acquired {
+					RecordTimeline(ctx, "limiter_blocked", map[string]any{"provider": provider.Name, "reason": "concurrency_limit_exceeded"})
+					slog.Info("Provider blocked by limiter", "provider", provider.Name, "model_with_provider_id", modelWithProvider.ID, "token_id", authKeyID, "reason", "concurrency_limit_exceeded")
+					balancer.Reduce(id)
+					continue
+				}
+				releaseSlot = release
 			}
 
 			slog.Info("using provider", "provider", provider.Name, "model", modelWithProvider.ProviderModel)
@@ -212,6 +374,8 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 				}
 
 				log := models.ChatLog{
+					RequestID:     requestID,
+					FallbackFrom:  providersWithMeta.FallbackFrom,
 					Name:          before.Model,
 					ProviderModel: modelWithProvider.ProviderModel,
 					ProviderName:  provider.Name,
@@ -225,9 +389,38 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 					ProxyTimeMs:   int(time.Since(start).Milliseconds()),
 				}
 
-				req, err := chatModel.BuildReq(ctx, header, modelWithProvider.ProviderModel, before.raw)
-				if err != nil {
+				attemptStart := time.Now()
+				logAttemptError := func(log models.ChatLog, err error) {
+					attemptErrors = append(attemptErrors, fmt.Sprintf("[%d] %s(%s): %s", retry, provider.Name, modelWithProvider.ProviderModel, err.Error()))
 					retryLog <- log.WithError(err)
+				}
+				recordAttempt := func(httpStatus int, err error) {
+					attrs := map[string]any{
+						"provider":       provider.Name,
+						"provider_model": modelWithProvider.ProviderModel,
+						"retry":          retry,
+						"status":         "success",
+						"latency_ms":     time.Since(attemptStart).Milliseconds(),
+					}
+					if httpStatus != 0 {
+						attrs["http_status"] = httpStatus
+					}
+					if err != nil {
+						attrs["status"] = "error"
+						attrs["error"] = err.Error()
+					}
+					RecordTimeline(ctx, "attempt", attrs)
+				}
+
+				// Anthropic 提供商承接 OpenAI 请求：请求体先转换为 Anthropic messages 格式
+				reqBody, err := upstreamRequestBody(style, provider.Type, before.raw)
+				var req *http.Request
+				if err == nil {
+					req, err = chatModel.BuildReq(ctx, header, modelWithProvider.ProviderModel, reqBody)
+				}
+				if err != nil {
+					recordAttempt(0, err)
+					logAttemptError(log, err)
 					// 构建请求失败属于不可恢复配置问题，直接切换
 					lastStatus = 0
 					lastWas429 = false
@@ -236,14 +429,15 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 
 				res, err := client.Do(req)
 				if err != nil {
-					retryLog <- log.WithError(err)
+					recordAttempt(0, err)
+					logAttemptError(log, err)
 					lastStatus = 0
 					lastWas429 = false
 					// 网络/超时类错误：继续在同一 provider 内重试
 					continue
 				}
 
-				if res.StatusCode != http.StatusOK {
+				if !IsSuccessStatus(provider.SuccessStatusCodes, res.StatusCode) {
 					lastStatus = res.StatusCode
 					lastWas429 = res.StatusCode == http.StatusTooManyRequests
 
@@ -251,9 +445,31 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 					if readErr != nil {
 						slog.Error("read body error", "error", readErr)
 					}
-					retryLog <- log.WithError(fmt.Errorf("status: %d, body: %s", res.StatusCode, safeBodyTextForLog(res, byteBody)))
+					statusErr := fmt.Errorf("status: %d, body: %s", res.StatusCode, safeBodyTextForLog(res, byteBody))
+					recordAttempt(res.StatusCode, statusErr)
+					logAttemptError(log, statusErr)
 					_ = res.Body.Close()
 
+					// 额度耗尽且上游给出重置时间：停用该提供商直到重置，本次请求也不再选择
+					if until, ok := quotaResetTime(res, byteBody, time.Now()); ok {
+						if err := DisableProviderUntil(ctx, provider.ID, &until); err != nil {
+							slog.Error("disable provider until quota reset error", "provider", provider.Name, "error", err)
+						} else {
+							slog.Warn("provider quota exhausted, disabled until reset", "provider", provider.Name, "until", until)
+							RecordTimeline(ctx, "provider_disabled", map[string]any{"provider": provider.Name, "until": until})
+						}
+						lastWas429 = false
+						break
+					}
+
+					// 429 带 Retry-After：该提供商在指定时间内不再被选择，也不在本提供商内继续重试
+					if lastWas429 {
+						if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+							balancers.SetCooldown(id, retryAfter)
+							break
+						}
+					}
+
 					// 非可重试的 4xx：直接切换（不浪费同 provider 的 3 次机会）
 					if !isRetryableStatus(res.StatusCode) {
 						break
@@ -263,11 +479,37 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 				}
 
 				// success
+				recordAttempt(res.StatusCode, nil)
 				balancer.Success(id)
+				res.Body = &releaseOnClose{ReadCloser: res.Body, release: releaseSlot}
+
+				if before.Stream {
+					res.Body = newIdleTimeoutReader(res.Body, streamReadTimeout)
+					res.Body = newDeadlineReader(res.Body, providersWithMeta.StreamMaxDuration)
+				}
+				// Bedrock 流式响应先解码为 Anthropic SSE
+				translateBedrockResponse(res, provider.Type, before.Stream)
+				// Anthropic 提供商承接 OpenAI 请求：响应转换回 OpenAI 格式
+				if translatesToAnthropic(style, provider.Type) {
+					if err := translateAnthropicResponse(res, before.Stream); err != nil {
+						// 关闭响应体以释放上游连接与并发名额
+						_ = res.Body.Close()
+						logAttemptError(log, err)
+						return fail(err)
+					}
+				}
+				// 响应中的模型名替换为客户端请求的网关模型名（备用模型仍回显原模型名）
+				if providersWithMeta.EchoModel {
+					if err := echoRequestedModel(res, providersWithMeta.requestedModel(before), before.Stream); err != nil {
+						_ = res.Body.Close()
+						logAttemptError(log, err)
+						return fail(err)
+					}
+				}
 
 				// 记录限流访问
 				if enableLimiter && c != nil {
-					if err := RecordProviderAccess(ctx, c, provider.ID, provider.RpmLimit, provider.IpLockMinutes); err != nil {
+					if err := RecordProviderAccess(ctx, c, provider.ID, provider.IpLockMinutes); err != nil {
 						slog.Warn("Failed to record provider access", "provider", provider.Name, "error", err)
 					}
 				}
@@ -275,6 +517,7 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 				return res, &log, nil
 			}
 
+			releaseSlot()
 			// 同一 provider 多次失败后再切换
 			if lastWas429 {
 				balancer.Reduce(id)
@@ -288,7 +531,72 @@ func balanceChatInternal(c *gin.Context, start time.Time, style string, before B
 		}
 	}
 
-	return nil, nil, errors.New("maximum retry attempts reached")
+	return fail(common.NewError(common.ErrCodeUpstreamError, "maximum retry attempts reached"))
+}
+
+// ParseSuccessStatusCodes 解析提供商配置的成功状态码列表（逗号分隔），空字符串返回 [200]
+// 429 固定按限流处理，不能配置为成功
+func ParseSuccessStatusCodes(raw string) ([]int, error) {
+	codes := make([]int, 0)
+	for part := range strings.SplitSeq(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code: %s", part)
+		}
+		if code == http.StatusTooManyRequests {
+			return nil, errors.New("status code 429 is reserved for rate limiting")
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		codes = append(codes, http.StatusOK)
+	}
+	return codes, nil
+}
+
+// IsSuccessStatus 上游状态码是否属于该提供商配置的成功状态码，配置无效时按仅 200 处理
+func IsSuccessStatus(raw string, code int) bool {
+	codes, err := ParseSuccessStatusCodes(raw)
+	if err != nil {
+		return code == http.StatusOK
+	}
+	return slices.Contains(codes, code)
+}
+
+// parseRetryAfter 解析 Retry-After 头：秒数或 HTTP-date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// releaseOnClose 响应体关闭时释放占用的提供商并发名额
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
 }
 
 func RecordRetryLog(ctx context.Context, retryLog chan models.ChatLog) {
@@ -299,10 +607,12 @@ func RecordRetryLog(ctx context.Context, retryLog chan models.ChatLog) {
 	}
 }
 
-func RecordLog(ctx context.Context, reqStart time.Time, reader io.ReadCloser, processer Processer, logId uint, before Before, ioLog bool) {
+func RecordLog(ctx context.Context, reqStart time.Time, reader io.ReadCloser, processer Processer, logId uint, before Before, ioLog bool, provider models.Provider, authKeyID uint) {
+	// 配置了对象存储时，IO 内容在处理完成后整体写入对象存储，数据库只记录对象 key
+	blobStore := GetBlobStore()
 	recordFunc := func() error {
 		defer reader.Close()
-		if ioLog {
+		if ioLog && blobStore == nil {
 			if err := gorm.G[models.ChatIO](models.DB).Create(ctx, &models.ChatIO{
 				Input: string(before.raw),
 				LogId: logId,
@@ -310,16 +620,53 @@ func RecordLog(ctx context.Context, reqStart time.Time, reader io.ReadCloser, pr
 				return err
 			}
 		}
-		log, output, err := processer(ctx, reader, before.Stream, reqStart)
+		stream := &partialStreamReader{r: reader}
+		log, output, err := processerForProvider(processer, provider.Type)(ctx, stream, before.Stream, reqStart)
 		if err != nil {
+			RecordTimeline(ctx, "failed", map[string]any{"error": err.Error()})
+			if ioLog && blobStore != nil {
+				if blobErr := saveChatIOToBlob(ctx, blobStore, models.ChatIO{LogId: logId, Input: string(before.raw)}); blobErr != nil {
+					slog.Error("save chat io error", "error", blobErr)
+				}
+			}
+			// 处理失败（如上游流中断/读取超时）时将日志标记为错误，避免残留为 success
+			if _, updateErr := gorm.G[models.ChatLog](models.DB).Where("id = ?", logId).Updates(ctx, models.ChatLog{Status: "error", Error: err.Error()}); updateErr != nil {
+				slog.Error("update chat log status error", "error", updateErr)
+			}
 			return err
 		}
+		if before.Stream && log.TotalTokens == 0 && len(output.OfStringArray) > 0 && shouldEstimateStreamUsage(ctx, provider) {
+			log.Usage = EstimateStreamUsage(before.raw, output.OfStringArray)
+			if log.ChunkTimeMs > 0 {
+				log.Tps = float64(log.TotalTokens) / (float64(log.ChunkTimeMs) / 1000)
+			}
+		}
+		// 超过流式最长时间被截断：保留已收到部分的用量，日志标记为错误
+		if stream.expired {
+			log.Status = "error"
+			log.Error = ErrStreamDeadline.Error()
+			RecordTimeline(ctx, "stream_deadline", nil)
+		}
 		log.TotalCost = calculateTotalCost(ctx, before.Model, log.Usage)
+		// token 用量在响应处理完成后才能得知，此时计入提供商 TPM 窗口
+		RecordProviderTokens(ctx, provider.ID, provider.TpmLimit, log.TotalTokens)
+		RecordTimeline(ctx, "completed", map[string]any{
+			"first_chunk_ms": log.FirstChunkTimeMs,
+			"total_tokens":   log.TotalTokens,
+			"size":           log.Size,
+		})
 		if _, err := gorm.G[models.ChatLog](models.DB).Where("id = ?", logId).Updates(ctx, *log); err != nil {
 			return err
 		}
+		// 费用落库后计入 AuthKey 月度消费缓存
+		addMonthSpend(ctx, authKeyID, log.TotalCost)
+		emitUsageLog(ctx, reqStart, logId)
 		if ioLog {
 			chatIO := models.ChatIO{}
+			if blobStore != nil {
+				chatIO.LogId = logId
+				chatIO.Input = string(before.raw)
+			}
 			if output.OfString != "" {
 				chatIO.OutputString = output.OfString
 			} else if len(output.OfStringArray) > 0 {
@@ -328,6 +675,9 @@ func RecordLog(ctx context.Context, reqStart time.Time, reader io.ReadCloser, pr
 					chatIO.OutputStringArray = string(jsonBytes)
 				}
 			}
+			if blobStore != nil {
+				return saveChatIOToBlob(ctx, blobStore, chatIO)
+			}
 			if _, err := gorm.G[models.ChatIO](models.DB).Where("log_id = ?", logId).Updates(ctx, chatIO); err != nil {
 				return err
 			}
@@ -337,6 +687,9 @@ func RecordLog(ctx context.Context, reqStart time.Time, reader io.ReadCloser, pr
 	if err := recordFunc(); err != nil {
 		slog.Error("record log error", "error", err)
 	}
+	saveTimeline(ctx, logId, TimelineFromContext(ctx))
+	publishLogByID(ctx, logId)
+	finishAccessLog(ctx, logId)
 }
 
 func SaveChatLog(ctx context.Context, log models.ChatLog) (uint, error) {
@@ -363,12 +716,32 @@ func SaveChatLog(ctx context.Context, log models.ChatLog) (uint, error) {
 			}
 			return 0, err
 		}
+		// 成功请求在响应处理完成后（RecordLog）再广播，此处只广播直接失败的记录
+		if log.Status != "success" {
+			publishLog(log)
+		}
 		return log.ID, nil
 	}
 
 	return 0, errors.New("failed to generate unique chat log uuid")
 }
 
+// NoIOLogHeader 客户端声明本次请求不记录 IO 内容（仍记录请求日志元数据）
+const NoIOLogHeader = "X-Llmio-No-Log"
+
+// IOLogDisabledByRequest 请求头 X-Llmio-No-Log 为真值（true/1）时关闭本次请求的 IO 记录
+func IOLogDisabledByRequest(header http.Header) bool {
+	disabled, _ := strconv.ParseBool(strings.TrimSpace(header.Get(NoIOLogHeader)))
+	return disabled
+}
+
+// ApplyRequestIOLog 请求头关闭 IO 记录时覆盖模型的 IOLog 设置
+func (p *ProvidersWithMeta) ApplyRequestIOLog(header http.Header) {
+	if p.IOLog && IOLogDisabledByRequest(header) {
+		p.IOLog = false
+	}
+}
+
 func BuildHeaders(source http.Header, withHeader bool, customHeaders map[string]string, stream bool) http.Header {
 	header := http.Header{}
 	if withHeader {
@@ -382,6 +755,7 @@ func BuildHeaders(source http.Header, withHeader bool, customHeaders map[string]
 	header.Del("Authorization")
 	header.Del("X-Api-Key")
 	header.Del("X-Goog-Api-Key")
+	header.Del(NoIOLogHeader)
 
 	for key, value := range customHeaders {
 		header.Set(key, value)
@@ -411,7 +785,8 @@ func calculateTotalCost(ctx context.Context, modelName string, usage models.Usag
 
 	total := float64(billableInput)*price.Input +
 		float64(usage.CompletionTokens)*price.Output +
-		float64(cachedTokens)*price.CacheRead
+		float64(cachedTokens)*price.CacheRead +
+		float64(parseCacheCreationTokens(usage.PromptTokensDetails))*price.CacheWrite
 
 	if total < 0 {
 		return 0
@@ -419,7 +794,8 @@ func calculateTotalCost(ctx context.Context, modelName string, usage models.Usag
 	return total
 }
 
-func loadModelPrice(ctx context.Context, modelName string) (models.ModelPrice, error) {
+// loadModelPrice 按模型名查询价格，测试中可替换
+var loadModelPrice = func(ctx context.Context, modelName string) (models.ModelPrice, error) {
 	price, err := gorm.G[models.ModelPrice](models.DB).Where("model_id = ?", modelName).First(ctx)
 	return price, err
 }
@@ -439,6 +815,22 @@ func parseCachedTokens(details string) int64 {
 	return parsed.CachedTokens
 }
 
+// parseCacheCreationTokens 解析写入缓存的 token 数，按 cache_write 价格计费
+func parseCacheCreationTokens(details string) int64 {
+	raw := strings.TrimSpace(details)
+	if raw == "" {
+		return 0
+	}
+	var parsed models.PromptTokensDetails
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return 0
+	}
+	if parsed.CacheCreationTokens < 0 {
+		return 0
+	}
+	return parsed.CacheCreationTokens
+}
+
 func loadAnthropicProxyIPConfig(ctx context.Context) (models.AnthropicProxyIPConfig, bool) {
 	config, err := gorm.G[models.Config](models.DB).
 		Where("key = ?", models.KeyAnthropicProxyIP).
@@ -466,19 +858,70 @@ func loadAnthropicProxyIPConfig(ctx context.Context) (models.AnthropicProxyIPCon
 	return cfg, true
 }
 
+// splitFailoverItems 按权重拆分候选：权重 >0 的为主候选，权重为 0 的仅用于故障转移
+// 故障转移层内部没有相对权重，统一按 1 参与选择
+func splitFailoverItems(items map[uint]int) (primary map[uint]int, fallback map[uint]int) {
+	primary = make(map[uint]int, len(items))
+	fallback = make(map[uint]int)
+	for id, weight := range items {
+		if weight > 0 {
+			primary[id] = weight
+			continue
+		}
+		fallback[id] = 1
+	}
+	return primary, fallback
+}
+
+// capabilityColumns 返回请求要求关联具备的能力列（tool_call/structured_output/image）
+func capabilityColumns(before Before) []string {
+	var columns []string
+	if before.toolCall {
+		columns = append(columns, "tool_call")
+	}
+	if before.structuredOutput {
+		columns = append(columns, "structured_output")
+	}
+	if before.image {
+		columns = append(columns, "image")
+	}
+	return columns
+}
+
 type ProvidersWithMeta struct {
+	ModelID              uint
 	ModelWithProviderMap map[uint]models.ModelWithProvider
-	WeightItems          map[uint]int
+	WeightItems          map[uint]int // 关联 ID -> 权重，权重为 0 表示仅在其余关联全部失败后用于故障转移
+	ShadowItems          []uint       // 影子提供商关联 ID，不参与正式路由
 	ProviderMap          map[uint]models.Provider
 	MaxRetry             int
 	TimeOut              int
 	IOLog                bool
-	Strategy             string // 负载均衡策略
-	Breaker              bool   // 是否开启熔断
+	Strategy             string                  // 负载均衡策略
+	Breaker              bool                    // 是否开启熔断
+	BreakerConfig        balancers.BreakerConfig // 熔断参数，未配置的项使用默认值
+	MaxInputTokens       int                     // 最大输入 token 数，0 表示不预检
+	TokenLockTTL         time.Duration           // token 独占锁时长，0 表示关闭
+	MaxProviders         int                     // 单次请求最多尝试的不同提供商数，0 表示不限制
+	CacheTTL             time.Duration           // 非流式响应缓存时长，0 表示关闭
+	MinWeight            int                     // 失败降权后保留的最低权重，0 表示不设下限
+	Costs                map[uint]float64        // cost_aware/cost 策略：关联 ID -> 上游模型单价
+	SuccessRates         map[uint]float64        // cost_aware 策略：关联 ID -> 近期成功率
+	QualityFloor         float64                 // cost_aware 策略：成功率下限
+	Latencies            map[uint]float64        // latency 策略：关联 ID -> 近期平均响应时间(毫秒)
+	FallbackFrom         string                  // 非空表示这是备用模型的提供商集合，值为原模型名称
+	StreamMaxDuration    time.Duration           // 流式响应总时长上限，0 表示不限制
+	ReasoningLimits      ReasoningLimits         // 推理预算上限
+	EchoModel            bool                    // 是否将响应中的模型名替换为网关模型名
+
+	model        string                                            // 模型名称
+	loadFallback func(context.Context) (*ProvidersWithMeta, error) // 加载备用模型的提供商集合，未配置备用模型时为 nil
 }
 
 func ProvidersWithMetaBymodelsName(ctx context.Context, providerType string, logStyle string, before Before) (*ProvidersWithMeta, error) {
-	model, err := gorm.G[models.Model](models.DB).Where("name = ?", before.Model).First(ctx)
+	model, err := models.RetryRead(ctx, func() (models.Model, error) {
+		return gorm.G[models.Model](models.DB).Where("name = ?", before.Model).First(ctx)
+	})
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			if _, err := SaveChatLog(ctx, models.ChatLog{
@@ -487,11 +930,11 @@ func ProvidersWithMetaBymodelsName(ctx context.Context, providerType string, log
 				Style:  logStyle,
 				Error:  err.Error(),
 			}); err != nil {
-				return nil, err
+				return nil, common.WrapError(common.ErrCodeStorageUnavailable, err)
 			}
-			return nil, errors.New("not found model " + before.Model)
+			return nil, common.NewError(common.ErrCodeModelNotFound, "not found model "+before.Model)
 		}
-		return nil, err
+		return nil, common.WrapError(common.ErrCodeStorageUnavailable, err)
 	}
 	if model.Status == 0 {
 		if _, err := SaveChatLog(ctx, models.ChatLog{
@@ -500,52 +943,56 @@ func ProvidersWithMetaBymodelsName(ctx context.Context, providerType string, log
 			Style:  logStyle,
 			Error:  "model disabled",
 		}); err != nil {
-			return nil, err
+			return nil, common.WrapError(common.ErrCodeStorageUnavailable, err)
 		}
-		return nil, errors.New("model disabled " + before.Model)
+		return nil, common.NewError(common.ErrCodeModelDisabled, "model disabled "+before.Model)
 	}
 
 	// model_with_providers.status/tool_call/structured_output/image 在数据库中是 0/1（int）
+	// 能力过滤在此一次完成，之后故障切换只会在该结果集内进行（见 balanceChatInternal）
 	modelWithProviderChain := gorm.G[models.ModelWithProvider](models.DB).Where("model_id = ?", model.ID).Where("status = ?", 1)
-
-	if before.toolCall {
-		modelWithProviderChain = modelWithProviderChain.Where("tool_call = ?", 1)
-	}
-
-	if before.structuredOutput {
-		modelWithProviderChain = modelWithProviderChain.Where("structured_output = ?", 1)
+	for _, column := range capabilityColumns(before) {
+		modelWithProviderChain = modelWithProviderChain.Where(column+" = ?", 1)
 	}
 
-	if before.image {
-		modelWithProviderChain = modelWithProviderChain.Where("image = ?", 1)
-	}
-
-	modelWithProviders, err := modelWithProviderChain.Find(ctx)
+	modelWithProviders, err := models.RetryRead(ctx, func() ([]models.ModelWithProvider, error) {
+		return modelWithProviderChain.Find(ctx)
+	})
 	if err != nil {
-		return nil, err
+		return nil, common.WrapError(common.ErrCodeStorageUnavailable, err)
 	}
 
+	modelWithProviders = excludeByRules(ctx, modelWithProviders, before)
+
 	if len(modelWithProviders) == 0 {
-		return nil, errors.New("not provider for model " + before.Model)
+		return nil, common.NewError(common.ErrCodeNoProvider, "not provider for model "+before.Model)
 	}
 
 	modelWithProviderMap := lo.KeyBy(modelWithProviders, func(mp models.ModelWithProvider) uint { return mp.ID })
 
-	providers, err := gorm.G[models.Provider](models.DB).
-		Where("id IN ?", lo.Map(modelWithProviders, func(mp models.ModelWithProvider, _ int) uint { return mp.ProviderID })).
-		Where("type = ?", providerType).
-		Find(ctx)
+	providers, err := models.RetryRead(ctx, func() ([]models.Provider, error) {
+		return scheduledProviders(ctx,
+			lo.Map(modelWithProviders, func(mp models.ModelWithProvider, _ int) uint { return mp.ProviderID }),
+			providers.RoutableTypes(providerType, logStyle),
+			time.Now(),
+		)
+	})
 	if err != nil {
-		return nil, err
+		return nil, common.WrapError(common.ErrCodeStorageUnavailable, err)
 	}
 
 	providerMap := lo.KeyBy(providers, func(p models.Provider) uint { return p.ID })
 
 	weightItems := make(map[uint]int)
+	shadowItems := make([]uint, 0)
 	for _, mp := range modelWithProviders {
 		if _, ok := providerMap[mp.ProviderID]; !ok {
 			continue
 		}
+		if mp.Shadow == 1 {
+			shadowItems = append(shadowItems, mp.ID)
+			continue
+		}
 		weightItems[mp.ID] = mp.Weight
 	}
 
@@ -553,14 +1000,63 @@ func ProvidersWithMetaBymodelsName(ctx context.Context, providerType string, log
 	ioLog := model.IOLog == 1
 	breaker := model.Breaker == 1
 
-	return &ProvidersWithMeta{
+	providersWithMeta := &ProvidersWithMeta{
+		model:                model.Name,
+		ModelID:              model.ID,
 		ModelWithProviderMap: modelWithProviderMap,
 		WeightItems:          weightItems,
+		ShadowItems:          shadowItems,
 		ProviderMap:          providerMap,
 		MaxRetry:             model.MaxRetry,
 		TimeOut:              model.TimeOut,
 		IOLog:                ioLog,
 		Strategy:             model.Strategy,
 		Breaker:              breaker,
-	}, nil
+		BreakerConfig: balancers.BreakerConfig{
+			MaxFailures:      model.BreakerMaxFailures,
+			SleepWindow:      time.Duration(model.BreakerSleepSeconds) * time.Second,
+			HalfOpenRequests: model.BreakerHalfOpenRequests,
+		},
+		MaxInputTokens:    model.MaxInputTokens,
+		TokenLockTTL:      time.Duration(model.TokenLockSeconds) * time.Second,
+		MaxProviders:      model.MaxProvidersPerRequest,
+		CacheTTL:          time.Duration(model.CacheTTLSeconds) * time.Second,
+		MinWeight:         model.MinWeight,
+		StreamMaxDuration: time.Duration(model.StreamMaxSeconds) * time.Second,
+		ReasoningLimits: ReasoningLimits{
+			MaxThinkingTokens:   model.MaxThinkingTokens,
+			MaxReasoningEffort:  model.MaxReasoningEffort,
+			MaxCompletionTokens: model.MaxCompletionTokens,
+		},
+		EchoModel: model.EchoModel == 1,
+	}
+	switch model.Strategy {
+	case consts.BalancerCostAware:
+		loadCostAwareMeta(ctx, model.Name, providersWithMeta)
+	case consts.BalancerCost:
+		costs, err := loadAssociationCosts(ctx, providersWithMeta)
+		if err != nil {
+			slog.Warn("load provider model prices error", "model", model.Name, "error", err)
+		}
+		providersWithMeta.Costs = costs
+	case consts.BalancerLatency:
+		loadLatencyMeta(ctx, model.Name, providersWithMeta)
+	}
+	// 备用模型只在全部提供商失败时才加载；备用模型自身的备用模型不再生效，最多切换一次
+	if fallbackModel := strings.TrimSpace(model.FallbackModel); fallbackModel != "" && before.fallbackFrom == "" && fallbackModel != model.Name {
+		providersWithMeta.loadFallback = func(ctx context.Context) (*ProvidersWithMeta, error) {
+			fallbackBefore := before
+			fallbackBefore.Model = fallbackModel
+			fallbackBefore.fallbackFrom = model.Name
+			fallback, err := ProvidersWithMetaBymodelsName(ctx, providerType, logStyle, fallbackBefore)
+			if err != nil {
+				return nil, err
+			}
+			fallback.FallbackFrom = model.Name
+			// 回显的是客户端请求的原模型名，是否回显按原模型的配置
+			fallback.EchoModel = model.EchoModel == 1
+			return fallback, nil
+		}
+	}
+	return providersWithMeta, nil
 }
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+)
+
+func TestProvidersWithMetaBefore(t *testing.T) {
+	before := Before{Model: "gpt-4o", Stream: true, raw: []byte(`{}`)}
+
+	primary := &ProvidersWithMeta{model: "gpt-4o"}
+	if got := primary.Before(before); got.Model != "gpt-4o" || got.fallbackFrom != "" {
+		t.Fatalf("primary: got model %q fallbackFrom %q", got.Model, got.fallbackFrom)
+	}
+
+	fallback := &ProvidersWithMeta{model: "claude-sonnet", FallbackFrom: "gpt-4o"}
+	got := fallback.Before(before)
+	if got.Model != "claude-sonnet" || got.fallbackFrom != "gpt-4o" || !got.Stream {
+		t.Fatalf("fallback: got %+v", got)
+	}
+	if before.Model != "gpt-4o" {
+		t.Fatalf("original before mutated: %q", before.Model)
+	}
+}
+
+// newFailingProviders 构造 n 个始终返回 500 的 openai 提供商，hits 记录每个提供商收到的请求数
+func newFailingProviders(t *testing.T, n int, baseID uint) (*ProvidersWithMeta, []*atomic.Int64) {
+	t.Helper()
+	statuses := make([]int, n)
+	for i := range statuses {
+		statuses[i] = http.StatusInternalServerError
+	}
+	return newTestProviders(t, baseID, statuses...)
+}
+
+// newTestProviders 为每个状态码构造一个始终返回该状态码的 openai 提供商（权重均为 1）
+func newTestProviders(t *testing.T, baseID uint, statuses ...int) (*ProvidersWithMeta, []*atomic.Int64) {
+	t.Helper()
+	meta := &ProvidersWithMeta{
+		ModelWithProviderMap: make(map[uint]models.ModelWithProvider),
+		WeightItems:          make(map[uint]int),
+		ProviderMap:          make(map[uint]models.Provider),
+		MaxRetry:             10,
+		TimeOut:              10,
+		Strategy:             consts.BalancerDefault,
+		model:                "gpt-4o",
+	}
+	hits := make([]*atomic.Int64, len(statuses))
+	for i, status := range statuses {
+		counter := new(atomic.Int64)
+		hits[i] = counter
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			counter.Add(1)
+			w.WriteHeader(status)
+			w.Write([]byte(`{"choices":[]}`))
+		}))
+		t.Cleanup(srv.Close)
+
+		id := baseID + uint(i)
+		provider := models.Provider{Name: fmt.Sprintf("p%d", i), Type: consts.StyleOpenAI, Config: `{"base_url":"` + srv.URL + `/v1","api_key":"k"}`}
+		provider.ID = id
+		mp := models.ModelWithProvider{ProviderID: id, ProviderModel: "gpt-4o", Weight: 1}
+		mp.ID = id
+		meta.ProviderMap[id] = provider
+		meta.ModelWithProviderMap[id] = mp
+		meta.WeightItems[id] = 1
+	}
+	return meta, hits
+}
+
+func TestBalanceChatModelMaxProviders(t *testing.T) {
+	before := Before{Model: "gpt-4o", raw: []byte(`{"model":"gpt-4o","messages":[]}`)}
+
+	tests := []struct {
+		name         string
+		maxProviders int
+		wantTried    int
+		wantErr      string
+	}{
+		{"capped", 2, 2, "maximum providers per request reached"},
+		{"unlimited", 0, 3, ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := captureSQL(t)
+			meta, hits := newFailingProviders(t, 3, uint(9100+i*10))
+			meta.MaxProviders = tt.maxProviders
+
+			_, _, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false)
+			if err == nil {
+				t.Fatal("expected error when all providers fail")
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+
+			tried, total := 0, int64(0)
+			for _, h := range hits {
+				if h.Load() > 0 {
+					tried++
+				}
+				total += h.Load()
+			}
+			if tried != tt.wantTried {
+				t.Fatalf("distinct providers tried = %d, want %d", tried, tt.wantTried)
+			}
+			// 同一提供商内仍按 perProviderMaxAttempts 重试
+			if total != int64(2*tt.wantTried) {
+				t.Fatalf("total attempts = %d, want %d", total, 2*tt.wantTried)
+			}
+			// 等待后台写完每次尝试与最终汇总的日志，避免在 models.DB 还原后写入
+			waitChatLogs(t, statements, int(total)+1)
+		})
+	}
+}
+
+// waitChatLogs 等待 chat_logs 写入达到 n 条
+func waitChatLogs(t *testing.T, statements func() []string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		count := 0
+		for _, sql := range statements() {
+			if strings.HasPrefix(sql, `INSERT INTO "chat_logs"`) {
+				count++
+			}
+		}
+		if count >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("chat_logs inserts = %d, want %d", count, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBalanceChatModelImageNeverFailsOverToNonImage(t *testing.T) {
+	statements := captureSQL(t)
+	// 1、2 为支持图片的关联，均失败；3 为不支持图片的影子关联，能成功响应但不能被选中
+	meta, hits := newFailingProviders(t, 2, 9200)
+	var nonImageHits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		nonImageHits.Add(1)
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+	provider := models.Provider{Name: "text-only", Type: consts.StyleOpenAI, Config: `{"base_url":"` + srv.URL + `/v1","api_key":"k"}`}
+	provider.ID = 9202
+	mp := models.ModelWithProvider{ProviderID: provider.ID, ProviderModel: "gpt-4o", Weight: 100}
+	mp.ID = 9202
+	meta.ProviderMap[provider.ID] = provider
+	meta.ModelWithProviderMap[mp.ID] = mp
+	meta.ShadowItems = []uint{mp.ID}
+	meta.MaxRetry = 20
+
+	before := Before{Model: "gpt-4o", image: true, raw: []byte(`{"model":"gpt-4o","messages":[]}`)}
+	_, _, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false)
+	if err == nil {
+		t.Fatal("expected error when all image providers fail")
+	}
+	if n := nonImageHits.Load(); n != 0 {
+		t.Fatalf("non-image provider received %d requests", n)
+	}
+	var total int64
+	for _, h := range hits {
+		total += h.Load()
+	}
+	waitChatLogs(t, statements, int(total)+1)
+}
+
+func TestSplitFailoverItems(t *testing.T) {
+	primary, fallback := splitFailoverItems(map[uint]int{1: 5, 2: 0, 3: 1, 4: 0})
+	if want := map[uint]int{1: 5, 3: 1}; !reflect.DeepEqual(primary, want) {
+		t.Fatalf("primary = %v, want %v", primary, want)
+	}
+	// 故障转移层内按等权重选择
+	if want := map[uint]int{2: 1, 4: 1}; !reflect.DeepEqual(fallback, want) {
+		t.Fatalf("fallback = %v, want %v", fallback, want)
+	}
+}
+
+func TestBalanceChatModelZeroWeightFailover(t *testing.T) {
+	before := Before{Model: "gpt-4o", raw: []byte(`{"model":"gpt-4o","messages":[]}`)}
+
+	t.Run("used after primaries fail", func(t *testing.T) {
+		statements := captureSQL(t)
+		// 0、1 为主提供商且失败，2 权重为 0 且可成功
+		meta, hits := newTestProviders(t, 9300, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK)
+		meta.WeightItems[9302] = 0
+
+		res, log, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if log.ProviderName != "p2" {
+			t.Fatalf("served by %s, want failover provider p2", log.ProviderName)
+		}
+		if hits[0].Load() == 0 || hits[1].Load() == 0 {
+			t.Fatalf("primaries hits = %d, %d; want both tried first", hits[0].Load(), hits[1].Load())
+		}
+		waitChatLogs(t, statements, int(hits[0].Load()+hits[1].Load()))
+	})
+
+	t.Run("unused while primary succeeds", func(t *testing.T) {
+		captureSQL(t)
+		meta, hits := newTestProviders(t, 9310, http.StatusOK, http.StatusOK)
+		meta.WeightItems[9311] = 0
+
+		for range 5 {
+			res, log, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if log.ProviderName != "p0" {
+				t.Fatalf("served by %s, want primary p0", log.ProviderName)
+			}
+		}
+		if n := hits[1].Load(); n != 0 {
+			t.Fatalf("failover provider received %d requests", n)
+		}
+	})
+}
+
+func TestIOLogDisabledByRequest(t *testing.T) {
+	tests := map[string]bool{"": false, "true": true, "1": true, " TRUE ": true, "false": false, "0": false, "yes": false}
+	for value, want := range tests {
+		header := http.Header{}
+		if value != "" {
+			header.Set(NoIOLogHeader, value)
+		}
+		if got := IOLogDisabledByRequest(header); got != want {
+			t.Errorf("%q: got %v, want %v", value, got, want)
+		}
+	}
+	// 该请求头只用于网关，不透传给上游
+	source := http.Header{NoIOLogHeader: {"true"}, "X-Trace": {"1"}}
+	if got := BuildHeaders(source, true, nil, false); got.Get(NoIOLogHeader) != "" || got.Get("X-Trace") != "1" {
+		t.Fatalf("forwarded headers = %v", got)
+	}
+}
+
+func TestNoIOLogHeaderSkipsChatIO(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header string
+		wantIO bool
+	}{
+		{"header set", "true", false},
+		{"header absent", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			statements := captureSQL(t)
+			meta, _ := newTestProviders(t, 9500, http.StatusOK)
+			meta.IOLog = true
+			header := http.Header{}
+			if tc.header != "" {
+				header.Set(NoIOLogHeader, tc.header)
+			}
+			meta.ApplyRequestIOLog(header)
+
+			before := Before{Model: "gpt-4o", raw: []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"secret"}]}`)}
+			res, log, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := log.ChatIO == 1; got != tc.wantIO {
+				t.Fatalf("log.ChatIO = %d, want io logging %v", log.ChatIO, tc.wantIO)
+			}
+			RecordLog(context.Background(), time.Now(), res.Body, ProcesserOpenAI, 1, before, meta.IOLog, meta.ProviderMap[9500], 0)
+
+			wroteIO, wroteLog := false, false
+			for _, stmt := range statements() {
+				wroteIO = wroteIO || strings.Contains(stmt, `"chat_io"`)
+				wroteLog = wroteLog || strings.Contains(stmt, `UPDATE "chat_logs"`)
+			}
+			if wroteIO != tc.wantIO {
+				t.Fatalf("chat_io written = %v, want %v: %v", wroteIO, tc.wantIO, statements())
+			}
+			// 元数据日志照常更新
+			if !wroteLog {
+				t.Fatalf("chat log metadata not updated: %v", statements())
+			}
+		})
+	}
+}
+
+func TestParseSuccessStatusCodes(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []int
+		wantErr bool
+	}{
+		{"", []int{200}, false},
+		{" , ", []int{200}, false},
+		{"200,201", []int{200, 201}, false},
+		{" 201 , 206 ", []int{201, 206}, false},
+		{"abc", nil, true},
+		{"99", nil, true},
+		{"600", nil, true},
+		{"200,429", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSuccessStatusCodes(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+
+	// 配置无效时按仅 200 处理
+	if !IsSuccessStatus("bad", http.StatusOK) || IsSuccessStatus("bad", http.StatusCreated) {
+		t.Fatal("invalid config should fall back to 200 only")
+	}
+}
+
+func TestBalanceChatModelCustomSuccessStatus(t *testing.T) {
+	before := Before{Model: "gpt-4o", raw: []byte(`{"model":"gpt-4o","messages":[]}`)}
+
+	t.Run("201 configured as success", func(t *testing.T) {
+		captureSQL(t)
+		meta, hits := newTestProviders(t, 9600, http.StatusCreated)
+		provider := meta.ProviderMap[9600]
+		provider.SuccessStatusCodes = "200,201"
+		meta.ProviderMap[9600] = provider
+
+		res, _, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("status = %d, want 201", res.StatusCode)
+		}
+		if n := hits[0].Load(); n != 1 {
+			t.Fatalf("provider hits = %d, want 1 (no retry)", n)
+		}
+	})
+
+	t.Run("201 rejected by default", func(t *testing.T) {
+		statements := captureSQL(t)
+		meta, hits := newTestProviders(t, 9610, http.StatusCreated)
+		meta.MaxRetry = 2
+
+		if _, _, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false); err == nil {
+			t.Fatal("expected 201 to be treated as failure without configuration")
+		}
+		if hits[0].Load() == 0 {
+			t.Fatal("provider never called")
+		}
+		waitChatLogs(t, statements, int(hits[0].Load()))
+	})
+}
+
+func TestBalanceChatModelNoImmediateReselection(t *testing.T) {
+	statements := captureSQL(t)
+	// 0 权重极高但返回 429（仅降权不移除），1 权重很低但可成功
+	meta, hits := newTestProviders(t, 9800, http.StatusTooManyRequests, http.StatusOK)
+	meta.WeightItems[9800] = 1000
+	meta.WeightItems[9801] = 1
+
+	before := Before{Model: "gpt-4o", raw: []byte(`{"model":"gpt-4o","messages":[]}`)}
+	res, log, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if log.ProviderName != "p1" {
+		t.Fatalf("served by %s, want p1", log.ProviderName)
+	}
+	// 失败的提供商只在自己的一轮重试内被请求，之后不再被立即选中
+	if n := hits[0].Load(); n == 0 || n > 2 {
+		t.Fatalf("failed provider hits = %d, want 1..2", n)
+	}
+	if n := hits[1].Load(); n != 1 {
+		t.Fatalf("healthy provider hits = %d, want 1", n)
+	}
+	waitChatLogs(t, statements, int(hits[0].Load()))
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// EnableCollectStream collect 模式下强制以流式请求上游，并要求返回 usage 以便聚合用量
+func EnableCollectStream(body []byte) ([]byte, error) {
+	body, err := sjson.SetBytes(body, "stream", true)
+	if err != nil {
+		return nil, err
+	}
+	if !gjson.GetBytes(body, "stream_options.include_usage").Exists() {
+		return sjson.SetBytes(body, "stream_options.include_usage", true)
+	}
+	return body, nil
+}
+
+type collectedToolCall struct {
+	Index    int    `json:"-"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type collectedMessage struct {
+	Role             string              `json:"role"`
+	Content          *string             `json:"content"`
+	ReasoningContent string              `json:"reasoning_content,omitempty"`
+	Refusal          *string             `json:"refusal,omitempty"`
+	ToolCalls        []collectedToolCall `json:"tool_calls,omitempty"`
+}
+
+type collectedChoice struct {
+	Index        int              `json:"index"`
+	Message      collectedMessage `json:"message"`
+	FinishReason *string          `json:"finish_reason"`
+}
+
+type collectedCompletion struct {
+	ID                string            `json:"id"`
+	Object            string            `json:"object"`
+	Created           int64             `json:"created"`
+	Model             string            `json:"model"`
+	SystemFingerprint string            `json:"system_fingerprint,omitempty"`
+	Choices           []collectedChoice `json:"choices"`
+	Usage             json.RawMessage   `json:"usage,omitempty"`
+}
+
+// CollectOpenAIStream 消费完整的 OpenAI SSE 响应，聚合为单个 chat.completion JSON
+// 分块解析复用 ProcesserOpenAI，上游流中的错误原样返回
+func CollectOpenAIStream(ctx context.Context, stream io.Reader) ([]byte, error) {
+	_, output, err := ProcesserOpenAI(ctx, stream, true, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(output.OfStringArray) == 0 {
+		return nil, errors.New("empty stream response from upstream")
+	}
+	return json.Marshal(assembleChatCompletion(output.OfStringArray))
+}
+
+// assembleChatCompletion 将 chat.completion.chunk 按 choice 合并：
+// content/reasoning_content/refusal 依次拼接，tool_calls 按 index 合并并拼接 arguments，usage 取最后一个非空值
+func assembleChatCompletion(chunks []string) collectedCompletion {
+	completion := collectedCompletion{Object: "chat.completion", Choices: []collectedChoice{}}
+	choices := make(map[int]*collectedChoice)
+
+	for _, raw := range chunks {
+		chunk := gjson.Parse(raw)
+		if completion.ID == "" {
+			completion.ID = chunk.Get("id").String()
+			completion.Created = chunk.Get("created").Int()
+			completion.Model = chunk.Get("model").String()
+		}
+		if fp := chunk.Get("system_fingerprint").String(); fp != "" {
+			completion.SystemFingerprint = fp
+		}
+		if usage := chunk.Get("usage"); usage.IsObject() {
+			completion.Usage = json.RawMessage(usage.Raw)
+		}
+
+		for _, c := range chunk.Get("choices").Array() {
+			index := int(c.Get("index").Int())
+			choice, ok := choices[index]
+			if !ok {
+				choice = &collectedChoice{Index: index, Message: collectedMessage{Role: "assistant"}}
+				choices[index] = choice
+			}
+			delta := c.Get("delta")
+			if role := delta.Get("role").String(); role != "" {
+				choice.Message.Role = role
+			}
+			if content := delta.Get("content"); content.Type == gjson.String {
+				s := content.String()
+				if choice.Message.Content != nil {
+					s = *choice.Message.Content + s
+				}
+				choice.Message.Content = &s
+			}
+			choice.Message.ReasoningContent += delta.Get("reasoning_content").String()
+			if refusal := delta.Get("refusal"); refusal.Type == gjson.String {
+				s := refusal.String()
+				if choice.Message.Refusal != nil {
+					s = *choice.Message.Refusal + s
+				}
+				choice.Message.Refusal = &s
+			}
+			for _, tc := range delta.Get("tool_calls").Array() {
+				mergeToolCallDelta(&choice.Message, tc)
+			}
+			if reason := c.Get("finish_reason"); reason.Type == gjson.String {
+				s := reason.String()
+				choice.FinishReason = &s
+			}
+		}
+	}
+
+	for _, choice := range choices {
+		completion.Choices = append(completion.Choices, *choice)
+	}
+	slices.SortFunc(completion.Choices, func(a, b collectedChoice) int { return a.Index - b.Index })
+	return completion
+}
+
+// mergeToolCallDelta 同一 index 的工具调用：id/name 取首次出现的值，arguments 逐段拼接
+func mergeToolCallDelta(message *collectedMessage, delta gjson.Result) {
+	index := int(delta.Get("index").Int())
+	i := slices.IndexFunc(message.ToolCalls, func(tc collectedToolCall) bool { return tc.Index == index })
+	if i < 0 {
+		message.ToolCalls = append(message.ToolCalls, collectedToolCall{Index: index, Type: "function"})
+		i = len(message.ToolCalls) - 1
+	}
+	tc := &message.ToolCalls[i]
+	if id := delta.Get("id").String(); id != "" && tc.ID == "" {
+		tc.ID = id
+	}
+	if typ := delta.Get("type").String(); typ != "" {
+		tc.Type = typ
+	}
+	if name := delta.Get("function.name").String(); name != "" && tc.Function.Name == "" {
+		tc.Function.Name = name
+	}
+	tc.Function.Arguments += delta.Get("function.arguments").String()
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sseStream 将 chunk 拼成 OpenAI SSE 响应
+func sseStream(chunks ...string) string {
+	var b strings.Builder
+	for _, chunk := range chunks {
+		fmt.Fprintf(&b, "data: %s\n\n", chunk)
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+func TestCollectOpenAIStreamMatchesDeltas(t *testing.T) {
+	contentDeltas := []string{"Hel", "lo", ", ", "world"}
+	argDeltas := []string{`{"ci`, `ty":"Par`, `is"}`}
+
+	chunks := []string{`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`}
+	for _, d := range contentDeltas {
+		chunks = append(chunks, fmt.Sprintf(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"content":%q}}]}`, d))
+	}
+	chunks = append(chunks, `{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`)
+	for _, d := range argDeltas {
+		chunks = append(chunks, fmt.Sprintf(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":%q}}]}}]}`, d))
+	}
+	chunks = append(chunks,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}}`,
+	)
+
+	body, err := CollectOpenAIStream(context.Background(), strings.NewReader(sseStream(chunks...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Created int64  `json:"created"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Index   int `json:"index"`
+			Message struct {
+				Role      string `json:"role"`
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Type     string `json:"type"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("unmarshal %s: %v", body, err)
+	}
+
+	if got.ID != "chatcmpl-1" || got.Object != "chat.completion" || got.Created != 1700000000 || got.Model != "gpt-4o" {
+		t.Fatalf("unexpected envelope: %s", body)
+	}
+	if len(got.Choices) != 1 {
+		t.Fatalf("choices = %d, want 1", len(got.Choices))
+	}
+	msg := got.Choices[0].Message
+	if want := strings.Join(contentDeltas, ""); msg.Content != want {
+		t.Errorf("content = %q, want %q", msg.Content, want)
+	}
+	if msg.Role != "assistant" {
+		t.Errorf("role = %q, want assistant", msg.Role)
+	}
+	if len(msg.ToolCalls) != 1 {
+		t.Fatalf("tool_calls = %d, want 1", len(msg.ToolCalls))
+	}
+	tc := msg.ToolCalls[0]
+	if tc.ID != "call_1" || tc.Type != "function" || tc.Function.Name != "get_weather" {
+		t.Errorf("unexpected tool call: %+v", tc)
+	}
+	if want := strings.Join(argDeltas, ""); tc.Function.Arguments != want {
+		t.Errorf("arguments = %q, want %q", tc.Function.Arguments, want)
+	}
+	if got.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("finish_reason = %q, want tool_calls", got.Choices[0].FinishReason)
+	}
+	if got.Usage.TotalTokens != 19 {
+		t.Errorf("usage.total_tokens = %d, want 19", got.Usage.TotalTokens)
+	}
+}
+
+func TestCollectOpenAIStreamMultipleChoices(t *testing.T) {
+	stream := sseStream(
+		`{"id":"c","choices":[{"index":1,"delta":{"content":"b1"}},{"index":0,"delta":{"content":"a1"}}]}`,
+		`{"id":"c","choices":[{"index":0,"delta":{"content":"a2"},"finish_reason":"stop"},{"index":1,"delta":{"content":"b2"},"finish_reason":"length"}]}`,
+	)
+	body, err := CollectOpenAIStream(context.Background(), strings.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Choices []struct {
+			Index   int `json:"index"`
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Choices) != 2 {
+		t.Fatalf("choices = %d, want 2", len(got.Choices))
+	}
+	for i, want := range []struct{ content, reason string }{{"a1a2", "stop"}, {"b1b2", "length"}} {
+		c := got.Choices[i]
+		if c.Index != i || c.Message.Content != want.content || c.FinishReason != want.reason {
+			t.Errorf("choice %d = %+v, want content %q finish_reason %q", i, c, want.content, want.reason)
+		}
+	}
+}
+
+func TestCollectOpenAIStreamEmpty(t *testing.T) {
+	if _, err := CollectOpenAIStream(context.Background(), strings.NewReader("data: [DONE]\n\n")); err == nil {
+		t.Fatal("expected error for empty stream")
+	}
+}
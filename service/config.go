@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+// configCacheTTL 配置在进程内的缓存时长：本实例修改后立即失效，多实例部署时其它实例最多延迟该时长生效
+const configCacheTTL = 5 * time.Second
+
+// ttlCache 按 key 缓存加载结果，过期或失效后重新加载；加载失败的结果不缓存
+type ttlCache[V any] struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]ttlCacheEntry[V]
+}
+
+type ttlCacheEntry[V any] struct {
+	value  V
+	expiry time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, entries: make(map[string]ttlCacheEntry[V])}
+}
+
+func (c *ttlCache[V]) get(key string, load func() (V, error)) (V, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiry) {
+		return entry.value, nil
+	}
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	c.mu.Lock()
+	c.entries[key] = ttlCacheEntry[V]{value: value, expiry: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return value, nil
+}
+
+func (c *ttlCache[V]) invalidate(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+func (c *ttlCache[V]) invalidateAll() {
+	c.mu.Lock()
+	clear(c.entries)
+	c.mu.Unlock()
+}
+
+// configCache 缓存配置原始值，未配置的 key 缓存为空字符串
+var configCache = newTTLCache[string](configCacheTTL)
+
+// InvalidateConfigCache 配置修改后使本实例的缓存立即失效
+func InvalidateConfigCache(key string) {
+	configCache.invalidate(key)
+}
+
+// loadConfigValue 读取 key 对应的配置原始值，配置不存在时返回空字符串
+var loadConfigValue = func(ctx context.Context, key string) (string, error) {
+	config, err := gorm.G[models.Config](models.DB).
+		Where("key = ?", key).
+		First(ctx)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return config.Value, nil
+}
+
+// loadJSONConfig 读取 key 对应的 JSON 配置到 dst，配置不存在或为空时返回 false
+// 请求路径上频繁读取，结果缓存 configCacheTTL
+func loadJSONConfig(ctx context.Context, key string, dst any) (bool, error) {
+	value, err := configCache.get(key, func() (string, error) {
+		return loadConfigValue(ctx, key)
+	})
+	if err != nil {
+		return false, err
+	}
+	raw := strings.TrimSpace(value)
+	if raw == "" {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(raw), dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
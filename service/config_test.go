@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/racio/llmio/models"
+)
+
+// stubConfig 将配置读取替换为内存 map，并统计实际读取次数
+func stubConfig(t *testing.T, values map[string]string) *int {
+	t.Helper()
+	loads := new(int)
+	orig := loadConfigValue
+	loadConfigValue = func(_ context.Context, key string) (string, error) {
+		*loads++
+		return values[key], nil
+	}
+	configCache.invalidateAll()
+	t.Cleanup(func() {
+		loadConfigValue = orig
+		configCache.invalidateAll()
+	})
+	return loads
+}
+
+func TestLoadJSONConfigCachesAndInvalidates(t *testing.T) {
+	values := map[string]string{models.KeyModelPolicy: `{"enabled":true,"deny":["gpt-*"]}`}
+	loads := stubConfig(t, values)
+	ctx := context.Background()
+
+	for range 5 {
+		var cfg models.ModelPolicyConfig
+		ok, err := loadJSONConfig(ctx, models.KeyModelPolicy, &cfg)
+		if err != nil || !ok || !cfg.Enabled {
+			t.Fatalf("load = %v, %v, %+v", ok, err, cfg)
+		}
+	}
+	if *loads != 1 {
+		t.Fatalf("loads = %d, want 1", *loads)
+	}
+
+	// 修改后失效，下一次读取拿到新值
+	values[models.KeyModelPolicy] = `{"enabled":false}`
+	InvalidateConfigCache(models.KeyModelPolicy)
+	var cfg models.ModelPolicyConfig
+	if _, err := loadJSONConfig(ctx, models.KeyModelPolicy, &cfg); err != nil || cfg.Enabled {
+		t.Fatalf("after invalidate: %+v, %v", cfg, err)
+	}
+	if *loads != 2 {
+		t.Fatalf("loads = %d, want 2", *loads)
+	}
+
+	// 未配置的 key 同样缓存
+	for range 3 {
+		if ok, err := loadJSONConfig(ctx, models.KeyIdempotency, &models.IdempotencyConfig{}); ok || err != nil {
+			t.Fatalf("missing key = %v, %v", ok, err)
+		}
+	}
+	if *loads != 3 {
+		t.Fatalf("loads = %d, want 3", *loads)
+	}
+}
+
+func TestTTLCacheExpiryAndErrors(t *testing.T) {
+	c := newTTLCache[int](30 * time.Millisecond)
+	loads := 0
+	load := func() (int, error) {
+		loads++
+		return loads, nil
+	}
+	if v, _ := c.get("k", load); v != 1 {
+		t.Fatalf("v = %d, want 1", v)
+	}
+	if v, _ := c.get("k", load); v != 1 {
+		t.Fatalf("cached v = %d, want 1", v)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if v, _ := c.get("k", load); v != 2 {
+		t.Fatalf("expired v = %d, want 2", v)
+	}
+
+	// 加载失败不缓存
+	failures := 0
+	failing := func() (int, error) {
+		failures++
+		return 0, errors.New("db down")
+	}
+	for range 2 {
+		if _, err := c.get("bad", failing); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if failures != 2 {
+		t.Fatalf("failures = %d, want 2", failures)
+	}
+}
+
+func TestPromptTemplateCache(t *testing.T) {
+	templates := map[string]string{"greeting": "Be polite."}
+	loads := 0
+	orig := loadPromptTemplate
+	loadPromptTemplate = func(_ context.Context, name string) (*models.PromptTemplate, error) {
+		loads++
+		content, ok := templates[name]
+		if !ok {
+			return nil, nil
+		}
+		return &models.PromptTemplate{Name: name, Content: content}, nil
+	}
+	InvalidatePromptTemplateCache()
+	t.Cleanup(func() {
+		loadPromptTemplate = orig
+		InvalidatePromptTemplateCache()
+	})
+
+	header := map[string][]string{PromptTemplateHeader: {"greeting"}}
+	body := []byte(`{"messages":[{"role":"user","content":"hi"}]}`)
+	for range 3 {
+		if _, err := ApplyPromptTemplate(context.Background(), header, "openai", body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("loads = %d, want 1", loads)
+	}
+
+	delete(templates, "greeting")
+	InvalidatePromptTemplateCache()
+	if _, err := ApplyPromptTemplate(context.Background(), header, "openai", body); err == nil {
+		t.Fatal("expected not found after template deleted")
+	}
+}
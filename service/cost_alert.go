@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/racio/llmio/models"
+)
+
+const (
+	costAlertInterval               = time.Minute
+	defaultCostAlertWindowMinutes   = 60
+	defaultCostAlertDebounceMinutes = 60
+	EventCostThreshold              = "cost_threshold_exceeded"
+)
+
+// modelCostSource 返回 since 之后各模型的累计消费
+type modelCostSource func(ctx context.Context, since time.Time) (map[string]float64, error)
+
+// costAlerter 按模型检查滑动窗口消费并在超阈值时通知，同一模型在防抖期内只通知一次
+type costAlerter struct {
+	source modelCostSource
+	notify func(ctx context.Context, event string, message string, data any) error
+
+	mu        sync.Mutex
+	lastFired map[string]time.Time
+}
+
+// StartCostAlert 启动按模型消费告警的后台任务
+func StartCostAlert(ctx context.Context) {
+	alerter := &costAlerter{
+		source:    queryModelCosts,
+		notify:    Notify,
+		lastFired: make(map[string]time.Time),
+	}
+	go alerter.loop(ctx)
+}
+
+func (a *costAlerter) loop(ctx context.Context) {
+	ticker := time.NewTicker(costAlertInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var cfg models.CostAlertConfig
+		ok, err := loadJSONConfig(ctx, models.KeyCostAlert, &cfg)
+		if err != nil {
+			slog.Error("读取消费告警配置失败", "error", err)
+			continue
+		}
+		if !ok || !cfg.Enabled || len(cfg.Thresholds) == 0 {
+			continue
+		}
+		if err := a.check(ctx, cfg, time.Now()); err != nil {
+			slog.Error("消费告警检查失败", "error", err)
+		}
+	}
+}
+
+// CostAlert 单个模型的告警内容
+type CostAlert struct {
+	Model         string  `json:"model"`
+	Cost          float64 `json:"cost"`
+	Threshold     float64 `json:"threshold"`
+	WindowMinutes int     `json:"window_minutes"`
+}
+
+func (a *costAlerter) check(ctx context.Context, cfg models.CostAlertConfig, now time.Time) error {
+	window := cfg.WindowMinutes
+	if window <= 0 {
+		window = defaultCostAlertWindowMinutes
+	}
+	debounce := time.Duration(cfg.DebounceMinutes) * time.Minute
+	if debounce <= 0 {
+		debounce = defaultCostAlertDebounceMinutes * time.Minute
+	}
+
+	costs, err := a.source(ctx, now.Add(-time.Duration(window)*time.Minute))
+	if err != nil {
+		return err
+	}
+
+	for model, threshold := range cfg.Thresholds {
+		if threshold <= 0 || costs[model] < threshold {
+			continue
+		}
+		a.mu.Lock()
+		last, fired := a.lastFired[model]
+		if fired && now.Sub(last) < debounce {
+			a.mu.Unlock()
+			continue
+		}
+		a.lastFired[model] = now
+		a.mu.Unlock()
+
+		alert := CostAlert{
+			Model:         model,
+			Cost:          costs[model],
+			Threshold:     threshold,
+			WindowMinutes: window,
+		}
+		message := fmt.Sprintf("model %s cost %.4f in last %d minutes exceeds threshold %.4f", model, alert.Cost, window, threshold)
+		slog.Warn("cost threshold exceeded", "model", model, "cost", alert.Cost, "threshold", threshold)
+		if err := a.notify(ctx, EventCostThreshold, message, alert); err != nil {
+			slog.Error("发送消费告警失败", "model", model, "error", err)
+		}
+	}
+	return nil
+}
+
+// queryModelCosts 从 chat_logs 汇总 since 之后各模型的消费
+func queryModelCosts(ctx context.Context, since time.Time) (map[string]float64, error) {
+	var rows []struct {
+		Name string
+		Cost float64
+	}
+	if err := models.Reader().WithContext(ctx).
+		Model(&models.ChatLog{}).
+		Select("name, COALESCE(SUM(total_cost), 0) AS cost").
+		Where("created_at >= ?", since).
+		Group("name").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	costs := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		costs[row.Name] = row.Cost
+	}
+	return costs, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/racio/llmio/models"
+)
+
+type recordedAlert struct {
+	event string
+	alert CostAlert
+}
+
+func newTestCostAlerter(costs map[string]float64, since *time.Time) (*costAlerter, *[]recordedAlert) {
+	fired := new([]recordedAlert)
+	return &costAlerter{
+		source: func(_ context.Context, s time.Time) (map[string]float64, error) {
+			if since != nil {
+				*since = s
+			}
+			return costs, nil
+		},
+		notify: func(_ context.Context, event string, _ string, data any) error {
+			*fired = append(*fired, recordedAlert{event: event, alert: data.(CostAlert)})
+			return nil
+		},
+		lastFired: make(map[string]time.Time),
+	}, fired
+}
+
+func TestCostAlerterFiresOnlyAboveThreshold(t *testing.T) {
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	var since time.Time
+	a, fired := newTestCostAlerter(map[string]float64{
+		"gpt-4o":   12.5,
+		"claude":   3,
+		"disabled": 100,
+	}, &since)
+	cfg := models.CostAlertConfig{
+		Enabled:       true,
+		WindowMinutes: 30,
+		Thresholds:    map[string]float64{"gpt-4o": 10, "claude": 5, "disabled": 0, "missing": 1},
+	}
+
+	if err := a.check(context.Background(), cfg, now); err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(-30 * time.Minute); !since.Equal(want) {
+		t.Fatalf("since = %v, want %v", since, want)
+	}
+	if len(*fired) != 1 {
+		t.Fatalf("fired = %+v, want only gpt-4o", *fired)
+	}
+	got := (*fired)[0]
+	want := CostAlert{Model: "gpt-4o", Cost: 12.5, Threshold: 10, WindowMinutes: 30}
+	if got.event != EventCostThreshold || got.alert != want {
+		t.Fatalf("alert = %s %+v, want %s %+v", got.event, got.alert, EventCostThreshold, want)
+	}
+}
+
+func TestCostAlerterDebounce(t *testing.T) {
+	start := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	a, fired := newTestCostAlerter(map[string]float64{"gpt-4o": 20}, nil)
+	cfg := models.CostAlertConfig{
+		Enabled:         true,
+		DebounceMinutes: 15,
+		Thresholds:      map[string]float64{"gpt-4o": 10},
+	}
+
+	steps := []struct {
+		offset time.Duration
+		total  int
+	}{
+		{0, 1},
+		{time.Minute, 1},
+		{14 * time.Minute, 1},
+		{15 * time.Minute, 2},
+		{20 * time.Minute, 2},
+	}
+	for _, step := range steps {
+		if err := a.check(context.Background(), cfg, start.Add(step.offset)); err != nil {
+			t.Fatal(err)
+		}
+		if len(*fired) != step.total {
+			t.Fatalf("after %v fired %d alerts, want %d", step.offset, len(*fired), step.total)
+		}
+	}
+}
+
+func TestCostAlerterDefaults(t *testing.T) {
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	var since time.Time
+	a, fired := newTestCostAlerter(map[string]float64{"gpt-4o": 20}, &since)
+	cfg := models.CostAlertConfig{Enabled: true, Thresholds: map[string]float64{"gpt-4o": 10}}
+
+	if err := a.check(context.Background(), cfg, now); err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(-defaultCostAlertWindowMinutes * time.Minute); !since.Equal(want) {
+		t.Fatalf("since = %v, want %v", since, want)
+	}
+	// 默认防抖 60 分钟
+	if err := a.check(context.Background(), cfg, now.Add(59*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.check(context.Background(), cfg, now.Add(60*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*fired) != 2 {
+		t.Fatalf("fired %d alerts, want 2", len(*fired))
+	}
+	if (*fired)[0].alert.WindowMinutes != defaultCostAlertWindowMinutes {
+		t.Fatalf("window = %d, want %d", (*fired)[0].alert.WindowMinutes, defaultCostAlertWindowMinutes)
+	}
+}
+
+func TestCostAlerterSourceError(t *testing.T) {
+	sourceErr := errors.New("db down")
+	a, fired := newTestCostAlerter(nil, nil)
+	a.source = func(context.Context, time.Time) (map[string]float64, error) { return nil, sourceErr }
+	cfg := models.CostAlertConfig{Enabled: true, Thresholds: map[string]float64{"gpt-4o": 10}}
+
+	if err := a.check(context.Background(), cfg, time.Now()); !errors.Is(err, sourceErr) {
+		t.Fatalf("err = %v, want %v", err, sourceErr)
+	}
+	if len(*fired) != 0 {
+		t.Fatalf("fired = %+v, want none", *fired)
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCostAwareQualityFloor  = 0.8
+	defaultCostAwareWindowMinutes = 15
+	defaultCostAwareMinRequests   = 10
+)
+
+// loadCostAwareMeta 为 cost_aware 策略加载各关联的上游模型单价与近期成功率
+// 加载失败时仅记录日志，策略退化为按权重排序
+func loadCostAwareMeta(ctx context.Context, modelName string, providersWithMeta *ProvidersWithMeta) {
+	cfg := models.CostAwareRoutingConfig{}
+	if _, err := loadJSONConfig(ctx, models.KeyCostAwareRouting, &cfg); err != nil {
+		slog.Warn("load cost aware routing config error", "error", err)
+	}
+	if cfg.QualityFloor <= 0 {
+		cfg.QualityFloor = defaultCostAwareQualityFloor
+	}
+	if cfg.WindowMinutes <= 0 {
+		cfg.WindowMinutes = defaultCostAwareWindowMinutes
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultCostAwareMinRequests
+	}
+	providersWithMeta.QualityFloor = cfg.QualityFloor
+
+	costs, err := loadAssociationCosts(ctx, providersWithMeta)
+	if err != nil {
+		slog.Warn("load provider model prices error", "model", modelName, "error", err)
+	}
+	providersWithMeta.Costs = costs
+
+	rates, err := loadAssociationSuccessRates(ctx, modelName, providersWithMeta, cfg)
+	if err != nil {
+		slog.Warn("load provider success rates error", "model", modelName, "error", err)
+	}
+	providersWithMeta.SuccessRates = rates
+}
+
+// loadAssociationCosts 按上游模型名匹配 ModelPrice，单价取输入与输出价格之和
+func loadAssociationCosts(ctx context.Context, providersWithMeta *ProvidersWithMeta) (map[uint]float64, error) {
+	costs := make(map[uint]float64)
+	names := make([]string, 0, len(providersWithMeta.WeightItems))
+	for id := range providersWithMeta.WeightItems {
+		names = append(names, strings.ToLower(strings.TrimSpace(providersWithMeta.ModelWithProviderMap[id].ProviderModel)))
+	}
+	if len(names) == 0 {
+		return costs, nil
+	}
+	prices, err := gorm.G[models.ModelPrice](models.DB).Where("model_id IN ?", names).Find(ctx)
+	if err != nil {
+		return costs, err
+	}
+	priceByModel := make(map[string]models.ModelPrice, len(prices))
+	for _, price := range prices {
+		priceByModel[price.ModelID] = price
+	}
+	for id := range providersWithMeta.WeightItems {
+		name := strings.ToLower(strings.TrimSpace(providersWithMeta.ModelWithProviderMap[id].ProviderModel))
+		if price, ok := priceByModel[name]; ok {
+			costs[id] = price.Input + price.Output
+		}
+	}
+	return costs, nil
+}
+
+// loadAssociationSuccessRates 统计窗口内各关联的成功率，请求数不足 MinRequests 的关联不参与质量判断
+func loadAssociationSuccessRates(ctx context.Context, modelName string, providersWithMeta *ProvidersWithMeta, cfg models.CostAwareRoutingConfig) (map[uint]float64, error) {
+	rates := make(map[uint]float64)
+	type row struct {
+		ProviderName  string
+		ProviderModel string
+		Total         int64
+		Success       int64
+	}
+	var rows []row
+	if err := models.Reader().WithContext(ctx).
+		Model(&models.ChatLog{}).
+		Select("provider_name, provider_model, COUNT(*) AS total, SUM(CASE WHEN status = 'success' THEN 1 ELSE 0 END) AS success").
+		Where("name = ?", modelName).
+		Where("created_at >= ?", time.Now().Add(-time.Duration(cfg.WindowMinutes)*time.Minute)).
+		Group("provider_name, provider_model").
+		Scan(&rows).Error; err != nil {
+		return rates, err
+	}
+	type statKey struct{ provider, model string }
+	stats := make(map[statKey]row, len(rows))
+	for _, r := range rows {
+		stats[statKey{r.ProviderName, r.ProviderModel}] = r
+	}
+	for id := range providersWithMeta.WeightItems {
+		mp := providersWithMeta.ModelWithProviderMap[id]
+		provider, ok := providersWithMeta.ProviderMap[mp.ProviderID]
+		if !ok {
+			continue
+		}
+		r, ok := stats[statKey{provider.Name, mp.ProviderModel}]
+		if !ok || r.Total < int64(cfg.MinRequests) {
+			continue
+		}
+		rates[id] = float64(r.Success) / float64(r.Total)
+	}
+	return rates, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/racio/llmio/models"
+)
+
+func TestLoadCostAwareMeta(t *testing.T) {
+	newMeta := func() *ProvidersWithMeta {
+		return &ProvidersWithMeta{
+			WeightItems: map[uint]int{1: 1, 2: 1},
+			ModelWithProviderMap: map[uint]models.ModelWithProvider{
+				1: {ProviderID: 10, ProviderModel: " GPT-4o-Mini "},
+				2: {ProviderID: 20, ProviderModel: "gpt-4o"},
+			},
+			ProviderMap: map[uint]models.Provider{10: {Name: "a"}, 20: {Name: "b"}},
+		}
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		stubConfig(t, nil)
+		statements := captureSQL(t)
+		meta := newMeta()
+
+		loadCostAwareMeta(context.Background(), "gpt-4o", meta)
+
+		if meta.QualityFloor != defaultCostAwareQualityFloor {
+			t.Fatalf("quality floor = %v, want %v", meta.QualityFloor, defaultCostAwareQualityFloor)
+		}
+		if meta.Costs == nil || meta.SuccessRates == nil {
+			t.Fatalf("costs %v rates %v, want non-nil maps", meta.Costs, meta.SuccessRates)
+		}
+		sqls := statements()
+		if len(sqls) != 2 {
+			t.Fatalf("statements = %q, want price and success rate queries", sqls)
+		}
+		// 按规范化后的上游模型名查询价格
+		if !strings.Contains(sqls[0], `"model_prices"`) || !strings.Contains(sqls[0], "'gpt-4o-mini'") || !strings.Contains(sqls[0], "'gpt-4o'") {
+			t.Fatalf("price query = %s", sqls[0])
+		}
+		if !strings.Contains(sqls[1], "name = 'gpt-4o'") || !strings.Contains(sqls[1], "GROUP BY provider_name, provider_model") {
+			t.Fatalf("success rate query = %s", sqls[1])
+		}
+	})
+
+	t.Run("configured floor", func(t *testing.T) {
+		stubConfig(t, map[string]string{models.KeyCostAwareRouting: `{"quality_floor":0.95}`})
+		captureSQL(t)
+		meta := newMeta()
+
+		loadCostAwareMeta(context.Background(), "gpt-4o", meta)
+
+		if meta.QualityFloor != 0.95 {
+			t.Fatalf("quality floor = %v, want 0.95", meta.QualityFloor)
+		}
+	})
+}
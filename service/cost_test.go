@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+func TestCalculateTotalCost(t *testing.T) {
+	prices := map[string]models.ModelPrice{
+		"claude-sonnet": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	}
+	orig := loadModelPrice
+	loadModelPrice = func(_ context.Context, modelName string) (models.ModelPrice, error) {
+		price, ok := prices[modelName]
+		if !ok {
+			return models.ModelPrice{}, gorm.ErrRecordNotFound
+		}
+		return price, nil
+	}
+	t.Cleanup(func() { loadModelPrice = orig })
+
+	tests := []struct {
+		name  string
+		model string
+		usage models.Usage
+		want  float64
+	}{
+		{"input only", "claude-sonnet", models.Usage{PromptTokens: 100}, 300},
+		{"output only", "claude-sonnet", models.Usage{CompletionTokens: 10}, 150},
+		{"input and output", "claude-sonnet", models.Usage{PromptTokens: 100, CompletionTokens: 10}, 450},
+		{
+			// 命中缓存的部分按 cache_read 计费，不再按 input 计费
+			"cache read discount", "claude-sonnet",
+			models.Usage{PromptTokens: 100, PromptTokensDetails: `{"cached_tokens":40}`},
+			60*3 + 40*0.3,
+		},
+		{
+			"cached exceeds prompt", "claude-sonnet",
+			models.Usage{PromptTokens: 10, PromptTokensDetails: `{"cached_tokens":40}`},
+			10 * 0.3,
+		},
+		{
+			// 写入缓存的 token 不包含在 PromptTokens 中，单独按 cache_write 计费
+			"cache write", "claude-sonnet",
+			models.Usage{PromptTokens: 100, CompletionTokens: 10, PromptTokensDetails: `{"cache_creation_tokens":200}`},
+			100*3 + 10*15 + 200*3.75,
+		},
+		{"model name normalized", "  Claude-Sonnet ", models.Usage{PromptTokens: 1}, 3},
+		{"missing price", "unknown-model", models.Usage{PromptTokens: 100, CompletionTokens: 10}, 0},
+		{"empty model", "", models.Usage{PromptTokens: 100}, 0},
+		{"invalid details", "claude-sonnet", models.Usage{PromptTokens: 100, PromptTokensDetails: "not json"}, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateTotalCost(context.Background(), tt.model, tt.usage)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("calculateTotalCost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/providers"
+	"github.com/samber/lo"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"gorm.io/gorm"
+)
+
+// ResolveCountTokensBody 将 Anthropic count_tokens 请求体中的网关模型名改写为上游模型名
+// 取该模型下权重最高的已启用 Anthropic 关联的 ProviderModel，与正式请求 BuildReq 的改写保持一致；
+// 未配置模型或找不到关联时原样转发
+func ResolveCountTokensBody(ctx context.Context, body []byte) []byte {
+	name := gjson.GetBytes(body, "model").String()
+	if name == "" {
+		return body
+	}
+	providerModel, ok := resolveProviderModel(ctx, name, consts.StyleAnthropic)
+	if !ok || providerModel == name {
+		return body
+	}
+	rewritten, err := sjson.SetBytes(body, "model", providerModel)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+// resolveProviderModel 按网关模型名查找指定类型提供商下权重最高的正式关联，返回其上游模型名
+var resolveProviderModel = func(ctx context.Context, name string, providerType string) (string, bool) {
+	model, err := gorm.G[models.Model](models.DB).Where("name = ?", name).First(ctx)
+	if err != nil {
+		return "", false
+	}
+	modelWithProviders, err := gorm.G[models.ModelWithProvider](models.DB).
+		Where("model_id = ?", model.ID).
+		Where("status = ?", 1).
+		Where("shadow = ?", 0).
+		Order("weight DESC").
+		Find(ctx)
+	if err != nil || len(modelWithProviders) == 0 {
+		return "", false
+	}
+	providerList, err := gorm.G[models.Provider](models.DB).
+		Where("id IN ?", lo.Map(modelWithProviders, func(mp models.ModelWithProvider, _ int) uint { return mp.ProviderID })).
+		Where("type IN ?", providers.CompatibleTypes(providerType)).
+		Find(ctx)
+	if err != nil {
+		return "", false
+	}
+	return firstProviderModel(modelWithProviders, providerList)
+}
+
+// firstProviderModel 按关联顺序返回第一个提供商在 providerList 中的关联的上游模型名
+func firstProviderModel(modelWithProviders []models.ModelWithProvider, providerList []models.Provider) (string, bool) {
+	providerMap := lo.KeyBy(providerList, func(p models.Provider) uint { return p.ID })
+	for _, mp := range modelWithProviders {
+		if _, ok := providerMap[mp.ProviderID]; ok {
+			return mp.ProviderModel, true
+		}
+	}
+	return "", false
+}
+
+// PrecheckGeminiInputTokens 转发前调用 Gemini countTokens 统计输入 token 数
+// 返回统计值以及是否超出模型的 MaxInputTokens；统计失败时放行，不影响正常请求
+func PrecheckGeminiInputTokens(ctx context.Context, before Before, providersWithMeta *ProvidersWithMeta) (int64, bool) {
+	if providersWithMeta.MaxInputTokens <= 0 {
+		return 0, false
+	}
+
+	// 选取权重最高的正式提供商进行统计
+	var selected uint
+	maxWeight := -1
+	for id, weight := range providersWithMeta.WeightItems {
+		if weight > maxWeight {
+			selected, maxWeight = id, weight
+		}
+	}
+	modelWithProvider, ok := providersWithMeta.ModelWithProviderMap[selected]
+	if !ok {
+		return 0, false
+	}
+	provider, ok := providersWithMeta.ProviderMap[modelWithProvider.ProviderID]
+	if !ok {
+		return 0, false
+	}
+
+	chatModel, err := providers.New(provider.Type, provider.Config)
+	if err != nil {
+		slog.Warn("gemini count tokens precheck skipped", "provider", provider.Name, "error", err)
+		return 0, false
+	}
+	gemini, ok := chatModel.(*providers.Gemini)
+	if !ok {
+		return 0, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(providersWithMeta.TimeOut))
+	defer cancel()
+	tokens, err := gemini.CountTokens(ctx, modelWithProvider.ProviderModel, before.raw)
+	if err != nil {
+		slog.Warn("gemini count tokens precheck failed", "provider", provider.Name, "error", err)
+		return 0, false
+	}
+	return tokens, tokens > int64(providersWithMeta.MaxInputTokens)
+}
+
+// estimatedImageTokens 图片/文档块按固定 token 数估算（约为 Anthropic 单张图片的上限）
+const estimatedImageTokens = 1600
+
+// CountTokensFallbackEnabled 上游 count_tokens 不可用时是否使用本地估算，未配置时默认开启
+func CountTokensFallbackEnabled(ctx context.Context) bool {
+	cfg := models.CountTokensFallbackConfig{Enabled: true}
+	if _, err := loadJSONConfig(ctx, models.KeyCountTokensFallback, &cfg); err != nil {
+		slog.Warn("load count tokens fallback config failed", "error", err)
+		return true
+	}
+	return cfg.Enabled
+}
+
+// EstimateInputTokens 按 Anthropic messages 请求体近似估算输入 token 数
+// 统计 system、messages 与 tools 中的文本，工具参数/结果按 JSON 文本计入
+func EstimateInputTokens(body []byte) int64 {
+	var total int64
+	system := gjson.GetBytes(body, "system")
+	if system.Type == gjson.String {
+		total += estimateTextTokens(system.String())
+	} else {
+		total += estimateContentTokens(system)
+	}
+	for _, message := range gjson.GetBytes(body, "messages").Array() {
+		// 每条消息的角色与分隔符开销
+		total += 3
+		content := message.Get("content")
+		if content.Type == gjson.String {
+			total += estimateTextTokens(content.String())
+			continue
+		}
+		total += estimateContentTokens(content)
+	}
+	for _, tool := range gjson.GetBytes(body, "tools").Array() {
+		total += estimateTextTokens(tool.Get("name").String())
+		total += estimateTextTokens(tool.Get("description").String())
+		total += estimateTextTokens(tool.Get("input_schema").Raw)
+	}
+	return total
+}
+
+// estimateContentTokens 估算 content 块数组的 token 数
+func estimateContentTokens(blocks gjson.Result) int64 {
+	var total int64
+	for _, block := range blocks.Array() {
+		switch block.Get("type").String() {
+		case "text":
+			total += estimateTextTokens(block.Get("text").String())
+		case "image", "document":
+			total += estimatedImageTokens
+		case "tool_use":
+			total += estimateTextTokens(block.Get("name").String())
+			total += estimateTextTokens(block.Get("input").Raw)
+		case "tool_result":
+			content := block.Get("content")
+			if content.Type == gjson.String {
+				total += estimateTextTokens(content.String())
+			} else {
+				total += estimateContentTokens(content)
+			}
+		case "thinking":
+			total += estimateTextTokens(block.Get("thinking").String())
+		}
+	}
+	return total
+}
+
+// estimateTextTokens 近似分词：ASCII 约 4 个字符一个 token，其余字符（如中日韩文字）按一个 token 计
+func estimateTextTokens(text string) int64 {
+	var ascii, others int64
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			others++
+		}
+	}
+	return (ascii+3)/4 + others
+}
+
+// EstimateStreamUsage 上游流式响应未返回 usage 时（如提供商删除了 stream_options）按请求体与响应分片估算用量
+// chunks 为 OpenAI chat completions 流式响应的 JSON 分片
+func EstimateStreamUsage(body []byte, chunks []string) models.Usage {
+	promptTokens := EstimateInputTokens(body)
+	var completionTokens int64
+	for _, chunk := range chunks {
+		for _, choice := range gjson.Get(chunk, "choices").Array() {
+			delta := choice.Get("delta")
+			completionTokens += estimateTextTokens(delta.Get("content").String())
+			completionTokens += estimateTextTokens(delta.Get("reasoning_content").String())
+			for _, toolCall := range delta.Get("tool_calls").Array() {
+				completionTokens += estimateTextTokens(toolCall.Get("function.name").String())
+				completionTokens += estimateTextTokens(toolCall.Get("function.arguments").String())
+			}
+		}
+	}
+	return models.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// shouldEstimateStreamUsage 提供商删除了 stream_options 且开启了 token 估算时，流式用量由网关估算
+func shouldEstimateStreamUsage(ctx context.Context, provider models.Provider) bool {
+	chatModel, err := providers.New(provider.Type, provider.Config)
+	if err != nil || !providers.StripsStreamOptions(chatModel) {
+		return false
+	}
+	return CountTokensFallbackEnabled(ctx)
+}
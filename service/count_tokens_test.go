@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+)
+
+func TestResolveCountTokensBody(t *testing.T) {
+	orig := resolveProviderModel
+	var gotType string
+	resolveProviderModel = func(_ context.Context, name string, providerType string) (string, bool) {
+		gotType = providerType
+		switch name {
+		case "claude-sonnet":
+			return "claude-sonnet-4-5-20250929", true
+		case "same":
+			return "same", true
+		}
+		return "", false
+	}
+	t.Cleanup(func() { resolveProviderModel = orig })
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"rewritten", `{"model":"claude-sonnet","messages":[{"role":"user","content":"hi"}]}`, `{"model":"claude-sonnet-4-5-20250929","messages":[{"role":"user","content":"hi"}]}`},
+		{"unknown model", `{"model":"other","messages":[]}`, `{"model":"other","messages":[]}`},
+		{"same name", `{"model":"same"}`, `{"model":"same"}`},
+		{"no model", `{"messages":[]}`, `{"messages":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveCountTokensBody(context.Background(), []byte(tt.body)); string(got) != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if gotType != consts.StyleAnthropic {
+		t.Fatalf("provider type = %q, want %q", gotType, consts.StyleAnthropic)
+	}
+}
+
+func TestFirstProviderModel(t *testing.T) {
+	provider := func(id uint) models.Provider {
+		p := models.Provider{Type: consts.StyleAnthropic}
+		p.ID = id
+		return p
+	}
+	// 关联已按权重降序排列；提供商 1 不是 Anthropic 类型，不在 providerList 中
+	mps := []models.ModelWithProvider{
+		{ProviderID: 1, ProviderModel: "openai-name"},
+		{ProviderID: 2, ProviderModel: "anthropic-high"},
+		{ProviderID: 3, ProviderModel: "anthropic-low"},
+	}
+
+	if got, ok := firstProviderModel(mps, []models.Provider{provider(3), provider(2)}); !ok || got != "anthropic-high" {
+		t.Fatalf("got %q, %v; want anthropic-high", got, ok)
+	}
+	if got, ok := firstProviderModel(mps, nil); ok || got != "" {
+		t.Fatalf("got %q, %v; want no match", got, ok)
+	}
+}
+
+func TestEstimateInputTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int64
+	}{
+		{"empty", `{}`, 0},
+		// "abcdefgh" 2 + 消息开销 3
+		{"string content", `{"messages":[{"role":"user","content":"abcdefgh"}]}`, 5},
+		// 中文按字计
+		{"cjk", `{"messages":[{"role":"user","content":"你好"}]}`, 5},
+		// system 4 + 消息 3 + 文本 1 + 图片
+		{"blocks", `{"system":"abcdefghijklmnop","messages":[{"role":"user","content":[{"type":"text","text":"hi"},{"type":"image","source":{}}]}]}`, 4 + 3 + 1 + estimatedImageTokens},
+		// 工具名 1 + 描述 1 + schema `{}` 1
+		{"tools", `{"tools":[{"name":"f","description":"d","input_schema":{}}]}`, 3},
+	}
+	for _, tt := range tests {
+		if got := EstimateInputTokens([]byte(tt.body)); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateStreamUsage(t *testing.T) {
+	body := []byte(`{"messages":[{"role":"user","content":"abcdefgh"}]}`)
+	chunks := []string{
+		`{"choices":[{"delta":{"role":"assistant","content":"abcd"}}]}`,
+		`{"choices":[{"delta":{"reasoning_content":"abcdefgh"}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"function":{"name":"f","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+	}
+	usage := EstimateStreamUsage(body, chunks)
+	// 输入 2 + 3；输出 1 + 2 + 1 + 1
+	if usage.PromptTokens != 5 || usage.CompletionTokens != 5 || usage.TotalTokens != 10 {
+		t.Fatalf("usage = %+v", usage)
+	}
+}
+
+func TestShouldEstimateStreamUsage(t *testing.T) {
+	keep := models.Provider{Type: consts.StyleOpenAI, Config: `{"base_url":"http://upstream/v1"}`}
+	strip := models.Provider{Type: consts.StyleOpenAI, Config: `{"base_url":"http://upstream/v1","strip_stream_options":true}`}
+	ctx := context.Background()
+
+	stubConfig(t, nil)
+	if shouldEstimateStreamUsage(ctx, keep) {
+		t.Fatal("provider keeping stream_options reports usage itself")
+	}
+	if !shouldEstimateStreamUsage(ctx, strip) {
+		t.Fatal("stripping provider should be estimated by default")
+	}
+
+	stubConfig(t, map[string]string{models.KeyCountTokensFallback: `{"enabled":false}`})
+	if shouldEstimateStreamUsage(ctx, strip) {
+		t.Fatal("estimation disabled by config")
+	}
+}
+
+func TestRecordLogEstimatesStrippedStreamUsage(t *testing.T) {
+	stream := "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"abcdefgh\"}}]}\n\n" +
+		"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: [DONE]\n\n"
+	before := Before{Model: "gpt-4o", Stream: true, raw: []byte(`{"messages":[{"role":"user","content":"abcdefgh"}]}`)}
+
+	for _, tt := range []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"strip", `{"base_url":"http://upstream/v1","strip_stream_options":true}`, `"total_tokens"=7`},
+		{"keep", `{"base_url":"http://upstream/v1"}`, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stubConfig(t, nil)
+			statements := captureSQL(t)
+			provider := models.Provider{Type: consts.StyleOpenAI, Config: tt.config}
+			RecordLog(context.Background(), time.Now(), io.NopCloser(strings.NewReader(stream)), ProcesserOpenAI, 1, before, false, provider, 0)
+
+			var update string
+			for _, stmt := range statements() {
+				if strings.HasPrefix(stmt, `UPDATE "chat_logs"`) {
+					update = stmt
+				}
+			}
+			if update == "" {
+				t.Fatalf("chat log not updated: %v", statements())
+			}
+			if tt.want != "" && !strings.Contains(update, tt.want) {
+				t.Fatalf("update = %s, want %s", update, tt.want)
+			}
+			if tt.want == "" && strings.Contains(update, "total_tokens") {
+				t.Fatalf("usage estimated for provider keeping stream_options: %s", update)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/racio/llmio/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// captureSQL 将 models.DB 替换为不连接数据库的 DryRun 会话，返回的函数获取已执行过的 SQL（参数已内联）
+func captureSQL(t *testing.T) func() []string {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1"), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu         sync.Mutex
+		statements []string
+	)
+	record := func(tx *gorm.DB) {
+		mu.Lock()
+		defer mu.Unlock()
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	}
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().After("gorm:create").Register("test:capture", record),
+		cb.Query().After("gorm:query").Register("test:capture", record),
+		cb.Update().After("gorm:update").Register("test:capture", record),
+		cb.Delete().After("gorm:delete").Register("test:capture", record),
+		cb.Row().After("gorm:row").Register("test:capture", record),
+		cb.Raw().After("gorm:raw").Register("test:capture", record),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	orig := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = orig })
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(statements)
+	}
+}
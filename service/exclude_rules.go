@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/racio/llmio/models"
+)
+
+// 排除规则可使用的请求属性
+const (
+	ExcludeAttrStream           = "stream"
+	ExcludeAttrToolCall         = "tool_call"
+	ExcludeAttrImage            = "image"
+	ExcludeAttrStructuredOutput = "structured_output"
+	ExcludeAttrInputBytes       = "input_bytes"  // 请求体字节数
+	ExcludeAttrInputTokens      = "input_tokens" // 按请求体文本粗略估算的 token 数
+)
+
+var (
+	excludeBoolAttrs   = []string{ExcludeAttrStream, ExcludeAttrToolCall, ExcludeAttrImage, ExcludeAttrStructuredOutput}
+	excludeNumberAttrs = []string{ExcludeAttrInputBytes, ExcludeAttrInputTokens}
+	excludeBoolOps     = []string{"eq", "ne"}
+	excludeNumberOps   = []string{"eq", "ne", "gt", "gte", "lt", "lte"}
+)
+
+// ExcludeRule 模型提供商关联的排除规则：请求属性 attr 与 value 按 op 比较成立时，本次请求不使用该关联
+// 布尔属性的 value 为 true/false，op 为 eq/ne；数值属性的 value 为数字，op 为 eq/ne/gt/gte/lt/lte
+type ExcludeRule struct {
+	Attr  string `json:"attr"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+}
+
+// requestAttrs 排除规则求值所用的请求属性，取自 Before
+type requestAttrs struct {
+	bools       map[string]bool
+	inputBytes  int64
+	raw         []byte
+	inputTokens int64 // 首次使用时估算，-1 表示尚未估算
+}
+
+func newRequestAttrs(before Before) *requestAttrs {
+	return &requestAttrs{
+		bools: map[string]bool{
+			ExcludeAttrStream:           before.Stream,
+			ExcludeAttrToolCall:         before.toolCall,
+			ExcludeAttrImage:            before.image,
+			ExcludeAttrStructuredOutput: before.structuredOutput,
+		},
+		inputBytes:  int64(len(before.raw)),
+		raw:         before.raw,
+		inputTokens: -1,
+	}
+}
+
+func (a *requestAttrs) number(attr string) float64 {
+	if attr == ExcludeAttrInputBytes {
+		return float64(a.inputBytes)
+	}
+	if a.inputTokens < 0 {
+		a.inputTokens = estimateTextTokens(string(a.raw))
+	}
+	return float64(a.inputTokens)
+}
+
+// validate 校验规则的属性、比较方式与取值类型
+func (r ExcludeRule) validate() error {
+	switch {
+	case slices.Contains(excludeBoolAttrs, r.Attr):
+		if !slices.Contains(excludeBoolOps, r.Op) {
+			return fmt.Errorf("attr %s: op must be one of %v", r.Attr, excludeBoolOps)
+		}
+		if _, ok := r.Value.(bool); !ok {
+			return fmt.Errorf("attr %s: value must be a boolean", r.Attr)
+		}
+	case slices.Contains(excludeNumberAttrs, r.Attr):
+		if !slices.Contains(excludeNumberOps, r.Op) {
+			return fmt.Errorf("attr %s: op must be one of %v", r.Attr, excludeNumberOps)
+		}
+		if _, ok := r.Value.(float64); !ok {
+			return fmt.Errorf("attr %s: value must be a number", r.Attr)
+		}
+	default:
+		return fmt.Errorf("unknown attr %q", r.Attr)
+	}
+	return nil
+}
+
+// matches 规则对该请求成立时返回 true，规则无效时视为不成立
+func (r ExcludeRule) matches(attrs *requestAttrs) bool {
+	if value, ok := r.Value.(bool); ok {
+		actual, known := attrs.bools[r.Attr]
+		if !known {
+			return false
+		}
+		return (r.Op == "eq") == (actual == value)
+	}
+	value, ok := r.Value.(float64)
+	if !ok || !slices.Contains(excludeNumberAttrs, r.Attr) {
+		return false
+	}
+	actual := attrs.number(r.Attr)
+	switch r.Op {
+	case "eq":
+		return actual == value
+	case "ne":
+		return actual != value
+	case "gt":
+		return actual > value
+	case "gte":
+		return actual >= value
+	case "lt":
+		return actual < value
+	case "lte":
+		return actual <= value
+	}
+	return false
+}
+
+// ParseExcludeRules 解析关联中保存的排除规则 (JSON)，空字符串表示没有规则
+func ParseExcludeRules(raw string) ([]ExcludeRule, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var rules []ExcludeRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// EncodeExcludeRules 校验并序列化排除规则，没有规则时返回空字符串
+func EncodeExcludeRules(rules []ExcludeRule) (string, error) {
+	if len(rules) == 0 {
+		return "", nil
+	}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return "", err
+		}
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// matchExcludeRules 返回第一条对该请求成立的规则
+func matchExcludeRules(rules []ExcludeRule, attrs *requestAttrs) (ExcludeRule, bool) {
+	for _, rule := range rules {
+		if rule.matches(attrs) {
+			return rule, true
+		}
+	}
+	return ExcludeRule{}, false
+}
+
+// excludeByRules 去掉排除规则对本次请求成立的关联，被排除的关联记录到时间线；规则解析失败时记录日志并忽略规则
+func excludeByRules(ctx context.Context, modelWithProviders []models.ModelWithProvider, before Before) []models.ModelWithProvider {
+	var attrs *requestAttrs
+	kept := make([]models.ModelWithProvider, 0, len(modelWithProviders))
+	for _, mp := range modelWithProviders {
+		rules, err := ParseExcludeRules(mp.ExcludeRules)
+		if err != nil {
+			slog.Warn("invalid exclude rules", "model_with_provider_id", mp.ID, "error", err)
+		}
+		if len(rules) == 0 {
+			kept = append(kept, mp)
+			continue
+		}
+		if attrs == nil {
+			attrs = newRequestAttrs(before)
+		}
+		if rule, ok := matchExcludeRules(rules, attrs); ok {
+			RecordTimeline(ctx, "provider_excluded", map[string]any{
+				"model_with_provider_id": mp.ID,
+				"attr":                   rule.Attr,
+				"op":                     rule.Op,
+				"value":                  rule.Value,
+			})
+			continue
+		}
+		kept = append(kept, mp)
+	}
+	return kept
+}
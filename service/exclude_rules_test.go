@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/racio/llmio/models"
+)
+
+func TestExcludeByRules(t *testing.T) {
+	small := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	large := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"` + strings.Repeat("hello world ", 4000) + `"}]}`)
+
+	tests := []struct {
+		name   string
+		rules  string
+		before Before
+		want   bool // 是否被排除
+	}{
+		{"stream eq true", `[{"attr":"stream","op":"eq","value":true}]`, Before{Stream: true, raw: small}, true},
+		{"stream eq true non-stream", `[{"attr":"stream","op":"eq","value":true}]`, Before{raw: small}, false},
+		{"stream ne true", `[{"attr":"stream","op":"ne","value":true}]`, Before{raw: small}, true},
+		{"tool call", `[{"attr":"tool_call","op":"eq","value":true}]`, Before{toolCall: true, raw: small}, true},
+		{"tool call absent", `[{"attr":"tool_call","op":"eq","value":true}]`, Before{raw: small}, false},
+		{"image", `[{"attr":"image","op":"eq","value":true}]`, Before{image: true, raw: small}, true},
+		{"image absent", `[{"attr":"image","op":"eq","value":true}]`, Before{raw: small}, false},
+		{"structured output", `[{"attr":"structured_output","op":"eq","value":true}]`, Before{structuredOutput: true, raw: small}, true},
+		{"structured output absent", `[{"attr":"structured_output","op":"eq","value":true}]`, Before{raw: small}, false},
+		{"input bytes over", `[{"attr":"input_bytes","op":"gt","value":1024}]`, Before{raw: large}, true},
+		{"input bytes under", `[{"attr":"input_bytes","op":"gt","value":1024}]`, Before{raw: small}, false},
+		{"input bytes lte", `[{"attr":"input_bytes","op":"lte","value":1024}]`, Before{raw: small}, true},
+		// 小上下文提供商：估算输入超过阈值时排除
+		{"input tokens over", `[{"attr":"input_tokens","op":"gte","value":4000}]`, Before{raw: large}, true},
+		{"input tokens under", `[{"attr":"input_tokens","op":"gte","value":4000}]`, Before{raw: small}, false},
+		{"any rule matches", `[{"attr":"image","op":"eq","value":true},{"attr":"stream","op":"eq","value":true}]`, Before{Stream: true, raw: small}, true},
+		{"no rules", ``, Before{Stream: true, toolCall: true, raw: large}, false},
+		// 无法解析的规则被忽略，关联照常参与
+		{"invalid rules ignored", `[{"attr":"stream","op":"gt","value":true}]`, Before{Stream: true, raw: small}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruled := models.ModelWithProvider{ProviderID: 1, ExcludeRules: tt.rules}
+			ruled.ID = 1
+			plain := models.ModelWithProvider{ProviderID: 2}
+			plain.ID = 2
+
+			kept := excludeByRules(context.Background(), []models.ModelWithProvider{ruled, plain}, tt.before)
+			ids := make([]uint, 0, len(kept))
+			for _, mp := range kept {
+				ids = append(ids, mp.ID)
+			}
+			want := []uint{1, 2}
+			if tt.want {
+				want = []uint{2}
+			}
+			if !slices.Equal(ids, want) {
+				t.Fatalf("kept = %v, want %v", ids, want)
+			}
+		})
+	}
+}
+
+func TestParseExcludeRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{"empty", ``, false},
+		{"bool rule", `[{"attr":"tool_call","op":"ne","value":false}]`, false},
+		{"number rule", `[{"attr":"input_tokens","op":"lt","value":100}]`, false},
+		{"unknown attr", `[{"attr":"beta","op":"eq","value":true}]`, true},
+		{"bool attr with number op", `[{"attr":"image","op":"gte","value":true}]`, true},
+		{"bool attr with number value", `[{"attr":"image","op":"eq","value":1}]`, true},
+		{"number attr with bool value", `[{"attr":"input_bytes","op":"gt","value":true}]`, true},
+		{"number attr with string value", `[{"attr":"input_bytes","op":"gt","value":"1024"}]`, true},
+		{"malformed json", `{"attr":"stream"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExcludeRules(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 编码后可原样解析
+	encoded, err := EncodeExcludeRules([]ExcludeRule{{Attr: ExcludeAttrInputTokens, Op: "gt", Value: float64(32000)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := ParseExcludeRules(encoded)
+	if err != nil || len(rules) != 1 || rules[0].Value != float64(32000) {
+		t.Fatalf("round trip = %+v, %v", rules, err)
+	}
+	if encoded, err := EncodeExcludeRules(nil); encoded != "" || err != nil {
+		t.Fatalf("empty rules = %q, %v", encoded, err)
+	}
+}
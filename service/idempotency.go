@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/racio/llmio/models"
+)
+
+const (
+	IdempotencyHeader         = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	MaxIdempotencyKeyLength   = 255
+	// MaxIdempotentBodySize 超过该大小的响应不缓存
+	MaxIdempotentBodySize = 4 << 20
+
+	defaultIdempotencyTTLSeconds = 600
+	idempotencyPollInterval      = 200 * time.Millisecond
+)
+
+// ErrIdempotencyKeyReused 同一个 Idempotency-Key 被用于请求体不同的请求
+var ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used with a different request body")
+
+// IdempotentResponse 按 Idempotency-Key 缓存的完整响应
+type IdempotentResponse struct {
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+	RequestHash string      `json:"request_hash,omitempty"` // 产生该响应的请求体摘要
+}
+
+type idempotencyEntry struct {
+	resp        *IdempotentResponse // nil 表示请求仍在处理中
+	requestHash string
+	expiry      time.Time
+}
+
+// IdempotencyRequestHash 计算请求体摘要，用于识别复用同一 key 的不同请求
+func IdempotencyRequestHash(body []byte) string {
+	return sha256Hex(body)
+}
+
+var idempotencyMemory sync.Map // key -> *idempotencyEntry
+
+// LoadIdempotencyTTL 读取去重配置，未开启时返回 0
+func LoadIdempotencyTTL(ctx context.Context) (time.Duration, error) {
+	var cfg models.IdempotencyConfig
+	ok, err := loadJSONConfig(ctx, models.KeyIdempotency, &cfg)
+	if err != nil || !ok || !cfg.Enabled {
+		return 0, err
+	}
+	ttl := cfg.TTLSeconds
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTLSeconds
+	}
+	return time.Duration(ttl) * time.Second, nil
+}
+
+func idempotencyKeys(authKeyID uint, key string) (string, string) {
+	base := fmt.Sprintf("idempotency:%d:%s", authKeyID, key)
+	return base + ":result", base + ":lock"
+}
+
+// BeginIdempotent 开始一个幂等请求：
+// - 已有完成结果：返回结果
+// - 无结果且成功占位：返回 acquired=true，调用方负责 Complete/Release
+// - 同 key 请求仍在处理中：返回 nil, false
+// - 同 key 已有请求体不同的结果或在途请求：返回 ErrIdempotencyKeyReused
+func BeginIdempotent(ctx context.Context, authKeyID uint, key string, requestHash string, ttl time.Duration) (*IdempotentResponse, bool, error) {
+	resultKey, lockKey := idempotencyKeys(authKeyID, key)
+
+	if rdb := GetRedisClient(); rdb != nil {
+		resp, err := getIdempotentRedis(ctx, rdb, resultKey)
+		if err != nil {
+			return nil, false, err
+		}
+		if resp != nil {
+			if resp.RequestHash != requestHash {
+				return nil, false, ErrIdempotencyKeyReused
+			}
+			return resp, false, nil
+		}
+		acquired, err := rdb.SetNX(ctx, lockKey, requestHash, ttl).Result()
+		if err != nil || acquired {
+			return nil, acquired, err
+		}
+		// 占位中的请求体摘要保存在锁的值中；锁恰好被释放时按在途处理，由等待逻辑决定结果
+		holder, err := rdb.Get(ctx, lockKey).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, false, err
+		}
+		if err == nil && holder != requestHash {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		return nil, false, nil
+	}
+
+	now := time.Now()
+	entry := &idempotencyEntry{requestHash: requestHash, expiry: now.Add(ttl)}
+	if v, loaded := idempotencyMemory.LoadOrStore(resultKey, entry); loaded {
+		existing := v.(*idempotencyEntry)
+		if now.Before(existing.expiry) {
+			if existing.requestHash != requestHash {
+				return nil, false, ErrIdempotencyKeyReused
+			}
+			return existing.resp, false, nil
+		}
+		// 已过期：替换为新的占位
+		if !idempotencyMemory.CompareAndSwap(resultKey, existing, entry) {
+			return nil, false, nil
+		}
+	}
+	expireIdempotentMemory(resultKey, entry, ttl)
+	return nil, true, nil
+}
+
+// WaitIdempotent 等待同 key 的在途请求完成；占位消失（请求失败）或超时返回 nil
+func WaitIdempotent(ctx context.Context, authKeyID uint, key string, timeout time.Duration) (*IdempotentResponse, error) {
+	resultKey, lockKey := idempotencyKeys(authKeyID, key)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(idempotencyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+		}
+
+		if rdb := GetRedisClient(); rdb != nil {
+			resp, err := getIdempotentRedis(ctx, rdb, resultKey)
+			if err != nil || resp != nil {
+				return resp, err
+			}
+			exists, err := rdb.Exists(ctx, lockKey).Result()
+			if err != nil {
+				return nil, err
+			}
+			if exists == 0 {
+				return nil, nil
+			}
+			continue
+		}
+
+		v, ok := idempotencyMemory.Load(resultKey)
+		if !ok {
+			return nil, nil
+		}
+		if resp := v.(*idempotencyEntry).resp; resp != nil {
+			return resp, nil
+		}
+	}
+}
+
+// CompleteIdempotent 保存完成的响应并释放占位
+func CompleteIdempotent(ctx context.Context, authKeyID uint, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	resultKey, lockKey := idempotencyKeys(authKeyID, key)
+
+	if rdb := GetRedisClient(); rdb != nil {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		if err := rdb.Set(ctx, resultKey, data, ttl).Err(); err != nil {
+			return err
+		}
+		return rdb.Del(ctx, lockKey).Err()
+	}
+
+	entry := &idempotencyEntry{resp: resp, requestHash: resp.RequestHash, expiry: time.Now().Add(ttl)}
+	idempotencyMemory.Store(resultKey, entry)
+	expireIdempotentMemory(resultKey, entry, ttl)
+	return nil
+}
+
+// ReleaseIdempotent 请求失败时释放占位，允许客户端重试
+func ReleaseIdempotent(ctx context.Context, authKeyID uint, key string) error {
+	resultKey, lockKey := idempotencyKeys(authKeyID, key)
+
+	if rdb := GetRedisClient(); rdb != nil {
+		return rdb.Del(ctx, lockKey).Err()
+	}
+
+	if v, ok := idempotencyMemory.Load(resultKey); ok && v.(*idempotencyEntry).resp == nil {
+		idempotencyMemory.CompareAndDelete(resultKey, v)
+	}
+	return nil
+}
+
+// expireIdempotentMemory 到期后清理内存条目（仅当条目未被替换时）
+func expireIdempotentMemory(key string, entry *idempotencyEntry, ttl time.Duration) {
+	time.AfterFunc(ttl, func() {
+		idempotencyMemory.CompareAndDelete(key, entry)
+	})
+}
+
+func getIdempotentRedis(ctx context.Context, rdb *redis.Client, resultKey string) (*IdempotentResponse, error) {
+	data, err := rdb.Get(ctx, resultKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp IdempotentResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
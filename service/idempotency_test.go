@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBeginIdempotentConcurrentDuplicates(t *testing.T) {
+	const n = 50
+	ctx := context.Background()
+	hash := IdempotencyRequestHash([]byte(`{"model":"gpt-4o"}`))
+
+	var (
+		wg       sync.WaitGroup
+		acquired atomic.Int64
+		pending  atomic.Int64
+		start    = make(chan struct{})
+	)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			resp, ok, err := BeginIdempotent(ctx, 101, "dup", hash, time.Minute)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			switch {
+			case ok:
+				acquired.Add(1)
+			case resp == nil:
+				pending.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if acquired.Load() != 1 || pending.Load() != n-1 {
+		t.Fatalf("acquired = %d, pending = %d; want 1, %d", acquired.Load(), pending.Load(), n-1)
+	}
+
+	// 在途请求完成后，等待中的重复请求拿到同一个响应
+	want := &IdempotentResponse{Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{"id":"x"}`), RequestHash: hash}
+	done := make(chan *IdempotentResponse)
+	go func() {
+		resp, err := WaitIdempotent(ctx, 101, "dup", 5*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- resp
+	}()
+	if err := CompleteIdempotent(ctx, 101, "dup", want, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-done; got == nil || string(got.Body) != string(want.Body) {
+		t.Fatalf("waited response = %+v, want %s", got, want.Body)
+	}
+
+	resp, ok, err := BeginIdempotent(ctx, 101, "dup", hash, time.Minute)
+	if err != nil || ok || resp == nil || string(resp.Body) != string(want.Body) {
+		t.Fatalf("replay = %+v, %v, %v", resp, ok, err)
+	}
+}
+
+func TestBeginIdempotentRejectsDifferentBody(t *testing.T) {
+	ctx := context.Background()
+	first := IdempotencyRequestHash([]byte(`{"messages":[{"role":"user","content":"a"}]}`))
+	second := IdempotencyRequestHash([]byte(`{"messages":[{"role":"user","content":"b"}]}`))
+
+	if _, ok, err := BeginIdempotent(ctx, 102, "reuse", first, time.Minute); err != nil || !ok {
+		t.Fatalf("first begin = %v, %v", ok, err)
+	}
+	// 在途请求的请求体不同
+	if _, _, err := BeginIdempotent(ctx, 102, "reuse", second, time.Minute); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("in-flight mismatch err = %v, want ErrIdempotencyKeyReused", err)
+	}
+
+	if err := CompleteIdempotent(ctx, 102, "reuse", &IdempotentResponse{Body: []byte("ok"), RequestHash: first}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// 已完成结果的请求体不同
+	if _, _, err := BeginIdempotent(ctx, 102, "reuse", second, time.Minute); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("completed mismatch err = %v, want ErrIdempotencyKeyReused", err)
+	}
+	// 不同的 AuthKey 使用同名 key 互不影响
+	if _, ok, err := BeginIdempotent(ctx, 103, "reuse", second, time.Minute); err != nil || !ok {
+		t.Fatalf("other auth key begin = %v, %v", ok, err)
+	}
+}
+
+func TestReleaseIdempotentAllowsRetry(t *testing.T) {
+	ctx := context.Background()
+	hash := IdempotencyRequestHash([]byte(`{}`))
+	if _, ok, _ := BeginIdempotent(ctx, 104, "retry", hash, time.Minute); !ok {
+		t.Fatal("expected first begin to acquire")
+	}
+	if err := ReleaseIdempotent(ctx, 104, "retry"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := BeginIdempotent(ctx, 104, "retry", hash, time.Minute); err != nil || !ok {
+		t.Fatalf("begin after release = %v, %v; want acquired", ok, err)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStreamReadTimeout 上游在流式响应过程中长时间没有任何数据
+var ErrStreamReadTimeout = errors.New("upstream stream read timeout")
+
+// streamReadTimeout 流式响应单次读取的最长等待时间，0 表示不限制
+// 通过环境变量 STREAM_READ_TIMEOUT_SECONDS 配置
+var streamReadTimeout = func() time.Duration {
+	v := os.Getenv("STREAM_READ_TIMEOUT_SECONDS")
+	if v == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds < 0 {
+		slog.Warn("Invalid STREAM_READ_TIMEOUT_SECONDS, read deadline disabled", "value", v)
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}()
+
+// idleTimeoutReader 为每次 Read 设置截止时间：超时后关闭底层 body，使阻塞中的 Read 立即返回
+// 截止时间由互斥锁保护，定时器触发时重新核对：读取已结束或截止时间已被后续 Read 推后的触发直接忽略，
+// 只有定时器在读取中真正到期时才报告超时，客户端断开等其它原因导致的读取失败原样返回
+type idleTimeoutReader struct {
+	rc      io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+
+	mu       sync.Mutex
+	reading  bool
+	deadline time.Time
+	timedOut bool
+}
+
+func newIdleTimeoutReader(rc io.ReadCloser, timeout time.Duration) io.ReadCloser {
+	if timeout <= 0 {
+		return rc
+	}
+	r := &idleTimeoutReader{rc: rc, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, r.expire)
+	r.timer.Stop()
+	return r
+}
+
+// expire 定时器回调：仅在读取中且已过截止时间时关闭底层 body
+func (r *idleTimeoutReader) expire() {
+	r.mu.Lock()
+	if !r.reading || time.Now().Before(r.deadline) {
+		r.mu.Unlock()
+		return
+	}
+	r.timedOut = true
+	r.mu.Unlock()
+	_ = r.rc.Close()
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	if r.timedOut {
+		r.mu.Unlock()
+		return 0, ErrStreamReadTimeout
+	}
+	r.reading = true
+	r.deadline = time.Now().Add(r.timeout)
+	r.timer.Reset(r.timeout)
+	r.mu.Unlock()
+
+	n, err := r.rc.Read(p)
+
+	r.mu.Lock()
+	r.reading = false
+	timedOut := r.timedOut
+	r.mu.Unlock()
+	r.timer.Stop()
+	if err != nil && timedOut {
+		return n, ErrStreamReadTimeout
+	}
+	return n, err
+}
+
+func (r *idleTimeoutReader) Close() error {
+	r.timer.Stop()
+	return r.rc.Close()
+}
+
+// ErrStreamDeadline 流式响应总时长超过模型配置的上限
+var ErrStreamDeadline = errors.New("stream exceeded max duration")
+
+// deadlineReader 为整个流式响应设置绝对截止时间：到期后关闭底层 body，使阻塞中的 Read 立即返回
+type deadlineReader struct {
+	rc      io.ReadCloser
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+func newDeadlineReader(rc io.ReadCloser, max time.Duration) io.ReadCloser {
+	if max <= 0 {
+		return rc
+	}
+	r := &deadlineReader{rc: rc}
+	r.timer = time.AfterFunc(max, func() {
+		r.expired.Store(true)
+		_ = rc.Close()
+	})
+	return r
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	if err != nil && r.expired.Load() {
+		return n, ErrStreamDeadline
+	}
+	return n, err
+}
+
+func (r *deadlineReader) Close() error {
+	r.timer.Stop()
+	return r.rc.Close()
+}
+
+// partialStreamReader 将流式截止错误视为正常结束，使处理器仍能统计截止前已收到内容的用量
+type partialStreamReader struct {
+	r       io.Reader
+	expired bool
+}
+
+func (r *partialStreamReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if errors.Is(err, ErrStreamDeadline) {
+		r.expired = true
+		return n, io.EOF
+	}
+	return n, err
+}
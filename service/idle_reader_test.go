@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeoutReaderHangingUpstream(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	r := newIdleTimeoutReader(pr, 50*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 16))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrStreamReadTimeout) {
+			t.Fatalf("err = %v, want ErrStreamReadTimeout", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Read still blocked after idle timeout")
+	}
+	// 超时后后续读取直接返回超时
+	if _, err := r.Read(make([]byte, 16)); !errors.Is(err, ErrStreamReadTimeout) {
+		t.Fatalf("subsequent err = %v, want ErrStreamReadTimeout", err)
+	}
+}
+
+func TestIdleTimeoutReaderSlowButProgressing(t *testing.T) {
+	pr, pw := io.Pipe()
+	r := newIdleTimeoutReader(pr, 80*time.Millisecond)
+	go func() {
+		// 每块间隔小于超时时间，总时长远超超时时间
+		for range 10 {
+			time.Sleep(30 * time.Millisecond)
+			if _, err := pw.Write([]byte("data: x\n\n")); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+	if len(data) != 10*len("data: x\n\n") {
+		t.Fatalf("read %d bytes", len(data))
+	}
+}
+
+func TestIdleTimeoutReaderClientClose(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	r := newIdleTimeoutReader(pr, time.Second)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 16))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// 客户端断开时 body 被关闭，不能报告为上游超时
+	r.Close()
+	select {
+	case err := <-done:
+		if err == nil || errors.Is(err, ErrStreamReadTimeout) {
+			t.Fatalf("err = %v, want a non-timeout error", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Read still blocked after Close")
+	}
+}
+
+func TestIdleTimeoutReaderDisabled(t *testing.T) {
+	pr, _ := io.Pipe()
+	if r := newIdleTimeoutReader(pr, 0); r != io.ReadCloser(pr) {
+		t.Fatal("zero timeout should return the original reader")
+	}
+}
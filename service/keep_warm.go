@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/providers"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultKeepWarmIntervalSeconds = 60
+	keepWarmRequestTimeout         = 10 * time.Second
+)
+
+// keepWarmInterval 保活间隔，环境变量 PROVIDER_KEEP_WARM_INTERVAL_SECONDS，<=0 表示关闭
+func keepWarmInterval() time.Duration {
+	seconds := defaultKeepWarmIntervalSeconds
+	if v := os.Getenv("PROVIDER_KEEP_WARM_INTERVAL_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			seconds = n
+		}
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// StartProviderKeepWarm 定期向标记了 keep_warm 的提供商发起轻量请求，保持转发所用连接池中的连接处于活跃状态
+// 失败只记录日志，不影响健康状态
+func StartProviderKeepWarm(ctx context.Context) {
+	interval := keepWarmInterval()
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := warmProviders(ctx); err != nil {
+				slog.Error("provider keep-warm error", "error", err)
+			}
+		}
+	}()
+}
+
+func warmProviders(ctx context.Context) error {
+	list, err := gorm.G[models.Provider](models.DB).Where("keep_warm = ?", 1).Find(ctx)
+	if err != nil {
+		return err
+	}
+	warmProviderList(ctx, list)
+	return nil
+}
+
+// warmProviderList 逐个预热提供商，返回成功预热的提供商数
+func warmProviderList(ctx context.Context, list []models.Provider) int {
+	warmed := 0
+	// 单个提供商配置或查询失败只跳过该提供商，不影响其它提供商保活
+	for _, provider := range list {
+		origin, err := providerOrigin(provider.Type, provider.Config)
+		if err != nil {
+			slog.Warn("provider keep-warm skipped", "provider", provider.Name, "error", err)
+			continue
+		}
+		timeouts, err := providerClientTimeouts(ctx, provider.ID)
+		if err != nil {
+			slog.Warn("provider keep-warm skipped", "provider", provider.Name, "error", err)
+			continue
+		}
+		// 转发按模型超时使用不同的缓存 client（各自独立的连接池），逐个预热
+		ok := true
+		for _, timeout := range timeouts {
+			if err := pingOrigin(ctx, providers.GetClient(timeout), origin); err != nil {
+				ok = false
+				slog.Warn("provider keep-warm failed", "provider", provider.Name, "error", err)
+			}
+		}
+		if ok && len(timeouts) > 0 {
+			warmed++
+		}
+	}
+	return warmed
+}
+
+// providerOrigin 按提供商实际转发使用的地址提取 scheme://host/：
+// 展开 ${ENV} 引用，未填写 base_url 时使用提供商的默认地址（如 Bedrock 按 region 生成、Azure 使用 endpoint）
+func providerOrigin(providerType string, config string) (string, error) {
+	provider, err := providers.New(providerType, config)
+	if err != nil {
+		return "", err
+	}
+	origin, ok := providers.Origin(provider)
+	if !ok {
+		return "", errors.New("provider has no request base url")
+	}
+	return origin, nil
+}
+
+// providerClientTimeouts 返回该提供商关联模型在转发时会使用的响应头超时（非流式与流式）
+var providerClientTimeouts = func(ctx context.Context, providerID uint) ([]time.Duration, error) {
+	var timeOuts []int
+	if err := models.DB.WithContext(ctx).
+		Model(&models.Model{}).
+		Distinct("models.time_out").
+		Joins("JOIN model_with_providers ON model_with_providers.model_id = models.id AND model_with_providers.deleted_at IS NULL").
+		Where("model_with_providers.provider_id = ?", providerID).
+		Pluck("models.time_out", &timeOuts).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[time.Duration]struct{})
+	result := make([]time.Duration, 0, len(timeOuts)*2)
+	for _, t := range timeOuts {
+		full := time.Second * time.Duration(t)
+		for _, d := range []time.Duration{full, full / 3} {
+			if _, ok := seen[d]; ok {
+				continue
+			}
+			seen[d] = struct{}{}
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+func pingOrigin(ctx context.Context, client *http.Client, origin string) error {
+	ctx, cancel := context.WithTimeout(ctx, keepWarmRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, origin, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+)
+
+func TestProviderOrigin(t *testing.T) {
+	t.Setenv("KEEP_WARM_TEST_BASE", "https://gw.example.com/openai/v1")
+	tests := []struct {
+		name    string
+		typ     string
+		config  string
+		want    string
+		wantErr bool
+	}{
+		{"openai", consts.StyleOpenAI, `{"base_url":"https://api.openai.com/v1","api_key":"k"}`, "https://api.openai.com/", false},
+		{"env reference", consts.StyleOpenAI, `{"base_url":"${KEEP_WARM_TEST_BASE}","api_key":"k"}`, "https://gw.example.com/", false},
+		{"unset env", consts.StyleOpenAI, `{"base_url":"${KEEP_WARM_TEST_UNSET}"}`, "", true},
+		{"anthropic", consts.StyleAnthropic, `{"base_url":"https://api.anthropic.com"}`, "https://api.anthropic.com/", false},
+		{"azure endpoint", consts.StyleAzure, `{"endpoint":"https://res.openai.azure.com/","api_key":"k"}`, "https://res.openai.azure.com/", false},
+		{"bedrock region", consts.StyleBedrock, `{"region":"us-east-1"}`, "https://bedrock-runtime.us-east-1.amazonaws.com/", false},
+		{"bedrock endpoint", consts.StyleBedrock, `{"region":"us-east-1","endpoint":"https://vpce-1.bedrock-runtime.us-east-1.vpce.amazonaws.com"}`, "https://vpce-1.bedrock-runtime.us-east-1.vpce.amazonaws.com/", false},
+		{"bedrock without region", consts.StyleBedrock, `{}`, "", true},
+		{"mistral default", consts.StyleMistral, `{"api_key":"k"}`, "https://api.mistral.ai/", false},
+		{"missing base url", consts.StyleOpenAI, `{"api_key":"k"}`, "", true},
+		{"unknown type", "unknown", `{}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := providerOrigin(tt.typ, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("origin = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarmProviderListSkipsFailures(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	orig := providerClientTimeouts
+	providerClientTimeouts = func(_ context.Context, providerID uint) ([]time.Duration, error) {
+		if providerID == 2 {
+			return nil, errors.New("db error")
+		}
+		return []time.Duration{30 * time.Second}, nil
+	}
+	t.Cleanup(func() { providerClientTimeouts = orig })
+
+	list := []models.Provider{
+		{Name: "broken-config", Type: consts.StyleOpenAI, Config: `{"base_url":"${KEEP_WARM_TEST_UNSET}"}`},
+		{Name: "timeouts-error", Type: consts.StyleOpenAI, Config: `{"base_url":"` + srv.URL + `/v1"}`},
+		{Name: "ok", Type: consts.StyleOpenAI, Config: `{"base_url":"` + srv.URL + `/v1"}`},
+	}
+	list[1].ID = 2
+	list[2].ID = 3
+
+	// 前两个提供商失败不影响最后一个提供商预热
+	if warmed := warmProviderList(context.Background(), list); warmed != 1 {
+		t.Fatalf("warmed = %d, want 1", warmed)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("origin hits = %d, want 1", hits.Load())
+	}
+}
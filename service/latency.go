@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/racio/llmio/models"
+)
+
+// latencyWindow latency 策略统计平均耗时的时间窗口
+const latencyWindow = 15 * time.Minute
+
+// loadLatencyMeta 为 latency 策略加载各关联近期成功请求的平均 proxy_time_ms，每个请求只查询一次
+// 加载失败时仅记录日志，策略退化为按权重排序
+func loadLatencyMeta(ctx context.Context, modelName string, providersWithMeta *ProvidersWithMeta) {
+	latencies := make(map[uint]float64)
+	defer func() { providersWithMeta.Latencies = latencies }()
+
+	type row struct {
+		ProviderName  string
+		ProviderModel string
+		AvgMs         float64
+	}
+	var rows []row
+	if err := models.Reader().WithContext(ctx).
+		Model(&models.ChatLog{}).
+		Select("provider_name, provider_model, AVG(proxy_time_ms) AS avg_ms").
+		Where("name = ?", modelName).
+		Where("status = ?", "success").
+		Where("created_at >= ?", time.Now().Add(-latencyWindow)).
+		Group("provider_name, provider_model").
+		Scan(&rows).Error; err != nil {
+		slog.Warn("load provider latencies error", "model", modelName, "error", err)
+		return
+	}
+	type statKey struct{ provider, model string }
+	avg := make(map[statKey]float64, len(rows))
+	for _, r := range rows {
+		avg[statKey{r.ProviderName, r.ProviderModel}] = r.AvgMs
+	}
+	for id := range providersWithMeta.WeightItems {
+		mp := providersWithMeta.ModelWithProviderMap[id]
+		provider, ok := providersWithMeta.ProviderMap[mp.ProviderID]
+		if !ok {
+			continue
+		}
+		if ms, ok := avg[statKey{provider.Name, mp.ProviderModel}]; ok {
+			latencies[id] = ms
+		}
+	}
+}
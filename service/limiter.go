@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
@@ -26,20 +28,54 @@ func GetRedisClient() *redis.Client {
 	return globalLimiterManager.GetRedisClient()
 }
 
+// StartRedisMonitor 后台探测 Redis 可用性，不可用时限流切换到进程内存计数，恢复后切回 Redis
+func StartRedisMonitor(ctx context.Context) {
+	if globalLimiterManager == nil {
+		return
+	}
+	globalLimiterManager.StartRedisMonitor(ctx, limiter.RedisProbeInterval)
+}
+
+// GetLimiterBackend 返回限流计数后端：redis / memory / degraded
+func GetLimiterBackend() string {
+	if globalLimiterManager == nil {
+		return limiter.BackendMemory
+	}
+	return globalLimiterManager.Backend()
+}
+
 // CheckProviderLimits 检查提供商限制
-func CheckProviderLimits(ctx context.Context, c *gin.Context, providerID uint, rpmLimit, ipLockMinutes int, modelWithProviderID uint, tokenID uint) (bool, string, error) {
+func CheckProviderLimits(ctx context.Context, c *gin.Context, providerID uint, rpmLimit, tpmLimit, ipLockMinutes int, modelWithProviderID uint, modelRpmLimit int, tokenID uint, tokenLockTTL time.Duration) (bool, string, error) {
 	if globalLimiterManager == nil {
 		return true, "", nil
 	}
-	return globalLimiterManager.CheckProviderLimits(ctx, c, providerID, rpmLimit, ipLockMinutes, modelWithProviderID, tokenID)
+	return globalLimiterManager.CheckProviderLimits(ctx, c, providerID, rpmLimit, tpmLimit, ipLockMinutes, modelWithProviderID, modelRpmLimit, tokenID, tokenLockTTL)
+}
+
+// RecordProviderTokens 记录提供商响应消耗的 token，用于 TPM 限制
+func RecordProviderTokens(ctx context.Context, providerID uint, tpmLimit int, tokens int64) {
+	if globalLimiterManager == nil {
+		return
+	}
+	if err := globalLimiterManager.RecordProviderTokens(ctx, providerID, tpmLimit, tokens); err != nil {
+		slog.Warn("Failed to record provider tokens", "provider_id", providerID, "error", err)
+	}
+}
+
+// GetCurrentTPM 获取当前TPM用量
+func GetCurrentTPM(ctx context.Context, providerID uint) (int64, error) {
+	if globalLimiterManager == nil {
+		return 0, nil
+	}
+	return globalLimiterManager.GetCurrentTPM(ctx, providerID)
 }
 
 // RecordProviderAccess 记录提供商访问
-func RecordProviderAccess(ctx context.Context, c *gin.Context, providerID uint, rpmLimit, ipLockMinutes int) error {
+func RecordProviderAccess(ctx context.Context, c *gin.Context, providerID uint, ipLockMinutes int) error {
 	if globalLimiterManager == nil {
 		return nil
 	}
-	return globalLimiterManager.RecordProviderAccess(ctx, c, providerID, rpmLimit, ipLockMinutes)
+	return globalLimiterManager.RecordProviderAccess(ctx, c, providerID, ipLockMinutes)
 }
 
 // GetCurrentRPMCount 获取当前RPM计数
@@ -50,6 +86,86 @@ func GetCurrentRPMCount(ctx context.Context, providerID uint) (int, error) {
 	return globalLimiterManager.GetCurrentRPMCount(ctx, providerID)
 }
 
+// GetCurrentModelProviderRPMCount 获取模型-提供商关联当前RPM计数
+func GetCurrentModelProviderRPMCount(ctx context.Context, modelWithProviderID uint) (int, error) {
+	if globalLimiterManager == nil {
+		return 0, nil
+	}
+	return globalLimiterManager.GetCurrentModelProviderRPMCount(ctx, modelWithProviderID)
+}
+
+// AllowKeyRequest 检查 AuthKey 的 RPM 限制，未超出时计入本次请求
+func AllowKeyRequest(ctx context.Context, authKeyID uint, limit int) (bool, error) {
+	if globalLimiterManager == nil {
+		return true, nil
+	}
+	return globalLimiterManager.AllowKeyRequest(ctx, authKeyID, limit)
+}
+
+// GetKeyRPMCount 获取 AuthKey 当前 RPM 计数
+func GetKeyRPMCount(ctx context.Context, authKeyID uint) (int, error) {
+	if globalLimiterManager == nil {
+		return 0, nil
+	}
+	return globalLimiterManager.GetKeyRPMCount(ctx, authKeyID)
+}
+
+// AcquireKeyConcurrency 占用 AuthKey 的并发名额，返回的 release 在请求结束时调用
+func AcquireKeyConcurrency(ctx context.Context, authKeyID uint, limit int) (bool, func(), error) {
+	noop := func() {}
+	if globalLimiterManager == nil || limit <= 0 || authKeyID == 0 {
+		return true, noop, nil
+	}
+	ok, err := globalLimiterManager.AcquireKeyConcurrency(ctx, authKeyID, limit)
+	if err != nil || !ok {
+		return ok, noop, err
+	}
+	release := func() {
+		// 客户端断开时请求 ctx 已取消，释放必须脱离该 ctx
+		if err := globalLimiterManager.ReleaseKeyConcurrency(context.WithoutCancel(ctx), authKeyID); err != nil {
+			slog.Warn("Failed to release key concurrency", "auth_key_id", authKeyID, "error", err)
+		}
+	}
+	return true, release, nil
+}
+
+// GetKeyConcurrency 获取 AuthKey 当前在途请求数
+func GetKeyConcurrency(ctx context.Context, authKeyID uint) (int, error) {
+	if globalLimiterManager == nil {
+		return 0, nil
+	}
+	return globalLimiterManager.GetKeyConcurrency(ctx, authKeyID)
+}
+
+// AcquireProviderConcurrency 占用提供商的并发名额，返回的 release 在上游响应读取结束时调用
+func AcquireProviderConcurrency(ctx context.Context, providerID uint, limit int) (bool, func(), error) {
+	noop := func() {}
+	if globalLimiterManager == nil || limit <= 0 {
+		return true, noop, nil
+	}
+	ok, err := globalLimiterManager.AcquireProviderConcurrency(ctx, providerID, limit)
+	if err != nil || !ok {
+		return ok, noop, err
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			if err := globalLimiterManager.ReleaseProviderConcurrency(context.WithoutCancel(ctx), providerID); err != nil {
+				slog.Warn("Failed to release provider concurrency", "provider_id", providerID, "error", err)
+			}
+		})
+	}
+	return true, release, nil
+}
+
+// GetProviderConcurrency 获取提供商当前在途请求数
+func GetProviderConcurrency(ctx context.Context, providerID uint) (int, error) {
+	if globalLimiterManager == nil {
+		return 0, nil
+	}
+	return globalLimiterManager.GetProviderConcurrency(ctx, providerID)
+}
+
 // GetRPMStats 获取RPM统计信息
 func GetRPMStats(ctx context.Context) map[string]interface{} {
 	if globalLimiterManager == nil {
@@ -61,6 +177,14 @@ func GetRPMStats(ctx context.Context) map[string]interface{} {
 	return globalLimiterManager.GetRPMStats(ctx)
 }
 
+// GetLimiterRejections 获取各提供商按原因统计的限流拒绝次数
+func GetLimiterRejections(window time.Duration) []limiter.RejectionCount {
+	if globalLimiterManager == nil {
+		return []limiter.RejectionCount{}
+	}
+	return globalLimiterManager.GetRejections(window)
+}
+
 // GetIPLockStatus 获取IP锁定状态
 func GetIPLockStatus(ctx context.Context, providerID uint) (*limiter.IPLockRecord, error) {
 	if globalLimiterManager == nil {
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/racio/llmio/limiter"
+)
+
+func TestCheckProviderLimitsTokenContention(t *testing.T) {
+	orig := globalLimiterManager
+	globalLimiterManager = limiter.NewManager(nil)
+	t.Cleanup(func() { globalLimiterManager = orig })
+
+	const (
+		attempts           = 50
+		providerA, mwpA    = 1, 11
+		providerB, mwpB    = 2, 12
+		tokenOne, tokenTwo = 101, 102
+	)
+	ctx := context.Background()
+
+	// 两个 token 并发争用同一关联：只有先拿到锁的 token 能使用，另一个全部被拒
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		wins = make(map[uint]int)
+	)
+	start := make(chan struct{})
+	for i := range 2 * attempts {
+		token := uint(tokenOne)
+		if i%2 == 1 {
+			token = tokenTwo
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			ok, reason, err := CheckProviderLimits(ctx, nil, providerA, 0, 0, 0, mwpA, 0, token, time.Minute)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !ok && reason != "token_access_denied" {
+				t.Errorf("reason = %q, want token_access_denied", reason)
+			}
+			if ok {
+				mu.Lock()
+				wins[token]++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if len(wins) != 1 {
+		t.Fatalf("tokens holding the association = %v, want exactly one", wins)
+	}
+	var holder, other uint = tokenOne, tokenTwo
+	if wins[tokenTwo] > 0 {
+		holder, other = tokenTwo, tokenOne
+	}
+	if wins[holder] != attempts {
+		t.Fatalf("holder allowed %d times, want %d", wins[holder], attempts)
+	}
+
+	// 被拒的 token 切换到其它关联可以正常使用，持有者仍粘在原关联上
+	if ok, reason, _ := CheckProviderLimits(ctx, nil, providerB, 0, 0, 0, mwpB, 0, other, time.Minute); !ok {
+		t.Fatalf("other token rejected on fallback association: %s", reason)
+	}
+	if ok, _, _ := CheckProviderLimits(ctx, nil, providerA, 0, 0, 0, mwpA, 0, holder, time.Minute); !ok {
+		t.Fatal("holder rejected on its association")
+	}
+	if ok, _, _ := CheckProviderLimits(ctx, nil, providerB, 0, 0, 0, mwpB, 0, holder, time.Minute); ok {
+		t.Fatal("holder allowed on association locked by the other token")
+	}
+}
+
+func TestAcquireKeyConcurrencyReleaseAfterDisconnect(t *testing.T) {
+	orig := globalLimiterManager
+	globalLimiterManager = limiter.NewManager(nil)
+	t.Cleanup(func() { globalLimiterManager = orig })
+
+	const keyID = 7
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ok, release, err := AcquireKeyConcurrency(ctx, keyID, 1)
+	if err != nil || !ok {
+		t.Fatalf("acquire = %v, %v", ok, err)
+	}
+	if n, _ := GetKeyConcurrency(context.Background(), keyID); n != 1 {
+		t.Fatalf("current = %d, want 1", n)
+	}
+	if ok, _, _ := AcquireKeyConcurrency(context.Background(), keyID, 1); ok {
+		t.Fatal("second acquire should be rejected")
+	}
+
+	// 客户端断开：请求 ctx 已取消，释放仍需生效
+	cancel()
+	release()
+	if n, _ := GetKeyConcurrency(context.Background(), keyID); n != 0 {
+		t.Fatalf("current after release = %d, want 0", n)
+	}
+
+	// 未设置上限时不占用名额
+	ok, release, err = AcquireKeyConcurrency(context.Background(), keyID, 0)
+	if err != nil || !ok {
+		t.Fatalf("unlimited acquire = %v, %v", ok, err)
+	}
+	release()
+	if n, _ := GetKeyConcurrency(context.Background(), keyID); n != 0 {
+		t.Fatalf("unlimited acquire counted: %d", n)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/racio/llmio/models"
+)
+
+// safeBodyTextForLog 输出格式中的标记
+const (
+	logBodyBase64Prefix    = "base64"
+	logBodyGzipLabel       = " (gzip 解压后)"
+	logBodyTruncatedFormat = "...(已截断，总计 %d 字节)"
+)
+
+var logBodyTruncatedPattern = regexp.MustCompile(`\.\.\.\(已截断，总计 (\d+) 字节\)$`)
+
+// DecodeLoggedBody 还原 safeBodyTextForLog 以 base64 保存的内容：gzip 数据解压，解码后为 UTF-8 文本时返回文本，
+// 否则返回二进制内容说明；不是 base64 格式的内容原样返回。截断标记保留在结果末尾
+func DecodeLoggedBody(text string) string {
+	rest, ok := strings.CutPrefix(text, logBodyBase64Prefix)
+	if !ok {
+		return text
+	}
+	label, payload, ok := strings.Cut(rest, ":")
+	if !ok || (label != "" && label != logBodyGzipLabel) {
+		return text
+	}
+	truncated := ""
+	if loc := logBodyTruncatedPattern.FindStringIndex(payload); loc != nil {
+		truncated = payload[loc[0]:]
+		payload = payload[:loc[0]]
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return text
+	}
+	// 未能在记录时解压的 gzip 数据（如截断后不完整）尽量解压已有部分
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		if zr, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+			if inflated, _ := io.ReadAll(zr); len(inflated) > 0 {
+				data = inflated
+				label = logBodyGzipLabel
+			}
+			_ = zr.Close()
+		}
+	}
+	if !utf8.Valid(data) {
+		return fmt.Sprintf("(二进制内容，%d 字节)%s%s", len(data), label, truncated)
+	}
+	return string(data) + label + truncated
+}
+
+// DecodeChatIO 对 IO 记录的各内容字段执行 DecodeLoggedBody
+func DecodeChatIO(chatIO *models.ChatIO) {
+	chatIO.Input = DecodeLoggedBody(chatIO.Input)
+	chatIO.OutputString = DecodeLoggedBody(chatIO.OutputString)
+	var chunks []string
+	if chatIO.OutputStringArray == "" || json.Unmarshal([]byte(chatIO.OutputStringArray), &chunks) != nil {
+		return
+	}
+	for i, chunk := range chunks {
+		chunks[i] = DecodeLoggedBody(chunk)
+	}
+	if data, err := json.Marshal(chunks); err == nil {
+		chatIO.OutputStringArray = string(data)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeLoggedBodyRoundTrip(t *testing.T) {
+	binary := []byte{0xff, 0xfe, 0x00, 0x01}
+	longText := strings.Repeat("a", 5000)
+	// 截断位置落在多字节字符中间，截断后不是合法 UTF-8
+	splitRune := append(bytes.Repeat([]byte("a"), 4095), []byte("中文")...)
+
+	tests := []struct {
+		name       string
+		header     http.Header
+		body       []byte
+		wantPrefix string
+		want       string
+	}{
+		{"plain", http.Header{}, []byte(`{"error":"bad"}`), "", `{"error":"bad"}`},
+		{"gzip text", http.Header{"Content-Encoding": {"gzip"}}, gzipBytes(t, []byte("hello")), "", "hello" + logBodyGzipLabel},
+		{"truncated text", http.Header{}, []byte(longText), "", longText[:4096] + "...(已截断，总计 5000 字节)"},
+		{"binary", http.Header{}, binary, "base64:", "(二进制内容，4 字节)"},
+		{"gzip binary", http.Header{"Content-Encoding": {"gzip"}}, gzipBytes(t, binary), "base64" + logBodyGzipLabel + ":", "(二进制内容，4 字节)" + logBodyGzipLabel},
+		{"truncated binary", http.Header{}, splitRune, "base64:", "(二进制内容，4096 字节)...(已截断，总计 4101 字节)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged := safeBodyTextForLog(&http.Response{Header: tt.header}, tt.body)
+			if !strings.HasPrefix(logged, tt.wantPrefix) {
+				t.Fatalf("logged %q, want prefix %q", logged, tt.wantPrefix)
+			}
+			if got := DecodeLoggedBody(logged); got != tt.want {
+				t.Fatalf("DecodeLoggedBody(%q) = %q, want %q", logged, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeLoggedBodyKeepsUnrecognized(t *testing.T) {
+	for _, text := range []string{
+		"base64:not valid!",
+		"base64 (other):aGk=",
+		"base64-ish text",
+	} {
+		if got := DecodeLoggedBody(text); got != text {
+			t.Errorf("DecodeLoggedBody(%q) = %q, want unchanged", text, got)
+		}
+	}
+	// base64 保存的 UTF-8 内容还原为文本
+	if got := DecodeLoggedBody("base64:aGk="); got != "hi" {
+		t.Errorf("got %q, want %q", got, "hi")
+	}
+}
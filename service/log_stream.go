@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+// ErrTooManyLogSubscribers 实时日志订阅数已达上限
+var ErrTooManyLogSubscribers = errors.New("too many log stream subscribers")
+
+// LogEvent 推送给实时日志订阅者的请求事件
+type LogEvent struct {
+	ID               uint      `json:"id"`
+	Model            string    `json:"model"`
+	ProviderName     string    `json:"provider_name"`
+	ProviderModel    string    `json:"provider_model"`
+	Style            string    `json:"style"`
+	Status           string    `json:"status"`
+	Error            string    `json:"error,omitempty"`
+	Retry            int       `json:"retry"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	TotalTokens      int64     `json:"total_tokens"`
+	TotalCost        float64   `json:"total_cost"`
+	FirstChunkTimeMs int       `json:"first_chunk_time_ms"`
+	ChunkTimeMs      int       `json:"chunk_time_ms"`
+	ProxyTimeMs      int       `json:"proxy_time_ms"`
+	AuthKeyID        uint      `json:"auth_key_id"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// LogStreamFilter 订阅过滤条件，空值表示不过滤
+type LogStreamFilter struct {
+	Model  string
+	Status string
+}
+
+func (f LogStreamFilter) match(event LogEvent) bool {
+	if f.Model != "" && f.Model != event.Model {
+		return false
+	}
+	if f.Status != "" && f.Status != event.Status {
+		return false
+	}
+	return true
+}
+
+// LogSubscriber 单个实时日志订阅
+type LogSubscriber struct {
+	C      chan LogEvent
+	filter LogStreamFilter
+}
+
+var logStream = struct {
+	mu    sync.RWMutex
+	subs  map[*LogSubscriber]struct{}
+	count atomic.Int32
+}{subs: make(map[*LogSubscriber]struct{})}
+
+// logStreamMaxSubscribers 最大同时订阅数（LOG_STREAM_MAX_SUBSCRIBERS，默认 10）
+func logStreamMaxSubscribers() int {
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv("LOG_STREAM_MAX_SUBSCRIBERS"))); err == nil && v > 0 {
+		return v
+	}
+	return 10
+}
+
+// SubscribeLogs 订阅实时请求日志，使用完毕后必须调用 UnsubscribeLogs
+func SubscribeLogs(filter LogStreamFilter) (*LogSubscriber, error) {
+	logStream.mu.Lock()
+	defer logStream.mu.Unlock()
+	if len(logStream.subs) >= logStreamMaxSubscribers() {
+		return nil, ErrTooManyLogSubscribers
+	}
+	sub := &LogSubscriber{C: make(chan LogEvent, 64), filter: filter}
+	logStream.subs[sub] = struct{}{}
+	logStream.count.Store(int32(len(logStream.subs)))
+	return sub, nil
+}
+
+// UnsubscribeLogs 取消订阅
+func UnsubscribeLogs(sub *LogSubscriber) {
+	logStream.mu.Lock()
+	defer logStream.mu.Unlock()
+	delete(logStream.subs, sub)
+	logStream.count.Store(int32(len(logStream.subs)))
+}
+
+func hasLogSubscribers() bool {
+	return logStream.count.Load() > 0
+}
+
+// publishLog 向订阅者广播事件；订阅者消费过慢时丢弃，绝不阻塞请求链路
+func publishLog(log models.ChatLog) {
+	if !hasLogSubscribers() {
+		return
+	}
+	event := LogEvent{
+		ID:               log.ID,
+		Model:            log.Name,
+		ProviderName:     log.ProviderName,
+		ProviderModel:    log.ProviderModel,
+		Style:            log.Style,
+		Status:           log.Status,
+		Error:            log.Error,
+		Retry:            log.Retry,
+		PromptTokens:     log.PromptTokens,
+		CompletionTokens: log.CompletionTokens,
+		TotalTokens:      log.TotalTokens,
+		TotalCost:        log.TotalCost,
+		FirstChunkTimeMs: log.FirstChunkTimeMs,
+		ChunkTimeMs:      log.ChunkTimeMs,
+		ProxyTimeMs:      log.ProxyTimeMs,
+		AuthKeyID:        log.AuthKeyID,
+		CreatedAt:        log.CreatedAt,
+	}
+
+	logStream.mu.RLock()
+	defer logStream.mu.RUnlock()
+	for sub := range logStream.subs {
+		if !sub.filter.match(event) {
+			continue
+		}
+		select {
+		case sub.C <- event:
+		default:
+		}
+	}
+}
+
+// publishLogByID 请求完成后按 ID 读取最终日志并广播，无订阅者时不查询数据库
+func publishLogByID(ctx context.Context, logId uint) {
+	if !hasLogSubscribers() {
+		return
+	}
+	log, err := gorm.G[models.ChatLog](models.DB).Where("id = ?", logId).First(ctx)
+	if err != nil {
+		slog.Error("load chat log for log stream error", "error", err)
+		return
+	}
+	publishLog(log)
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// modelEchoPaths 响应中回显模型名的字段：OpenAI/Anthropic 响应体与 chunk 顶层的 model，
+// Anthropic message_start 事件的 message.model，Responses API 事件的 response.model
+var modelEchoPaths = []string{"model", "message.model", "response.model"}
+
+// rewriteModelEcho 将 JSON 中已存在的模型字段替换为网关模型名，非 JSON 内容原样返回
+func rewriteModelEcho(data []byte, model string) []byte {
+	if !gjson.ValidBytes(data) {
+		return data
+	}
+	for _, path := range modelEchoPaths {
+		if value := gjson.GetBytes(data, path); value.Type != gjson.String || value.Str == model {
+			continue
+		}
+		if rewritten, err := sjson.SetBytes(data, path, model); err == nil {
+			data = rewritten
+		}
+	}
+	return data
+}
+
+// echoRequestedModel 将上游响应中的模型名替换为客户端请求的网关模型名，供校验 model 字段的客户端使用
+func echoRequestedModel(res *http.Response, model string, stream bool) error {
+	if stream {
+		res.Body = newModelEchoStream(res.Body, model)
+		return nil
+	}
+	raw, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return err
+	}
+	body := rewriteModelEcho(raw, model)
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// modelEchoStream 逐行改写 SSE 中 data 行的模型字段，其余行原样输出
+type modelEchoStream struct {
+	src    io.ReadCloser
+	reader *bufio.Reader
+	model  string
+	buf    bytes.Buffer
+	err    error
+}
+
+func newModelEchoStream(src io.ReadCloser, model string) *modelEchoStream {
+	return &modelEchoStream{src: src, reader: bufio.NewReader(src), model: model}
+}
+
+func (s *modelEchoStream) Read(p []byte) (int, error) {
+	for s.buf.Len() == 0 && s.err == nil {
+		line, err := s.reader.ReadBytes('\n')
+		s.buf.Write(s.rewriteLine(line))
+		// 读取错误（含截止/超时）在已读内容输出后再返回
+		s.err = err
+	}
+	if s.buf.Len() == 0 {
+		return 0, s.err
+	}
+	return s.buf.Read(p)
+}
+
+func (s *modelEchoStream) rewriteLine(line []byte) []byte {
+	content := bytes.TrimRight(line, "\r\n")
+	data, ok := bytes.CutPrefix(content, []byte("data:"))
+	if !ok {
+		return line
+	}
+	rewritten := rewriteModelEcho(bytes.TrimSpace(data), s.model)
+	out := make([]byte, 0, len(line)+len(s.model))
+	out = append(out, "data: "...)
+	out = append(out, rewritten...)
+	return append(out, line[len(content):]...)
+}
+
+func (s *modelEchoStream) Close() error {
+	return s.src.Close()
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEchoRequestedModelNonStream(t *testing.T) {
+	res := &http.Response{
+		Header: http.Header{},
+		Body:   io.NopCloser(strings.NewReader(`{"id":"1","model":"upstream-model","choices":[]}`)),
+	}
+	if err := echoRequestedModel(res, "gateway-model", false); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	if want := `{"id":"1","model":"gateway-model","choices":[]}`; string(body) != want {
+		t.Fatalf("body = %s, want %s", body, want)
+	}
+	if res.Header.Get("Content-Length") != "47" {
+		t.Fatalf("content-length = %s", res.Header.Get("Content-Length"))
+	}
+}
+
+func TestEchoRequestedModelStream(t *testing.T) {
+	stream := "event: message_start\n" +
+		`data: {"type":"message_start","message":{"model":"claude-upstream"}}` + "\n\n" +
+		`data: {"model":"upstream","choices":[]}` + "\r\n\r\n" +
+		"data: [DONE]\n\n"
+	res := &http.Response{Header: http.Header{}, Body: io.NopCloser(strings.NewReader(stream))}
+	if err := echoRequestedModel(res, "gw", true); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	want := "event: message_start\n" +
+		`data: {"type":"message_start","message":{"model":"gw"}}` + "\n\n" +
+		`data: {"model":"gw","choices":[]}` + "\r\n\r\n" +
+		"data: [DONE]\n\n"
+	if string(body) != want {
+		t.Fatalf("body = %q, want %q", body, want)
+	}
+}
+
+func TestRequestedModelOnFallback(t *testing.T) {
+	before := Before{Model: "gpt-4o"}
+	fallback := &ProvidersWithMeta{model: "claude-sonnet", FallbackFrom: "gpt-4o"}
+	if got := fallback.requestedModel(fallback.Before(before)); got != "gpt-4o" {
+		t.Fatalf("fallback requested model = %q, want gpt-4o", got)
+	}
+	primary := &ProvidersWithMeta{model: "gpt-4o"}
+	if got := primary.requestedModel(before); got != "gpt-4o" {
+		t.Fatalf("primary requested model = %q", got)
+	}
+}
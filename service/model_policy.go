@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/models"
+)
+
+// CheckModelPolicy 按全局模型策略校验模型是否允许访问，独立于 auth key 权限
+// 返回 nil 表示放行；拒绝时返回带错误码的错误及建议的 HTTP 状态码
+func CheckModelPolicy(ctx context.Context, model string) (int, error) {
+	var cfg models.ModelPolicyConfig
+	ok, err := loadJSONConfig(ctx, models.KeyModelPolicy, &cfg)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !ok || !cfg.Enabled {
+		return http.StatusOK, nil
+	}
+
+	if matchModelPattern(cfg.Deny, model) {
+		return http.StatusForbidden, common.NewError(common.ErrCodeModelForbidden, "model "+model+" is blocked by gateway policy")
+	}
+	if len(cfg.Allow) > 0 && !matchModelPattern(cfg.Allow, model) {
+		return http.StatusNotFound, common.NewError(common.ErrCodeModelNotFound, "model "+model+" is not served by this gateway")
+	}
+	return http.StatusOK, nil
+}
+
+// matchModelPattern 模型名与任一模式匹配时返回 true，模式语法见 matchGlob
+func matchModelPattern(patterns []string, model string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if matchGlob(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob 通配符匹配：* 匹配任意长度（含 0）的任意字符，包括 "/"（如 openrouter/* 匹配 openrouter/anthropic/claude），
+// ? 匹配任意单个字符，其余字符按原样比较
+func matchGlob(pattern, name string) bool {
+	p, n := []rune(pattern), []rune(name)
+	pi, ni := 0, 0
+	star, mark := -1, 0
+	for ni < len(n) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == n[ni]):
+			pi++
+			ni++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, ni
+			pi++
+		case star >= 0:
+			// 回溯：让上一个 * 多匹配一个字符
+			mark++
+			pi, ni = star+1, mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/racio/llmio/models"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"gpt-4o", "gpt-4o", true},
+		{"gpt-4o", "gpt-4o-mini", false},
+		{"gpt-*", "gpt-4o-mini", true},
+		{"*", "anything/with/slashes", true},
+		// * 可以跨越 "/"
+		{"openrouter/*", "openrouter/anthropic/claude-3.5-sonnet", true},
+		{"*/claude-*", "openrouter/anthropic/claude-3.5-sonnet", true},
+		{"*claude*", "anthropic/claude-3-opus", true},
+		{"openai/*", "openrouter/openai/gpt-4o", false},
+		{"gpt-?o", "gpt-4o", true},
+		{"gpt-?o", "gpt-40o", false},
+		{"*-mini", "gpt-4o-mini", true},
+		{"*-mini", "gpt-4o-mini-2024", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"", "", true},
+		{"[abc]", "a", false}, // 不支持字符类，按字面比较
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckModelPolicy(t *testing.T) {
+	stubConfig(t, map[string]string{
+		models.KeyModelPolicy: `{"enabled":true,"allow":["openrouter/*","gpt-*"],"deny":["*/free"]}`,
+	})
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"openrouter/anthropic/claude-3.5-sonnet", http.StatusOK},
+		{"gpt-4o", http.StatusOK},
+		{"openrouter/meta/llama/free", http.StatusForbidden},
+		{"claude-3-opus", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		status, err := CheckModelPolicy(context.Background(), tt.model)
+		if status != tt.want {
+			t.Errorf("CheckModelPolicy(%q) = %d (%v), want %d", tt.model, status, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/racio/llmio/models"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// WebhookEvent webhook 通知内容
+type WebhookEvent struct {
+	Event     string    `json:"event"`
+	Message   string    `json:"message"`
+	Data      any       `json:"data,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notify 按 webhook_notifier 配置发送通知；未启用时直接返回
+func Notify(ctx context.Context, event string, message string, data any) error {
+	var cfg models.WebhookNotifierConfig
+	ok, err := loadJSONConfig(ctx, models.KeyWebhookNotifier, &cfg)
+	if err != nil {
+		return err
+	}
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	if !ok || !cfg.Enabled || cfg.URL == "" {
+		return nil
+	}
+	return postWebhook(ctx, cfg.URL, cfg.Headers, event, message, data)
+}
+
+// postWebhook 向指定地址 POST 一条 WebhookEvent，非 2xx 响应视为失败
+func postWebhook(ctx context.Context, url string, headers map[string]string, event string, message string, data any) error {
+	body, err := json.Marshal(WebhookEvent{
+		Event:     event,
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook status code: %d", res.StatusCode)
+	}
+	slog.Info("webhook notified", "event", event)
+	return nil
+}
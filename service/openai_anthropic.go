@@ -0,0 +1,490 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/racio/llmio/consts"
+	"github.com/tidwall/gjson"
+)
+
+// OpenAI chat/completions → Anthropic messages 协议转换
+// 仅客户端使用 OpenAI chat/completions 且关联的提供商为 anthropic 类型时启用：请求体转换后转发，
+// 上游响应（非流式 JSON 或流式 SSE）再转换回 OpenAI 格式，日志与用量统计按 OpenAI 格式解析
+
+// defaultAnthropicMaxTokens OpenAI 请求未指定 max_tokens 时使用的默认值（Anthropic 要求必填）
+const defaultAnthropicMaxTokens = 4096
+
+// translatesToAnthropic 是否需要把 OpenAI 请求转换后发给 Anthropic 提供商
+func translatesToAnthropic(style string, providerType string) bool {
+	return style == consts.StyleOpenAI && (providerType == consts.StyleAnthropic || providerType == consts.StyleBedrock)
+}
+
+// upstreamRequestBody 返回发往该类型提供商的请求体，需要协议转换时转换后返回
+func upstreamRequestBody(style string, providerType string, raw []byte) ([]byte, error) {
+	if translatesToAnthropic(style, providerType) {
+		return OpenAIToAnthropicRequest(raw)
+	}
+	return raw, nil
+}
+
+// OpenAIToAnthropicRequest 将 OpenAI chat/completions 请求体转换为 Anthropic messages 请求体
+// system/developer 消息合并为 system，tool 消息转为 tool_result，assistant 的 tool_calls 转为 tool_use
+func OpenAIToAnthropicRequest(raw []byte) ([]byte, error) {
+	if !gjson.ValidBytes(raw) {
+		return nil, errors.New("invalid json body")
+	}
+	req := gjson.ParseBytes(raw)
+	body := map[string]any{
+		"model": req.Get("model").String(),
+	}
+
+	var systemParts []string
+	messages := make([]map[string]any, 0)
+	appendMessage := func(role string, blocks []map[string]any) {
+		if len(blocks) == 0 {
+			return
+		}
+		// Anthropic 要求 user/assistant 交替出现，连续的同角色消息合并
+		if n := len(messages); n > 0 && messages[n-1]["role"] == role {
+			messages[n-1]["content"] = append(messages[n-1]["content"].([]map[string]any), blocks...)
+			return
+		}
+		messages = append(messages, map[string]any{"role": role, "content": blocks})
+	}
+	for _, msg := range req.Get("messages").Array() {
+		switch role := msg.Get("role").String(); role {
+		case "system", "developer":
+			if text := openAIContentText(msg.Get("content")); text != "" {
+				systemParts = append(systemParts, text)
+			}
+		case "user":
+			appendMessage("user", openAIContentBlocks(msg.Get("content")))
+		case "assistant":
+			blocks := openAIContentBlocks(msg.Get("content"))
+			for _, call := range msg.Get("tool_calls").Array() {
+				input := map[string]any{}
+				if args := call.Get("function.arguments").String(); strings.TrimSpace(args) != "" {
+					if err := json.Unmarshal([]byte(args), &input); err != nil {
+						return nil, fmt.Errorf("invalid tool call arguments: %w", err)
+					}
+				}
+				blocks = append(blocks, map[string]any{
+					"type":  "tool_use",
+					"id":    call.Get("id").String(),
+					"name":  call.Get("function.name").String(),
+					"input": input,
+				})
+			}
+			appendMessage("assistant", blocks)
+		case "tool":
+			appendMessage("user", []map[string]any{{
+				"type":        "tool_result",
+				"tool_use_id": msg.Get("tool_call_id").String(),
+				"content":     openAIContentText(msg.Get("content")),
+			}})
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", role)
+		}
+	}
+	if len(systemParts) > 0 {
+		body["system"] = strings.Join(systemParts, "\n\n")
+	}
+	body["messages"] = messages
+
+	maxTokens := int64(defaultAnthropicMaxTokens)
+	if v := req.Get("max_completion_tokens"); v.Exists() && v.Int() > 0 {
+		maxTokens = v.Int()
+	} else if v := req.Get("max_tokens"); v.Exists() && v.Int() > 0 {
+		maxTokens = v.Int()
+	}
+	body["max_tokens"] = maxTokens
+
+	if v := req.Get("temperature"); v.Exists() {
+		body["temperature"] = v.Float()
+	}
+	if v := req.Get("top_p"); v.Exists() {
+		body["top_p"] = v.Float()
+	}
+	if v := req.Get("stream"); v.Exists() {
+		body["stream"] = v.Bool()
+	}
+	if v := req.Get("stop"); v.Exists() {
+		var stops []string
+		if v.IsArray() {
+			for _, s := range v.Array() {
+				stops = append(stops, s.String())
+			}
+		} else if v.String() != "" {
+			stops = append(stops, v.String())
+		}
+		if len(stops) > 0 {
+			body["stop_sequences"] = stops
+		}
+	}
+	if v := req.Get("user"); v.String() != "" {
+		body["metadata"] = map[string]any{"user_id": v.String()}
+	}
+
+	if tools := req.Get("tools").Array(); len(tools) > 0 {
+		anthropicTools := make([]map[string]any, 0, len(tools))
+		for _, tool := range tools {
+			if tool.Get("type").String() != "function" {
+				continue
+			}
+			schema := json.RawMessage(`{"type":"object"}`)
+			if params := tool.Get("function.parameters"); params.Exists() {
+				schema = json.RawMessage(params.Raw)
+			}
+			item := map[string]any{
+				"name":         tool.Get("function.name").String(),
+				"input_schema": schema,
+			}
+			if desc := tool.Get("function.description").String(); desc != "" {
+				item["description"] = desc
+			}
+			anthropicTools = append(anthropicTools, item)
+		}
+		body["tools"] = anthropicTools
+	}
+	if toolChoice := anthropicToolChoice(req.Get("tool_choice")); toolChoice != nil {
+		if v := req.Get("parallel_tool_calls"); v.Exists() && !v.Bool() && toolChoice["type"] != "none" {
+			toolChoice["disable_parallel_tool_use"] = true
+		}
+		body["tool_choice"] = toolChoice
+	} else if v := req.Get("parallel_tool_calls"); v.Exists() && !v.Bool() && req.Get("tools").IsArray() {
+		body["tool_choice"] = map[string]any{"type": "auto", "disable_parallel_tool_use": true}
+	}
+
+	return json.Marshal(body)
+}
+
+// anthropicToolChoice 转换 tool_choice：auto/required/none 以及指定函数
+func anthropicToolChoice(choice gjson.Result) map[string]any {
+	if !choice.Exists() {
+		return nil
+	}
+	if choice.Type == gjson.String {
+		switch choice.String() {
+		case "auto":
+			return map[string]any{"type": "auto"}
+		case "required":
+			return map[string]any{"type": "any"}
+		case "none":
+			return map[string]any{"type": "none"}
+		}
+		return nil
+	}
+	if name := choice.Get("function.name").String(); name != "" {
+		return map[string]any{"type": "tool", "name": name}
+	}
+	return nil
+}
+
+// openAIContentText 提取消息内容中的文本（字符串或 text 片段数组）
+func openAIContentText(content gjson.Result) string {
+	if content.Type == gjson.String {
+		return content.String()
+	}
+	var parts []string
+	for _, part := range content.Array() {
+		if part.Get("type").String() == "text" {
+			parts = append(parts, part.Get("text").String())
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// openAIContentBlocks 将消息内容转换为 Anthropic 内容块，支持文本与 image_url（data URL 或 http 地址）
+func openAIContentBlocks(content gjson.Result) []map[string]any {
+	if content.Type == gjson.String {
+		if content.String() == "" {
+			return nil
+		}
+		return []map[string]any{{"type": "text", "text": content.String()}}
+	}
+	blocks := make([]map[string]any, 0)
+	for _, part := range content.Array() {
+		switch part.Get("type").String() {
+		case "text":
+			// Anthropic 不接受空文本块
+			if text := part.Get("text").String(); text != "" {
+				blocks = append(blocks, map[string]any{"type": "text", "text": text})
+			}
+		case "image_url":
+			url := part.Get("image_url.url").String()
+			if mediaType, data, ok := parseDataURL(url); ok {
+				blocks = append(blocks, map[string]any{
+					"type":   "image",
+					"source": map[string]any{"type": "base64", "media_type": mediaType, "data": data},
+				})
+				continue
+			}
+			blocks = append(blocks, map[string]any{
+				"type":   "image",
+				"source": map[string]any{"type": "url", "url": url},
+			})
+		}
+	}
+	return blocks
+}
+
+// parseDataURL 解析 data:<media_type>;base64,<data>
+func parseDataURL(url string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", "", false
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", "", false
+	}
+	mediaType, ok := strings.CutSuffix(meta, ";base64")
+	if !ok {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
+
+// openAIFinishReason Anthropic stop_reason → OpenAI finish_reason
+func openAIFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// openAIUsage Anthropic usage → OpenAI usage，缓存读写的 token 计入 prompt_tokens
+func openAIUsage(usage gjson.Result, outputTokens int64) map[string]any {
+	promptTokens := usage.Get("input_tokens").Int() + usage.Get("cache_read_input_tokens").Int() + usage.Get("cache_creation_input_tokens").Int()
+	return map[string]any{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": outputTokens,
+		"total_tokens":      promptTokens + outputTokens,
+	}
+}
+
+// AnthropicToOpenAIResponse 将 Anthropic 非流式响应转换为 OpenAI chat.completion
+func AnthropicToOpenAIResponse(raw []byte) ([]byte, error) {
+	if !gjson.ValidBytes(raw) {
+		return nil, errors.New("invalid anthropic response")
+	}
+	res := gjson.ParseBytes(raw)
+	var text strings.Builder
+	toolCalls := make([]map[string]any, 0)
+	for _, block := range res.Get("content").Array() {
+		switch block.Get("type").String() {
+		case "text":
+			text.WriteString(block.Get("text").String())
+		case "tool_use":
+			input := block.Get("input").Raw
+			if input == "" {
+				input = "{}"
+			}
+			toolCalls = append(toolCalls, map[string]any{
+				"id":   block.Get("id").String(),
+				"type": "function",
+				"function": map[string]any{
+					"name":      block.Get("name").String(),
+					"arguments": input,
+				},
+			})
+		}
+	}
+	message := map[string]any{"role": "assistant", "content": text.String()}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+		if text.Len() == 0 {
+			message["content"] = nil
+		}
+	}
+	usage := res.Get("usage")
+	return json.Marshal(map[string]any{
+		"id":      res.Get("id").String(),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   res.Get("model").String(),
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       message,
+			"finish_reason": openAIFinishReason(res.Get("stop_reason").String()),
+		}},
+		"usage": openAIUsage(usage, usage.Get("output_tokens").Int()),
+	})
+}
+
+// anthropicToOpenAIStream 将 Anthropic SSE 流逐事件转换为 OpenAI chat.completion.chunk 流
+type anthropicToOpenAIStream struct {
+	src     io.ReadCloser
+	reader  *bufio.Reader
+	buf     bytes.Buffer
+	done    bool
+	id      string
+	model   string
+	created int64
+	usage   gjson.Result
+	// Anthropic 内容块下标 → OpenAI tool_calls 下标
+	toolIndex map[int64]int
+}
+
+func newAnthropicToOpenAIStream(src io.ReadCloser) *anthropicToOpenAIStream {
+	return &anthropicToOpenAIStream{
+		src:       src,
+		reader:    bufio.NewReader(src),
+		created:   time.Now().Unix(),
+		toolIndex: make(map[int64]int),
+	}
+}
+
+func (s *anthropicToOpenAIStream) Read(p []byte) (int, error) {
+	for s.buf.Len() == 0 && !s.done {
+		line, err := s.reader.ReadString('\n')
+		if data, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "data:"); ok {
+			if convErr := s.convert(strings.TrimSpace(data)); convErr != nil {
+				return 0, convErr
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return 0, err
+			}
+			s.done = true
+		}
+	}
+	if s.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return s.buf.Read(p)
+}
+
+func (s *anthropicToOpenAIStream) Close() error {
+	return s.src.Close()
+}
+
+// convert 处理一个 Anthropic 事件的 data，生成零个或多个 OpenAI chunk
+func (s *anthropicToOpenAIStream) convert(data string) error {
+	if !gjson.Valid(data) {
+		return nil
+	}
+	event := gjson.Parse(data)
+	switch event.Get("type").String() {
+	case "message_start":
+		s.id = event.Get("message.id").String()
+		s.model = event.Get("message.model").String()
+		s.usage = event.Get("message.usage")
+		return s.writeChunk(map[string]any{"role": "assistant", "content": ""}, nil, nil)
+	case "content_block_start":
+		block := event.Get("content_block")
+		if block.Get("type").String() != "tool_use" {
+			return nil
+		}
+		index := len(s.toolIndex)
+		s.toolIndex[event.Get("index").Int()] = index
+		return s.writeChunk(map[string]any{"tool_calls": []map[string]any{{
+			"index": index,
+			"id":    block.Get("id").String(),
+			"type":  "function",
+			"function": map[string]any{
+				"name":      block.Get("name").String(),
+				"arguments": "",
+			},
+		}}}, nil, nil)
+	case "content_block_delta":
+		delta := event.Get("delta")
+		switch delta.Get("type").String() {
+		case "text_delta":
+			return s.writeChunk(map[string]any{"content": delta.Get("text").String()}, nil, nil)
+		case "input_json_delta":
+			index, ok := s.toolIndex[event.Get("index").Int()]
+			if !ok {
+				return nil
+			}
+			return s.writeChunk(map[string]any{"tool_calls": []map[string]any{{
+				"index":    index,
+				"function": map[string]any{"arguments": delta.Get("partial_json").String()},
+			}}}, nil, nil)
+		}
+	case "message_delta":
+		finishReason := openAIFinishReason(event.Get("delta.stop_reason").String())
+		// 用量随结束 chunk 一起下发，便于按 OpenAI 格式统计 token
+		usage := openAIUsage(s.usage, event.Get("usage.output_tokens").Int())
+		return s.writeChunk(map[string]any{}, &finishReason, usage)
+	case "message_stop":
+		s.buf.WriteString("data: [DONE]\n\n")
+	case "error":
+		payload, err := json.Marshal(map[string]any{"error": map[string]any{
+			"type":    event.Get("error.type").String(),
+			"message": event.Get("error.message").String(),
+		}})
+		if err != nil {
+			return err
+		}
+		s.buf.WriteString("data: ")
+		s.buf.Write(payload)
+		s.buf.WriteString("\n\n")
+	}
+	return nil
+}
+
+func (s *anthropicToOpenAIStream) writeChunk(delta map[string]any, finishReason *string, usage map[string]any) error {
+	chunk := map[string]any{
+		"id":      s.id,
+		"object":  "chat.completion.chunk",
+		"created": s.created,
+		"model":   s.model,
+		"choices": []map[string]any{{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	}
+	if usage != nil {
+		chunk["usage"] = usage
+	}
+	payload, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	s.buf.WriteString("data: ")
+	s.buf.Write(payload)
+	s.buf.WriteString("\n\n")
+	return nil
+}
+
+// translateAnthropicResponse 将 Anthropic 成功响应替换为等价的 OpenAI 响应
+func translateAnthropicResponse(res *http.Response, stream bool) error {
+	res.Header.Del("Content-Length")
+	if stream {
+		res.Body = newAnthropicToOpenAIStream(res.Body)
+		res.ContentLength = -1
+		return nil
+	}
+	raw, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return err
+	}
+	body, err := AnthropicToOpenAIResponse(raw)
+	if err != nil {
+		return err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	res.Header.Set("Content-Type", "application/json")
+	return nil
+}
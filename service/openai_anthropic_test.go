@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/limiter"
+	"github.com/racio/llmio/models"
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAIToAnthropicRequest(t *testing.T) {
+	raw := []byte(`{
+		"model": "claude-sonnet",
+		"max_tokens": 256,
+		"temperature": 0.2,
+		"stop": "END",
+		"messages": [
+			{"role": "system", "content": "be brief"},
+			{"role": "developer", "content": [{"type": "text", "text": "answer in english"}]},
+			{"role": "user", "content": "weather in Paris?"},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}
+			]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "sunny"},
+			{"role": "user", "content": "thanks"}
+		],
+		"tools": [{"type": "function", "function": {
+			"name": "get_weather",
+			"description": "current weather",
+			"parameters": {"type": "object", "properties": {"city": {"type": "string"}}}
+		}}],
+		"tool_choice": "required"
+	}`)
+
+	body, err := OpenAIToAnthropicRequest(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := gjson.ParseBytes(body)
+
+	checks := map[string]string{
+		"system":                                    "be brief\n\nanswer in english",
+		"max_tokens":                                "256",
+		"temperature":                               "0.2",
+		"stop_sequences.0":                          "END",
+		"messages.#":                                "3",
+		"messages.0.role":                           "user",
+		"messages.0.content.0.text":                 "weather in Paris?",
+		"messages.1.role":                           "assistant",
+		"messages.1.content.0.type":                 "tool_use",
+		"messages.1.content.0.id":                   "call_1",
+		"messages.1.content.0.name":                 "get_weather",
+		"messages.1.content.0.input.city":           "Paris",
+		"messages.2.role":                           "user",
+		"messages.2.content.0.type":                 "tool_result",
+		"messages.2.content.0.tool_use_id":          "call_1",
+		"messages.2.content.0.content":              "sunny",
+		"messages.2.content.1.text":                 "thanks",
+		"tools.0.name":                              "get_weather",
+		"tools.0.description":                       "current weather",
+		"tools.0.input_schema.properties.city.type": "string",
+		"tool_choice.type":                          "any",
+	}
+	for path, want := range checks {
+		if v := got.Get(path).String(); v != want {
+			t.Errorf("%s = %q, want %q", path, v, want)
+		}
+	}
+}
+
+func TestOpenAIToAnthropicRequestDefaultMaxTokens(t *testing.T) {
+	body, err := OpenAIToAnthropicRequest([]byte(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := gjson.GetBytes(body, "max_tokens").Int(); v != defaultAnthropicMaxTokens {
+		t.Fatalf("max_tokens = %d, want %d", v, defaultAnthropicMaxTokens)
+	}
+	if gjson.GetBytes(body, "system").Exists() {
+		t.Fatalf("system should be omitted: %s", body)
+	}
+}
+
+func TestOpenAIToAnthropicRequestInvalid(t *testing.T) {
+	if _, err := OpenAIToAnthropicRequest([]byte(`{`)); err == nil {
+		t.Fatal("expected error for invalid json")
+	}
+	bad := []byte(`{"messages":[{"role":"assistant","tool_calls":[{"id":"c","function":{"name":"f","arguments":"{"}}]}]}`)
+	if _, err := OpenAIToAnthropicRequest(bad); err == nil {
+		t.Fatal("expected error for invalid tool call arguments")
+	}
+}
+
+func TestAnthropicToOpenAIResponse(t *testing.T) {
+	raw := []byte(`{
+		"id": "msg_1",
+		"model": "claude-sonnet",
+		"stop_reason": "tool_use",
+		"content": [
+			{"type": "text", "text": "checking"},
+			{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}
+		],
+		"usage": {"input_tokens": 10, "cache_read_input_tokens": 5, "output_tokens": 7}
+	}`)
+
+	body, err := AnthropicToOpenAIResponse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := gjson.ParseBytes(body)
+
+	checks := map[string]string{
+		"id":                                "msg_1",
+		"object":                            "chat.completion",
+		"choices.0.finish_reason":           "tool_calls",
+		"choices.0.message.role":            "assistant",
+		"choices.0.message.content":         "checking",
+		"choices.0.message.tool_calls.0.id": "toolu_1",
+		"choices.0.message.tool_calls.0.function.name": "get_weather",
+		"usage.prompt_tokens":                          "15",
+		"usage.completion_tokens":                      "7",
+		"usage.total_tokens":                           "22",
+	}
+	for path, want := range checks {
+		if v := got.Get(path).String(); v != want {
+			t.Errorf("%s = %q, want %q", path, v, want)
+		}
+	}
+	args := got.Get("choices.0.message.tool_calls.0.function.arguments").String()
+	if gjson.Get(args, "city").String() != "Paris" {
+		t.Fatalf("arguments = %s", args)
+	}
+}
+
+// anthropicSSE 将事件列表编码为 Anthropic SSE 流
+func anthropicSSE(events ...string) string {
+	var sb strings.Builder
+	for _, event := range events {
+		sb.WriteString("event: " + gjson.Get(event, "type").String() + "\n")
+		sb.WriteString("data: " + event + "\n\n")
+	}
+	return sb.String()
+}
+
+// readOpenAIChunks 读取转换后的流，返回所有 data 负载（不含 [DONE]）及是否收到 [DONE]
+func readOpenAIChunks(t *testing.T, r io.Reader) ([]gjson.Result, bool) {
+	t.Helper()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []gjson.Result
+	done := false
+	for line := range strings.SplitSeq(string(out), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		chunks = append(chunks, gjson.Parse(data))
+	}
+	return chunks, done
+}
+
+func TestAnthropicToOpenAIStream(t *testing.T) {
+	stream := anthropicSSE(
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet","usage":{"input_tokens":12}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+		`{"type":"message_stop"}`,
+	)
+
+	chunks, done := readOpenAIChunks(t, newAnthropicToOpenAIStream(io.NopCloser(strings.NewReader(stream))))
+	if !done {
+		t.Fatal("missing [DONE]")
+	}
+
+	var text, args strings.Builder
+	var toolID, toolName, finishReason string
+	var usage gjson.Result
+	for _, chunk := range chunks {
+		if chunk.Get("object").String() != "chat.completion.chunk" || chunk.Get("id").String() != "msg_1" {
+			t.Fatalf("unexpected chunk: %s", chunk.Raw)
+		}
+		delta := chunk.Get("choices.0.delta")
+		text.WriteString(delta.Get("content").String())
+		for _, call := range delta.Get("tool_calls").Array() {
+			if call.Get("index").Int() != 0 {
+				t.Fatalf("tool call index = %d, want 0", call.Get("index").Int())
+			}
+			if id := call.Get("id").String(); id != "" {
+				toolID, toolName = id, call.Get("function.name").String()
+			}
+			args.WriteString(call.Get("function.arguments").String())
+		}
+		if reason := chunk.Get("choices.0.finish_reason"); reason.Type == gjson.String {
+			finishReason = reason.String()
+		}
+		if u := chunk.Get("usage"); u.Exists() {
+			usage = u
+		}
+	}
+
+	if text.String() != "Hello" {
+		t.Errorf("text = %q, want Hello", text.String())
+	}
+	if toolID != "toolu_1" || toolName != "get_weather" {
+		t.Errorf("tool call = %s/%s", toolID, toolName)
+	}
+	if gjson.Get(args.String(), "city").String() != "Paris" {
+		t.Errorf("arguments = %q", args.String())
+	}
+	if finishReason != "tool_calls" {
+		t.Errorf("finish_reason = %q, want tool_calls", finishReason)
+	}
+	if usage.Get("prompt_tokens").Int() != 12 || usage.Get("completion_tokens").Int() != 9 {
+		t.Errorf("usage = %s", usage.Raw)
+	}
+}
+
+func TestAnthropicToOpenAIStreamError(t *testing.T) {
+	stream := anthropicSSE(`{"type":"error","error":{"type":"overloaded_error","message":"busy"}}`)
+	chunks, _ := readOpenAIChunks(t, newAnthropicToOpenAIStream(io.NopCloser(strings.NewReader(stream))))
+	if len(chunks) != 1 || chunks[0].Get("error.type").String() != "overloaded_error" || chunks[0].Get("error.message").String() != "busy" {
+		t.Fatalf("chunks = %v", chunks)
+	}
+}
+
+// newAnthropicProvider 构造单个 anthropic 提供商，handler 收到转换后的请求体
+func newAnthropicProvider(t *testing.T, handler func(w http.ResponseWriter, body []byte)) *ProvidersWithMeta {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		handler(w, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	provider := models.Provider{Name: "claude", Type: consts.StyleAnthropic, Config: `{"base_url":"` + srv.URL + `/v1","api_key":"k"}`}
+	provider.ID = 9400
+	mp := models.ModelWithProvider{ProviderID: 9400, ProviderModel: "claude-sonnet", Weight: 1}
+	mp.ID = 9400
+	return &ProvidersWithMeta{
+		ModelWithProviderMap: map[uint]models.ModelWithProvider{9400: mp},
+		WeightItems:          map[uint]int{9400: 1},
+		ProviderMap:          map[uint]models.Provider{9400: provider},
+		MaxRetry:             1,
+		TimeOut:              10,
+		Strategy:             consts.BalancerDefault,
+		model:                "gpt-4o",
+	}
+}
+
+func TestBalanceChatModelOpenAIToAnthropic(t *testing.T) {
+	request := `{"model":"gpt-4o","messages":[{"role":"system","content":"sys"},{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object"}}}]}`
+
+	t.Run("non-stream", func(t *testing.T) {
+		captureSQL(t)
+		var upstream []byte
+		meta := newAnthropicProvider(t, func(w http.ResponseWriter, body []byte) {
+			upstream = body
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"msg_1","model":"claude-sonnet","stop_reason":"tool_use","content":[{"type":"tool_use","id":"toolu_1","name":"lookup","input":{"q":"x"}}],"usage":{"input_tokens":3,"output_tokens":4}}`))
+		})
+
+		before := Before{Model: "gpt-4o", raw: []byte(request)}
+		res, _, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if gjson.GetBytes(upstream, "system").String() != "sys" || gjson.GetBytes(upstream, "model").String() != "claude-sonnet" {
+			t.Fatalf("upstream body not translated: %s", upstream)
+		}
+		if gjson.GetBytes(upstream, "tools.0.input_schema.type").String() != "object" {
+			t.Fatalf("upstream tools not translated: %s", upstream)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ContentLength != int64(len(body)) {
+			t.Fatalf("ContentLength = %d, body %d bytes", res.ContentLength, len(body))
+		}
+		call := gjson.GetBytes(body, "choices.0.message.tool_calls.0")
+		if call.Get("id").String() != "toolu_1" || call.Get("function.name").String() != "lookup" {
+			t.Fatalf("response not translated: %s", body)
+		}
+		if !json.Valid([]byte(call.Get("function.arguments").String())) {
+			t.Fatalf("arguments not json: %s", call.Get("function.arguments").String())
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		captureSQL(t)
+		var upstream []byte
+		meta := newAnthropicProvider(t, func(w http.ResponseWriter, body []byte) {
+			upstream = body
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, anthropicSSE(
+				`{"type":"message_start","message":{"id":"msg_2","model":"claude-sonnet","usage":{"input_tokens":3}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi there"}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+				`{"type":"message_stop"}`,
+			))
+		})
+
+		before := Before{Model: "gpt-4o", Stream: true, raw: []byte(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)}
+		res, _, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if !gjson.GetBytes(upstream, "stream").Bool() {
+			t.Fatalf("upstream stream flag lost: %s", upstream)
+		}
+		chunks, done := readOpenAIChunks(t, res.Body)
+		if !done {
+			t.Fatal("missing [DONE]")
+		}
+		var text strings.Builder
+		for _, chunk := range chunks {
+			text.WriteString(chunk.Get("choices.0.delta.content").String())
+		}
+		if text.String() != "hi there" {
+			t.Fatalf("text = %q", text.String())
+		}
+		if last := chunks[len(chunks)-1]; last.Get("choices.0.finish_reason").String() != "stop" || last.Get("usage.total_tokens").Int() != 5 {
+			t.Fatalf("final chunk = %s", last.Raw)
+		}
+	})
+}
+
+func TestBalanceChatModelOpenAIToAnthropicMalformedReleasesSlot(t *testing.T) {
+	orig := globalLimiterManager
+	globalLimiterManager = limiter.NewManager(nil)
+	t.Cleanup(func() { globalLimiterManager = orig })
+
+	statements := captureSQL(t)
+	inFlight := -1
+	meta := newAnthropicProvider(t, func(w http.ResponseWriter, _ []byte) {
+		inFlight, _ = GetProviderConcurrency(context.Background(), 9400)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","content":`))
+	})
+	provider := meta.ProviderMap[9400]
+	provider.MaxConcurrency = 1
+	meta.ProviderMap[9400] = provider
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	before := Before{Model: "gpt-4o", raw: []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)}
+	res, _, err := balanceChatModel(c, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, true)
+	if err == nil {
+		res.Body.Close()
+		t.Fatal("expected error for malformed upstream response")
+	}
+	if inFlight != 1 {
+		t.Fatalf("provider concurrency during request = %d, want 1", inFlight)
+	}
+	if n, _ := GetProviderConcurrency(context.Background(), 9400); n != 0 {
+		t.Fatalf("provider concurrency = %d after failed translation, want 0", n)
+	}
+	// 该次尝试与最终汇总都应写入日志
+	waitChatLogs(t, statements, 2)
+}
@@ -8,7 +8,9 @@ import (
 	"log/slog"
 	"net/http"
 	"regexp"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/racio/llmio/models"
@@ -19,8 +21,13 @@ import (
 const (
 	defaultPriceSyncIntervalMinutes = 1440
 	defaultPriceSyncURL             = "https://models.dev/api.json"
+	defaultPriceSyncTimeoutSeconds  = 20
+	defaultPriceSyncRetries         = 2
 )
 
+// priceSyncRetryBackoff 价格源首次重试前的等待时间，之后每次翻倍
+var priceSyncRetryBackoff = 2 * time.Second
+
 var priceProviders = []string{
 	"openai",
 	"anthropic",
@@ -54,6 +61,14 @@ type priceAPIProvider struct {
 
 type priceAPIResponse map[string]priceAPIProvider
 
+// priceSyncReady 首次价格同步成功（或同步未启用）后置为 true，用于就绪检查
+var priceSyncReady atomic.Bool
+
+// PriceSyncReady 首次价格同步是否已完成
+func PriceSyncReady() bool {
+	return priceSyncReady.Load()
+}
+
 func StartPriceSync(ctx context.Context) {
 	go priceSyncLoop(ctx)
 }
@@ -66,9 +81,14 @@ func priceSyncLoop(ctx context.Context) {
 		}
 
 		if cfg.Enabled {
-			if err := syncModelPrices(ctx, cfg.SourceURL); err != nil {
+			if err := syncModelPrices(ctx, cfg); err != nil {
 				slog.Error("同步模型价格失败", "error", err)
+			} else {
+				priceSyncReady.Store(true)
 			}
+		} else if err == nil {
+			// 未启用同步时无需等待，视为就绪
+			priceSyncReady.Store(true)
 		}
 
 		interval := time.Duration(cfg.IntervalMinutes) * time.Minute
@@ -89,6 +109,8 @@ func loadPriceSyncConfig(ctx context.Context) (models.ModelPriceSyncConfig, erro
 		Enabled:         true,
 		IntervalMinutes: defaultPriceSyncIntervalMinutes,
 		SourceURL:       defaultPriceSyncURL,
+		TimeoutSeconds:  defaultPriceSyncTimeoutSeconds,
+		Retries:         defaultPriceSyncRetries,
 	}
 
 	config, err := gorm.G[models.Config](models.DB).
@@ -112,10 +134,16 @@ func loadPriceSyncConfig(ctx context.Context) (models.ModelPriceSyncConfig, erro
 	if cfg.IntervalMinutes <= 0 {
 		cfg.IntervalMinutes = defaultPriceSyncIntervalMinutes
 	}
+	if cfg.TimeoutSeconds <= 0 {
+		cfg.TimeoutSeconds = defaultPriceSyncTimeoutSeconds
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
 	return cfg, nil
 }
 
-func syncModelPrices(ctx context.Context, sourceURL string) error {
+func syncModelPrices(ctx context.Context, cfg models.ModelPriceSyncConfig) error {
 	allowedModels, err := loadExistingModelNames(ctx)
 	if err != nil {
 		return err
@@ -124,26 +152,10 @@ func syncModelPrices(ctx context.Context, sourceURL string) error {
 		return nil
 	}
 
-	client := &http.Client{Timeout: 20 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
-	if err != nil {
-		return err
-	}
-
-	res, err := client.Do(req)
+	raw, err := fetchPriceSource(ctx, cfg)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("price api status: %d", res.StatusCode)
-	}
-
-	var raw priceAPIResponse
-	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
-		return err
-	}
 
 	prices := make([]models.ModelPrice, 0, len(allowedModels))
 	seen := make(map[string]struct{})
@@ -186,6 +198,59 @@ func syncModelPrices(ctx context.Context, sourceURL string) error {
 	}).Create(&prices).Error
 }
 
+// fetchPriceSource 拉取价格源数据，失败时按指数退避重试 cfg.Retries 次，避免偶发错误跳过整个同步周期
+func fetchPriceSource(ctx context.Context, cfg models.ModelPriceSyncConfig) (priceAPIResponse, error) {
+	client := &http.Client{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second}
+	backoff := priceSyncRetryBackoff
+	var lastErr error
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		if attempt > 0 {
+			slog.Warn("price sync request failed, retrying", "attempt", attempt, "backoff", backoff, "error", lastErr)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		raw, err := fetchPriceSourceOnce(ctx, client, cfg)
+		if err == nil {
+			return raw, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func fetchPriceSourceOnce(ctx context.Context, client *http.Client, cfg models.ModelPriceSyncConfig) (priceAPIResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.SourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ua := strings.TrimSpace(cfg.UserAgent); ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	if auth := strings.TrimSpace(cfg.Authorization); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price api status: %d", res.StatusCode)
+	}
+
+	var raw priceAPIResponse
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
 func appendPriceEntry(target *[]models.ModelPrice, seen map[string]struct{}, allowed map[string]struct{}, provider, modelID string, cost priceAPICost) {
 	if _, ok := allowed[modelID]; !ok {
 		return
@@ -264,3 +329,101 @@ func generateClaudeAliases(modelID string) []string {
 
 	return aliases
 }
+
+// PriceResolution 模型名称到价格记录的匹配结果，用于排查费用为 0 的原因
+type PriceResolution struct {
+	Model      string `json:"model"`
+	Normalized string `json:"normalized"` // 实际用于匹配的名称（小写、去空白）
+	Matched    bool   `json:"matched"`
+	// Via 匹配方式：exact 表示价格源中即为该名称，alias 表示由 AliasOf 中的模型生成的别名
+	Via     string             `json:"via,omitempty"`
+	AliasOf []string           `json:"alias_of,omitempty"`
+	Price   *models.ModelPrice `json:"price,omitempty"`
+	// Candidates 该名称可能对应的全部写法（含别名），未匹配时用于对照价格源
+	Candidates []string `json:"candidates"`
+	// CandidatePrices 以其他写法存在的价格记录，存在时说明仅是名称写法不一致
+	CandidatePrices []models.ModelPrice `json:"candidate_prices"`
+}
+
+var claudeNamePattern = regexp.MustCompile(`^claude-(?:(opus|sonnet|haiku)-(\d)[.-](\d)|(\d)[.-](\d)-(opus|sonnet|haiku))(-.*)?$`)
+
+// ResolveModelPrice 按计费时的规则（小写、去空白后精确匹配）查找模型价格，并给出别名来源与候选写法
+func ResolveModelPrice(ctx context.Context, model string) (PriceResolution, error) {
+	normalized := strings.ToLower(strings.TrimSpace(model))
+	res := PriceResolution{
+		Model:           model,
+		Normalized:      normalized,
+		Candidates:      []string{},
+		CandidatePrices: []models.ModelPrice{},
+	}
+	if normalized == "" {
+		return res, nil
+	}
+
+	res.AliasOf = priceAliasSources(normalized)
+	seen := map[string]struct{}{normalized: {}}
+	addCandidate := func(names ...string) {
+		for _, name := range names {
+			if _, ok := seen[name]; ok || name == "" {
+				continue
+			}
+			seen[name] = struct{}{}
+			res.Candidates = append(res.Candidates, name)
+		}
+	}
+	addCandidate(generateClaudeAliases(normalized)...)
+	addCandidate(modelAliases[normalized]...)
+	for _, source := range res.AliasOf {
+		addCandidate(source)
+		addCandidate(generateClaudeAliases(source)...)
+	}
+
+	prices, err := gorm.G[models.ModelPrice](models.DB).
+		Where("model_id IN ?", append([]string{normalized}, res.Candidates...)).
+		Find(ctx)
+	if err != nil {
+		return res, err
+	}
+	for _, price := range prices {
+		if price.ModelID == normalized {
+			res.Matched = true
+			res.Price = &price
+			continue
+		}
+		res.CandidatePrices = append(res.CandidatePrices, price)
+	}
+	if res.Matched {
+		res.Via = "exact"
+		if len(res.AliasOf) > 0 {
+			res.Via = "alias"
+		}
+	}
+	return res, nil
+}
+
+// priceAliasSources 返回会在价格同步时生成 name 作为别名的价格源模型 ID
+func priceAliasSources(name string) []string {
+	sources := make([]string, 0)
+	for source, aliases := range modelAliases {
+		if slices.Contains(aliases, name) {
+			sources = append(sources, source)
+		}
+	}
+	if match := claudeNamePattern.FindStringSubmatch(name); match != nil {
+		modelType, major, minor := match[1], match[2], match[3]
+		if modelType == "" {
+			modelType, major, minor = match[6], match[4], match[5]
+		}
+		suffix := match[7]
+		for _, source := range []string{
+			fmt.Sprintf("claude-%s-%s-%s%s", modelType, major, minor, suffix),
+			fmt.Sprintf("claude-%s-%s-%s%s", major, minor, modelType, suffix),
+		} {
+			if source != name && slices.Contains(generateClaudeAliases(source), name) {
+				sources = append(sources, source)
+			}
+		}
+	}
+	slices.Sort(sources)
+	return sources
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/racio/llmio/models"
+)
+
+// flakyPriceServer 前 failures 次请求返回 500，之后返回价格数据；delay 为首个请求的额外延迟
+func flakyPriceServer(t *testing.T, failures int64, delay time.Duration) (*httptest.Server, *atomic.Int64, chan http.Header) {
+	t.Helper()
+	hits := new(atomic.Int64)
+	headers := make(chan http.Header, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		headers <- r.Header.Clone()
+		if n == 1 && delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if n <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"openai":{"models":{"gpt-4o":{"id":"gpt-4o","cost":{"input":2.5,"output":10}}}}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, hits, headers
+}
+
+func shortPriceSyncBackoff(t *testing.T) {
+	t.Helper()
+	prev := priceSyncRetryBackoff
+	priceSyncRetryBackoff = time.Millisecond
+	t.Cleanup(func() { priceSyncRetryBackoff = prev })
+}
+
+func TestFetchPriceSourceRetries(t *testing.T) {
+	shortPriceSyncBackoff(t)
+	srv, hits, headers := flakyPriceServer(t, 2, 0)
+
+	cfg := models.ModelPriceSyncConfig{
+		SourceURL:      srv.URL,
+		TimeoutSeconds: 5,
+		Retries:        2,
+		UserAgent:      "llmio-price-sync",
+		Authorization:  "Bearer secret",
+	}
+	raw, err := fetchPriceSource(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Fatalf("hits = %d, want 3", n)
+	}
+	if cost := raw["openai"].Models["gpt-4o"].Cost; cost.Input == nil || *cost.Input != 2.5 {
+		t.Fatalf("unexpected price data: %+v", raw)
+	}
+	for range hits.Load() {
+		header := <-headers
+		if header.Get("User-Agent") != "llmio-price-sync" || header.Get("Authorization") != "Bearer secret" {
+			t.Fatalf("request headers = %v", header)
+		}
+	}
+}
+
+func TestFetchPriceSourceGivesUp(t *testing.T) {
+	shortPriceSyncBackoff(t)
+	srv, hits, _ := flakyPriceServer(t, 2, 0)
+
+	_, err := fetchPriceSource(context.Background(), models.ModelPriceSyncConfig{SourceURL: srv.URL, TimeoutSeconds: 5, Retries: 1})
+	if err == nil {
+		t.Fatal("expected error after retries exhausted")
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("hits = %d, want 2", n)
+	}
+}
+
+func TestFetchPriceSourceTimeoutRetried(t *testing.T) {
+	shortPriceSyncBackoff(t)
+	// 首个请求超过超时时间，重试后成功
+	srv, hits, _ := flakyPriceServer(t, 0, 3*time.Second)
+
+	if _, err := fetchPriceSource(context.Background(), models.ModelPriceSyncConfig{SourceURL: srv.URL, TimeoutSeconds: 1, Retries: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("hits = %d, want 2", n)
+	}
+}
+
+func TestFetchPriceSourceCanceled(t *testing.T) {
+	prev := priceSyncRetryBackoff
+	priceSyncRetryBackoff = time.Hour
+	t.Cleanup(func() { priceSyncRetryBackoff = prev })
+	srv, _, _ := flakyPriceServer(t, 10, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fetchPriceSource(ctx, models.ModelPriceSyncConfig{SourceURL: srv.URL, TimeoutSeconds: 5, Retries: 3}); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want context deadline while backing off", err)
+	}
+}
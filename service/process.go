@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/racio/llmio/consts"
 	"github.com/racio/llmio/models"
 	"github.com/tidwall/gjson"
 )
@@ -238,9 +239,10 @@ func ProcesserAnthropic(ctx context.Context, pr io.Reader, stream bool, start ti
 
 	// 构建 PromptTokensDetails JSON 字符串
 	promptTokensDetailsJSON := ""
-	if athropicUsage.CacheReadInputTokens > 0 {
+	if athropicUsage.CacheReadInputTokens > 0 || athropicUsage.CacheCreationInputTokens > 0 {
 		details := models.PromptTokensDetails{
-			CachedTokens: athropicUsage.CacheReadInputTokens,
+			CachedTokens:        athropicUsage.CacheReadInputTokens,
+			CacheCreationTokens: athropicUsage.CacheCreationInputTokens,
 		}
 		if jsonBytes, err := json.Marshal(details); err == nil {
 			promptTokensDetailsJSON = string(jsonBytes)
@@ -350,6 +352,109 @@ func ProcesserGemini(ctx context.Context, pr io.Reader, stream bool, start time.
 	}, &output, nil
 }
 
+// MistralUsage Mistral 的用量格式：缓存命中数在 num_cached_tokens（部分模型同时返回 prompt_tokens_details），
+// 个别响应不返回 total_tokens
+type MistralUsage struct {
+	PromptTokens        int64 `json:"prompt_tokens"`
+	CompletionTokens    int64 `json:"completion_tokens"`
+	TotalTokens         int64 `json:"total_tokens"`
+	NumCachedTokens     int64 `json:"num_cached_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+// ProcesserMistral 处理 mistral 提供商的 OpenAI 格式响应：流式错误为 {"object":"error"} 分块，用量按 Mistral 格式解析
+func ProcesserMistral(ctx context.Context, pr io.Reader, stream bool, start time.Time) (*models.ChatLog, *models.OutputUnion, error) {
+	// 首字时延
+	var firstChunkTime time.Duration
+	var once sync.Once
+
+	var usageStr string
+	var output models.OutputUnion
+	var size int
+
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 0, InitScannerBufferSize), MaxScannerBufferSize)
+	for chunk, chunkSize := range ScannerToken(scanner) {
+		size += chunkSize
+		once.Do(func() {
+			firstChunkTime = time.Since(start)
+		})
+		if !stream {
+			output.OfString = chunk
+			usageStr = gjson.Get(chunk, "usage").String()
+			break
+		}
+		chunk = strings.TrimPrefix(chunk, "data: ")
+		if chunk == "[DONE]" {
+			break
+		}
+		// 流式过程中错误
+		if gjson.Get(chunk, "object").String() == "error" {
+			return nil, nil, errors.New(gjson.Get(chunk, "message").String())
+		}
+		if errStr := gjson.Get(chunk, "error"); errStr.Exists() {
+			return nil, nil, errors.New(errStr.String())
+		}
+		output.OfStringArray = append(output.OfStringArray, chunk)
+
+		// usage 仅出现在最后一个分块
+		if usage := gjson.Get(chunk, "usage"); usage.IsObject() {
+			usageStr = usage.String()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var mistralUsage MistralUsage
+	usage := []byte(usageStr)
+	if json.Valid(usage) {
+		if err := json.Unmarshal(usage, &mistralUsage); err != nil {
+			return nil, nil, err
+		}
+	}
+	if mistralUsage.TotalTokens == 0 {
+		mistralUsage.TotalTokens = mistralUsage.PromptTokens + mistralUsage.CompletionTokens
+	}
+
+	chunkTime := time.Since(start) - firstChunkTime
+
+	// 构建 PromptTokensDetails JSON 字符串
+	promptTokensDetailsJSON := ""
+	cachedTokens := max(mistralUsage.NumCachedTokens, mistralUsage.PromptTokensDetails.CachedTokens)
+	if cachedTokens > 0 {
+		details := models.PromptTokensDetails{
+			CachedTokens: cachedTokens,
+		}
+		if jsonBytes, err := json.Marshal(details); err == nil {
+			promptTokensDetailsJSON = string(jsonBytes)
+		}
+	}
+
+	return &models.ChatLog{
+		FirstChunkTimeMs: int(firstChunkTime.Milliseconds()),
+		ChunkTimeMs:      int(chunkTime.Milliseconds()),
+		Usage: models.Usage{
+			PromptTokens:        mistralUsage.PromptTokens,
+			CompletionTokens:    mistralUsage.CompletionTokens,
+			TotalTokens:         mistralUsage.TotalTokens,
+			PromptTokensDetails: promptTokensDetailsJSON,
+		},
+		Tps:  float64(mistralUsage.TotalTokens) / chunkTime.Seconds(),
+		Size: size,
+	}, &output, nil
+}
+
+// processerForProvider 返回处理指定类型提供商响应的处理器：用量格式与请求风格不一致的提供商使用专用处理器
+func processerForProvider(processer Processer, providerType string) Processer {
+	if providerType == consts.StyleMistral {
+		return ProcesserMistral
+	}
+	return processer
+}
+
 func ScannerToken(reader *bufio.Scanner) iter.Seq2[string, int] {
 	return func(yield func(string, int) bool) {
 		for reader.Scan() {
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"gorm.io/gorm"
+)
+
+const (
+	// PromptTemplateHeader 按名称引用服务端提示词模板
+	PromptTemplateHeader = "X-Prompt-Template"
+	// PromptVarsHeader 模板变量，JSON 对象（如 {"lang":"zh"}）
+	PromptVarsHeader = "X-Prompt-Vars"
+)
+
+var promptVarPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// RenderPromptTemplate 将 {{var}} 替换为 vars 中的值，未提供的变量保持原样
+func RenderPromptTemplate(content string, vars map[string]string) string {
+	return promptVarPattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := promptVarPattern.FindStringSubmatch(match)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return match
+	})
+}
+
+// ApplyPromptTemplate 若请求头引用了提示词模板，则按请求风格将渲染后的模板插入到系统提示词最前面
+func ApplyPromptTemplate(ctx context.Context, header http.Header, style string, body []byte) ([]byte, error) {
+	name := strings.TrimSpace(header.Get(PromptTemplateHeader))
+	if name == "" {
+		return body, nil
+	}
+
+	vars := make(map[string]string)
+	if raw := strings.TrimSpace(header.Get(PromptVarsHeader)); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &vars); err != nil {
+			return nil, common.NewError(common.ErrCodeBadRequest, "invalid "+PromptVarsHeader+" header: "+err.Error())
+		}
+	}
+
+	template, err := promptTemplateCache.get(name, func() (*models.PromptTemplate, error) {
+		return loadPromptTemplate(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, common.NewError(common.ErrCodeNotFound, "prompt template not found: "+name)
+	}
+
+	return injectSystemPrompt(style, body, RenderPromptTemplate(template.Content, vars))
+}
+
+// promptTemplateCache 按名称缓存提示词模板，不存在的名称缓存为 nil
+var promptTemplateCache = newTTLCache[*models.PromptTemplate](configCacheTTL)
+
+// InvalidatePromptTemplateCache 模板增删改后使本实例的缓存立即失效
+func InvalidatePromptTemplateCache() {
+	promptTemplateCache.invalidateAll()
+}
+
+// loadPromptTemplate 按名称查询模板，不存在时返回 nil
+var loadPromptTemplate = func(ctx context.Context, name string) (*models.PromptTemplate, error) {
+	template, err := gorm.G[models.PromptTemplate](models.DB).Where("name = ?", name).First(ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// injectSystemPrompt 按请求风格插入系统提示词
+func injectSystemPrompt(style string, body []byte, prompt string) ([]byte, error) {
+	switch style {
+	case consts.StyleOpenAI:
+		messages := gjson.GetBytes(body, "messages").Array()
+		items := make([]json.RawMessage, 0, len(messages)+1)
+		system, err := json.Marshal(map[string]string{"role": "system", "content": prompt})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, system)
+		for _, m := range messages {
+			items = append(items, json.RawMessage(m.Raw))
+		}
+		raw, err := json.Marshal(items)
+		if err != nil {
+			return nil, err
+		}
+		return sjson.SetRawBytes(body, "messages", raw)
+	case consts.StyleOpenAIRes:
+		if existing := gjson.GetBytes(body, "instructions").String(); existing != "" {
+			prompt = prompt + "\n\n" + existing
+		}
+		return sjson.SetBytes(body, "instructions", prompt)
+	case consts.StyleAnthropic:
+		system := gjson.GetBytes(body, "system")
+		if system.IsArray() {
+			return prependRawArray(body, "system", map[string]string{"type": "text", "text": prompt}, system.Array())
+		}
+		if existing := system.String(); existing != "" {
+			prompt = prompt + "\n\n" + existing
+		}
+		return sjson.SetBytes(body, "system", prompt)
+	case consts.StyleGemini:
+		path := "systemInstruction"
+		if !gjson.GetBytes(body, path).Exists() && gjson.GetBytes(body, "system_instruction").Exists() {
+			path = "system_instruction"
+		}
+		parts := gjson.GetBytes(body, path+".parts").Array()
+		return prependRawArray(body, path+".parts", map[string]string{"text": prompt}, parts)
+	default:
+		return body, nil
+	}
+}
+
+func prependRawArray(body []byte, path string, first any, rest []gjson.Result) ([]byte, error) {
+	head, err := json.Marshal(first)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]json.RawMessage, 0, len(rest)+1)
+	items = append(items, head)
+	for _, item := range rest {
+		items = append(items, json.RawMessage(item.Raw))
+	}
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	return sjson.SetRawBytes(body, path, raw)
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+)
+
+func TestRenderPromptTemplate(t *testing.T) {
+	vars := map[string]string{"lang": "zh", "team.name": "infra", "empty": ""}
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"reply in {{lang}}", "reply in zh"},
+		{"{{ lang }}/{{lang}}", "zh/zh"},
+		{"team {{team.name}}", "team infra"},
+		{"keep {{missing}}", "keep {{missing}}"},
+		{"blank [{{empty}}]", "blank []"},
+		{"not a var {lang} {{ }}", "not a var {lang} {{ }}"},
+	}
+	for _, tt := range tests {
+		if got := RenderPromptTemplate(tt.content, vars); got != tt.want {
+			t.Errorf("RenderPromptTemplate(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestInjectSystemPrompt(t *testing.T) {
+	tests := []struct {
+		name  string
+		style string
+		body  string
+		want  string
+	}{
+		{
+			"openai",
+			consts.StyleOpenAI,
+			`{"model":"m","messages":[{"role":"user","content":"hi"}]}`,
+			`{"model":"m","messages":[{"role":"system","content":"P"},{"role":"user","content":"hi"}]}`,
+		},
+		{
+			"openai responses appends existing instructions",
+			consts.StyleOpenAIRes,
+			`{"instructions":"be brief"}`,
+			`{"instructions":"P\n\nbe brief"}`,
+		},
+		{
+			"anthropic string system",
+			consts.StyleAnthropic,
+			`{"system":"be brief","messages":[]}`,
+			`{"system":"P\n\nbe brief","messages":[]}`,
+		},
+		{
+			"anthropic block system",
+			consts.StyleAnthropic,
+			`{"system":[{"type":"text","text":"be brief"}]}`,
+			`{"system":[{"type":"text","text":"P"},{"type":"text","text":"be brief"}]}`,
+		},
+		{
+			"anthropic without system",
+			consts.StyleAnthropic,
+			`{"messages":[]}`,
+			`{"messages":[],"system":"P"}`,
+		},
+		{
+			"gemini camel",
+			consts.StyleGemini,
+			`{"systemInstruction":{"parts":[{"text":"be brief"}]}}`,
+			`{"systemInstruction":{"parts":[{"text":"P"},{"text":"be brief"}]}}`,
+		},
+		{
+			"gemini snake",
+			consts.StyleGemini,
+			`{"system_instruction":{"parts":[{"text":"be brief"}]}}`,
+			`{"system_instruction":{"parts":[{"text":"P"},{"text":"be brief"}]}}`,
+		},
+		{
+			"unknown style unchanged",
+			"other",
+			`{"a":1}`,
+			`{"a":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := injectSystemPrompt(tt.style, []byte(tt.body), "P")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var g, w any
+			if err := json.Unmarshal(got, &g); err != nil {
+				t.Fatalf("invalid json %s: %v", got, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &w); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g, w) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPromptTemplate(t *testing.T) {
+	orig := loadPromptTemplate
+	loadPromptTemplate = func(_ context.Context, name string) (*models.PromptTemplate, error) {
+		if name != "greeting" {
+			return nil, nil
+		}
+		return &models.PromptTemplate{Name: name, Content: "Answer in {{lang}}"}, nil
+	}
+	InvalidatePromptTemplateCache()
+	t.Cleanup(func() {
+		loadPromptTemplate = orig
+		InvalidatePromptTemplateCache()
+	})
+
+	body := []byte(`{"messages":[{"role":"user","content":"hi"}]}`)
+
+	t.Run("no header", func(t *testing.T) {
+		got, err := ApplyPromptTemplate(context.Background(), http.Header{}, consts.StyleOpenAI, body)
+		if err != nil || string(got) != string(body) {
+			t.Fatalf("got %s, %v; want body unchanged", got, err)
+		}
+	})
+
+	t.Run("rendered", func(t *testing.T) {
+		header := http.Header{}
+		header.Set(PromptTemplateHeader, "greeting")
+		header.Set(PromptVarsHeader, `{"lang":"zh"}`)
+		got, err := ApplyPromptTemplate(context.Background(), header, consts.StyleOpenAI, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"messages":[{"content":"Answer in zh","role":"system"},{"role":"user","content":"hi"}]}`
+		if string(got) != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("invalid vars", func(t *testing.T) {
+		header := http.Header{}
+		header.Set(PromptTemplateHeader, "greeting")
+		header.Set(PromptVarsHeader, `["zh"]`)
+		_, err := ApplyPromptTemplate(context.Background(), header, consts.StyleOpenAI, body)
+		if common.ErrorCodeOf(err) != common.ErrCodeBadRequest {
+			t.Fatalf("err = %v, want %s", err, common.ErrCodeBadRequest)
+		}
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		header := http.Header{}
+		header.Set(PromptTemplateHeader, "missing")
+		_, err := ApplyPromptTemplate(context.Background(), header, consts.StyleOpenAI, body)
+		if common.ErrorCodeOf(err) != common.ErrCodeNotFound {
+			t.Fatalf("err = %v, want %s", err, common.ErrCodeNotFound)
+		}
+	})
+}
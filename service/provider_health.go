@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/racio/llmio/balancers"
+	"github.com/racio/llmio/models"
+	"github.com/samber/lo"
+)
+
+// ModelHealthRequestBlock 模型健康请求块
+type ModelHealthRequestBlock struct {
+	Success   bool   `json:"success"`
+	Timestamp string `json:"timestamp"`
+}
+
+// ModelHealth 模型健康状态
+type ModelHealth struct {
+	ModelName         string                    `json:"modelName"`
+	ProviderModel     string                    `json:"providerModel"`
+	Status            string                    `json:"status"`
+	TotalRequests     int                       `json:"totalRequests"`
+	FailedRequests    int                       `json:"failedRequests"`
+	SuccessRate       float64                   `json:"successRate"`
+	AvgResponseTimeMs float64                   `json:"avgResponseTimeMs"`
+	LastCheck         string                    `json:"lastCheck"`
+	LastError         *string                   `json:"lastError,omitempty"`
+	Reasons           []string                  `json:"reasons"` // 状态判定原因，便于直接展示
+	RequestBlocks     []ModelHealthRequestBlock `json:"requestBlocks"`
+	BreakerState      string                    `json:"breakerState"`           // 熔断状态：closed / open / halfopen
+	BreakerUntil      *string                   `json:"breakerUntil,omitempty"` // open 状态下的冷却结束时间
+}
+
+// ProviderHealth 提供商健康状态
+type ProviderHealth struct {
+	ID             int           `json:"id"`
+	Name           string        `json:"name"`
+	Type           string        `json:"type"`
+	Status         string        `json:"status"`
+	LastCheck      string        `json:"lastCheck"`
+	ResponseTimeMs int           `json:"responseTimeMs"`
+	ErrorRate      float64       `json:"errorRate"`
+	TotalRequests  int           `json:"totalRequests"`
+	FailedRequests int           `json:"failedRequests"`
+	LastError      *string       `json:"lastError,omitempty"`
+	Reasons        []string      `json:"reasons"` // 状态判定原因，便于直接展示
+	Models         []ModelHealth `json:"models"`
+}
+
+// ProvidersHealth 全部提供商的健康汇总
+type ProvidersHealth struct {
+	Status    string           `json:"status"`
+	Total     int              `json:"total"`
+	Healthy   int              `json:"healthy"`
+	Degraded  int              `json:"degraded"`
+	Unhealthy int              `json:"unhealthy"`
+	Details   []ProviderHealth `json:"details"`
+}
+
+// CheckProvidersHealth 按最近 windowMinutes 分钟的请求日志检查提供商健康状态
+func CheckProvidersHealth(ctx context.Context, windowMinutes int) ProvidersHealth {
+	result := ProvidersHealth{
+		Status:  "healthy",
+		Details: []ProviderHealth{},
+	}
+
+	now := time.Now()
+	windowStart := now.Add(-time.Duration(windowMinutes) * time.Minute)
+
+	// 1) 一次性获取所有提供商
+	var providers []models.Provider
+	if err := models.Reader().WithContext(ctx).Where("deleted_at IS NULL").Find(&providers).Error; err != nil {
+		result.Status = "unhealthy"
+		return result
+	}
+	result.Total = len(providers)
+
+	if len(providers) == 0 {
+		return result
+	}
+
+	providerByID := make(map[uint]models.Provider, len(providers))
+	providerNames := make([]string, 0, len(providers))
+	for _, p := range providers {
+		providerByID[p.ID] = p
+		providerNames = append(providerNames, p.Name)
+	}
+
+	// 2) 一次性获取所有 model_with_providers 关联（避免 N+1）
+	var modelProviders []models.ModelWithProvider
+	if err := models.Reader().WithContext(ctx).Where("deleted_at IS NULL").Find(&modelProviders).Error; err != nil {
+		result.Status = "unhealthy"
+		return result
+	}
+
+	// 只保留 provider 存在的关联
+	filteredMP := make([]models.ModelWithProvider, 0, len(modelProviders))
+	modelIDSet := make(map[uint]struct{})
+	providerModelSet := make(map[string]struct{})
+	for _, mp := range modelProviders {
+		if _, ok := providerByID[mp.ProviderID]; !ok {
+			continue
+		}
+		filteredMP = append(filteredMP, mp)
+		modelIDSet[mp.ModelID] = struct{}{}
+		if mp.ProviderModel != "" {
+			providerModelSet[mp.ProviderModel] = struct{}{}
+		}
+	}
+
+	// 3) 一次性获取模型信息（id->name）
+	modelIDs := make([]uint, 0, len(modelIDSet))
+	for id := range modelIDSet {
+		modelIDs = append(modelIDs, id)
+	}
+	var modelList []models.Model
+	if len(modelIDs) > 0 {
+		if err := models.Reader().WithContext(ctx).Where("id IN ? AND deleted_at IS NULL", modelIDs).Find(&modelList).Error; err != nil {
+			result.Status = "unhealthy"
+			return result
+		}
+	}
+	modelNameByID := make(map[uint]string, len(modelList))
+	modelNames := make([]string, 0, len(modelList))
+	for _, m := range modelList {
+		modelNameByID[m.ID] = m.Name
+		modelNames = append(modelNames, m.Name)
+	}
+
+	providerModels := make([]string, 0, len(providerModelSet))
+	for pm := range providerModelSet {
+		providerModels = append(providerModels, pm)
+	}
+
+	// 4) 批量查询 chat_logs：用窗口函数取每组（provider_name,name,provider_model）最新 100 条
+	type logRow struct {
+		Name          string    `gorm:"column:name"`
+		ProviderName  string    `gorm:"column:provider_name"`
+		ProviderModel string    `gorm:"column:provider_model"`
+		Status        string    `gorm:"column:status"`
+		Error         string    `gorm:"column:error"`
+		ProxyTimeMs   int       `gorm:"column:proxy_time_ms"`
+		CreatedAt     time.Time `gorm:"column:created_at"`
+	}
+
+	type logKey struct {
+		providerName  string
+		modelName     string
+		providerModel string
+	}
+
+	logsByKey := make(map[logKey][]logRow)
+	if len(providerNames) > 0 && len(modelNames) > 0 && len(providerModels) > 0 {
+		sql := `
+SELECT name, provider_name, provider_model, status, error, proxy_time_ms, created_at
+FROM (
+  SELECT name, provider_name, provider_model, status, error, proxy_time_ms, created_at,
+         row_number() OVER (PARTITION BY provider_name, name, provider_model ORDER BY created_at DESC) AS rn
+  FROM chat_logs
+  WHERE created_at >= ? AND deleted_at IS NULL
+    AND provider_name IN (?)
+    AND name IN (?)
+    AND provider_model IN (?)
+) t
+WHERE rn <= 100
+`
+		var rows []logRow
+		if err := models.Reader().WithContext(ctx).Raw(sql, windowStart, providerNames, modelNames, providerModels).Scan(&rows).Error; err != nil {
+			// 日志查询失败会严重影响健康统计，直接标记为不健康
+			result.Status = "unhealthy"
+			return result
+		}
+
+		for _, r := range rows {
+			k := logKey{
+				providerName:  r.ProviderName,
+				modelName:     r.Name,
+				providerModel: r.ProviderModel,
+			}
+			logsByKey[k] = append(logsByKey[k], r)
+		}
+	}
+
+	// 5) 内存聚合生成 ProviderHealth / ModelHealth（不再逐模型查询）
+	healthByProviderID := make(map[uint]*ProviderHealth, len(providers))
+	// 提供商的 LastError 取其下各模型中最近的一条错误
+	latestErrAtByProviderID := make(map[uint]time.Time, len(providers))
+	for _, p := range providers {
+		healthByProviderID[p.ID] = &ProviderHealth{
+			ID:        int(p.ID),
+			Name:      p.Name,
+			Type:      p.Type,
+			Status:    "unknown",
+			LastCheck: now.UTC().Format(time.RFC3339),
+			Models:    []ModelHealth{},
+		}
+	}
+
+	// 熔断节点以模型 + 模型-提供商关联 ID 为 key，未出现过的关联视为 closed
+	type breakerKey struct{ model, key uint }
+	breakerByID := make(map[breakerKey]balancers.NodeSnapshot)
+	for _, node := range balancers.Snapshot() {
+		breakerByID[breakerKey{node.ModelID, node.Key}] = node
+	}
+
+	for _, mp := range filteredMP {
+		p := providerByID[mp.ProviderID]
+		modelName := modelNameByID[mp.ModelID]
+		if modelName == "" {
+			// 模型不存在/已删除：跳过
+			continue
+		}
+
+		k := logKey{
+			providerName:  p.Name,
+			modelName:     modelName,
+			providerModel: mp.ProviderModel,
+		}
+		rows := logsByKey[k]
+
+		modelHealth := ModelHealth{
+			ModelName:     modelName,
+			ProviderModel: mp.ProviderModel,
+			Status:        "unknown",
+			LastCheck:     now.UTC().Format(time.RFC3339),
+			RequestBlocks: []ModelHealthRequestBlock{},
+		}
+
+		modelHealth.TotalRequests = len(rows)
+		totalResponseTime := 0.0
+		var latestErrAt time.Time
+		var latestErr string
+
+		// blocks 需要从旧到新
+		if len(rows) > 0 {
+			slices.SortFunc(rows, func(a, b logRow) int {
+				if a.CreatedAt.Before(b.CreatedAt) {
+					return -1
+				}
+				if a.CreatedAt.After(b.CreatedAt) {
+					return 1
+				}
+				return 0
+			})
+		}
+
+		for _, r := range rows {
+			isSuccess := r.Status == "success"
+			if !isSuccess {
+				modelHealth.FailedRequests++
+				if latestErrAt.IsZero() || r.CreatedAt.After(latestErrAt) {
+					latestErrAt = r.CreatedAt
+					latestErr = r.Error
+				}
+			}
+			modelHealth.RequestBlocks = append(modelHealth.RequestBlocks, ModelHealthRequestBlock{
+				Success:   isSuccess,
+				Timestamp: r.CreatedAt.UTC().Format(time.RFC3339),
+			})
+			if r.ProxyTimeMs > 0 {
+				totalResponseTime += float64(r.ProxyTimeMs)
+			}
+		}
+
+		if modelHealth.TotalRequests > 0 {
+			modelHealth.SuccessRate = float64(modelHealth.TotalRequests-modelHealth.FailedRequests) / float64(modelHealth.TotalRequests) * 100
+			modelHealth.AvgResponseTimeMs = totalResponseTime / float64(modelHealth.TotalRequests)
+		}
+		modelHealth.Status, modelHealth.Reasons = modelHealthStatus(modelHealth.TotalRequests, modelHealth.SuccessRate, modelHealth.AvgResponseTimeMs)
+		modelHealth.BreakerState = balancers.StateClosed.String()
+		if node, ok := breakerByID[breakerKey{mp.ModelID, mp.ID}]; ok {
+			modelHealth.BreakerState = node.State
+			if node.Expiry != nil {
+				modelHealth.BreakerUntil = lo.ToPtr(node.Expiry.UTC().Format(time.RFC3339))
+			}
+		}
+		if latestErrAt.IsZero() == false && latestErr != "" {
+			modelHealth.LastError = lo.ToPtr(latestErr)
+		}
+
+		ph := healthByProviderID[mp.ProviderID]
+		ph.Models = append(ph.Models, modelHealth)
+		ph.TotalRequests += modelHealth.TotalRequests
+		ph.FailedRequests += modelHealth.FailedRequests
+		if modelHealth.LastError != nil && latestErrAt.After(latestErrAtByProviderID[mp.ProviderID]) {
+			latestErrAtByProviderID[mp.ProviderID] = latestErrAt
+			ph.LastError = modelHealth.LastError
+		}
+	}
+
+	// 6) 计算每个 provider 的整体状态
+	for _, p := range providers {
+		ph := healthByProviderID[p.ID]
+
+		// 平均响应时间：取有请求的模型的平均值
+		totalAvg := 0.0
+		count := 0
+		for _, mh := range ph.Models {
+			if mh.TotalRequests > 0 {
+				totalAvg += mh.AvgResponseTimeMs
+				count++
+			}
+		}
+		if count > 0 {
+			ph.ResponseTimeMs = int(totalAvg / float64(count))
+		}
+
+		if ph.TotalRequests > 0 {
+			ph.ErrorRate = float64(ph.FailedRequests) / float64(ph.TotalRequests) * 100
+		}
+
+		ph.Status, ph.Reasons = providerHealthStatus(ph.TotalRequests, ph.ErrorRate, ph.ResponseTimeMs)
+
+		// 让模型列表输出更稳定（按 modelName+providerModel 排序）
+		slices.SortFunc(ph.Models, func(a, b ModelHealth) int {
+			if a.ModelName != b.ModelName {
+				return strings.Compare(a.ModelName, b.ModelName)
+			}
+			return strings.Compare(a.ProviderModel, b.ProviderModel)
+		})
+
+		result.Details = append(result.Details, *ph)
+		switch ph.Status {
+		case "healthy":
+			result.Healthy++
+		case "degraded":
+			result.Degraded++
+		case "unhealthy":
+			result.Unhealthy++
+		}
+	}
+
+	// 确定整体提供商状态
+	if result.Unhealthy > 0 {
+		if result.Unhealthy >= result.Total/2 {
+			result.Status = "unhealthy"
+		} else {
+			result.Status = "degraded"
+		}
+	} else if result.Degraded > 0 {
+		result.Status = "degraded"
+	}
+
+	return result
+}
+
+// modelHealthStatus 按成功率/平均延迟阈值判定模型状态，并给出触发的原因
+func modelHealthStatus(total int, successRate float64, avgResponseTimeMs float64) (string, []string) {
+	reasons := []string{}
+	if total == 0 {
+		return "unknown", append(reasons, "no requests in window")
+	}
+	if successRate < 50 {
+		return "unhealthy", append(reasons, fmt.Sprintf("success rate %.0f%% < 50%%", successRate))
+	}
+	if successRate < 90 {
+		reasons = append(reasons, fmt.Sprintf("success rate %.0f%% < 90%%", successRate))
+	}
+	if avgResponseTimeMs > 10000 {
+		reasons = append(reasons, fmt.Sprintf("avg latency %.1fs > 10s", avgResponseTimeMs/1000))
+	}
+	if len(reasons) > 0 {
+		return "degraded", reasons
+	}
+	return "healthy", reasons
+}
+
+// providerHealthStatus 按错误率/平均延迟阈值判定提供商状态，并给出触发的原因
+func providerHealthStatus(total int, errorRate float64, responseTimeMs int) (string, []string) {
+	reasons := []string{}
+	if total == 0 {
+		return "unknown", append(reasons, "no requests in window")
+	}
+	if errorRate > 50 {
+		return "unhealthy", append(reasons, fmt.Sprintf("error rate %.0f%% > 50%%", errorRate))
+	}
+	if errorRate > 10 {
+		reasons = append(reasons, fmt.Sprintf("error rate %.0f%% > 10%%", errorRate))
+	}
+	if responseTimeMs > 5000 {
+		reasons = append(reasons, fmt.Sprintf("avg latency %.1fs > 5s", float64(responseTimeMs)/1000))
+	}
+	if len(reasons) > 0 {
+		return "degraded", reasons
+	}
+	return "healthy", reasons
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/racio/llmio/models"
+)
+
+const (
+	defaultProviderHealthAlertIntervalSeconds = 60
+	defaultProviderHealthAlertWindowMinutes   = 15
+	defaultProviderHealthAlertDebounceMinutes = 10
+	EventProviderHealthChanged                = "provider_health_changed"
+)
+
+// providerHealthSource 返回最近 windowMinutes 分钟内各提供商的健康状态
+type providerHealthSource func(ctx context.Context, windowMinutes int) ProvidersHealth
+
+// ProviderHealthAlert 提供商健康状态变化的通知内容
+type ProviderHealthAlert struct {
+	ProviderID     int      `json:"provider_id"`
+	Provider       string   `json:"provider"`
+	OldStatus      string   `json:"old_status"`
+	NewStatus      string   `json:"new_status"`
+	ErrorRate      float64  `json:"error_rate"`
+	TotalRequests  int      `json:"total_requests"`
+	FailedRequests int      `json:"failed_requests"`
+	Reasons        []string `json:"reasons"`
+	SampleError    string   `json:"sample_error,omitempty"`
+	WindowMinutes  int      `json:"window_minutes"`
+}
+
+// providerHealthState 提供商最近一次通知（或首次观察）时的状态
+type providerHealthState struct {
+	status     string
+	notifiedAt time.Time
+}
+
+// providerHealthAlerter 定期汇总提供商健康状态，在 healthy/degraded/unhealthy 之间变化时发送 webhook
+// 同一提供商在防抖期内不重复通知，期间状态又恢复原样的抖动不会产生通知
+type providerHealthAlerter struct {
+	source providerHealthSource
+	notify func(ctx context.Context, cfg models.ProviderHealthAlertConfig, message string, alert ProviderHealthAlert) error
+
+	mu     sync.Mutex
+	states map[int]providerHealthState
+}
+
+// StartProviderHealthAlert 启动提供商健康状态变化通知的后台任务
+func StartProviderHealthAlert(ctx context.Context) {
+	alerter := &providerHealthAlerter{
+		source: CheckProvidersHealth,
+		notify: notifyProviderHealth,
+		states: make(map[int]providerHealthState),
+	}
+	go alerter.loop(ctx)
+}
+
+func (a *providerHealthAlerter) loop(ctx context.Context) {
+	interval := time.Duration(defaultProviderHealthAlertIntervalSeconds) * time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		cfg, ok, err := loadProviderHealthAlertConfig(ctx)
+		if err != nil {
+			slog.Error("读取提供商健康告警配置失败", "error", err)
+			continue
+		}
+		interval = time.Duration(cfg.IntervalSeconds) * time.Second
+		if !ok || !cfg.Enabled {
+			// 关闭期间不保留状态，重新开启后以当时的状态为基准
+			a.reset()
+			continue
+		}
+		a.check(ctx, cfg, time.Now())
+	}
+}
+
+// ValidateProviderHealthAlertConfig 校验提供商健康告警配置
+func ValidateProviderHealthAlertConfig(cfg models.ProviderHealthAlertConfig) error {
+	if cfg.IntervalSeconds < 0 || cfg.WindowMinutes < 0 || cfg.DebounceMinutes < 0 {
+		return errors.New("interval_seconds, window_minutes and debounce_minutes must not be negative")
+	}
+	if raw := strings.TrimSpace(cfg.URL); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url must be an http(s) URL: %q", raw)
+		}
+	}
+	return nil
+}
+
+func loadProviderHealthAlertConfig(ctx context.Context) (models.ProviderHealthAlertConfig, bool, error) {
+	var cfg models.ProviderHealthAlertConfig
+	ok, err := loadJSONConfig(ctx, models.KeyProviderHealthAlert, &cfg)
+	if err == nil {
+		err = ValidateProviderHealthAlertConfig(cfg)
+	}
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = defaultProviderHealthAlertIntervalSeconds
+	}
+	if cfg.WindowMinutes <= 0 {
+		cfg.WindowMinutes = defaultProviderHealthAlertWindowMinutes
+	}
+	if cfg.DebounceMinutes <= 0 {
+		cfg.DebounceMinutes = defaultProviderHealthAlertDebounceMinutes
+	}
+	return cfg, ok, err
+}
+
+func (a *providerHealthAlerter) reset() {
+	a.mu.Lock()
+	clear(a.states)
+	a.mu.Unlock()
+}
+
+func (a *providerHealthAlerter) check(ctx context.Context, cfg models.ProviderHealthAlertConfig, now time.Time) {
+	health := a.source(ctx, cfg.WindowMinutes)
+	if len(health.Details) == 0 {
+		// 没有提供商或查询失败，保留已有状态
+		return
+	}
+	debounce := time.Duration(cfg.DebounceMinutes) * time.Minute
+
+	seen := make(map[int]struct{}, len(health.Details))
+	for _, ph := range health.Details {
+		seen[ph.ID] = struct{}{}
+		// 窗口内没有请求（unknown）时无法判断，保持上一次的状态
+		if ph.Status != "healthy" && ph.Status != "degraded" && ph.Status != "unhealthy" {
+			continue
+		}
+
+		a.mu.Lock()
+		state, known := a.states[ph.ID]
+		if !known {
+			// 首次观察只记录基准，避免重启后对已有状态重复通知
+			a.states[ph.ID] = providerHealthState{status: ph.Status}
+			a.mu.Unlock()
+			continue
+		}
+		if state.status == ph.Status || now.Sub(state.notifiedAt) < debounce {
+			a.mu.Unlock()
+			continue
+		}
+		a.states[ph.ID] = providerHealthState{status: ph.Status, notifiedAt: now}
+		a.mu.Unlock()
+
+		alert := ProviderHealthAlert{
+			ProviderID:     ph.ID,
+			Provider:       ph.Name,
+			OldStatus:      state.status,
+			NewStatus:      ph.Status,
+			ErrorRate:      ph.ErrorRate,
+			TotalRequests:  ph.TotalRequests,
+			FailedRequests: ph.FailedRequests,
+			Reasons:        ph.Reasons,
+			WindowMinutes:  cfg.WindowMinutes,
+		}
+		if ph.LastError != nil {
+			alert.SampleError = *ph.LastError
+		}
+		message := fmt.Sprintf("provider %s health changed from %s to %s (error rate %.1f%% in last %d minutes)", ph.Name, state.status, ph.Status, ph.ErrorRate, cfg.WindowMinutes)
+		slog.Warn("provider health changed", "provider", ph.Name, "old_status", state.status, "new_status", ph.Status, "error_rate", ph.ErrorRate)
+		if err := a.notify(ctx, cfg, message, alert); err != nil {
+			slog.Error("发送提供商健康告警失败", "provider", ph.Name, "error", err)
+		}
+	}
+
+	// 已删除的提供商不再跟踪
+	a.mu.Lock()
+	for id := range a.states {
+		if _, ok := seen[id]; !ok {
+			delete(a.states, id)
+		}
+	}
+	a.mu.Unlock()
+}
+
+// notifyProviderHealth 配置了 url 时发送到该地址，否则按 webhook_notifier 配置发送
+func notifyProviderHealth(ctx context.Context, cfg models.ProviderHealthAlertConfig, message string, alert ProviderHealthAlert) error {
+	if target := strings.TrimSpace(cfg.URL); target != "" {
+		return postWebhook(ctx, target, cfg.Headers, EventProviderHealthChanged, message, alert)
+	}
+	return Notify(ctx, EventProviderHealthChanged, message, alert)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModelHealthStatusReasons(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       int
+		successRate float64
+		latencyMs   float64
+		status      string
+		reasons     []string
+	}{
+		{"no requests", 0, 0, 0, "unknown", []string{"no requests in window"}},
+		{"healthy", 100, 99, 800, "healthy", []string{}},
+		{"low success", 100, 40, 800, "unhealthy", []string{"success rate 40% < 50%"}},
+		{"degraded success", 100, 80, 800, "degraded", []string{"success rate 80% < 90%"}},
+		{"slow", 100, 95, 12000, "degraded", []string{"avg latency 12.0s > 10s"}},
+		{"degraded and slow", 100, 85, 15500, "degraded", []string{"success rate 85% < 90%", "avg latency 15.5s > 10s"}},
+		{"unhealthy ignores latency", 100, 10, 15500, "unhealthy", []string{"success rate 10% < 50%"}},
+		{"boundary", 100, 90, 10000, "healthy", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reasons := modelHealthStatus(tt.total, tt.successRate, tt.latencyMs)
+			if status != tt.status || !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Fatalf("got %s %q, want %s %q", status, reasons, tt.status, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestProviderHealthStatusReasons(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int
+		errorRate float64
+		latencyMs int
+		status    string
+		reasons   []string
+	}{
+		{"no requests", 0, 0, 0, "unknown", []string{"no requests in window"}},
+		{"healthy", 100, 1, 800, "healthy", []string{}},
+		{"high errors", 100, 60, 800, "unhealthy", []string{"error rate 60% > 50%"}},
+		{"some errors", 100, 20, 800, "degraded", []string{"error rate 20% > 10%"}},
+		{"slow", 100, 0, 6500, "degraded", []string{"avg latency 6.5s > 5s"}},
+		{"errors and slow", 100, 15, 7000, "degraded", []string{"error rate 15% > 10%", "avg latency 7.0s > 5s"}},
+		{"boundary", 100, 10, 5000, "healthy", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reasons := providerHealthStatus(tt.total, tt.errorRate, tt.latencyMs)
+			if status != tt.status || !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Fatalf("got %s %q, want %s %q", status, reasons, tt.status, tt.reasons)
+			}
+		})
+	}
+}
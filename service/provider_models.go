@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/providers"
+)
+
+const defaultProviderModelsCacheTTL = 5 * time.Minute
+
+// ProviderModelsResult 上游模型列表及其拉取时间，Cached 表示结果来自缓存
+type ProviderModelsResult struct {
+	Models    []providers.Model `json:"models"`
+	FetchedAt time.Time         `json:"fetched_at"`
+	Cached    bool              `json:"cached"`
+}
+
+// providerModelsEntry 缓存内容，Redis 中以 JSON 存储
+type providerModelsEntry struct {
+	Models    []providers.Model `json:"models"`
+	FetchedAt time.Time         `json:"fetched_at"`
+	expiry    time.Time
+}
+
+var (
+	providerModelsMu     sync.Mutex
+	providerModelsMemory = make(map[uint]providerModelsEntry) // 未启用 Redis 时的模型列表缓存
+)
+
+// providerModelsCacheTTL 上游模型列表缓存时长，0 表示不缓存：
+// 优先使用配置 provider_models_cache，其次 PROVIDER_MODELS_CACHE_TTL_SECONDS，默认 5 分钟
+func providerModelsCacheTTL(ctx context.Context) time.Duration {
+	var cfg models.ProviderModelsCacheConfig
+	ok, err := loadJSONConfig(ctx, models.KeyProviderModelsCache, &cfg)
+	if err != nil {
+		slog.Warn("load provider models cache config failed", "error", err)
+	}
+	if ok && cfg.TTLSeconds >= 0 {
+		return time.Duration(cfg.TTLSeconds) * time.Second
+	}
+	if v := os.Getenv("PROVIDER_MODELS_CACHE_TTL_SECONDS"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultProviderModelsCacheTTL
+}
+
+func providerModelsKey(providerID uint) string {
+	return fmt.Sprintf("provider_models:%d", providerID)
+}
+
+// GetProviderModels 获取提供商上游的模型列表：缓存未过期时直接返回，refresh 为 true 时强制从上游拉取
+func GetProviderModels(ctx context.Context, provider models.Provider, refresh bool) (*ProviderModelsResult, error) {
+	ttl := providerModelsCacheTTL(ctx)
+	if ttl > 0 && !refresh {
+		if cached, ok := loadProviderModels(ctx, provider.ID); ok {
+			return &ProviderModelsResult{Models: cached.Models, FetchedAt: cached.FetchedAt, Cached: true}, nil
+		}
+	}
+
+	chatModel, err := providers.New(provider.Type, provider.Config)
+	if err != nil {
+		return nil, err
+	}
+	list, err := chatModel.Models(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry := providerModelsEntry{Models: list, FetchedAt: time.Now()}
+	if ttl > 0 {
+		storeProviderModels(ctx, provider.ID, entry, ttl)
+	}
+	return &ProviderModelsResult{Models: list, FetchedAt: entry.FetchedAt}, nil
+}
+
+// InvalidateProviderModels 提供商配置变更或删除后清除其模型列表缓存
+func InvalidateProviderModels(ctx context.Context, providerID uint) {
+	if rdb := GetRedisClient(); rdb != nil {
+		if err := rdb.Del(ctx, providerModelsKey(providerID)).Err(); err != nil {
+			slog.Warn("invalidate provider models cache failed", "provider_id", providerID, "error", err)
+		}
+		return
+	}
+	providerModelsMu.Lock()
+	delete(providerModelsMemory, providerID)
+	providerModelsMu.Unlock()
+}
+
+func loadProviderModels(ctx context.Context, providerID uint) (providerModelsEntry, bool) {
+	if rdb := GetRedisClient(); rdb != nil {
+		data, err := rdb.Get(ctx, providerModelsKey(providerID)).Bytes()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				slog.Warn("load provider models cache failed", "provider_id", providerID, "error", err)
+			}
+			return providerModelsEntry{}, false
+		}
+		var entry providerModelsEntry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.FetchedAt.IsZero() {
+			return providerModelsEntry{}, false
+		}
+		return entry, true
+	}
+	providerModelsMu.Lock()
+	defer providerModelsMu.Unlock()
+	entry, ok := providerModelsMemory[providerID]
+	if !ok || time.Now().After(entry.expiry) {
+		return providerModelsEntry{}, false
+	}
+	return entry, true
+}
+
+func storeProviderModels(ctx context.Context, providerID uint, entry providerModelsEntry, ttl time.Duration) {
+	if rdb := GetRedisClient(); rdb != nil {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		if err := rdb.Set(ctx, providerModelsKey(providerID), data, ttl).Err(); err != nil {
+			slog.Warn("store provider models cache failed", "provider_id", providerID, "error", err)
+		}
+		return
+	}
+	entry.expiry = time.Now().Add(ttl)
+	providerModelsMu.Lock()
+	providerModelsMemory[providerID] = entry
+	providerModelsMu.Unlock()
+}
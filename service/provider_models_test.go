@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+)
+
+// newModelsProvider 构造一个 openai 提供商，hits 记录上游 /models 被调用的次数
+func newModelsProvider(t *testing.T, id uint) (models.Provider, *atomic.Int64) {
+	t.Helper()
+	// 使用默认缓存时长，不受运行环境影响
+	t.Setenv("PROVIDER_MODELS_CACHE_TTL_SECONDS", "")
+	hits := new(atomic.Int64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o","object":"model","owned_by":"openai"}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { InvalidateProviderModels(context.Background(), id) })
+
+	provider := models.Provider{Name: "p", Type: consts.StyleOpenAI, Config: `{"base_url":"` + srv.URL + `/v1","api_key":"k"}`}
+	provider.ID = id
+	return provider, hits
+}
+
+func TestGetProviderModelsCache(t *testing.T) {
+	stubConfig(t, nil)
+	ctx := context.Background()
+	provider, hits := newModelsProvider(t, 9700)
+
+	first, err := GetProviderModels(ctx, provider, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Cached || len(first.Models) != 1 || first.Models[0].ID != "gpt-4o" {
+		t.Fatalf("first = %+v", first)
+	}
+
+	second, err := GetProviderModels(ctx, provider, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.Cached || !second.FetchedAt.Equal(first.FetchedAt) || hits.Load() != 1 {
+		t.Fatalf("second = %+v, hits %d; want cache hit", second, hits.Load())
+	}
+
+	// refresh 强制拉取并更新缓存
+	refreshed, err := GetProviderModels(ctx, provider, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Cached || hits.Load() != 2 {
+		t.Fatalf("refreshed = %+v, hits %d; want upstream fetch", refreshed, hits.Load())
+	}
+	if again, _ := GetProviderModels(ctx, provider, false); !again.Cached || !again.FetchedAt.Equal(refreshed.FetchedAt) {
+		t.Fatalf("after refresh = %+v, want refreshed entry cached", again)
+	}
+
+	// 提供商配置变更后缓存失效
+	InvalidateProviderModels(ctx, provider.ID)
+	if after, _ := GetProviderModels(ctx, provider, false); after.Cached || hits.Load() != 3 {
+		t.Fatalf("after invalidate = %+v, hits %d", after, hits.Load())
+	}
+}
+
+func TestGetProviderModelsCacheExpiry(t *testing.T) {
+	stubConfig(t, nil)
+	ctx := context.Background()
+	provider, hits := newModelsProvider(t, 9701)
+
+	if _, err := GetProviderModels(ctx, provider, false); err != nil {
+		t.Fatal(err)
+	}
+	providerModelsMu.Lock()
+	entry := providerModelsMemory[provider.ID]
+	entry.expiry = time.Now().Add(-time.Second)
+	providerModelsMemory[provider.ID] = entry
+	providerModelsMu.Unlock()
+
+	if res, _ := GetProviderModels(ctx, provider, false); res.Cached || hits.Load() != 2 {
+		t.Fatalf("expired entry served: %+v, hits %d", res, hits.Load())
+	}
+}
+
+func TestGetProviderModelsCacheDisabled(t *testing.T) {
+	stubConfig(t, map[string]string{models.KeyProviderModelsCache: `{"ttl_seconds":0}`})
+	ctx := context.Background()
+	provider, hits := newModelsProvider(t, 9702)
+
+	for range 2 {
+		res, err := GetProviderModels(ctx, provider, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Cached {
+			t.Fatal("cache disabled but result cached")
+		}
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("hits = %d, want 2", hits.Load())
+	}
+}
+
+func TestProviderModelsCacheTTL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Setenv("PROVIDER_MODELS_CACHE_TTL_SECONDS", "")
+	stubConfig(t, nil)
+	if got := providerModelsCacheTTL(ctx); got != defaultProviderModelsCacheTTL {
+		t.Fatalf("default = %v", got)
+	}
+
+	t.Setenv("PROVIDER_MODELS_CACHE_TTL_SECONDS", "30")
+	if got := providerModelsCacheTTL(ctx); got != 30*time.Second {
+		t.Fatalf("env = %v", got)
+	}
+
+	// 配置优先于环境变量
+	stubConfig(t, map[string]string{models.KeyProviderModelsCache: `{"ttl_seconds":90}`})
+	if got := providerModelsCacheTTL(ctx); got != 90*time.Second {
+		t.Fatalf("config = %v", got)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+const providerScheduleSweepInterval = time.Minute
+
+// quotaExhaustedMarkers 上游错误响应中表示额度（而非瞬时限流）耗尽的关键字，按小写匹配
+var quotaExhaustedMarkers = []string{
+	"insufficient_quota",
+	"quota_exceeded",
+	"exceeded your current quota",
+	"daily limit",
+	"resource_exhausted",
+}
+
+// quotaResetHeaders 上游返回额度重置时间的响应头，按顺序取第一个可解析的值
+var quotaResetHeaders = []string{
+	"X-RateLimit-Reset",
+	"X-RateLimit-Reset-Requests",
+	"Anthropic-RateLimit-Requests-Reset",
+	"Anthropic-RateLimit-Tokens-Reset",
+	"Retry-After",
+}
+
+// quotaResetTime 上游返回额度耗尽错误且带有重置时间时，返回该提供商应停用到的时间
+func quotaResetTime(res *http.Response, body []byte, now time.Time) (time.Time, bool) {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusForbidden {
+		return time.Time{}, false
+	}
+	lower := bytes.ToLower(body)
+	exhausted := false
+	for _, marker := range quotaExhaustedMarkers {
+		if bytes.Contains(lower, []byte(marker)) {
+			exhausted = true
+			break
+		}
+	}
+	if !exhausted {
+		return time.Time{}, false
+	}
+	for _, header := range quotaResetHeaders {
+		if reset, ok := parseResetTime(res.Header.Get(header), now); ok {
+			return reset, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseResetTime 解析重置时间：Unix 时间戳、RFC3339/HTTP-date、秒数或 Go duration（如 "6m0s"），已过去的时间视为无效
+func parseResetTime(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	var reset time.Time
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// 大于 10^9 的整数按 Unix 时间戳处理，否则按秒数
+		if n > 1_000_000_000 {
+			reset = time.Unix(n, 0)
+		} else {
+			reset = now.Add(time.Duration(n) * time.Second)
+		}
+	} else if t, err := time.Parse(time.RFC3339, value); err == nil {
+		reset = t
+	} else if t, err := http.ParseTime(value); err == nil {
+		reset = t
+	} else if d, err := time.ParseDuration(value); err == nil {
+		reset = now.Add(d)
+	} else {
+		return time.Time{}, false
+	}
+	if !reset.After(now) {
+		return time.Time{}, false
+	}
+	return reset, true
+}
+
+// DisableProviderUntil 在指定时间前停用提供商，until 为 nil 时立即恢复
+func DisableProviderUntil(ctx context.Context, providerID uint, until *time.Time) error {
+	_, err := gorm.G[models.Provider](models.DB).Where("id = ?", providerID).Update(ctx, "disabled_until", until)
+	return err
+}
+
+// scheduledProviders 查询指定 ID 与类型中当前未停用（disabled_until 为空或已过期）的提供商
+func scheduledProviders(ctx context.Context, ids []uint, types []string, now time.Time) ([]models.Provider, error) {
+	return gorm.G[models.Provider](models.DB).
+		Where("id IN ?", ids).
+		Where("type IN ?", types).
+		Where("disabled_until IS NULL OR disabled_until <= ?", now).
+		Find(ctx)
+}
+
+// clearExpiredProviderSchedules 清除已过期的停用时间，返回恢复的提供商数量
+func clearExpiredProviderSchedules(ctx context.Context, now time.Time) (int, error) {
+	return gorm.G[models.Provider](models.DB).Where("disabled_until <= ?", now).Update(ctx, "disabled_until", nil)
+}
+
+// StartProviderScheduleSweeper 后台定期清除已过期的提供商停用时间
+func StartProviderScheduleSweeper(ctx context.Context) {
+	go providerScheduleSweepLoop(ctx)
+}
+
+func providerScheduleSweepLoop(ctx context.Context) {
+	ticker := time.NewTicker(providerScheduleSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		restored, err := clearExpiredProviderSchedules(ctx, time.Now())
+		if err != nil {
+			slog.Warn("clear expired provider disabled_until failed", "error", err)
+			continue
+		}
+		if restored > 0 {
+			slog.Info("Providers re-enabled after disabled_until passed", "count", restored)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+)
+
+func TestParseResetTime(t *testing.T) {
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"seconds", "3600", now.Add(time.Hour), true},
+		{"unix timestamp", "1792231200", time.Unix(1792231200, 0), true},
+		{"rfc3339", "2026-10-18T00:00:00Z", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), true},
+		{"http date", "Sun, 18 Oct 2026 00:00:00 GMT", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), true},
+		{"duration", "6m0s", now.Add(6 * time.Minute), true},
+		{"empty", "", time.Time{}, false},
+		{"garbage", "tomorrow", time.Time{}, false},
+		{"past", "2026-10-16T00:00:00Z", time.Time{}, false},
+		{"zero seconds", "0", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseResetTime(tt.value, now)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Fatalf("parseResetTime(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestQuotaResetTime(t *testing.T) {
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	quotaBody := []byte(`{"error":{"code":"insufficient_quota","message":"You exceeded your current quota"}}`)
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		body   []byte
+		want   time.Time
+		ok     bool
+	}{
+		{"quota with reset header", http.StatusTooManyRequests, map[string]string{"X-RateLimit-Reset": "3600"}, quotaBody, now.Add(time.Hour), true},
+		{"forbidden quota", http.StatusForbidden, map[string]string{"Retry-After": "60"}, []byte(`{"error":{"status":"RESOURCE_EXHAUSTED"}}`), now.Add(time.Minute), true},
+		{"header order", http.StatusTooManyRequests, map[string]string{"X-RateLimit-Reset": "120", "Retry-After": "60"}, quotaBody, now.Add(2 * time.Minute), true},
+		{"quota without reset", http.StatusTooManyRequests, nil, quotaBody, time.Time{}, false},
+		// 普通限流交给 Retry-After 冷却处理，不停用提供商
+		{"rate limit only", http.StatusTooManyRequests, map[string]string{"Retry-After": "60"}, []byte(`{"error":"rate limited"}`), time.Time{}, false},
+		{"server error", http.StatusInternalServerError, map[string]string{"Retry-After": "60"}, quotaBody, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for k, v := range tt.header {
+				res.Header.Set(k, v)
+			}
+			got, ok := quotaResetTime(res, tt.body, now)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Fatalf("quotaResetTime = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestScheduledProvidersExcludesDisabled(t *testing.T) {
+	statements := captureSQL(t)
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	if _, err := scheduledProviders(context.Background(), []uint{1, 2}, []string{consts.StyleOpenAI}, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clearExpiredProviderSchedules(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+
+	got := statements()
+	if len(got) != 2 {
+		t.Fatalf("statements = %q", got)
+	}
+	for _, want := range []string{`FROM "providers"`, "id IN (1,2)", "disabled_until IS NULL OR disabled_until <= '2026-10-17 08:00:00"} {
+		if !strings.Contains(got[0], want) {
+			t.Fatalf("select %q missing %q", got[0], want)
+		}
+	}
+	// 过期的停用时间自动清除
+	for _, want := range []string{`UPDATE "providers" SET "disabled_until"=NULL`, "disabled_until <= '2026-10-17 08:00:00"} {
+		if !strings.Contains(got[1], want) {
+			t.Fatalf("sweep %q missing %q", got[1], want)
+		}
+	}
+}
+
+func TestBalanceChatModelDisablesProviderOnQuotaExhausted(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		body         string
+		wantDisabled bool
+	}{
+		{"quota exhausted with reset", "3600", `{"error":{"code":"insufficient_quota"}}`, true},
+		// 没有重置时间时按普通 429 在同一提供商内重试
+		{"quota exhausted without reset", "", `{"error":{"code":"insufficient_quota"}}`, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := captureSQL(t)
+			var hits atomic.Int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				hits.Add(1)
+				if tt.header != "" {
+					w.Header().Set("X-RateLimit-Reset", tt.header)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			id := uint(9700 + i)
+			meta, _ := newTestProviders(t, id, http.StatusOK)
+			provider := meta.ProviderMap[id]
+			provider.Config = `{"base_url":"` + srv.URL + `/v1","api_key":"k"}`
+			meta.ProviderMap[id] = provider
+
+			before := Before{Model: "gpt-4o", raw: []byte(`{"model":"gpt-4o","messages":[]}`)}
+			if _, _, err := balanceChatModel(nil, time.Now(), consts.StyleOpenAI, before, meta, models.ReqMeta{}, false); err == nil {
+				t.Fatal("expected error when the only provider is out of quota")
+			}
+			// 停用后不再在同一提供商内重试
+			got := hits.Load()
+			if tt.wantDisabled && got != 1 || !tt.wantDisabled && got < 2 {
+				t.Fatalf("upstream hits = %d, disabled %v", got, tt.wantDisabled)
+			}
+			waitChatLogs(t, statements, int(got)+1)
+
+			disabled := false
+			for _, stmt := range statements() {
+				if strings.HasPrefix(stmt, `UPDATE "providers" SET "disabled_until"=`) && strings.Contains(stmt, fmt.Sprintf("id = %d", id)) {
+					disabled = true
+				}
+			}
+			if disabled != tt.wantDisabled {
+				t.Fatalf("provider disabled = %v, want %v; statements %q", disabled, tt.wantDisabled, statements())
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/racio/llmio/consts"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// reasoningEfforts OpenAI 推理强度，按从低到高排列
+var reasoningEfforts = []string{"none", "minimal", "low", "medium", "high", "xhigh"}
+
+// ReasoningLimits 模型的推理用量上限，各项为 0/空表示不限制
+type ReasoningLimits struct {
+	MaxThinkingTokens   int    // Anthropic thinking.budget_tokens 上限
+	MaxReasoningEffort  string // OpenAI 推理强度上限
+	MaxCompletionTokens int    // OpenAI max_completion_tokens（Responses API 为 max_output_tokens）上限
+}
+
+// ValidReasoningEffort 校验推理强度上限配置，空字符串表示不限制
+func ValidReasoningEffort(effort string) bool {
+	return effort == "" || slices.Contains(reasoningEfforts, effort)
+}
+
+// clampReasoning 将客户端请求的推理预算压到模型配置的上限以内，返回处理后的请求体及被压低的字段
+func clampReasoning(style string, raw []byte, limits ReasoningLimits) ([]byte, map[string]any, error) {
+	type clampField struct {
+		path  string
+		limit int
+	}
+	var tokenFields []clampField
+	var effortPath string
+	switch style {
+	case consts.StyleAnthropic:
+		tokenFields = []clampField{{"thinking.budget_tokens", limits.MaxThinkingTokens}}
+	case consts.StyleOpenAI:
+		tokenFields = []clampField{{"max_completion_tokens", limits.MaxCompletionTokens}}
+		effortPath = "reasoning_effort"
+	case consts.StyleOpenAIRes:
+		tokenFields = []clampField{{"max_output_tokens", limits.MaxCompletionTokens}}
+		effortPath = "reasoning.effort"
+	default:
+		return raw, nil, nil
+	}
+
+	clamped := make(map[string]any)
+	var err error
+	for _, field := range tokenFields {
+		value := gjson.GetBytes(raw, field.path)
+		if field.limit <= 0 || value.Type != gjson.Number || value.Int() <= int64(field.limit) {
+			continue
+		}
+		if raw, err = sjson.SetBytes(raw, field.path, field.limit); err != nil {
+			return nil, nil, err
+		}
+		clamped[field.path] = value.Int()
+	}
+	if effortPath != "" && limits.MaxReasoningEffort != "" {
+		effort := gjson.GetBytes(raw, effortPath).String()
+		// 未识别的推理强度原样转发，由上游校验
+		if current, limit := slices.Index(reasoningEfforts, effort), slices.Index(reasoningEfforts, limits.MaxReasoningEffort); current >= 0 && limit >= 0 && current > limit {
+			if raw, err = sjson.SetBytes(raw, effortPath, limits.MaxReasoningEffort); err != nil {
+				return nil, nil, err
+			}
+			clamped[effortPath] = effort
+		}
+	}
+	return raw, clamped, nil
+}
+
+// applyReasoningLimits 转发前按模型配置压低推理预算，压低时记录日志与时间线；处理失败时原样转发
+func applyReasoningLimits(ctx context.Context, style string, before *Before, limits ReasoningLimits) {
+	body, clamped, err := clampReasoning(style, before.raw, limits)
+	if err != nil {
+		slog.Warn("clamp reasoning budget error", "model", before.Model, "error", err)
+		return
+	}
+	if len(clamped) == 0 {
+		return
+	}
+	before.raw = body
+	slog.Info("reasoning budget clamped", "model", before.Model, "requested", clamped)
+	RecordTimeline(ctx, "reasoning_clamped", clamped)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+	"github.com/tidwall/gjson"
+)
+
+// anthropicThinkingBody 与后台提供商测试使用的 Anthropic 请求体一致，客户端请求了接近 max_tokens 的思考预算
+const anthropicThinkingBody = `{
+	"model": "claude-sonnet-4-5",
+	"messages": [{"role": "user", "content": [{"type": "text", "text": "Please reply me yes or no"}]}],
+	"max_tokens": 32000,
+	"thinking": {"budget_tokens": 31999, "type": "enabled"},
+	"stream": true
+}`
+
+func TestClampReasoning(t *testing.T) {
+	tests := []struct {
+		name        string
+		style       string
+		body        string
+		limits      ReasoningLimits
+		want        map[string]any // 路径 -> 处理后的值
+		wantClamped map[string]any
+	}{
+		{
+			name:        "anthropic budget clamped",
+			style:       consts.StyleAnthropic,
+			body:        anthropicThinkingBody,
+			limits:      ReasoningLimits{MaxThinkingTokens: 8000},
+			want:        map[string]any{"thinking.budget_tokens": int64(8000), "max_tokens": int64(32000)},
+			wantClamped: map[string]any{"thinking.budget_tokens": int64(31999)},
+		},
+		{
+			name:   "anthropic budget within limit",
+			style:  consts.StyleAnthropic,
+			body:   anthropicThinkingBody,
+			limits: ReasoningLimits{MaxThinkingTokens: 40000},
+			want:   map[string]any{"thinking.budget_tokens": int64(31999)},
+		},
+		{
+			name:   "anthropic unlimited",
+			style:  consts.StyleAnthropic,
+			body:   anthropicThinkingBody,
+			limits: ReasoningLimits{MaxReasoningEffort: "low", MaxCompletionTokens: 100},
+			want:   map[string]any{"thinking.budget_tokens": int64(31999), "max_tokens": int64(32000)},
+		},
+		{
+			name:        "openai effort and completion tokens",
+			style:       consts.StyleOpenAI,
+			body:        `{"model":"o3","messages":[],"reasoning_effort":"high","max_completion_tokens":64000}`,
+			limits:      ReasoningLimits{MaxReasoningEffort: "medium", MaxCompletionTokens: 16000},
+			want:        map[string]any{"reasoning_effort": "medium", "max_completion_tokens": int64(16000)},
+			wantClamped: map[string]any{"reasoning_effort": "high", "max_completion_tokens": int64(64000)},
+		},
+		{
+			name:   "openai lower effort kept",
+			style:  consts.StyleOpenAI,
+			body:   `{"model":"o3","messages":[],"reasoning_effort":"low","max_completion_tokens":1000}`,
+			limits: ReasoningLimits{MaxReasoningEffort: "medium", MaxCompletionTokens: 16000},
+			want:   map[string]any{"reasoning_effort": "low", "max_completion_tokens": int64(1000)},
+		},
+		{
+			name:   "openai unknown effort forwarded",
+			style:  consts.StyleOpenAI,
+			body:   `{"model":"o3","messages":[],"reasoning_effort":"extreme"}`,
+			limits: ReasoningLimits{MaxReasoningEffort: "low"},
+			want:   map[string]any{"reasoning_effort": "extreme"},
+		},
+		{
+			name:        "responses api",
+			style:       consts.StyleOpenAIRes,
+			body:        `{"model":"o3","input":"hi","reasoning":{"effort":"xhigh"},"max_output_tokens":50000}`,
+			limits:      ReasoningLimits{MaxReasoningEffort: "high", MaxCompletionTokens: 20000},
+			want:        map[string]any{"reasoning.effort": "high", "max_output_tokens": int64(20000)},
+			wantClamped: map[string]any{"reasoning.effort": "xhigh", "max_output_tokens": int64(50000)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, clamped, err := clampReasoning(tt.style, []byte(tt.body), tt.limits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for path, want := range tt.want {
+				if got := gjson.GetBytes(body, path).Value(); normalizeJSONNumber(got) != want {
+					t.Fatalf("%s = %v, want %v; body %s", path, got, want, body)
+				}
+			}
+			if !maps.Equal(clamped, tt.wantClamped) {
+				t.Fatalf("clamped = %v, want %v", clamped, tt.wantClamped)
+			}
+			if len(tt.wantClamped) == 0 && string(body) != tt.body {
+				t.Fatalf("body changed without clamping: %s", body)
+			}
+		})
+	}
+}
+
+// normalizeJSONNumber gjson 将数字解析为 float64，整数统一转为 int64 便于比较
+func normalizeJSONNumber(v any) any {
+	if f, ok := v.(float64); ok && f == float64(int64(f)) {
+		return int64(f)
+	}
+	return v
+}
+
+func TestApplyReasoningLimits(t *testing.T) {
+	before := Before{Model: "claude-sonnet-4-5", raw: []byte(anthropicThinkingBody)}
+	applyReasoningLimits(context.Background(), consts.StyleAnthropic, &before, ReasoningLimits{MaxThinkingTokens: 4096})
+	if got := gjson.GetBytes(before.raw, "thinking.budget_tokens").Int(); got != 4096 {
+		t.Fatalf("budget_tokens = %d, want 4096", got)
+	}
+
+	// 其他风格不做处理
+	gemini := Before{Model: "gemini-2.5-pro", raw: []byte(`{"generationConfig":{"thinkingConfig":{"thinkingBudget":30000}}}`)}
+	applyReasoningLimits(context.Background(), consts.StyleGemini, &gemini, ReasoningLimits{MaxThinkingTokens: 4096})
+	if got := gjson.GetBytes(gemini.raw, "generationConfig.thinkingConfig.thinkingBudget").Int(); got != 30000 {
+		t.Fatalf("gemini thinkingBudget = %d, want unchanged", got)
+	}
+}
+
+func TestValidReasoningEffort(t *testing.T) {
+	for _, effort := range []string{"", "none", "minimal", "low", "medium", "high", "xhigh"} {
+		if !ValidReasoningEffort(effort) {
+			t.Fatalf("%q should be valid", effort)
+		}
+	}
+	for _, effort := range []string{"HIGH", "max", " low"} {
+		if ValidReasoningEffort(effort) {
+			t.Fatalf("%q should be invalid", effort)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+// ResourceKind 受数量上限约束的资源类型
+type ResourceKind string
+
+const (
+	ResourceAuthKeys  ResourceKind = "auth keys"
+	ResourceProviders ResourceKind = "providers"
+	ResourceModels    ResourceKind = "models"
+)
+
+// CheckResourceLimit 按配置 resource_limits 检查是否还能再创建（或启用）一个资源，未配置或上限 <= 0 时不限制；
+// API Key 只统计启用中的，提供商与模型统计未删除的全部记录
+func CheckResourceLimit(ctx context.Context, kind ResourceKind) error {
+	return CheckResourceLimitTx(ctx, models.DB, kind)
+}
+
+// CheckResourceLimitTx 同 CheckResourceLimit，在指定事务内计数，使事务中尚未提交的新建记录也计入上限
+func CheckResourceLimitTx(ctx context.Context, db *gorm.DB, kind ResourceKind) error {
+	var cfg models.ResourceLimitsConfig
+	ok, err := loadJSONConfig(ctx, models.KeyResourceLimits, &cfg)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	var limit int
+	var count int64
+	switch kind {
+	case ResourceAuthKeys:
+		if limit = cfg.MaxAuthKeys; limit > 0 {
+			count, err = gorm.G[models.AuthKey](db).Where("status = ?", 1).Count(ctx, "id")
+		}
+	case ResourceProviders:
+		if limit = cfg.MaxProviders; limit > 0 {
+			count, err = gorm.G[models.Provider](db).Count(ctx, "id")
+		}
+	case ResourceModels:
+		if limit = cfg.MaxModels; limit > 0 {
+			count, err = gorm.G[models.Model](db).Count(ctx, "id")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if limit > 0 && count >= int64(limit) {
+		return common.NewError(common.ErrCodeResourceLimit, fmt.Sprintf("maximum number of %s reached (limit %d)", kind, limit))
+	}
+	return nil
+}
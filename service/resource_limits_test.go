@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/racio/llmio/common"
+	"github.com/racio/llmio/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// stubCounts 将 models.DB 替换为不连接数据库的会话，Count 查询按表名返回 counts 中的值
+func stubCounts(t *testing.T, counts map[string]int64) {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1"), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Callback().Query().Replace("gorm:query", func(tx *gorm.DB) {
+		if dst, ok := tx.Statement.Dest.(*int64); ok {
+			*dst = counts[tx.Statement.Table]
+			tx.RowsAffected = 1
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = orig })
+}
+
+func TestCheckResourceLimit(t *testing.T) {
+	stubConfig(t, map[string]string{models.KeyResourceLimits: `{"max_auth_keys":2,"max_providers":3,"max_models":1}`})
+
+	tests := []struct {
+		kind    ResourceKind
+		table   string
+		count   int64
+		wantErr bool
+	}{
+		{ResourceAuthKeys, "auth_keys", 1, false},
+		{ResourceAuthKeys, "auth_keys", 2, true},
+		{ResourceProviders, "providers", 2, false},
+		{ResourceProviders, "providers", 3, true},
+		{ResourceModels, "models", 0, false},
+		{ResourceModels, "models", 1, true},
+	}
+	for _, tt := range tests {
+		stubCounts(t, map[string]int64{tt.table: tt.count})
+		err := CheckResourceLimit(context.Background(), tt.kind)
+		if !tt.wantErr {
+			// 创建后恰好达到上限，允许
+			if err != nil {
+				t.Errorf("%s with %d existing: err = %v, want nil", tt.kind, tt.count, err)
+			}
+			continue
+		}
+		if common.ErrorCodeOf(err) != common.ErrCodeResourceLimit {
+			t.Errorf("%s with %d existing: err = %v, want %s", tt.kind, tt.count, err, common.ErrCodeResourceLimit)
+		}
+	}
+}
+
+func TestCheckResourceLimitUnlimited(t *testing.T) {
+	stubCounts(t, map[string]int64{"auth_keys": 100, "providers": 100, "models": 100})
+	for _, config := range []string{"", `{"max_auth_keys":0,"max_providers":-1}`} {
+		stubConfig(t, map[string]string{models.KeyResourceLimits: config})
+		for _, kind := range []ResourceKind{ResourceAuthKeys, ResourceProviders, ResourceModels} {
+			if err := CheckResourceLimit(context.Background(), kind); err != nil {
+				t.Errorf("config %q %s: err = %v, want nil", config, kind, err)
+			}
+		}
+	}
+}
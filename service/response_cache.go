@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// ResponseCacheHeader 命中响应缓存时返回 X-Llmio-Cache: HIT
+	ResponseCacheHeader = "X-Llmio-Cache"
+	// MaxCachedResponseSize 超过该大小的响应不缓存
+	MaxCachedResponseSize = 1 << 20
+)
+
+// CachedResponse 缓存的非流式响应
+type CachedResponse struct {
+	Header        http.Header `json:"header"`
+	Body          []byte      `json:"body"`
+	ProviderName  string      `json:"provider_name,omitempty"` // 产生该响应的提供商，命中时记入日志
+	ProviderModel string      `json:"provider_model,omitempty"`
+}
+
+// ResponseCacheKey 按接口风格、模型名与规范化后的请求体生成缓存键：
+// 请求体经解析后重新序列化（对象键有序、去除空白），字段顺序与格式不同的相同请求命中同一缓存
+func ResponseCacheKey(style string, model string, body []byte) (string, error) {
+	var parsed any
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(parsed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return fmt.Sprintf("response_cache:%s:%s:%s", style, model, hex.EncodeToString(sum[:])), nil
+}
+
+// GetCachedResponse 读取缓存；未启用 Redis 或未命中时返回 nil
+func GetCachedResponse(ctx context.Context, key string) (*CachedResponse, error) {
+	rdb := GetRedisClient()
+	if rdb == nil {
+		return nil, nil
+	}
+	data, err := rdb.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp CachedResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SaveCachedResponse 保存响应，未启用 Redis 时为空操作
+func SaveCachedResponse(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	rdb := GetRedisClient()
+	if rdb == nil || ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return rdb.Set(ctx, key, data, ttl).Err()
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"github.com/racio/llmio/providers"
+	"gorm.io/gorm"
+)
+
+// DispatchShadow 在正式响应完成后，按采样率异步向影子提供商复制请求
+// 影子请求的响应内容被丢弃，仅记录耗时/用量/状态/费用到 shadow_logs
+func DispatchShadow(ctx context.Context, style string, before Before, providersWithMeta *ProvidersWithMeta, reqMeta models.ReqMeta, processer Processer) {
+	if len(providersWithMeta.ShadowItems) == 0 {
+		return
+	}
+	// 保留请求上下文中的值（auth key、Gemini method 等），但不随客户端请求取消
+	shadowCtx := context.WithoutCancel(ctx)
+	for _, id := range providersWithMeta.ShadowItems {
+		modelWithProvider, ok := providersWithMeta.ModelWithProviderMap[id]
+		if !ok {
+			continue
+		}
+		if modelWithProvider.ShadowRate <= 0 || rand.Float64() >= modelWithProvider.ShadowRate {
+			continue
+		}
+		provider, ok := providersWithMeta.ProviderMap[modelWithProvider.ProviderID]
+		if !ok {
+			continue
+		}
+		go func() {
+			log := shadowChat(shadowCtx, style, before, modelWithProvider, provider, providersWithMeta.TimeOut, reqMeta, processer)
+			if err := gorm.G[models.ShadowLog](models.DB).Create(shadowCtx, &log); err != nil {
+				slog.Error("save shadow log error", "error", err)
+			}
+		}()
+	}
+}
+
+// shadowChat 向单个影子提供商发送请求并读取完整响应
+func shadowChat(ctx context.Context, style string, before Before, modelWithProvider models.ModelWithProvider, provider models.Provider, timeout int, reqMeta models.ReqMeta, processer Processer) models.ShadowLog {
+	authKeyID, _ := ctx.Value(consts.ContextKeyAuthKeyID).(uint)
+	log := models.ShadowLog{
+		Name:          before.Model,
+		ProviderModel: modelWithProvider.ProviderModel,
+		ProviderName:  provider.Name,
+		Status:        "success",
+		Style:         style,
+		AuthKeyID:     authKeyID,
+	}
+	withError := func(err error) models.ShadowLog {
+		log.Status = "error"
+		log.Error = err.Error()
+		return log
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(timeout))
+	defer cancel()
+
+	chatModel, err := providers.New(provider.Type, provider.Config)
+	if err != nil {
+		return withError(err)
+	}
+
+	customHeaders := make(map[string]string)
+	if modelWithProvider.CustomerHeaders != "" {
+		if err := json.Unmarshal([]byte(modelWithProvider.CustomerHeaders), &customHeaders); err != nil {
+			slog.Error("parse custom headers error", "error", err)
+		}
+	}
+	header := BuildHeaders(reqMeta.Header, modelWithProvider.WithHeader == 1, customHeaders, before.Stream)
+
+	start := time.Now()
+	reqBody, err := upstreamRequestBody(style, provider.Type, before.raw)
+	if err != nil {
+		return withError(err)
+	}
+	req, err := chatModel.BuildReq(ctx, header, modelWithProvider.ProviderModel, reqBody)
+	if err != nil {
+		return withError(err)
+	}
+
+	client := providers.GetClient(time.Second * time.Duration(timeout))
+	res, err := client.Do(req)
+	if err != nil {
+		return withError(err)
+	}
+	defer res.Body.Close()
+	log.ProxyTimeMs = int(time.Since(start).Milliseconds())
+
+	if !IsSuccessStatus(provider.SuccessStatusCodes, res.StatusCode) {
+		byteBody, _ := io.ReadAll(res.Body)
+		return withError(fmt.Errorf("status: %d, body: %s", res.StatusCode, safeBodyTextForLog(res, byteBody)))
+	}
+
+	translateBedrockResponse(res, provider.Type, before.Stream)
+	if translatesToAnthropic(style, provider.Type) {
+		if err := translateAnthropicResponse(res, before.Stream); err != nil {
+			return withError(err)
+		}
+	}
+	chatLog, _, err := processerForProvider(processer, provider.Type)(ctx, res.Body, before.Stream, start)
+	if err != nil {
+		return withError(err)
+	}
+	log.FirstChunkTimeMs = chatLog.FirstChunkTimeMs
+	log.ChunkTimeMs = chatLog.ChunkTimeMs
+	log.Tps = chatLog.Tps
+	log.Size = chatLog.Size
+	log.Usage = chatLog.Usage
+	log.TotalCost = calculateTotalCost(ctx, before.Model, log.Usage)
+	return log
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/racio/llmio/models"
+)
+
+const (
+	SLOMetricFirstChunk = "first_chunk"
+	SLOMetricProxy      = "proxy"
+
+	sloAlertInterval              = time.Minute
+	defaultSLOAlertWindowMinutes  = 15
+	defaultSLOAlertSustainMinutes = 5
+	MaxSLOWindowMinutes           = 7 * 24 * 60
+	EventSLOBreach                = "slo_breach"
+)
+
+var sloMetricColumns = map[string]string{
+	SLOMetricFirstChunk: "first_chunk_time_ms",
+	SLOMetricProxy:      "proxy_time_ms",
+}
+
+// SLOReport 单个模型在窗口内的 SLO 达成情况
+type SLOReport struct {
+	Model       string  `json:"model"`
+	Metric      string  `json:"metric"`
+	Percentile  float64 `json:"percentile"`
+	ThresholdMs int     `json:"threshold_ms"`
+	ValueMs     float64 `json:"value_ms"`   // 窗口内实际百分位耗时
+	Total       int64   `json:"total"`      // 窗口内成功请求数
+	Violations  int64   `json:"violations"` // 超过阈值的请求数
+	Compliance  float64 `json:"compliance"` // 未超阈值请求占比
+	Compliant   bool    `json:"compliant"`  // 百分位耗时是否达标
+}
+
+// ValidateSLOConfig 校验 SLO 配置
+func ValidateSLOConfig(cfg models.SLOConfig) error {
+	if cfg.AlertWindowMinutes < 0 || cfg.AlertWindowMinutes > MaxSLOWindowMinutes {
+		return fmt.Errorf("alert_window_minutes must be between 0 and %d", MaxSLOWindowMinutes)
+	}
+	if cfg.AlertSustainMinutes < 0 {
+		return errors.New("alert_sustain_minutes must not be negative")
+	}
+	for model, target := range cfg.Targets {
+		if _, ok := sloMetricColumns[target.Metric]; !ok {
+			return fmt.Errorf("model %s: metric must be %s or %s", model, SLOMetricFirstChunk, SLOMetricProxy)
+		}
+		if target.Percentile <= 0 || target.Percentile >= 100 {
+			return fmt.Errorf("model %s: percentile must be between 0 and 100", model)
+		}
+		if target.ThresholdMs <= 0 {
+			return fmt.Errorf("model %s: threshold_ms must be positive", model)
+		}
+	}
+	return nil
+}
+
+// LoadSLOConfig 读取并校验 SLO 配置，未配置时返回空配置
+func LoadSLOConfig(ctx context.Context) (models.SLOConfig, error) {
+	var cfg models.SLOConfig
+	if _, err := loadJSONConfig(ctx, models.KeySLO, &cfg); err != nil {
+		return cfg, err
+	}
+	if err := ValidateSLOConfig(cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// ComputeSLO 计算 since 之后各模型的 SLO 达成情况，按模型名排序
+func ComputeSLO(ctx context.Context, targets map[string]models.SLOTarget, since time.Time) ([]SLOReport, error) {
+	reports := make([]SLOReport, 0, len(targets))
+	for model, target := range targets {
+		column, ok := sloMetricColumns[target.Metric]
+		if !ok {
+			continue
+		}
+		var row struct {
+			Total      int64
+			Violations int64
+			Value      float64
+		}
+		query := fmt.Sprintf(`SELECT COUNT(*) AS total,
+	COUNT(*) FILTER (WHERE %[1]s > ?) AS violations,
+	COALESCE(percentile_cont(?) WITHIN GROUP (ORDER BY %[1]s), 0) AS value
+FROM chat_logs
+WHERE deleted_at IS NULL AND status = 'success' AND name = ? AND created_at >= ?`, column)
+		if err := models.Reader().WithContext(ctx).Raw(query, target.ThresholdMs, target.Percentile/100, model, since).Scan(&row).Error; err != nil {
+			return nil, err
+		}
+
+		report := SLOReport{
+			Model:       model,
+			Metric:      target.Metric,
+			Percentile:  target.Percentile,
+			ThresholdMs: target.ThresholdMs,
+			ValueMs:     row.Value,
+			Total:       row.Total,
+			Violations:  row.Violations,
+			Compliance:  1,
+			Compliant:   true,
+		}
+		if row.Total > 0 {
+			report.Compliance = float64(row.Total-row.Violations) / float64(row.Total)
+			report.Compliant = row.Value <= float64(target.ThresholdMs)
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Model < reports[j].Model })
+	return reports, nil
+}
+
+// StartSLOAlert 启动 SLO 持续违约告警任务
+func StartSLOAlert(ctx context.Context) {
+	go sloAlertLoop(ctx)
+}
+
+func sloAlertLoop(ctx context.Context) {
+	ticker := time.NewTicker(sloAlertInterval)
+	defer ticker.Stop()
+	// 模型 -> 开始违约时间；告警后标记，恢复达标前不重复告警
+	breachSince := make(map[string]time.Time)
+	notified := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg, err := LoadSLOConfig(ctx)
+		if err != nil {
+			slog.Error("读取 SLO 配置失败", "error", err)
+			continue
+		}
+		if !cfg.Enabled || len(cfg.Targets) == 0 {
+			continue
+		}
+		window := cfg.AlertWindowMinutes
+		if window <= 0 {
+			window = defaultSLOAlertWindowMinutes
+		}
+		sustain := time.Duration(cfg.AlertSustainMinutes) * time.Minute
+		if sustain <= 0 {
+			sustain = defaultSLOAlertSustainMinutes * time.Minute
+		}
+
+		now := time.Now()
+		reports, err := ComputeSLO(ctx, cfg.Targets, now.Add(-time.Duration(window)*time.Minute))
+		if err != nil {
+			slog.Error("计算 SLO 失败", "error", err)
+			continue
+		}
+		for _, report := range reports {
+			if report.Compliant {
+				delete(breachSince, report.Model)
+				delete(notified, report.Model)
+				continue
+			}
+			since, ok := breachSince[report.Model]
+			if !ok {
+				breachSince[report.Model] = now
+				since = now
+			}
+			if notified[report.Model] || now.Sub(since) < sustain {
+				continue
+			}
+			notified[report.Model] = true
+			message := fmt.Sprintf("model %s p%g %s %.0fms exceeds SLO %dms for %s", report.Model, report.Percentile, report.Metric, report.ValueMs, report.ThresholdMs, now.Sub(since).Round(time.Minute))
+			slog.Warn("slo breach", "model", report.Model, "value_ms", report.ValueMs, "threshold_ms", report.ThresholdMs)
+			if err := Notify(ctx, EventSLOBreach, message, report); err != nil {
+				slog.Error("发送 SLO 告警失败", "model", report.Model, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/racio/llmio/models"
+)
+
+// ApplyGlobalSystemPrompt 按请求风格将全局系统提示词插入到系统提示词最前面（位于提示词模板与客户端系统提示词之前），
+// 模型或 API Key 在豁免列表中时不注入；返回注入后的请求体，同时更新 before 中转发使用的原始请求体
+func ApplyGlobalSystemPrompt(ctx context.Context, style string, before *Before, authKeyID uint) ([]byte, error) {
+	var cfg models.GlobalSystemPromptConfig
+	ok, err := loadJSONConfig(ctx, models.KeyGlobalSystemPrompt, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	prompt := strings.TrimSpace(cfg.Prompt)
+	if !ok || !cfg.Enabled || prompt == "" {
+		return before.raw, nil
+	}
+	if matchModelPattern(cfg.ExemptModels, before.Model) || (authKeyID != 0 && slices.Contains(cfg.ExemptAuthKeyIDs, authKeyID)) {
+		return before.raw, nil
+	}
+
+	body, err := injectSystemPrompt(style, before.raw, prompt)
+	if err != nil {
+		return nil, err
+	}
+	before.raw = body
+	return body, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+)
+
+func TestApplyGlobalSystemPrompt(t *testing.T) {
+	orig := loadPromptTemplate
+	loadPromptTemplate = func(_ context.Context, name string) (*models.PromptTemplate, error) {
+		return &models.PromptTemplate{Name: name, Content: "T"}, nil
+	}
+	InvalidatePromptTemplateCache()
+	t.Cleanup(func() {
+		loadPromptTemplate = orig
+		InvalidatePromptTemplateCache()
+	})
+	stubConfig(t, map[string]string{
+		models.KeyGlobalSystemPrompt: `{"enabled":true,"prompt":" G ","exempt_models":["exempt-*"],"exempt_auth_key_ids":[7]}`,
+	})
+	header := http.Header{}
+	header.Set(PromptTemplateHeader, "tpl")
+
+	// 组合顺序：全局 → 模板 → 客户端
+	tests := []struct {
+		name  string
+		style string
+		body  string
+		want  string
+	}{
+		{
+			"openai",
+			consts.StyleOpenAI,
+			`{"model":"m","messages":[{"role":"system","content":"C"},{"role":"user","content":"hi"}]}`,
+			`{"model":"m","messages":[{"role":"system","content":"G"},{"role":"system","content":"T"},{"role":"system","content":"C"},{"role":"user","content":"hi"}]}`,
+		},
+		{
+			"openai responses",
+			consts.StyleOpenAIRes,
+			`{"model":"m","instructions":"C","input":"hi"}`,
+			`{"model":"m","instructions":"G\n\nT\n\nC","input":"hi"}`,
+		},
+		{
+			"anthropic string system",
+			consts.StyleAnthropic,
+			`{"model":"m","system":"C","messages":[]}`,
+			`{"model":"m","system":"G\n\nT\n\nC","messages":[]}`,
+		},
+		{
+			"anthropic block system",
+			consts.StyleAnthropic,
+			`{"model":"m","system":[{"type":"text","text":"C"}],"messages":[]}`,
+			`{"model":"m","system":[{"type":"text","text":"G"},{"type":"text","text":"T"},{"type":"text","text":"C"}],"messages":[]}`,
+		},
+		{
+			"gemini",
+			consts.StyleGemini,
+			`{"systemInstruction":{"parts":[{"text":"C"}]},"contents":[]}`,
+			`{"systemInstruction":{"parts":[{"text":"G"},{"text":"T"},{"text":"C"}]},"contents":[]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := ApplyPromptTemplate(context.Background(), header, tt.style, []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := &Before{Model: "m", raw: body}
+			got, err := ApplyGlobalSystemPrompt(context.Background(), tt.style, before, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var g, w any
+			if err := json.Unmarshal(got, &g); err != nil {
+				t.Fatalf("invalid json %s: %v", got, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &w); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g, w) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+			if string(before.raw) != string(got) {
+				t.Fatalf("before.raw = %s, want %s", before.raw, got)
+			}
+		})
+	}
+}
+
+func TestApplyGlobalSystemPromptSkipped(t *testing.T) {
+	body := `{"model":"m","messages":[{"role":"system","content":"C"}]}`
+	tests := []struct {
+		name      string
+		config    string
+		model     string
+		authKeyID uint
+	}{
+		{"not configured", "", "m", 1},
+		{"disabled", `{"enabled":false,"prompt":"G"}`, "m", 1},
+		{"empty prompt", `{"enabled":true,"prompt":"  "}`, "m", 1},
+		{"exempt model", `{"enabled":true,"prompt":"G","exempt_models":["exempt-*"]}`, "exempt-1", 1},
+		{"exempt auth key", `{"enabled":true,"prompt":"G","exempt_auth_key_ids":[7]}`, "m", 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubConfig(t, map[string]string{models.KeyGlobalSystemPrompt: tt.config})
+			before := &Before{Model: tt.model, raw: []byte(body)}
+			got, err := ApplyGlobalSystemPrompt(context.Background(), consts.StyleOpenAI, before, tt.authKeyID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != body || string(before.raw) != body {
+				t.Fatalf("got %s, before.raw %s; want body unchanged", got, before.raw)
+			}
+		})
+	}
+}
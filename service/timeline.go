@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/racio/llmio/consts"
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+// maxTimelineEvents 单个请求最多保留的事件数，超出后丢弃最早的事件
+const maxTimelineEvents = 64
+
+// TimelineEvent 请求生命周期中的一个事件
+type TimelineEvent struct {
+	OffsetMs int64          `json:"offset_ms"` // 相对请求接收时间的偏移（毫秒）
+	Event    string         `json:"event"`
+	Attrs    map[string]any `json:"attrs,omitempty"`
+}
+
+// Timeline 单个请求的结构化生命周期时间线，可并发写入
+type Timeline struct {
+	mu     sync.Mutex
+	start  time.Time
+	events []TimelineEvent
+}
+
+// StartTimeline 按 request_timeline 配置的采样率决定是否为请求开启时间线，开启时挂到返回的 context 上
+func StartTimeline(ctx context.Context) context.Context {
+	var cfg models.RequestTimelineConfig
+	ok, err := loadJSONConfig(ctx, models.KeyRequestTimeline, &cfg)
+	if err != nil {
+		slog.Warn("load request timeline config error", "error", err)
+		return ctx
+	}
+	if !ok || !cfg.Enabled || cfg.SampleRate <= 0 || rand.Float64() >= cfg.SampleRate {
+		return ctx
+	}
+	return context.WithValue(ctx, consts.ContextKeyTimeline, &Timeline{start: time.Now()})
+}
+
+// TimelineFromContext 取出请求的时间线，未采样时返回 nil
+func TimelineFromContext(ctx context.Context) *Timeline {
+	tl, _ := ctx.Value(consts.ContextKeyTimeline).(*Timeline)
+	return tl
+}
+
+// WithTimeline 将时间线挂到另一个 context 上（用于脱离请求生命周期的异步处理）
+func WithTimeline(ctx context.Context, tl *Timeline) context.Context {
+	if tl == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, consts.ContextKeyTimeline, tl)
+}
+
+// RecordTimeline 向请求时间线追加事件，未采样时为空操作
+func RecordTimeline(ctx context.Context, event string, attrs map[string]any) {
+	if tl := TimelineFromContext(ctx); tl != nil {
+		tl.Record(event, attrs)
+	}
+}
+
+// Record 追加事件
+func (t *Timeline) Record(event string, attrs map[string]any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.events) >= maxTimelineEvents {
+		t.events = t.events[1:]
+	}
+	t.events = append(t.events, TimelineEvent{
+		OffsetMs: time.Since(t.start).Milliseconds(),
+		Event:    event,
+		Attrs:    attrs,
+	})
+}
+
+// MarshalJSON 序列化为事件数组
+func (t *Timeline) MarshalJSON() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.Marshal(t.events)
+}
+
+// saveTimeline 将时间线写入对应的请求日志
+func saveTimeline(ctx context.Context, logId uint, tl *Timeline) {
+	if tl == nil {
+		return
+	}
+	data, err := json.Marshal(tl)
+	if err != nil {
+		slog.Error("marshal request timeline error", "error", err)
+		return
+	}
+	if _, err := gorm.G[models.ChatLog](models.DB).Where("id = ?", logId).Update(ctx, "timeline", string(data)); err != nil {
+		slog.Error("save request timeline error", "error", err)
+	}
+}
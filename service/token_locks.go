@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/racio/llmio/limiter"
+)
+
+// ListTokenLocks 列出当前生效的 token 锁
+func ListTokenLocks(ctx context.Context) ([]limiter.TokenLockInfo, error) {
+	if globalLimiterManager == nil {
+		return []limiter.TokenLockInfo{}, nil
+	}
+	return globalLimiterManager.ListTokenLocks(ctx)
+}
+
+// ClearTokenLock 清除指定 model_with_provider 的 token 锁
+func ClearTokenLock(ctx context.Context, modelWithProviderID uint) error {
+	if globalLimiterManager == nil {
+		return nil
+	}
+	return globalLimiterManager.ClearTokenLock(ctx, modelWithProviderID)
+}
+
+// ClearAllTokenLocks 清除全部 token 锁，返回清除数量
+func ClearAllTokenLocks(ctx context.Context) (int, error) {
+	if globalLimiterManager == nil {
+		return 0, nil
+	}
+	return globalLimiterManager.ClearAllTokenLocks(ctx)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/racio/llmio/models"
+	"gorm.io/gorm"
+)
+
+// usageLogLine 单次请求的用量事件，字段与 OpenAI Usage API 的 completions 结果对齐，便于成本工具直接解析
+type usageLogLine struct {
+	Object            string      `json:"object"`
+	StartTime         int64       `json:"start_time"`
+	EndTime           int64       `json:"end_time"`
+	Model             string      `json:"model"`
+	ProviderModel     string      `json:"provider_model"`
+	InputTokens       int64       `json:"input_tokens"`
+	OutputTokens      int64       `json:"output_tokens"`
+	InputCachedTokens int64       `json:"input_cached_tokens"`
+	NumModelRequests  int         `json:"num_model_requests"`
+	APIKeyID          *string     `json:"api_key_id"`
+	Amount            usageAmount `json:"amount"`
+}
+
+type usageAmount struct {
+	Value    float64 `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+// usageLogEnabled 是否开启 stdout 用量日志（USAGE_LOG_STDOUT=true）
+func usageLogEnabled() bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("USAGE_LOG_STDOUT")))
+	return err == nil && v
+}
+
+// emitUsageLog 按 OpenAI usage 格式向 stdout 输出一行 JSON，用量取自已落库的请求日志，未开启时为空操作
+func emitUsageLog(ctx context.Context, start time.Time, logId uint) {
+	if !usageLogEnabled() {
+		return
+	}
+	log, err := gorm.G[models.ChatLog](models.DB).Where("id = ?", logId).First(ctx)
+	if err != nil {
+		slog.Error("load chat log for usage log error", "error", err)
+		return
+	}
+	line := usageLogLine{
+		Object:            "organization.usage.completions.result",
+		StartTime:         start.Unix(),
+		EndTime:           time.Now().Unix(),
+		Model:             log.Name,
+		ProviderModel:     log.ProviderModel,
+		InputTokens:       log.PromptTokens,
+		OutputTokens:      log.CompletionTokens,
+		InputCachedTokens: parseCachedTokens(log.PromptTokensDetails),
+		NumModelRequests:  1,
+		Amount:            usageAmount{Value: log.TotalCost, Currency: "usd"},
+	}
+	if log.AuthKeyID != 0 {
+		id := strconv.FormatUint(uint64(log.AuthKeyID), 10)
+		line.APIKeyID = &id
+	}
+	// Encoder 单次 Write 输出整行，多个请求并发写入时不会交错
+	if err := json.NewEncoder(os.Stdout).Encode(line); err != nil {
+		slog.Error("write usage log error", "error", err)
+	}
+}
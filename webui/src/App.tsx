@@ -4,6 +4,7 @@ import { ThemeProvider } from "@/components/theme-provider"
 import { SnowProvider } from "@/components/snow-effect"
 import Loading from "@/components/loading"
 import { Toaster } from './components/ui/sonner';
+import { BASE_PATH } from './lib/base-path';
 
 // 懒加载路由组件
 const Layout = lazy(() => import('./routes/layout'));
@@ -28,7 +29,7 @@ function App() {
   return (
     <ThemeProvider defaultTheme="system" storageKey="vite-ui-theme">
       <SnowProvider>
-        <Router>
+        <Router basename={BASE_PATH || undefined}>
           <Suspense fallback={<PageLoader />}>
             <Routes>
               <Route path="/login" element={<LoginPage />} />
@@ -1,6 +1,8 @@
 // API client for interacting with the backend
 
-const API_BASE = '/api';
+import { withBasePath } from './base-path';
+
+const API_BASE = withBasePath('/api');
 
 export interface Provider {
   ID: number;
@@ -9,7 +11,11 @@ export interface Provider {
   Config: string;
   Console: string;
   RpmLimit: number; // 每分钟请求数限制，0 表示无限制
+  TpmLimit: number; // 每分钟 token 数限制，0 表示无限制
+  MaxConcurrency: number; // 同时在途请求数上限，0 表示无限制
+  SuccessStatusCodes: string; // 视为成功的上游状态码（逗号分隔），空表示仅 200
   IpLockMinutes: number; // IP 锁定时间（分钟），0 表示不锁定
+  DisabledUntil?: string | null; // 在此时间前不参与路由，为空表示未停用
 }
 
 export interface Model {
@@ -23,6 +29,23 @@ export interface Model {
   Strategy: string;
   // 后端当前返回为 0/1（对应 models.breaker）
   Breaker?: number | null;
+  // 后端当前返回为 0/1（对应 models.auto_weight）
+  AutoWeight?: number | null;
+  EchoModel?: number | null;
+  // 非流式响应缓存时长（秒），0 表示关闭
+  CacheTTLSeconds?: number | null;
+  // 失败降权后保留的最低权重，0 表示不设下限
+  MinWeight?: number | null;
+  // 熔断参数，0 表示使用默认值
+  BreakerMaxFailures?: number | null;
+  BreakerSleepSeconds?: number | null;
+  BreakerHalfOpenRequests?: number | null;
+  // 全部提供商失败后改用的备用模型，空字符串表示关闭
+  FallbackModel?: string | null;
+  StreamMaxSeconds?: number | null;
+  MaxThinkingTokens?: number | null;
+  MaxReasoningEffort?: string | null;
+  MaxCompletionTokens?: number | null;
   // 后端当前返回为 0/1（对应 models.status）
   Status?: number | null;
   InputPrice?: number | null;
@@ -46,6 +69,8 @@ export interface AuthKeySummary {
   outputCost: number;
   allowAll: boolean;
   models: string[];
+  maxConcurrency: number;
+  currentConcurrency: number;
 }
 
 export interface ModelWithProvider {
@@ -60,6 +85,16 @@ export interface ModelWithProvider {
   CustomerHeaders: Record<string, string> | null;
   Status: boolean | null;
   Weight: number;
+  RpmLimit?: number; // 该关联每分钟请求数限制，与提供商 RPM 同时生效，0 表示无限制
+  ExcludeRules: ExcludeRule[]; // 请求属性满足任一规则时本次请求不使用该关联
+}
+
+// 关联排除规则：attr 为 stream/tool_call/image/structured_output（布尔，op 为 eq/ne）
+// 或 input_bytes/input_tokens（数值，op 为 eq/ne/gt/gte/lt/lte）
+export interface ExcludeRule {
+  attr: string;
+  op: string;
+  value: boolean | number;
 }
 
 export interface PaginatedResponse<T> {
@@ -83,6 +118,9 @@ export interface AuthKey {
   ExpiresAt: string | null;
   UsageCount: number;
   LastUsedAt: string | null;
+  MaxConcurrency: number;
+  RpmLimit: number; // 每分钟请求数上限，0 表示无限制
+  MonthlyBudget: number; // 每月消费上限，0 表示无限制
 }
 
 const toBoolean = (value: unknown): boolean => value === true || value === 1 || value === "1";
@@ -135,8 +173,20 @@ const normalizeModelWithProvider = (raw: any): ModelWithProvider => ({
   WithHeader: toBoolean(raw?.WithHeader),
   Status: raw?.Status == null ? null : toBoolean(raw?.Status),
   CustomerHeaders: parseRecordStringString(raw?.CustomerHeaders),
+  ExcludeRules: parseExcludeRules(raw?.ExcludeRules),
 });
 
+const parseExcludeRules = (raw: unknown): ExcludeRule[] => {
+  if (Array.isArray(raw)) return raw as ExcludeRule[];
+  if (typeof raw !== "string" || raw === "") return [];
+  try {
+    const parsed = JSON.parse(raw);
+    return Array.isArray(parsed) ? parsed : [];
+  } catch {
+    return [];
+  }
+};
+
 export interface SystemConfig {
   enable_smart_routing: boolean;
   success_rate_weight: number;
@@ -182,7 +232,7 @@ async function apiRequest<T>(endpoint: string, options: RequestInit = {}): Promi
   // Handle 401 Unauthorized response
   if (response.status === 401) {
     // Redirect to login page
-    window.location.href = '/login';
+    window.location.href = withBasePath('/login');
     throw new Error('Unauthorized');
   }
 
@@ -200,7 +250,7 @@ async function apiRequest<T>(endpoint: string, options: RequestInit = {}): Promi
 async function authKeyRequest<T>(endpoint: string, options: RequestInit = {}): Promise<T> {
   const token = localStorage.getItem("authToken")?.trim();
 
-  const response = await fetch(endpoint, {
+  const response = await fetch(withBasePath(endpoint), {
     headers: {
       'Content-Type': 'application/json',
       ...(token ? { 'Authorization': `Bearer ${token}` } : {}),
@@ -210,7 +260,7 @@ async function authKeyRequest<T>(endpoint: string, options: RequestInit = {}): P
   });
 
   if (response.status === 401) {
-    window.location.href = '/login';
+    window.location.href = withBasePath('/login');
     throw new Error('Unauthorized');
   }
 
@@ -233,6 +283,22 @@ export async function getAuthKeySummary(): Promise<AuthKeySummary> {
   return authKeyRequest<AuthKeySummary>('/auth-key/summary');
 }
 
+// API Key 按模型的用量，totalCost 为实际记录的费用
+export interface AuthKeyModelUsage {
+  model: string;
+  requests: number;
+  promptTokens: number;
+  completionTokens: number;
+  totalTokens: number;
+  totalCost: number;
+  inputCost: number;
+  outputCost: number;
+}
+
+export async function getAuthKeyModelUsage(): Promise<AuthKeyModelUsage[]> {
+  return authKeyRequest<AuthKeyModelUsage[]>('/auth-key/models');
+}
+
 // Provider API functions
 export async function getProviders(filters: {
   name?: string;
@@ -255,6 +321,9 @@ export async function createProvider(provider: {
   config: string;
   console: string;
   rpm_limit?: number;
+  tpm_limit?: number;
+  max_concurrency?: number;
+  success_status_codes?: string;
   ip_lock_minutes?: number;
 }): Promise<Provider> {
   return apiRequest<Provider>('/providers', {
@@ -269,6 +338,9 @@ export async function updateProvider(id: number, provider: {
   config?: string;
   console?: string;
   rpm_limit?: number;
+  tpm_limit?: number;
+  max_concurrency?: number;
+  success_status_codes?: string;
   ip_lock_minutes?: number;
 }): Promise<Provider> {
   return apiRequest<Provider>(`/providers/${id}`, {
@@ -277,12 +349,51 @@ export async function updateProvider(id: number, provider: {
   });
 }
 
+// 安排提供商在指定时间前停用，until 为 null 表示立即恢复
+export async function setProviderDisabledUntil(id: number, until: string | null): Promise<Provider> {
+  return apiRequest<Provider>(`/providers/${id}/disabled-until`, {
+    method: 'PUT',
+    body: JSON.stringify({ until }),
+  });
+}
+
 export async function deleteProvider(id: number): Promise<void> {
   await apiRequest<void>(`/providers/${id}`, {
     method: 'DELETE',
   });
 }
 
+// 提供商、模型与关联的导入导出格式，关联以模型名、提供商名引用
+export interface ConfigBundle {
+  providers: Record<string, unknown>[];
+  models: Record<string, unknown>[];
+  associations: Record<string, unknown>[];
+}
+
+export interface ImportResult {
+  created: string[];
+  skipped: string[];
+}
+
+export interface ImportSummary {
+  providers: ImportResult;
+  models: ImportResult;
+  associations: ImportResult;
+}
+
+// 导出全部配置，提供商凭据已清空
+export async function exportConfig(): Promise<ConfigBundle> {
+  return apiRequest<ConfigBundle>('/export');
+}
+
+// 在一个事务内导入配置，已存在的同名资源跳过
+export async function importConfig(bundle: ConfigBundle): Promise<ImportSummary> {
+  return apiRequest<ImportSummary>('/import', {
+    method: 'POST',
+    body: JSON.stringify(bundle),
+  });
+}
+
 // Model API functions
 export type ModelQuery = {
   page?: number;
@@ -315,6 +426,18 @@ export async function createModel(model: {
   io_log: boolean;
   strategy: string;
   breaker: boolean;
+  auto_weight?: boolean;
+  echo_model?: boolean;
+  cache_ttl_seconds?: number;
+  min_weight?: number;
+  breaker_max_failures?: number;
+  breaker_sleep_seconds?: number;
+  breaker_half_open_requests?: number;
+  fallback_model?: string;
+  stream_max_seconds?: number;
+  max_thinking_tokens?: number;
+  max_reasoning_effort?: string;
+  max_completion_tokens?: number;
 }): Promise<Model> {
   return apiRequest<Model>('/models', {
     method: 'POST',
@@ -330,6 +453,18 @@ export async function updateModel(id: number, model: {
   io_log?: boolean;
   strategy?: string;
   breaker?: boolean;
+  auto_weight?: boolean;
+  echo_model?: boolean;
+  cache_ttl_seconds?: number;
+  min_weight?: number;
+  breaker_max_failures?: number;
+  breaker_sleep_seconds?: number;
+  breaker_half_open_requests?: number;
+  fallback_model?: string;
+  stream_max_seconds?: number;
+  max_thinking_tokens?: number;
+  max_reasoning_effort?: string;
+  max_completion_tokens?: number;
 }): Promise<Model> {
   return apiRequest<Model>(`/models/${id}`, {
     method: 'PUT',
@@ -358,6 +493,9 @@ export type AuthKeyPayload = {
   allow_all: boolean;
   models: string[];
   expires_at?: string | null;
+  max_concurrency?: number;
+  rpm_limit?: number;
+  monthly_budget?: number;
 };
 
 export async function getAuthKeys(params: {
@@ -446,6 +584,8 @@ export async function createModelProvider(association: {
   with_header: boolean;
   customer_headers: Record<string, string>;
   weight: number;
+  rpm_limit?: number;
+  exclude_rules?: ExcludeRule[];
 }): Promise<ModelWithProvider> {
   const res = await apiRequest<any>('/model-providers', {
     method: 'POST',
@@ -464,6 +604,8 @@ export async function updateModelProvider(id: number, association: {
   with_header?: boolean;
   customer_headers?: Record<string, string>;
   weight?: number;
+  rpm_limit?: number;
+  exclude_rules?: ExcludeRule[];
 }): Promise<ModelWithProvider> {
   const res = await apiRequest<any>(`/model-providers/${id}`, {
     method: 'PUT',
@@ -540,11 +682,48 @@ export async function getProjectCounts(): Promise<ProjectCount[]> {
   return apiRequest<ProjectCount[]>('/metrics/projects');
 }
 
+export interface LimiterRejectionItem {
+  provider_id: number;
+  provider_name: string;
+  reason: string;
+  total: number; // 进程启动以来累计
+  window: number; // 时间窗口内
+}
+
+export interface LimiterRejections {
+  window_minutes: number;
+  items: LimiterRejectionItem[];
+}
+
+export async function getLimiterRejections(window?: number): Promise<LimiterRejections> {
+  const query = window ? `?window=${window}` : '';
+  return apiRequest<LimiterRejections>(`/metrics/limiter-rejections${query}`);
+}
+
 // Test API functions
-export async function testModelProvider(id: number): Promise<any> {
+// override 提供时使用临时的 api_key/base_url 测试（不会保存到提供商配置）
+export async function testModelProvider(id: number, override?: { api_key?: string; base_url?: string }): Promise<any> {
+  if (override) {
+    return apiRequest<any>(`/test/${id}`, {
+      method: 'POST',
+      body: JSON.stringify(override),
+    });
+  }
   return apiRequest<any>(`/test/${id}`);
 }
 
+export interface ProviderConnectivityResult {
+  reachable: boolean;
+  latency_ms: number;
+  model_count: number;
+  error?: string;
+}
+
+// 按提供商配置请求模型列表，测试基础连通性（不依赖模型关联）
+export async function testProviderConnectivity(id: number): Promise<ProviderConnectivityResult> {
+  return apiRequest<ProviderConnectivityResult>(`/providers/${id}/test`);
+}
+
 // Provider Templates API functions
 export interface ProviderTemplate {
   type: string;
@@ -559,6 +738,8 @@ export interface ProviderStatsItem {
   provider_id: number;
   rpm_count: number;
   rpm_loaded: boolean;
+  tpm_count: number;
+  tpm_loaded: boolean;
   locked: boolean;
   ip_lock_loaded: boolean;
   lock_until?: string;
@@ -579,8 +760,25 @@ export interface ProviderModel {
   owned_by: string;
 }
 
-export async function getProviderModels(providerId: number): Promise<ProviderModel[]> {
-  return apiRequest<ProviderModel[]>(`/providers/models/${providerId}`);
+export interface ProviderModelsResult {
+  models: ProviderModel[];
+  fetched_at: string;
+  cached: boolean;
+  // 距上次从上游拉取的秒数
+  cache_age_seconds: number;
+}
+
+// 上游模型列表由后端缓存，refresh 为 true 时强制重新拉取
+export async function getProviderModels(providerId: number, refresh: boolean = false): Promise<ProviderModelsResult> {
+  const query = refresh ? "?refresh=true" : "";
+  return apiRequest<ProviderModelsResult>(`/providers/models/${providerId}${query}`);
+}
+
+// 清除缓存并从上游重新拉取模型列表
+export async function refreshProviderModels(providerId: number): Promise<ProviderModelsResult> {
+  return apiRequest<ProviderModelsResult>(`/providers/${providerId}/models/refresh`, {
+    method: 'POST',
+  });
 }
 
 // Config API functions
@@ -604,6 +802,10 @@ export interface ModelPriceSyncConfig {
   enabled: boolean;
   interval_minutes: number;
   source_url: string;
+  timeout_seconds?: number; // 单次请求超时（秒），默认 20
+  retries?: number; // 失败后的重试次数，默认 2
+  user_agent?: string;
+  authorization?: string;
 }
 
 export const configAPI = {
@@ -621,6 +823,8 @@ export const configAPI = {
 export interface ChatLog {
   ID: number;
   CreatedAt: string;
+  RequestID?: string; // 同一客户端请求的各次重试与最终汇总记录共享
+  FallbackFrom?: string; // 切换到备用模型时的原模型名称
   Name: string;
   ProviderModel: string;
   ProviderName: string;
@@ -696,6 +900,7 @@ export async function getLogs(
     status?: string;
     style?: string;
     authKeyId?: string;
+    requestId?: string;
   } = {}
 ): Promise<LogsResponse> {
   const params = new URLSearchParams();
@@ -708,6 +913,7 @@ export async function getLogs(
   if (filters.status) params.append("status", filters.status);
   if (filters.style) params.append("style", filters.style);
   if (filters.authKeyId) params.append("auth_key_id", filters.authKeyId);
+  if (filters.requestId) params.append("request_id", filters.requestId);
 
   return apiRequest<LogsResponse>(`/logs?${params.toString()}`);
 }
@@ -716,8 +922,41 @@ export async function getRequestAmountTrend(): Promise<RequestAmountSummary> {
   return apiRequest<RequestAmountSummary>('/metrics/request-amount');
 }
 
-export async function getChatIO(logId: number | string): Promise<ChatIO> {
-  return apiRequest<ChatIO>(`/logs/${logId}/chat-io`);
+// decode 为 true 时由服务端还原以 base64 保存的内容（gzip 解压，二进制内容返回说明文字）
+export async function getChatIO(logId: number | string, decode = false): Promise<ChatIO> {
+  return apiRequest<ChatIO>(`/logs/${logId}/chat-io${decode ? "?decode=true" : ""}`);
+}
+
+// IO 内容搜索 API
+export interface LogSearchItem extends ChatLog {
+  input_snippet: string;
+  output_snippet: string;
+}
+
+export interface LogSearchResponse {
+  data: LogSearchItem[];
+  total: number;
+  page: number;
+  page_size: number;
+  pages: number;
+  io_disabled_count: number;
+  blob_stored_count: number;
+  warnings: string[];
+}
+
+export async function searchLogs(params: {
+  query: string;
+  mode?: 'contains' | 'fulltext';
+  start_time?: string;
+  end_time?: string;
+  name?: string;
+  page?: number;
+  page_size?: number;
+}): Promise<LogSearchResponse> {
+  return apiRequest<LogSearchResponse>('/logs/search', {
+    method: 'POST',
+    body: JSON.stringify(params),
+  });
 }
 
 // Clean logs API
@@ -764,7 +1003,10 @@ export interface ModelHealth {
   avgResponseTimeMs: number;
   lastCheck: string;
   lastError?: string;
+  reasons?: string[]; // 状态判定原因
   requestBlocks: ModelHealthRequestBlock[]; // 最近100次请求，从旧到新
+  breakerState?: "closed" | "open" | "halfopen"; // 熔断状态
+  breakerUntil?: string; // open 状态下的冷却结束时间
 }
 
 export interface ProviderHealth {
@@ -778,6 +1020,7 @@ export interface ProviderHealth {
   totalRequests: number;
   failedRequests: number;
   lastError?: string;
+  reasons?: string[]; // 状态判定原因
   models: ModelHealth[]; // 该提供商下的模型列表
 }
 
@@ -803,7 +1046,7 @@ export interface SystemHealth {
 
 export async function getSystemHealthDetail(timeWindowMinutes?: number): Promise<SystemHealth> {
   const params = timeWindowMinutes ? `?window=${timeWindowMinutes}` : "";
-  const response = await fetch(`/api/health/detail${params}`);
+  const response = await fetch(`${API_BASE}/health/detail${params}`);
   if (!response.ok) {
     throw new Error(`健康检查失败: ${response.status}`);
   }
@@ -811,7 +1054,7 @@ export async function getSystemHealthDetail(timeWindowMinutes?: number): Promise
 }
 
 export async function getPrometheusMetrics(): Promise<string> {
-  const response = await fetch("/api/metrics");
+  const response = await fetch(`${API_BASE}/metrics`);
   if (!response.ok) {
     throw new Error(`获取指标失败: ${response.status}`);
   }
@@ -0,0 +1,11 @@
+// 服务端通过 LLMIO_BASE_PATH 部署在子路径下时，会在 index.html 中注入 window.__LLMIO_BASE_PATH__
+declare global {
+  interface Window {
+    __LLMIO_BASE_PATH__?: string;
+  }
+}
+
+export const BASE_PATH = (window.__LLMIO_BASE_PATH__ ?? '').replace(/\/+$/, '');
+
+// withBasePath 为站内绝对路径加上部署前缀
+export const withBasePath = (path: string) => `${BASE_PATH}${path}`;
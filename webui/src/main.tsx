@@ -2,6 +2,7 @@ import { StrictMode } from 'react'
 import { createRoot } from 'react-dom/client'
 import './index.css'
 import App from './App.tsx'
+import { BASE_PATH, withBasePath } from './lib/base-path'
 
 // Check if user is authenticated
 const isAuthenticated = () => {
@@ -10,7 +11,7 @@ const isAuthenticated = () => {
 
 // Redirect to login if not authenticated (except for login page)
 const ProtectedApp = () => {
-  const path = window.location.pathname;
+  const path = window.location.pathname.slice(BASE_PATH.length);
 
   // Allow access to login page without authentication
   if (path === '/login') {
@@ -19,7 +20,7 @@ const ProtectedApp = () => {
 
   // Redirect to login if not authenticated
   if (!isAuthenticated()) {
-    window.location.href = '/login';
+    window.location.href = withBasePath('/login');
     return null;
   }
 
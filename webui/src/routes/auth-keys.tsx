@@ -77,6 +77,9 @@ const formSchema = z.object({
   allow_all: z.boolean(),
   models: z.array(z.string()),
   expires_at: z.string().nullable().optional(),
+  max_concurrency: z.number().int().min(0, { message: "并发上限不能为负数" }),
+  rpm_limit: z.number().int().min(0, { message: "RPM 上限不能为负数" }),
+  monthly_budget: z.number().min(0, { message: "月度预算不能为负数" }),
 }).refine((value) => value.allow_all || value.models.length > 0, {
   message: "请选择至少一个允许的模型",
   path: ["models"],
@@ -90,6 +93,9 @@ const defaultFormValues: AuthKeyFormValues = {
   allow_all: true,
   models: [],
   expires_at: null,
+  max_concurrency: 0,
+  rpm_limit: 0,
+  monthly_budget: 0,
 };
 
 type MobileInfoItemProps = {
@@ -226,6 +232,9 @@ export default function AuthKeysPage() {
       allow_all: key.AllowAll === true,
       models: key.Models ?? [],
       expires_at: key.ExpiresAt,
+      max_concurrency: key.MaxConcurrency ?? 0,
+      rpm_limit: key.RpmLimit ?? 0,
+      monthly_budget: key.MonthlyBudget ?? 0,
     });
     setDialogOpen(true);
   };
@@ -250,6 +259,9 @@ export default function AuthKeysPage() {
         allow_all: values.allow_all,
         models: values.allow_all ? [] : values.models,
         expires_at: values.expires_at ?? undefined,
+        max_concurrency: values.max_concurrency,
+        rpm_limit: values.rpm_limit,
+        monthly_budget: values.monthly_budget,
       };
       if (editingKey) {
         await updateAuthKey(editingKey.ID, payload);
@@ -770,6 +782,64 @@ export default function AuthKeysPage() {
                 )}
               />
 
+              <FormField
+                control={form.control}
+                name="max_concurrency"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>最大并发请求数（0 为不限制）</FormLabel>
+                    <FormControl>
+                      <Input
+                        type="number"
+                        min={0}
+                        {...field}
+                        onChange={e => field.onChange(+e.target.value)}
+                      />
+                    </FormControl>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
+
+              <FormField
+                control={form.control}
+                name="rpm_limit"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>每分钟最大请求数（0 为不限制）</FormLabel>
+                    <FormControl>
+                      <Input
+                        type="number"
+                        min={0}
+                        {...field}
+                        onChange={e => field.onChange(+e.target.value)}
+                      />
+                    </FormControl>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
+
+              <FormField
+                control={form.control}
+                name="monthly_budget"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>月度消费预算（0 为不限制）</FormLabel>
+                    <FormControl>
+                      <Input
+                        type="number"
+                        min={0}
+                        step="0.01"
+                        {...field}
+                        onChange={e => field.onChange(+e.target.value)}
+                      />
+                    </FormControl>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
+
               <FormField
                 control={form.control}
                 name="expires_at"
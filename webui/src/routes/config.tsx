@@ -31,6 +31,10 @@ const priceSyncSchema = z.object({
   enabled: z.boolean(),
   interval_minutes: z.number().min(1, { message: '执行间隔必须大于 0' }),
   source_url: z.string().trim(),
+  timeout_seconds: z.number().min(1, { message: '超时时间必须大于 0' }),
+  retries: z.number().min(0, { message: '重试次数不能为负数' }),
+  user_agent: z.string().trim(),
+  authorization: z.string().trim(),
 });
 
 type AnthropicProxyForm = z.infer<typeof anthropicProxySchema>;
@@ -52,6 +56,10 @@ export default function ConfigPage() {
       enabled: true,
       interval_minutes: 1440,
       source_url: 'https://models.dev/api.json',
+      timeout_seconds: 20,
+      retries: 2,
+      user_agent: '',
+      authorization: '',
     },
   });
 
@@ -82,6 +90,10 @@ export default function ConfigPage() {
             enabled: Boolean(priceSyncCfg.enabled),
             interval_minutes: Number(priceSyncCfg.interval_minutes || 1440),
             source_url: priceSyncCfg.source_url || 'https://models.dev/api.json',
+            timeout_seconds: Number(priceSyncCfg.timeout_seconds || 20),
+            retries: Number(priceSyncCfg.retries ?? 2),
+            user_agent: priceSyncCfg.user_agent || '',
+            authorization: priceSyncCfg.authorization || '',
           };
           priceSyncForm.reset(nextPriceSyncConfig);
         }
@@ -246,6 +258,72 @@ export default function ConfigPage() {
                         </FormItem>
                       )}
                     />
+
+                    <FormField
+                      control={priceSyncForm.control}
+                      name="timeout_seconds"
+                      render={({ field }) => (
+                        <FormItem>
+                          <FormLabel>请求超时（秒）</FormLabel>
+                          <FormControl>
+                            <Input
+                              type="number"
+                              min={1}
+                              value={field.value}
+                              onChange={(event) => field.onChange(Number(event.target.value))}
+                            />
+                          </FormControl>
+                          <FormMessage />
+                        </FormItem>
+                      )}
+                    />
+
+                    <FormField
+                      control={priceSyncForm.control}
+                      name="retries"
+                      render={({ field }) => (
+                        <FormItem>
+                          <FormLabel>失败重试次数</FormLabel>
+                          <FormControl>
+                            <Input
+                              type="number"
+                              min={0}
+                              value={field.value}
+                              onChange={(event) => field.onChange(Number(event.target.value))}
+                            />
+                          </FormControl>
+                          <FormMessage />
+                        </FormItem>
+                      )}
+                    />
+
+                    <FormField
+                      control={priceSyncForm.control}
+                      name="user_agent"
+                      render={({ field }) => (
+                        <FormItem>
+                          <FormLabel>User-Agent</FormLabel>
+                          <FormControl>
+                            <Input placeholder="留空使用默认值" {...field} />
+                          </FormControl>
+                          <FormMessage />
+                        </FormItem>
+                      )}
+                    />
+
+                    <FormField
+                      control={priceSyncForm.control}
+                      name="authorization"
+                      render={({ field }) => (
+                        <FormItem>
+                          <FormLabel>Authorization</FormLabel>
+                          <FormControl>
+                            <Input type="password" placeholder="可选，如 Bearer xxx" {...field} />
+                          </FormControl>
+                          <FormMessage />
+                        </FormItem>
+                      )}
+                    />
                   </div>
                 </form>
               </Form>
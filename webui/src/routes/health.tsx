@@ -112,6 +112,9 @@ const humanProviderType = (raw: string): string => {
   if (lower === "openai") return "OpenAI";
   if (lower === "anthropic") return "Anthropic";
   if (lower === "gemini") return "Gemini";
+  if (lower === "azure") return "Azure OpenAI";
+  if (lower === "bedrock") return "AWS Bedrock";
+  if (lower === "mistral") return "Mistral";
   return v.charAt(0).toUpperCase() + v.slice(1);
 };
 
@@ -171,6 +174,16 @@ const ModelHealthCard = ({
             <span className="text-muted-foreground">近期可用性</span>
             <span className="font-medium tabular-nums">{model.successRate.toFixed(0)}%</span>
           </div>
+          {model.status !== "healthy" && model.reasons && model.reasons.length > 0 && (
+            <div className="mt-1 text-xs text-muted-foreground">{model.reasons.join("；")}</div>
+          )}
+          {model.breakerState && model.breakerState !== "closed" && (
+            <div className="mt-1 text-xs text-red-500">
+              {model.breakerState === "open"
+                ? `熔断中${model.breakerUntil ? `，${new Date(model.breakerUntil).toLocaleTimeString()} 后探测恢复` : ""}`
+                : "熔断探测恢复中"}
+            </div>
+          )}
           <div className="mt-1.5 h-1.5 w-full rounded-full bg-muted overflow-hidden">
             <div
               className={cn("h-1.5 rounded-full transition-[width] duration-300", {
@@ -259,6 +272,9 @@ const ProviderCard = ({ provider, consoleLatency }: { provider: ProviderHealth;
               <CardDescription className="mt-1">
                 {provider.type} · {provider.models.length} 个模型 · 成功率 {successRate}% · {provider.totalRequests.toLocaleString()} 次请求
               </CardDescription>
+              {provider.status !== "healthy" && provider.reasons && provider.reasons.length > 0 && (
+                <div className="mt-1 text-xs text-muted-foreground">{provider.reasons.join("；")}</div>
+              )}
             </div>
           </div>
           <div className="flex items-center gap-2 text-sm text-muted-foreground">
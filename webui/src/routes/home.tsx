@@ -354,6 +354,12 @@ const AuthKeyDashboard = ({ summary, errorMessage }: AuthKeyDashboardProps) => {
                 {expireText}
               </span>
             </div>
+            <div className="flex flex-wrap items-center gap-3 text-xs text-muted-foreground">
+              <span>当前并发</span>
+              <span className="text-foreground">
+                {summary?.currentConcurrency ?? 0} / {summary?.maxConcurrency ? summary.maxConcurrency : "不限制"}
+              </span>
+            </div>
           </CardContent>
         </Card>
 
@@ -210,7 +210,7 @@ export default function LogChatPage() {
 
     const fetchChatIO = async () => {
       try {
-        const data = await getChatIO(logId);
+        const data = await getChatIO(logId, true);
         setChatIO(data);
         setLoadErrorMessage(null);
       } catch (fetchError) {
@@ -66,8 +66,17 @@ const formSchema = z.object({
   structured_output: z.boolean(),
   image: z.boolean(),
   with_header: z.boolean(),
-  weight: z.number().positive({ message: "权重必须大于0" }),
+  weight: z.number().int().min(0, { message: "权重不能小于0" }),
+  rpm_limit: z.number().int().min(0, { message: "RPM 限制不能小于0" }),
   customer_headers: z.array(headerPairSchema).default([]),
+  exclude_rules: z.string().refine((value) => {
+    if (!value.trim()) return true;
+    try {
+      return Array.isArray(JSON.parse(value));
+    } catch {
+      return false;
+    }
+  }, { message: "排除规则必须是 JSON 数组" }),
 });
 
 type FormValues = z.input<typeof formSchema>;
@@ -134,7 +143,9 @@ export function ModelProvidersPanel({ embedded = false, fixedModel = null }: Mod
       image: false,
       with_header: true,
       weight: 1,
+      rpm_limit: 0,
       customer_headers: [],
+      exclude_rules: "",
     },
   });
 
@@ -196,6 +207,8 @@ export function ModelProvidersPanel({ embedded = false, fixedModel = null }: Mod
       with_header: values.with_header,
       customer_headers: headers,
       weight: values.weight,
+      rpm_limit: values.rpm_limit,
+      exclude_rules: values.exclude_rules.trim() ? JSON.parse(values.exclude_rules) : [],
     };
   };
 
@@ -233,7 +246,9 @@ export function ModelProvidersPanel({ embedded = false, fixedModel = null }: Mod
         image: false,
         with_header: false,
         weight: 1,
+        rpm_limit: 0,
         customer_headers: [],
+      exclude_rules: "",
       });
       await fetchModelProviders();
     } catch (err) {
@@ -259,7 +274,9 @@ export function ModelProvidersPanel({ embedded = false, fixedModel = null }: Mod
         image: false,
         with_header: false,
         weight: 1,
+        rpm_limit: 0,
         customer_headers: [],
+      exclude_rules: "",
       });
       await fetchModelProviders();
     } catch (err) {
@@ -463,7 +480,9 @@ export function ModelProvidersPanel({ embedded = false, fixedModel = null }: Mod
       image: association.Image === true,
       with_header: association.WithHeader === true,
       weight: association.Weight,
+      rpm_limit: association.RpmLimit || 0,
       customer_headers: headerPairs.length ? headerPairs : [],
+      exclude_rules: association.ExcludeRules?.length ? JSON.stringify(association.ExcludeRules, null, 2) : "",
     });
     setOpen(true);
   };
@@ -479,7 +498,9 @@ export function ModelProvidersPanel({ embedded = false, fixedModel = null }: Mod
       image: false,
       with_header: false,
       weight: 1,
+      rpm_limit: 0,
       customer_headers: [],
+      exclude_rules: "",
     });
     setOpen(true);
   };
@@ -495,7 +516,7 @@ export function ModelProvidersPanel({ embedded = false, fixedModel = null }: Mod
     setProviderModelsLoading((prev) => ({ ...prev, [providerId]: true }));
     try {
       const data = await getProviderModels(providerId);
-      setProviderModelsMap((prev) => ({ ...prev, [providerId]: data }));
+      setProviderModelsMap((prev) => ({ ...prev, [providerId]: data.models }));
     } catch (err) {
       toast.warning(`获取提供商模型列表失败: ${err}`);
       setProviderModelsMap((prev) => ({ ...prev, [providerId]: [] }));
@@ -923,12 +944,12 @@ export function ModelProvidersPanel({ embedded = false, fixedModel = null }: Mod
                   name="weight"
                   render={({ field }) => (
                     <FormItem>
-                      <FormLabel>权重 (必须大于0)</FormLabel>
+                      <FormLabel>权重 (0 表示仅在其余提供商全部失败时用于故障转移)</FormLabel>
                       <FormControl>
                         <Input
                           {...field}
                           type="number"
-                          min="1"
+                          min="0"
                           onChange={(e) => field.onChange(parseInt(e.target.value) || 0)}
                         />
                       </FormControl>
@@ -936,6 +957,44 @@ export function ModelProvidersPanel({ embedded = false, fixedModel = null }: Mod
                     </FormItem>
                   )}
                 />
+
+                <FormField
+                  control={form.control}
+                  name="rpm_limit"
+                  render={({ field }) => (
+                    <FormItem>
+                      <FormLabel>RPM 限制 (0 表示无限制，与提供商 RPM 限制同时生效)</FormLabel>
+                      <FormControl>
+                        <Input
+                          {...field}
+                          type="number"
+                          min="0"
+                          onChange={(e) => field.onChange(parseInt(e.target.value) || 0)}
+                        />
+                      </FormControl>
+                      <FormMessage />
+                    </FormItem>
+                  )}
+                />
+
+                <FormField
+                  control={form.control}
+                  name="exclude_rules"
+                  render={({ field }) => (
+                    <FormItem>
+                      <FormLabel>排除规则 (JSON 数组，请求满足任一规则时不使用该关联)</FormLabel>
+                      <FormControl>
+                        <Textarea
+                          {...field}
+                          rows={3}
+                          className="font-mono text-xs"
+                          placeholder='[{"attr": "input_tokens", "op": "gt", "value": 32000}]'
+                        />
+                      </FormControl>
+                      <FormMessage />
+                    </FormItem>
+                  )}
+                />
               </div>
 
               <DialogFooter>
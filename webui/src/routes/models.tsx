@@ -124,8 +124,20 @@ const formSchema = z.object({
   max_retry: z.number().min(0, { message: "重试次数限制不能为负数" }),
   time_out: z.number().min(0, { message: "超时时间不能为负数" }),
   io_log: z.boolean(),
-  strategy: z.enum(["lottery", "rotor"]),
+  strategy: z.enum(["lottery", "rotor", "cost_aware", "cost", "latency"]),
   breaker: z.boolean(),
+  auto_weight: z.boolean(),
+  echo_model: z.boolean(),
+  cache_ttl_seconds: z.number().min(0, { message: "缓存时长不能为负数" }),
+  min_weight: z.number().min(0, { message: "最低权重不能为负数" }),
+  breaker_max_failures: z.number().min(0, { message: "熔断失败次数不能为负数" }),
+  breaker_sleep_seconds: z.number().min(0, { message: "熔断冷却时间不能为负数" }),
+  breaker_half_open_requests: z.number().min(0, { message: "恢复成功次数不能为负数" }),
+  fallback_model: z.string(),
+  stream_max_seconds: z.number().min(0, { message: "流式最长时间不能为负数" }),
+  max_thinking_tokens: z.number().min(0, { message: "思考预算上限不能为负数" }),
+  max_reasoning_effort: z.enum(["", "none", "minimal", "low", "medium", "high", "xhigh"]),
+  max_completion_tokens: z.number().min(0, { message: "输出 token 上限不能为负数" }),
   status: z.boolean(),
 });
 
@@ -154,6 +166,18 @@ export default function ModelsPage() {
       io_log: false,
       strategy: "lottery",
       breaker: false,
+      auto_weight: false,
+      echo_model: false,
+      cache_ttl_seconds: 0,
+      min_weight: 0,
+      breaker_max_failures: 0,
+      breaker_sleep_seconds: 0,
+      breaker_half_open_requests: 0,
+      fallback_model: "",
+      stream_max_seconds: 0,
+      max_thinking_tokens: 0,
+      max_reasoning_effort: "",
+      max_completion_tokens: 0,
       status: true,
     },
   });
@@ -205,10 +229,22 @@ export default function ModelsPage() {
         io_log: values.io_log,
         strategy: values.strategy,
         breaker: values.breaker,
+        auto_weight: values.auto_weight,
+        echo_model: values.echo_model,
+        cache_ttl_seconds: values.cache_ttl_seconds,
+        min_weight: values.min_weight,
+        breaker_max_failures: values.breaker_max_failures,
+        breaker_sleep_seconds: values.breaker_sleep_seconds,
+        breaker_half_open_requests: values.breaker_half_open_requests,
+        fallback_model: values.fallback_model.trim(),
+        stream_max_seconds: values.stream_max_seconds,
+        max_thinking_tokens: values.max_thinking_tokens,
+        max_reasoning_effort: values.max_reasoning_effort,
+        max_completion_tokens: values.max_completion_tokens,
       });
       setOpen(false);
       toast.success(`模型: ${values.name} 创建成功`);
-      form.reset({ name: "", remark: "", max_retry: 10, time_out: 60, io_log: false, strategy: "lottery", breaker: false });
+      form.reset({ name: "", remark: "", max_retry: 10, time_out: 60, io_log: false, strategy: "lottery", breaker: false, auto_weight: false, echo_model: false, cache_ttl_seconds: 0, min_weight: 0, breaker_max_failures: 0, breaker_sleep_seconds: 0, breaker_half_open_requests: 0, fallback_model: "", stream_max_seconds: 0, max_thinking_tokens: 0, max_reasoning_effort: "", max_completion_tokens: 0 });
       await fetchModels();
     } catch (err) {
       const message = err instanceof Error ? err.message : String(err);
@@ -227,6 +263,18 @@ export default function ModelsPage() {
         io_log: values.io_log,
         strategy: values.strategy,
         breaker: values.breaker,
+        auto_weight: values.auto_weight,
+        echo_model: values.echo_model,
+        cache_ttl_seconds: values.cache_ttl_seconds,
+        min_weight: values.min_weight,
+        breaker_max_failures: values.breaker_max_failures,
+        breaker_sleep_seconds: values.breaker_sleep_seconds,
+        breaker_half_open_requests: values.breaker_half_open_requests,
+        fallback_model: values.fallback_model.trim(),
+        stream_max_seconds: values.stream_max_seconds,
+        max_thinking_tokens: values.max_thinking_tokens,
+        max_reasoning_effort: values.max_reasoning_effort,
+        max_completion_tokens: values.max_completion_tokens,
       });
       const previousEnabled = editingModel.Status == null ? true : Number(editingModel.Status) === 1;
       if (previousEnabled !== values.status) {
@@ -235,7 +283,7 @@ export default function ModelsPage() {
       setOpen(false);
       toast.success(`模型: ${values.name} 更新成功`);
       setEditingModel(null);
-      form.reset({ name: "", remark: "", max_retry: 10, time_out: 60, io_log: false, strategy: "lottery", breaker: false, status: true });
+      form.reset({ name: "", remark: "", max_retry: 10, time_out: 60, io_log: false, strategy: "lottery", breaker: false, auto_weight: false, echo_model: false, cache_ttl_seconds: 0, min_weight: 0, breaker_max_failures: 0, breaker_sleep_seconds: 0, breaker_half_open_requests: 0, fallback_model: "", stream_max_seconds: 0, max_thinking_tokens: 0, max_reasoning_effort: "", max_completion_tokens: 0, status: true });
       await fetchModels();
     } catch (err) {
       const message = err instanceof Error ? err.message : String(err);
@@ -268,8 +316,20 @@ export default function ModelsPage() {
       max_retry: model.MaxRetry,
       time_out: model.TimeOut,
       io_log: Boolean(model.IOLog),
-      strategy: model.Strategy === "rotor" ? "rotor" : "lottery",
+      strategy: model.Strategy === "rotor" || model.Strategy === "cost_aware" || model.Strategy === "cost" || model.Strategy === "latency" ? model.Strategy : "lottery",
       breaker: Boolean(model.Breaker),
+      auto_weight: Boolean(model.AutoWeight),
+      echo_model: Boolean(model.EchoModel),
+      cache_ttl_seconds: model.CacheTTLSeconds ?? 0,
+      min_weight: model.MinWeight ?? 0,
+      breaker_max_failures: model.BreakerMaxFailures ?? 0,
+      breaker_sleep_seconds: model.BreakerSleepSeconds ?? 0,
+      breaker_half_open_requests: model.BreakerHalfOpenRequests ?? 0,
+      fallback_model: model.FallbackModel ?? "",
+      stream_max_seconds: model.StreamMaxSeconds ?? 0,
+      max_thinking_tokens: model.MaxThinkingTokens ?? 0,
+      max_reasoning_effort: (model.MaxReasoningEffort ?? "") as z.infer<typeof formSchema>["max_reasoning_effort"],
+      max_completion_tokens: model.MaxCompletionTokens ?? 0,
       status: statusEnabled,
     });
     setOpen(true);
@@ -282,7 +342,7 @@ export default function ModelsPage() {
 
   const openCreateDialog = () => {
     setEditingModel(null);
-    form.reset({ name: "", remark: "", max_retry: 10, time_out: 60, io_log: false, strategy: "lottery", breaker: false, status: true });
+    form.reset({ name: "", remark: "", max_retry: 10, time_out: 60, io_log: false, strategy: "lottery", breaker: false, auto_weight: false, echo_model: false, cache_ttl_seconds: 0, min_weight: 0, breaker_max_failures: 0, breaker_sleep_seconds: 0, breaker_half_open_requests: 0, fallback_model: "", stream_max_seconds: 0, max_thinking_tokens: 0, max_reasoning_effort: "", max_completion_tokens: 0, status: true });
     setOpen(true);
   };
 
@@ -522,6 +582,20 @@ export default function ModelsPage() {
                 )}
               />
 
+              <FormField
+                control={form.control}
+                name="fallback_model"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>备用模型</FormLabel>
+                    <FormControl>
+                      <Input className="h-9" placeholder="全部提供商失败后改用的模型，留空表示关闭" {...field} />
+                    </FormControl>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
+
               <div className="grid grid-cols-2 gap-3">
                 <FormField
                   control={form.control}
@@ -560,6 +634,135 @@ export default function ModelsPage() {
                     </FormItem>
                   )}
                 />
+
+                <FormField
+                  control={form.control}
+                  name="cache_ttl_seconds"
+                  render={({ field }) => (
+                    <FormItem>
+                      <FormLabel>响应缓存(秒)</FormLabel>
+                      <FormControl>
+                        <Input
+                          type="number"
+                          className="h-9"
+                          min={0}
+                          placeholder="0 表示关闭"
+                          {...field}
+                          onChange={e => field.onChange(+e.target.value)}
+                        />
+                      </FormControl>
+                      <FormMessage />
+                    </FormItem>
+                  )}
+                />
+
+                <FormField
+                  control={form.control}
+                  name="min_weight"
+                  render={({ field }) => (
+                    <FormItem>
+                      <FormLabel>最低权重</FormLabel>
+                      <FormControl>
+                        <Input
+                          type="number"
+                          className="h-9"
+                          min={0}
+                          placeholder="0 表示不设下限"
+                          {...field}
+                          onChange={e => field.onChange(+e.target.value)}
+                        />
+                      </FormControl>
+                      <FormMessage />
+                    </FormItem>
+                  )}
+                />
+
+                <FormField
+                  control={form.control}
+                  name="stream_max_seconds"
+                  render={({ field }) => (
+                    <FormItem>
+                      <FormLabel>流式最长时间(秒)</FormLabel>
+                      <FormControl>
+                        <Input
+                          type="number"
+                          className="h-9"
+                          min={0}
+                          placeholder="0 表示不限制"
+                          {...field}
+                          onChange={e => field.onChange(+e.target.value)}
+                        />
+                      </FormControl>
+                      <FormMessage />
+                    </FormItem>
+                  )}
+                />
+
+                <FormField
+                  control={form.control}
+                  name="max_thinking_tokens"
+                  render={({ field }) => (
+                    <FormItem>
+                      <FormLabel>思考预算上限</FormLabel>
+                      <FormControl>
+                        <Input
+                          type="number"
+                          className="h-9"
+                          min={0}
+                          placeholder="0 表示不限制"
+                          {...field}
+                          onChange={e => field.onChange(+e.target.value)}
+                        />
+                      </FormControl>
+                      <FormMessage />
+                    </FormItem>
+                  )}
+                />
+
+                <FormField
+                  control={form.control}
+                  name="max_reasoning_effort"
+                  render={({ field }) => (
+                    <FormItem>
+                      <FormLabel>推理强度上限</FormLabel>
+                      <Select value={field.value || "unlimited"} onValueChange={value => field.onChange(value === "unlimited" ? "" : value)}>
+                        <FormControl>
+                          <SelectTrigger className="h-9 w-full">
+                            <SelectValue />
+                          </SelectTrigger>
+                        </FormControl>
+                        <SelectContent>
+                          <SelectItem value="unlimited">不限制</SelectItem>
+                          {["none", "minimal", "low", "medium", "high", "xhigh"].map(effort => (
+                            <SelectItem key={effort} value={effort}>{effort}</SelectItem>
+                          ))}
+                        </SelectContent>
+                      </Select>
+                      <FormMessage />
+                    </FormItem>
+                  )}
+                />
+
+                <FormField
+                  control={form.control}
+                  name="max_completion_tokens"
+                  render={({ field }) => (
+                    <FormItem>
+                      <FormLabel>输出 token 上限</FormLabel>
+                      <FormControl>
+                        <Input
+                          type="number"
+                          className="h-9"
+                          min={0}
+                          placeholder="0 表示不限制"
+                          {...field}
+                          onChange={e => field.onChange(+e.target.value)}
+                        />
+                      </FormControl>
+                      <FormMessage />
+                    </FormItem>
+                  )}
+                />
               </div>
 
               <div className="grid gap-3 sm:grid-cols-2">
@@ -594,8 +797,107 @@ export default function ModelsPage() {
                     </FormItem>
                   )}
                 />
+
+                <FormField
+                  control={form.control}
+                  name="auto_weight"
+                  render={({ field }) => (
+                    <FormItem className="flex items-center justify-between rounded-lg border border-border/60 bg-muted/50 px-3 py-2">
+                      <FormLabel className="text-xs text-muted-foreground">自动调权</FormLabel>
+                      <FormControl>
+                        <Switch
+                          checked={field.value === true}
+                          onCheckedChange={(checked) => field.onChange(checked === true)}
+                        />
+                      </FormControl>
+                    </FormItem>
+                  )}
+                />
+
+                <FormField
+                  control={form.control}
+                  name="echo_model"
+                  render={({ field }) => (
+                    <FormItem className="flex items-center justify-between rounded-lg border border-border/60 bg-muted/50 px-3 py-2">
+                      <FormLabel className="text-xs text-muted-foreground">回显模型名</FormLabel>
+                      <FormControl>
+                        <Switch
+                          checked={field.value === true}
+                          onCheckedChange={(checked) => field.onChange(checked === true)}
+                        />
+                      </FormControl>
+                    </FormItem>
+                  )}
+                />
               </div>
 
+              {form.watch("breaker") && (
+                <div className="grid gap-3 sm:grid-cols-3">
+                  <FormField
+                    control={form.control}
+                    name="breaker_max_failures"
+                    render={({ field }) => (
+                      <FormItem>
+                        <FormLabel>熔断失败次数</FormLabel>
+                        <FormControl>
+                          <Input
+                            type="number"
+                            className="h-9"
+                            min={0}
+                            placeholder="0 表示默认 5"
+                            {...field}
+                            onChange={e => field.onChange(+e.target.value)}
+                          />
+                        </FormControl>
+                        <FormMessage />
+                      </FormItem>
+                    )}
+                  />
+
+                  <FormField
+                    control={form.control}
+                    name="breaker_sleep_seconds"
+                    render={({ field }) => (
+                      <FormItem>
+                        <FormLabel>熔断冷却(秒)</FormLabel>
+                        <FormControl>
+                          <Input
+                            type="number"
+                            className="h-9"
+                            min={0}
+                            placeholder="0 表示默认 60"
+                            {...field}
+                            onChange={e => field.onChange(+e.target.value)}
+                          />
+                        </FormControl>
+                        <FormMessage />
+                      </FormItem>
+                    )}
+                  />
+
+                  <FormField
+                    control={form.control}
+                    name="breaker_half_open_requests"
+                    render={({ field }) => (
+                      <FormItem>
+                        <FormLabel>恢复成功次数</FormLabel>
+                        <FormControl>
+                          <Input
+                            type="number"
+                            className="h-9"
+                            min={0}
+                            placeholder="0 表示默认 2"
+                            {...field}
+                            onChange={e => field.onChange(+e.target.value)}
+                          />
+                        </FormControl>
+                        <FormMessage />
+                      </FormItem>
+                    )}
+                  />
+                </div>
+              )}
+
               <FormField
                 control={form.control}
                 name="strategy"
@@ -611,6 +913,9 @@ export default function ModelsPage() {
                       <SelectContent>
                         <SelectItem value="lottery">抽签（权重随机）</SelectItem>
                         <SelectItem value="rotor">轮转（权重轮询）</SelectItem>
+                        <SelectItem value="cost_aware">成本优先（低价优先，兼顾成功率）</SelectItem>
+                        <SelectItem value="cost">成本加权（按 权重/单价 抽取）</SelectItem>
+                        <SelectItem value="latency">最快响应（近期平均耗时最低优先）</SelectItem>
                       </SelectContent>
                     </Select>
                     <FormMessage />
@@ -56,12 +56,15 @@ import {
   updateProvider,
   deleteProvider,
   getProviderTemplates,
+  setProviderDisabledUntil,
   getProviderModels,
-  getProvidersStats
+  refreshProviderModels,
+  getProvidersStats,
+  testProviderConnectivity
 } from "@/lib/api";
 import type { Provider, ProviderTemplate, ProviderModel, ProviderStatsItem } from "@/lib/api";
 import { toast } from "sonner";
-import { ExternalLink, Pencil, Trash2, Boxes } from "lucide-react";
+import { ExternalLink, Pencil, Trash2, Boxes, Activity } from "lucide-react";
 
 const parseConfigJson = (raw?: string | null): Record<string, unknown> | null => {
   if (!raw) return null;
@@ -119,6 +122,9 @@ const formSchema = z.object({
   config: z.string().min(1, { message: "配置不能为空" }),
   console: z.string().optional(),
   rpmLimit: z.number().min(0, { message: "RPM 限制必须大于等于 0" }).optional(),
+  tpmLimit: z.number().min(0, { message: "TPM 限制必须大于等于 0" }).optional(),
+  maxConcurrency: z.number().int().min(0, { message: "并发上限必须大于等于 0" }).optional(),
+  successStatusCodes: z.string().optional(),
   ipLockMinutes: z.number().min(0, { message: "IP 锁定时间必须大于等于 0" }).optional(),
 });
 
@@ -134,6 +140,7 @@ export default function ProvidersPage() {
   const [providerModels, setProviderModels] = useState<ProviderModel[]>([]);
   const [filteredProviderModels, setFilteredProviderModels] = useState<ProviderModel[]>([]);
   const [modelsLoading, setModelsLoading] = useState(false);
+  const [modelsCacheAge, setModelsCacheAge] = useState<number | null>(null);
   const [structuredConfigEnabled, setStructuredConfigEnabled] = useState(false);
   const configCacheRef = useRef<Record<string, string>>({});
   const statsRequestRef = useRef(0);
@@ -153,7 +160,7 @@ export default function ProvidersPage() {
   // 初始化表单
   const form = useForm<z.infer<typeof formSchema>>({
     resolver: zodResolver(formSchema),
-    defaultValues: { name: "", type: "", config: "", console: "", rpmLimit: 0, ipLockMinutes: 0 },
+    defaultValues: { name: "", type: "", config: "", console: "", rpmLimit: 0, tpmLimit: 0, maxConcurrency: 0, successStatusCodes: "", ipLockMinutes: 0 },
   });
   const selectedProviderType = form.watch("type");
 
@@ -307,16 +314,18 @@ export default function ProvidersPage() {
     }
   };
 
-  const fetchProviderModels = async (providerId: number) => {
+  const fetchProviderModels = async (providerId: number, refresh = false) => {
     try {
       setModelsLoading(true);
-      const data = await getProviderModels(providerId);
-      setProviderModels(data);
-      setFilteredProviderModels(data);
+      const data = refresh ? await refreshProviderModels(providerId) : await getProviderModels(providerId);
+      setProviderModels(data.models);
+      setFilteredProviderModels(data.models);
+      setModelsCacheAge(data.cache_age_seconds);
     } catch (err) {
       console.error("获取提供商模型失败", err);
       setProviderModels([]);
       setFilteredProviderModels([]);
+      setModelsCacheAge(null);
     } finally {
       setModelsLoading(false);
     }
@@ -348,11 +357,14 @@ export default function ProvidersPage() {
         config: values.config,
         console: values.console || "",
         rpm_limit: values.rpmLimit || 0,
+        tpm_limit: values.tpmLimit || 0,
+        max_concurrency: values.maxConcurrency || 0,
+        success_status_codes: values.successStatusCodes || "",
         ip_lock_minutes: values.ipLockMinutes || 0
       });
       setOpen(false);
       toast.success(`提供商 ${values.name} 创建成功`);
-      form.reset({ name: "", type: "", config: "", console: "", rpmLimit: 0, ipLockMinutes: 0 });
+      form.reset({ name: "", type: "", config: "", console: "", rpmLimit: 0, tpmLimit: 0, maxConcurrency: 0, successStatusCodes: "", ipLockMinutes: 0 });
       fetchProviders();
     } catch (err) {
       const message = err instanceof Error ? err.message : String(err);
@@ -370,12 +382,15 @@ export default function ProvidersPage() {
         config: values.config,
         console: values.console || "",
         rpm_limit: values.rpmLimit || 0,
+        tpm_limit: values.tpmLimit || 0,
+        max_concurrency: values.maxConcurrency || 0,
+        success_status_codes: values.successStatusCodes || "",
         ip_lock_minutes: values.ipLockMinutes || 0
       });
       setOpen(false);
       toast.success(`提供商 ${values.name} 更新成功`);
       setEditingProvider(null);
-      form.reset({ name: "", type: "", config: "", console: "", rpmLimit: 0, ipLockMinutes: 0 });
+      form.reset({ name: "", type: "", config: "", console: "", rpmLimit: 0, tpmLimit: 0, maxConcurrency: 0, successStatusCodes: "", ipLockMinutes: 0 });
       fetchProviders();
     } catch (err) {
       const message = err instanceof Error ? err.message : String(err);
@@ -399,6 +414,31 @@ export default function ProvidersPage() {
     }
   };
 
+  const handleRestoreProvider = async (provider: Provider) => {
+    try {
+      await setProviderDisabledUntil(provider.ID, null);
+      fetchProviders();
+      toast.success(`提供商 ${provider.Name} 已恢复`);
+    } catch (err) {
+      const message = err instanceof Error ? err.message : String(err);
+      toast.error(`恢复提供商失败: ${message}`);
+    }
+  };
+
+  const handleTestConnectivity = async (provider: Provider) => {
+    try {
+      const result = await testProviderConnectivity(provider.ID);
+      if (result.reachable) {
+        toast.success(`${provider.Name} 连通正常，耗时 ${result.latency_ms}ms，共 ${result.model_count} 个模型`);
+      } else {
+        toast.error(`${provider.Name} 连通失败（${result.latency_ms}ms）: ${result.error ?? "未知错误"}`);
+      }
+    } catch (err) {
+      const message = err instanceof Error ? err.message : String(err);
+      toast.error(`测试连通性失败: ${message}`);
+    }
+  };
+
   const openEditDialog = (provider: Provider) => {
     configCacheRef.current = {};
     setEditingProvider(provider);
@@ -408,6 +448,9 @@ export default function ProvidersPage() {
       config: provider.Config,
       console: provider.Console || "",
       rpmLimit: provider.RpmLimit || 0,
+      tpmLimit: provider.TpmLimit || 0,
+      maxConcurrency: provider.MaxConcurrency || 0,
+      successStatusCodes: provider.SuccessStatusCodes || "",
       ipLockMinutes: provider.IpLockMinutes || 0,
     });
     setOpen(true);
@@ -539,6 +582,14 @@ export default function ProvidersPage() {
                               <ProviderFavicon consoleUrl={provider.Console} fallback={provider.Type || "?"} />
                               <span>类型: {provider.Type || "未知"}</span>
                             </span>
+                            {provider.DisabledUntil && new Date(provider.DisabledUntil).getTime() > Date.now() && (
+                              <span className="inline-flex items-center gap-1 text-amber-600">
+                                <span>停用至 {new Date(provider.DisabledUntil).toLocaleString()}</span>
+                                <button type="button" className="underline" onClick={() => handleRestoreProvider(provider)}>
+                                  恢复
+                                </button>
+                              </span>
+                            )}
                           </div>
                         </div>
                         <div className="flex items-center gap-1.5">
@@ -603,6 +654,9 @@ export default function ProvidersPage() {
                       <Button variant="secondary" size="icon" className="h-8 w-8" onClick={() => openModelsDialog(provider.ID)}>
                         <Boxes className="h-4 w-4" />
                       </Button>
+                      <Button variant="outline" size="icon" className="h-8 w-8" title="测试连通性" onClick={() => handleTestConnectivity(provider)}>
+                        <Activity className="h-4 w-4" />
+                      </Button>
                       <AlertDialog>
                         <AlertDialogTrigger asChild>
                           <Button variant="destructive" size="icon" className="h-8 w-8" onClick={() => openDeleteDialog(provider.ID)}>
@@ -793,6 +847,69 @@ export default function ProvidersPage() {
                 )}
               />
 
+              <FormField
+                control={form.control}
+                name="tpmLimit"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>TPM 限制</FormLabel>
+                    <FormControl>
+                      <Input
+                        type="number"
+                        min={0}
+                        placeholder="0 表示无限制"
+                        value={field.value ?? 0}
+                        onChange={(e) => field.onChange(Number(e.target.value) || 0)}
+                      />
+                    </FormControl>
+                    <p className="text-xs text-muted-foreground">
+                      每分钟最大 token 数（按最近 60 秒成功响应的 total_tokens 统计），0 表示无限制。达到限制后会优先使用其他供应商。
+                    </p>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
+
+              <FormField
+                control={form.control}
+                name="maxConcurrency"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>并发上限</FormLabel>
+                    <FormControl>
+                      <Input
+                        type="number"
+                        min={0}
+                        placeholder="0 表示无限制"
+                        value={field.value ?? 0}
+                        onChange={(e) => field.onChange(Number(e.target.value) || 0)}
+                      />
+                    </FormControl>
+                    <p className="text-xs text-muted-foreground">
+                      同时在途（含流式输出中）的请求数上限，0 表示无限制。达到上限后会优先使用其他供应商。
+                    </p>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
+
+              <FormField
+                control={form.control}
+                name="successStatusCodes"
+                render={({ field }) => (
+                  <FormItem>
+                    <FormLabel>成功状态码</FormLabel>
+                    <FormControl>
+                      <Input placeholder="200" {...field} value={field.value ?? ""} />
+                    </FormControl>
+                    <p className="text-xs text-muted-foreground">
+                      视为成功的上游 HTTP 状态码，逗号分隔（如 200,201），留空表示仅 200。429 固定按限流处理。
+                    </p>
+                    <FormMessage />
+                  </FormItem>
+                )}
+              />
+
               <FormField
                 control={form.control}
                 name="ipLockMinutes"
@@ -836,6 +953,11 @@ export default function ProvidersPage() {
             <DialogTitle>{providers.find(v => v.ID === modelsOpenId)?.Name}模型列表</DialogTitle>
             <DialogDescription>
               当前提供商的所有可用模型
+              {modelsCacheAge !== null && !modelsLoading && (
+                <span className="ml-2">
+                  （{modelsCacheAge < 60 ? "刚刚" : `${Math.floor(modelsCacheAge / 60)} 分钟前`}刷新）
+                </span>
+              )}
             </DialogDescription>
           </DialogHeader>
 
@@ -900,6 +1022,13 @@ export default function ProvidersPage() {
           )}
 
           <DialogFooter>
+            <Button
+              variant="outline"
+              disabled={modelsLoading || modelsOpenId === null}
+              onClick={() => modelsOpenId !== null && fetchProviderModels(modelsOpenId, true)}
+            >
+              刷新
+            </Button>
             <Button onClick={() => setModelsOpen(false)}>关闭</Button>
           </DialogFooter>
         </DialogContent>